@@ -1,54 +1,553 @@
 package main
 
 import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
 	"database/sql"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net"
+	"net/http"
 	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/joho/godotenv"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/labstack/echo/otelecho"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/reflection"
 
+	"github.com/aldipi/loan-service/config"
+	"github.com/aldipi/loan-service/data/migration"
+	"github.com/aldipi/loan-service/document"
+	"github.com/aldipi/loan-service/export"
+	"github.com/aldipi/loan-service/fxrate"
+	"github.com/aldipi/loan-service/graphqlhandler"
+	"github.com/aldipi/loan-service/grpchandler"
 	"github.com/aldipi/loan-service/handler"
+	"github.com/aldipi/loan-service/logging"
+	"github.com/aldipi/loan-service/metrics"
+	"github.com/aldipi/loan-service/notifier"
+	"github.com/aldipi/loan-service/payment"
+	loanv1 "github.com/aldipi/loan-service/proto/loan/v1"
+	"github.com/aldipi/loan-service/publisher"
 	"github.com/aldipi/loan-service/repository"
+	"github.com/aldipi/loan-service/storage"
 	"github.com/aldipi/loan-service/usecase"
-	_ "github.com/go-sql-driver/mysql"
+	"github.com/go-sql-driver/mysql"
 )
 
-func init() {
+func main() {
+	// Local development keeps settings in .env, deployments set the
+	// environment directly.
 	err := godotenv.Load()
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		fmt.Fprintln(os.Stderr, "load .env:", err)
+		os.Exit(2)
+	}
+
+	cfg, args, err := config.Load(os.Args[1:], os.LookupEnv, os.Stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
 	if err != nil {
-		panic("Error loading .env file")
+		fmt.Fprintf(os.Stderr, "invalid configuration:\n%v\n", err)
+		os.Exit(2)
 	}
-}
 
-func main() {
-	connStr := os.Getenv("DB_CONN_STRING")
-	if connStr == "" {
-		panic("DB_CONN_STRING environment variable not set")
+	err = run(cfg, args)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
 	}
-	db, err := sql.Open("mysql", connStr)
+}
+
+func run(cfg *config.Config, args []string) error {
+	logger := newLogger(cfg.Log)
+	slog.SetDefault(logger)
+
+	db, err := sql.Open(cfg.DB.Driver, cfg.DB.DSN)
 	if err != nil {
-		panic(err)
+		return err
 	}
 	defer db.Close()
+	db.SetMaxOpenConns(cfg.DB.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.DB.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.DB.ConnMaxLifetime)
+
+	repo := repository.NewLoanRepository(db, repository.WithLogger(logger))
+	blobStore, blobHandler, err := newBlobStore(cfg.Documents)
+	if err != nil {
+		return err
+	}
+	signingKey, err := newSigningKey(cfg.Signing)
+	if err != nil {
+		return err
+	}
+	fxRates, err := newFXRates(cfg.FX)
+	if err != nil {
+		return err
+	}
+
+	registry := metrics.NewRegistry()
+	registry.MustRegister(collectors.NewDBStatsCollector(db, dbName(cfg.DB.DSN)))
+
+	uc := usecase.NewLoanUsecase(repo,
+		usecase.WithNotifier(newNotifier(cfg.Notifier)),
+		usecase.WithDocuments(document.NewPDFGenerator(), blobStore),
+		usecase.WithDocumentHosts(documentHosts(cfg.Documents)...),
+		usecase.WithSignatures(signingKey, cfg.Signing.BaseURL),
+		usecase.WithFundingPeriod(cfg.Loans.FundingPeriod),
+		usecase.WithPaymentRail(payment.NewFakeRail(cfg.Payments.FakeLimit)),
+		usecase.WithFXRates(fxRates),
+		usecase.WithMetrics(metrics.NewLoanMetrics(registry)),
+		usecase.WithExports(export.NewEncoder()),
+		usecase.WithLogger(logger),
+	)
+	metrics.RegisterLoanStates(registry, uc)
+
+	if len(args) > 0 {
+		return runCommand(uc, args[0])
+	}
+
+	// Background workers run until the server has drained its requests, so
+	// events of the last requests are still relayed.
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+	var workers sync.WaitGroup
+	runWorker := func(run func(ctx context.Context)) {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			run(workerCtx)
+		}()
+	}
+
+	readinessChecks := []handler.ReadinessCheck{
+		{Name: "database", Check: repo.Ping},
+		{Name: "schema", Check: schemaCheck(repo)},
+	}
+
+	publishers := []publisher.Publisher{newEventPublisher(cfg.Events)}
+	if cfg.Features.Webhooks {
+		publishers = append(publishers, usecase.NewWebhookPublisher(repo))
+	}
+	relay := usecase.NewOutboxRelay(repo, publisher.NewMultiPublisher(publishers...))
+	relay.Interval = cfg.Workers.OutboxRelayInterval
+	relay.Logger = logger
+	runWorker(relay.Run)
+	readinessChecks = append(readinessChecks, handler.ReadinessCheck{Name: "outbox_relay", Check: relay.Check})
 
-	repo := repository.NewLoanRepository(db)
-	uc := usecase.NewLoanUsecase(repo)
-	h := handler.NewHttpHandler(uc)
+	if cfg.Features.Webhooks {
+		dispatcher := usecase.NewWebhookDispatcher(repo, nil)
+		dispatcher.Interval = cfg.Workers.WebhookDispatcherInterval
+		dispatcher.Logger = logger
+		runWorker(dispatcher.Run)
+		readinessChecks = append(readinessChecks, handler.ReadinessCheck{Name: "webhook_dispatcher", Check: dispatcher.Check})
+	}
+
+	transferWorker := usecase.NewTransferWorker(uc)
+	transferWorker.Interval = cfg.Workers.TransferWorkerInterval
+	transferWorker.RetryAfter = cfg.Workers.TransferRetryAfter
+	transferWorker.Logger = logger
+	runWorker(transferWorker.Run)
+	readinessChecks = append(readinessChecks, handler.ReadinessCheck{Name: "transfer_worker", Check: transferWorker.Check})
+
+	if cfg.Features.Exports {
+		exportWorker := usecase.NewExportWorker(uc)
+		exportWorker.Interval = cfg.Workers.ExportWorkerInterval
+		exportWorker.JobTimeout = cfg.Workers.ExportJobTimeout
+		exportWorker.Logger = logger
+		runWorker(exportWorker.Run)
+		readinessChecks = append(readinessChecks, handler.ReadinessCheck{Name: "export_worker", Check: exportWorker.Check})
+	}
+
+	h := handler.NewHttpHandler(uc,
+		handler.WithLogger(logger),
+		handler.WithReadinessChecks(readinessChecks...),
+	)
 
 	e := echo.New()
-	e.Use(middleware.Logger())
+	e.HideBanner = true
+	e.Server.ReadTimeout = cfg.Server.ReadTimeout
+	e.Server.WriteTimeout = cfg.Server.WriteTimeout
+	e.Server.IdleTimeout = cfg.Server.IdleTimeout
+	e.Use(handler.RequestID())
+	e.Use(handler.RequestLogger(logger))
+	tracerProvider, err := newTracerProvider(context.Background(), cfg.Tracing)
+	if err != nil {
+		return err
+	}
+	if tracerProvider != nil {
+		e.Use(otelecho.Middleware(cfg.Tracing.ServiceName))
+	}
+	e.Use(handler.Metrics(metrics.NewHTTPMetrics(registry)))
 	e.Use(middleware.Recover())
+	if cfg.Auth.Mode == config.AuthModeGateway {
+		// Probes and scrapes do not pass the gateway, and documents are
+		// served by signed URLs.
+		e.Use(handler.GatewayAuth(cfg.Auth.GatewayToken, "/healthz", "/readyz", "/metrics", "/documents/*"))
+	}
+
+	e.GET("/healthz", h.Healthz)
+	e.GET("/readyz", h.Readyz)
+	if cfg.Features.Metrics {
+		e.GET("/metrics", echo.WrapHandler(metrics.Handler(registry, logger)))
+	}
 
 	e.GET("/loans/all", h.GetAllLoans)
 	e.GET("/loans", h.GetLoans)
 	e.POST("/loans", h.CreateLoan)
+	e.GET("/loans/:id", h.GetLoan)
+	e.POST("/loans/bulk-approval", h.BulkApproveLoans)
+	e.POST("/loans/bulk-disbursement", h.BulkDisburseLoans)
 	e.PATCH("/loans/:id/approval", h.ApproveLoan)
 	e.PATCH("/loans/:id/disbursement", h.DisburseLoan)
+	e.POST("/loans/:id/repayments", h.RepayLoan)
 	e.GET("/loans/:id/availability", h.LoanAvailability)
+	e.GET("/loans/:id/documents", h.GetLoanDocuments)
+	e.GET("/loans/:id/signatures", h.GetLoanSignatures)
+
+	e.GET("/signatures/:token", h.GetAgreementToSign)
+	e.POST("/signatures/:token", h.SignAgreement)
+
+	e.GET("/marketplace/loans", h.GetMarketplaceLoans)
 
 	e.GET("/investments", h.GetInvestments)
 	e.POST("/investments", h.CreateInvestment)
+	e.GET("/investors/me/portfolio", h.GetPortfolio)
+	e.GET("/investors/me/wallet", h.GetWallet)
+	e.POST("/investors/me/wallet/deposits", h.Deposit)
+	e.POST("/investors/me/wallet/withdrawals", h.Withdraw)
+
+	e.POST("/loans/:id/approval-proof", h.UploadApprovalProof, middleware.BodyLimit("11M"))
+	e.POST("/loans/:id/signed-agreement", h.UploadSignedAgreement, middleware.BodyLimit("11M"))
+	if blobHandler != nil {
+		e.GET("/documents/*", echo.WrapHandler(http.StripPrefix("/documents", blobHandler)))
+	}
+
+	e.GET("/ledger/trial-balance", h.GetTrialBalance)
+
+	if cfg.Features.Webhooks {
+		e.POST("/webhooks", h.CreateWebhook)
+		e.GET("/webhooks/:id/deliveries", h.GetWebhookDeliveries)
+	}
+
+	if cfg.Features.Exports {
+		e.GET("/exports/loans.:format", h.ExportLoans)
+		e.POST("/exports/loans.:format", h.ExportLoans)
+		e.GET("/exports/investments.:format", h.ExportInvestments)
+		e.POST("/exports/investments.:format", h.ExportInvestments)
+		e.GET("/exports/:id", h.GetExportJob)
+	}
+
+	if cfg.Features.GraphQL {
+		graphqlHandler := graphqlhandler.NewHandler(uc,
+			graphqlhandler.WithLogger(logger),
+			graphqlhandler.WithMaxDepth(cfg.GraphQL.MaxDepth),
+			graphqlhandler.WithMaxQueryLength(cfg.GraphQL.MaxQueryLength),
+			graphqlhandler.WithMaxFields(cfg.GraphQL.MaxFields),
+		)
+		e.POST("/graphql", graphqlHandler.Serve)
+	}
+
+	shutdownSteps := []func(ctx context.Context) error{e.Shutdown}
+	serverErr := make(chan error, 2)
+
+	if cfg.Features.GRPC {
+		interceptors := []grpc.UnaryServerInterceptor{grpchandler.Logger(logger)}
+		gatewayToken := ""
+		if cfg.Auth.Mode == config.AuthModeGateway {
+			gatewayToken = cfg.Auth.GatewayToken
+		}
+		interceptors = append(interceptors, grpchandler.Recovery(logger), grpchandler.Auth(gatewayToken))
+
+		serverOpts := []grpc.ServerOption{grpc.ChainUnaryInterceptor(interceptors...)}
+		if tracerProvider != nil {
+			serverOpts = append(serverOpts, grpc.StatsHandler(otelgrpc.NewServerHandler()))
+		}
+		grpcServer := grpc.NewServer(serverOpts...)
+		loanv1.RegisterLoanServiceServer(grpcServer, grpchandler.NewServer(uc, grpchandler.WithLogger(logger)))
+		reflection.Register(grpcServer)
+
+		listener, err := net.Listen("tcp", cfg.GRPC.Addr)
+		if err != nil {
+			return fmt.Errorf("grpc server failed: %w", err)
+		}
+		go func() {
+			serverErr <- grpcServer.Serve(listener)
+		}()
+		shutdownSteps = append(shutdownSteps, func(ctx context.Context) error {
+			err := waitFunc(grpcServer.GracefulStop)(ctx)
+			if err != nil {
+				grpcServer.Stop()
+			}
+			return err
+		})
+		logger.Info("grpc server started", "addr", cfg.GRPC.Addr)
+	}
 
-	e.Logger.Fatal(e.Start(":8080"))
+	signalCtx, stopSignals := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stopSignals()
+
+	go func() {
+		serverErr <- e.Start(cfg.Server.Addr)
+	}()
+
+	select {
+	case err := <-serverErr:
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			return fmt.Errorf("server failed: %w", err)
+		}
+	case <-signalCtx.Done():
+	}
+
+	shutdown(logger, cfg.Server.ShutdownTimeout, append(shutdownSteps,
+		waitFunc(func() {
+			stopWorkers()
+			workers.Wait()
+		}),
+		waitFunc(uc.Wait),
+		// The tracer provider is shut down last, so it sends the spans of
+		// the drained requests.
+		func(ctx context.Context) error {
+			if tracerProvider == nil {
+				return nil
+			}
+			return tracerProvider.Shutdown(ctx)
+		},
+	))
+	return nil
+}
+
+// shutdown runs the steps of a graceful shutdown in order. All steps share
+// timeout, and steps still running then are abandoned.
+func shutdown(logger *slog.Logger, timeout time.Duration, steps []func(ctx context.Context) error) {
+	logger.Info("shutting down", "timeout", timeout)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	for _, step := range steps {
+		err := step(ctx)
+		if err != nil {
+			logger.Error("shutdown did not complete", "error", err)
+			return
+		}
+	}
+	logger.Info("shutdown complete")
+}
+
+// waitFunc returns a shutdown step running wait, which gives up when the
+// context of the step is done.
+func waitFunc(wait func()) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		done := make(chan struct{})
+		go func() {
+			wait()
+			close(done)
+		}()
+
+		select {
+		case <-done:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// schemaCheck returns a readiness check failing unless the database schema is
+// at the latest migration.
+func schemaCheck(repo *repository.LoanRepository) func(ctx context.Context) error {
+	latest := migration.Latest()
+	return func(ctx context.Context) error {
+		version, dirty, err := repo.GetSchemaVersion(ctx)
+		if err != nil {
+			return err
+		}
+		if dirty {
+			return fmt.Errorf("migration %d did not complete", version)
+		}
+		if version != latest {
+			return fmt.Errorf("schema is at version %d, want %d", version, latest)
+		}
+		return nil
+	}
+}
+
+// newLogger returns the logger writing JSON lines to stdout.
+func newLogger(cfg config.LogConfig) *slog.Logger {
+	// The level is validated with the configuration.
+	level, _ := logging.ParseLevel(cfg.Level)
+	return logging.New(os.Stdout, level)
+}
+
+func newEventPublisher(cfg config.EventConfig) publisher.Publisher {
+	if cfg.Publisher == "memory" {
+		return publisher.NewMemoryPublisher()
+	}
+	return publisher.NewFilePublisher(cfg.File)
+}
+
+// newBlobStore returns the configured document store. The local store also
+// returns the handler serving its signed URLs.
+func newBlobStore(cfg config.DocumentConfig) (usecase.BlobStore, http.Handler, error) {
+	if cfg.Store == "s3" {
+		return storage.NewS3Store(storage.S3Config{
+			Endpoint:        cfg.S3.Endpoint,
+			Region:          cfg.S3.Region,
+			Bucket:          cfg.S3.Bucket,
+			AccessKeyID:     cfg.S3.AccessKeyID,
+			SecretAccessKey: cfg.S3.SecretAccessKey,
+			PathStyle:       cfg.S3.PathStyle,
+		}, nil), nil, nil
+	}
+
+	secret := []byte(cfg.URLSecret)
+	if len(secret) == 0 {
+		// Signed URLs stop working on restart without a configured secret.
+		secret = make([]byte, 32)
+		_, err := rand.Read(secret)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	store := storage.NewLocalStore(cfg.Dir, cfg.BaseURL, secret)
+	return store, store, nil
+}
+
+// documentHosts returns the hosts documents submitted as URLs may be
+// downloaded from, or none to allow every public host.
+func documentHosts(cfg config.DocumentConfig) []string {
+	hosts := []string{}
+	for _, host := range strings.Split(cfg.URLHosts, ",") {
+		if host = strings.TrimSpace(host); host != "" {
+			hosts = append(hosts, host)
+		}
+	}
+	return hosts
+}
+
+// newSigningKey returns the key signing agreements on behalf of the parties.
+func newSigningKey(cfg config.SigningConfig) (ed25519.PrivateKey, error) {
+	// The key is validated with the configuration.
+	seed, _ := hex.DecodeString(cfg.Key)
+	if len(seed) == 0 {
+		// Signatures can not be verified after a restart without a
+		// configured key.
+		seed = make([]byte, ed25519.SeedSize)
+		_, err := rand.Read(seed)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return ed25519.NewKeyFromSeed(seed), nil
+}
+
+// newFXRates returns the FX rates read from the rates file, or none when it
+// is not configured.
+func newFXRates(cfg config.FXConfig) (usecase.FXRateProvider, error) {
+	if cfg.RatesFile == "" {
+		return nil, nil
+	}
+
+	provider, err := fxrate.NewFileProvider(cfg.RatesFile)
+	if err != nil {
+		return nil, fmt.Errorf("fx.rates_file: %w", err)
+	}
+	return provider, nil
+}
+
+// newTracerProvider installs the configured OpenTelemetry tracer provider
+// and W3C Trace Context propagation, or returns nil when tracing is disabled.
+// The stdout exporter writes spans as JSON lines, the otlp exporter sends
+// them to a collector over OTLP/HTTP. Spans are sent in batches until the
+// provider is shut down.
+func newTracerProvider(ctx context.Context, cfg config.TracingConfig) (*sdktrace.TracerProvider, error) {
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch cfg.Exporter {
+	case "stdout":
+		exporter, err = stdouttrace.New()
+	case "otlp":
+		exporter, err = otlptracehttp.New(ctx,
+			otlptracehttp.WithEndpointURL(strings.TrimSuffix(cfg.OTLPEndpoint, "/")+"/v1/traces"),
+		)
+	default:
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("tracing.exporter: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName(cfg.ServiceName))),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	return provider, nil
+}
+
+// dbName returns the name of the database dsn connects to, which labels the
+// connection pool metrics.
+func dbName(dsn string) string {
+	parsed, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		return ""
+	}
+	return parsed.DBName
+}
+
+func newNotifier(cfg config.NotifierConfig) usecase.Notifier {
+	if cfg.Kind == "smtp" {
+		return notifier.NewSMTPNotifier(cfg.SMTP.Addr, cfg.SMTP.From, cfg.SMTP.Username, cfg.SMTP.Password)
+	}
+	return notifier.NewWriterNotifier(os.Stdout)
+}
+
+// runCommand runs a one-off maintenance command instead of the API server.
+func runCommand(uc *usecase.LoanUsecase, command string) error {
+	switch command {
+	case "rebuild-loans":
+		rebuild, err := uc.RebuildLoanProjection(context.Background())
+		if err != nil {
+			return err
+		}
+		fmt.Printf("rebuilt %d loans from loan events\n", rebuild.Rebuilt)
+		if len(rebuild.WithoutEvents) > 0 {
+			fmt.Printf("kept %d loans without events as they are: %v\n", len(rebuild.WithoutEvents), rebuild.WithoutEvents)
+		}
+	case "check-ledger":
+		trialBalance, err := uc.CheckLedger(context.Background())
+		if err != nil {
+			return err
+		}
+		for _, total := range trialBalance.Totals {
+			fmt.Printf("ledger is balanced: %s debits %d, credits %d\n", total.Currency, total.Debit, total.Credit)
+		}
+	default:
+		return fmt.Errorf("unknown command: %s", command)
+	}
+	return nil
 }
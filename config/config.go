@@ -0,0 +1,206 @@
+package config
+
+import (
+	"time"
+)
+
+// Config is the configuration of the service. Every setting has a default,
+// which is overridden by the config file, then by environment variables and
+// then by command line flags. The flag of a setting is its path in the config
+// file, such as -db.max_open_conns.
+type Config struct {
+	Server    ServerConfig   `yaml:"server"`
+	GRPC      GRPCConfig     `yaml:"grpc"`
+	GraphQL   GraphQLConfig  `yaml:"graphql"`
+	DB        DBConfig       `yaml:"db"`
+	Log       LogConfig      `yaml:"log"`
+	Auth      AuthConfig     `yaml:"auth"`
+	Features  FeatureConfig  `yaml:"features"`
+	Workers   WorkerConfig   `yaml:"workers"`
+	Loans     LoanConfig     `yaml:"loans"`
+	Events    EventConfig    `yaml:"events"`
+	Documents DocumentConfig `yaml:"documents"`
+	Signing   SigningConfig  `yaml:"signing"`
+	Payments  PaymentConfig  `yaml:"payments"`
+	FX        FXConfig       `yaml:"fx"`
+	Tracing   TracingConfig  `yaml:"tracing"`
+	Notifier  NotifierConfig `yaml:"notifier"`
+}
+
+type ServerConfig struct {
+	Addr            string        `yaml:"addr" env:"HTTP_ADDR" help:"address the API listens on"`
+	ReadTimeout     time.Duration `yaml:"read_timeout" env:"HTTP_READ_TIMEOUT" help:"maximum duration for reading a request, including its body"`
+	WriteTimeout    time.Duration `yaml:"write_timeout" env:"HTTP_WRITE_TIMEOUT" help:"maximum duration for writing a response"`
+	IdleTimeout     time.Duration `yaml:"idle_timeout" env:"HTTP_IDLE_TIMEOUT" help:"how long idle keep-alive connections are kept open"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT" help:"how long a graceful shutdown may take"`
+}
+
+type GRPCConfig struct {
+	Addr string `yaml:"addr" env:"GRPC_ADDR" help:"address the gRPC API listens on"`
+}
+
+// GraphQLConfig limits the queries of the GraphQL API, so one query can not
+// load the whole database.
+type GraphQLConfig struct {
+	MaxDepth       int `yaml:"max_depth" env:"GRAPHQL_MAX_DEPTH" help:"how deep fields of GraphQL queries may be nested"`
+	MaxQueryLength int `yaml:"max_query_length" env:"GRAPHQL_MAX_QUERY_LENGTH" help:"longest GraphQL query in bytes"`
+	MaxFields      int `yaml:"max_fields" env:"GRAPHQL_MAX_FIELDS" help:"most fields a GraphQL query may resolve, counting every alias and list item"`
+}
+
+type DBConfig struct {
+	Driver          string        `yaml:"driver" env:"DB_DRIVER" help:"database driver, only mysql is supported"`
+	DSN             string        `yaml:"dsn" env:"DB_CONN_STRING" help:"database connection string"`
+	MaxOpenConns    int           `yaml:"max_open_conns" env:"DB_MAX_OPEN_CONNS" help:"maximum open database connections"`
+	MaxIdleConns    int           `yaml:"max_idle_conns" env:"DB_MAX_IDLE_CONNS" help:"maximum idle database connections"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime" env:"DB_CONN_MAX_LIFETIME" help:"how long a database connection is reused"`
+}
+
+type LogConfig struct {
+	Level string `yaml:"level" env:"LOG_LEVEL" help:"log level: debug, info, warn or error"`
+}
+
+// AuthConfig tells how callers are authenticated. Users are always
+// identified by the X-User-Id header set by the auth gateway. In header mode
+// the header is trusted as is, in gateway mode only from requests carrying
+// the gateway token.
+type AuthConfig struct {
+	Mode         string `yaml:"mode" env:"AUTH_MODE" help:"auth mode: header or gateway"`
+	GatewayToken string `yaml:"gateway_token" env:"AUTH_GATEWAY_TOKEN" help:"token the auth gateway sends in X-Gateway-Token, in gateway mode"`
+}
+
+type FeatureConfig struct {
+	Webhooks bool `yaml:"webhooks" env:"FEATURE_WEBHOOKS" help:"serve the webhook API and deliver webhooks"`
+	Metrics  bool `yaml:"metrics" env:"FEATURE_METRICS" help:"serve metrics on /metrics"`
+	GRPC     bool `yaml:"grpc" env:"FEATURE_GRPC" help:"serve the gRPC API"`
+	GraphQL  bool `yaml:"graphql" env:"FEATURE_GRAPHQL" help:"serve the GraphQL API on /graphql"`
+	Exports  bool `yaml:"exports" env:"FEATURE_EXPORTS" help:"serve CSV and XLSX exports and run export jobs"`
+}
+
+type WorkerConfig struct {
+	OutboxRelayInterval       time.Duration `yaml:"outbox_relay_interval" env:"OUTBOX_RELAY_INTERVAL" help:"how often pending events are published"`
+	WebhookDispatcherInterval time.Duration `yaml:"webhook_dispatcher_interval" env:"WEBHOOK_DISPATCHER_INTERVAL" help:"how often pending webhook deliveries are sent"`
+	ExportWorkerInterval      time.Duration `yaml:"export_worker_interval" env:"EXPORT_WORKER_INTERVAL" help:"how often pending export jobs are started"`
+	ExportJobTimeout          time.Duration `yaml:"export_job_timeout" env:"EXPORT_JOB_TIMEOUT" help:"how long an export job may run before it fails, and a job left running by a stopped worker is run again"`
+	TransferWorkerInterval    time.Duration `yaml:"transfer_worker_interval" env:"TRANSFER_WORKER_INTERVAL" help:"how often pending wallet transfers are checked"`
+	TransferRetryAfter        time.Duration `yaml:"transfer_retry_after" env:"TRANSFER_RETRY_AFTER" help:"how long a wallet transfer may stay pending before it is sent to the payment rail again"`
+}
+
+type LoanConfig struct {
+	FundingPeriod time.Duration `yaml:"funding_period" env:"LOAN_FUNDING_PERIOD" help:"how long approved loans are offered to investors"`
+}
+
+type EventConfig struct {
+	Publisher string `yaml:"publisher" env:"EVENT_PUBLISHER" help:"event publisher: file or memory"`
+	File      string `yaml:"file" env:"EVENT_PUBLISHER_FILE" help:"file events are written to by the file publisher"`
+}
+
+type DocumentConfig struct {
+	Store     string   `yaml:"store" env:"BLOB_STORE" help:"document store: local or s3"`
+	Dir       string   `yaml:"dir" env:"DOCUMENT_DIR" help:"directory of the local document store"`
+	BaseURL   string   `yaml:"base_url" env:"DOCUMENT_BASE_URL" help:"base URL of documents in the local document store"`
+	URLSecret string   `yaml:"url_secret" env:"DOCUMENT_URL_SECRET" help:"secret signing document URLs of the local document store, random when empty"`
+	URLHosts  string   `yaml:"url_hosts" env:"DOCUMENT_URL_HOSTS" help:"comma separated hosts documents submitted as URLs are downloaded from, any public host when empty"`
+	S3        S3Config `yaml:"s3"`
+}
+
+type S3Config struct {
+	Endpoint        string `yaml:"endpoint" env:"S3_ENDPOINT" help:"S3 endpoint, AWS when empty"`
+	Region          string `yaml:"region" env:"S3_REGION" help:"S3 region"`
+	Bucket          string `yaml:"bucket" env:"S3_BUCKET" help:"S3 bucket of documents"`
+	AccessKeyID     string `yaml:"access_key_id" env:"S3_ACCESS_KEY_ID" help:"S3 access key ID"`
+	SecretAccessKey string `yaml:"secret_access_key" env:"S3_SECRET_ACCESS_KEY" help:"S3 secret access key"`
+	PathStyle       bool   `yaml:"path_style" env:"S3_PATH_STYLE" help:"address the bucket in the URL path rather than the host"`
+}
+
+type SigningConfig struct {
+	Key     string `yaml:"key" env:"SIGNING_KEY" help:"hex encoded Ed25519 seed signing agreements, random when empty"`
+	BaseURL string `yaml:"base_url" env:"SIGNING_BASE_URL" help:"base URL of signing links sent to the parties"`
+}
+
+type PaymentConfig struct {
+	Rail      string `yaml:"rail" env:"PAYMENT_RAIL" help:"payment rail: fake"`
+	FakeLimit int64  `yaml:"fake_limit" env:"FAKE_PAYMENT_LIMIT" help:"transfers above this amount are declined by the fake rail, 0 for no limit"`
+}
+
+type FXConfig struct {
+	RatesFile string `yaml:"rates_file" env:"FX_RATES_FILE" help:"file of FX rates, cross-currency investments are rejected when empty"`
+}
+
+type TracingConfig struct {
+	Exporter     string `yaml:"exporter" env:"OTEL_TRACES_EXPORTER" help:"trace exporter: none, stdout or otlp"`
+	OTLPEndpoint string `yaml:"otlp_endpoint" env:"OTEL_EXPORTER_OTLP_ENDPOINT" help:"OTLP/HTTP collector endpoint"`
+	ServiceName  string `yaml:"service_name" env:"OTEL_SERVICE_NAME" help:"service name of the spans"`
+}
+
+type NotifierConfig struct {
+	Kind string     `yaml:"kind" env:"NOTIFIER" help:"notifier: log or smtp"`
+	SMTP SMTPConfig `yaml:"smtp"`
+}
+
+type SMTPConfig struct {
+	Addr     string `yaml:"addr" env:"SMTP_ADDR" help:"SMTP server address, host:port"`
+	From     string `yaml:"from" env:"SMTP_FROM" help:"sender address of notifications"`
+	Username string `yaml:"username" env:"SMTP_USERNAME" help:"SMTP username"`
+	Password string `yaml:"password" env:"SMTP_PASSWORD" help:"SMTP password"`
+}
+
+// Default returns the configuration used when nothing is configured.
+func Default() *Config {
+	return &Config{
+		Server: ServerConfig{
+			Addr:            ":8080",
+			ReadTimeout:     30 * time.Second,
+			WriteTimeout:    30 * time.Second,
+			IdleTimeout:     2 * time.Minute,
+			ShutdownTimeout: 30 * time.Second,
+		},
+		GRPC: GRPCConfig{Addr: ":9090"},
+		GraphQL: GraphQLConfig{
+			MaxDepth:       8,
+			MaxQueryLength: 10000,
+			MaxFields:      5000,
+		},
+		DB: DBConfig{
+			Driver:          "mysql",
+			MaxOpenConns:    25,
+			MaxIdleConns:    10,
+			ConnMaxLifetime: 5 * time.Minute,
+		},
+		Log:  LogConfig{Level: "info"},
+		Auth: AuthConfig{Mode: AuthModeHeader},
+		Features: FeatureConfig{
+			Webhooks: true,
+			Metrics:  true,
+			GRPC:     true,
+			GraphQL:  true,
+			Exports:  true,
+		},
+		Workers: WorkerConfig{
+			OutboxRelayInterval:       time.Second,
+			WebhookDispatcherInterval: 5 * time.Second,
+			ExportWorkerInterval:      5 * time.Second,
+			ExportJobTimeout:          time.Hour,
+			TransferWorkerInterval:    30 * time.Second,
+			TransferRetryAfter:        time.Minute,
+		},
+		Loans:  LoanConfig{FundingPeriod: 30 * 24 * time.Hour},
+		Events: EventConfig{Publisher: "file", File: "events.jsonl"},
+		Documents: DocumentConfig{
+			Store:   "local",
+			Dir:     "documents",
+			BaseURL: "http://localhost:8080/documents",
+		},
+		Payments: PaymentConfig{Rail: "fake"},
+		Tracing: TracingConfig{
+			Exporter:     "none",
+			OTLPEndpoint: "http://localhost:4318",
+			ServiceName:  "loan-service",
+		},
+		Notifier: NotifierConfig{Kind: "log"},
+	}
+}
+
+const (
+	AuthModeHeader  = "header"
+	AuthModeGateway = "gateway"
+)
@@ -0,0 +1,150 @@
+package config
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func env(values map[string]string) func(string) (string, bool) {
+	return func(name string) (string, bool) {
+		value, ok := values[name]
+		return value, ok
+	}
+}
+
+func writeFile(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestLoadDefaults(t *testing.T) {
+	cfg, args, err := Load(nil, env(map[string]string{"DB_CONN_STRING": "root@/loan_service"}), io.Discard)
+
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Empty(t, args)
+	want := Default()
+	want.DB.DSN = "root@/loan_service"
+	assert.Equal(t, want, cfg)
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	path := writeFile(t, `
+server:
+  addr: ":9000"
+  shutdown_timeout: 10s
+db:
+  dsn: file-dsn
+  max_open_conns: 50
+  max_idle_conns: 20
+features:
+  webhooks: false
+`)
+
+	cfg, args, err := Load(
+		[]string{"-config", path, "-db.max_open_conns", "100", "-features.webhooks", "rebuild-loans"},
+		env(map[string]string{
+			"DB_CONN_STRING":    "env-dsn",
+			"DB_MAX_OPEN_CONNS": "75",
+			"SHUTDOWN_TIMEOUT":  "",
+		}),
+		io.Discard,
+	)
+
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, []string{"rebuild-loans"}, args)
+	assert.Equal(t, ":9000", cfg.Server.Addr)
+	assert.Equal(t, 10*time.Second, cfg.Server.ShutdownTimeout, "empty env does not override the file")
+	assert.Equal(t, "env-dsn", cfg.DB.DSN)
+	assert.Equal(t, 100, cfg.DB.MaxOpenConns)
+	assert.Equal(t, 20, cfg.DB.MaxIdleConns)
+	assert.True(t, cfg.Features.Webhooks)
+	assert.Equal(t, 5*time.Minute, cfg.DB.ConnMaxLifetime)
+}
+
+func TestLoadConfigFileFromEnv(t *testing.T) {
+	path := writeFile(t, "db:\n  dsn: file-dsn\n")
+
+	cfg, _, err := Load(nil, env(map[string]string{ConfigFileEnv: path}), io.Discard)
+
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "file-dsn", cfg.DB.DSN)
+}
+
+func TestLoadReportsEveryError(t *testing.T) {
+	path := writeFile(t, "db:\n  dsn: file-dsn\n  max_open_connections: 5\n")
+
+	_, _, err := Load(
+		[]string{"-config", path, "-server.read_timeout", "soon", "-auth.mode", "gateway"},
+		env(map[string]string{
+			"DB_MAX_IDLE_CONNS":     "many",
+			"FEATURE_METRICS":       "maybe",
+			"OUTBOX_RELAY_INTERVAL": "0s",
+			"NOTIFIER":              "pigeon",
+		}),
+		io.Discard,
+	)
+
+	assert.Error(t, err)
+	for _, want := range []string{
+		"field max_open_connections not found",
+		`DB_MAX_IDLE_CONNS: invalid integer "many"`,
+		`FEATURE_METRICS: invalid boolean "maybe"`,
+		`-server.read_timeout: invalid duration "soon"`,
+		"auth.gateway_token is required in gateway mode",
+		"workers.outbox_relay_interval must be positive",
+		`notifier.kind must be one of log, smtp, got "pigeon"`,
+	} {
+		assert.ErrorContains(t, err, want)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	valid := func() *Config {
+		cfg := Default()
+		cfg.DB.DSN = "root@/loan_service"
+		return cfg
+	}
+
+	tests := []struct {
+		name   string
+		modify func(cfg *Config)
+		want   string
+	}{
+		{"unsupported driver", func(cfg *Config) { cfg.DB.Driver = "postgres" }, `db.driver must be one of mysql, got "postgres"`},
+		{"idle above open", func(cfg *Config) { cfg.DB.MaxIdleConns = 30 }, "db.max_idle_conns must not exceed db.max_open_conns"},
+		{"signing key size", func(cfg *Config) { cfg.Signing.Key = "abcd" }, "signing.key must be 32 hex encoded bytes"},
+		{"otlp endpoint", func(cfg *Config) {
+			cfg.Tracing.Exporter = "otlp"
+			cfg.Tracing.OTLPEndpoint = "localhost:4318"
+		}, "tracing.otlp_endpoint must be an http or https URL"},
+		{"smtp", func(cfg *Config) { cfg.Notifier.Kind = "smtp" }, "notifier.smtp.addr is required by the smtp notifier"},
+		{"file publisher", func(cfg *Config) { cfg.Events.File = "" }, "events.file is required by the file publisher"},
+		{"graphql limits", func(cfg *Config) { cfg.GraphQL.MaxQueryLength = 0 }, "graphql.max_query_length must be positive"},
+		{"graphql field limit", func(cfg *Config) { cfg.GraphQL.MaxFields = 0 }, "graphql.max_fields must be positive"},
+		{"export worker interval", func(cfg *Config) { cfg.Workers.ExportWorkerInterval = 0 }, "workers.export_worker_interval must be positive"},
+		{"export job timeout", func(cfg *Config) { cfg.Workers.ExportJobTimeout = 0 }, "workers.export_job_timeout must be positive"},
+		{"transfer worker interval", func(cfg *Config) { cfg.Workers.TransferWorkerInterval = 0 }, "workers.transfer_worker_interval must be positive"},
+		{"transfer retry", func(cfg *Config) { cfg.Workers.TransferRetryAfter = 0 }, "workers.transfer_retry_after must be positive"},
+	}
+
+	assert.NoError(t, valid().Validate())
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := valid()
+			tt.modify(cfg)
+			assert.ErrorContains(t, cfg.Validate(), tt.want)
+		})
+	}
+}
@@ -0,0 +1,190 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigFileEnv names the config file when the -config flag is not set.
+const ConfigFileEnv = "CONFIG_FILE"
+
+// Load returns the configuration set by the optional YAML config file, the
+// environment and the command line args, along with the args left after the
+// flags. Every invalid setting is reported in the returned error, not only
+// the first. Empty environment variables are treated as unset.
+//
+// Load returns flag.ErrHelp when args ask for help, after writing the usage
+// to output.
+func Load(args []string, lookupEnv func(string) (string, bool), output io.Writer) (*Config, []string, error) {
+	cfg := Default()
+	settings := settingsOf(cfg)
+
+	fs := flag.NewFlagSet("loan-service", flag.ContinueOnError)
+	fs.SetOutput(output)
+	configFile := fs.String("config", "", "YAML config file, also set by "+ConfigFileEnv)
+	var flags []flagValue
+	for _, s := range settings {
+		fs.Var(&flagValue{setting: s, set: &flags}, s.path, s.usage())
+	}
+	err := fs.Parse(args)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var errs []error
+	path := *configFile
+	if path == "" {
+		path, _ = lookupEnv(ConfigFileEnv)
+	}
+	if path != "" {
+		err = loadFile(cfg, path)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	for _, s := range settings {
+		value, ok := lookupEnv(s.env)
+		if !ok || value == "" {
+			continue
+		}
+		err = s.set(value)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", s.env, err))
+		}
+	}
+
+	for _, f := range flags {
+		err = f.setting.set(f.value)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("-%s: %w", f.setting.path, err))
+		}
+	}
+
+	err = cfg.Validate()
+	if err != nil {
+		errs = append(errs, err)
+	}
+	if len(errs) > 0 {
+		return nil, nil, errors.Join(errs...)
+	}
+
+	return cfg, fs.Args(), nil
+}
+
+// loadFile overrides the settings of cfg set in the YAML file at path.
+// Unknown keys are rejected, so misspelled settings are not ignored.
+func loadFile(cfg *Config, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("config file: %w", err)
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	err = decoder.Decode(cfg)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// setting is a configurable field of Config.
+type setting struct {
+	path  string
+	env   string
+	help  string
+	value reflect.Value
+}
+
+// settingsOf returns the settings of cfg, with values pointing into cfg.
+func settingsOf(cfg *Config) []*setting {
+	var settings []*setting
+	var walk func(prefix string, v reflect.Value)
+	walk = func(prefix string, v reflect.Value) {
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			path := prefix + field.Tag.Get("yaml")
+			if field.Type.Kind() == reflect.Struct {
+				walk(path+".", v.Field(i))
+				continue
+			}
+			settings = append(settings, &setting{
+				path:  path,
+				env:   field.Tag.Get("env"),
+				help:  field.Tag.Get("help"),
+				value: v.Field(i),
+			})
+		}
+	}
+	walk("", reflect.ValueOf(cfg).Elem())
+	return settings
+}
+
+func (s *setting) usage() string {
+	return s.help + " (" + s.env + ")"
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+func (s *setting) set(value string) error {
+	switch {
+	case s.value.Type() == durationType:
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", value)
+		}
+		s.value.SetInt(int64(d))
+	case s.value.Kind() == reflect.String:
+		s.value.SetString(value)
+	case s.value.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", value)
+		}
+		s.value.SetBool(b)
+	case s.value.Kind() == reflect.Int || s.value.Kind() == reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", value)
+		}
+		s.value.SetInt(n)
+	default:
+		panic("config: unsupported setting type " + s.value.Type().String())
+	}
+	return nil
+}
+
+// flagValue records the flags set on the command line, so they are applied
+// after the config file and the environment.
+type flagValue struct {
+	setting *setting
+	set     *[]flagValue
+	value   string
+}
+
+func (f *flagValue) String() string {
+	if f.setting == nil {
+		return ""
+	}
+	return strings.TrimSpace(fmt.Sprint(f.setting.value.Interface()))
+}
+
+func (f *flagValue) Set(value string) error {
+	*f.set = append(*f.set, flagValue{setting: f.setting, value: value})
+	return nil
+}
+
+func (f *flagValue) IsBoolFlag() bool {
+	return f.setting != nil && f.setting.value.Kind() == reflect.Bool
+}
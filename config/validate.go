@@ -0,0 +1,126 @@
+package config
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/aldipi/loan-service/logging"
+)
+
+// Validate reports every invalid setting of c.
+func (c *Config) Validate() error {
+	v := &validator{}
+
+	v.check(c.Server.Addr != "", "server.addr is required")
+	v.check(c.Server.ReadTimeout >= 0, "server.read_timeout must not be negative")
+	v.check(c.Server.WriteTimeout >= 0, "server.write_timeout must not be negative")
+	v.check(c.Server.IdleTimeout >= 0, "server.idle_timeout must not be negative")
+	v.check(c.Server.ShutdownTimeout > 0, "server.shutdown_timeout must be positive")
+
+	if c.Features.GRPC {
+		v.check(c.GRPC.Addr != "", "grpc.addr is required to serve the gRPC API")
+		v.check(c.GRPC.Addr != c.Server.Addr, "grpc.addr must differ from server.addr")
+	}
+
+	if c.Features.GraphQL {
+		v.check(c.GraphQL.MaxDepth > 0, "graphql.max_depth must be positive")
+		v.check(c.GraphQL.MaxQueryLength > 0, "graphql.max_query_length must be positive")
+		v.check(c.GraphQL.MaxFields > 0, "graphql.max_fields must be positive")
+	}
+
+	// Queries are written for MySQL.
+	v.oneOf("db.driver", c.DB.Driver, "mysql")
+	v.check(c.DB.DSN != "", "db.dsn is required, set DB_CONN_STRING")
+	v.check(c.DB.MaxOpenConns >= 0, "db.max_open_conns must not be negative")
+	v.check(c.DB.MaxIdleConns >= 0, "db.max_idle_conns must not be negative")
+	v.check(c.DB.MaxOpenConns == 0 || c.DB.MaxIdleConns <= c.DB.MaxOpenConns, "db.max_idle_conns must not exceed db.max_open_conns")
+	v.check(c.DB.ConnMaxLifetime >= 0, "db.conn_max_lifetime must not be negative")
+
+	_, err := logging.ParseLevel(c.Log.Level)
+	v.check(err == nil, "log.level: %v", err)
+
+	v.oneOf("auth.mode", c.Auth.Mode, AuthModeHeader, AuthModeGateway)
+	if c.Auth.Mode == AuthModeGateway {
+		v.check(c.Auth.GatewayToken != "", "auth.gateway_token is required in gateway mode")
+	}
+
+	v.check(c.Workers.OutboxRelayInterval > 0, "workers.outbox_relay_interval must be positive")
+	v.check(c.Workers.WebhookDispatcherInterval > 0, "workers.webhook_dispatcher_interval must be positive")
+	v.check(c.Workers.ExportWorkerInterval > 0, "workers.export_worker_interval must be positive")
+	v.check(c.Workers.ExportJobTimeout > 0, "workers.export_job_timeout must be positive")
+	v.check(c.Workers.TransferWorkerInterval > 0, "workers.transfer_worker_interval must be positive")
+	v.check(c.Workers.TransferRetryAfter > 0, "workers.transfer_retry_after must be positive")
+
+	v.check(c.Loans.FundingPeriod > 0, "loans.funding_period must be positive")
+
+	v.oneOf("events.publisher", c.Events.Publisher, "file", "memory")
+	if c.Events.Publisher == "file" {
+		v.check(c.Events.File != "", "events.file is required by the file publisher")
+	}
+
+	v.oneOf("documents.store", c.Documents.Store, "local", "s3")
+	switch c.Documents.Store {
+	case "local":
+		v.check(c.Documents.Dir != "", "documents.dir is required by the local store")
+		v.url("documents.base_url", c.Documents.BaseURL)
+	case "s3":
+		v.check(c.Documents.S3.Region != "", "documents.s3.region is required by the s3 store")
+		v.check(c.Documents.S3.Bucket != "", "documents.s3.bucket is required by the s3 store")
+		v.check(c.Documents.S3.AccessKeyID != "", "documents.s3.access_key_id is required by the s3 store")
+		v.check(c.Documents.S3.SecretAccessKey != "", "documents.s3.secret_access_key is required by the s3 store")
+		if c.Documents.S3.Endpoint != "" {
+			v.url("documents.s3.endpoint", c.Documents.S3.Endpoint)
+		}
+	}
+
+	if c.Signing.Key != "" {
+		seed, err := hex.DecodeString(c.Signing.Key)
+		v.check(err == nil && len(seed) == SigningKeySize, "signing.key must be %d hex encoded bytes", SigningKeySize)
+	}
+	if c.Signing.BaseURL != "" {
+		v.url("signing.base_url", c.Signing.BaseURL)
+	}
+
+	v.oneOf("payments.rail", c.Payments.Rail, "fake")
+	v.check(c.Payments.FakeLimit >= 0, "payments.fake_limit must not be negative")
+
+	v.oneOf("tracing.exporter", c.Tracing.Exporter, "none", "stdout", "otlp")
+	if c.Tracing.Exporter == "otlp" {
+		v.url("tracing.otlp_endpoint", c.Tracing.OTLPEndpoint)
+		v.check(c.Tracing.ServiceName != "", "tracing.service_name is required by the otlp exporter")
+	}
+
+	v.oneOf("notifier.kind", c.Notifier.Kind, "log", "smtp")
+	if c.Notifier.Kind == "smtp" {
+		v.check(c.Notifier.SMTP.Addr != "", "notifier.smtp.addr is required by the smtp notifier")
+		v.check(c.Notifier.SMTP.From != "", "notifier.smtp.from is required by the smtp notifier")
+	}
+
+	return errors.Join(v.errs...)
+}
+
+// SigningKeySize is the size of the Ed25519 seed in signing.key.
+const SigningKeySize = 32
+
+type validator struct {
+	errs []error
+}
+
+func (v *validator) check(ok bool, format string, args ...any) {
+	if !ok {
+		v.errs = append(v.errs, fmt.Errorf(format, args...))
+	}
+}
+
+func (v *validator) oneOf(path, value string, allowed ...string) {
+	v.check(slices.Contains(allowed, value), "%s must be one of %s, got %q", path, strings.Join(allowed, ", "), value)
+}
+
+func (v *validator) url(path, value string) {
+	u, err := url.Parse(value)
+	v.check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "", "%s must be an http or https URL", path)
+}
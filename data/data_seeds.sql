@@ -1,8 +1,8 @@
 -- Insert dummy data into users table
-INSERT INTO users (name) VALUES
-('John Doe'),
-('Foo Bar'),
-('Putra Raja');
+INSERT INTO users (name, email, locale) VALUES
+('John Doe', 'john.doe@example.com', 'en'),
+('Foo Bar', 'foo.bar@example.com', 'en'),
+('Putra Raja', 'putra.raja@example.com', 'id');
 
 -- Insert dummy data into employees table
 INSERT INTO employees (name) VALUES
@@ -11,10 +11,10 @@ INSERT INTO employees (name) VALUES
 ('Roger');
 
 -- Insert dummy data into investors table
-INSERT INTO investors (name) VALUES
-('Investor A'),
-('Investor B'),
-('Investor C');
+INSERT INTO investors (name, email, locale) VALUES
+('Investor A', 'investor.a@example.com', 'en'),
+('Investor B', 'investor.b@example.com', 'id'),
+('Investor C', 'investor.c@example.com', 'en');
 
 -- Insert dummy data into loan_products table
 INSERT INTO loan_products (name, rate, roi) VALUES
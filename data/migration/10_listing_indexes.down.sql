@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_investments_investor_amount;
+DROP INDEX IF EXISTS idx_investments_investor_created_at;
+DROP INDEX IF EXISTS idx_loans_state;
+DROP INDEX IF EXISTS idx_loans_principal_amount;
+DROP INDEX IF EXISTS idx_loans_created_at;
@@ -0,0 +1,5 @@
+CREATE INDEX idx_loans_created_at ON loans(created_at, id);
+CREATE INDEX idx_loans_principal_amount ON loans(principal_amount, id);
+CREATE INDEX idx_loans_state ON loans(state);
+CREATE INDEX idx_investments_investor_created_at ON investments(investor_id, created_at, id);
+CREATE INDEX idx_investments_investor_amount ON investments(investor_id, amount, id);
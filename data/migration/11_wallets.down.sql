@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_wallet_entries_investor;
+
+DROP TABLE IF EXISTS wallet_entries;
+DROP TABLE IF EXISTS wallets;
@@ -0,0 +1,45 @@
+CREATE TABLE wallets (
+    investor_id BIGINT PRIMARY KEY,
+    available INT NOT NULL DEFAULT 0,
+    reserved INT NOT NULL DEFAULT 0,
+    deployed INT NOT NULL DEFAULT 0,
+    last_updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE wallet_entries (
+    id SERIAL PRIMARY KEY,
+    investor_id BIGINT NOT NULL,
+    type VARCHAR(32) NOT NULL,
+    amount INT NOT NULL,
+    loan_id BIGINT,
+    investment_id BIGINT,
+    reference VARCHAR(255),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_wallet_entries_investor ON wallet_entries(investor_id, type);
+
+-- Investments made before wallets existed are funded by an opening deposit, so
+-- their reservations can be released once their loans are fully funded.
+INSERT INTO wallet_entries (investor_id, type, amount, loan_id, investment_id, reference, created_at)
+SELECT investor_id, 'deposit', amount, loan_id, id, 'opening balance', created_at
+FROM investments;
+
+INSERT INTO wallet_entries (investor_id, type, amount, loan_id, investment_id, created_at)
+SELECT investor_id, 'reservation', amount, loan_id, id, created_at
+FROM investments;
+
+INSERT INTO wallet_entries (investor_id, type, amount, loan_id, investment_id, created_at)
+SELECT i.investor_id, 'release', i.amount, i.loan_id, i.id, l.last_updated_at
+FROM investments i
+JOIN loans l ON l.id = i.loan_id
+WHERE l.state > 1;
+
+INSERT INTO wallets (investor_id, reserved, deployed)
+SELECT
+    i.investor_id,
+    SUM(CASE WHEN l.state > 1 THEN 0 ELSE i.amount END),
+    SUM(CASE WHEN l.state > 1 THEN i.amount ELSE 0 END)
+FROM investments i
+JOIN loans l ON l.id = i.loan_id
+GROUP BY i.investor_id;
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_journal_lines_account;
+DROP INDEX IF EXISTS idx_journal_lines_entry;
+
+DROP TABLE IF EXISTS journal_lines;
+DROP TABLE IF EXISTS journal_entries;
@@ -0,0 +1,19 @@
+CREATE TABLE journal_entries (
+    id SERIAL PRIMARY KEY,
+    kind VARCHAR(32) NOT NULL,
+    loan_id BIGINT,
+    investment_id BIGINT,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE journal_lines (
+    id SERIAL PRIMARY KEY,
+    journal_entry_id BIGINT NOT NULL,
+    account VARCHAR(32) NOT NULL,
+    owner_id BIGINT NOT NULL DEFAULT 0,
+    debit INT NOT NULL DEFAULT 0,
+    credit INT NOT NULL DEFAULT 0
+);
+
+CREATE INDEX idx_journal_lines_entry ON journal_lines(journal_entry_id);
+CREATE INDEX idx_journal_lines_account ON journal_lines(account, owner_id);
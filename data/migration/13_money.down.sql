@@ -0,0 +1,57 @@
+UPDATE outbox_messages
+SET payload = JSON_SET(payload,
+    '$.amount', CAST(JSON_UNQUOTE(JSON_EXTRACT(payload, '$.amount')) AS SIGNED) DIV 100)
+WHERE event_type = 'InvestmentMade' AND published_at IS NULL;
+
+UPDATE outbox_messages
+SET payload = JSON_REMOVE(JSON_SET(payload,
+    '$.principal_amount', CAST(JSON_UNQUOTE(JSON_EXTRACT(payload, '$.principal_amount')) AS SIGNED) DIV 100),
+    '$.currency')
+WHERE event_type = 'LoanProposed' AND published_at IS NULL;
+
+UPDATE loan_events
+SET payload = JSON_SET(payload,
+    '$.amount', CAST(JSON_UNQUOTE(JSON_EXTRACT(payload, '$.amount')) AS SIGNED) DIV 100)
+WHERE type = 'InvestmentMade';
+
+UPDATE loan_events
+SET payload = JSON_REMOVE(JSON_SET(payload,
+    '$.principal_amount', CAST(JSON_UNQUOTE(JSON_EXTRACT(payload, '$.principal_amount')) AS SIGNED) DIV 100),
+    '$.currency')
+WHERE type = 'LoanProposed';
+
+UPDATE journal_lines SET debit = debit DIV 100, credit = credit DIV 100;
+ALTER TABLE journal_lines
+    MODIFY debit INT NOT NULL DEFAULT 0,
+    MODIFY credit INT NOT NULL DEFAULT 0;
+
+ALTER TABLE journal_entries
+    DROP COLUMN currency;
+
+UPDATE wallet_entries SET amount = amount DIV 100;
+ALTER TABLE wallet_entries
+    DROP COLUMN currency,
+    MODIFY amount INT NOT NULL;
+
+UPDATE wallets SET available = available DIV 100, reserved = reserved DIV 100, deployed = deployed DIV 100;
+ALTER TABLE wallets
+    DROP COLUMN currency,
+    MODIFY available INT NOT NULL DEFAULT 0,
+    MODIFY reserved INT NOT NULL DEFAULT 0,
+    MODIFY deployed INT NOT NULL DEFAULT 0;
+
+UPDATE investments SET amount = amount DIV 100;
+ALTER TABLE investments
+    DROP COLUMN currency,
+    MODIFY amount INT NOT NULL;
+
+UPDATE loans SET principal_amount = principal_amount DIV 100;
+ALTER TABLE loans
+    DROP COLUMN currency,
+    MODIFY principal_amount INT NOT NULL;
+
+ALTER TABLE investors
+    DROP COLUMN currency;
+
+ALTER TABLE loan_products
+    DROP COLUMN currency;
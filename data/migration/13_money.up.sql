@@ -0,0 +1,61 @@
+-- Amounts were whole rupiah. They become BIGINT counts of the minor unit of
+-- their currency, so existing IDR amounts are multiplied by 100.
+ALTER TABLE loan_products
+    ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'IDR' AFTER roi;
+
+ALTER TABLE investors
+    ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'IDR' AFTER locale;
+
+ALTER TABLE loans
+    MODIFY principal_amount BIGINT NOT NULL,
+    ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'IDR' AFTER principal_amount;
+UPDATE loans SET principal_amount = principal_amount * 100;
+
+ALTER TABLE investments
+    MODIFY amount BIGINT NOT NULL,
+    ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'IDR' AFTER amount;
+UPDATE investments SET amount = amount * 100;
+
+ALTER TABLE wallets
+    ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'IDR' AFTER investor_id,
+    MODIFY available BIGINT NOT NULL DEFAULT 0,
+    MODIFY reserved BIGINT NOT NULL DEFAULT 0,
+    MODIFY deployed BIGINT NOT NULL DEFAULT 0;
+UPDATE wallets SET available = available * 100, reserved = reserved * 100, deployed = deployed * 100;
+
+ALTER TABLE wallet_entries
+    MODIFY amount BIGINT NOT NULL,
+    ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'IDR' AFTER amount;
+UPDATE wallet_entries SET amount = amount * 100;
+
+ALTER TABLE journal_entries
+    ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'IDR' AFTER kind;
+
+ALTER TABLE journal_lines
+    MODIFY debit BIGINT NOT NULL DEFAULT 0,
+    MODIFY credit BIGINT NOT NULL DEFAULT 0;
+UPDATE journal_lines SET debit = debit * 100, credit = credit * 100;
+
+-- Event payloads carry amounts as strings of minor units, so the loans
+-- projection can still be rebuilt from them.
+UPDATE loan_events
+SET payload = JSON_SET(payload,
+    '$.principal_amount', CAST(JSON_EXTRACT(payload, '$.principal_amount') * 100 AS CHAR),
+    '$.currency', 'IDR')
+WHERE type = 'LoanProposed';
+
+UPDATE loan_events
+SET payload = JSON_SET(payload,
+    '$.amount', CAST(JSON_EXTRACT(payload, '$.amount') * 100 AS CHAR))
+WHERE type = 'InvestmentMade';
+
+UPDATE outbox_messages
+SET payload = JSON_SET(payload,
+    '$.principal_amount', CAST(JSON_EXTRACT(payload, '$.principal_amount') * 100 AS CHAR),
+    '$.currency', 'IDR')
+WHERE event_type = 'LoanProposed' AND published_at IS NULL;
+
+UPDATE outbox_messages
+SET payload = JSON_SET(payload,
+    '$.amount', CAST(JSON_EXTRACT(payload, '$.amount') * 100 AS CHAR))
+WHERE event_type = 'InvestmentMade' AND published_at IS NULL;
@@ -0,0 +1,4 @@
+ALTER TABLE investments
+    DROP COLUMN fx_rate,
+    DROP COLUMN settlement_currency,
+    DROP COLUMN settlement_amount;
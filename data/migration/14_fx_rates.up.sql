@@ -0,0 +1,6 @@
+-- Investments were always paid in the currency of their loan.
+ALTER TABLE investments
+    ADD COLUMN settlement_amount BIGINT NOT NULL DEFAULT 0 AFTER currency,
+    ADD COLUMN settlement_currency CHAR(3) NOT NULL DEFAULT 'IDR' AFTER settlement_amount,
+    ADD COLUMN fx_rate DECIMAL(20, 10) NOT NULL DEFAULT 1 AFTER settlement_currency;
+UPDATE investments SET settlement_amount = amount, settlement_currency = currency;
@@ -0,0 +1,2 @@
+-- The migration runner records the previous version in schema_migrations.
+DO 0;
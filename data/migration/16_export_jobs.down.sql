@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_export_jobs_state;
+
+DROP TABLE IF EXISTS export_jobs;
@@ -0,0 +1,18 @@
+CREATE TABLE export_jobs (
+    id SERIAL PRIMARY KEY,
+    kind VARCHAR(32) NOT NULL,
+    format VARCHAR(8) NOT NULL,
+    filter JSON NOT NULL,
+    requested_by_role VARCHAR(16) NOT NULL,
+    requested_by BIGINT NOT NULL,
+    state VARCHAR(16) NOT NULL DEFAULT 'pending',
+    row_count BIGINT NOT NULL DEFAULT 0,
+    file_key VARCHAR(255),
+    last_error TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    started_at TIMESTAMP NULL,
+    completed_at TIMESTAMP NULL,
+    last_updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_export_jobs_state ON export_jobs(state, id);
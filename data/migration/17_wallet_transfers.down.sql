@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS idx_wallet_entries_status;
+DROP INDEX IF EXISTS idx_wallet_entries_idempotency_key;
+
+-- Reversed transfers never moved any money.
+DELETE FROM wallet_entries WHERE status = 'reversed';
+
+ALTER TABLE wallet_entries
+    DROP COLUMN idempotency_key,
+    DROP COLUMN status;
@@ -0,0 +1,9 @@
+-- Deposits and withdrawals are recorded as pending before the payment rail is
+-- called, and settled or reversed once it answers. Existing entries have all
+-- been settled.
+ALTER TABLE wallet_entries
+    ADD COLUMN status VARCHAR(16) NOT NULL DEFAULT 'settled' AFTER currency,
+    ADD COLUMN idempotency_key VARCHAR(64) AFTER reference;
+
+CREATE UNIQUE INDEX idx_wallet_entries_idempotency_key ON wallet_entries(idempotency_key);
+CREATE INDEX idx_wallet_entries_status ON wallet_entries(status, created_at);
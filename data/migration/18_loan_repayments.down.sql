@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_loan_repayments_loan;
+
+DROP TABLE IF EXISTS loan_repayments;
@@ -0,0 +1,13 @@
+CREATE TABLE loan_repayments (
+    id SERIAL PRIMARY KEY,
+    loan_id BIGINT NOT NULL,
+    amount BIGINT NOT NULL,
+    principal BIGINT NOT NULL,
+    interest BIGINT NOT NULL,
+    fee BIGINT NOT NULL,
+    currency CHAR(3) NOT NULL,
+    recorded_by BIGINT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_loan_repayments_loan ON loan_repayments(loan_id);
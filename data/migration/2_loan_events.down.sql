@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_loan_events_loan_id;
+
+DROP TABLE IF EXISTS loan_events;
@@ -0,0 +1,10 @@
+CREATE TABLE loan_events (
+    id SERIAL PRIMARY KEY,
+    loan_id BIGINT NOT NULL,
+    type VARCHAR(64) NOT NULL,
+    payload JSON NOT NULL,
+    occurred_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_loan_events_loan_id ON loan_events(loan_id);
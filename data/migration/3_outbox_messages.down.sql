@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_outbox_messages_pending;
+
+DROP TABLE IF EXISTS outbox_messages;
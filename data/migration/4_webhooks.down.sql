@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_webhook_deliveries_pending;
+
+DROP TABLE IF EXISTS webhook_deliveries;
+DROP TABLE IF EXISTS webhooks;
@@ -0,0 +1,7 @@
+ALTER TABLE investors
+    DROP COLUMN locale,
+    DROP COLUMN email;
+
+ALTER TABLE users
+    DROP COLUMN locale,
+    DROP COLUMN email;
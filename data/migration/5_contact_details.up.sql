@@ -0,0 +1,7 @@
+ALTER TABLE users
+    ADD COLUMN email VARCHAR(255) NULL AFTER name,
+    ADD COLUMN locale VARCHAR(8) NOT NULL DEFAULT 'en' AFTER email;
+
+ALTER TABLE investors
+    ADD COLUMN email VARCHAR(255) NULL AFTER name,
+    ADD COLUMN locale VARCHAR(8) NOT NULL DEFAULT 'en' AFTER email;
@@ -0,0 +1,6 @@
+ALTER TABLE loans
+    DROP COLUMN borrower_agreement_checksum,
+    DROP COLUMN borrower_agreement;
+
+ALTER TABLE investments
+    DROP COLUMN agreement_checksum;
@@ -0,0 +1,6 @@
+ALTER TABLE investments
+    ADD COLUMN agreement_checksum CHAR(64) NOT NULL DEFAULT '' AFTER agreement_letter;
+
+ALTER TABLE loans
+    ADD COLUMN borrower_agreement VARCHAR(255) NULL AFTER disbursed_by,
+    ADD COLUMN borrower_agreement_checksum CHAR(64) NULL AFTER borrower_agreement;
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_loan_documents_checksum;
+DROP INDEX IF EXISTS idx_loan_documents_loan;
+
+DROP TABLE IF EXISTS loan_documents;
@@ -0,0 +1,14 @@
+CREATE TABLE loan_documents (
+    id SERIAL PRIMARY KEY,
+    loan_id BIGINT NOT NULL,
+    investment_id BIGINT NULL,
+    kind VARCHAR(32) NOT NULL,
+    location VARCHAR(2048) NOT NULL,
+    checksum CHAR(64) NOT NULL,
+    content_type VARCHAR(127) NOT NULL,
+    size BIGINT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_loan_documents_loan ON loan_documents(loan_id);
+CREATE INDEX idx_loan_documents_checksum ON loan_documents(checksum);
@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS idx_document_signatures_loan;
+DROP INDEX IF EXISTS idx_document_signatures_document;
+DROP INDEX IF EXISTS idx_document_signatures_token;
+
+DROP TABLE IF EXISTS document_signatures;
+
+ALTER TABLE loan_documents
+    DROP COLUMN status;
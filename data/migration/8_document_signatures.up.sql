@@ -0,0 +1,20 @@
+ALTER TABLE loan_documents
+    ADD COLUMN status VARCHAR(32) NOT NULL DEFAULT 'attached' AFTER size;
+
+CREATE TABLE document_signatures (
+    id SERIAL PRIMARY KEY,
+    loan_document_id BIGINT NOT NULL,
+    loan_id BIGINT NOT NULL,
+    signer_role VARCHAR(32) NOT NULL,
+    signer_id BIGINT NOT NULL,
+    checksum CHAR(64) NOT NULL,
+    token_hash CHAR(64) NOT NULL,
+    signed_at TIMESTAMP NULL,
+    ip_address VARCHAR(45) NULL,
+    signature VARCHAR(255) NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX idx_document_signatures_token ON document_signatures(token_hash);
+CREATE INDEX idx_document_signatures_document ON document_signatures(loan_document_id);
+CREATE INDEX idx_document_signatures_loan ON document_signatures(loan_id);
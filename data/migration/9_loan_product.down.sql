@@ -0,0 +1,2 @@
+ALTER TABLE loans
+    DROP COLUMN loan_product_id;
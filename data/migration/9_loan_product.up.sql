@@ -0,0 +1,2 @@
+ALTER TABLE loans
+    ADD COLUMN loan_product_id BIGINT NULL AFTER borrower_id;
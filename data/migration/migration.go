@@ -0,0 +1,38 @@
+// Package migration embeds the database migrations, named
+// <version>_<name>.up.sql and <version>_<name>.down.sql.
+package migration
+
+import (
+	"embed"
+	"io/fs"
+	"strconv"
+	"strings"
+)
+
+//go:embed *.sql
+var FS embed.FS
+
+// Latest returns the version of the newest migration, which a database that
+// is up to date is at.
+func Latest() int64 {
+	names, err := fs.Glob(FS, "*.up.sql")
+	if err != nil {
+		panic(err)
+	}
+
+	var latest int64
+	for _, name := range names {
+		version, err := Version(name)
+		if err != nil {
+			panic(err)
+		}
+		latest = max(latest, version)
+	}
+	return latest
+}
+
+// Version returns the version of the migration file name.
+func Version(name string) (int64, error) {
+	prefix, _, _ := strings.Cut(name, "_")
+	return strconv.ParseInt(prefix, 10, 64)
+}
@@ -0,0 +1,36 @@
+package migration
+
+import (
+	"io/fs"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLatest(t *testing.T) {
+	assert.Equal(t, int64(18), Latest())
+}
+
+func TestMigrationsArePaired(t *testing.T) {
+	ups, err := fs.Glob(FS, "*.up.sql")
+	assert.NoError(t, err)
+	downs, err := fs.Glob(FS, "*.down.sql")
+	assert.NoError(t, err)
+	assert.Len(t, downs, len(ups))
+
+	seen := map[int64]bool{}
+	for _, up := range ups {
+		version, err := Version(up)
+		assert.NoError(t, err, up)
+		assert.False(t, seen[version], "version %d is used twice", version)
+		seen[version] = true
+
+		_, err = fs.Stat(FS, strings.TrimSuffix(up, ".up.sql")+".down.sql")
+		assert.NoError(t, err, up)
+	}
+
+	for version := int64(1); version <= Latest(); version++ {
+		assert.True(t, seen[version], "version %d is missing", version)
+	}
+}
@@ -0,0 +1,87 @@
+package document
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/aldipi/loan-service/model"
+)
+
+const lineWidth = 90
+
+//go:embed templates
+var templateFS embed.FS
+
+var templateFuncs = template.FuncMap{
+	"date": func(t time.Time) string {
+		return t.Format("2 January 2006")
+	},
+}
+
+// PDFGenerator renders agreement letters from text templates into PDF files.
+// The output only depends on the agreement, so the same agreement always
+// produces the same checksum.
+type PDFGenerator struct{}
+
+func NewPDFGenerator() *PDFGenerator {
+	return &PDFGenerator{}
+}
+
+func (g *PDFGenerator) GenerateAgreement(ctx context.Context, agreement *model.Agreement) ([]byte, error) {
+	lines, err := RenderAgreement(agreement)
+	if err != nil {
+		return nil, err
+	}
+
+	return WritePDF(lines), nil
+}
+
+// RenderAgreement fills the template of the agreement kind and returns its
+// text wrapped into lines that fit the page.
+func RenderAgreement(agreement *model.Agreement) ([]string, error) {
+	tmpl, err := template.New(string(agreement.Kind)+".tmpl").
+		Funcs(templateFuncs).
+		ParseFS(templateFS, "templates/"+string(agreement.Kind)+".tmpl")
+	if err != nil {
+		return nil, err
+	}
+
+	text := &bytes.Buffer{}
+	err = tmpl.Execute(text, agreement)
+	if err != nil {
+		return nil, err
+	}
+
+	lines := []string{}
+	for _, paragraph := range strings.Split(strings.TrimRight(text.String(), "\n"), "\n") {
+		lines = append(lines, wrap(paragraph, lineWidth)...)
+	}
+
+	return lines, nil
+}
+
+// wrap splits a paragraph at word boundaries into lines of at most width
+// characters. Words longer than width are kept on their own line.
+func wrap(paragraph string, width int) []string {
+	words := strings.Fields(paragraph)
+	if len(words) == 0 {
+		return []string{""}
+	}
+
+	lines := []string{}
+	line := words[0]
+	for _, word := range words[1:] {
+		if len(line)+1+len(word) > width {
+			lines = append(lines, line)
+			line = word
+			continue
+		}
+		line += " " + word
+	}
+
+	return append(lines, line)
+}
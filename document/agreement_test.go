@@ -0,0 +1,68 @@
+package document
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aldipi/loan-service/model"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenderAgreement(t *testing.T) {
+	lines, err := RenderAgreement(&model.Agreement{
+		Kind:      model.AgreementInvestor,
+		LoanID:    7,
+		PartyName: "Investor A",
+		Amount:    25000000,
+		Currency:  "IDR",
+		Rate:      decimal.NewFromFloat(12.5),
+		ROI:       decimal.NewFromInt(10),
+		Date:      time.Date(2021, 1, 2, 0, 0, 0, 0, time.UTC),
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "INVESTOR AGREEMENT - LOAN #7", lines[0])
+	assert.Contains(t, lines, "Date: 2 January 2021")
+	assert.Contains(t, lines, "Investor: Investor A")
+	assert.Contains(t, lines, "Investment amount: IDR 250,000.00")
+	assert.Contains(t, lines, "Loan interest rate: 12.5%")
+	assert.Contains(t, lines, "Return on investment: 10%")
+	for _, line := range lines {
+		assert.LessOrEqual(t, len(line), lineWidth)
+	}
+}
+
+func TestRenderAgreementUnknownKind(t *testing.T) {
+	_, err := RenderAgreement(&model.Agreement{Kind: "lender"})
+
+	assert.Error(t, err)
+}
+
+func TestGenerateAgreementIsDeterministic(t *testing.T) {
+	agreement := &model.Agreement{
+		Kind:      model.AgreementBorrower,
+		LoanID:    7,
+		PartyName: "Putra Raja",
+		Amount:    1000000,
+		Rate:      decimal.NewFromInt(12),
+		ROI:       decimal.NewFromInt(10),
+		Date:      time.Date(2021, 1, 2, 0, 0, 0, 0, time.UTC),
+	}
+
+	first, err := NewPDFGenerator().GenerateAgreement(context.Background(), agreement)
+	assert.NoError(t, err)
+	second, err := NewPDFGenerator().GenerateAgreement(context.Background(), agreement)
+	assert.NoError(t, err)
+
+	assert.Equal(t, first, second)
+	assert.Contains(t, string(first), "(BORROWER AGREEMENT - LOAN #7) Tj T*")
+	assert.Contains(t, string(first), "(Borrower: Putra Raja) Tj T*")
+}
+
+func TestWrap(t *testing.T) {
+	assert.Equal(t, []string{""}, wrap("", 10))
+	assert.Equal(t, []string{"one two", "three"}, wrap("one two three", 8))
+	assert.Equal(t, []string{"averylongword", "x"}, wrap("averylongword x", 8))
+}
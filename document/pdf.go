@@ -0,0 +1,101 @@
+package document
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// Page layout in PDF points for an A4 page.
+const (
+	pageWidth   = 595
+	pageHeight  = 842
+	pageMargin  = 56
+	fontSize    = 11
+	lineLeading = 15
+
+	linesPerPage = (pageHeight - 2*pageMargin) / lineLeading
+)
+
+// WritePDF lays out text lines on as many A4 pages as needed using the
+// standard Helvetica font, which every PDF reader provides, so no font has to
+// be embedded.
+func WritePDF(lines []string) []byte {
+	pages := [][]string{}
+	for len(lines) > linesPerPage {
+		pages = append(pages, lines[:linesPerPage])
+		lines = lines[linesPerPage:]
+	}
+	pages = append(pages, lines)
+
+	// Objects 1 to 3 are the catalog, the page tree and the font. Every page
+	// is followed by its content stream.
+	objects := []string{"", "", "<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>"}
+
+	kids := []string{}
+	for _, page := range pages {
+		pageID := len(objects) + 1
+		kids = append(kids, fmt.Sprintf("%d 0 R", pageID))
+
+		content := pageContent(page)
+		objects = append(objects,
+			fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Resources << /Font << /F1 3 0 R >> >> /Contents %d 0 R >>", pageWidth, pageHeight, pageID+1),
+			fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(content), content),
+		)
+	}
+
+	objects[0] = "<< /Type /Catalog /Pages 2 0 R >>"
+	objects[1] = fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages))
+
+	buf := &bytes.Buffer{}
+	buf.WriteString("%PDF-1.4\n")
+
+	offsets := make([]int, len(objects))
+	for i, object := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(buf, "%d 0 obj\n%s\nendobj\n", i+1, object)
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+
+	return buf.Bytes()
+}
+
+func pageContent(lines []string) string {
+	content := &strings.Builder{}
+	fmt.Fprintf(content, "BT\n/F1 %d Tf\n%d TL\n%d %d Td\n", fontSize, lineLeading, pageMargin, pageHeight-pageMargin)
+	for _, line := range lines {
+		fmt.Fprintf(content, "(%s) Tj T*\n", escapeText(line))
+	}
+	content.WriteString("ET")
+
+	return content.String()
+}
+
+// escapeText encodes a line as the body of a PDF literal string. Characters
+// outside Latin-1 can not be shown by the standard fonts and become "?".
+func escapeText(s string) string {
+	escaped := &strings.Builder{}
+	for _, r := range s {
+		switch {
+		case r == '\\' || r == '(' || r == ')':
+			escaped.WriteByte('\\')
+			escaped.WriteRune(r)
+		case r < 0x20:
+			escaped.WriteByte(' ')
+		case r < 0x80:
+			escaped.WriteRune(r)
+		case r <= 0xff:
+			fmt.Fprintf(escaped, "\\%03o", r)
+		default:
+			escaped.WriteByte('?')
+		}
+	}
+
+	return escaped.String()
+}
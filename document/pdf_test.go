@@ -0,0 +1,45 @@
+package document
+
+import (
+	"bytes"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWritePDF(t *testing.T) {
+	pdf := WritePDF([]string{"Hello (world)", "José \\ 世"})
+
+	assert.True(t, bytes.HasPrefix(pdf, []byte("%PDF-1.4\n")))
+	assert.True(t, bytes.HasSuffix(pdf, []byte("%%EOF\n")))
+	assert.Contains(t, string(pdf), `(Hello \(world\)) Tj T*`)
+	assert.Contains(t, string(pdf), `(Jos\351 \\ ?) Tj T*`)
+	assert.Contains(t, string(pdf), "/Count 1")
+
+	// Every xref entry must point at the start of its object.
+	startxref := regexp.MustCompile(`startxref\n(\d+)\n`).FindSubmatch(pdf)
+	assert.NotNil(t, startxref)
+	xref, err := strconv.Atoi(string(startxref[1]))
+	assert.NoError(t, err)
+	assert.True(t, bytes.HasPrefix(pdf[xref:], []byte("xref\n")))
+
+	entries := regexp.MustCompile(`(\d{10}) 00000 n `).FindAllSubmatch(pdf[xref:], -1)
+	assert.Len(t, entries, 5)
+	for i, entry := range entries {
+		offset, err := strconv.Atoi(string(entry[1]))
+		assert.NoError(t, err)
+		assert.True(t, bytes.HasPrefix(pdf[offset:], []byte(strconv.Itoa(i+1)+" 0 obj\n")))
+	}
+}
+
+func TestWritePDFSplitsPages(t *testing.T) {
+	lines := strings.Split(strings.Repeat("line\n", linesPerPage+1), "\n")
+
+	pdf := WritePDF(lines[:linesPerPage+1])
+
+	assert.Contains(t, string(pdf), "/Count 2")
+	assert.Contains(t, string(pdf), "/Kids [4 0 R 6 0 R]")
+}
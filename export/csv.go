@@ -0,0 +1,55 @@
+package export
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/aldipi/loan-service/model"
+	"github.com/shopspring/decimal"
+)
+
+func writeCSV(w io.Writer, table model.Table) error {
+	writer := csv.NewWriter(w)
+	err := writer.Write(table.Columns)
+	if err != nil {
+		return err
+	}
+
+	record := make([]string, len(table.Columns))
+	err = table.Rows(func(values ...any) error {
+		for i, value := range values {
+			record[i] = formatCSV(value)
+		}
+		return writer.Write(record[:len(values)])
+	})
+	if err != nil {
+		return err
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// formatCSV formats a cell value as text. Times are written in RFC 3339 in
+// UTC, so spreadsheets in any time zone read the same instant.
+func formatCSV(value any) string {
+	switch value := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	case int64:
+		return strconv.FormatInt(value, 10)
+	case int:
+		return strconv.Itoa(value)
+	case decimal.Decimal:
+		return value.String()
+	case time.Time:
+		return value.UTC().Format(time.RFC3339)
+	default:
+		return fmt.Sprint(value)
+	}
+}
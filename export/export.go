@@ -0,0 +1,31 @@
+// Package export writes tables into spreadsheet files row by row, so tables
+// of any size are exported with little memory.
+package export
+
+import (
+	"io"
+
+	"github.com/aldipi/loan-service/model"
+)
+
+// Encoder writes tables as CSV or XLSX files.
+type Encoder struct{}
+
+func NewEncoder() *Encoder {
+	return &Encoder{}
+}
+
+// EncodeTable writes the columns of the table as a header followed by its
+// rows in the format. CSV files are written as the rows are produced, while
+// XLSX files are buffered in a temporary file and written once the last row
+// is produced.
+func (e *Encoder) EncodeTable(w io.Writer, format model.ExportFormat, table model.Table) error {
+	switch format {
+	case model.ExportFormatCSV:
+		return writeCSV(w, table)
+	case model.ExportFormatXLSX:
+		return writeXLSX(w, table)
+	default:
+		return model.ErrExportFormatInvalid
+	}
+}
@@ -0,0 +1,104 @@
+package export
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aldipi/loan-service/model"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/xuri/excelize/v2"
+)
+
+var createdAt = time.Date(2024, 5, 1, 9, 30, 0, 0, time.FixedZone("WIB", 7*60*60))
+
+func loanTable(rows int) model.Table {
+	return model.Table{
+		Name:    "loans",
+		Columns: []string{"id", "state", "rate", "created_at", "approved_at"},
+		Rows: func(write func(values ...any) error) error {
+			for i := 1; i <= rows; i++ {
+				err := write(int64(i), "approved, funded", decimal.RequireFromString("0.125"), createdAt, nil)
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+}
+
+func TestEncodeCSV(t *testing.T) {
+	out := &bytes.Buffer{}
+
+	err := NewEncoder().EncodeTable(out, model.ExportFormatCSV, loanTable(2))
+
+	assert.NoError(t, err)
+	assert.Equal(t, "id,state,rate,created_at,approved_at\n"+
+		"1,\"approved, funded\",0.125,2024-05-01T02:30:00Z,\n"+
+		"2,\"approved, funded\",0.125,2024-05-01T02:30:00Z,\n", out.String())
+}
+
+func TestEncodeXLSX(t *testing.T) {
+	out := &bytes.Buffer{}
+
+	err := NewEncoder().EncodeTable(out, model.ExportFormatXLSX, loanTable(2))
+	assert.NoError(t, err)
+
+	f, err := excelize.OpenReader(out)
+	assert.NoError(t, err)
+	defer f.Close()
+	assert.Equal(t, []string{"loans"}, f.GetSheetList())
+
+	rows, err := f.GetRows("loans")
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{
+		{"id", "state", "rate", "created_at", "approved_at"},
+		{"1", "approved, funded", "0.125", "2024-05-01 02:30:00"},
+		{"2", "approved, funded", "0.125", "2024-05-01 02:30:00"},
+	}, rows)
+}
+
+func TestEncodeXLSXSplitsSheets(t *testing.T) {
+	defer func(rows int) { xlsxMaxRows = rows }(xlsxMaxRows)
+	xlsxMaxRows = 3
+	out := &bytes.Buffer{}
+
+	err := NewEncoder().EncodeTable(out, model.ExportFormatXLSX, loanTable(5))
+	assert.NoError(t, err)
+
+	f, err := excelize.OpenReader(out)
+	assert.NoError(t, err)
+	defer f.Close()
+	assert.Equal(t, []string{"loans", "loans 2", "loans 3"}, f.GetSheetList())
+
+	for sheet, ids := range map[string][]string{"loans": {"1", "2"}, "loans 2": {"3", "4"}, "loans 3": {"5"}} {
+		rows, err := f.GetRows(sheet)
+		assert.NoError(t, err)
+		assert.Equal(t, "id", rows[0][0], sheet)
+		for i, id := range ids {
+			assert.Equal(t, id, rows[i+1][0], sheet)
+		}
+		assert.Len(t, rows, len(ids)+1, sheet)
+	}
+}
+
+func TestEncodeStopsOnRowError(t *testing.T) {
+	table := loanTable(0)
+	table.Rows = func(write func(values ...any) error) error {
+		return errors.New("connection lost")
+	}
+
+	for _, format := range []model.ExportFormat{model.ExportFormatCSV, model.ExportFormatXLSX} {
+		err := NewEncoder().EncodeTable(&bytes.Buffer{}, format, table)
+		assert.EqualError(t, err, "connection lost", format)
+	}
+}
+
+func TestEncodeInvalidFormat(t *testing.T) {
+	err := NewEncoder().EncodeTable(&bytes.Buffer{}, "pdf", loanTable(1))
+
+	assert.Equal(t, model.ErrExportFormatInvalid, err)
+}
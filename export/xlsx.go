@@ -0,0 +1,134 @@
+package export
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/aldipi/loan-service/model"
+	"github.com/shopspring/decimal"
+	"github.com/xuri/excelize/v2"
+)
+
+const xlsxTimeFormat = "yyyy-mm-dd hh:mm:ss"
+
+// xlsxMaxRows is the number of rows of a sheet, including its header. Tables
+// with more rows continue on the next sheet.
+var xlsxMaxRows = excelize.TotalRows
+
+// writeXLSX writes the table to sheets named after it. The stream writer keeps
+// the rows in a temporary file once they outgrow its buffer, so memory stays
+// flat however many rows there are.
+func writeXLSX(w io.Writer, table model.Table) error {
+	f := excelize.NewFile()
+	defer f.Close()
+
+	timeFormat := xlsxTimeFormat
+	timeStyle, err := f.NewStyle(&excelize.Style{CustomNumFmt: &timeFormat})
+	if err != nil {
+		return err
+	}
+	headerStyle, err := f.NewStyle(&excelize.Style{Font: &excelize.Font{Bold: true}})
+	if err != nil {
+		return err
+	}
+
+	x := &xlsxWriter{file: f, table: table, timeStyle: timeStyle, headerStyle: headerStyle}
+	err = x.nextSheet()
+	if err != nil {
+		return err
+	}
+
+	err = table.Rows(x.writeRow)
+	if err != nil {
+		return err
+	}
+
+	err = x.sheet.Flush()
+	if err != nil {
+		return err
+	}
+
+	return f.Write(w)
+}
+
+type xlsxWriter struct {
+	file        *excelize.File
+	table       model.Table
+	timeStyle   int
+	headerStyle int
+
+	sheet  *excelize.StreamWriter
+	sheets int
+	row    int
+}
+
+// nextSheet flushes the current sheet and starts the next one with the
+// header. The first sheet replaces the default sheet of the file.
+func (x *xlsxWriter) nextSheet() error {
+	x.sheets++
+	name := x.table.Name
+	if x.sheets > 1 {
+		name = fmt.Sprintf("%s %d", x.table.Name, x.sheets)
+	}
+
+	if x.sheet == nil {
+		err := x.file.SetSheetName(x.file.GetSheetName(0), name)
+		if err != nil {
+			return err
+		}
+	} else {
+		err := x.sheet.Flush()
+		if err != nil {
+			return err
+		}
+		_, err = x.file.NewSheet(name)
+		if err != nil {
+			return err
+		}
+	}
+
+	sheet, err := x.file.NewStreamWriter(name)
+	if err != nil {
+		return err
+	}
+	x.sheet = sheet
+	x.row = 0
+
+	header := make([]any, len(x.table.Columns))
+	for i, column := range x.table.Columns {
+		header[i] = excelize.Cell{StyleID: x.headerStyle, Value: column}
+	}
+	return x.setRow(header)
+}
+
+func (x *xlsxWriter) writeRow(values ...any) error {
+	if x.row == xlsxMaxRows {
+		err := x.nextSheet()
+		if err != nil {
+			return err
+		}
+	}
+
+	cells := make([]any, len(values))
+	for i, value := range values {
+		switch value := value.(type) {
+		case time.Time:
+			cells[i] = excelize.Cell{StyleID: x.timeStyle, Value: value.UTC()}
+		case decimal.Decimal:
+			cells[i] = value.InexactFloat64()
+		default:
+			cells[i] = value
+		}
+	}
+	return x.setRow(cells)
+}
+
+func (x *xlsxWriter) setRow(cells []any) error {
+	x.row++
+	cell, err := excelize.CoordinatesToCellName(1, x.row)
+	if err != nil {
+		return err
+	}
+	return x.sheet.SetRow(cell, cells)
+}
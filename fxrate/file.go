@@ -0,0 +1,115 @@
+package fxrate
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/aldipi/loan-service/model"
+	"github.com/shopspring/decimal"
+)
+
+// rateScale is the number of decimal places rates are rounded to, the scale
+// they are stored with on investments.
+const rateScale = 10
+
+// ratesFile is the content of a rates file. Every rate is the number of units
+// of its currency per unit of the base currency.
+type ratesFile struct {
+	Base  model.Currency                     `json:"base"`
+	Rates map[model.Currency]decimal.Decimal `json:"rates"`
+}
+
+// FileProvider quotes exchange rates from a JSON file like
+//
+//	{"base": "USD", "rates": {"IDR": "16250", "SGD": "1.34"}}
+//
+// Rates between two currencies other than the base are crossed through it.
+// The file is read again when it changes, so it can be updated by a job
+// fetching the rates of the day.
+type FileProvider struct {
+	path string
+
+	mu      sync.Mutex
+	modTime time.Time
+	rates   ratesFile
+}
+
+// NewFileProvider returns a provider quoting the rates of the file at path,
+// failing when the file can not be read.
+func NewFileProvider(path string) (*FileProvider, error) {
+	p := &FileProvider{path: path}
+	_, err := p.load()
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *FileProvider) Rate(ctx context.Context, from model.Currency, to model.Currency) (decimal.Decimal, error) {
+	rates, err := p.load()
+	if err != nil {
+		return decimal.Decimal{}, err
+	}
+
+	fromRate, ok := rates.rate(from)
+	if !ok {
+		return decimal.Decimal{}, model.ErrFXRateUnavailable
+	}
+	toRate, ok := rates.rate(to)
+	if !ok {
+		return decimal.Decimal{}, model.ErrFXRateUnavailable
+	}
+
+	return toRate.DivRound(fromRate, rateScale), nil
+}
+
+// load returns the rates of the file, reading it again when it was modified
+// since it was last read.
+func (p *FileProvider) load() (ratesFile, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	info, err := os.Stat(p.path)
+	if err != nil {
+		return ratesFile{}, err
+	}
+	if info.ModTime().Equal(p.modTime) {
+		return p.rates, nil
+	}
+
+	data, err := os.ReadFile(p.path)
+	if err != nil {
+		return ratesFile{}, err
+	}
+
+	var rates ratesFile
+	err = json.Unmarshal(data, &rates)
+	if err != nil {
+		return ratesFile{}, fmt.Errorf("invalid rates file %s: %w", p.path, err)
+	}
+	if !rates.Base.Valid() {
+		return ratesFile{}, fmt.Errorf("invalid rates file %s: unknown base currency %q", p.path, rates.Base)
+	}
+	for currency, rate := range rates.Rates {
+		if !currency.Valid() || !rate.IsPositive() {
+			return ratesFile{}, fmt.Errorf("invalid rates file %s: invalid rate of %q", p.path, currency)
+		}
+	}
+
+	p.modTime = info.ModTime()
+	p.rates = rates
+	return rates, nil
+}
+
+// rate returns the number of units of the currency per unit of the base.
+func (f ratesFile) rate(currency model.Currency) (decimal.Decimal, bool) {
+	if currency == f.Base {
+		return decimal.NewFromInt(1), true
+	}
+	rate, ok := f.Rates[currency]
+	return rate, ok
+}
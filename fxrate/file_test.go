@@ -0,0 +1,68 @@
+package fxrate
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aldipi/loan-service/model"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func writeRates(t *testing.T, path string, content string, modTime time.Time) {
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	assert.NoError(t, os.Chtimes(path, modTime, modTime))
+}
+
+func TestFileProvider(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rates.json")
+	writeRates(t, path, `{"base": "USD", "rates": {"IDR": "16000", "SGD": "1.25"}}`, time.Now())
+
+	p, err := NewFileProvider(path)
+	assert.NoError(t, err)
+
+	rate, err := p.Rate(context.Background(), "USD", "IDR")
+	assert.NoError(t, err)
+	assert.True(t, decimal.NewFromInt(16000).Equal(rate), rate.String())
+
+	rate, err = p.Rate(context.Background(), "SGD", "IDR")
+	assert.NoError(t, err)
+	assert.True(t, decimal.NewFromInt(12800).Equal(rate), rate.String())
+
+	rate, err = p.Rate(context.Background(), "IDR", "USD")
+	assert.NoError(t, err)
+	assert.Equal(t, "0.0000625", rate.String())
+
+	_, err = p.Rate(context.Background(), "USD", "EUR")
+	assert.ErrorIs(t, err, model.ErrFXRateUnavailable)
+}
+
+func TestFileProviderReloadsChangedFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rates.json")
+	modTime := time.Now().Add(-time.Hour)
+	writeRates(t, path, `{"base": "USD", "rates": {"IDR": "16000"}}`, modTime)
+
+	p, err := NewFileProvider(path)
+	assert.NoError(t, err)
+
+	writeRates(t, path, `{"base": "USD", "rates": {"IDR": "16500"}}`, modTime.Add(time.Minute))
+
+	rate, err := p.Rate(context.Background(), "USD", "IDR")
+	assert.NoError(t, err)
+	assert.True(t, decimal.NewFromInt(16500).Equal(rate), rate.String())
+}
+
+func TestNewFileProviderInvalidFile(t *testing.T) {
+	dir := t.TempDir()
+
+	_, err := NewFileProvider(filepath.Join(dir, "missing.json"))
+	assert.Error(t, err)
+
+	path := filepath.Join(dir, "rates.json")
+	writeRates(t, path, `{"base": "USD", "rates": {"IDR": "0"}}`, time.Now())
+	_, err = NewFileProvider(path)
+	assert.EqualError(t, err, `invalid rates file `+path+`: invalid rate of "IDR"`)
+}
@@ -0,0 +1,168 @@
+package graphqlhandler
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/aldipi/loan-service/logging"
+	"github.com/aldipi/loan-service/model"
+	graphql "github.com/graph-gophers/graphql-go"
+	gqlerrors "github.com/graph-gophers/graphql-go/errors"
+	"github.com/labstack/echo/v4"
+)
+
+//go:embed schema.graphql
+var schema string
+
+// Usecase is the part of the loan usecase served over GraphQL.
+type Usecase interface {
+	GetLoanByID(ctx context.Context, actor model.Actor, loanID int64, expand []model.LoanExpansion) (*model.LoanDetail, error)
+	GetLoansForActor(ctx context.Context, actor model.Actor, filter model.LoanFilter) (*model.Page[*model.Loan], error)
+	GetInvestmentsByLoanIDs(ctx context.Context, loanIDs []int64) (map[int64][]*model.Investment, error)
+	GetLoanProductsByIDs(ctx context.Context, ids []int64) (map[int64]*model.LoanProduct, error)
+	GetUsersByIDs(ctx context.Context, ids []int64) (map[int64]*model.User, error)
+	GetEmployeesByIDs(ctx context.Context, ids []int64) (map[int64]*model.Employee, error)
+	GetInvestorsByIDs(ctx context.Context, ids []int64) (map[int64]*model.Investor, error)
+}
+
+const (
+	defaultMaxDepth       = 8
+	defaultMaxQueryLength = 10000
+	defaultMaxFields      = 5000
+)
+
+// Handler serves the read-only GraphQL API of dashboards on top of the loan
+// usecase. Callers are identified by the X-User-Id and X-User-Role headers,
+// as on the HTTP API.
+type Handler struct {
+	uc             Usecase
+	schema         *graphql.Schema
+	logger         *slog.Logger
+	maxDepth       int
+	maxQueryLength int
+	maxFields      int
+}
+
+// Option configures optional settings of Handler.
+type Option func(h *Handler)
+
+func NewHandler(uc Usecase, opts ...Option) *Handler {
+	h := &Handler{
+		uc:             uc,
+		logger:         slog.Default(),
+		maxDepth:       defaultMaxDepth,
+		maxQueryLength: defaultMaxQueryLength,
+		maxFields:      defaultMaxFields,
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	h.schema = graphql.MustParseSchema(schema, &resolver{uc: uc},
+		graphql.UseStringDescriptions(),
+		graphql.MaxDepth(h.maxDepth),
+		graphql.MaxQueryLength(h.maxQueryLength),
+		graphql.Tracer(fieldLimiter{}),
+		graphql.PanicHandler(h),
+	)
+	return h
+}
+
+// WithLogger sets the logger of queries failing with an internal error.
+func WithLogger(logger *slog.Logger) Option {
+	return func(h *Handler) {
+		h.logger = logger
+	}
+}
+
+// WithMaxDepth sets how deep fields of queries may be nested.
+func WithMaxDepth(depth int) Option {
+	return func(h *Handler) {
+		h.maxDepth = depth
+	}
+}
+
+// WithMaxQueryLength sets the longest query in bytes.
+func WithMaxQueryLength(length int) Option {
+	return func(h *Handler) {
+		h.maxQueryLength = length
+	}
+}
+
+// WithMaxFields sets the most fields a query may resolve, counting every
+// alias and every item of a list.
+func WithMaxFields(fields int) Option {
+	return func(h *Handler) {
+		h.maxFields = fields
+	}
+}
+
+type graphqlRequest struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName"`
+	Variables     map[string]any `json:"variables"`
+}
+
+// Serve executes the GraphQL query in the JSON body of the request. Queries
+// deeper or longer than allowed are rejected before anything is fetched, and
+// queries resolving more fields than allowed are stopped once they reach the
+// limit.
+func (h *Handler) Serve(c echo.Context) error {
+	var req graphqlRequest
+	err := json.NewDecoder(c.Request().Body).Decode(&req)
+	if err != nil || req.Query == "" {
+		return c.JSON(http.StatusBadRequest, errorResponse("request body must be a JSON object with a query"))
+	}
+
+	userID, _ := strconv.ParseInt(c.Request().Header.Get("X-User-Id"), 10, 64)
+	actor := model.Actor{Role: model.Role(c.Request().Header.Get("X-User-Role")), ID: userID}
+	r := &request{actor: actor, loaders: newLoaders(h.uc), fieldBudget: newFieldBudget(h.maxFields)}
+	ctx := withRequest(c.Request().Context(), r)
+
+	res := h.schema.Exec(ctx, req.Query, req.OperationName, req.Variables)
+	if r.exceeded.Load() {
+		return c.JSON(http.StatusOK, errorResponse(fmt.Sprintf("query resolves more than the limit of %d fields", h.maxFields)))
+	}
+	for _, queryErr := range res.Errors {
+		h.hideInternalError(ctx, queryErr)
+	}
+	return c.JSON(http.StatusOK, res)
+}
+
+func errorResponse(message string) *graphql.Response {
+	return &graphql.Response{Errors: []*gqlerrors.QueryError{{Message: message}}}
+}
+
+// hideInternalError replaces the message of an error returned by a resolver
+// when it is not the caller's fault, as its details may expose internals of
+// the service. The error is logged and the message carries the request ID,
+// so the failure can be found in the logs.
+func (h *Handler) hideInternalError(ctx context.Context, queryErr *gqlerrors.QueryError) {
+	err := queryErr.ResolverError
+	var loanErr model.LoanError
+	if err == nil || errors.As(err, &loanErr) || errors.Is(err, errIDInvalid) {
+		return
+	}
+
+	h.logger.ErrorContext(ctx, "query failed", "path", queryErr.Path, "error", err)
+	queryErr.Message = internalErrorMessage(ctx)
+}
+
+// MakePanicError logs a resolver panic and returns it as an internal error.
+func (h *Handler) MakePanicError(ctx context.Context, value any) *gqlerrors.QueryError {
+	h.logger.ErrorContext(ctx, "query panicked", "panic", value)
+	return &gqlerrors.QueryError{Message: internalErrorMessage(ctx)}
+}
+
+func internalErrorMessage(ctx context.Context) string {
+	message := "internal server error"
+	if id := logging.RequestID(ctx); id != "" {
+		message += ", request id " + id
+	}
+	return message
+}
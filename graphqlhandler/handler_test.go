@@ -0,0 +1,392 @@
+package graphqlhandler
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aldipi/loan-service/logging"
+	"github.com/aldipi/loan-service/model"
+	"github.com/labstack/echo/v4"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type mockUsecase struct {
+	mock.Mock
+}
+
+func (m *mockUsecase) GetLoanByID(ctx context.Context, actor model.Actor, loanID int64, expand []model.LoanExpansion) (*model.LoanDetail, error) {
+	args := m.Called(ctx, actor, loanID, expand)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.LoanDetail), args.Error(1)
+}
+
+func (m *mockUsecase) GetLoansForActor(ctx context.Context, actor model.Actor, filter model.LoanFilter) (*model.Page[*model.Loan], error) {
+	args := m.Called(ctx, actor, filter)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Page[*model.Loan]), args.Error(1)
+}
+
+func (m *mockUsecase) GetInvestmentsByLoanIDs(ctx context.Context, loanIDs []int64) (map[int64][]*model.Investment, error) {
+	args := m.Called(ctx, loanIDs)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(map[int64][]*model.Investment), args.Error(1)
+}
+
+func (m *mockUsecase) GetLoanProductsByIDs(ctx context.Context, ids []int64) (map[int64]*model.LoanProduct, error) {
+	args := m.Called(ctx, ids)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(map[int64]*model.LoanProduct), args.Error(1)
+}
+
+func (m *mockUsecase) GetUsersByIDs(ctx context.Context, ids []int64) (map[int64]*model.User, error) {
+	args := m.Called(ctx, ids)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(map[int64]*model.User), args.Error(1)
+}
+
+func (m *mockUsecase) GetEmployeesByIDs(ctx context.Context, ids []int64) (map[int64]*model.Employee, error) {
+	args := m.Called(ctx, ids)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(map[int64]*model.Employee), args.Error(1)
+}
+
+func (m *mockUsecase) GetInvestorsByIDs(ctx context.Context, ids []int64) (map[int64]*model.Investor, error) {
+	args := m.Called(ctx, ids)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(map[int64]*model.Investor), args.Error(1)
+}
+
+// serve posts the query on behalf of the actor and returns the status and the
+// decoded response.
+func serve(t *testing.T, h *Handler, actor model.Actor, query string, variables map[string]any) (int, map[string]any) {
+	body, err := json.Marshal(map[string]any{"query": query, "variables": variables})
+	assert.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(string(body)))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	req.Header.Set("X-User-Role", string(actor.Role))
+	req.Header.Set("X-User-Id", strconv.FormatInt(actor.ID, 10))
+	req = req.WithContext(logging.ContextWithRequestID(req.Context(), "req-1"))
+	rec := httptest.NewRecorder()
+
+	err = h.Serve(echo.New().NewContext(req, rec))
+	assert.NoError(t, err)
+
+	var res map[string]any
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+	return rec.Code, res
+}
+
+func approvedLoan(id int64, borrowerID int64) *model.Loan {
+	approvedAt := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	return &model.Loan{
+		ID:              id,
+		State:           model.LoanStateApproved,
+		BorrowerID:      borrowerID,
+		LoanProductID:   sql.NullInt64{Int64: 1, Valid: true},
+		PrincipalAmount: 5000000000,
+		Currency:        "IDR",
+		Rate:            decimal.RequireFromString("10"),
+		ROI:             decimal.RequireFromString("8.5"),
+		ApprovalProof:   sql.NullString{String: "http://localhost/proof.png", Valid: true},
+		ApprovedBy:      sql.NullInt64{Int64: 7, Valid: true},
+		CreatedAt:       time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		ApprovedAt:      sql.NullTime{Time: approvedAt, Valid: true},
+	}
+}
+
+func TestLoansBatchesRelations(t *testing.T) {
+	uc := new(mockUsecase)
+	h := NewHandler(uc)
+	employee := model.Actor{Role: model.RoleEmployee, ID: 7}
+
+	uc.On("GetLoansForActor", mock.Anything, employee, model.LoanFilter{Limit: 2, State: ptr(model.LoanStateApproved)}).
+		Return(&model.Page[*model.Loan]{Data: []*model.Loan{approvedLoan(1, 10), approvedLoan(2, 11)}, NextCursor: "abc", Total: 3}, nil)
+	uc.On("GetInvestmentsByLoanIDs", mock.Anything, []int64{1, 2}).Return(map[int64][]*model.Investment{
+		1: {{ID: 100, LoanID: 1, InvestorID: 20, Amount: 1000000000}, {ID: 101, LoanID: 1, InvestorID: 21, Amount: 500000000}},
+		2: {{ID: 102, LoanID: 2, InvestorID: 20, Amount: 2000000000}},
+	}, nil)
+	uc.On("GetUsersByIDs", mock.Anything, []int64{10, 11}).Return(map[int64]*model.User{
+		10: {ID: 10, Name: "John", Email: sql.NullString{String: "john@example.com", Valid: true}},
+		11: {ID: 11, Name: "Jane"},
+	}, nil)
+	uc.On("GetLoanProductsByIDs", mock.Anything, []int64{1}).Return(map[int64]*model.LoanProduct{1: {ID: 1, Name: "Micro"}}, nil)
+	uc.On("GetEmployeesByIDs", mock.Anything, []int64{7}).Return(map[int64]*model.Employee{7: {ID: 7, Name: "Budi"}}, nil)
+	uc.On("GetInvestorsByIDs", mock.Anything, mock.MatchedBy(func(ids []int64) bool {
+		ids = slices.Clone(ids)
+		slices.Sort(ids)
+		return slices.Equal(ids, []int64{20, 21})
+	})).Return(map[int64]*model.Investor{20: {ID: 20, Name: "Ani"}, 21: {ID: 21, Name: "Bayu"}}, nil)
+
+	status, res := serve(t, h, employee, `query($first: Int) {
+		loans(filter: {state: APPROVED}, first: $first) {
+			totalCount
+			nextCursor
+			nodes {
+				id
+				state
+				principalAmount
+				fundedAmount
+				remainingAmount
+				approvalProof
+				approvedAt
+				product { name }
+				borrower { name email }
+				approver { name }
+				disburser { name }
+				investments { id amount investor { name } }
+			}
+		}
+	}`, map[string]any{"first": 2})
+
+	assert.Equal(t, http.StatusOK, status)
+	assert.Nil(t, res["errors"])
+	loans := res["data"].(map[string]any)["loans"].(map[string]any)
+	assert.Equal(t, float64(3), loans["totalCount"])
+	assert.Equal(t, "abc", loans["nextCursor"])
+	nodes := loans["nodes"].([]any)
+	assert.Len(t, nodes, 2)
+	assert.Equal(t, map[string]any{
+		"id":              "1",
+		"state":           "APPROVED",
+		"principalAmount": float64(5000000000),
+		"fundedAmount":    float64(1500000000),
+		"remainingAmount": float64(3500000000),
+		"approvalProof":   "http://localhost/proof.png",
+		"approvedAt":      "2024-01-02T00:00:00Z",
+		"product":         map[string]any{"name": "Micro"},
+		"borrower":        map[string]any{"name": "John", "email": "john@example.com"},
+		"approver":        map[string]any{"name": "Budi"},
+		"disburser":       nil,
+		"investments": []any{
+			map[string]any{"id": "100", "amount": float64(1000000000), "investor": map[string]any{"name": "Ani"}},
+			map[string]any{"id": "101", "amount": float64(500000000), "investor": map[string]any{"name": "Bayu"}},
+		},
+	}, nodes[0])
+	assert.Equal(t, map[string]any{"name": "Jane", "email": nil}, nodes[1].(map[string]any)["borrower"])
+
+	for _, method := range []string{"GetInvestmentsByLoanIDs", "GetUsersByIDs", "GetLoanProductsByIDs", "GetEmployeesByIDs", "GetInvestorsByIDs"} {
+		uc.AssertNumberOfCalls(t, method, 1)
+	}
+}
+
+func TestLoanHidesFieldsFromInvestors(t *testing.T) {
+	uc := new(mockUsecase)
+	h := NewHandler(uc)
+	investor := model.Actor{Role: model.RoleInvestor, ID: 20}
+
+	loan := approvedLoan(1, 10)
+	loan.AgreementLetter = sql.NullString{String: "http://localhost/agreement.pdf", Valid: true}
+	uc.On("GetLoanByID", mock.Anything, investor, int64(1), []model.LoanExpansion(nil)).Return(&model.LoanDetail{Loan: *loan}, nil)
+	uc.On("GetInvestmentsByLoanIDs", mock.Anything, []int64{1}).Return(map[int64][]*model.Investment{
+		1: {
+			{ID: 100, LoanID: 1, InvestorID: 20, Amount: 1000000000, AgreementLetter: "http://localhost/100.pdf"},
+			{ID: 101, LoanID: 1, InvestorID: 21, Amount: 500000000, AgreementLetter: "http://localhost/101.pdf"},
+		},
+	}, nil)
+	uc.On("GetUsersByIDs", mock.Anything, []int64{10}).Return(map[int64]*model.User{
+		10: {ID: 10, Name: "John", Email: sql.NullString{String: "john@example.com", Valid: true}},
+	}, nil)
+	uc.On("GetInvestorsByIDs", mock.Anything, []int64{20, 21}).Return(map[int64]*model.Investor{
+		20: {ID: 20, Name: "Ani", Email: sql.NullString{String: "ani@example.com", Valid: true}},
+		21: {ID: 21, Name: "Bayu", Email: sql.NullString{String: "bayu@example.com", Valid: true}},
+	}, nil)
+
+	status, res := serve(t, h, investor, `{
+		loan(id: 1) {
+			fundedAmount
+			approvalProof
+			agreementLetter
+			borrower { name email }
+			investments { id agreementLetter investor { name email } loan { id } }
+		}
+	}`, nil)
+
+	assert.Equal(t, http.StatusOK, status)
+	assert.Nil(t, res["errors"])
+	assert.Equal(t, map[string]any{
+		"fundedAmount":    float64(1500000000),
+		"approvalProof":   nil,
+		"agreementLetter": nil,
+		"borrower":        map[string]any{"name": "John", "email": nil},
+		"investments": []any{
+			map[string]any{
+				"id":              "100",
+				"agreementLetter": "http://localhost/100.pdf",
+				"investor":        map[string]any{"name": "Ani", "email": "ani@example.com"},
+				"loan":            map[string]any{"id": "1"},
+			},
+		},
+	}, res["data"].(map[string]any)["loan"])
+}
+
+func TestLoanShowsBorrowerTheirDetails(t *testing.T) {
+	uc := new(mockUsecase)
+	h := NewHandler(uc)
+	borrower := model.Actor{Role: model.RoleBorrower, ID: 10}
+
+	loan := approvedLoan(1, 10)
+	loan.AgreementLetter = sql.NullString{String: "http://localhost/agreement.pdf", Valid: true}
+	uc.On("GetLoanByID", mock.Anything, borrower, int64(1), []model.LoanExpansion(nil)).Return(&model.LoanDetail{Loan: *loan}, nil)
+	uc.On("GetUsersByIDs", mock.Anything, []int64{10}).Return(map[int64]*model.User{
+		10: {ID: 10, Name: "John", Email: sql.NullString{String: "john@example.com", Valid: true}},
+	}, nil)
+
+	_, res := serve(t, h, borrower, `{ loan(id: "1") { approvalProof agreementLetter borrower { email } } }`, nil)
+
+	assert.Nil(t, res["errors"])
+	assert.Equal(t, map[string]any{
+		"approvalProof":   nil,
+		"agreementLetter": "http://localhost/agreement.pdf",
+		"borrower":        map[string]any{"email": "john@example.com"},
+	}, res["data"].(map[string]any)["loan"])
+}
+
+func TestLoanDenied(t *testing.T) {
+	uc := new(mockUsecase)
+	h := NewHandler(uc)
+	investor := model.Actor{Role: model.RoleInvestor, ID: 20}
+
+	uc.On("GetLoanByID", mock.Anything, investor, int64(1), []model.LoanExpansion(nil)).Return(nil, model.ErrLoanAccessDenied)
+
+	status, res := serve(t, h, investor, `{ loan(id: 1) { id } }`, nil)
+
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, map[string]any{"loan": nil}, res["data"])
+	assert.Equal(t, "loan can not be accessed", res["errors"].([]any)[0].(map[string]any)["message"])
+}
+
+func TestInternalErrorsAreHidden(t *testing.T) {
+	uc := new(mockUsecase)
+	h := NewHandler(uc, WithLogger(slog.New(slog.NewTextHandler(io.Discard, nil))))
+	employee := model.Actor{Role: model.RoleEmployee, ID: 7}
+
+	uc.On("GetLoanByID", mock.Anything, employee, int64(1), []model.LoanExpansion(nil)).Return(&model.LoanDetail{Loan: *approvedLoan(1, 10)}, nil)
+	uc.On("GetUsersByIDs", mock.Anything, []int64{10}).Return(nil, errors.New("dial tcp 10.0.0.1:3306: connection refused"))
+
+	_, res := serve(t, h, employee, `{ loan(id: 1) { id borrower { name } } }`, nil)
+
+	assert.Equal(t, "internal server error, request id req-1", res["errors"].([]any)[0].(map[string]any)["message"])
+}
+
+func TestFilterRejectsInvalidIDs(t *testing.T) {
+	uc := new(mockUsecase)
+	h := NewHandler(uc)
+
+	_, res := serve(t, h, model.Actor{Role: model.RoleEmployee, ID: 7}, `{ loans(filter: {borrowerId: "john"}) { totalCount } }`, nil)
+
+	assert.Equal(t, "id is invalid", res["errors"].([]any)[0].(map[string]any)["message"])
+	uc.AssertNotCalled(t, "GetLoansForActor", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestQueryLimits(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		message string
+	}{
+		{
+			name:    "too deep",
+			query:   `{ loans(first: 1) { nodes { investments { loan { investments { loan { id } } } } } } }`,
+			message: `Field "id" has depth 7 that exceeds max depth 6`,
+		},
+		{
+			name:    "too long",
+			query:   `{ loans(first: 1) { nodes { id ` + strings.Repeat("amount ", 20) + `} } }`,
+			message: "exceeds the maximum allowed query length of 100 bytes",
+		},
+		{
+			name:    "syntax error",
+			query:   `{ loans { `,
+			message: "syntax error",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			uc := new(mockUsecase)
+			h := NewHandler(uc, WithMaxDepth(6), WithMaxQueryLength(100))
+
+			status, res := serve(t, h, model.Actor{Role: model.RoleEmployee, ID: 7}, tt.query, map[string]any{"first": 50})
+
+			assert.Equal(t, http.StatusOK, status)
+			assert.Nil(t, res["data"])
+			assert.Contains(t, res["errors"].([]any)[0].(map[string]any)["message"], tt.message)
+			uc.AssertNotCalled(t, "GetLoansForActor", mock.Anything, mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestQueryFieldLimit(t *testing.T) {
+	employee := model.Actor{Role: model.RoleEmployee, ID: 7}
+	uc := new(mockUsecase)
+	uc.On("GetLoanByID", mock.Anything, employee, int64(1), []model.LoanExpansion(nil)).Return(&model.LoanDetail{Loan: *approvedLoan(1, 10)}, nil)
+	h := NewHandler(uc, WithMaxFields(20))
+
+	var query strings.Builder
+	query.WriteString("{ ")
+	for i := range 50 {
+		fmt.Fprintf(&query, "l%d: loan(id: 1) { id state } ", i)
+	}
+	query.WriteString("}")
+
+	status, res := serve(t, h, employee, query.String(), nil)
+
+	assert.Equal(t, http.StatusOK, status)
+	assert.Nil(t, res["data"])
+	assert.Equal(t, "query resolves more than the limit of 20 fields", res["errors"].([]any)[0].(map[string]any)["message"])
+	assert.LessOrEqual(t, len(uc.Calls), 20)
+
+	uc = new(mockUsecase)
+	uc.On("GetLoanByID", mock.Anything, employee, int64(1), []model.LoanExpansion(nil)).Return(&model.LoanDetail{Loan: *approvedLoan(1, 10)}, nil)
+	h = NewHandler(uc, WithMaxFields(20))
+
+	_, res = serve(t, h, employee, `{ a: loan(id: 1) { id state } b: loan(id: 1) { id state } }`, nil)
+
+	assert.Nil(t, res["errors"])
+	assert.Equal(t, map[string]any{"id": "1", "state": "APPROVED"}, res["data"].(map[string]any)["b"])
+}
+
+func TestServeRejectsInvalidBody(t *testing.T) {
+	h := NewHandler(new(mockUsecase))
+
+	req := httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader("query { loans }"))
+	rec := httptest.NewRecorder()
+
+	err := h.Serve(echo.New().NewContext(req, rec))
+
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
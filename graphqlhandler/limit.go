@@ -0,0 +1,55 @@
+package graphqlhandler
+
+import (
+	"context"
+	"sync/atomic"
+
+	gqlerrors "github.com/graph-gophers/graphql-go/errors"
+	"github.com/graph-gophers/graphql-go/trace/noop"
+)
+
+// fieldLimiter limits the number of fields a query resolves. Depth and length
+// limits do not bound the cost of a query, as a short and shallow query can
+// repeat an expensive field under many aliases, or ask for every item of a
+// page. The executor traces every field it resolves, once per alias and once
+// per item of a list, so the tracer counts the actual cost. Once the limit of
+// the request is used up, the remaining fields get a cancelled context, and
+// the executor does not call their resolvers.
+type fieldLimiter struct {
+	noop.Tracer
+}
+
+func (fieldLimiter) TraceField(ctx context.Context, label, typeName, fieldName string, trivial bool, args map[string]any) (context.Context, func(*gqlerrors.QueryError)) {
+	finish := func(*gqlerrors.QueryError) {}
+
+	req, ok := ctx.Value(requestKey{}).(*request)
+	if !ok || req.resolveField() {
+		return ctx, finish
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	cancel()
+	return ctx, finish
+}
+
+// fieldBudget is the number of fields a request may still resolve.
+type fieldBudget struct {
+	left     atomic.Int64
+	exceeded atomic.Bool
+}
+
+func newFieldBudget(maxFields int) *fieldBudget {
+	b := &fieldBudget{}
+	b.left.Store(int64(maxFields))
+	return b
+}
+
+// resolveField takes a field from the budget, and reports whether it was
+// left. Fields are resolved in parallel.
+func (b *fieldBudget) resolveField() bool {
+	if b.left.Add(-1) >= 0 {
+		return true
+	}
+	b.exceeded.Store(true)
+	return false
+}
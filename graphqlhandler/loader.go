@@ -0,0 +1,75 @@
+package graphqlhandler
+
+import (
+	"context"
+	"sync"
+)
+
+// fetchFunc returns the values of the keys. Keys without a value are left out.
+type fetchFunc[V any] func(ctx context.Context, keys []int64) (map[int64]V, error)
+
+// loader batches the loads of values by key within a request. The keys a list
+// of parents will load are primed when the list is resolved, and the first
+// load fetches every primed key not fetched yet in one call, so resolving a
+// relation of N parents costs one fetch rather than N. Values, and errors, are
+// cached for the rest of the request.
+type loader[V any] struct {
+	fetch fetchFunc[V]
+
+	mu      sync.Mutex
+	pending []int64
+	values  map[int64]V
+	errs    map[int64]error
+}
+
+func newLoader[V any](fetch fetchFunc[V]) *loader[V] {
+	return &loader[V]{fetch: fetch, values: map[int64]V{}, errs: map[int64]error{}}
+}
+
+// prime adds the keys to the next fetch.
+func (l *loader[V]) prime(keys ...int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.pending = append(l.pending, keys...)
+}
+
+// load returns the value of the key, or the zero value when it has none.
+func (l *loader[V]) load(ctx context.Context, key int64) (V, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if !l.done(key) {
+		var keys []int64
+		seen := map[int64]bool{}
+		for _, k := range append(l.pending, key) {
+			if !seen[k] && !l.done(k) {
+				seen[k] = true
+				keys = append(keys, k)
+			}
+		}
+		l.pending = nil
+
+		values, err := l.fetch(ctx, keys)
+		for _, k := range keys {
+			if err != nil {
+				l.errs[k] = err
+				continue
+			}
+			// Keys without a value get the zero value, so they are not
+			// fetched again.
+			l.values[k] = values[k]
+		}
+	}
+
+	return l.values[key], l.errs[key]
+}
+
+// done reports whether the key was fetched. The caller holds the lock.
+func (l *loader[V]) done(key int64) bool {
+	if _, ok := l.values[key]; ok {
+		return true
+	}
+	_, ok := l.errs[key]
+	return ok
+}
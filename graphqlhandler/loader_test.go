@@ -0,0 +1,51 @@
+package graphqlhandler
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoaderBatchesPrimedKeys(t *testing.T) {
+	var fetches [][]int64
+	l := newLoader(func(ctx context.Context, keys []int64) (map[int64]string, error) {
+		fetches = append(fetches, keys)
+		return map[int64]string{1: "one", 2: "two"}, nil
+	})
+
+	l.prime(1, 2, 1, 3)
+	one, err := l.load(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, "one", one)
+
+	two, err := l.load(context.Background(), 2)
+	assert.NoError(t, err)
+	assert.Equal(t, "two", two)
+
+	missing, err := l.load(context.Background(), 3)
+	assert.NoError(t, err)
+	assert.Empty(t, missing)
+
+	_, err = l.load(context.Background(), 4)
+	assert.NoError(t, err)
+
+	assert.Equal(t, [][]int64{{1, 2, 3}, {4}}, fetches)
+}
+
+func TestLoaderCachesErrors(t *testing.T) {
+	fetches := 0
+	l := newLoader(func(ctx context.Context, keys []int64) (map[int64]string, error) {
+		fetches++
+		return nil, errors.New("connection refused")
+	})
+
+	l.prime(1, 2)
+	_, err := l.load(context.Background(), 1)
+	assert.EqualError(t, err, "connection refused")
+	_, err = l.load(context.Background(), 2)
+	assert.EqualError(t, err, "connection refused")
+
+	assert.Equal(t, 1, fetches)
+}
@@ -0,0 +1,488 @@
+package graphqlhandler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/aldipi/loan-service/model"
+	graphql "github.com/graph-gophers/graphql-go"
+)
+
+// errIDInvalid is returned for IDs in arguments that are not loan service IDs.
+var errIDInvalid = errors.New("id is invalid")
+
+var loanStateNames = map[model.LoanState]string{
+	model.LoanStateProposed:  "PROPOSED",
+	model.LoanStateApproved:  "APPROVED",
+	model.LoanStateInvested:  "INVESTED",
+	model.LoanStateDisbursed: "DISBURSED",
+}
+
+// long is the Long scalar of the schema.
+type long int64
+
+func (long) ImplementsGraphQLType(name string) bool {
+	return name == "Long"
+}
+
+func (l *long) UnmarshalGraphQL(input any) error {
+	switch input := input.(type) {
+	case int32:
+		*l = long(input)
+	case int64:
+		*l = long(input)
+	case float64:
+		// Variables are decoded from JSON as floats.
+		if input != math.Trunc(input) || math.Abs(input) > math.MaxInt64 {
+			return fmt.Errorf("%v is not a Long", input)
+		}
+		*l = long(input)
+	case string:
+		n, err := strconv.ParseInt(input, 10, 64)
+		if err != nil {
+			return fmt.Errorf("%q is not a Long", input)
+		}
+		*l = long(n)
+	default:
+		return fmt.Errorf("%v is not a Long", input)
+	}
+	return nil
+}
+
+func parseID(id graphql.ID) (int64, error) {
+	n, err := strconv.ParseInt(string(id), 10, 64)
+	if err != nil {
+		return 0, errIDInvalid
+	}
+	return n, nil
+}
+
+func toID(id int64) graphql.ID {
+	return graphql.ID(strconv.FormatInt(id, 10))
+}
+
+func toTime(t sql.NullTime) *graphql.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &graphql.Time{Time: t.Time}
+}
+
+func toString(s sql.NullString) *string {
+	if !s.Valid || s.String == "" {
+		return nil
+	}
+	return &s.String
+}
+
+type requestKey struct{}
+
+// request is the state of a GraphQL request: who runs it, the loaders
+// batching its fetches and the number of fields it may still resolve.
+type request struct {
+	actor   model.Actor
+	loaders *loaders
+	*fieldBudget
+}
+
+func withRequest(ctx context.Context, req *request) context.Context {
+	return context.WithValue(ctx, requestKey{}, req)
+}
+
+func requestFrom(ctx context.Context) *request {
+	return ctx.Value(requestKey{}).(*request)
+}
+
+func (r *request) isEmployee() bool {
+	return r.actor.Role == model.RoleEmployee
+}
+
+// is reports whether the actor is the party with the role and ID.
+func (r *request) is(role model.Role, id int64) bool {
+	return r.actor.Role == role && r.actor.ID == id
+}
+
+// loaders batch the fetches of the relations of loans.
+type loaders struct {
+	investments *loader[[]*model.Investment]
+	products    *loader[*model.LoanProduct]
+	users       *loader[*model.User]
+	employees   *loader[*model.Employee]
+	investors   *loader[*model.Investor]
+}
+
+func newLoaders(uc Usecase) *loaders {
+	l := &loaders{
+		products:  newLoader(uc.GetLoanProductsByIDs),
+		users:     newLoader(uc.GetUsersByIDs),
+		employees: newLoader(uc.GetEmployeesByIDs),
+		investors: newLoader(uc.GetInvestorsByIDs),
+	}
+	l.investments = newLoader(func(ctx context.Context, loanIDs []int64) (map[int64][]*model.Investment, error) {
+		investments, err := uc.GetInvestmentsByLoanIDs(ctx, loanIDs)
+		if err != nil {
+			return nil, err
+		}
+		// The investors of every loan fetched are loaded together.
+		for _, list := range investments {
+			for _, investment := range list {
+				l.investors.prime(investment.InvestorID)
+			}
+		}
+		return investments, nil
+	})
+	return l
+}
+
+// loanResolvers resolves the loans, priming their relations so they are
+// fetched for all loans at once.
+func (r *request) loanResolvers(loans []*model.Loan) []*loanResolver {
+	resolvers := make([]*loanResolver, 0, len(loans))
+	for _, loan := range loans {
+		r.loaders.investments.prime(loan.ID)
+		r.loaders.users.prime(loan.BorrowerID)
+		if loan.LoanProductID.Valid {
+			r.loaders.products.prime(loan.LoanProductID.Int64)
+		}
+		if loan.ApprovedBy.Valid {
+			r.loaders.employees.prime(loan.ApprovedBy.Int64)
+		}
+		if loan.DisbursedBy.Valid {
+			r.loaders.employees.prime(loan.DisbursedBy.Int64)
+		}
+		resolvers = append(resolvers, &loanResolver{req: r, loan: loan})
+	}
+	return resolvers
+}
+
+// resolver resolves the queries of the schema.
+type resolver struct {
+	uc Usecase
+}
+
+func (q *resolver) Loan(ctx context.Context, args struct{ ID graphql.ID }) (*loanResolver, error) {
+	req := requestFrom(ctx)
+	id, err := parseID(args.ID)
+	if err != nil {
+		return nil, model.ErrLoanNotFound
+	}
+
+	detail, err := q.uc.GetLoanByID(ctx, req.actor, id, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return req.loanResolvers([]*model.Loan{&detail.Loan})[0], nil
+}
+
+type loanFilterInput struct {
+	State         *string
+	BorrowerID    *graphql.ID
+	LoanProductID *graphql.ID
+	MinAmount     *long
+	MaxAmount     *long
+	CreatedFrom   *graphql.Time
+	CreatedTo     *graphql.Time
+}
+
+type loansArgs struct {
+	Filter *loanFilterInput
+	Sort   *string
+	First  int32
+	After  *string
+}
+
+func (q *resolver) Loans(ctx context.Context, args loansArgs) (*loanConnectionResolver, error) {
+	req := requestFrom(ctx)
+	filter, err := args.loanFilter()
+	if err != nil {
+		return nil, err
+	}
+
+	page, err := q.uc.GetLoansForActor(ctx, req.actor, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return &loanConnectionResolver{page: page, nodes: req.loanResolvers(page.Data)}, nil
+}
+
+func (args loansArgs) loanFilter() (model.LoanFilter, error) {
+	filter := model.LoanFilter{Limit: int(args.First)}
+	if args.Sort != nil {
+		filter.Sort = model.ParseSort(*args.Sort)
+	}
+	if args.After != nil && *args.After != "" {
+		cursor, err := model.DecodeCursor(*args.After)
+		if err != nil {
+			return filter, err
+		}
+		filter.Cursor = cursor
+	}
+
+	f := args.Filter
+	if f == nil {
+		return filter, nil
+	}
+	if f.State != nil {
+		for state, name := range loanStateNames {
+			if name == *f.State {
+				filter.State = &state
+			}
+		}
+	}
+	var err error
+	if f.BorrowerID != nil {
+		filter.BorrowerID, err = parseID(*f.BorrowerID)
+		if err != nil {
+			return filter, err
+		}
+	}
+	if f.LoanProductID != nil {
+		filter.LoanProductID, err = parseID(*f.LoanProductID)
+		if err != nil {
+			return filter, err
+		}
+	}
+	if f.MinAmount != nil {
+		filter.MinAmount = int64(*f.MinAmount)
+	}
+	if f.MaxAmount != nil {
+		filter.MaxAmount = int64(*f.MaxAmount)
+	}
+	if f.CreatedFrom != nil {
+		filter.CreatedFrom = f.CreatedFrom.Time
+	}
+	if f.CreatedTo != nil {
+		filter.CreatedTo = f.CreatedTo.Time
+	}
+	return filter, nil
+}
+
+type loanConnectionResolver struct {
+	page  *model.Page[*model.Loan]
+	nodes []*loanResolver
+}
+
+func (c *loanConnectionResolver) Nodes() []*loanResolver {
+	return c.nodes
+}
+
+func (c *loanConnectionResolver) NextCursor() *string {
+	if c.page.NextCursor == "" {
+		return nil
+	}
+	return &c.page.NextCursor
+}
+
+func (c *loanConnectionResolver) TotalCount() int32 {
+	return int32(c.page.Total)
+}
+
+type loanResolver struct {
+	req  *request
+	loan *model.Loan
+}
+
+func (l *loanResolver) ID() graphql.ID             { return toID(l.loan.ID) }
+func (l *loanResolver) State() string              { return loanStateNames[l.loan.State] }
+func (l *loanResolver) PrincipalAmount() long      { return long(l.loan.PrincipalAmount) }
+func (l *loanResolver) Currency() string           { return string(l.loan.Currency) }
+func (l *loanResolver) Rate() string               { return l.loan.Rate.String() }
+func (l *loanResolver) Roi() string                { return l.loan.ROI.String() }
+func (l *loanResolver) CreatedAt() graphql.Time    { return graphql.Time{Time: l.loan.CreatedAt} }
+func (l *loanResolver) ApprovedAt() *graphql.Time  { return toTime(l.loan.ApprovedAt) }
+func (l *loanResolver) InvestedAt() *graphql.Time  { return toTime(l.loan.InvestedAt) }
+func (l *loanResolver) DisbursedAt() *graphql.Time { return toTime(l.loan.DisbursedAt) }
+
+func (l *loanResolver) FundedAmount(ctx context.Context) (long, error) {
+	investments, err := l.req.loaders.investments.load(ctx, l.loan.ID)
+	if err != nil {
+		return 0, err
+	}
+
+	var funded int64
+	for _, investment := range investments {
+		funded += investment.Amount
+	}
+	return long(funded), nil
+}
+
+func (l *loanResolver) RemainingAmount(ctx context.Context) (long, error) {
+	funded, err := l.FundedAmount(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return long(l.loan.PrincipalAmount) - funded, nil
+}
+
+func (l *loanResolver) Product(ctx context.Context) (*loanProductResolver, error) {
+	if !l.loan.LoanProductID.Valid {
+		return nil, nil
+	}
+
+	product, err := l.req.loaders.products.load(ctx, l.loan.LoanProductID.Int64)
+	if err != nil || product == nil {
+		return nil, err
+	}
+	return &loanProductResolver{product: product}, nil
+}
+
+func (l *loanResolver) Borrower(ctx context.Context) (*userResolver, error) {
+	user, err := l.req.loaders.users.load(ctx, l.loan.BorrowerID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, model.ErrUserNotFound
+	}
+	return &userResolver{req: l.req, user: user}, nil
+}
+
+func (l *loanResolver) Approver(ctx context.Context) (*employeeResolver, error) {
+	return l.employee(ctx, l.loan.ApprovedBy)
+}
+
+func (l *loanResolver) Disburser(ctx context.Context) (*employeeResolver, error) {
+	return l.employee(ctx, l.loan.DisbursedBy)
+}
+
+func (l *loanResolver) employee(ctx context.Context, id sql.NullInt64) (*employeeResolver, error) {
+	if !id.Valid {
+		return nil, nil
+	}
+
+	employee, err := l.req.loaders.employees.load(ctx, id.Int64)
+	if err != nil || employee == nil {
+		return nil, err
+	}
+	return &employeeResolver{employee: employee}, nil
+}
+
+func (l *loanResolver) ApprovalProof() *string {
+	if !l.req.isEmployee() {
+		return nil
+	}
+	return toString(l.loan.ApprovalProof)
+}
+
+func (l *loanResolver) AgreementLetter() *string {
+	if !l.req.isEmployee() && !l.req.is(model.RoleBorrower, l.loan.BorrowerID) {
+		return nil
+	}
+	return toString(l.loan.AgreementLetter)
+}
+
+// Investments returns the investments visible to the actor. Investors only see
+// their own, as when getting the loan over the HTTP API.
+func (l *loanResolver) Investments(ctx context.Context) ([]*investmentResolver, error) {
+	investments, err := l.req.loaders.investments.load(ctx, l.loan.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	resolvers := []*investmentResolver{}
+	for _, investment := range investments {
+		if l.req.actor.Role == model.RoleInvestor && investment.InvestorID != l.req.actor.ID {
+			continue
+		}
+		resolvers = append(resolvers, &investmentResolver{req: l.req, investment: investment, loan: l})
+	}
+	return resolvers, nil
+}
+
+type investmentResolver struct {
+	req        *request
+	investment *model.Investment
+	loan       *loanResolver
+}
+
+func (i *investmentResolver) ID() graphql.ID      { return toID(i.investment.ID) }
+func (i *investmentResolver) Amount() long        { return long(i.investment.Amount) }
+func (i *investmentResolver) Currency() string    { return string(i.investment.Currency) }
+func (i *investmentResolver) FxRate() string      { return i.investment.FXRate.String() }
+func (i *investmentResolver) Loan() *loanResolver { return i.loan }
+
+func (i *investmentResolver) CreatedAt() graphql.Time {
+	return graphql.Time{Time: i.investment.CreatedAt}
+}
+
+func (i *investmentResolver) SettlementAmount() long {
+	return long(i.investment.SettlementAmount)
+}
+
+func (i *investmentResolver) SettlementCurrency() string {
+	return string(i.investment.SettlementCurrency)
+}
+
+func (i *investmentResolver) AgreementLetter() *string {
+	if !i.req.isEmployee() && !i.req.is(model.RoleInvestor, i.investment.InvestorID) {
+		return nil
+	}
+	return toString(sql.NullString{String: i.investment.AgreementLetter, Valid: true})
+}
+
+func (i *investmentResolver) Investor(ctx context.Context) (*investorResolver, error) {
+	investor, err := i.req.loaders.investors.load(ctx, i.investment.InvestorID)
+	if err != nil {
+		return nil, err
+	}
+	if investor == nil {
+		return nil, model.ErrInvestorNotFound
+	}
+	return &investorResolver{req: i.req, investor: investor}, nil
+}
+
+type loanProductResolver struct {
+	product *model.LoanProduct
+}
+
+func (p *loanProductResolver) ID() graphql.ID   { return toID(p.product.ID) }
+func (p *loanProductResolver) Name() string     { return p.product.Name }
+func (p *loanProductResolver) Rate() string     { return p.product.Rate.String() }
+func (p *loanProductResolver) Roi() string      { return p.product.ROI.String() }
+func (p *loanProductResolver) Currency() string { return string(p.product.Currency) }
+
+type userResolver struct {
+	req  *request
+	user *model.User
+}
+
+func (u *userResolver) ID() graphql.ID { return toID(u.user.ID) }
+func (u *userResolver) Name() string   { return u.user.Name }
+func (u *userResolver) Locale() string { return u.user.Locale }
+
+func (u *userResolver) Email() *string {
+	if !u.req.isEmployee() && !u.req.is(model.RoleBorrower, u.user.ID) {
+		return nil
+	}
+	return toString(u.user.Email)
+}
+
+type employeeResolver struct {
+	employee *model.Employee
+}
+
+func (e *employeeResolver) ID() graphql.ID { return toID(e.employee.ID) }
+func (e *employeeResolver) Name() string   { return e.employee.Name }
+
+type investorResolver struct {
+	req      *request
+	investor *model.Investor
+}
+
+func (i *investorResolver) ID() graphql.ID   { return toID(i.investor.ID) }
+func (i *investorResolver) Name() string     { return i.investor.Name }
+func (i *investorResolver) Locale() string   { return i.investor.Locale }
+func (i *investorResolver) Currency() string { return string(i.investor.Currency) }
+
+func (i *investorResolver) Email() *string {
+	if !i.req.isEmployee() && !i.req.is(model.RoleInvestor, i.investor.ID) {
+		return nil
+	}
+	return toString(i.investor.Email)
+}
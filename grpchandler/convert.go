@@ -0,0 +1,124 @@
+package grpchandler
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/aldipi/loan-service/model"
+	loanv1 "github.com/aldipi/loan-service/proto/loan/v1"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+var errLoanStateInvalid = model.LoanError("loan state is invalid")
+
+// Loan states of the API are shifted by one from the model, as the zero value
+// of protobuf enums means unset.
+func toLoanState(state model.LoanState) loanv1.LoanState {
+	return loanv1.LoanState(state + 1)
+}
+
+func fromLoanState(state loanv1.LoanState) (model.LoanState, error) {
+	if state < loanv1.LoanState_LOAN_STATE_PROPOSED || state > loanv1.LoanState_LOAN_STATE_DISBURSED {
+		return 0, errLoanStateInvalid
+	}
+	return model.LoanState(state - 1), nil
+}
+
+func toTimestamp(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
+
+func toNullTimestamp(t sql.NullTime) *timestamppb.Timestamp {
+	if !t.Valid {
+		return nil
+	}
+	return timestamppb.New(t.Time)
+}
+
+func fromTimestamp(t *timestamppb.Timestamp) time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+	return t.AsTime()
+}
+
+func toLoan(loan *model.Loan) *loanv1.Loan {
+	return &loanv1.Loan{
+		Id:                loan.ID,
+		State:             toLoanState(loan.State),
+		BorrowerId:        loan.BorrowerID,
+		LoanProductId:     loan.LoanProductID.Int64,
+		PrincipalAmount:   loan.PrincipalAmount,
+		Currency:          string(loan.Currency),
+		Rate:              loan.Rate.String(),
+		Roi:               loan.ROI.String(),
+		ApprovalProof:     loan.ApprovalProof.String,
+		ApprovedBy:        loan.ApprovedBy.Int64,
+		AgreementLetter:   loan.AgreementLetter.String,
+		DisbursedBy:       loan.DisbursedBy.Int64,
+		BorrowerAgreement: loan.BorrowerAgreement.String,
+		CreatedAt:         toTimestamp(loan.CreatedAt),
+		ApprovedAt:        toNullTimestamp(loan.ApprovedAt),
+		InvestedAt:        toNullTimestamp(loan.InvestedAt),
+		DisbursedAt:       toNullTimestamp(loan.DisbursedAt),
+		LastUpdatedAt:     toTimestamp(loan.LastUpdatedAt),
+	}
+}
+
+func toLoanDetail(detail *model.LoanDetail) *loanv1.Loan {
+	loan := toLoan(&detail.Loan)
+	loan.FundedAmount = detail.FundedAmount
+	loan.RemainingAmount = detail.RemainingAmount
+	loan.Borrower = toParty(detail.Borrower)
+	loan.Approver = toParty(detail.Approver)
+	loan.Disburser = toParty(detail.Disburser)
+	if detail.Product != nil {
+		loan.Product = toLoanProduct(detail.Product)
+	}
+	for _, investment := range detail.Investments {
+		loan.Investments = append(loan.Investments, &loanv1.LoanInvestment{
+			Investment:   toInvestment(&investment.Investment),
+			InvestorName: investment.InvestorName,
+		})
+	}
+	return loan
+}
+
+func toParty(party *model.Party) *loanv1.Party {
+	if party == nil {
+		return nil
+	}
+	return &loanv1.Party{Id: party.ID, Name: party.Name}
+}
+
+func toLoanProduct(product *model.LoanProduct) *loanv1.LoanProduct {
+	return &loanv1.LoanProduct{
+		Id:            product.ID,
+		Name:          product.Name,
+		Rate:          product.Rate.String(),
+		Roi:           product.ROI.String(),
+		Currency:      string(product.Currency),
+		CreatedAt:     toTimestamp(product.CreatedAt),
+		LastUpdatedAt: toTimestamp(product.LastUpdatedAt),
+	}
+}
+
+func toInvestment(investment *model.Investment) *loanv1.Investment {
+	return &loanv1.Investment{
+		Id:                 investment.ID,
+		Amount:             investment.Amount,
+		Currency:           string(investment.Currency),
+		SettlementAmount:   investment.SettlementAmount,
+		SettlementCurrency: string(investment.SettlementCurrency),
+		FxRate:             investment.FXRate.String(),
+		InvestorId:         investment.InvestorID,
+		LoanId:             investment.LoanID,
+		AgreementLetter:    investment.AgreementLetter,
+		AgreementChecksum:  investment.AgreementChecksum,
+		CreatedAt:          toTimestamp(investment.CreatedAt),
+		LastUpdatedAt:      toTimestamp(investment.LastUpdatedAt),
+	}
+}
@@ -0,0 +1,124 @@
+package grpchandler
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/aldipi/loan-service/logging"
+	"github.com/aldipi/loan-service/model"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Metadata keys of the gRPC API, matching the headers of the HTTP API.
+const (
+	UserIDKey       = "x-user-id"
+	UserRoleKey     = "x-user-role"
+	GatewayTokenKey = "x-gateway-token"
+	RequestIDKey    = "x-request-id"
+)
+
+func metadataValue(ctx context.Context, key string) string {
+	values := metadata.ValueFromIncomingContext(ctx, key)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+type callerKey struct{}
+
+// Auth identifies the caller by the x-user-id and x-user-role metadata set by
+// the auth gateway. With a gatewayToken, calls without the token in
+// x-gateway-token are rejected, so only the gateway can assert the caller.
+func Auth(gatewayToken string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if gatewayToken != "" {
+			got := metadataValue(ctx, GatewayTokenKey)
+			if subtle.ConstantTimeCompare([]byte(got), []byte(gatewayToken)) != 1 {
+				return nil, status.Error(codes.Unauthenticated, "invalid gateway token")
+			}
+		}
+
+		if value := metadataValue(ctx, UserIDKey); value != "" {
+			id, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return nil, status.Error(codes.Unauthenticated, "invalid "+UserIDKey)
+			}
+			caller := model.Actor{Role: model.Role(metadataValue(ctx, UserRoleKey)), ID: id}
+			ctx = context.WithValue(ctx, callerKey{}, caller)
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+// callerFromContext returns the caller identified by Auth, or an
+// Unauthenticated status when the call does not identify one.
+func callerFromContext(ctx context.Context) (model.Actor, error) {
+	caller, ok := ctx.Value(callerKey{}).(model.Actor)
+	if !ok {
+		return model.Actor{}, status.Error(codes.Unauthenticated, UserIDKey+" is required")
+	}
+	return caller, nil
+}
+
+// requestIDPattern matches request IDs accepted from callers, so IDs can not
+// be used to inject content into logs.
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,128}$`)
+
+// Logger identifies every call by the x-request-id metadata of the caller,
+// or a new random ID when it has none, and logs the call once it is served.
+// The ID is returned in the x-request-id header.
+func Logger(logger *slog.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		start := time.Now()
+
+		id := metadataValue(ctx, RequestIDKey)
+		if !requestIDPattern.MatchString(id) {
+			b := make([]byte, 16)
+			_, _ = rand.Read(b)
+			id = hex.EncodeToString(b)
+		}
+		ctx = logging.ContextWithRequestID(ctx, id)
+		_ = grpc.SetHeader(ctx, metadata.Pairs(RequestIDKey, id))
+
+		res, err := handler(ctx, req)
+
+		code := status.Code(err)
+		level := slog.LevelInfo
+		if code == codes.Internal || code == codes.Unknown {
+			level = slog.LevelError
+		}
+		logger.Log(ctx, level, "call",
+			"method", info.FullMethod,
+			"code", code.String(),
+			"duration", time.Since(start),
+		)
+
+		return res, err
+	}
+}
+
+// Recovery turns a panic of a call into an Internal status, so it does not
+// crash the service.
+func Recovery(logger *slog.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (res any, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				logger.ErrorContext(ctx, "call panicked", "method", info.FullMethod, "panic", fmt.Sprint(r))
+				err = status.Error(codes.Internal, "internal server error")
+			}
+		}()
+		return handler(ctx, req)
+	}
+}
@@ -0,0 +1,205 @@
+package grpchandler
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/aldipi/loan-service/model"
+	loanv1 "github.com/aldipi/loan-service/proto/loan/v1"
+)
+
+// Usecase is the part of the loan usecase served over gRPC.
+type Usecase interface {
+	GetLoans(ctx context.Context, filter model.LoanFilter) (*model.Page[*model.Loan], error)
+	GetLoanByID(ctx context.Context, actor model.Actor, loanID int64, expand []model.LoanExpansion) (*model.LoanDetail, error)
+	CreateLoan(ctx context.Context, userID int64, loanProductID int64, amount int64) (loan *model.Loan, err error)
+	ApproveLoan(ctx context.Context, loanID int64, employeeID int64, approvalProof string) error
+	DisburseLoan(ctx context.Context, loanID int64, employeeID int64, agreementLetter string) error
+	GetInvestmentsByInvestorID(ctx context.Context, investorID int64, filter model.InvestmentFilter) (*model.Page[*model.Investment], error)
+	CheckAvailableInvestmentByLoanID(ctx context.Context, loanID int64) (int64, error)
+	CreateInvestment(ctx context.Context, investorID int64, loanID int64, amount int64) (investment *model.Investment, err error)
+}
+
+// Server implements the LoanService of the gRPC API on top of the loan
+// usecase, as the HTTP handler does for the HTTP API.
+type Server struct {
+	loanv1.UnimplementedLoanServiceServer
+
+	uc     Usecase
+	logger *slog.Logger
+}
+
+// Option configures optional dependencies of Server.
+type Option func(s *Server)
+
+func NewServer(uc Usecase, opts ...Option) *Server {
+	s := &Server{uc: uc, logger: slog.Default()}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// WithLogger sets the logger of calls failing with an internal error.
+func WithLogger(logger *slog.Logger) Option {
+	return func(s *Server) {
+		s.logger = logger
+	}
+}
+
+func (s *Server) CreateLoan(ctx context.Context, req *loanv1.CreateLoanRequest) (*loanv1.Loan, error) {
+	caller, err := callerFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	loan, err := s.uc.CreateLoan(ctx, caller.ID, req.GetLoanProductId(), req.GetPrincipalAmount())
+	if err != nil {
+		return nil, s.toStatus(ctx, err)
+	}
+	return toLoan(loan), nil
+}
+
+func (s *Server) GetLoan(ctx context.Context, req *loanv1.GetLoanRequest) (*loanv1.Loan, error) {
+	caller, err := callerFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	expand := make([]model.LoanExpansion, 0, len(req.GetExpand()))
+	for _, expansion := range req.GetExpand() {
+		expand = append(expand, model.LoanExpansion(expansion))
+	}
+	loan, err := s.uc.GetLoanByID(ctx, caller, req.GetId(), expand)
+	if err != nil {
+		return nil, s.toStatus(ctx, err)
+	}
+	return toLoanDetail(loan), nil
+}
+
+func (s *Server) ListLoans(ctx context.Context, req *loanv1.ListLoansRequest) (*loanv1.ListLoansResponse, error) {
+	cursor, err := decodePageToken(req.GetPageToken())
+	if err != nil {
+		return nil, s.toStatus(ctx, err)
+	}
+	filter := model.LoanFilter{
+		BorrowerID:    req.GetBorrowerId(),
+		LoanProductID: req.GetLoanProductId(),
+		MinAmount:     req.GetMinAmount(),
+		MaxAmount:     req.GetMaxAmount(),
+		CreatedFrom:   fromTimestamp(req.GetCreatedFrom()),
+		CreatedTo:     fromTimestamp(req.GetCreatedTo()),
+		ApprovedFrom:  fromTimestamp(req.GetApprovedFrom()),
+		ApprovedTo:    fromTimestamp(req.GetApprovedTo()),
+		Sort:          model.ParseSort(req.GetSort()),
+		Cursor:        cursor,
+		Limit:         int(req.GetPageSize()),
+	}
+	if req.State != nil {
+		state, err := fromLoanState(req.GetState())
+		if err != nil {
+			return nil, s.toStatus(ctx, err)
+		}
+		filter.State = &state
+	}
+
+	page, err := s.uc.GetLoans(ctx, filter)
+	if err != nil {
+		return nil, s.toStatus(ctx, err)
+	}
+
+	res := &loanv1.ListLoansResponse{
+		Loans:         make([]*loanv1.Loan, 0, len(page.Data)),
+		NextPageToken: page.NextCursor,
+		TotalSize:     int32(page.Total),
+	}
+	for _, loan := range page.Data {
+		res.Loans = append(res.Loans, toLoan(loan))
+	}
+	return res, nil
+}
+
+func (s *Server) ApproveLoan(ctx context.Context, req *loanv1.ApproveLoanRequest) (*loanv1.ApproveLoanResponse, error) {
+	caller, err := callerFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	err = s.uc.ApproveLoan(ctx, req.GetId(), caller.ID, req.GetApprovalProof())
+	if err != nil {
+		return nil, s.toStatus(ctx, err)
+	}
+	return &loanv1.ApproveLoanResponse{}, nil
+}
+
+func (s *Server) DisburseLoan(ctx context.Context, req *loanv1.DisburseLoanRequest) (*loanv1.DisburseLoanResponse, error) {
+	caller, err := callerFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	err = s.uc.DisburseLoan(ctx, req.GetId(), caller.ID, req.GetAgreementLetter())
+	if err != nil {
+		return nil, s.toStatus(ctx, err)
+	}
+	return &loanv1.DisburseLoanResponse{}, nil
+}
+
+func (s *Server) GetLoanAvailability(ctx context.Context, req *loanv1.GetLoanAvailabilityRequest) (*loanv1.GetLoanAvailabilityResponse, error) {
+	available, err := s.uc.CheckAvailableInvestmentByLoanID(ctx, req.GetLoanId())
+	if err != nil {
+		return nil, s.toStatus(ctx, err)
+	}
+	return &loanv1.GetLoanAvailabilityResponse{AvailableAmount: available}, nil
+}
+
+func (s *Server) CreateInvestment(ctx context.Context, req *loanv1.CreateInvestmentRequest) (*loanv1.Investment, error) {
+	caller, err := callerFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	investment, err := s.uc.CreateInvestment(ctx, caller.ID, req.GetLoanId(), req.GetAmount())
+	if err != nil {
+		return nil, s.toStatus(ctx, err)
+	}
+	return toInvestment(investment), nil
+}
+
+func (s *Server) ListInvestments(ctx context.Context, req *loanv1.ListInvestmentsRequest) (*loanv1.ListInvestmentsResponse, error) {
+	caller, err := callerFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	cursor, err := decodePageToken(req.GetPageToken())
+	if err != nil {
+		return nil, s.toStatus(ctx, err)
+	}
+	filter := model.InvestmentFilter{
+		LoanID:      req.GetLoanId(),
+		MinAmount:   req.GetMinAmount(),
+		MaxAmount:   req.GetMaxAmount(),
+		CreatedFrom: fromTimestamp(req.GetCreatedFrom()),
+		CreatedTo:   fromTimestamp(req.GetCreatedTo()),
+		Sort:        model.ParseSort(req.GetSort()),
+		Cursor:      cursor,
+		Limit:       int(req.GetPageSize()),
+	}
+
+	page, err := s.uc.GetInvestmentsByInvestorID(ctx, caller.ID, filter)
+	if err != nil {
+		return nil, s.toStatus(ctx, err)
+	}
+
+	res := &loanv1.ListInvestmentsResponse{
+		Investments:   make([]*loanv1.Investment, 0, len(page.Data)),
+		NextPageToken: page.NextCursor,
+		TotalSize:     int32(page.Total),
+	}
+	for _, investment := range page.Data {
+		res.Investments = append(res.Investments, toInvestment(investment))
+	}
+	return res, nil
+}
+
+func decodePageToken(token string) (*model.Cursor, error) {
+	if token == "" {
+		return nil, nil
+	}
+	return model.DecodeCursor(token)
+}
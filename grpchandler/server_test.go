@@ -0,0 +1,282 @@
+package grpchandler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/aldipi/loan-service/model"
+	loanv1 "github.com/aldipi/loan-service/proto/loan/v1"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+type mockUsecase struct {
+	mock.Mock
+}
+
+func (m *mockUsecase) GetLoans(ctx context.Context, filter model.LoanFilter) (*model.Page[*model.Loan], error) {
+	args := m.Called(ctx, filter)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Page[*model.Loan]), args.Error(1)
+}
+
+func (m *mockUsecase) GetLoanByID(ctx context.Context, actor model.Actor, loanID int64, expand []model.LoanExpansion) (*model.LoanDetail, error) {
+	args := m.Called(ctx, actor, loanID, expand)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.LoanDetail), args.Error(1)
+}
+
+func (m *mockUsecase) CreateLoan(ctx context.Context, userID int64, loanProductID int64, amount int64) (*model.Loan, error) {
+	args := m.Called(ctx, userID, loanProductID, amount)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Loan), args.Error(1)
+}
+
+func (m *mockUsecase) ApproveLoan(ctx context.Context, loanID int64, employeeID int64, approvalProof string) error {
+	args := m.Called(ctx, loanID, employeeID, approvalProof)
+	return args.Error(0)
+}
+
+func (m *mockUsecase) DisburseLoan(ctx context.Context, loanID int64, employeeID int64, agreementLetter string) error {
+	args := m.Called(ctx, loanID, employeeID, agreementLetter)
+	return args.Error(0)
+}
+
+func (m *mockUsecase) GetInvestmentsByInvestorID(ctx context.Context, investorID int64, filter model.InvestmentFilter) (*model.Page[*model.Investment], error) {
+	args := m.Called(ctx, investorID, filter)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Page[*model.Investment]), args.Error(1)
+}
+
+func (m *mockUsecase) CheckAvailableInvestmentByLoanID(ctx context.Context, loanID int64) (int64, error) {
+	args := m.Called(ctx, loanID)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *mockUsecase) CreateInvestment(ctx context.Context, investorID int64, loanID int64, amount int64) (*model.Investment, error) {
+	args := m.Called(ctx, investorID, loanID, amount)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Investment), args.Error(1)
+}
+
+// newClient serves uc over an in-memory connection, with the interceptors of
+// the service.
+func newClient(t *testing.T, uc Usecase, gatewayToken string) loanv1.LoanServiceClient {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	listener := bufconn.Listen(1 << 20)
+	server := grpc.NewServer(grpc.ChainUnaryInterceptor(Logger(logger), Recovery(logger), Auth(gatewayToken)))
+	loanv1.RegisterLoanServiceServer(server, NewServer(uc, WithLogger(logger)))
+	go func() {
+		_ = server.Serve(listener)
+	}()
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	return loanv1.NewLoanServiceClient(conn)
+}
+
+func asUser(id string, kv ...string) context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), append([]string{UserIDKey, id}, kv...)...)
+}
+
+func TestCreateLoan(t *testing.T) {
+	uc := new(mockUsecase)
+	client := newClient(t, uc, "")
+
+	createdAt := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	uc.On("CreateLoan", mock.Anything, int64(7), int64(1), int64(500000)).Return(&model.Loan{
+		ID:              3,
+		State:           model.LoanStateProposed,
+		BorrowerID:      7,
+		LoanProductID:   sql.NullInt64{Int64: 1, Valid: true},
+		PrincipalAmount: 500000,
+		Currency:        "IDR",
+		Rate:            decimal.RequireFromString("0.125"),
+		ROI:             decimal.RequireFromString("0.1"),
+		CreatedAt:       createdAt,
+	}, nil)
+
+	loan, err := client.CreateLoan(asUser("7"), &loanv1.CreateLoanRequest{LoanProductId: 1, PrincipalAmount: 500000})
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), loan.GetId())
+	assert.Equal(t, loanv1.LoanState_LOAN_STATE_PROPOSED, loan.GetState())
+	assert.Equal(t, "0.125", loan.GetRate())
+	assert.Equal(t, createdAt, loan.GetCreatedAt().AsTime())
+	assert.Nil(t, loan.GetApprovedAt())
+}
+
+func TestCallerRequired(t *testing.T) {
+	uc := new(mockUsecase)
+	client := newClient(t, uc, "")
+
+	_, err := client.CreateLoan(context.Background(), &loanv1.CreateLoanRequest{LoanProductId: 1, PrincipalAmount: 500000})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	_, err = client.CreateLoan(asUser("seven"), &loanv1.CreateLoanRequest{LoanProductId: 1, PrincipalAmount: 500000})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	uc.AssertNotCalled(t, "CreateLoan", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestGatewayToken(t *testing.T) {
+	uc := new(mockUsecase)
+	client := newClient(t, uc, "gateway-secret")
+
+	uc.On("CheckAvailableInvestmentByLoanID", mock.Anything, int64(3)).Return(int64(250000), nil)
+
+	_, err := client.GetLoanAvailability(asUser("7", GatewayTokenKey, "wrong"), &loanv1.GetLoanAvailabilityRequest{LoanId: 3})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	res, err := client.GetLoanAvailability(asUser("7", GatewayTokenKey, "gateway-secret"), &loanv1.GetLoanAvailabilityRequest{LoanId: 3})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(250000), res.GetAvailableAmount())
+}
+
+func TestLoanErrorStatus(t *testing.T) {
+	uc := new(mockUsecase)
+	client := newClient(t, uc, "")
+
+	uc.On("ApproveLoan", mock.Anything, int64(1), int64(9), "https://example.com/proof.jpg").Return(model.ErrLoanNotProposed)
+	uc.On("ApproveLoan", mock.Anything, int64(2), int64(9), "").Return(model.ErrEmployeeNotFound)
+	uc.On("ApproveLoan", mock.Anything, int64(3), int64(9), "not a url").Return(model.LoanError("approval proof is invalid"))
+	uc.On("ApproveLoan", mock.Anything, int64(4), int64(9), "").Return(errors.New("dial tcp 10.0.0.5:3306: connection refused"))
+
+	tests := []struct {
+		req     *loanv1.ApproveLoanRequest
+		code    codes.Code
+		message string
+	}{
+		{&loanv1.ApproveLoanRequest{Id: 1, ApprovalProof: "https://example.com/proof.jpg"}, codes.FailedPrecondition, "loan not proposed"},
+		{&loanv1.ApproveLoanRequest{Id: 2}, codes.NotFound, "employee not found"},
+		{&loanv1.ApproveLoanRequest{Id: 3, ApprovalProof: "not a url"}, codes.InvalidArgument, "approval proof is invalid"},
+		{&loanv1.ApproveLoanRequest{Id: 4}, codes.Internal, "internal server error, request id req-1"},
+	}
+	for _, tt := range tests {
+		_, err := client.ApproveLoan(asUser("9", RequestIDKey, "req-1"), tt.req)
+		st, _ := status.FromError(err)
+		assert.Equal(t, tt.code, st.Code())
+		assert.Equal(t, tt.message, st.Message())
+	}
+}
+
+func TestListLoans(t *testing.T) {
+	uc := new(mockUsecase)
+	client := newClient(t, uc, "")
+
+	approved := model.LoanStateApproved
+	cursor := model.Cursor{Sort: "id", ID: 10}
+	uc.On("GetLoans", mock.Anything, model.LoanFilter{
+		State:  &approved,
+		Sort:   model.Sort{Field: model.SortByPrincipalAmount, Desc: true},
+		Cursor: &cursor,
+		Limit:  2,
+	}).Return(&model.Page[*model.Loan]{
+		Data:       []*model.Loan{{ID: 11, State: model.LoanStateApproved}, {ID: 12, State: model.LoanStateApproved}},
+		NextCursor: "next",
+		Total:      5,
+	}, nil)
+
+	state := loanv1.LoanState_LOAN_STATE_APPROVED
+	res, err := client.ListLoans(context.Background(), &loanv1.ListLoansRequest{
+		State:     &state,
+		Sort:      "-principal_amount",
+		PageSize:  2,
+		PageToken: cursor.Encode(),
+	})
+
+	assert.NoError(t, err)
+	assert.Len(t, res.GetLoans(), 2)
+	assert.Equal(t, loanv1.LoanState_LOAN_STATE_APPROVED, res.GetLoans()[0].GetState())
+	assert.Equal(t, "next", res.GetNextPageToken())
+	assert.Equal(t, int32(5), res.GetTotalSize())
+}
+
+func TestListLoansInvalidArguments(t *testing.T) {
+	uc := new(mockUsecase)
+	client := newClient(t, uc, "")
+
+	state := loanv1.LoanState_LOAN_STATE_UNSPECIFIED
+	_, err := client.ListLoans(context.Background(), &loanv1.ListLoansRequest{State: &state})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = client.ListLoans(context.Background(), &loanv1.ListLoansRequest{PageToken: "!"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	uc.AssertNotCalled(t, "GetLoans", mock.Anything, mock.Anything)
+}
+
+func TestGetLoanDetail(t *testing.T) {
+	uc := new(mockUsecase)
+	client := newClient(t, uc, "")
+
+	actor := model.Actor{Role: model.RoleInvestor, ID: 4}
+	uc.On("GetLoanByID", mock.Anything, actor, int64(3), []model.LoanExpansion{model.ExpandInvestments}).Return(&model.LoanDetail{
+		Loan:            model.Loan{ID: 3, State: model.LoanStateInvested, PrincipalAmount: 1000},
+		FundedAmount:    1000,
+		RemainingAmount: 0,
+		Investments: []*model.LoanInvestment{{
+			Investment:   model.Investment{ID: 8, Amount: 1000, InvestorID: 4, LoanID: 3, FXRate: decimal.NewFromInt(1)},
+			InvestorName: "Investor",
+		}},
+	}, nil)
+
+	loan, err := client.GetLoan(asUser("4", UserRoleKey, "investor"), &loanv1.GetLoanRequest{Id: 3, Expand: []string{"investments"}})
+
+	assert.NoError(t, err)
+	assert.Equal(t, loanv1.LoanState_LOAN_STATE_INVESTED, loan.GetState())
+	assert.Equal(t, int64(1000), loan.GetFundedAmount())
+	assert.Len(t, loan.GetInvestments(), 1)
+	assert.Equal(t, int64(8), loan.GetInvestments()[0].GetInvestment().GetId())
+	assert.Equal(t, "1", loan.GetInvestments()[0].GetInvestment().GetFxRate())
+	assert.Nil(t, loan.GetProduct())
+}
+
+type panickingUsecase struct {
+	mockUsecase
+}
+
+func (u *panickingUsecase) CheckAvailableInvestmentByLoanID(ctx context.Context, loanID int64) (int64, error) {
+	panic("boom")
+}
+
+func TestRecovery(t *testing.T) {
+	client := newClient(t, new(panickingUsecase), "")
+
+	_, err := client.GetLoanAvailability(context.Background(), &loanv1.GetLoanAvailabilityRequest{LoanId: 3})
+
+	assert.Equal(t, codes.Internal, status.Code(err))
+}
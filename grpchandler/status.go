@@ -0,0 +1,56 @@
+package grpchandler
+
+import (
+	"context"
+	"errors"
+
+	"github.com/aldipi/loan-service/logging"
+	"github.com/aldipi/loan-service/model"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// loanErrorCodes maps the loan errors not caused by an invalid argument to
+// their status code.
+var loanErrorCodes = map[model.LoanError]codes.Code{
+	model.ErrLoanNotFound:            codes.NotFound,
+	model.ErrLoanProductNotFound:     codes.NotFound,
+	model.ErrInvestmentNotFound:      codes.NotFound,
+	model.ErrUserNotFound:            codes.NotFound,
+	model.ErrEmployeeNotFound:        codes.NotFound,
+	model.ErrInvestorNotFound:        codes.NotFound,
+	model.ErrLoanAccessDenied:        codes.PermissionDenied,
+	model.ErrLoanNotProposed:         codes.FailedPrecondition,
+	model.ErrLoanNotApproved:         codes.FailedPrecondition,
+	model.ErrLoanFundingEnded:        codes.FailedPrecondition,
+	model.ErrLoanNotInvested:         codes.FailedPrecondition,
+	model.ErrLoanEventInvalid:        codes.FailedPrecondition,
+	model.ErrAgreementsNotSigned:     codes.FailedPrecondition,
+	model.ErrDocumentChecksumChanged: codes.FailedPrecondition,
+	model.ErrInsufficientFunds:       codes.FailedPrecondition,
+	model.ErrCurrencyMismatch:        codes.FailedPrecondition,
+	model.ErrFXRateUnavailable:       codes.FailedPrecondition,
+	model.ErrPaymentDeclined:         codes.FailedPrecondition,
+	model.ErrDocumentUnavailable:     codes.Unavailable,
+}
+
+// toStatus returns the status of a failed call. Loan errors are the caller's
+// fault and are returned as is, other errors are logged and returned without
+// their details, which may expose internals of the service.
+func (s *Server) toStatus(ctx context.Context, err error) error {
+	var loanErr model.LoanError
+	if errors.As(err, &loanErr) {
+		code, ok := loanErrorCodes[loanErr]
+		if !ok {
+			code = codes.InvalidArgument
+		}
+		return status.Error(code, loanErr.Error())
+	}
+
+	s.logger.ErrorContext(ctx, "call failed", "error", err)
+	message := "internal server error"
+	if id := logging.RequestID(ctx); id != "" {
+		message += ", request id " + id
+	}
+	return status.Error(codes.Internal, message)
+}
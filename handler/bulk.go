@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/aldipi/loan-service/model"
+	"github.com/labstack/echo/v4"
+)
+
+type bulkApprovalRequest struct {
+	model.BulkOptions
+	Loans []model.BulkApproval `json:"loans"`
+}
+
+type bulkDisbursementRequest struct {
+	model.BulkOptions
+	Loans []model.BulkDisbursement `json:"loans"`
+}
+
+func (h *HttpHanlder) BulkApproveLoans(c echo.Context) error {
+	employeeID, _ := strconv.ParseInt(c.Request().Header.Get("X-User-Id"), 10, 64)
+	req := bulkApprovalRequest{}
+	err := c.Bind(&req)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, "invalid request body")
+	}
+	result, err := h.uc.BulkApproveLoans(c.Request().Context(), employeeID, req.Loans, req.BulkOptions)
+	return h.bulkResult(c, result, err)
+}
+
+func (h *HttpHanlder) BulkDisburseLoans(c echo.Context) error {
+	employeeID, _ := strconv.ParseInt(c.Request().Header.Get("X-User-Id"), 10, 64)
+	req := bulkDisbursementRequest{}
+	err := c.Bind(&req)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, "invalid request body")
+	}
+	result, err := h.uc.BulkDisburseLoans(c.Request().Context(), employeeID, req.Loans, req.BulkOptions)
+	return h.bulkResult(c, result, err)
+}
+
+// bulkResult responds with the result of every loan of a bulk request. The
+// request succeeds when its loans were processed, even if some of them failed.
+func (h *HttpHanlder) bulkResult(c echo.Context, result *model.BulkResult, err error) error {
+	if err != nil {
+		if loanErr, ok := err.(model.LoanError); ok {
+			return c.JSON(http.StatusBadRequest, loanErr.Error())
+		}
+		return h.internalError(c, err)
+	}
+	return c.JSON(http.StatusOK, result)
+}
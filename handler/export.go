@@ -0,0 +1,118 @@
+package handler
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/aldipi/loan-service/model"
+	"github.com/labstack/echo/v4"
+)
+
+// attachmentWriter writes a file download. The headers are sent with the
+// first bytes of the file, so a request failing before then still gets an
+// error response.
+type attachmentWriter struct {
+	c        echo.Context
+	format   model.ExportFormat
+	filename string
+}
+
+func (w *attachmentWriter) Write(p []byte) (int, error) {
+	res := w.c.Response()
+	if !res.Committed {
+		res.Header().Set(echo.HeaderContentType, w.format.ContentType())
+		res.Header().Set(echo.HeaderContentDisposition, `attachment; filename="`+w.filename+`"`)
+		res.WriteHeader(http.StatusOK)
+	}
+	return res.Write(p)
+}
+
+func (h *HttpHanlder) ExportLoans(c echo.Context) error {
+	actor := requestActor(c)
+	format := model.ExportFormat(c.Param("format"))
+	filter, err := parseLoanFilter(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	if c.Request().Method == http.MethodPost {
+		job, err := h.uc.CreateLoanExportJob(c.Request().Context(), actor, format, filter)
+		return h.exportJobCreated(c, job, err)
+	}
+	return h.export(c, format, "loans", func(w io.Writer) error {
+		return h.uc.ExportLoans(c.Request().Context(), actor, format, filter, w)
+	})
+}
+
+func (h *HttpHanlder) ExportInvestments(c echo.Context) error {
+	actor := requestActor(c)
+	format := model.ExportFormat(c.Param("format"))
+	filter, err := parseInvestmentFilter(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	q := &queryParams{c: c}
+	filter.InvestorID = q.int64("investor_id")
+	if err := q.err(); err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	if c.Request().Method == http.MethodPost {
+		job, err := h.uc.CreateInvestmentExportJob(c.Request().Context(), actor, format, filter)
+		return h.exportJobCreated(c, job, err)
+	}
+	return h.export(c, format, "investments", func(w io.Writer) error {
+		return h.uc.ExportInvestments(c.Request().Context(), actor, format, filter, w)
+	})
+}
+
+func (h *HttpHanlder) GetExportJob(c echo.Context) error {
+	id, _ := strconv.ParseInt(c.Param("id"), 10, 64)
+	job, err := h.uc.GetExportJob(c.Request().Context(), requestActor(c), id)
+	if err != nil {
+		if err == model.ErrExportJobNotFound {
+			return c.JSON(http.StatusNotFound, err.Error())
+		}
+		return h.internalError(c, err)
+	}
+	return c.JSON(http.StatusOK, job)
+}
+
+// export streams a file written by write. Large exports take longer than the
+// write timeout of the server, which is lifted for the request. A failure
+// after the file was started can not be reported to the client anymore, so
+// the connection is broken off instead of ending the file as if complete.
+func (h *HttpHanlder) export(c echo.Context, format model.ExportFormat, name string, write func(w io.Writer) error) error {
+	// Not every response writer supports deadlines, and then there is none.
+	_ = http.NewResponseController(c.Response()).SetWriteDeadline(time.Time{})
+
+	err := write(&attachmentWriter{c: c, format: format, filename: name + "." + string(format)})
+	if err == nil {
+		return nil
+	}
+	if c.Response().Committed {
+		h.logger.ErrorContext(c.Request().Context(), "export failed", "route", c.Path(), "error", err)
+		panic(http.ErrAbortHandler)
+	}
+	if err == model.ErrExportAccessDenied {
+		return c.JSON(http.StatusForbidden, err.Error())
+	}
+	if loanErr, ok := err.(model.LoanError); ok {
+		return c.JSON(http.StatusBadRequest, loanErr.Error())
+	}
+	return h.internalError(c, err)
+}
+
+func (h *HttpHanlder) exportJobCreated(c echo.Context, job *model.ExportJob, err error) error {
+	if err != nil {
+		if err == model.ErrExportAccessDenied {
+			return c.JSON(http.StatusForbidden, err.Error())
+		}
+		if loanErr, ok := err.(model.LoanError); ok {
+			return c.JSON(http.StatusBadRequest, loanErr.Error())
+		}
+		return h.internalError(c, err)
+	}
+	c.Response().Header().Set(echo.HeaderLocation, "/exports/"+strconv.FormatInt(job.ID, 10))
+	return c.JSON(http.StatusAccepted, job)
+}
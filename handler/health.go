@@ -0,0 +1,72 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// readinessTimeout bounds how long readiness checks may take, so probes get
+// an answer before they time out themselves.
+const readinessTimeout = 2 * time.Second
+
+// ReadinessCheck is a dependency the service needs to serve requests.
+type ReadinessCheck struct {
+	Name  string
+	Check func(ctx context.Context) error
+}
+
+// WithReadinessChecks sets the checks run by Readyz.
+func WithReadinessChecks(checks ...ReadinessCheck) Option {
+	return func(h *HttpHanlder) {
+		h.readinessChecks = append(h.readinessChecks, checks...)
+	}
+}
+
+type healthResponse struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+// Healthz tells whether the process is alive. It checks no dependency, so a
+// database outage does not get the service restarted.
+func (h *HttpHanlder) Healthz(c echo.Context) error {
+	return c.JSON(http.StatusOK, healthResponse{Status: "ok"})
+}
+
+// Readyz tells whether the service can serve requests, running every
+// readiness check concurrently. It responds with 503 Service Unavailable and
+// the failed checks when any check fails.
+func (h *HttpHanlder) Readyz(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), readinessTimeout)
+	defer cancel()
+
+	errs := make([]error, len(h.readinessChecks))
+	var wg sync.WaitGroup
+	for i, check := range h.readinessChecks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = check.Check(ctx)
+		}()
+	}
+	wg.Wait()
+
+	res := healthResponse{Status: "ok", Checks: make(map[string]string, len(h.readinessChecks))}
+	status := http.StatusOK
+	for i, check := range h.readinessChecks {
+		if errs[i] != nil {
+			h.logger.WarnContext(ctx, "readiness check failed", "check", check.Name, "error", errs[i])
+			res.Checks[check.Name] = errs[i].Error()
+			res.Status = "unavailable"
+			status = http.StatusServiceUnavailable
+			continue
+		}
+		res.Checks[check.Name] = "ok"
+	}
+
+	return c.JSON(status, res)
+}
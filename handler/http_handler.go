@@ -2,41 +2,99 @@ package handler
 
 import (
 	"context"
+	"io"
+	"log/slog"
 	"net/http"
 	"strconv"
+	"strings"
 
+	"github.com/aldipi/loan-service/logging"
 	"github.com/aldipi/loan-service/model"
 	"github.com/labstack/echo/v4"
 )
 
 type Usecase interface {
-	GetLoans(ctx context.Context, limit int, offset int) ([]*model.Loan, error)
+	GetLoans(ctx context.Context, filter model.LoanFilter) (*model.Page[*model.Loan], error)
 	GetLoansByBorrowerID(ctx context.Context, borrowerID int64, limit int, offset int) ([]*model.Loan, error)
-	CreateLoan(ctx context.Context, userID int64, loanProductID int64, amount int) (loan *model.Loan, err error)
+	GetLoanByID(ctx context.Context, actor model.Actor, loanID int64, expand []model.LoanExpansion) (*model.LoanDetail, error)
+	CreateLoan(ctx context.Context, userID int64, loanProductID int64, amount int64) (loan *model.Loan, err error)
 	ApproveLoan(ctx context.Context, loanID int64, employeeID int64, approvalProof string) error
 	DisburseLoan(ctx context.Context, loanID int64, employeeID int64, agreementLetter string) error
-	GetInvestmentsByInvestorID(ctx context.Context, investorID int64, limit int, offset int) ([]*model.Investment, error)
-	CheckAvailableInvestmentByLoanID(ctx context.Context, loanID int64) (int, error)
-	CreateInvestment(ctx context.Context, investorID int64, loanID int64, amount int) (investment *model.Investment, err error)
+	RepayLoan(ctx context.Context, loanID int64, employeeID int64, amount int64) (*model.Repayment, error)
+	BulkApproveLoans(ctx context.Context, employeeID int64, approvals []model.BulkApproval, options model.BulkOptions) (*model.BulkResult, error)
+	BulkDisburseLoans(ctx context.Context, employeeID int64, disbursements []model.BulkDisbursement, options model.BulkOptions) (*model.BulkResult, error)
+	GetMarketplaceLoans(ctx context.Context, filter model.MarketplaceFilter) (*model.Page[*model.MarketplaceLoan], error)
+	GetInvestmentsByInvestorID(ctx context.Context, investorID int64, filter model.InvestmentFilter) (*model.Page[*model.Investment], error)
+	GetPortfolio(ctx context.Context, investorID int64, currency model.Currency) (*model.Portfolio, error)
+	GetWallet(ctx context.Context, investorID int64, limit int) (*model.Wallet, error)
+	Deposit(ctx context.Context, investorID int64, amount int64) (*model.WalletEntry, error)
+	Withdraw(ctx context.Context, investorID int64, amount int64) (*model.WalletEntry, error)
+	CheckAvailableInvestmentByLoanID(ctx context.Context, loanID int64) (int64, error)
+	CreateInvestment(ctx context.Context, investorID int64, loanID int64, amount int64) (investment *model.Investment, err error)
+	UploadLoanDocument(ctx context.Context, actor model.Actor, loanID int64, kind model.DocumentKind, contentType string, data []byte) (*model.Upload, error)
+	GetLoanDocuments(ctx context.Context, actor model.Actor, loanID int64) ([]*model.LoanDocument, error)
+	GetAgreementToSign(ctx context.Context, token string) (*model.LoanDocument, error)
+	SignAgreement(ctx context.Context, token string, signerID int64, ipAddress string) (*model.Signature, error)
+	GetLoanSignatures(ctx context.Context, actor model.Actor, loanID int64) ([]*model.Signature, error)
+	GetTrialBalance(ctx context.Context) (*model.TrialBalance, error)
+	CreateWebhook(ctx context.Context, actor model.Actor, webhookURL string, eventTypes []string, secret string) (*model.Webhook, error)
+	GetWebhookDeliveries(ctx context.Context, actor model.Actor, webhookID int64, limit int, offset int) ([]*model.WebhookDelivery, error)
+	ExportLoans(ctx context.Context, actor model.Actor, format model.ExportFormat, filter model.LoanFilter, w io.Writer) error
+	ExportInvestments(ctx context.Context, actor model.Actor, format model.ExportFormat, filter model.InvestmentFilter, w io.Writer) error
+	CreateLoanExportJob(ctx context.Context, actor model.Actor, format model.ExportFormat, filter model.LoanFilter) (*model.ExportJob, error)
+	CreateInvestmentExportJob(ctx context.Context, actor model.Actor, format model.ExportFormat, filter model.InvestmentFilter) (*model.ExportJob, error)
+	GetExportJob(ctx context.Context, actor model.Actor, id int64) (*model.ExportJob, error)
 }
 
 type HttpHanlder struct {
-	uc Usecase
+	uc              Usecase
+	logger          *slog.Logger
+	readinessChecks []ReadinessCheck
 }
 
-func NewHttpHandler(uc Usecase) *HttpHanlder {
-	return &HttpHanlder{uc: uc}
+// Option configures optional dependencies of HttpHanlder.
+type Option func(h *HttpHanlder)
+
+func NewHttpHandler(uc Usecase, opts ...Option) *HttpHanlder {
+	h := &HttpHanlder{uc: uc, logger: slog.Default()}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+// WithLogger sets the logger of requests failing with an internal error.
+func WithLogger(logger *slog.Logger) Option {
+	return func(h *HttpHanlder) {
+		h.logger = logger
+	}
+}
+
+// internalError logs err and responds without its details, which may expose
+// internals of the service. The response carries the request ID so the
+// failure can be found in the logs.
+func (h *HttpHanlder) internalError(c echo.Context, err error) error {
+	ctx := c.Request().Context()
+	h.logger.ErrorContext(ctx, "request failed", "method", c.Request().Method, "route", c.Path(), "error", err)
+
+	message := "internal server error"
+	if id := logging.RequestID(ctx); id != "" {
+		message += ", request id " + id
+	}
+	return c.JSON(http.StatusInternalServerError, message)
 }
 
 func (h *HttpHanlder) GetAllLoans(c echo.Context) error {
-	limit, _ := strconv.Atoi(c.QueryParam("limit"))
-	if limit == 0 {
-		limit = 10
+	filter, err := parseLoanFilter(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
 	}
-	offset, _ := strconv.Atoi(c.QueryParam("offset"))
-	loans, err := h.uc.GetLoans(c.Request().Context(), limit, offset)
+	loans, err := h.uc.GetLoans(c.Request().Context(), filter)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, err)
+		if loanErr, ok := err.(model.LoanError); ok {
+			return c.JSON(http.StatusBadRequest, loanErr.Error())
+		}
+		return h.internalError(c, err)
 	}
 	return c.JSON(http.StatusOK, loans)
 }
@@ -50,21 +108,46 @@ func (h *HttpHanlder) GetLoans(c echo.Context) error {
 	offset, _ := strconv.Atoi(c.QueryParam("offset"))
 	loans, err := h.uc.GetLoansByBorrowerID(c.Request().Context(), borrowerID, limit, offset)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, err)
+		return h.internalError(c, err)
 	}
 	return c.JSON(http.StatusOK, loans)
 }
 
+func (h *HttpHanlder) GetLoan(c echo.Context) error {
+	actor := requestActor(c)
+	loanID, _ := strconv.ParseInt(c.Param("id"), 10, 64)
+	expand := []model.LoanExpansion{}
+	for _, expansion := range strings.Split(c.QueryParam("expand"), ",") {
+		if expansion = strings.TrimSpace(expansion); expansion != "" {
+			expand = append(expand, model.LoanExpansion(expansion))
+		}
+	}
+	loan, err := h.uc.GetLoanByID(c.Request().Context(), actor, loanID, expand)
+	if err != nil {
+		if err == model.ErrLoanNotFound {
+			return c.JSON(http.StatusNotFound, err.Error())
+		}
+		if err == model.ErrLoanAccessDenied {
+			return c.JSON(http.StatusForbidden, err.Error())
+		}
+		if loanErr, ok := err.(model.LoanError); ok {
+			return c.JSON(http.StatusBadRequest, loanErr.Error())
+		}
+		return h.internalError(c, err)
+	}
+	return c.JSON(http.StatusOK, loan)
+}
+
 func (h *HttpHanlder) CreateLoan(c echo.Context) error {
 	userID, _ := strconv.ParseInt(c.Request().Header["X-User-Id"][0], 10, 64)
 	loanProductID, _ := strconv.ParseInt(c.FormValue("loanProductID"), 10, 64)
-	amount, _ := strconv.Atoi(c.FormValue("amount"))
+	amount, _ := strconv.ParseInt(c.FormValue("amount"), 10, 64)
 	loan, err := h.uc.CreateLoan(c.Request().Context(), userID, loanProductID, amount)
 	if err != nil {
 		if loanErr, ok := err.(model.LoanError); ok {
 			return c.JSON(http.StatusBadRequest, loanErr.Error())
 		}
-		return c.JSON(http.StatusInternalServerError, err)
+		return h.internalError(c, err)
 	}
 	return c.JSON(http.StatusCreated, loan)
 }
@@ -78,7 +161,7 @@ func (h *HttpHanlder) ApproveLoan(c echo.Context) error {
 		if loanErr, ok := err.(model.LoanError); ok {
 			return c.JSON(http.StatusBadRequest, loanErr.Error())
 		}
-		return c.JSON(http.StatusInternalServerError, err)
+		return h.internalError(c, err)
 	}
 	return c.JSON(http.StatusOK, "Loan approved")
 }
@@ -92,35 +175,239 @@ func (h *HttpHanlder) DisburseLoan(c echo.Context) error {
 		if loanErr, ok := err.(model.LoanError); ok {
 			return c.JSON(http.StatusBadRequest, loanErr.Error())
 		}
-		return c.JSON(http.StatusInternalServerError, err)
+		return h.internalError(c, err)
 	}
 	return c.JSON(http.StatusOK, "Loan disbursed")
 }
 
+func (h *HttpHanlder) RepayLoan(c echo.Context) error {
+	employee := requestActor(c)
+	if employee.ID == 0 {
+		return c.JSON(http.StatusUnauthorized, userRequired)
+	}
+	loanID, _ := strconv.ParseInt(c.Param("id"), 10, 64)
+	amount, _ := strconv.ParseInt(c.FormValue("amount"), 10, 64)
+	repayment, err := h.uc.RepayLoan(c.Request().Context(), loanID, employee.ID, amount)
+	if err != nil {
+		if loanErr, ok := err.(model.LoanError); ok {
+			return c.JSON(http.StatusBadRequest, loanErr.Error())
+		}
+		return h.internalError(c, err)
+	}
+	return c.JSON(http.StatusCreated, repayment)
+}
+
+func (h *HttpHanlder) UploadApprovalProof(c echo.Context) error {
+	return h.uploadLoanDocument(c, model.DocumentApprovalProof)
+}
+
+func (h *HttpHanlder) UploadSignedAgreement(c echo.Context) error {
+	return h.uploadLoanDocument(c, model.DocumentSignedAgreement)
+}
+
+func (h *HttpHanlder) uploadLoanDocument(c echo.Context, kind model.DocumentKind) error {
+	loanID, _ := strconv.ParseInt(c.Param("id"), 10, 64)
+	file, err := c.FormFile("file")
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, "file is required")
+	}
+	if file.Size > model.MaxDocumentSize {
+		return c.JSON(http.StatusBadRequest, model.ErrDocumentTooLarge.Error())
+	}
+	src, err := file.Open()
+	if err != nil {
+		return h.internalError(c, err)
+	}
+	defer src.Close()
+	data, err := io.ReadAll(io.LimitReader(src, model.MaxDocumentSize+1))
+	if err != nil {
+		return h.internalError(c, err)
+	}
+	upload, err := h.uc.UploadLoanDocument(c.Request().Context(), requestActor(c), loanID, kind, file.Header.Get("Content-Type"), data)
+	if err != nil {
+		if err == model.ErrLoanNotFound {
+			return c.JSON(http.StatusNotFound, err.Error())
+		}
+		if err == model.ErrLoanAccessDenied {
+			return c.JSON(http.StatusForbidden, err.Error())
+		}
+		if loanErr, ok := err.(model.LoanError); ok {
+			return c.JSON(http.StatusBadRequest, loanErr.Error())
+		}
+		return h.internalError(c, err)
+	}
+	return c.JSON(http.StatusCreated, upload)
+}
+
+func (h *HttpHanlder) GetLoanDocuments(c echo.Context) error {
+	loanID, _ := strconv.ParseInt(c.Param("id"), 10, 64)
+	documents, err := h.uc.GetLoanDocuments(c.Request().Context(), requestActor(c), loanID)
+	if err != nil {
+		if err == model.ErrLoanNotFound {
+			return c.JSON(http.StatusNotFound, err.Error())
+		}
+		if err == model.ErrLoanAccessDenied {
+			return c.JSON(http.StatusForbidden, err.Error())
+		}
+		if loanErr, ok := err.(model.LoanError); ok {
+			return c.JSON(http.StatusBadRequest, loanErr.Error())
+		}
+		return h.internalError(c, err)
+	}
+	return c.JSON(http.StatusOK, documents)
+}
+
+func (h *HttpHanlder) GetAgreementToSign(c echo.Context) error {
+	document, err := h.uc.GetAgreementToSign(c.Request().Context(), c.Param("token"))
+	if err != nil {
+		if err == model.ErrSignatureNotFound {
+			return c.JSON(http.StatusNotFound, err.Error())
+		}
+		return h.internalError(c, err)
+	}
+	return c.JSON(http.StatusOK, document)
+}
+
+func (h *HttpHanlder) SignAgreement(c echo.Context) error {
+	signer := requestActor(c)
+	if signer.ID == 0 {
+		return c.JSON(http.StatusUnauthorized, userRequired)
+	}
+	signature, err := h.uc.SignAgreement(c.Request().Context(), c.Param("token"), signer.ID, c.RealIP())
+	if err != nil {
+		if err == model.ErrSignatureNotFound {
+			return c.JSON(http.StatusNotFound, err.Error())
+		}
+		if loanErr, ok := err.(model.LoanError); ok {
+			return c.JSON(http.StatusBadRequest, loanErr.Error())
+		}
+		return h.internalError(c, err)
+	}
+	return c.JSON(http.StatusOK, signature)
+}
+
+func (h *HttpHanlder) GetLoanSignatures(c echo.Context) error {
+	loanID, _ := strconv.ParseInt(c.Param("id"), 10, 64)
+	signatures, err := h.uc.GetLoanSignatures(c.Request().Context(), requestActor(c), loanID)
+	if err != nil {
+		if err == model.ErrLoanNotFound {
+			return c.JSON(http.StatusNotFound, err.Error())
+		}
+		if err == model.ErrLoanAccessDenied {
+			return c.JSON(http.StatusForbidden, err.Error())
+		}
+		if loanErr, ok := err.(model.LoanError); ok {
+			return c.JSON(http.StatusBadRequest, loanErr.Error())
+		}
+		return h.internalError(c, err)
+	}
+	return c.JSON(http.StatusOK, signatures)
+}
+
+func (h *HttpHanlder) GetMarketplaceLoans(c echo.Context) error {
+	filter, err := parseMarketplaceFilter(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	loans, err := h.uc.GetMarketplaceLoans(c.Request().Context(), filter)
+	if err != nil {
+		if loanErr, ok := err.(model.LoanError); ok {
+			return c.JSON(http.StatusBadRequest, loanErr.Error())
+		}
+		return h.internalError(c, err)
+	}
+	return c.JSON(http.StatusOK, loans)
+}
+
 func (h *HttpHanlder) GetInvestments(c echo.Context) error {
 	investorID, _ := strconv.ParseInt(c.Request().Header["X-User-Id"][0], 10, 64)
-	limit, _ := strconv.Atoi(c.QueryParam("limit"))
-	if limit == 0 {
-		limit = 10
+	filter, err := parseInvestmentFilter(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
 	}
-	offset, _ := strconv.Atoi(c.QueryParam("offset"))
-	investments, err := h.uc.GetInvestmentsByInvestorID(c.Request().Context(), investorID, limit, offset)
+	investments, err := h.uc.GetInvestmentsByInvestorID(c.Request().Context(), investorID, filter)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, err)
+		if loanErr, ok := err.(model.LoanError); ok {
+			return c.JSON(http.StatusBadRequest, loanErr.Error())
+		}
+		return h.internalError(c, err)
 	}
 	return c.JSON(http.StatusOK, investments)
 }
 
+func (h *HttpHanlder) GetPortfolio(c echo.Context) error {
+	investor := requestActor(c)
+	if investor.ID == 0 {
+		return c.JSON(http.StatusUnauthorized, userRequired)
+	}
+	portfolio, err := h.uc.GetPortfolio(c.Request().Context(), investor.ID, model.Currency(c.QueryParam("currency")))
+	if err != nil {
+		if loanErr, ok := err.(model.LoanError); ok {
+			return c.JSON(http.StatusBadRequest, loanErr.Error())
+		}
+		return h.internalError(c, err)
+	}
+	return c.JSON(http.StatusOK, portfolio)
+}
+
+func (h *HttpHanlder) GetWallet(c echo.Context) error {
+	investor := requestActor(c)
+	if investor.ID == 0 {
+		return c.JSON(http.StatusUnauthorized, userRequired)
+	}
+	limit, _ := strconv.Atoi(c.QueryParam("limit"))
+	wallet, err := h.uc.GetWallet(c.Request().Context(), investor.ID, limit)
+	if err != nil {
+		if loanErr, ok := err.(model.LoanError); ok {
+			return c.JSON(http.StatusBadRequest, loanErr.Error())
+		}
+		return h.internalError(c, err)
+	}
+	return c.JSON(http.StatusOK, wallet)
+}
+
+func (h *HttpHanlder) Deposit(c echo.Context) error {
+	investor := requestActor(c)
+	if investor.ID == 0 {
+		return c.JSON(http.StatusUnauthorized, userRequired)
+	}
+	amount, _ := strconv.ParseInt(c.FormValue("amount"), 10, 64)
+	entry, err := h.uc.Deposit(c.Request().Context(), investor.ID, amount)
+	if err != nil {
+		if loanErr, ok := err.(model.LoanError); ok {
+			return c.JSON(http.StatusBadRequest, loanErr.Error())
+		}
+		return h.internalError(c, err)
+	}
+	return c.JSON(http.StatusCreated, entry)
+}
+
+func (h *HttpHanlder) Withdraw(c echo.Context) error {
+	investor := requestActor(c)
+	if investor.ID == 0 {
+		return c.JSON(http.StatusUnauthorized, userRequired)
+	}
+	amount, _ := strconv.ParseInt(c.FormValue("amount"), 10, 64)
+	entry, err := h.uc.Withdraw(c.Request().Context(), investor.ID, amount)
+	if err != nil {
+		if loanErr, ok := err.(model.LoanError); ok {
+			return c.JSON(http.StatusBadRequest, loanErr.Error())
+		}
+		return h.internalError(c, err)
+	}
+	return c.JSON(http.StatusCreated, entry)
+}
+
 func (h *HttpHanlder) CreateInvestment(c echo.Context) error {
 	investorID, _ := strconv.ParseInt(c.Request().Header["X-User-Id"][0], 10, 64)
 	loanID, _ := strconv.ParseInt(c.FormValue("loan_id"), 10, 64)
-	amount, _ := strconv.Atoi(c.FormValue("amount"))
+	amount, _ := strconv.ParseInt(c.FormValue("amount"), 10, 64)
 	investment, err := h.uc.CreateInvestment(c.Request().Context(), investorID, loanID, amount)
 	if err != nil {
 		if loanErr, ok := err.(model.LoanError); ok {
 			return c.JSON(http.StatusBadRequest, loanErr.Error())
 		}
-		return c.JSON(http.StatusInternalServerError, err)
+		return h.internalError(c, err)
 	}
 	return c.JSON(http.StatusCreated, investment)
 }
@@ -132,7 +419,68 @@ func (h *HttpHanlder) LoanAvailability(c echo.Context) error {
 		if loanErr, ok := err.(model.LoanError); ok {
 			return c.JSON(http.StatusBadRequest, loanErr.Error())
 		}
-		return c.JSON(http.StatusInternalServerError, err)
+		return h.internalError(c, err)
 	}
-	return c.JSON(http.StatusOK, availableAmount)
+	return c.JSON(http.StatusOK, strconv.FormatInt(availableAmount, 10))
+}
+
+func (h *HttpHanlder) GetTrialBalance(c echo.Context) error {
+	trialBalance, err := h.uc.GetTrialBalance(c.Request().Context())
+	if err != nil {
+		return h.internalError(c, err)
+	}
+	return c.JSON(http.StatusOK, trialBalance)
+}
+
+func (h *HttpHanlder) CreateWebhook(c echo.Context) error {
+	webhookURL := c.FormValue("url")
+	eventTypes := []string{}
+	for _, eventType := range strings.Split(c.FormValue("event_types"), ",") {
+		if eventType = strings.TrimSpace(eventType); eventType != "" {
+			eventTypes = append(eventTypes, eventType)
+		}
+	}
+	secret := c.FormValue("secret")
+	webhook, err := h.uc.CreateWebhook(c.Request().Context(), requestActor(c), webhookURL, eventTypes, secret)
+	if err != nil {
+		if err == model.ErrWebhookAccessDenied {
+			return c.JSON(http.StatusForbidden, err.Error())
+		}
+		if loanErr, ok := err.(model.LoanError); ok {
+			return c.JSON(http.StatusBadRequest, loanErr.Error())
+		}
+		return h.internalError(c, err)
+	}
+	return c.JSON(http.StatusCreated, webhook)
+}
+
+func (h *HttpHanlder) GetWebhookDeliveries(c echo.Context) error {
+	webhookID, _ := strconv.ParseInt(c.Param("id"), 10, 64)
+	limit, _ := strconv.Atoi(c.QueryParam("limit"))
+	if limit == 0 {
+		limit = 10
+	}
+	offset, _ := strconv.Atoi(c.QueryParam("offset"))
+	deliveries, err := h.uc.GetWebhookDeliveries(c.Request().Context(), requestActor(c), webhookID, limit, offset)
+	if err != nil {
+		if err == model.ErrWebhookAccessDenied {
+			return c.JSON(http.StatusForbidden, err.Error())
+		}
+		if err == model.ErrWebhookNotFound {
+			return c.JSON(http.StatusNotFound, err.Error())
+		}
+		return h.internalError(c, err)
+	}
+	return c.JSON(http.StatusOK, deliveries)
+}
+
+// userRequired answers requests without a valid X-User-Id header on routes
+// acting for the user.
+const userRequired = "X-User-Id header is required"
+
+// requestActor returns the user making the request from the X-User-Id and
+// X-User-Role headers. The ID is 0 when the header is missing or invalid.
+func requestActor(c echo.Context) model.Actor {
+	userID, _ := strconv.ParseInt(c.Request().Header.Get("X-User-Id"), 10, 64)
+	return model.Actor{Role: model.Role(c.Request().Header.Get("X-User-Role")), ID: userID}
 }
@@ -0,0 +1,135 @@
+package handler
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/aldipi/loan-service/model"
+	"github.com/labstack/echo/v4"
+)
+
+const dateLayout = "2006-01-02"
+
+// queryParams parses optional query parameters, keeping the first invalid one.
+type queryParams struct {
+	c       echo.Context
+	invalid string
+}
+
+func (q *queryParams) int(name string) int {
+	value := q.c.QueryParam(name)
+	if value == "" {
+		return 0
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		q.fail(name)
+	}
+	return n
+}
+
+func (q *queryParams) int64(name string) int64 {
+	value := q.c.QueryParam(name)
+	if value == "" {
+		return 0
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		q.fail(name)
+	}
+	return n
+}
+
+// time parses an RFC 3339 timestamp or a date. A date ending a range includes
+// the whole day.
+func (q *queryParams) time(name string, end bool) time.Time {
+	value := q.c.QueryParam(name)
+	if value == "" {
+		return time.Time{}
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err == nil {
+		return t
+	}
+	t, err = time.Parse(dateLayout, value)
+	if err != nil {
+		q.fail(name)
+		return time.Time{}
+	}
+	if end {
+		return t.AddDate(0, 0, 1)
+	}
+	return t
+}
+
+func (q *queryParams) cursor() *model.Cursor {
+	value := q.c.QueryParam("cursor")
+	if value == "" {
+		return nil
+	}
+	cursor, err := model.DecodeCursor(value)
+	if err != nil {
+		q.fail("cursor")
+	}
+	return cursor
+}
+
+func (q *queryParams) fail(name string) {
+	if q.invalid == "" {
+		q.invalid = name
+	}
+}
+
+func (q *queryParams) err() error {
+	if q.invalid == "" {
+		return nil
+	}
+	return model.LoanError("invalid query parameter: " + q.invalid)
+}
+
+func parseLoanFilter(c echo.Context) (model.LoanFilter, error) {
+	q := &queryParams{c: c}
+	filter := model.LoanFilter{
+		BorrowerID:    q.int64("borrower_id"),
+		LoanProductID: q.int64("loan_product_id"),
+		MinAmount:     q.int64("min_amount"),
+		MaxAmount:     q.int64("max_amount"),
+		CreatedFrom:   q.time("created_from", false),
+		CreatedTo:     q.time("created_to", true),
+		ApprovedFrom:  q.time("approved_from", false),
+		ApprovedTo:    q.time("approved_to", true),
+		Sort:          model.ParseSort(c.QueryParam("sort")),
+		Cursor:        q.cursor(),
+		Limit:         q.int("limit"),
+	}
+	if c.QueryParam("state") != "" {
+		state := model.LoanState(q.int("state"))
+		filter.State = &state
+	}
+	return filter, q.err()
+}
+
+func parseInvestmentFilter(c echo.Context) (model.InvestmentFilter, error) {
+	q := &queryParams{c: c}
+	filter := model.InvestmentFilter{
+		LoanID:      q.int64("loan_id"),
+		MinAmount:   q.int64("min_amount"),
+		MaxAmount:   q.int64("max_amount"),
+		CreatedFrom: q.time("created_from", false),
+		CreatedTo:   q.time("created_to", true),
+		Sort:        model.ParseSort(c.QueryParam("sort")),
+		Cursor:      q.cursor(),
+		Limit:       q.int("limit"),
+	}
+	return filter, q.err()
+}
+
+func parseMarketplaceFilter(c echo.Context) (model.MarketplaceFilter, error) {
+	q := &queryParams{c: c}
+	filter := model.MarketplaceFilter{
+		Sort:   model.ParseSort(c.QueryParam("sort")),
+		Cursor: q.cursor(),
+		Limit:  q.int("limit"),
+	}
+	return filter, q.err()
+}
@@ -0,0 +1,127 @@
+package handler
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"slices"
+	"time"
+
+	"github.com/aldipi/loan-service/logging"
+	"github.com/labstack/echo/v4"
+)
+
+// RequestObserver records the requests served by the API.
+type RequestObserver interface {
+	ObserveRequest(method, route string, status int, duration time.Duration)
+}
+
+// Metrics records the method, route, status and latency of every request.
+// Requests matching no route are recorded with the route "unmatched".
+func Metrics(observer RequestObserver) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			start := time.Now()
+
+			err := next(c)
+			if err != nil {
+				c.Error(err)
+			}
+
+			route := c.Path()
+			if route == "" {
+				route = "unmatched"
+			}
+			observer.ObserveRequest(c.Request().Method, route, c.Response().Status, time.Since(start))
+
+			return nil
+		}
+	}
+}
+
+// requestIDPattern matches request IDs accepted from callers, so IDs can not
+// be used to inject content into logs.
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,128}$`)
+
+// RequestID identifies every request by the X-Request-ID header of the caller,
+// or a new random ID when it has none. The ID is returned in the X-Request-ID
+// response header and carried by the request context, so it is logged with
+// every line about the request.
+func RequestID() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			id := req.Header.Get(echo.HeaderXRequestID)
+			if !requestIDPattern.MatchString(id) {
+				id = newRequestID()
+			}
+
+			c.Response().Header().Set(echo.HeaderXRequestID, id)
+			c.SetRequest(req.WithContext(logging.ContextWithRequestID(req.Context(), id)))
+
+			return next(c)
+		}
+	}
+}
+
+func newRequestID() string {
+	id := make([]byte, 16)
+	_, _ = rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// RequestLogger logs every request once it is served. Requests are logged by
+// their route rather than their path, which may carry tokens, and failed
+// requests are logged at error level.
+func RequestLogger(logger *slog.Logger) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			start := time.Now()
+
+			err := next(c)
+			if err != nil {
+				c.Error(err)
+			}
+
+			res := c.Response()
+			level := slog.LevelInfo
+			if res.Status >= http.StatusInternalServerError {
+				level = slog.LevelError
+			}
+			logger.Log(c.Request().Context(), level, "request",
+				"method", c.Request().Method,
+				"route", c.Path(),
+				"status", res.Status,
+				"duration", time.Since(start),
+				"bytes_out", res.Size,
+			)
+
+			return nil
+		}
+	}
+}
+
+// GatewayTokenHeader carries the token shared by the auth gateway and the
+// service.
+const GatewayTokenHeader = "X-Gateway-Token"
+
+// GatewayAuth rejects requests without the token of the auth gateway, so the
+// X-User-Id of a request can only be asserted by the gateway. Requests to the
+// skipped routes, such as probes, need no token.
+func GatewayAuth(token string, skipRoutes ...string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if slices.Contains(skipRoutes, c.Path()) {
+				return next(c)
+			}
+			got := c.Request().Header.Get(GatewayTokenHeader)
+			if subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+				return c.JSON(http.StatusUnauthorized, "invalid gateway token")
+			}
+			return next(c)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+func decode(t *testing.T, buf *bytes.Buffer) map[string]any {
+	line := map[string]any{}
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &line))
+	buf.Reset()
+	return line
+}
+
+func TestNew(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, slog.LevelInfo)
+
+	logger.Debug("hidden")
+	assert.Empty(t, buf.String())
+
+	ctx := ContextWithRequestID(context.Background(), "req-1")
+	logger.With("component", "usecase").InfoContext(ctx, "loan created", "loan_id", 1)
+
+	line := decode(t, &buf)
+	assert.Equal(t, "INFO", line["level"])
+	assert.Equal(t, "loan created", line["msg"])
+	assert.Equal(t, "usecase", line["component"])
+	assert.Equal(t, float64(1), line["loan_id"])
+	assert.Equal(t, "req-1", line["request_id"])
+	assert.NotContains(t, line, "trace_id")
+
+	ctx, span := sdktrace.NewTracerProvider().Tracer("test").Start(ctx, "GET /loans")
+	logger.ErrorContext(ctx, "request failed")
+
+	line = decode(t, &buf)
+	assert.Equal(t, span.SpanContext().TraceID().String(), line["trace_id"])
+	assert.Equal(t, span.SpanContext().SpanID().String(), line["span_id"])
+}
+
+func TestParseLevel(t *testing.T) {
+	level, err := ParseLevel("")
+	assert.NoError(t, err)
+	assert.Equal(t, slog.LevelInfo, level)
+
+	level, err = ParseLevel("debug")
+	assert.NoError(t, err)
+	assert.Equal(t, slog.LevelDebug, level)
+
+	level, err = ParseLevel("WARN")
+	assert.NoError(t, err)
+	assert.Equal(t, slog.LevelWarn, level)
+
+	_, err = ParseLevel("verbose")
+	assert.Error(t, err)
+}
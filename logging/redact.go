@@ -0,0 +1,66 @@
+package logging
+
+import (
+	"log/slog"
+	"regexp"
+	"strings"
+)
+
+const redacted = "[REDACTED]"
+
+// sensitiveKeys are the attribute keys whose values are never logged. Keys
+// ending in _name, _url, _token or _secret are redacted as well.
+var sensitiveKeys = map[string]bool{
+	"name":             true,
+	"email":            true,
+	"token":            true,
+	"secret":           true,
+	"password":         true,
+	"authorization":    true,
+	"signature":        true,
+	"url":              true,
+	"approval_proof":   true,
+	"agreement_letter": true,
+}
+
+var sensitiveSuffixes = []string{"_name", "_url", "_token", "_secret"}
+
+// urlPattern matches URLs, which may point at private documents or carry
+// signing tokens and signed URL signatures.
+var urlPattern = regexp.MustCompile(`[a-zA-Z][a-zA-Z0-9+.-]*://[^\s"'<>]+`)
+
+// Redact is a slog.HandlerOptions.ReplaceAttr function hiding the values of
+// sensitive attributes, and URLs anywhere in messages, strings and errors.
+func Redact(groups []string, attr slog.Attr) slog.Attr {
+	if isSensitive(attr.Key) {
+		return slog.String(attr.Key, redacted)
+	}
+
+	switch attr.Value.Kind() {
+	case slog.KindString:
+		attr.Value = slog.StringValue(RedactString(attr.Value.String()))
+	case slog.KindAny:
+		if err, ok := attr.Value.Any().(error); ok {
+			attr.Value = slog.StringValue(RedactString(err.Error()))
+		}
+	}
+	return attr
+}
+
+// RedactString replaces every URL in s.
+func RedactString(s string) string {
+	return urlPattern.ReplaceAllString(s, "[REDACTED URL]")
+}
+
+func isSensitive(key string) bool {
+	key = strings.ToLower(key)
+	if sensitiveKeys[key] {
+		return true
+	}
+	for _, suffix := range sensitiveSuffixes {
+		if strings.HasSuffix(key, suffix) {
+			return true
+		}
+	}
+	return false
+}
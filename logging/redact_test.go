@@ -0,0 +1,43 @@
+package logging
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedact(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, slog.LevelInfo)
+
+	logger.Info("fetch https://file.io/123/proof.jpg failed",
+		"loan_id", 1,
+		"name", "Budi Santoso",
+		"borrower_name", "Budi Santoso",
+		"document_url", "https://bucket.s3.amazonaws.com/loans/1/agreement.pdf",
+		"signing_token", "c2lnbmluZy10b2tlbg",
+		"Authorization", "Bearer abc",
+		"error", errors.New(`Get "https://docs.example.com/a.pdf?X-Amz-Signature=abc": timeout`),
+		slog.Group("request", "path", "/signatures", "url", "http://localhost:8080/signatures/token"),
+	)
+
+	out := buf.String()
+	for _, secret := range []string{"Budi", "file.io", "bucket.s3", "c2lnbmluZy10b2tlbg", "Bearer", "X-Amz-Signature", "signatures/token"} {
+		assert.NotContains(t, out, secret)
+	}
+
+	line := decode(t, bytes.NewBufferString(out))
+	assert.Equal(t, "fetch [REDACTED URL] failed", line["msg"])
+	assert.Equal(t, float64(1), line["loan_id"])
+	assert.Equal(t, "[REDACTED]", line["borrower_name"])
+	assert.Equal(t, `Get "[REDACTED URL]": timeout`, line["error"])
+	assert.Equal(t, map[string]any{"path": "/signatures", "url": "[REDACTED]"}, line["request"])
+}
+
+func TestRedactString(t *testing.T) {
+	assert.Equal(t, "see [REDACTED URL] and [REDACTED URL]", RedactString("see https://a.example/x?token=1 and s3://bucket/key"))
+	assert.Equal(t, "no urls here", RedactString("no urls here"))
+}
@@ -0,0 +1,29 @@
+package metrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// HTTPMetrics measures the requests served by the API.
+type HTTPMetrics struct {
+	duration *prometheus.HistogramVec
+}
+
+func NewHTTPMetrics(r prometheus.Registerer) *HTTPMetrics {
+	return &HTTPMetrics{
+		duration: promauto.With(r).NewHistogramVec(prometheus.HistogramOpts{
+			Name: "http_request_duration_seconds",
+			Help: "Time taken to serve HTTP requests.",
+		}, []string{"method", "route", "status"}),
+	}
+}
+
+// ObserveRequest records a request to route, the path pattern it matched
+// rather than its path, so loans do not each get their own series.
+func (m *HTTPMetrics) ObserveRequest(method, route string, status int, duration time.Duration) {
+	m.duration.WithLabelValues(method, route, strconv.Itoa(status)).Observe(duration.Seconds())
+}
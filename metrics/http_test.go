@@ -0,0 +1,23 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHTTPMetrics(t *testing.T) {
+	r := prometheus.NewRegistry()
+	m := NewHTTPMetrics(r)
+
+	m.ObserveRequest("GET", "/loans/:id", 200, 30*time.Millisecond)
+	m.ObserveRequest("GET", "/loans/:id", 200, 2*time.Second)
+
+	out := scrape(t, r)
+	assert.Contains(t, out, `http_request_duration_seconds_bucket{method="GET",route="/loans/:id",status="200",le="0.05"} 1`+"\n")
+	assert.Contains(t, out, `http_request_duration_seconds_count{method="GET",route="/loans/:id",status="200"} 2`+"\n")
+	assert.True(t, strings.HasPrefix(out, "# HELP http_request_duration_seconds "))
+}
@@ -0,0 +1,144 @@
+package metrics
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/aldipi/loan-service/model"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// TimeToFundBuckets are the upper bounds, in seconds, of the time loans take
+// from approval to being fully funded.
+var TimeToFundBuckets = []float64{
+	3600,       // 1 hour
+	6 * 3600,   // 6 hours
+	86400,      // 1 day
+	3 * 86400,  // 3 days
+	7 * 86400,  // 1 week
+	14 * 86400, // 2 weeks
+	30 * 86400, // 30 days
+	60 * 86400, // 60 days
+}
+
+var loanStates = []struct {
+	state model.LoanState
+	name  string
+}{
+	{model.LoanStateProposed, "proposed"},
+	{model.LoanStateApproved, "approved"},
+	{model.LoanStateInvested, "invested"},
+	{model.LoanStateDisbursed, "disbursed"},
+}
+
+// LoanMetrics counts the loans and investments handled by the usecase.
+type LoanMetrics struct {
+	created          *prometheus.CounterVec
+	approved         *prometheus.CounterVec
+	disbursed        *prometheus.CounterVec
+	investments      *prometheus.CounterVec
+	investmentAmount *prometheus.CounterVec
+	timeToFund       *prometheus.HistogramVec
+}
+
+func NewLoanMetrics(r prometheus.Registerer) *LoanMetrics {
+	factory := promauto.With(r)
+	counter := func(name, help, label string) *prometheus.CounterVec {
+		return factory.NewCounterVec(prometheus.CounterOpts{Name: name, Help: help}, []string{label})
+	}
+	return &LoanMetrics{
+		created:          counter("loans_created_total", "Number of loans proposed.", "product"),
+		approved:         counter("loans_approved_total", "Number of loans approved.", "product"),
+		disbursed:        counter("loans_disbursed_total", "Number of loans disbursed.", "product"),
+		investments:      counter("investments_total", "Number of investments made.", "currency"),
+		investmentAmount: counter("investment_amount_total", "Amount invested, in the currency of the loan.", "currency"),
+		timeToFund: factory.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "loan_time_to_fund_seconds",
+			Help:    "Time from the approval of a loan until it is fully funded.",
+			Buckets: TimeToFundBuckets,
+		}, []string{"product"}),
+	}
+}
+
+func (m *LoanMetrics) LoanCreated(loan *model.Loan) {
+	m.created.WithLabelValues(productLabel(loan)).Inc()
+}
+
+func (m *LoanMetrics) LoanApproved(loan *model.Loan) {
+	m.approved.WithLabelValues(productLabel(loan)).Inc()
+}
+
+// LoanFunded records the time the loan took to be fully funded after its
+// approval.
+func (m *LoanMetrics) LoanFunded(loan *model.Loan) {
+	if !loan.ApprovedAt.Valid || !loan.InvestedAt.Valid {
+		return
+	}
+	m.timeToFund.WithLabelValues(productLabel(loan)).Observe(loan.InvestedAt.Time.Sub(loan.ApprovedAt.Time).Seconds())
+}
+
+func (m *LoanMetrics) LoanDisbursed(loan *model.Loan) {
+	m.disbursed.WithLabelValues(productLabel(loan)).Inc()
+}
+
+// InvestmentCreated records the investment, in major units of its currency so
+// the volume reads as money.
+func (m *LoanMetrics) InvestmentCreated(investment *model.Investment) {
+	currency := string(investment.Currency)
+	m.investments.WithLabelValues(currency).Inc()
+	m.investmentAmount.WithLabelValues(currency).Add(float64(investment.Amount) / math.Pow10(investment.Currency.Exponent()))
+}
+
+// productLabel returns the loan product of loan, or none for loans proposed
+// before loan products existed.
+func productLabel(loan *model.Loan) string {
+	if !loan.LoanProductID.Valid {
+		return "none"
+	}
+	return strconv.FormatInt(loan.LoanProductID.Int64, 10)
+}
+
+// LoanCounter counts the loans in every state.
+type LoanCounter interface {
+	CountLoansByState(ctx context.Context) (map[model.LoanState]int, error)
+}
+
+// loanStatesTimeout bounds how long counting the loans may hold up a scrape.
+const loanStatesTimeout = 5 * time.Second
+
+// loanStatesCollector collects the number of loans in every state, counted
+// whenever the registry is scraped.
+type loanStatesCollector struct {
+	counter LoanCounter
+	desc    *prometheus.Desc
+}
+
+// RegisterLoanStates registers the number of loans in every state, counted
+// by counter whenever the registry is scraped.
+func RegisterLoanStates(r prometheus.Registerer, counter LoanCounter) {
+	r.MustRegister(&loanStatesCollector{
+		counter: counter,
+		desc:    prometheus.NewDesc("loans", "Number of loans by state.", []string{"state"}, nil),
+	})
+}
+
+func (c *loanStatesCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *loanStatesCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), loanStatesTimeout)
+	defer cancel()
+
+	counts, err := c.counter.CountLoansByState(ctx)
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(c.desc, err)
+		return
+	}
+	for _, s := range loanStates {
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, float64(counts[s.state]), s.name)
+	}
+}
@@ -0,0 +1,79 @@
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aldipi/loan-service/model"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoanMetrics(t *testing.T) {
+	r := prometheus.NewRegistry()
+	m := NewLoanMetrics(r)
+
+	approvedAt := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	loan := &model.Loan{
+		LoanProductID: sql.NullInt64{Int64: 7, Valid: true},
+		ApprovedAt:    sql.NullTime{Time: approvedAt, Valid: true},
+		InvestedAt:    sql.NullTime{Time: approvedAt.Add(2 * 24 * time.Hour), Valid: true},
+	}
+	m.LoanCreated(loan)
+	m.LoanCreated(&model.Loan{})
+	m.LoanApproved(loan)
+	m.LoanFunded(loan)
+	m.LoanFunded(&model.Loan{})
+	m.LoanDisbursed(loan)
+	m.InvestmentCreated(&model.Investment{Amount: 150050, Currency: "IDR"})
+	m.InvestmentCreated(&model.Investment{Amount: 250, Currency: "JPY"})
+
+	out := scrape(t, r)
+	assert.Contains(t, out, `loans_created_total{product="7"} 1`+"\n")
+	assert.Contains(t, out, `loans_created_total{product="none"} 1`+"\n")
+	assert.Contains(t, out, `loans_approved_total{product="7"} 1`+"\n")
+	assert.Contains(t, out, `loans_disbursed_total{product="7"} 1`+"\n")
+	assert.Contains(t, out, `investments_total{currency="IDR"} 1`+"\n")
+	assert.Contains(t, out, `investment_amount_total{currency="IDR"} 1500.5`+"\n")
+	assert.Contains(t, out, `investment_amount_total{currency="JPY"} 250`+"\n")
+	assert.Contains(t, out, `loan_time_to_fund_seconds_bucket{product="7",le="86400"} 0`+"\n")
+	assert.Contains(t, out, `loan_time_to_fund_seconds_bucket{product="7",le="259200"} 1`+"\n")
+	assert.Contains(t, out, `loan_time_to_fund_seconds_count{product="7"} 1`+"\n")
+}
+
+type loanCounterFunc func(ctx context.Context) (map[model.LoanState]int, error)
+
+func (f loanCounterFunc) CountLoansByState(ctx context.Context) (map[model.LoanState]int, error) {
+	return f(ctx)
+}
+
+func TestRegisterLoanStates(t *testing.T) {
+	r := prometheus.NewRegistry()
+	RegisterLoanStates(r, loanCounterFunc(func(ctx context.Context) (map[model.LoanState]int, error) {
+		return map[model.LoanState]int{model.LoanStateApproved: 3, model.LoanStateDisbursed: 1}, nil
+	}))
+
+	assert.NoError(t, testutil.GatherAndCompare(r, strings.NewReader(`# HELP loans Number of loans by state.
+# TYPE loans gauge
+loans{state="approved"} 3
+loans{state="disbursed"} 1
+loans{state="invested"} 0
+loans{state="proposed"} 0
+`), "loans"))
+}
+
+func TestRegisterLoanStatesFailure(t *testing.T) {
+	r := NewRegistry()
+	RegisterLoanStates(r, loanCounterFunc(func(ctx context.Context) (map[model.LoanState]int, error) {
+		return nil, errors.New("db is down")
+	}))
+
+	_, err := r.Gather()
+	assert.ErrorContains(t, err, "db is down")
+	assert.NotContains(t, scrape(t, r), "# TYPE loans ")
+}
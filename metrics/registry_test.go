@@ -0,0 +1,54 @@
+package metrics
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+func scrape(t *testing.T, r *prometheus.Registry) string {
+	rec := httptest.NewRecorder()
+	Handler(r, slog.Default()).ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Header().Get("Content-Type"), "text/plain; version=0.0.4")
+	return rec.Body.String()
+}
+
+func TestNewRegistry(t *testing.T) {
+	out := scrape(t, NewRegistry())
+
+	assert.Contains(t, out, "# TYPE go_goroutines gauge\n")
+	assert.Contains(t, out, "# TYPE process_start_time_seconds gauge\n")
+}
+
+type failingCollector struct {
+	desc *prometheus.Desc
+}
+
+func (c failingCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c failingCollector) Collect(ch chan<- prometheus.Metric) {
+	ch <- prometheus.NewInvalidMetric(c.desc, assert.AnError)
+}
+
+func TestHandlerLogsFailedCollection(t *testing.T) {
+	r := prometheus.NewRegistry()
+	r.MustRegister(failingCollector{desc: prometheus.NewDesc("broken", "Never collected.", nil, nil)})
+	NewHTTPMetrics(r).ObserveRequest("GET", "/loans", 200, 0)
+
+	var logs bytes.Buffer
+	rec := httptest.NewRecorder()
+	Handler(r, slog.New(slog.NewJSONHandler(&logs, nil))).ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "http_request_duration_seconds_count")
+	assert.NotContains(t, rec.Body.String(), "broken")
+	assert.Contains(t, logs.String(), assert.AnError.Error())
+}
@@ -0,0 +1,54 @@
+package model
+
+// MaxBulkLoans is the most loans one bulk request may change.
+const MaxBulkLoans = 500
+
+type BulkApproval struct {
+	LoanID        int64  `json:"loan_id"`
+	ApprovalProof string `json:"approval_proof"`
+}
+
+type BulkDisbursement struct {
+	LoanID          int64  `json:"loan_id"`
+	AgreementLetter string `json:"agreement_letter"`
+}
+
+// BulkOptions changes how a bulk request is processed. Atomic requests apply
+// every loan in one transaction, or none when any of them fails. Dry runs
+// only validate the loans.
+type BulkOptions struct {
+	Atomic bool `json:"atomic"`
+	DryRun bool `json:"dry_run"`
+}
+
+type BulkItemStatus string
+
+const (
+	// BulkItemApplied loans were changed.
+	BulkItemApplied BulkItemStatus = "applied"
+	// BulkItemValid loans could be changed, but were not as the request was
+	// a dry run or an atomic request with failed loans.
+	BulkItemValid BulkItemStatus = "valid"
+	// BulkItemFailed loans could not be changed, for the reason in Error.
+	BulkItemFailed BulkItemStatus = "failed"
+)
+
+type BulkItemResult struct {
+	LoanID int64          `json:"loan_id"`
+	Status BulkItemStatus `json:"status"`
+	Error  string         `json:"error,omitempty"`
+}
+
+// BulkResult holds the result of every loan of a bulk request, in the order
+// of the request.
+type BulkResult struct {
+	Applied int              `json:"applied"`
+	Failed  int              `json:"failed"`
+	Items   []BulkItemResult `json:"items"`
+}
+
+const (
+	ErrBulkEmpty         = LoanError("bulk request has no loans")
+	ErrBulkTooLarge      = LoanError("bulk request has too many loans")
+	ErrBulkDuplicateLoan = LoanError("loan is already in the bulk request")
+)
@@ -0,0 +1,79 @@
+package model
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+type AgreementKind string
+
+const (
+	AgreementInvestor AgreementKind = "investor"
+	AgreementBorrower AgreementKind = "borrower"
+)
+
+// Agreement holds the values filled into an agreement letter template.
+type Agreement struct {
+	Kind      AgreementKind
+	LoanID    int64
+	PartyName string
+	Amount    int64
+	Currency  Currency
+	Rate      decimal.Decimal
+	ROI       decimal.Decimal
+	Date      time.Time
+}
+
+// Document is a generated file kept in blob storage.
+type Document struct {
+	Key      string
+	Checksum string
+	Size     int
+}
+
+// MaxDocumentSize is the largest file accepted by document uploads.
+const MaxDocumentSize = 10 << 20
+
+type DocumentKind string
+
+const (
+	DocumentApprovalProof     DocumentKind = "approval_proof"
+	DocumentSignedAgreement   DocumentKind = "signed_agreement"
+	DocumentBorrowerAgreement DocumentKind = "borrower_agreement"
+	DocumentInvestorAgreement DocumentKind = "investor_agreement"
+)
+
+// Upload is a file uploaded to blob storage. Key is what approval and
+// disbursement refer to, URL is a download link that expires.
+type Upload struct {
+	Key         string `json:"key"`
+	URL         string `json:"url"`
+	ContentType string `json:"content_type"`
+	Size        int    `json:"size"`
+}
+
+// LoanDocument is a file attached to a loan. Its checksum is recorded when the
+// file is attached, so the stored file can later be proven unchanged.
+// Agreements stay pending until every party signed them.
+type LoanDocument struct {
+	ID           int64          `json:"id" db:"id"`
+	LoanID       int64          `json:"loan_id" db:"loan_id"`
+	InvestmentID sql.NullInt64  `json:"investment_id" db:"investment_id"`
+	Kind         DocumentKind   `json:"kind" db:"kind"`
+	Location     string         `json:"url" db:"location"`
+	Checksum     string         `json:"sha256" db:"checksum"`
+	ContentType  string         `json:"content_type" db:"content_type"`
+	Size         int            `json:"size" db:"size"`
+	Status       DocumentStatus `json:"status" db:"status"`
+	CreatedAt    time.Time      `json:"created_at" db:"created_at"`
+}
+
+const (
+	ErrDocumentEmpty           = LoanError("document is empty")
+	ErrDocumentTooLarge        = LoanError("document is too large")
+	ErrDocumentUnsupportedType = LoanError("document type is not supported")
+	ErrDocumentDuplicate       = LoanError("document is already attached")
+	ErrDocumentUnavailable     = LoanError("document can not be fetched")
+)
@@ -0,0 +1,40 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type LoanEventType string
+
+const (
+	LoanEventProposed    LoanEventType = "LoanProposed"
+	LoanEventApproved    LoanEventType = "LoanApproved"
+	LoanEventInvestment  LoanEventType = "InvestmentMade"
+	LoanEventFullyFunded LoanEventType = "LoanFullyFunded"
+	LoanEventDisbursed   LoanEventType = "LoanDisbursed"
+)
+
+// LoanEvent is a stored domain event of a loan. Payload holds the JSON encoded
+// event body whose shape depends on Type.
+type LoanEvent struct {
+	ID         int64           `json:"id" db:"id"`
+	LoanID     int64           `json:"loan_id" db:"loan_id"`
+	Type       LoanEventType   `json:"type" db:"type"`
+	Payload    json.RawMessage `json:"payload" db:"payload"`
+	OccurredAt time.Time       `json:"occurred_at" db:"occurred_at"`
+	CreatedAt  time.Time       `json:"created_at" db:"created_at"`
+}
+
+// LoanRebuild is the result of rebuilding the loans table from the loan
+// events. The loans in WithoutEvents have no event to rebuild them from, so
+// their rows were kept as they are.
+type LoanRebuild struct {
+	Rebuilt       int
+	WithoutEvents []int64
+}
+
+const (
+	ErrLoanEventUnknown = LoanError("unknown loan event")
+	ErrLoanEventInvalid = LoanError("loan event cannot be applied")
+)
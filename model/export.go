@@ -0,0 +1,84 @@
+package model
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+type ExportFormat string
+
+const (
+	ExportFormatCSV  ExportFormat = "csv"
+	ExportFormatXLSX ExportFormat = "xlsx"
+)
+
+var exportContentTypes = map[ExportFormat]string{
+	ExportFormatCSV:  "text/csv; charset=utf-8",
+	ExportFormatXLSX: "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+}
+
+// Valid reports whether the format is supported.
+func (f ExportFormat) Valid() bool {
+	_, ok := exportContentTypes[f]
+	return ok
+}
+
+// ContentType returns the media type of files of the format.
+func (f ExportFormat) ContentType() string {
+	return exportContentTypes[f]
+}
+
+type ExportKind string
+
+const (
+	ExportLoans       ExportKind = "loans"
+	ExportInvestments ExportKind = "investments"
+)
+
+// Table is a table to export. Rows calls write with the values of every row
+// in column order. Values are strings, integers, decimals, times, or nil for
+// empty cells.
+type Table struct {
+	Name    string
+	Columns []string
+	Rows    func(write func(values ...any) error) error
+}
+
+type ExportJobState string
+
+const (
+	ExportJobPending   ExportJobState = "pending"
+	ExportJobRunning   ExportJobState = "running"
+	ExportJobCompleted ExportJobState = "completed"
+	ExportJobFailed    ExportJobState = "failed"
+)
+
+// ExportJob exports a listing in the background, for ranges too large to be
+// downloaded in one request. Filter is the LoanFilter or InvestmentFilter of
+// the listing, already limited to what the requester can see. DownloadURL is
+// only set on completed jobs and expires. The error of failed jobs is kept
+// for operators and not shown to the requester.
+type ExportJob struct {
+	ID              int64           `json:"id" db:"id"`
+	Kind            ExportKind      `json:"kind" db:"kind"`
+	Format          ExportFormat    `json:"format" db:"format"`
+	Filter          json.RawMessage `json:"-" db:"filter"`
+	RequestedByRole Role            `json:"-" db:"requested_by_role"`
+	RequestedBy     int64           `json:"-" db:"requested_by"`
+	State           ExportJobState  `json:"state" db:"state"`
+	RowCount        int64           `json:"row_count" db:"row_count"`
+	FileKey         sql.NullString  `json:"-" db:"file_key"`
+	DownloadURL     string          `json:"download_url,omitempty" db:"-"`
+	LastError       sql.NullString  `json:"-" db:"last_error"`
+	CreatedAt       time.Time       `json:"created_at" db:"created_at"`
+	StartedAt       sql.NullTime    `json:"started_at" db:"started_at"`
+	CompletedAt     sql.NullTime    `json:"completed_at" db:"completed_at"`
+	LastUpdatedAt   time.Time       `json:"last_updated_at" db:"last_updated_at"`
+}
+
+const (
+	ErrExportFormatInvalid = LoanError("export format is invalid")
+	ErrExportJobNotFound   = LoanError("export job not found")
+	ErrExportAccessDenied  = LoanError("export can not be accessed")
+)
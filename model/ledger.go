@@ -0,0 +1,114 @@
+package model
+
+import (
+	"database/sql"
+	"time"
+)
+
+// AccountType is an account of the ledger. Investor accounts are owned by an
+// investor and loan accounts by a loan, while the settlement account, holding
+// the money at the bank, and the platform revenue account have no owner.
+type AccountType string
+
+const (
+	AccountSettlement       AccountType = "settlement"
+	AccountInvestorCash     AccountType = "investor_cash"
+	AccountInvestorReserved AccountType = "investor_reserved"
+	AccountInvestorDeployed AccountType = "investor_deployed"
+	AccountLoanReceivable   AccountType = "loan_receivable"
+	AccountBorrowerPayable  AccountType = "borrower_payable"
+	AccountInterestPayable  AccountType = "interest_payable"
+	AccountPlatformRevenue  AccountType = "platform_revenue"
+)
+
+// JournalKind is the money movement a journal entry records.
+type JournalKind string
+
+const (
+	JournalDeposit      JournalKind = "deposit"
+	JournalWithdrawal   JournalKind = "withdrawal"
+	JournalInvestment   JournalKind = "investment"
+	JournalFunding      JournalKind = "funding"
+	JournalDisbursement JournalKind = "disbursement"
+	JournalRepayment    JournalKind = "repayment"
+	JournalPayout       JournalKind = "payout"
+)
+
+// JournalEntry records a money movement as lines debiting and crediting
+// accounts of the ledger, all in the currency of the entry. The debits of an
+// entry always equal its credits.
+type JournalEntry struct {
+	ID           int64          `json:"id" db:"id"`
+	Kind         JournalKind    `json:"kind" db:"kind"`
+	Currency     Currency       `json:"currency" db:"currency"`
+	LoanID       sql.NullInt64  `json:"loan_id" db:"loan_id"`
+	InvestmentID sql.NullInt64  `json:"investment_id" db:"investment_id"`
+	Lines        []*JournalLine `json:"lines" db:"-"`
+	CreatedAt    time.Time      `json:"created_at" db:"created_at"`
+}
+
+type JournalLine struct {
+	ID             int64       `json:"id" db:"id"`
+	JournalEntryID int64       `json:"journal_entry_id" db:"journal_entry_id"`
+	Account        AccountType `json:"account" db:"account"`
+	OwnerID        int64       `json:"owner_id" db:"owner_id"`
+	Debit          int64       `json:"debit,string" db:"debit"`
+	Credit         int64       `json:"credit,string" db:"credit"`
+}
+
+// Debit adds a line debiting the account of the owner. Zero amounts are
+// skipped.
+func (e *JournalEntry) Debit(account AccountType, ownerID int64, amount int64) {
+	if amount != 0 {
+		e.Lines = append(e.Lines, &JournalLine{Account: account, OwnerID: ownerID, Debit: amount})
+	}
+}
+
+// Credit adds a line crediting the account of the owner. Zero amounts are
+// skipped.
+func (e *JournalEntry) Credit(account AccountType, ownerID int64, amount int64) {
+	if amount != 0 {
+		e.Lines = append(e.Lines, &JournalLine{Account: account, OwnerID: ownerID, Credit: amount})
+	}
+}
+
+// Balanced reports whether the entry has lines and its debits equal its
+// credits.
+func (e *JournalEntry) Balanced() bool {
+	var debit, credit int64
+	for _, line := range e.Lines {
+		debit += line.Debit
+		credit += line.Credit
+	}
+
+	return len(e.Lines) > 0 && debit == credit
+}
+
+// AccountBalance totals the lines of an account in one currency. Balance is
+// the debits minus the credits.
+type AccountBalance struct {
+	Account  AccountType `json:"account"`
+	OwnerID  int64       `json:"owner_id"`
+	Currency Currency    `json:"currency"`
+	Debit    int64       `json:"debit,string"`
+	Credit   int64       `json:"credit,string"`
+	Balance  int64       `json:"balance,string"`
+}
+
+// CurrencyTotal adds up the debits and credits of the ledger in one currency.
+type CurrencyTotal struct {
+	Currency Currency `json:"currency"`
+	Debit    int64    `json:"debit,string"`
+	Credit   int64    `json:"credit,string"`
+}
+
+// TrialBalance lists the balances of all accounts of the ledger. Amounts in
+// different currencies are never added up, so the ledger is balanced when the
+// debits equal the credits in every currency and every journal entry is
+// balanced on its own.
+type TrialBalance struct {
+	Accounts          []*AccountBalance `json:"accounts"`
+	Totals            []*CurrencyTotal  `json:"totals"`
+	Balanced          bool              `json:"balanced"`
+	UnbalancedEntries []int64           `json:"unbalanced_entries"`
+}
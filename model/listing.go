@@ -0,0 +1,120 @@
+package model
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultPageSize = 10
+	MaxPageSize     = 100
+)
+
+const (
+	SortByID              = "id"
+	SortByCreatedAt       = "created_at"
+	SortByPrincipalAmount = "principal_amount"
+	SortByAmount          = "amount"
+)
+
+// Sort orders a listing by a field, with the ID breaking ties.
+type Sort struct {
+	Field string
+	Desc  bool
+}
+
+// ParseSort parses a sort field, prefixed with "-" for descending order. An
+// empty value sorts by ID.
+func ParseSort(value string) Sort {
+	if value == "" {
+		return Sort{Field: SortByID}
+	}
+
+	if strings.HasPrefix(value, "-") {
+		return Sort{Field: value[1:], Desc: true}
+	}
+
+	return Sort{Field: value}
+}
+
+func (s Sort) String() string {
+	if s.Desc {
+		return "-" + s.Field
+	}
+
+	return s.Field
+}
+
+// Cursor points after the last item of a page. Value is the sort field of the
+// item formatted as text, and ID breaks ties between items with equal values.
+type Cursor struct {
+	Sort  string `json:"s"`
+	Value string `json:"v,omitempty"`
+	ID    int64  `json:"i"`
+}
+
+// Encode returns the cursor as an opaque token.
+func (c Cursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeCursor parses a token returned by Cursor.Encode.
+func DecodeCursor(token string) (*Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, ErrCursorInvalid
+	}
+
+	cursor := &Cursor{}
+	err = json.Unmarshal(data, cursor)
+	if err != nil {
+		return nil, ErrCursorInvalid
+	}
+
+	return cursor, nil
+}
+
+type LoanFilter struct {
+	State         *LoanState
+	BorrowerID    int64
+	LoanProductID int64
+	MinAmount     int64
+	MaxAmount     int64
+	CreatedFrom   time.Time
+	CreatedTo     time.Time
+	ApprovedFrom  time.Time
+	ApprovedTo    time.Time
+
+	Sort   Sort
+	Cursor *Cursor
+	Limit  int
+}
+
+type InvestmentFilter struct {
+	InvestorID  int64
+	LoanID      int64
+	MinAmount   int64
+	MaxAmount   int64
+	CreatedFrom time.Time
+	CreatedTo   time.Time
+
+	Sort   Sort
+	Cursor *Cursor
+	Limit  int
+}
+
+// Page is a page of a listing. NextCursor is empty on the last page, and Total
+// counts the items matching the filters over all pages.
+type Page[T any] struct {
+	Data       []T    `json:"data"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	Total      int    `json:"total"`
+}
+
+var (
+	ErrCursorInvalid    = LoanError("cursor is invalid")
+	ErrSortFieldInvalid = LoanError("sort field is invalid")
+)
@@ -0,0 +1,54 @@
+package model
+
+type Role string
+
+const (
+	RoleBorrower Role = "borrower"
+	RoleEmployee Role = "employee"
+	RoleInvestor Role = "investor"
+)
+
+// Actor is the caller of the API as identified by the authentication service.
+type Actor struct {
+	Role Role
+	ID   int64
+}
+
+type LoanExpansion string
+
+const (
+	ExpandInvestments LoanExpansion = "investments"
+	ExpandProduct     LoanExpansion = "product"
+	ExpandBorrower    LoanExpansion = "borrower"
+	ExpandApprover    LoanExpansion = "approver"
+	ExpandDisburser   LoanExpansion = "disburser"
+)
+
+// Party is a person involved in a loan, without their contact details.
+type Party struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+}
+
+type LoanInvestment struct {
+	Investment
+	InvestorName string `json:"investor_name"`
+}
+
+// LoanDetail is a loan with its funding progress. Relations are only set when
+// requested with the matching LoanExpansion.
+type LoanDetail struct {
+	Loan
+	FundedAmount    int64             `json:"funded_amount,string"`
+	RemainingAmount int64             `json:"remaining_amount,string"`
+	Product         *LoanProduct      `json:"product,omitempty"`
+	Borrower        *Party            `json:"borrower,omitempty"`
+	Approver        *Party            `json:"approver,omitempty"`
+	Disburser       *Party            `json:"disburser,omitempty"`
+	Investments     []*LoanInvestment `json:"investments,omitempty"`
+}
+
+const (
+	ErrLoanAccessDenied     = LoanError("loan can not be accessed")
+	ErrLoanExpansionInvalid = LoanError("loan expansion is invalid")
+)
@@ -0,0 +1,38 @@
+package model
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+const (
+	SortByROI           = "roi"
+	SortByFundedPercent = "funded_percent"
+)
+
+// MarketplaceLoan is an approved loan investors can still invest in, with its
+// funding progress.
+type MarketplaceLoan struct {
+	ID              int64           `json:"id"`
+	BorrowerID      int64           `json:"borrower_id"`
+	LoanProductID   sql.NullInt64   `json:"loan_product_id"`
+	PrincipalAmount int64           `json:"principal_amount,string"`
+	FundedAmount    int64           `json:"funded_amount,string"`
+	RemainingAmount int64           `json:"remaining_amount,string"`
+	Currency        Currency        `json:"currency"`
+	FundedPercent   decimal.Decimal `json:"funded_percent"`
+	Rate            decimal.Decimal `json:"rate"`
+	ROI             decimal.Decimal `json:"roi"`
+	InvestorCount   int             `json:"investor_count"`
+	ApprovedAt      time.Time       `json:"approved_at"`
+	ExpiresAt       time.Time       `json:"expires_at"`
+	TimeLeft        int64           `json:"time_left_seconds"`
+}
+
+type MarketplaceFilter struct {
+	Sort   Sort
+	Cursor *Cursor
+	Limit  int
+}
@@ -8,10 +8,12 @@ import (
 )
 
 type User struct {
-	ID            int64     `json:"id" db:"id"`
-	Name          string    `json:"name" db:"name"`
-	CreatedAt     time.Time `json:"created_at" db:"created_at"`
-	LastUpdatedAt time.Time `json:"last_updated_at" db:"last_updated_at"`
+	ID            int64          `json:"id" db:"id"`
+	Name          string         `json:"name" db:"name"`
+	Email         sql.NullString `json:"email" db:"email"`
+	Locale        string         `json:"locale" db:"locale"`
+	CreatedAt     time.Time      `json:"created_at" db:"created_at"`
+	LastUpdatedAt time.Time      `json:"last_updated_at" db:"last_updated_at"`
 }
 
 type Employee struct {
@@ -22,10 +24,13 @@ type Employee struct {
 }
 
 type Investor struct {
-	ID            int64     `json:"id" db:"id"`
-	Name          string    `json:"name" db:"name"`
-	CreatedAt     time.Time `json:"created_at" db:"created_at"`
-	LastUpdatedAt time.Time `json:"last_updated_at" db:"last_updated_at"`
+	ID            int64          `json:"id" db:"id"`
+	Name          string         `json:"name" db:"name"`
+	Email         sql.NullString `json:"email" db:"email"`
+	Locale        string         `json:"locale" db:"locale"`
+	Currency      Currency       `json:"currency" db:"currency"`
+	CreatedAt     time.Time      `json:"created_at" db:"created_at"`
+	LastUpdatedAt time.Time      `json:"last_updated_at" db:"last_updated_at"`
 }
 
 type LoanState int16
@@ -38,31 +43,44 @@ const (
 )
 
 type Loan struct {
-	ID              int64           `json:"id" db:"id"`
-	State           LoanState       `json:"state" db:"state"`
-	BorrowerID      int64           `json:"borrower_id" db:"borrower_id"`
-	PrincipalAmount int             `json:"principal_amount" db:"principal_amount"`
-	Rate            decimal.Decimal `json:"rate" db:"rate"`
-	ROI             decimal.Decimal `json:"roi" db:"roi"`
-	ApprovalProof   sql.NullString  `json:"approval_proof" db:"approval_proof"`
-	ApprovedBy      sql.NullInt64   `json:"approved_by" db:"approved_by"`
-	AgreementLetter sql.NullString  `json:"agreement_letter" db:"agreement_letter"`
-	DisbursedBy     sql.NullInt64   `json:"disbursed_by" db:"disbursed_by"`
-	CreatedAt       time.Time       `json:"created_at" db:"created_at"`
-	ApprovedAt      sql.NullTime    `json:"approved_at" db:"approved_at"`
-	InvestedAt      sql.NullTime    `json:"invested_at" db:"invested_at"`
-	DisbursedAt     sql.NullTime    `json:"disbursed_at" db:"disbursed_at"`
-	LastUpdatedAt   time.Time       `json:"last_updated_at" db:"last_updated_at"`
+	ID                        int64           `json:"id" db:"id"`
+	State                     LoanState       `json:"state" db:"state"`
+	BorrowerID                int64           `json:"borrower_id" db:"borrower_id"`
+	LoanProductID             sql.NullInt64   `json:"loan_product_id" db:"loan_product_id"`
+	PrincipalAmount           int64           `json:"principal_amount,string" db:"principal_amount"`
+	Currency                  Currency        `json:"currency" db:"currency"`
+	Rate                      decimal.Decimal `json:"rate" db:"rate"`
+	ROI                       decimal.Decimal `json:"roi" db:"roi"`
+	ApprovalProof             sql.NullString  `json:"approval_proof" db:"approval_proof"`
+	ApprovedBy                sql.NullInt64   `json:"approved_by" db:"approved_by"`
+	AgreementLetter           sql.NullString  `json:"agreement_letter" db:"agreement_letter"`
+	DisbursedBy               sql.NullInt64   `json:"disbursed_by" db:"disbursed_by"`
+	BorrowerAgreement         sql.NullString  `json:"borrower_agreement" db:"borrower_agreement"`
+	BorrowerAgreementChecksum sql.NullString  `json:"borrower_agreement_checksum" db:"borrower_agreement_checksum"`
+	CreatedAt                 time.Time       `json:"created_at" db:"created_at"`
+	ApprovedAt                sql.NullTime    `json:"approved_at" db:"approved_at"`
+	InvestedAt                sql.NullTime    `json:"invested_at" db:"invested_at"`
+	DisbursedAt               sql.NullTime    `json:"disbursed_at" db:"disbursed_at"`
+	LastUpdatedAt             time.Time       `json:"last_updated_at" db:"last_updated_at"`
 }
 
+// Investment funds a loan with an amount in the currency of the loan. The
+// investor pays the settlement amount in the currency of their wallet,
+// converted at the FX rate of the time of the investment, which is the number
+// of units of the settlement currency per unit of the loan currency.
 type Investment struct {
-	ID              int64     `json:"id" db:"id"`
-	Amount          int       `json:"amount" db:"amount"`
-	InvestorID      int64     `json:"investor_id" db:"investor_id"`
-	LoanID          int64     `json:"loan_id" db:"loan_id"`
-	AgreementLetter string    `json:"agreement_letter" db:"agreement_letter"`
-	CreatedAt       time.Time `json:"created_at" db:"created_at"`
-	LastUpdatedAt   time.Time `json:"last_updated_at" db:"last_updated_at"`
+	ID                 int64           `json:"id" db:"id"`
+	Amount             int64           `json:"amount,string" db:"amount"`
+	Currency           Currency        `json:"currency" db:"currency"`
+	SettlementAmount   int64           `json:"settlement_amount,string" db:"settlement_amount"`
+	SettlementCurrency Currency        `json:"settlement_currency" db:"settlement_currency"`
+	FXRate             decimal.Decimal `json:"fx_rate" db:"fx_rate"`
+	InvestorID         int64           `json:"investor_id" db:"investor_id"`
+	LoanID             int64           `json:"loan_id" db:"loan_id"`
+	AgreementLetter    string          `json:"agreement_letter" db:"agreement_letter"`
+	AgreementChecksum  string          `json:"agreement_checksum" db:"agreement_checksum"`
+	CreatedAt          time.Time       `json:"created_at" db:"created_at"`
+	LastUpdatedAt      time.Time       `json:"last_updated_at" db:"last_updated_at"`
 }
 
 type LoanProduct struct {
@@ -70,6 +88,7 @@ type LoanProduct struct {
 	Name          string          `json:"name" db:"name"`
 	Rate          decimal.Decimal `json:"rate" db:"rate"`
 	ROI           decimal.Decimal `json:"roi" db:"roi"`
+	Currency      Currency        `json:"currency" db:"currency"`
 	CreatedAt     time.Time       `json:"created_at" db:"created_at"`
 	LastUpdatedAt time.Time       `json:"last_updated_at" db:"last_updated_at"`
 }
@@ -77,6 +96,7 @@ type LoanProduct struct {
 const (
 	ErrLoanNotProposed         = LoanError("loan not proposed")
 	ErrLoanNotApproved         = LoanError("loan not approved")
+	ErrLoanFundingEnded        = LoanError("loan funding period has ended")
 	ErrLoanNotInvested         = LoanError("loan not invested")
 	ErrLoanNotFound            = LoanError("loan not found")
 	ErrLoanProductNotFound     = LoanError("loan product not found")
@@ -0,0 +1,91 @@
+package model
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/shopspring/decimal"
+)
+
+// Currency is an ISO 4217 currency code. Amounts are stored as int64 counts of
+// the minor unit of their currency, e.g. sen for IDR and cents for USD.
+type Currency string
+
+const DefaultCurrency Currency = "IDR"
+
+// currencyExponents holds the number of minor unit digits of the supported
+// currencies.
+var currencyExponents = map[Currency]int{
+	"AUD": 2,
+	"EUR": 2,
+	"IDR": 2,
+	"JPY": 0,
+	"MYR": 2,
+	"PHP": 2,
+	"SGD": 2,
+	"THB": 2,
+	"USD": 2,
+	"VND": 0,
+}
+
+// Valid reports whether the currency is supported.
+func (c Currency) Valid() bool {
+	_, ok := currencyExponents[c]
+	return ok
+}
+
+// Exponent returns the number of minor unit digits of the currency.
+func (c Currency) Exponent() int {
+	return currencyExponents[c]
+}
+
+// Format writes the amount in minor units as a decimal with thousands
+// separators, prefixed with the currency code, e.g. "IDR 1,000,000.00".
+func (c Currency) Format(amount int64) string {
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+
+	digits := strconv.FormatInt(amount, 10)
+	exponent := c.Exponent()
+	if len(digits) <= exponent {
+		digits = strings.Repeat("0", exponent-len(digits)+1) + digits
+	}
+	whole, fraction := digits[:len(digits)-exponent], digits[len(digits)-exponent:]
+
+	grouped := &strings.Builder{}
+	for i, digit := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			grouped.WriteByte(',')
+		}
+		grouped.WriteRune(digit)
+	}
+	if fraction != "" {
+		grouped.WriteString("." + fraction)
+	}
+
+	return string(c) + " " + sign + grouped.String()
+}
+
+// Convert converts the amount in minor units of the currency to minor units of
+// the other currency, at rate units of the other currency per unit of this
+// one. The result is rounded half away from zero.
+func (c Currency) Convert(amount int64, rate decimal.Decimal, to Currency) int64 {
+	if c == to {
+		return amount
+	}
+
+	return decimal.NewFromInt(amount).
+		Mul(rate).
+		Shift(int32(to.Exponent() - c.Exponent())).
+		Round(0).
+		IntPart()
+}
+
+var (
+	ErrCurrencyInvalid   = LoanError("currency is invalid")
+	ErrCurrencyMismatch  = LoanError("currency does not match")
+	ErrFXRateUnavailable = LoanError("exchange rate is not available")
+)
@@ -0,0 +1,25 @@
+package model
+
+type NotificationEvent string
+
+const (
+	NotificationBorrowerLoanInvested  NotificationEvent = "borrower_loan_invested"
+	NotificationInvestorLoanInvested  NotificationEvent = "investor_loan_invested"
+	NotificationBorrowerLoanDisbursed NotificationEvent = "borrower_loan_disbursed"
+	NotificationInvestorLoanDisbursed NotificationEvent = "investor_loan_disbursed"
+	NotificationSignatureRequested    NotificationEvent = "agreement_signature_requested"
+)
+
+// Notification is a message about a loan sent to a borrower or an investor.
+// InvestedAmount is only set for investors and holds the total they invested
+// in the loan. SigningURL is only set when the recipient is asked to sign an
+// agreement.
+type Notification struct {
+	Event          NotificationEvent
+	Locale         string
+	Name           string
+	Email          string
+	Loan           Loan
+	InvestedAmount int64
+	SigningURL     string
+}
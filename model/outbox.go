@@ -0,0 +1,22 @@
+package model
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+// OutboxMessage is a domain event waiting to be published to downstream
+// services. It is written in the same transaction as the change that raised
+// the event and is delivered at least once by the outbox relay.
+type OutboxMessage struct {
+	ID            int64           `json:"id" db:"id"`
+	EventType     LoanEventType   `json:"event_type" db:"event_type"`
+	LoanID        int64           `json:"loan_id" db:"loan_id"`
+	Payload       json.RawMessage `json:"payload" db:"payload"`
+	Attempts      int             `json:"attempts" db:"attempts"`
+	NextAttemptAt time.Time       `json:"next_attempt_at" db:"next_attempt_at"`
+	LastError     sql.NullString  `json:"last_error" db:"last_error"`
+	PublishedAt   sql.NullTime    `json:"published_at" db:"published_at"`
+	CreatedAt     time.Time       `json:"created_at" db:"created_at"`
+}
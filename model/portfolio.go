@@ -0,0 +1,54 @@
+package model
+
+import (
+	"database/sql"
+
+	"github.com/shopspring/decimal"
+)
+
+// Holding is an investment together with the loan attributes the portfolio of
+// its investor is aggregated by.
+type Holding struct {
+	InvestmentID  int64
+	LoanID        int64
+	Amount        int64
+	Currency      Currency
+	LoanState     LoanState
+	BorrowerID    int64
+	LoanProductID sql.NullInt64
+	ROI           decimal.Decimal
+}
+
+// Portfolio summarises the investments of an investor. Committed capital is
+// invested in loans that are not disbursed yet, and deployed capital in
+// disbursed loans. Expected return applies the ROI of every loan to the amount
+// invested in it. Amounts are in minor units of the currency.
+type Portfolio struct {
+	InvestorID         int64                     `json:"investor_id"`
+	Currency           Currency                  `json:"currency"`
+	InvestmentCount    int                       `json:"investment_count"`
+	TotalInvested      decimal.Decimal           `json:"total_invested"`
+	CommittedCapital   decimal.Decimal           `json:"committed_capital"`
+	DeployedCapital    decimal.Decimal           `json:"deployed_capital"`
+	ExpectedReturn     decimal.Decimal           `json:"expected_return"`
+	RealisedPayouts    decimal.Decimal           `json:"realised_payouts"`
+	WeightedAverageROI decimal.Decimal           `json:"weighted_average_roi"`
+	ByState            []*PortfolioStateTotal    `json:"by_state"`
+	ByBorrower         []*PortfolioConcentration `json:"by_borrower"`
+	ByProduct          []*PortfolioConcentration `json:"by_product"`
+}
+
+type PortfolioStateTotal struct {
+	State           LoanState       `json:"state"`
+	InvestmentCount int             `json:"investment_count"`
+	Amount          decimal.Decimal `json:"amount"`
+}
+
+// PortfolioConcentration is the amount invested with a borrower or in a loan
+// product, and its share of the portfolio in percent. Loans without a loan
+// product are grouped under ID 0.
+type PortfolioConcentration struct {
+	ID     int64           `json:"id"`
+	Amount decimal.Decimal `json:"amount"`
+	Share  decimal.Decimal `json:"share"`
+}
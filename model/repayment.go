@@ -0,0 +1,27 @@
+package model
+
+import "time"
+
+// Repayment is money paid back by the borrower of a disbursed loan, in the
+// currency of the loan. The borrower owes the principal and the interest at
+// the rate of the loan, and every repayment pays both in the proportion they
+// make up of the amount due. The investors earn the interest at the ROI of the
+// loan, and the platform keeps the rest of the interest as its fee.
+type Repayment struct {
+	ID           int64     `json:"id" db:"id"`
+	LoanID       int64     `json:"loan_id" db:"loan_id"`
+	Amount       int64     `json:"amount,string" db:"amount"`
+	Principal    int64     `json:"principal,string" db:"principal"`
+	Interest     int64     `json:"interest,string" db:"interest"`
+	Fee          int64     `json:"fee,string" db:"fee"`
+	Currency     Currency  `json:"currency" db:"currency"`
+	RecordedBy   int64     `json:"recorded_by" db:"recorded_by"`
+	RemainingDue int64     `json:"remaining_due,string" db:"-"`
+	CreatedAt    time.Time `json:"created_at" db:"created_at"`
+}
+
+const (
+	ErrLoanNotDisbursed       = LoanError("loan not disbursed")
+	ErrRepaymentInvalidAmount = LoanError("repayment amount is invalid")
+	ErrRepaymentExceedsDue    = LoanError("repayment exceeds the amount due")
+)
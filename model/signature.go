@@ -0,0 +1,49 @@
+package model
+
+import (
+	"database/sql"
+	"time"
+)
+
+type DocumentStatus string
+
+const (
+	// DocumentAttached is the status of uploaded documents, which need no
+	// signature.
+	DocumentAttached         DocumentStatus = "attached"
+	DocumentPendingSignature DocumentStatus = "pending_signature"
+	DocumentSigned           DocumentStatus = "signed"
+)
+
+type SignerRole string
+
+const (
+	SignerBorrower SignerRole = "borrower"
+	SignerInvestor SignerRole = "investor"
+)
+
+// Signature is the request for a party to sign an agreement and, once signed,
+// the record of it. The party proves it got the request with the signing
+// token, of which only the hash is stored. Value is the detached signature of
+// the service over the agreement checksum, the signer and the signing time.
+type Signature struct {
+	ID             int64          `json:"id" db:"id"`
+	LoanDocumentID int64          `json:"loan_document_id" db:"loan_document_id"`
+	LoanID         int64          `json:"loan_id" db:"loan_id"`
+	SignerRole     SignerRole     `json:"signer_role" db:"signer_role"`
+	SignerID       int64          `json:"signer_id" db:"signer_id"`
+	Checksum       string         `json:"sha256" db:"checksum"`
+	TokenHash      string         `json:"-" db:"token_hash"`
+	SignedAt       sql.NullTime   `json:"signed_at" db:"signed_at"`
+	IPAddress      sql.NullString `json:"ip_address" db:"ip_address"`
+	Value          sql.NullString `json:"signature" db:"signature"`
+	CreatedAt      time.Time      `json:"created_at" db:"created_at"`
+}
+
+const (
+	ErrSignatureNotFound       = LoanError("signature request not found")
+	ErrSignatureSignerMismatch = LoanError("signer is not a party of the agreement")
+	ErrAgreementAlreadySigned  = LoanError("agreement is already signed")
+	ErrAgreementsNotSigned     = LoanError("loan agreements are not fully signed")
+	ErrDocumentChecksumChanged = LoanError("document does not match its recorded checksum")
+)
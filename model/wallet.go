@@ -0,0 +1,68 @@
+package model
+
+import (
+	"database/sql"
+	"time"
+)
+
+// WalletEntryType is a movement of investor funds. Deposits and payouts add
+// to the available balance and withdrawals take from it. Investing reserves
+// available funds, and once the loan is fully funded its reservations are
+// released to the borrower, becoming deployed capital. As the borrower repays
+// the loan, repayments move the principal back from deployed capital to the
+// available balance, and payouts add the interest earned.
+type WalletEntryType string
+
+const (
+	WalletDeposit     WalletEntryType = "deposit"
+	WalletWithdrawal  WalletEntryType = "withdrawal"
+	WalletReservation WalletEntryType = "reservation"
+	WalletRelease     WalletEntryType = "release"
+	WalletPayout      WalletEntryType = "payout"
+	WalletRepayment   WalletEntryType = "repayment"
+)
+
+// WalletEntryStatus tracks a deposit or withdrawal through the payment rail.
+// It is pending until the rail answers, then settled when the money moved or
+// reversed when it did not. Entries not moving money through the rail are
+// settled as soon as they are made.
+type WalletEntryStatus string
+
+const (
+	WalletEntryPending  WalletEntryStatus = "pending"
+	WalletEntrySettled  WalletEntryStatus = "settled"
+	WalletEntryReversed WalletEntryStatus = "reversed"
+)
+
+type Wallet struct {
+	InvestorID    int64          `json:"investor_id" db:"investor_id"`
+	Currency      Currency       `json:"currency" db:"currency"`
+	Available     int64          `json:"available,string" db:"available"`
+	Reserved      int64          `json:"reserved,string" db:"reserved"`
+	Deployed      int64          `json:"deployed,string" db:"deployed"`
+	LastUpdatedAt time.Time      `json:"last_updated_at" db:"last_updated_at"`
+	Entries       []*WalletEntry `json:"entries,omitempty" db:"-"`
+}
+
+// WalletEntry is a movement of the funds of an investor. The idempotency key
+// of a deposit or withdrawal is sent to the payment rail, so asking the rail
+// again for the same entry never moves the money twice.
+type WalletEntry struct {
+	ID             int64             `json:"id" db:"id"`
+	InvestorID     int64             `json:"investor_id" db:"investor_id"`
+	Type           WalletEntryType   `json:"type" db:"type"`
+	Amount         int64             `json:"amount,string" db:"amount"`
+	Currency       Currency          `json:"currency" db:"currency"`
+	Status         WalletEntryStatus `json:"status" db:"status"`
+	LoanID         sql.NullInt64     `json:"loan_id" db:"loan_id"`
+	InvestmentID   sql.NullInt64     `json:"investment_id" db:"investment_id"`
+	Reference      sql.NullString    `json:"reference" db:"reference"`
+	IdempotencyKey sql.NullString    `json:"-" db:"idempotency_key"`
+	CreatedAt      time.Time         `json:"created_at" db:"created_at"`
+}
+
+var (
+	ErrWalletInvalidAmount = LoanError("wallet amount is invalid")
+	ErrInsufficientFunds   = LoanError("insufficient wallet balance")
+	ErrPaymentDeclined     = LoanError("payment was declined")
+)
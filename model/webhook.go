@@ -0,0 +1,52 @@
+package model
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+// Webhook is a partner subscription to loan events. Secret is used to sign
+// every delivery and is never serialized.
+type Webhook struct {
+	ID            int64           `json:"id" db:"id"`
+	URL           string          `json:"url" db:"url"`
+	EventTypes    []LoanEventType `json:"event_types" db:"event_types"`
+	Secret        string          `json:"-" db:"secret"`
+	CreatedAt     time.Time       `json:"created_at" db:"created_at"`
+	LastUpdatedAt time.Time       `json:"last_updated_at" db:"last_updated_at"`
+}
+
+type WebhookDeliveryStatus string
+
+const (
+	WebhookDeliveryPending   WebhookDeliveryStatus = "pending"
+	WebhookDeliveryDelivered WebhookDeliveryStatus = "delivered"
+	WebhookDeliveryDead      WebhookDeliveryStatus = "dead"
+)
+
+// WebhookDelivery is one outbox message to be sent to one webhook. Payload is
+// the exact request body, so every attempt sends the same bytes.
+type WebhookDelivery struct {
+	ID                 int64                 `json:"id" db:"id"`
+	WebhookID          int64                 `json:"webhook_id" db:"webhook_id"`
+	OutboxMessageID    int64                 `json:"outbox_message_id" db:"outbox_message_id"`
+	EventType          LoanEventType         `json:"event_type" db:"event_type"`
+	Payload            json.RawMessage       `json:"payload" db:"payload"`
+	Status             WebhookDeliveryStatus `json:"status" db:"status"`
+	Attempts           int                   `json:"attempts" db:"attempts"`
+	NextAttemptAt      time.Time             `json:"next_attempt_at" db:"next_attempt_at"`
+	LastResponseStatus sql.NullInt64         `json:"last_response_status" db:"last_response_status"`
+	LastError          sql.NullString        `json:"last_error" db:"last_error"`
+	DeliveredAt        sql.NullTime          `json:"delivered_at" db:"delivered_at"`
+	CreatedAt          time.Time             `json:"created_at" db:"created_at"`
+	LastUpdatedAt      time.Time             `json:"last_updated_at" db:"last_updated_at"`
+}
+
+const (
+	ErrWebhookNotFound         = LoanError("webhook not found")
+	ErrWebhookInvalidURL       = LoanError("webhook url is invalid")
+	ErrWebhookInvalidEventType = LoanError("webhook event type is invalid")
+	ErrWebhookInvalidSecret    = LoanError("webhook secret must be at least 16 characters")
+	ErrWebhookAccessDenied     = LoanError("webhooks can only be managed by employees")
+)
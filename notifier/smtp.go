@@ -0,0 +1,55 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"time"
+
+	"github.com/aldipi/loan-service/model"
+)
+
+// SMTPNotifier sends notifications as plain text emails through an SMTP
+// server.
+type SMTPNotifier struct {
+	addr string
+	from string
+	auth smtp.Auth
+}
+
+// NewSMTPNotifier returns a notifier sending through the server at addr
+// (host:port). PLAIN authentication is used when username is not empty.
+func NewSMTPNotifier(addr string, from string, username string, password string) *SMTPNotifier {
+	var auth smtp.Auth
+	if username != "" {
+		host, _, _ := net.SplitHostPort(addr)
+		auth = smtp.PlainAuth("", username, password, host)
+	}
+
+	return &SMTPNotifier{addr: addr, from: from, auth: auth}
+}
+
+func (n *SMTPNotifier) Notify(ctx context.Context, notification *model.Notification) error {
+	message, err := Render(notification)
+	if err != nil {
+		return err
+	}
+
+	return smtp.SendMail(n.addr, n.auth, n.from, []string{message.To}, n.format(message))
+}
+
+func (n *SMTPNotifier) format(message *Message) []byte {
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "From: %s\r\n", n.from)
+	fmt.Fprintf(buf, "To: %s\r\n", message.To)
+	fmt.Fprintf(buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", message.Subject))
+	fmt.Fprintf(buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	buf.WriteString("\r\n")
+	buf.Write(bytes.ReplaceAll([]byte(message.Body), []byte("\n"), []byte("\r\n")))
+	return buf.Bytes()
+}
@@ -0,0 +1,103 @@
+package notifier
+
+import (
+	"bufio"
+	"context"
+	"mime"
+	"net"
+	"net/textproto"
+	"strings"
+	"testing"
+
+	"github.com/aldipi/loan-service/model"
+	"github.com/stretchr/testify/assert"
+)
+
+type smtpMail struct {
+	from string
+	to   []string
+	data string
+}
+
+// fakeSMTPServer accepts a single SMTP session without extensions and sends
+// the received mail on the returned channel.
+func fakeSMTPServer(t *testing.T) (string, <-chan smtpMail) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	mails := make(chan smtpMail, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		tp := textproto.NewConn(conn)
+		mail := smtpMail{}
+		tp.PrintfLine("220 localhost ESMTP fake")
+		for {
+			line, err := tp.ReadLine()
+			if err != nil {
+				return
+			}
+
+			command := strings.ToUpper(line)
+			switch {
+			case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+				tp.PrintfLine("250 localhost")
+			case strings.HasPrefix(command, "MAIL FROM:"):
+				mail.from = strings.Trim(line[len("MAIL FROM:"):], "<>")
+				tp.PrintfLine("250 OK")
+			case strings.HasPrefix(command, "RCPT TO:"):
+				mail.to = append(mail.to, strings.Trim(line[len("RCPT TO:"):], "<>"))
+				tp.PrintfLine("250 OK")
+			case command == "DATA":
+				tp.PrintfLine("354 End data with <CR><LF>.<CR><LF>")
+				data, err := tp.ReadDotBytes()
+				if err != nil {
+					return
+				}
+				mail.data = string(data)
+				tp.PrintfLine("250 OK")
+				mails <- mail
+			case command == "QUIT":
+				tp.PrintfLine("221 Bye")
+				return
+			default:
+				tp.PrintfLine("502 Command not implemented")
+			}
+		}
+	}()
+
+	return listener.Addr().String(), mails
+}
+
+func TestSMTPNotifier(t *testing.T) {
+	addr, mails := fakeSMTPServer(t)
+	n := NewSMTPNotifier(addr, "loans@example.com", "", "")
+
+	err := n.Notify(context.Background(), &model.Notification{
+		Event:  model.NotificationBorrowerLoanInvested,
+		Locale: "id",
+		Name:   "Putra Raja",
+		Email:  "putra.raja@example.com",
+		Loan:   model.Loan{ID: 7, PrincipalAmount: 1000000},
+	})
+	assert.NoError(t, err)
+
+	mail := <-mails
+	assert.Equal(t, "loans@example.com", mail.from)
+	assert.Equal(t, []string{"putra.raja@example.com"}, mail.to)
+
+	msg, err := textproto.NewReader(bufio.NewReader(strings.NewReader(mail.data))).ReadMIMEHeader()
+	assert.NoError(t, err)
+	assert.Equal(t, "putra.raja@example.com", msg.Get("To"))
+	assert.Equal(t, "text/plain; charset=utf-8", msg.Get("Content-Type"))
+	assert.Contains(t, mail.data, "Halo Putra Raja,")
+
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Get("Subject"))
+	assert.NoError(t, err)
+	assert.Equal(t, "Pinjaman #7 Anda telah terdanai penuh", subject)
+}
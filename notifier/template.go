@@ -0,0 +1,62 @@
+package notifier
+
+import (
+	"bytes"
+	"embed"
+	"strings"
+	"text/template"
+
+	"github.com/aldipi/loan-service/model"
+)
+
+const defaultLocale = "en"
+
+//go:embed templates
+var templateFS embed.FS
+
+// Message is a rendered notification.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Render fills the template of the notification event in the recipient
+// locale. Locales without a template fall back to English.
+func Render(n *model.Notification) (*Message, error) {
+	tmpl, err := loadTemplate(n.Locale, n.Event)
+	if err != nil {
+		return nil, err
+	}
+
+	subject := &bytes.Buffer{}
+	err = tmpl.ExecuteTemplate(subject, "subject", n)
+	if err != nil {
+		return nil, err
+	}
+
+	body := &bytes.Buffer{}
+	err = tmpl.ExecuteTemplate(body, "body", n)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Message{
+		To:      n.Email,
+		Subject: strings.TrimSpace(subject.String()),
+		Body:    strings.TrimSpace(body.String()) + "\n",
+	}, nil
+}
+
+func loadTemplate(locale string, event model.NotificationEvent) (*template.Template, error) {
+	if locale == "" {
+		locale = defaultLocale
+	}
+
+	tmpl, err := template.ParseFS(templateFS, "templates/"+locale+"/"+string(event)+".tmpl")
+	if err != nil && locale != defaultLocale {
+		return loadTemplate(defaultLocale, event)
+	}
+
+	return tmpl, err
+}
@@ -0,0 +1,73 @@
+package notifier
+
+import (
+	"testing"
+
+	"github.com/aldipi/loan-service/model"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRender(t *testing.T) {
+	message, err := Render(&model.Notification{
+		Event:          model.NotificationInvestorLoanInvested,
+		Locale:         "en",
+		Name:           "Investor A",
+		Email:          "investor.a@example.com",
+		Loan:           model.Loan{ID: 7, PrincipalAmount: 100000000, Currency: "IDR", ROI: decimal.NewFromInt(10)},
+		InvestedAmount: 25000000,
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "investor.a@example.com", message.To)
+	assert.Equal(t, "Loan #7 you invested in is fully funded", message.Subject)
+	assert.Contains(t, message.Body, "Hi Investor A,")
+	assert.Contains(t, message.Body, "principal amount of IDR 1,000,000.00")
+	assert.Contains(t, message.Body, "Your investment: IDR 250,000.00")
+	assert.Contains(t, message.Body, "Expected return: 10%")
+}
+
+func TestRenderLocale(t *testing.T) {
+	message, err := Render(&model.Notification{
+		Event:  model.NotificationBorrowerLoanDisbursed,
+		Locale: "id",
+		Name:   "Putra Raja",
+		Loan:   model.Loan{ID: 7, PrincipalAmount: 150000, Currency: "JPY"},
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "Pinjaman #7 Anda telah dicairkan", message.Subject)
+	assert.Contains(t, message.Body, "sebesar JPY 150,000 telah dicairkan")
+	assert.Contains(t, message.Body, "Halo Putra Raja,")
+}
+
+func TestRenderUnknownLocaleFallsBackToEnglish(t *testing.T) {
+	message, err := Render(&model.Notification{
+		Event:  model.NotificationBorrowerLoanDisbursed,
+		Locale: "fr",
+		Loan:   model.Loan{ID: 7},
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "Your loan #7 has been disbursed", message.Subject)
+}
+
+func TestRenderUnknownEvent(t *testing.T) {
+	_, err := Render(&model.Notification{Event: "loan_renamed", Locale: "en"})
+
+	assert.Error(t, err)
+}
+
+func TestRenderSignatureRequested(t *testing.T) {
+	message, err := Render(&model.Notification{
+		Event:      model.NotificationSignatureRequested,
+		Locale:     "en",
+		Name:       "Investor A",
+		Loan:       model.Loan{ID: 7},
+		SigningURL: "http://localhost:8080/signatures/abc",
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "Please sign your agreement for loan #7", message.Subject)
+	assert.Contains(t, message.Body, "http://localhost:8080/signatures/abc")
+}
@@ -0,0 +1,35 @@
+package notifier
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/aldipi/loan-service/model"
+)
+
+// WriterNotifier writes rendered notifications to a writer such as stdout or a
+// file instead of sending them. It is meant for local development.
+type WriterNotifier struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func NewWriterNotifier(w io.Writer) *WriterNotifier {
+	return &WriterNotifier{w: w}
+}
+
+func (n *WriterNotifier) Notify(ctx context.Context, notification *model.Notification) error {
+	message, err := Render(notification)
+	if err != nil {
+		return err
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	_, err = fmt.Fprintf(n.w, "To: %s\nSubject: %s\n\n%s---\n", message.To, message.Subject, message.Body)
+
+	return err
+}
@@ -0,0 +1,26 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/aldipi/loan-service/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriterNotifier(t *testing.T) {
+	buf := &bytes.Buffer{}
+	n := NewWriterNotifier(buf)
+
+	err := n.Notify(context.Background(), &model.Notification{
+		Event: model.NotificationBorrowerLoanInvested,
+		Name:  "John Doe",
+		Email: "john.doe@example.com",
+		Loan:  model.Loan{ID: 7, PrincipalAmount: 1000000},
+	})
+
+	assert.NoError(t, err)
+	assert.Contains(t, buf.String(), "To: john.doe@example.com\nSubject: Your loan #7 is fully funded\n\nHi John Doe,")
+	assert.Contains(t, buf.String(), "\n---\n")
+}
@@ -0,0 +1,86 @@
+package payment
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/aldipi/loan-service/model"
+)
+
+type Direction string
+
+const (
+	DirectionCollect Direction = "collect"
+	DirectionPayout  Direction = "payout"
+)
+
+// Transfer is money moved between an investor and the service.
+type Transfer struct {
+	Reference      string
+	IdempotencyKey string
+	Direction      Direction
+	InvestorID     int64
+	Amount         int64
+	Currency       model.Currency
+}
+
+// FakeRail pretends to collect and pay out money, for local testing. It keeps
+// the transfers in memory and declines transfers above its limit. Like a real
+// rail, it moves the money of an idempotency key once, and answers later
+// requests with the same key with the reference of that transfer.
+type FakeRail struct {
+	limit int64
+
+	mu        sync.Mutex
+	transfers []Transfer
+	byKey     map[string]Transfer
+}
+
+// NewFakeRail returns a rail declining transfers above limit, in minor units,
+// or none when limit is zero.
+func NewFakeRail(limit int64) *FakeRail {
+	return &FakeRail{limit: limit, byKey: map[string]Transfer{}}
+}
+
+func (r *FakeRail) Collect(ctx context.Context, idempotencyKey string, investorID int64, amount int64, currency model.Currency) (string, error) {
+	return r.transfer(DirectionCollect, idempotencyKey, investorID, amount, currency)
+}
+
+func (r *FakeRail) Payout(ctx context.Context, idempotencyKey string, investorID int64, amount int64, currency model.Currency) (string, error) {
+	return r.transfer(DirectionPayout, idempotencyKey, investorID, amount, currency)
+}
+
+// Transfers returns the transfers made so far.
+func (r *FakeRail) Transfers() []Transfer {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]Transfer{}, r.transfers...)
+}
+
+func (r *FakeRail) transfer(direction Direction, idempotencyKey string, investorID int64, amount int64, currency model.Currency) (string, error) {
+	if r.limit > 0 && amount > r.limit {
+		return "", model.ErrPaymentDeclined
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if transfer, ok := r.byKey[idempotencyKey]; ok {
+		return transfer.Reference, nil
+	}
+
+	transfer := Transfer{
+		Reference:      fmt.Sprintf("fake-%s-%d", direction, len(r.transfers)+1),
+		IdempotencyKey: idempotencyKey,
+		Direction:      direction,
+		InvestorID:     investorID,
+		Amount:         amount,
+		Currency:       currency,
+	}
+	r.transfers = append(r.transfers, transfer)
+	r.byKey[idempotencyKey] = transfer
+
+	return transfer.Reference, nil
+}
@@ -0,0 +1,47 @@
+package payment
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aldipi/loan-service/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFakeRail(t *testing.T) {
+	rail := NewFakeRail(0)
+
+	reference, err := rail.Collect(context.Background(), "key-1", 100, 500000, model.DefaultCurrency)
+	assert.NoError(t, err)
+	assert.Equal(t, "fake-collect-1", reference)
+
+	reference, err = rail.Payout(context.Background(), "key-2", 100, 200000, model.DefaultCurrency)
+	assert.NoError(t, err)
+	assert.Equal(t, "fake-payout-2", reference)
+
+	assert.Equal(t, []Transfer{
+		{Reference: "fake-collect-1", IdempotencyKey: "key-1", Direction: DirectionCollect, InvestorID: 100, Amount: 500000, Currency: model.DefaultCurrency},
+		{Reference: "fake-payout-2", IdempotencyKey: "key-2", Direction: DirectionPayout, InvestorID: 100, Amount: 200000, Currency: model.DefaultCurrency},
+	}, rail.Transfers())
+}
+
+func TestFakeRailMovesMoneyOncePerIdempotencyKey(t *testing.T) {
+	rail := NewFakeRail(0)
+
+	first, err := rail.Collect(context.Background(), "key-1", 100, 500000, model.DefaultCurrency)
+	assert.NoError(t, err)
+	again, err := rail.Collect(context.Background(), "key-1", 100, 500000, model.DefaultCurrency)
+	assert.NoError(t, err)
+
+	assert.Equal(t, first, again)
+	assert.Len(t, rail.Transfers(), 1)
+}
+
+func TestFakeRailDeclinesAboveLimit(t *testing.T) {
+	rail := NewFakeRail(1000)
+
+	_, err := rail.Collect(context.Background(), "key-1", 100, 1001, model.DefaultCurrency)
+
+	assert.ErrorIs(t, err, model.ErrPaymentDeclined)
+	assert.Empty(t, rail.Transfers())
+}
@@ -0,0 +1,34 @@
+package publisher
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aldipi/loan-service/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFilePublisher(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	p := NewFilePublisher(path)
+
+	err := p.Publish(context.Background(), &model.OutboxMessage{ID: 1, EventType: model.LoanEventProposed, LoanID: 1, Payload: json.RawMessage(`{}`)})
+	assert.NoError(t, err)
+	err = p.Publish(context.Background(), &model.OutboxMessage{ID: 2, EventType: model.LoanEventApproved, LoanID: 1, Payload: json.RawMessage(`{}`)})
+	assert.NoError(t, err)
+
+	content, err := os.ReadFile(path)
+	assert.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	assert.Len(t, lines, 2)
+
+	message := &model.OutboxMessage{}
+	assert.NoError(t, json.Unmarshal([]byte(lines[1]), message))
+	assert.Equal(t, int64(2), message.ID)
+	assert.Equal(t, model.LoanEventApproved, message.EventType)
+}
@@ -0,0 +1,44 @@
+package publisher
+
+import (
+	"context"
+	"sync"
+
+	"github.com/aldipi/loan-service/model"
+)
+
+// Subscriber handles a published message. Returning an error makes the outbox
+// relay retry the message later.
+type Subscriber func(ctx context.Context, message *model.OutboxMessage) error
+
+// MemoryPublisher delivers messages synchronously to subscribers registered in
+// the same process.
+type MemoryPublisher struct {
+	mu          sync.RWMutex
+	subscribers []Subscriber
+}
+
+func NewMemoryPublisher() *MemoryPublisher {
+	return &MemoryPublisher{}
+}
+
+func (p *MemoryPublisher) Subscribe(subscriber Subscriber) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.subscribers = append(p.subscribers, subscriber)
+}
+
+func (p *MemoryPublisher) Publish(ctx context.Context, message *model.OutboxMessage) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	for _, subscriber := range p.subscribers {
+		err := subscriber(ctx, message)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,38 @@
+package publisher
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aldipi/loan-service/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryPublisher(t *testing.T) {
+	p := NewMemoryPublisher()
+
+	received := []*model.OutboxMessage{}
+	p.Subscribe(func(ctx context.Context, message *model.OutboxMessage) error {
+		received = append(received, message)
+		return nil
+	})
+
+	message := &model.OutboxMessage{ID: 1, EventType: model.LoanEventApproved, LoanID: 1}
+	err := p.Publish(context.Background(), message)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []*model.OutboxMessage{message}, received)
+}
+
+func TestMemoryPublisherSubscriberError(t *testing.T) {
+	p := NewMemoryPublisher()
+
+	p.Subscribe(func(ctx context.Context, message *model.OutboxMessage) error {
+		return errors.New("subscriber unavailable")
+	})
+
+	err := p.Publish(context.Background(), &model.OutboxMessage{ID: 1})
+
+	assert.EqualError(t, err, "subscriber unavailable")
+}
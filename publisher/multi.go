@@ -0,0 +1,34 @@
+package publisher
+
+import (
+	"context"
+
+	"github.com/aldipi/loan-service/model"
+)
+
+// Publisher is implemented by every publisher in this package.
+type Publisher interface {
+	Publish(ctx context.Context, message *model.OutboxMessage) error
+}
+
+// MultiPublisher publishes every message to all of its publishers in order. It
+// stops at the first error, so a retried message may be published again to the
+// publishers that already succeeded.
+type MultiPublisher struct {
+	publishers []Publisher
+}
+
+func NewMultiPublisher(publishers ...Publisher) *MultiPublisher {
+	return &MultiPublisher{publishers: publishers}
+}
+
+func (p *MultiPublisher) Publish(ctx context.Context, message *model.OutboxMessage) error {
+	for _, publisher := range p.publishers {
+		err := publisher.Publish(ctx, message)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
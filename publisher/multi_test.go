@@ -0,0 +1,49 @@
+package publisher
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aldipi/loan-service/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMultiPublisher(t *testing.T) {
+	first := NewMemoryPublisher()
+	second := NewMemoryPublisher()
+
+	var calls []string
+	first.Subscribe(func(ctx context.Context, message *model.OutboxMessage) error {
+		calls = append(calls, "first")
+		return nil
+	})
+	second.Subscribe(func(ctx context.Context, message *model.OutboxMessage) error {
+		calls = append(calls, "second")
+		return nil
+	})
+
+	err := NewMultiPublisher(first, second).Publish(context.Background(), &model.OutboxMessage{ID: 1})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"first", "second"}, calls)
+}
+
+func TestMultiPublisherStopsAtError(t *testing.T) {
+	first := NewMemoryPublisher()
+	second := NewMemoryPublisher()
+
+	first.Subscribe(func(ctx context.Context, message *model.OutboxMessage) error {
+		return errors.New("first failed")
+	})
+	called := false
+	second.Subscribe(func(ctx context.Context, message *model.OutboxMessage) error {
+		called = true
+		return nil
+	})
+
+	err := NewMultiPublisher(first, second).Publish(context.Background(), &model.OutboxMessage{ID: 1})
+
+	assert.EqualError(t, err, "first failed")
+	assert.False(t, called)
+}
@@ -0,0 +1,237 @@
+package repository
+
+import (
+	"context"
+	"strings"
+
+	"github.com/aldipi/loan-service/model"
+)
+
+// in returns the IN condition selecting the IDs from column, with its
+// arguments. The IDs must not be empty.
+func in(column string, ids []int64) (string, []any) {
+	args := make([]any, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	return column + " IN (?" + strings.Repeat(", ?", len(ids)-1) + ")", args
+}
+
+// GetInvestmentsByLoanIDs returns the investments in the loans with the IDs,
+// ordered by ID.
+func (r *LoanRepository) GetInvestmentsByLoanIDs(ctx context.Context, loanIDs []int64) ([]*model.Investment, error) {
+	if len(loanIDs) == 0 {
+		return []*model.Investment{}, nil
+	}
+
+	condition, args := in("loan_id", loanIDs)
+	query := `
+		SELECT
+			id, loan_id, investor_id, amount, currency,
+			settlement_amount, settlement_currency, fx_rate,
+			agreement_letter, agreement_checksum, created_at, last_updated_at
+		FROM
+			investments
+		WHERE
+			` + condition + `
+		ORDER BY id
+	`
+
+	rows, err := r.conn(ctx).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	investments := []*model.Investment{}
+	for rows.Next() {
+		investment, err := scanInvestment(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		investments = append(investments, investment)
+	}
+
+	return investments, nil
+}
+
+// GetLoanProductsByIDs returns the loan products with the IDs, ordered by ID.
+// IDs of missing products are left out.
+func (r *LoanRepository) GetLoanProductsByIDs(ctx context.Context, ids []int64) ([]*model.LoanProduct, error) {
+	if len(ids) == 0 {
+		return []*model.LoanProduct{}, nil
+	}
+
+	condition, args := in("id", ids)
+	query := `
+		SELECT
+			id, name, rate, roi, currency, created_at, last_updated_at
+		FROM
+			loan_products
+		WHERE
+			` + condition + `
+		ORDER BY id
+	`
+
+	rows, err := r.conn(ctx).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	loanProducts := []*model.LoanProduct{}
+	for rows.Next() {
+		loanProduct := &model.LoanProduct{}
+		err = rows.Scan(
+			&loanProduct.ID,
+			&loanProduct.Name,
+			&loanProduct.Rate,
+			&loanProduct.ROI,
+			&loanProduct.Currency,
+			&loanProduct.CreatedAt,
+			&loanProduct.LastUpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		loanProducts = append(loanProducts, loanProduct)
+	}
+
+	return loanProducts, nil
+}
+
+// GetUsersByIDs returns the users with the IDs, ordered by ID. IDs of missing
+// users are left out.
+func (r *LoanRepository) GetUsersByIDs(ctx context.Context, ids []int64) ([]*model.User, error) {
+	if len(ids) == 0 {
+		return []*model.User{}, nil
+	}
+
+	condition, args := in("id", ids)
+	query := `
+		SELECT
+			id, name, email, locale, created_at, last_updated_at
+		FROM
+			users
+		WHERE
+			` + condition + `
+		ORDER BY id
+	`
+
+	rows, err := r.conn(ctx).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []*model.User{}
+	for rows.Next() {
+		user := &model.User{}
+		err = rows.Scan(
+			&user.ID,
+			&user.Name,
+			&user.Email,
+			&user.Locale,
+			&user.CreatedAt,
+			&user.LastUpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		users = append(users, user)
+	}
+
+	return users, nil
+}
+
+// GetEmployeesByIDs returns the employees with the IDs, ordered by ID. IDs of
+// missing employees are left out.
+func (r *LoanRepository) GetEmployeesByIDs(ctx context.Context, ids []int64) ([]*model.Employee, error) {
+	if len(ids) == 0 {
+		return []*model.Employee{}, nil
+	}
+
+	condition, args := in("id", ids)
+	query := `
+		SELECT
+			id, name, created_at, last_updated_at
+		FROM
+			employees
+		WHERE
+			` + condition + `
+		ORDER BY id
+	`
+
+	rows, err := r.conn(ctx).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	employees := []*model.Employee{}
+	for rows.Next() {
+		employee := &model.Employee{}
+		err = rows.Scan(
+			&employee.ID,
+			&employee.Name,
+			&employee.CreatedAt,
+			&employee.LastUpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		employees = append(employees, employee)
+	}
+
+	return employees, nil
+}
+
+// GetInvestorsByIDs returns the investors with the IDs, ordered by ID. IDs of
+// missing investors are left out.
+func (r *LoanRepository) GetInvestorsByIDs(ctx context.Context, ids []int64) ([]*model.Investor, error) {
+	if len(ids) == 0 {
+		return []*model.Investor{}, nil
+	}
+
+	condition, args := in("id", ids)
+	query := `
+		SELECT
+			id, name, email, locale, currency, created_at, last_updated_at
+		FROM
+			investors
+		WHERE
+			` + condition + `
+		ORDER BY id
+	`
+
+	rows, err := r.conn(ctx).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	investors := []*model.Investor{}
+	for rows.Next() {
+		investor := &model.Investor{}
+		err = rows.Scan(
+			&investor.ID,
+			&investor.Name,
+			&investor.Email,
+			&investor.Locale,
+			&investor.Currency,
+			&investor.CreatedAt,
+			&investor.LastUpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		investors = append(investors, investor)
+	}
+
+	return investors, nil
+}
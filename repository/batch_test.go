@@ -0,0 +1,199 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/aldipi/loan-service/model"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetUsersByIDsEmpty(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewLoanRepository(db)
+
+	users, err := repo.GetUsersByIDs(context.Background(), nil)
+
+	assert.NoError(t, err)
+	assert.Empty(t, users)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetInvestmentsByLoanIDs(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewLoanRepository(db)
+
+	createdAt, _ := time.Parse("2006-01-02 15:04:05", "2021-01-01 00:00:00")
+	rows := sqlmock.NewRows([]string{"id", "loan_id", "investor_id", "amount", "currency", "settlement_amount", "settlement_currency", "fx_rate", "agreement_letter", "agreement_checksum", "created_at", "last_updated_at"}).
+		AddRow(1, 1, 123, 200000, "IDR", 200000, "IDR", "1", "https://file.io/123/agreement_letter.pdf", "", createdAt, createdAt).
+		AddRow(2, 2, 456, 300000, "IDR", 300000, "IDR", "1", "https://file.io/456/agreement_letter.pdf", "", createdAt, createdAt)
+
+	mock.ExpectQuery(regexp.QuoteMeta("FROM investments WHERE loan_id IN (?, ?) ORDER BY id")).
+		WithArgs(1, 2).
+		WillReturnRows(rows)
+
+	investments, err := repo.GetInvestmentsByLoanIDs(context.Background(), []int64{1, 2})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []*model.Investment{
+		{
+			ID:                 1,
+			LoanID:             1,
+			InvestorID:         123,
+			Amount:             200000,
+			Currency:           "IDR",
+			SettlementAmount:   200000,
+			SettlementCurrency: "IDR",
+			FXRate:             decimal.RequireFromString("1"),
+			AgreementLetter:    "https://file.io/123/agreement_letter.pdf",
+			CreatedAt:          createdAt,
+			LastUpdatedAt:      createdAt,
+		},
+		{
+			ID:                 2,
+			LoanID:             2,
+			InvestorID:         456,
+			Amount:             300000,
+			Currency:           "IDR",
+			SettlementAmount:   300000,
+			SettlementCurrency: "IDR",
+			FXRate:             decimal.RequireFromString("1"),
+			AgreementLetter:    "https://file.io/456/agreement_letter.pdf",
+			CreatedAt:          createdAt,
+			LastUpdatedAt:      createdAt,
+		},
+	}, investments)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetLoanProductsByIDs(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewLoanRepository(db)
+
+	createdAt, _ := time.Parse("2006-01-02 15:04:05", "2021-01-01 00:00:00")
+	rows := sqlmock.NewRows([]string{"id", "name", "rate", "roi", "currency", "created_at", "last_updated_at"}).
+		AddRow(1, "Micro", "10", "8", "IDR", createdAt, createdAt)
+
+	mock.ExpectQuery(regexp.QuoteMeta("FROM loan_products WHERE id IN (?) ORDER BY id")).
+		WithArgs(1).
+		WillReturnRows(rows)
+
+	loanProducts, err := repo.GetLoanProductsByIDs(context.Background(), []int64{1})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []*model.LoanProduct{{
+		ID:            1,
+		Name:          "Micro",
+		Rate:          decimal.RequireFromString("10"),
+		ROI:           decimal.RequireFromString("8"),
+		Currency:      "IDR",
+		CreatedAt:     createdAt,
+		LastUpdatedAt: createdAt,
+	}}, loanProducts)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetUsersByIDs(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewLoanRepository(db)
+
+	createdAt, _ := time.Parse("2006-01-02 15:04:05", "2021-01-01 00:00:00")
+	rows := sqlmock.NewRows([]string{"id", "name", "email", "locale", "created_at", "last_updated_at"}).
+		AddRow(1, "John Doe", "john.doe@example.com", "id", createdAt, createdAt).
+		AddRow(2, "Jane Doe", nil, "en", createdAt, createdAt)
+
+	mock.ExpectQuery(regexp.QuoteMeta("FROM users WHERE id IN (?, ?) ORDER BY id")).
+		WithArgs(1, 2).
+		WillReturnRows(rows)
+
+	users, err := repo.GetUsersByIDs(context.Background(), []int64{1, 2})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []*model.User{
+		{
+			ID:            1,
+			Name:          "John Doe",
+			Email:         sql.NullString{String: "john.doe@example.com", Valid: true},
+			Locale:        "id",
+			CreatedAt:     createdAt,
+			LastUpdatedAt: createdAt,
+		},
+		{
+			ID:            2,
+			Name:          "Jane Doe",
+			Locale:        "en",
+			CreatedAt:     createdAt,
+			LastUpdatedAt: createdAt,
+		},
+	}, users)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetEmployeesByIDs(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewLoanRepository(db)
+
+	createdAt, _ := time.Parse("2006-01-02 15:04:05", "2021-01-01 00:00:00")
+	rows := sqlmock.NewRows([]string{"id", "name", "created_at", "last_updated_at"}).
+		AddRow(7, "Budi", createdAt, createdAt)
+
+	mock.ExpectQuery(regexp.QuoteMeta("FROM employees WHERE id IN (?, ?) ORDER BY id")).
+		WithArgs(7, 8).
+		WillReturnRows(rows)
+
+	employees, err := repo.GetEmployeesByIDs(context.Background(), []int64{7, 8})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []*model.Employee{{ID: 7, Name: "Budi", CreatedAt: createdAt, LastUpdatedAt: createdAt}}, employees)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetInvestorsByIDs(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewLoanRepository(db)
+
+	createdAt, _ := time.Parse("2006-01-02 15:04:05", "2021-01-01 00:00:00")
+	rows := sqlmock.NewRows([]string{"id", "name", "email", "locale", "currency", "created_at", "last_updated_at"}).
+		AddRow(123, "Ani", "ani@example.com", "id", "IDR", createdAt, createdAt)
+
+	mock.ExpectQuery(regexp.QuoteMeta("FROM investors WHERE id IN (?) ORDER BY id")).
+		WithArgs(123).
+		WillReturnRows(rows)
+
+	investors, err := repo.GetInvestorsByIDs(context.Background(), []int64{123})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []*model.Investor{{
+		ID:            123,
+		Name:          "Ani",
+		Email:         sql.NullString{String: "ani@example.com", Valid: true},
+		Locale:        "id",
+		Currency:      "IDR",
+		CreatedAt:     createdAt,
+		LastUpdatedAt: createdAt,
+	}}, investors)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
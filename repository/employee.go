@@ -16,7 +16,7 @@ func (r *LoanRepository) GetEmployeeByID(ctx context.Context, id int64) (*model.
 			id = ?
 	`
 
-	row := r.DB.QueryRowContext(ctx, query, id)
+	row := r.conn(ctx).QueryRowContext(ctx, query, id)
 
 	employee := &model.Employee{}
 	err := row.Scan(
@@ -0,0 +1,265 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/aldipi/loan-service/model"
+)
+
+// StreamLoans calls fn with every loan matching the filter in its sort order,
+// starting after its cursor and ignoring its limit. Rows are read from the
+// server as fn consumes them instead of being loaded at once, so memory stays
+// flat however many loans match, while a connection is held until the last
+// loan is read. An error returned by fn stops the stream.
+func (r *LoanRepository) StreamLoans(ctx context.Context, filter model.LoanFilter, fn func(loan *model.Loan) error) error {
+	column, ok := loanSortColumns[filter.Sort.Field]
+	if !ok {
+		return model.ErrSortFieldInvalid
+	}
+
+	c := loanConditions(filter)
+	err := c.addAfter(column, filter.Sort, filter.Cursor)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		SELECT
+			id, state, borrower_id, loan_product_id, principal_amount, currency, rate, roi,
+			approval_proof, approved_by, agreement_letter, disbursed_by,
+			borrower_agreement, borrower_agreement_checksum,
+			created_at, approved_at, invested_at, disbursed_at, last_updated_at
+		FROM
+			loans
+		` + c.where() + `
+		` + orderBy(column, filter.Sort) + `
+	`
+
+	rows, err := r.conn(ctx).QueryContext(ctx, query, c.args...)
+	if err != nil {
+		return err
+	}
+
+	for rows.Next() {
+		loan, err := scanLoan(rows)
+		if err == nil {
+			err = fn(loan)
+		}
+		if err != nil {
+			rows.Close()
+			return err
+		}
+	}
+
+	// Close reports a stream that broke off before its last row.
+	return rows.Close()
+}
+
+// StreamInvestments calls fn with every investment matching the filter, like
+// StreamLoans.
+func (r *LoanRepository) StreamInvestments(ctx context.Context, filter model.InvestmentFilter, fn func(investment *model.Investment) error) error {
+	column, ok := investmentSortColumns[filter.Sort.Field]
+	if !ok {
+		return model.ErrSortFieldInvalid
+	}
+
+	c := investmentConditions(filter)
+	err := c.addAfter(column, filter.Sort, filter.Cursor)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		SELECT
+			id, loan_id, investor_id, amount, currency,
+			settlement_amount, settlement_currency, fx_rate,
+			agreement_letter, agreement_checksum, created_at, last_updated_at
+		FROM
+			investments
+		` + c.where() + `
+		` + orderBy(column, filter.Sort) + `
+	`
+
+	rows, err := r.conn(ctx).QueryContext(ctx, query, c.args...)
+	if err != nil {
+		return err
+	}
+
+	for rows.Next() {
+		investment, err := scanInvestment(rows)
+		if err == nil {
+			err = fn(investment)
+		}
+		if err != nil {
+			rows.Close()
+			return err
+		}
+	}
+
+	return rows.Close()
+}
+
+func (r *LoanRepository) CreateExportJob(ctx context.Context, job *model.ExportJob) (id int64, err error) {
+	query := `
+		INSERT INTO export_jobs (kind, format, filter, requested_by_role, requested_by, state)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`
+
+	res, err := r.conn(ctx).ExecContext(ctx, query,
+		job.Kind,
+		job.Format,
+		job.Filter,
+		job.RequestedByRole,
+		job.RequestedBy,
+		job.State,
+	)
+
+	if err != nil {
+		return
+	}
+
+	return res.LastInsertId()
+}
+
+func (r *LoanRepository) GetExportJobByID(ctx context.Context, id int64) (*model.ExportJob, error) {
+	query := `
+		SELECT
+			id, kind, format, filter, requested_by_role, requested_by, state, row_count,
+			file_key, last_error, created_at, started_at, completed_at, last_updated_at
+		FROM
+			export_jobs
+		WHERE
+			id = ?
+	`
+
+	return scanExportJob(r.conn(ctx).QueryRowContext(ctx, query, id))
+}
+
+// GetPendingExportJobs returns jobs waiting to be run, oldest first.
+func (r *LoanRepository) GetPendingExportJobs(ctx context.Context, limit int) ([]*model.ExportJob, error) {
+	query := `
+		SELECT
+			id, kind, format, filter, requested_by_role, requested_by, state, row_count,
+			file_key, last_error, created_at, started_at, completed_at, last_updated_at
+		FROM
+			export_jobs
+		WHERE
+			state = ?
+		ORDER BY id
+		LIMIT ?
+	`
+
+	rows, err := r.conn(ctx).QueryContext(ctx, query, model.ExportJobPending, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	jobs := []*model.ExportJob{}
+	for rows.Next() {
+		job, err := scanExportJob(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		jobs = append(jobs, job)
+	}
+
+	return jobs, nil
+}
+
+// StartExportJob marks a pending job as running. It reports false when the
+// job is no longer pending, as another worker started it first.
+func (r *LoanRepository) StartExportJob(ctx context.Context, id int64, startedAt time.Time) (bool, error) {
+	query := `
+		UPDATE export_jobs
+		SET state = ?,
+			started_at = ?,
+			last_updated_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND state = ?
+	`
+
+	res, err := r.conn(ctx).ExecContext(ctx, query, model.ExportJobRunning, startedAt, id, model.ExportJobPending)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected == 1, nil
+}
+
+// RequeueStaleExportJobs makes jobs running without an update for longer than
+// timeout pending again, as the worker running them stopped before recording
+// their outcome. It returns how many jobs were requeued.
+func (r *LoanRepository) RequeueStaleExportJobs(ctx context.Context, timeout time.Duration) (int64, error) {
+	query := `
+		UPDATE export_jobs
+		SET state = ?,
+			started_at = NULL,
+			last_updated_at = CURRENT_TIMESTAMP
+		WHERE state = ? AND last_updated_at < DATE_SUB(CURRENT_TIMESTAMP, INTERVAL ? SECOND)
+	`
+
+	res, err := r.conn(ctx).ExecContext(ctx, query, model.ExportJobPending, model.ExportJobRunning, int64(timeout.Seconds()))
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
+
+// FinishExportJob records the outcome of a job that was running.
+func (r *LoanRepository) FinishExportJob(ctx context.Context, job *model.ExportJob) error {
+	query := `
+		UPDATE export_jobs
+		SET state = ?,
+			row_count = ?,
+			file_key = ?,
+			last_error = ?,
+			completed_at = ?,
+			last_updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?
+	`
+
+	_, err := r.conn(ctx).ExecContext(ctx, query,
+		job.State,
+		job.RowCount,
+		job.FileKey,
+		job.LastError,
+		job.CompletedAt,
+		job.ID,
+	)
+
+	return err
+}
+
+func scanExportJob(row scanner) (*model.ExportJob, error) {
+	job := &model.ExportJob{}
+	err := row.Scan(
+		&job.ID,
+		&job.Kind,
+		&job.Format,
+		&job.Filter,
+		&job.RequestedByRole,
+		&job.RequestedBy,
+		&job.State,
+		&job.RowCount,
+		&job.FileKey,
+		&job.LastError,
+		&job.CreatedAt,
+		&job.StartedAt,
+		&job.CompletedAt,
+		&job.LastUpdatedAt,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return job, nil
+}
@@ -0,0 +1,314 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/aldipi/loan-service/model"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+var loanColumns = []string{"id", "state", "borrower_id", "loan_product_id", "principal_amount", "currency", "rate", "roi", "approval_proof", "approved_by", "agreement_letter", "disbursed_by", "borrower_agreement", "borrower_agreement_checksum", "created_at", "approved_at", "invested_at", "disbursed_at", "last_updated_at"}
+
+var exportJobColumns = []string{"id", "kind", "format", "filter", "requested_by_role", "requested_by", "state", "row_count", "file_key", "last_error", "created_at", "started_at", "completed_at", "last_updated_at"}
+
+func TestStreamLoans(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewLoanRepository(db)
+
+	state := model.LoanStateDisbursed
+	createdAt := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	rows := sqlmock.NewRows(loanColumns).
+		AddRow(1, state, 123, nil, 1000000, "IDR", decimal.NewFromInt(6), decimal.NewFromInt(3), nil, 555, nil, 777, nil, nil, createdAt, createdAt, createdAt, createdAt, createdAt).
+		AddRow(2, state, 456, nil, 2000000, "IDR", decimal.NewFromInt(6), decimal.NewFromInt(3), nil, 555, nil, 777, nil, nil, createdAt, createdAt, createdAt, createdAt, createdAt)
+
+	query := regexp.QuoteMeta(`
+		SELECT
+			id, state, borrower_id, loan_product_id, principal_amount, currency, rate, roi,
+			approval_proof, approved_by, agreement_letter, disbursed_by,
+			borrower_agreement, borrower_agreement_checksum,
+			created_at, approved_at, invested_at, disbursed_at, last_updated_at
+		FROM
+			loans
+		WHERE state = ?
+		ORDER BY principal_amount DESC, id DESC
+	`)
+
+	mock.ExpectQuery(query).WithArgs(state).WillReturnRows(rows)
+
+	ids := []int64{}
+	err = repo.StreamLoans(context.Background(), model.LoanFilter{
+		State: &state,
+		Sort:  model.Sort{Field: model.SortByPrincipalAmount, Desc: true},
+		Limit: 10,
+	}, func(loan *model.Loan) error {
+		ids = append(ids, loan.ID)
+		return nil
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{1, 2}, ids)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestStreamLoansStops(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewLoanRepository(db)
+
+	rows := sqlmock.NewRows(loanColumns).AddRow(proposedLoanRow(1)...).AddRow(proposedLoanRow(2)...)
+	mock.ExpectQuery("FROM loans").WillReturnRows(rows)
+
+	ids := []int64{}
+	err = repo.StreamLoans(context.Background(), model.LoanFilter{Sort: model.Sort{Field: model.SortByID}}, func(loan *model.Loan) error {
+		ids = append(ids, loan.ID)
+		return errors.New("client went away")
+	})
+
+	assert.EqualError(t, err, "client went away")
+	assert.Equal(t, []int64{1}, ids)
+
+	// A stream broken off by the server fails too.
+	rows = sqlmock.NewRows(loanColumns).AddRow(proposedLoanRow(1)...).RowError(0, errors.New("connection reset"))
+	mock.ExpectQuery("FROM loans").WillReturnRows(rows)
+
+	err = repo.StreamLoans(context.Background(), model.LoanFilter{Sort: model.Sort{Field: model.SortByID}}, func(loan *model.Loan) error {
+		return nil
+	})
+
+	assert.EqualError(t, err, "connection reset")
+}
+
+func proposedLoanRow(id int64) []driver.Value {
+	createdAt := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	return []driver.Value{id, model.LoanStateProposed, 123, nil, 1000000, "IDR", "6", "3", nil, nil, nil, nil, nil, nil, createdAt, nil, nil, nil, createdAt}
+}
+
+func TestStreamLoansRejectsInvalidSort(t *testing.T) {
+	db, _, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewLoanRepository(db)
+
+	err = repo.StreamLoans(context.Background(), model.LoanFilter{Sort: model.Sort{Field: "rate"}}, func(loan *model.Loan) error {
+		return nil
+	})
+
+	assert.Equal(t, model.ErrSortFieldInvalid, err)
+}
+
+func TestStreamInvestments(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewLoanRepository(db)
+
+	createdAt := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	rows := sqlmock.NewRows([]string{"id", "loan_id", "investor_id", "amount", "currency", "settlement_amount", "settlement_currency", "fx_rate", "agreement_letter", "agreement_checksum", "created_at", "last_updated_at"}).
+		AddRow(5, 1, 9, 500000, "IDR", 500000, "IDR", decimal.NewFromInt(1), "agreements/5.pdf", "abc", createdAt, createdAt)
+
+	query := regexp.QuoteMeta(`
+		SELECT
+			id, loan_id, investor_id, amount, currency,
+			settlement_amount, settlement_currency, fx_rate,
+			agreement_letter, agreement_checksum, created_at, last_updated_at
+		FROM
+			investments
+		WHERE investor_id = ?
+		ORDER BY id ASC
+	`)
+
+	mock.ExpectQuery(query).WithArgs(9).WillReturnRows(rows)
+
+	investments := []*model.Investment{}
+	err = repo.StreamInvestments(context.Background(), model.InvestmentFilter{InvestorID: 9, Sort: model.Sort{Field: model.SortByID}}, func(investment *model.Investment) error {
+		investments = append(investments, investment)
+		return nil
+	})
+
+	assert.NoError(t, err)
+	assert.Len(t, investments, 1)
+	assert.Equal(t, int64(5), investments[0].ID)
+	assert.Equal(t, int64(9), investments[0].InvestorID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCreateExportJob(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewLoanRepository(db)
+
+	filter := json.RawMessage(`{"BorrowerID":123}`)
+	query := regexp.QuoteMeta(`
+		INSERT INTO export_jobs (kind, format, filter, requested_by_role, requested_by, state)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`)
+
+	mock.ExpectExec(query).
+		WithArgs(model.ExportLoans, model.ExportFormatCSV, filter, model.RoleBorrower, 123, model.ExportJobPending).
+		WillReturnResult(sqlmock.NewResult(4, 1))
+
+	id, err := repo.CreateExportJob(context.Background(), &model.ExportJob{
+		Kind:            model.ExportLoans,
+		Format:          model.ExportFormatCSV,
+		Filter:          filter,
+		RequestedByRole: model.RoleBorrower,
+		RequestedBy:     123,
+		State:           model.ExportJobPending,
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), id)
+}
+
+func TestGetPendingExportJobs(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewLoanRepository(db)
+
+	createdAt := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	rows := sqlmock.NewRows(exportJobColumns).
+		AddRow(4, "loans", "xlsx", []byte(`{}`), "employee", 555, "pending", 0, nil, nil, createdAt, nil, nil, createdAt)
+
+	query := regexp.QuoteMeta(`
+		SELECT
+			id, kind, format, filter, requested_by_role, requested_by, state, row_count,
+			file_key, last_error, created_at, started_at, completed_at, last_updated_at
+		FROM
+			export_jobs
+		WHERE
+			state = ?
+		ORDER BY id
+		LIMIT ?
+	`)
+
+	mock.ExpectQuery(query).WithArgs(model.ExportJobPending, 10).WillReturnRows(rows)
+
+	jobs, err := repo.GetPendingExportJobs(context.Background(), 10)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []*model.ExportJob{{
+		ID:              4,
+		Kind:            model.ExportLoans,
+		Format:          model.ExportFormatXLSX,
+		Filter:          json.RawMessage(`{}`),
+		RequestedByRole: model.RoleEmployee,
+		RequestedBy:     555,
+		State:           model.ExportJobPending,
+		CreatedAt:       createdAt,
+		LastUpdatedAt:   createdAt,
+	}}, jobs)
+}
+
+func TestStartExportJob(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewLoanRepository(db)
+
+	at := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	query := regexp.QuoteMeta(`
+		UPDATE export_jobs
+		SET state = ?,
+			started_at = ?,
+			last_updated_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND state = ?
+	`)
+
+	mock.ExpectExec(query).
+		WithArgs(model.ExportJobRunning, at, 4, model.ExportJobPending).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(query).
+		WithArgs(model.ExportJobRunning, at, 4, model.ExportJobPending).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	started, err := repo.StartExportJob(context.Background(), 4, at)
+	assert.NoError(t, err)
+	assert.True(t, started)
+
+	started, err = repo.StartExportJob(context.Background(), 4, at)
+	assert.NoError(t, err)
+	assert.False(t, started)
+}
+
+func TestRequeueStaleExportJobs(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewLoanRepository(db)
+
+	query := regexp.QuoteMeta(`
+		UPDATE export_jobs
+		SET state = ?,
+			started_at = NULL,
+			last_updated_at = CURRENT_TIMESTAMP
+		WHERE state = ? AND last_updated_at < DATE_SUB(CURRENT_TIMESTAMP, INTERVAL ? SECOND)
+	`)
+
+	mock.ExpectExec(query).
+		WithArgs(model.ExportJobPending, model.ExportJobRunning, 3600).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+
+	requeued, err := repo.RequeueStaleExportJobs(context.Background(), time.Hour)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), requeued)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestFinishExportJob(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewLoanRepository(db)
+
+	completedAt := sql.NullTime{Time: time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC), Valid: true}
+	fileKey := sql.NullString{String: "exports/4/loans.xlsx", Valid: true}
+	query := regexp.QuoteMeta(`
+		UPDATE export_jobs
+		SET state = ?,
+			row_count = ?,
+			file_key = ?,
+			last_error = ?,
+			completed_at = ?,
+			last_updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?
+	`)
+
+	mock.ExpectExec(query).
+		WithArgs(model.ExportJobCompleted, 1000, fileKey, sql.NullString{}, completedAt, 4).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err = repo.FinishExportJob(context.Background(), &model.ExportJob{
+		ID:          4,
+		State:       model.ExportJobCompleted,
+		RowCount:    1000,
+		FileKey:     fileKey,
+		CompletedAt: completedAt,
+	})
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
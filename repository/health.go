@@ -0,0 +1,25 @@
+package repository
+
+import (
+	"context"
+)
+
+// Ping checks that the database can be reached.
+func (r *LoanRepository) Ping(ctx context.Context) error {
+	return r.DB.PingContext(ctx)
+}
+
+// GetSchemaVersion returns the version of the newest migration applied to the
+// database, and whether it failed halfway and left the schema dirty.
+func (r *LoanRepository) GetSchemaVersion(ctx context.Context) (version int64, dirty bool, err error) {
+	query := `
+		SELECT
+			version, dirty
+		FROM
+			schema_migrations
+		LIMIT 1
+	`
+
+	err = r.conn(ctx).QueryRowContext(ctx, query).Scan(&version, &dirty)
+	return version, dirty, err
+}
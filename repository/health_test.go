@@ -0,0 +1,58 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPing(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewLoanRepository(db)
+
+	mock.ExpectPing()
+	assert.NoError(t, repo.Ping(context.Background()))
+
+	mock.ExpectPing().WillReturnError(errors.New("connection refused"))
+	assert.Error(t, repo.Ping(context.Background()))
+}
+
+func TestGetSchemaVersion(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewLoanRepository(db)
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT version, dirty FROM schema_migrations LIMIT 1")).
+		WillReturnRows(sqlmock.NewRows([]string{"version", "dirty"}).AddRow(15, false))
+
+	version, dirty, err := repo.GetSchemaVersion(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(15), version)
+	assert.False(t, dirty)
+}
+
+func TestGetSchemaVersionNotMigrated(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewLoanRepository(db)
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT version, dirty FROM schema_migrations LIMIT 1")).
+		WillReturnError(sql.ErrNoRows)
+
+	_, _, err = repo.GetSchemaVersion(context.Background())
+
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+}
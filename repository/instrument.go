@@ -0,0 +1,139 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/aldipi/loan-service/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type sqlRows interface {
+	Next() bool
+	Scan(dest ...any) error
+	Close() error
+}
+
+// instrumentedConn logs every query, and records a span for it on the
+// request's trace, with the SQL statement and the number of rows it returned
+// or affected. Query arguments are never logged.
+type instrumentedConn struct {
+	q      querier
+	logger *slog.Logger
+}
+
+// query is a query being run on an instrumentedConn.
+type query struct {
+	ctx       context.Context
+	logger    *slog.Logger
+	span      trace.Span
+	statement string
+	start     time.Time
+}
+
+func (c instrumentedConn) start(ctx context.Context, statement string) (context.Context, *query) {
+	statement = strings.Join(strings.Fields(statement), " ")
+	operation, _, _ := strings.Cut(statement, " ")
+
+	ctx, span := tracing.Start(ctx, "db."+strings.ToLower(operation),
+		attribute.String("db.system", "mysql"),
+		attribute.String("db.statement", statement),
+	)
+	return ctx, &query{ctx: ctx, logger: c.logger, span: span, statement: statement, start: time.Now()}
+}
+
+// end records the outcome of the query. rowsKey names what rows counts.
+// Missing rows are an expected outcome, not a failed query.
+func (q *query) end(rowsKey string, rows int64, err error) {
+	duration := time.Since(q.start)
+	if errors.Is(err, sql.ErrNoRows) {
+		err = nil
+	}
+
+	if err != nil {
+		q.logger.ErrorContext(q.ctx, "query failed", "statement", q.statement, "duration", duration, "error", err)
+	} else {
+		q.span.SetAttributes(attribute.Int64(rowsKey, rows))
+		q.logger.DebugContext(q.ctx, "query", "statement", q.statement, "duration", duration, rowsKey, rows)
+	}
+	tracing.End(q.span, err)
+}
+
+func (c instrumentedConn) ExecContext(ctx context.Context, statement string, args ...any) (sql.Result, error) {
+	ctx, q := c.start(ctx, statement)
+	result, err := c.q.ExecContext(ctx, statement, args...)
+
+	var affected int64
+	if err == nil {
+		// Not every driver reports affected rows, which is not an error of
+		// the query.
+		affected, _ = result.RowsAffected()
+	}
+	q.end("db.rows_affected", affected, err)
+
+	return result, err
+}
+
+func (c instrumentedConn) QueryContext(ctx context.Context, statement string, args ...any) (sqlRows, error) {
+	ctx, q := c.start(ctx, statement)
+	rows, err := c.q.QueryContext(ctx, statement, args...)
+	if err != nil {
+		q.end("db.rows_returned", 0, err)
+		return nil, err
+	}
+
+	return &instrumentedRows{Rows: rows, query: q}, nil
+}
+
+func (c instrumentedConn) QueryRowContext(ctx context.Context, statement string, args ...any) scanner {
+	ctx, q := c.start(ctx, statement)
+	return &instrumentedRow{row: c.q.QueryRowContext(ctx, statement, args...), query: q}
+}
+
+// instrumentedRows ends its query when closed, once the number of rows read
+// is known.
+type instrumentedRows struct {
+	*sql.Rows
+	query *query
+	count int64
+}
+
+func (r *instrumentedRows) Next() bool {
+	if !r.Rows.Next() {
+		return false
+	}
+	r.count++
+	return true
+}
+
+func (r *instrumentedRows) Close() error {
+	err := r.Rows.Close()
+	if err == nil {
+		err = r.Rows.Err()
+	}
+	r.query.end("db.rows_returned", r.count, err)
+
+	return err
+}
+
+type instrumentedRow struct {
+	row   *sql.Row
+	query *query
+}
+
+func (r *instrumentedRow) Scan(dest ...any) error {
+	err := r.row.Scan(dest...)
+
+	var count int64
+	if err == nil {
+		count = 1
+	}
+	r.query.end("db.rows_returned", count, err)
+
+	return err
+}
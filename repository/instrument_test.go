@@ -0,0 +1,148 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log/slog"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/aldipi/loan-service/model"
+	"github.com/aldipi/loan-service/tracing"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// startRequestSpan starts the server span of a request. The spans started
+// below it are recorded by the returned recorder once they end.
+func startRequestSpan(name string) (context.Context, trace.Span, *tracetest.SpanRecorder) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	ctx, span := provider.Tracer("test").Start(context.Background(), name, trace.WithSpanKind(trace.SpanKindServer))
+	return ctx, span, recorder
+}
+
+func spanAttribute(span sdktrace.ReadOnlySpan, key string) any {
+	for _, attr := range span.Attributes() {
+		if string(attr.Key) == key {
+			return attr.Value.AsInterface()
+		}
+	}
+	return nil
+}
+
+func TestQueriesAreTraced(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewLoanRepository(db)
+	ctx, root, recorder := startRequestSpan("POST /investments")
+
+	createdAt := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id, investor_id, type, amount, currency, status, loan_id, investment_id, reference, idempotency_key, created_at FROM wallet_entries WHERE investor_id = ? ORDER BY id DESC LIMIT ?")).
+		WithArgs(100, 10).
+		WillReturnRows(sqlmock.NewRows(walletEntryColumns).
+			AddRow(2, 100, model.WalletReservation, 200000, "IDR", model.WalletEntrySettled, 1, 5, nil, nil, createdAt).
+			AddRow(1, 100, model.WalletDeposit, 500000, "IDR", model.WalletEntrySettled, nil, nil, "fake-1", "key-1", createdAt))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT investor_id, currency, available, reserved, deployed, last_updated_at FROM wallets WHERE investor_id = ?")).
+		WithArgs(100).
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE wallets SET available = ?, reserved = ?, deployed = ?, last_updated_at = ? WHERE investor_id = ?")).
+		WithArgs(100, 200, 300, createdAt, 100).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	err = repo.WithTransaction(ctx, func(ctx context.Context) error {
+		_, err := repo.GetWalletEntriesByInvestorID(ctx, 100, 10)
+		assert.NoError(t, err)
+		_, err = repo.GetWalletByInvestorID(ctx, 100)
+		assert.ErrorIs(t, err, sql.ErrNoRows)
+		return repo.UpdateWallet(ctx, &model.Wallet{InvestorID: 100, Available: 100, Reserved: 200, Deployed: 300, LastUpdatedAt: createdAt})
+	})
+	tracing.End(root, err)
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+	spans := recorder.Ended()
+	assert.Len(t, spans, 5)
+
+	entries, wallet, update, transaction := spans[0], spans[1], spans[2], spans[3]
+	assert.Equal(t, "db.transaction", transaction.Name())
+	assert.Equal(t, root.SpanContext().SpanID(), transaction.Parent().SpanID())
+
+	assert.Equal(t, "db.select", entries.Name())
+	assert.Equal(t, transaction.SpanContext().SpanID(), entries.Parent().SpanID())
+	assert.Equal(t, "SELECT id, investor_id, type, amount, currency, status, loan_id, investment_id, reference, idempotency_key, created_at FROM wallet_entries WHERE investor_id = ? ORDER BY id DESC LIMIT ?", spanAttribute(entries, "db.statement"))
+	assert.Equal(t, int64(2), spanAttribute(entries, "db.rows_returned"))
+
+	assert.Equal(t, int64(0), spanAttribute(wallet, "db.rows_returned"))
+	assert.Equal(t, codes.Unset, wallet.Status().Code)
+
+	assert.Equal(t, "db.update", update.Name())
+	assert.Equal(t, int64(1), spanAttribute(update, "db.rows_affected"))
+}
+
+func TestFailedQueriesAreTraced(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewLoanRepository(db)
+	ctx, _, recorder := startRequestSpan("GET /investors/me/wallet")
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT COALESCE(SUM(amount), 0) FROM wallet_entries WHERE investor_id = ? AND type = ? AND status = ?")).
+		WithArgs(100, model.WalletPayout, model.WalletEntrySettled).
+		WillReturnError(sql.ErrConnDone)
+
+	_, err = repo.SumWalletEntries(ctx, 100, model.WalletPayout)
+
+	assert.ErrorIs(t, err, sql.ErrConnDone)
+	assert.Len(t, recorder.Ended(), 1)
+	assert.Equal(t, sql.ErrConnDone.Error(), recorder.Ended()[0].Status().Description)
+}
+
+func TestQueriesAreLogged(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	var buf bytes.Buffer
+	repo := NewLoanRepository(db, WithLogger(slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))))
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT COALESCE(SUM(amount), 0) FROM wallet_entries WHERE investor_id = ? AND type = ? AND status = ?")).
+		WithArgs(100, model.WalletPayout, model.WalletEntrySettled).
+		WillReturnRows(sqlmock.NewRows([]string{"sum"}).AddRow(15000))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE wallets SET available = ?, reserved = ?, deployed = ?, last_updated_at = ? WHERE investor_id = ?")).
+		WithArgs(100, 200, 300, sqlmock.AnyArg(), 100).
+		WillReturnError(sql.ErrConnDone)
+
+	_, err = repo.SumWalletEntries(context.Background(), 100, model.WalletPayout)
+	assert.NoError(t, err)
+	err = repo.UpdateWallet(context.Background(), &model.Wallet{InvestorID: 100, Available: 100, Reserved: 200, Deployed: 300})
+	assert.ErrorIs(t, err, sql.ErrConnDone)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Len(t, lines, 2)
+
+	query := map[string]any{}
+	assert.NoError(t, json.Unmarshal([]byte(lines[0]), &query))
+	assert.Equal(t, "DEBUG", query["level"])
+	assert.Equal(t, "SELECT COALESCE(SUM(amount), 0) FROM wallet_entries WHERE investor_id = ? AND type = ? AND status = ?", query["statement"])
+	assert.Equal(t, float64(1), query["db.rows_returned"])
+
+	failure := map[string]any{}
+	assert.NoError(t, json.Unmarshal([]byte(lines[1]), &failure))
+	assert.Equal(t, "ERROR", failure["level"])
+	assert.Equal(t, "query failed", failure["msg"])
+	assert.Equal(t, sql.ErrConnDone.Error(), failure["error"])
+}
@@ -9,14 +9,16 @@ import (
 func (r *LoanRepository) GetInvestmentsByLoanID(ctx context.Context, loanID int64) ([]*model.Investment, error) {
 	query := `
 		SELECT
-			id, loan_id, investor_id, amount, agreement_letter, created_at, last_updated_at
+			id, loan_id, investor_id, amount, currency,
+			settlement_amount, settlement_currency, fx_rate,
+			agreement_letter, agreement_checksum, created_at, last_updated_at
 		FROM
 			investments
 		WHERE
 			loan_id = ?
 	`
 
-	rows, err := r.DB.QueryContext(ctx, query, loanID)
+	rows, err := r.conn(ctx).QueryContext(ctx, query, loanID)
 	if err != nil {
 		return nil, err
 	}
@@ -24,16 +26,7 @@ func (r *LoanRepository) GetInvestmentsByLoanID(ctx context.Context, loanID int6
 
 	investments := []*model.Investment{}
 	for rows.Next() {
-		investment := &model.Investment{}
-		err = rows.Scan(
-			&investment.ID,
-			&investment.LoanID,
-			&investment.InvestorID,
-			&investment.Amount,
-			&investment.AgreementLetter,
-			&investment.CreatedAt,
-			&investment.LastUpdatedAt,
-		)
+		investment, err := scanInvestment(rows)
 		if err != nil {
 			return nil, err
 		}
@@ -44,18 +37,33 @@ func (r *LoanRepository) GetInvestmentsByLoanID(ctx context.Context, loanID int6
 	return investments, nil
 }
 
-func (r *LoanRepository) GetInvestmentsByInvestorID(ctx context.Context, investorID int64, limit int, offset int) ([]*model.Investment, error) {
+// GetInvestments returns a page of the investments matching the filter in its
+// sort order, starting after its cursor.
+func (r *LoanRepository) GetInvestments(ctx context.Context, filter model.InvestmentFilter) ([]*model.Investment, error) {
+	column, ok := investmentSortColumns[filter.Sort.Field]
+	if !ok {
+		return nil, model.ErrSortFieldInvalid
+	}
+
+	c := investmentConditions(filter)
+	err := c.addAfter(column, filter.Sort, filter.Cursor)
+	if err != nil {
+		return nil, err
+	}
+
 	query := `
 		SELECT
-			id, loan_id, investor_id, amount, agreement_letter, created_at, last_updated_at
+			id, loan_id, investor_id, amount, currency,
+			settlement_amount, settlement_currency, fx_rate,
+			agreement_letter, agreement_checksum, created_at, last_updated_at
 		FROM
 			investments
-		WHERE
-			investor_id = ?
-		LIMIT ? OFFSET ?
+		` + c.where() + `
+		` + orderBy(column, filter.Sort) + `
+		LIMIT ?
 	`
 
-	rows, err := r.DB.QueryContext(ctx, query, investorID, limit, offset)
+	rows, err := r.conn(ctx).QueryContext(ctx, query, append(c.args, filter.Limit)...)
 	if err != nil {
 		return nil, err
 	}
@@ -63,16 +71,7 @@ func (r *LoanRepository) GetInvestmentsByInvestorID(ctx context.Context, investo
 
 	investments := []*model.Investment{}
 	for rows.Next() {
-		investment := &model.Investment{}
-		err = rows.Scan(
-			&investment.ID,
-			&investment.LoanID,
-			&investment.InvestorID,
-			&investment.Amount,
-			&investment.AgreementLetter,
-			&investment.CreatedAt,
-			&investment.LastUpdatedAt,
-		)
+		investment, err := scanInvestment(rows)
 		if err != nil {
 			return nil, err
 		}
@@ -83,17 +82,42 @@ func (r *LoanRepository) GetInvestmentsByInvestorID(ctx context.Context, investo
 	return investments, nil
 }
 
+// CountInvestments counts the investments matching the filter, ignoring its
+// cursor.
+func (r *LoanRepository) CountInvestments(ctx context.Context, filter model.InvestmentFilter) (int, error) {
+	c := investmentConditions(filter)
+	query := `
+		SELECT
+			COUNT(*)
+		FROM
+			investments
+		` + c.where() + `
+	`
+
+	var count int
+	err := r.conn(ctx).QueryRowContext(ctx, query, c.args...).Scan(&count)
+	return count, err
+}
+
 func (r *LoanRepository) CreateInvestment(ctx context.Context, investment *model.Investment) (id int64, err error) {
 	query := `
-		INSERT INTO investments (loan_id, investor_id, amount, agreement_letter)
-		VALUES (?, ?, ?, ?)
+		INSERT INTO investments (
+			loan_id, investor_id, amount, currency,
+			settlement_amount, settlement_currency, fx_rate,
+			agreement_letter, agreement_checksum
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
 
-	res, err := r.DB.ExecContext(ctx, query,
+	res, err := r.conn(ctx).ExecContext(ctx, query,
 		investment.LoanID,
 		investment.InvestorID,
 		investment.Amount,
+		investment.Currency,
+		investment.SettlementAmount,
+		investment.SettlementCurrency,
+		investment.FXRate,
 		investment.AgreementLetter,
+		investment.AgreementChecksum,
 	)
 
 	if err != nil {
@@ -102,3 +126,72 @@ func (r *LoanRepository) CreateInvestment(ctx context.Context, investment *model
 
 	return res.LastInsertId()
 }
+
+// GetHoldingsByInvestorID returns every investment of the investor with the
+// state, borrower, product and ROI of its loan.
+func (r *LoanRepository) GetHoldingsByInvestorID(ctx context.Context, investorID int64) ([]*model.Holding, error) {
+	query := `
+		SELECT
+			i.id, i.loan_id, i.amount, i.currency, l.state, l.borrower_id, l.loan_product_id, l.roi
+		FROM
+			investments i
+			JOIN loans l ON l.id = i.loan_id
+		WHERE
+			i.investor_id = ?
+		ORDER BY i.id
+	`
+
+	rows, err := r.conn(ctx).QueryContext(ctx, query, investorID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	holdings := []*model.Holding{}
+	for rows.Next() {
+		holding := &model.Holding{}
+		err = rows.Scan(
+			&holding.InvestmentID,
+			&holding.LoanID,
+			&holding.Amount,
+			&holding.Currency,
+			&holding.LoanState,
+			&holding.BorrowerID,
+			&holding.LoanProductID,
+			&holding.ROI,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		holdings = append(holdings, holding)
+	}
+
+	return holdings, nil
+}
+
+// scanInvestment scans a row selected with the investment columns in table
+// order.
+func scanInvestment(row scanner) (*model.Investment, error) {
+	investment := &model.Investment{}
+	err := row.Scan(
+		&investment.ID,
+		&investment.LoanID,
+		&investment.InvestorID,
+		&investment.Amount,
+		&investment.Currency,
+		&investment.SettlementAmount,
+		&investment.SettlementCurrency,
+		&investment.FXRate,
+		&investment.AgreementLetter,
+		&investment.AgreementChecksum,
+		&investment.CreatedAt,
+		&investment.LastUpdatedAt,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return investment, nil
+}
@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"database/sql"
 	"reflect"
 	"regexp"
 	"testing"
@@ -9,6 +10,7 @@ import (
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/aldipi/loan-service/model"
+	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -22,13 +24,15 @@ func TestGetInvestmentsByLoanID(t *testing.T) {
 	createdAt, _ := time.Parse("2006-01-02 15:04:05", "2021-01-01 00:00:00")
 	lastUpdatedAt, _ := time.Parse("2006-01-02 15:04:05", "2021-01-01 00:00:00")
 
-	rows := sqlmock.NewRows([]string{"id", "loan_id", "investor_id", "amount", "agreement_letter", "created_at", "last_updated_at"}).
-		AddRow(1, 1, 123, 200000, "https://file.io/123/agreement_letter.pdf", createdAt, lastUpdatedAt).
-		AddRow(2, 1, 456, 300000, "https://file.io/456/agreement_letter.pdf", createdAt, lastUpdatedAt)
+	rows := sqlmock.NewRows([]string{"id", "loan_id", "investor_id", "amount", "currency", "settlement_amount", "settlement_currency", "fx_rate", "agreement_letter", "agreement_checksum", "created_at", "last_updated_at"}).
+		AddRow(1, 1, 123, 200000, "IDR", 200000, "IDR", "1", "https://file.io/123/agreement_letter.pdf", "", createdAt, lastUpdatedAt).
+		AddRow(2, 1, 456, 300000, "IDR", 300000, "IDR", "1", "https://file.io/456/agreement_letter.pdf", "", createdAt, lastUpdatedAt)
 
 	query := regexp.QuoteMeta(`
 		SELECT
-			id, loan_id, investor_id, amount, agreement_letter, created_at, last_updated_at
+			id, loan_id, investor_id, amount, currency,
+			settlement_amount, settlement_currency, fx_rate,
+			agreement_letter, agreement_checksum, created_at, last_updated_at
 		FROM
 			investments
 		WHERE
@@ -43,22 +47,30 @@ func TestGetInvestmentsByLoanID(t *testing.T) {
 	assert.NotEmpty(t, investments)
 	assert.Len(t, investments, 2)
 	assert.True(t, reflect.DeepEqual(investments[0], &model.Investment{
-		ID:              1,
-		LoanID:          1,
-		InvestorID:      123,
-		Amount:          200000,
-		AgreementLetter: "https://file.io/123/agreement_letter.pdf",
-		CreatedAt:       createdAt,
-		LastUpdatedAt:   lastUpdatedAt,
+		ID:                 1,
+		LoanID:             1,
+		InvestorID:         123,
+		Amount:             200000,
+		Currency:           "IDR",
+		SettlementAmount:   200000,
+		SettlementCurrency: "IDR",
+		FXRate:             decimal.RequireFromString("1"),
+		AgreementLetter:    "https://file.io/123/agreement_letter.pdf",
+		CreatedAt:          createdAt,
+		LastUpdatedAt:      lastUpdatedAt,
 	}))
 	assert.True(t, reflect.DeepEqual(investments[1], &model.Investment{
-		ID:              2,
-		LoanID:          1,
-		InvestorID:      456,
-		Amount:          300000,
-		AgreementLetter: "https://file.io/456/agreement_letter.pdf",
-		CreatedAt:       createdAt,
-		LastUpdatedAt:   lastUpdatedAt,
+		ID:                 2,
+		LoanID:             1,
+		InvestorID:         456,
+		Amount:             300000,
+		Currency:           "IDR",
+		SettlementAmount:   300000,
+		SettlementCurrency: "IDR",
+		FXRate:             decimal.RequireFromString("1"),
+		AgreementLetter:    "https://file.io/456/agreement_letter.pdf",
+		CreatedAt:          createdAt,
+		LastUpdatedAt:      lastUpdatedAt,
 	}))
 }
 
@@ -69,11 +81,13 @@ func TestGetInvestmentsByLoanIDReturnEmpty(t *testing.T) {
 
 	repo := NewLoanRepository(db)
 
-	rows := sqlmock.NewRows([]string{"id", "loan_id", "investor_id", "amount", "agreement_letter", "created_at", "last_updated_at"})
+	rows := sqlmock.NewRows([]string{"id", "loan_id", "investor_id", "amount", "currency", "settlement_amount", "settlement_currency", "fx_rate", "agreement_letter", "agreement_checksum", "created_at", "last_updated_at"})
 
 	query := regexp.QuoteMeta(`
 		SELECT
-			id, loan_id, investor_id, amount, agreement_letter, created_at, last_updated_at
+			id, loan_id, investor_id, amount, currency,
+			settlement_amount, settlement_currency, fx_rate,
+			agreement_letter, agreement_checksum, created_at, last_updated_at
 		FROM
 			investments
 		WHERE
@@ -88,7 +102,7 @@ func TestGetInvestmentsByLoanIDReturnEmpty(t *testing.T) {
 	assert.Empty(t, investments)
 }
 
-func TestGetInvestmentsByInvestorID(t *testing.T) {
+func TestGetInvestments(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	assert.NoError(t, err)
 	defer db.Close()
@@ -98,74 +112,160 @@ func TestGetInvestmentsByInvestorID(t *testing.T) {
 	createdAt, _ := time.Parse("2006-01-02 15:04:05", "2021-01-01 00:00:00")
 	lastUpdatedAt, _ := time.Parse("2006-01-02 15:04:05", "2021-01-01 00:00:00")
 
-	rows := sqlmock.NewRows([]string{"id", "loan_id", "investor_id", "amount", "agreement_letter", "created_at", "last_updated_at"}).
-		AddRow(1, 1, 123, 200000, "https://file.io/123/agreement_letter.pdf", createdAt, lastUpdatedAt).
-		AddRow(5, 2, 123, 300000, "https://file.io/456/agreement_letter.pdf", createdAt, lastUpdatedAt)
+	rows := sqlmock.NewRows([]string{"id", "loan_id", "investor_id", "amount", "currency", "settlement_amount", "settlement_currency", "fx_rate", "agreement_letter", "agreement_checksum", "created_at", "last_updated_at"}).
+		AddRow(1, 1, 123, 200000, "IDR", 200000, "IDR", "1", "https://file.io/123/agreement_letter.pdf", "", createdAt, lastUpdatedAt).
+		AddRow(5, 2, 123, 300000, "IDR", 300000, "IDR", "1", "https://file.io/456/agreement_letter.pdf", "", createdAt, lastUpdatedAt)
 
 	query := regexp.QuoteMeta(`
 		SELECT
-			id, loan_id, investor_id, amount, agreement_letter, created_at, last_updated_at
+			id, loan_id, investor_id, amount, currency,
+			settlement_amount, settlement_currency, fx_rate,
+			agreement_letter, agreement_checksum, created_at, last_updated_at
 		FROM
 			investments
-		WHERE
-			investor_id = ?
-		LIMIT ? OFFSET ?
+		WHERE investor_id = ?
+		ORDER BY id ASC
+		LIMIT ?
 	`)
 
-	mock.ExpectQuery(query).WithArgs(123, 10, 0).WillReturnRows(rows)
+	mock.ExpectQuery(query).WithArgs(123, 10).WillReturnRows(rows)
 
-	investments, err := repo.GetInvestmentsByInvestorID(context.Background(), 123, 10, 0)
+	investments, err := repo.GetInvestments(context.Background(), model.InvestmentFilter{
+		InvestorID: 123,
+		Sort:       model.Sort{Field: model.SortByID},
+		Limit:      10,
+	})
 
 	assert.NoError(t, err)
 	assert.NotEmpty(t, investments)
 	assert.Len(t, investments, 2)
 	assert.True(t, reflect.DeepEqual(investments[0], &model.Investment{
-		ID:              1,
-		LoanID:          1,
-		InvestorID:      123,
-		Amount:          200000,
-		AgreementLetter: "https://file.io/123/agreement_letter.pdf",
-		CreatedAt:       createdAt,
-		LastUpdatedAt:   lastUpdatedAt,
+		ID:                 1,
+		LoanID:             1,
+		InvestorID:         123,
+		Amount:             200000,
+		Currency:           "IDR",
+		SettlementAmount:   200000,
+		SettlementCurrency: "IDR",
+		FXRate:             decimal.RequireFromString("1"),
+		AgreementLetter:    "https://file.io/123/agreement_letter.pdf",
+		CreatedAt:          createdAt,
+		LastUpdatedAt:      lastUpdatedAt,
 	}))
 	assert.True(t, reflect.DeepEqual(investments[1], &model.Investment{
-		ID:              5,
-		LoanID:          2,
-		InvestorID:      123,
-		Amount:          300000,
-		AgreementLetter: "https://file.io/456/agreement_letter.pdf",
-		CreatedAt:       createdAt,
-		LastUpdatedAt:   lastUpdatedAt,
+		ID:                 5,
+		LoanID:             2,
+		InvestorID:         123,
+		Amount:             300000,
+		Currency:           "IDR",
+		SettlementAmount:   300000,
+		SettlementCurrency: "IDR",
+		FXRate:             decimal.RequireFromString("1"),
+		AgreementLetter:    "https://file.io/456/agreement_letter.pdf",
+		CreatedAt:          createdAt,
+		LastUpdatedAt:      lastUpdatedAt,
 	}))
 }
 
-func TestGetInvestmentsByInvestorIDReturnEmpty(t *testing.T) {
+func TestGetInvestmentsReturnEmpty(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	assert.NoError(t, err)
 	defer db.Close()
 
 	repo := NewLoanRepository(db)
 
-	rows := sqlmock.NewRows([]string{"id", "loan_id", "investor_id", "amount", "agreement_letter", "created_at", "last_updated_at"})
+	rows := sqlmock.NewRows([]string{"id", "loan_id", "investor_id", "amount", "currency", "settlement_amount", "settlement_currency", "fx_rate", "agreement_letter", "agreement_checksum", "created_at", "last_updated_at"})
 
 	query := regexp.QuoteMeta(`
 		SELECT
-			id, loan_id, investor_id, amount, agreement_letter, created_at, last_updated_at
+			id, loan_id, investor_id, amount, currency,
+			settlement_amount, settlement_currency, fx_rate,
+			agreement_letter, agreement_checksum, created_at, last_updated_at
 		FROM
 			investments
-		WHERE
-			investor_id = ?
-		LIMIT ? OFFSET ?
+		WHERE investor_id = ?
+		ORDER BY id ASC
+		LIMIT ?
 	`)
 
-	mock.ExpectQuery(query).WithArgs(123, 10, 0).WillReturnRows(rows)
+	mock.ExpectQuery(query).WithArgs(123, 10).WillReturnRows(rows)
 
-	investments, err := repo.GetInvestmentsByInvestorID(context.Background(), 123, 10, 0)
+	investments, err := repo.GetInvestments(context.Background(), model.InvestmentFilter{
+		InvestorID: 123,
+		Sort:       model.Sort{Field: model.SortByID},
+		Limit:      10,
+	})
 
 	assert.NoError(t, err)
 	assert.Empty(t, investments)
 }
 
+func TestGetInvestmentsAfterCursor(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewLoanRepository(db)
+
+	createdAt, _ := time.Parse("2006-01-02 15:04:05", "2021-01-01 00:00:00")
+
+	rows := sqlmock.NewRows([]string{"id", "loan_id", "investor_id", "amount", "currency", "settlement_amount", "settlement_currency", "fx_rate", "agreement_letter", "agreement_checksum", "created_at", "last_updated_at"}).
+		AddRow(7, 2, 123, 300000, "IDR", 300000, "IDR", "1", "https://file.io/456/agreement_letter.pdf", "", createdAt, createdAt)
+
+	query := regexp.QuoteMeta(`
+		SELECT
+			id, loan_id, investor_id, amount, currency,
+			settlement_amount, settlement_currency, fx_rate,
+			agreement_letter, agreement_checksum, created_at, last_updated_at
+		FROM
+			investments
+		WHERE investor_id = ? AND loan_id = ? AND (amount > ? OR (amount = ? AND id > ?))
+		ORDER BY amount ASC, id ASC
+		LIMIT ?
+	`)
+
+	mock.ExpectQuery(query).WithArgs(123, 2, 200000, 200000, 5, 10).WillReturnRows(rows)
+
+	investments, err := repo.GetInvestments(context.Background(), model.InvestmentFilter{
+		InvestorID: 123,
+		LoanID:     2,
+		Sort:       model.Sort{Field: model.SortByAmount},
+		Cursor:     &model.Cursor{Sort: "amount", Value: "200000", ID: 5},
+		Limit:      10,
+	})
+
+	assert.NoError(t, err)
+	assert.Len(t, investments, 1)
+	assert.Equal(t, int64(7), investments[0].ID)
+}
+
+func TestCountInvestments(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewLoanRepository(db)
+
+	createdTo := time.Date(2021, 2, 1, 0, 0, 0, 0, time.UTC)
+
+	query := regexp.QuoteMeta(`
+		SELECT
+			COUNT(*)
+		FROM
+			investments
+		WHERE investor_id = ? AND created_at < ?
+	`)
+
+	mock.ExpectQuery(query).
+		WithArgs(123, createdTo).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+
+	count, err := repo.CountInvestments(context.Background(), model.InvestmentFilter{InvestorID: 123, CreatedTo: createdTo})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 3, count)
+}
+
 func TestCreateInvestment(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	assert.NoError(t, err)
@@ -173,22 +273,30 @@ func TestCreateInvestment(t *testing.T) {
 
 	repo := NewLoanRepository(db)
 
+	checksum := "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b4b0b822cd15d6c15b0f00a08"
+
 	query := regexp.QuoteMeta(`
-		INSERT INTO investments
-			(loan_id, investor_id, amount, agreement_letter)
-		VALUES
-			(?, ?, ?, ?)
+		INSERT INTO investments (
+			loan_id, investor_id, amount, currency,
+			settlement_amount, settlement_currency, fx_rate,
+			agreement_letter, agreement_checksum
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`)
 
 	mock.ExpectExec(query).
-		WithArgs(1, 123, 200000, "https://file.io/123/agreement_letter.pdf").
+		WithArgs(1, 123, 200000, "IDR", 17, "SGD", "0.000085", "https://file.io/123/agreement_letter.pdf", checksum).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
 	investment := &model.Investment{
-		LoanID:          1,
-		InvestorID:      123,
-		Amount:          200000,
-		AgreementLetter: "https://file.io/123/agreement_letter.pdf",
+		LoanID:             1,
+		InvestorID:         123,
+		Amount:             200000,
+		Currency:           "IDR",
+		SettlementAmount:   17,
+		SettlementCurrency: "SGD",
+		FXRate:             decimal.RequireFromString("0.000085"),
+		AgreementLetter:    "https://file.io/123/agreement_letter.pdf",
+		AgreementChecksum:  checksum,
 	}
 
 	id, err := repo.CreateInvestment(context.Background(), investment)
@@ -196,3 +304,53 @@ func TestCreateInvestment(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, int64(1), id)
 }
+
+func TestGetHoldingsByInvestorID(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewLoanRepository(db)
+
+	rows := sqlmock.NewRows([]string{"id", "loan_id", "amount", "currency", "state", "borrower_id", "loan_product_id", "roi"}).
+		AddRow(1, 10, 200000, "IDR", model.LoanStateDisbursed, 123, 7, "5.50").
+		AddRow(2, 11, 300000, "IDR", model.LoanStateApproved, 456, nil, "3.00")
+
+	query := regexp.QuoteMeta(`
+		SELECT
+			i.id, i.loan_id, i.amount, i.currency, l.state, l.borrower_id, l.loan_product_id, l.roi
+		FROM
+			investments i
+			JOIN loans l ON l.id = i.loan_id
+		WHERE
+			i.investor_id = ?
+		ORDER BY i.id
+	`)
+
+	mock.ExpectQuery(query).WithArgs(100).WillReturnRows(rows)
+
+	holdings, err := repo.GetHoldingsByInvestorID(context.Background(), 100)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []*model.Holding{
+		{
+			InvestmentID:  1,
+			LoanID:        10,
+			Amount:        200000,
+			Currency:      "IDR",
+			LoanState:     model.LoanStateDisbursed,
+			BorrowerID:    123,
+			LoanProductID: sql.NullInt64{Int64: 7, Valid: true},
+			ROI:           decimal.RequireFromString("5.50"),
+		},
+		{
+			InvestmentID: 2,
+			LoanID:       11,
+			Amount:       300000,
+			Currency:     "IDR",
+			LoanState:    model.LoanStateApproved,
+			BorrowerID:   456,
+			ROI:          decimal.RequireFromString("3.00"),
+		},
+	}, holdings)
+}
@@ -9,19 +9,22 @@ import (
 func (r *LoanRepository) GetInvestorByID(ctx context.Context, id int64) (*model.Investor, error) {
 	query := `
 		SELECT
-			id, name, created_at, last_updated_at
+			id, name, email, locale, currency, created_at, last_updated_at
 		FROM
 			investors
 		WHERE
 			id = ?
 	`
 
-	row := r.DB.QueryRowContext(ctx, query, id)
+	row := r.conn(ctx).QueryRowContext(ctx, query, id)
 
 	investor := &model.Investor{}
 	err := row.Scan(
 		&investor.ID,
 		&investor.Name,
+		&investor.Email,
+		&investor.Locale,
+		&investor.Currency,
 		&investor.CreatedAt,
 		&investor.LastUpdatedAt,
 	)
@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"database/sql"
 	"reflect"
 	"testing"
 	"time"
@@ -20,10 +21,10 @@ func TestGetInvestorByID(t *testing.T) {
 
 	createdAt, _ := time.Parse("2006-01-02 15:04:05", "2021-01-01 00:00:00")
 	lastUpdatedAt, _ := time.Parse("2006-01-02 15:04:05", "2021-01-01 00:00:00")
-	rows := sqlmock.NewRows([]string{"id", "name", "created_at", "last_updated_at"}).
-		AddRow(1, "Investor A", createdAt, lastUpdatedAt)
+	rows := sqlmock.NewRows([]string{"id", "name", "email", "locale", "currency", "created_at", "last_updated_at"}).
+		AddRow(1, "Investor A", "investor.a@example.com", "id", "IDR", createdAt, lastUpdatedAt)
 
-	mock.ExpectQuery("SELECT id, name, created_at, last_updated_at FROM investors WHERE id = ?").
+	mock.ExpectQuery("SELECT id, name, email, locale, currency, created_at, last_updated_at FROM investors WHERE id = ?").
 		WithArgs(1).
 		WillReturnRows(rows)
 
@@ -34,6 +35,9 @@ func TestGetInvestorByID(t *testing.T) {
 	assert.True(t, reflect.DeepEqual(investor, &model.Investor{
 		ID:            1,
 		Name:          "Investor A",
+		Email:         sql.NullString{String: "investor.a@example.com", Valid: true},
+		Locale:        "id",
+		Currency:      "IDR",
 		CreatedAt:     createdAt,
 		LastUpdatedAt: lastUpdatedAt,
 	}))
@@ -0,0 +1,132 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/aldipi/loan-service/model"
+)
+
+// CreateJournalEntry stores the entry together with its lines.
+func (r *LoanRepository) CreateJournalEntry(ctx context.Context, entry *model.JournalEntry) (id int64, err error) {
+	err = r.WithTransaction(ctx, func(ctx context.Context) error {
+		query := `
+			INSERT INTO journal_entries (kind, currency, loan_id, investment_id, created_at)
+			VALUES (?, ?, ?, ?, ?)
+		`
+
+		res, err := r.conn(ctx).ExecContext(ctx, query,
+			entry.Kind,
+			entry.Currency,
+			entry.LoanID,
+			entry.InvestmentID,
+			entry.CreatedAt,
+		)
+		if err != nil {
+			return err
+		}
+
+		id, err = res.LastInsertId()
+		if err != nil {
+			return err
+		}
+
+		for _, line := range entry.Lines {
+			line.JournalEntryID = id
+			res, err = r.conn(ctx).ExecContext(ctx, `
+				INSERT INTO journal_lines (journal_entry_id, account, owner_id, debit, credit)
+				VALUES (?, ?, ?, ?, ?)
+			`,
+				line.JournalEntryID,
+				line.Account,
+				line.OwnerID,
+				line.Debit,
+				line.Credit,
+			)
+			if err != nil {
+				return err
+			}
+
+			line.ID, err = res.LastInsertId()
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	return id, err
+}
+
+// GetAccountBalances totals the journal lines of every account of the ledger
+// in each currency.
+func (r *LoanRepository) GetAccountBalances(ctx context.Context) ([]*model.AccountBalance, error) {
+	query := `
+		SELECT
+			l.account, l.owner_id, e.currency, SUM(l.debit), SUM(l.credit)
+		FROM
+			journal_lines l
+			JOIN journal_entries e ON e.id = l.journal_entry_id
+		GROUP BY l.account, l.owner_id, e.currency
+		ORDER BY l.account, l.owner_id, e.currency
+	`
+
+	rows, err := r.conn(ctx).QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	balances := []*model.AccountBalance{}
+	for rows.Next() {
+		balance := &model.AccountBalance{}
+		err = rows.Scan(
+			&balance.Account,
+			&balance.OwnerID,
+			&balance.Currency,
+			&balance.Debit,
+			&balance.Credit,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		balance.Balance = balance.Debit - balance.Credit
+		balances = append(balances, balance)
+	}
+
+	return balances, nil
+}
+
+// GetUnbalancedJournalEntryIDs returns the IDs of the journal entries whose
+// debits do not equal their credits.
+func (r *LoanRepository) GetUnbalancedJournalEntryIDs(ctx context.Context) ([]int64, error) {
+	query := `
+		SELECT
+			journal_entry_id
+		FROM
+			journal_lines
+		GROUP BY journal_entry_id
+		HAVING SUM(debit) <> SUM(credit)
+		ORDER BY journal_entry_id
+	`
+
+	rows, err := r.conn(ctx).QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int64{}
+	for rows.Next() {
+		var id int64
+		err = rows.Scan(&id)
+		if err != nil {
+			return nil, err
+		}
+
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
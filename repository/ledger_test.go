@@ -0,0 +1,114 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/aldipi/loan-service/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateJournalEntry(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewLoanRepository(db)
+
+	createdAt := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	entry := &model.JournalEntry{
+		Kind:         model.JournalInvestment,
+		Currency:     model.DefaultCurrency,
+		LoanID:       sql.NullInt64{Int64: 1, Valid: true},
+		InvestmentID: sql.NullInt64{Int64: 3, Valid: true},
+		CreatedAt:    createdAt,
+	}
+	entry.Debit(model.AccountInvestorCash, 100, 500000)
+	entry.Credit(model.AccountInvestorReserved, 100, 500000)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO journal_entries (kind, currency, loan_id, investment_id, created_at) VALUES (?, ?, ?, ?, ?)")).
+		WithArgs(model.JournalInvestment, model.DefaultCurrency, entry.LoanID, entry.InvestmentID, createdAt).
+		WillReturnResult(sqlmock.NewResult(7, 1))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO journal_lines (journal_entry_id, account, owner_id, debit, credit) VALUES (?, ?, ?, ?, ?)")).
+		WithArgs(7, model.AccountInvestorCash, 100, 500000, 0).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO journal_lines (journal_entry_id, account, owner_id, debit, credit) VALUES (?, ?, ?, ?, ?)")).
+		WithArgs(7, model.AccountInvestorReserved, 100, 0, 500000).
+		WillReturnResult(sqlmock.NewResult(2, 1))
+	mock.ExpectCommit()
+
+	id, err := repo.CreateJournalEntry(context.Background(), entry)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(7), id)
+	assert.Equal(t, int64(7), entry.Lines[1].JournalEntryID)
+	assert.Equal(t, int64(2), entry.Lines[1].ID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCreateJournalEntryRollsBackOnLineError(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewLoanRepository(db)
+
+	entry := &model.JournalEntry{Kind: model.JournalDeposit}
+	entry.Debit(model.AccountSettlement, 0, 500000)
+	entry.Credit(model.AccountInvestorCash, 100, 500000)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO journal_entries")).
+		WillReturnResult(sqlmock.NewResult(7, 1))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO journal_lines")).
+		WillReturnError(errors.New("connection lost"))
+	mock.ExpectRollback()
+
+	_, err = repo.CreateJournalEntry(context.Background(), entry)
+
+	assert.EqualError(t, err, "connection lost")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetAccountBalances(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewLoanRepository(db)
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT l.account, l.owner_id, e.currency, SUM(l.debit), SUM(l.credit) FROM journal_lines l JOIN journal_entries e ON e.id = l.journal_entry_id GROUP BY l.account, l.owner_id, e.currency ORDER BY l.account, l.owner_id, e.currency")).
+		WillReturnRows(sqlmock.NewRows([]string{"account", "owner_id", "currency", "debit", "credit"}).
+			AddRow("investor_cash", 100, "IDR", 200000, 500000).
+			AddRow("settlement", 0, "IDR", 500000, 200000))
+
+	balances, err := repo.GetAccountBalances(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, []*model.AccountBalance{
+		{Account: model.AccountInvestorCash, OwnerID: 100, Currency: "IDR", Debit: 200000, Credit: 500000, Balance: -300000},
+		{Account: model.AccountSettlement, OwnerID: 0, Currency: "IDR", Debit: 500000, Credit: 200000, Balance: 300000},
+	}, balances)
+}
+
+func TestGetUnbalancedJournalEntryIDs(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewLoanRepository(db)
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT journal_entry_id FROM journal_lines GROUP BY journal_entry_id HAVING SUM(debit) <> SUM(credit) ORDER BY journal_entry_id")).
+		WillReturnRows(sqlmock.NewRows([]string{"journal_entry_id"}).AddRow(4).AddRow(9))
+
+	ids, err := repo.GetUnbalancedJournalEntryIDs(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{4, 9}, ids)
+}
@@ -0,0 +1,154 @@
+package repository
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aldipi/loan-service/model"
+)
+
+// sortColumn is a column listings can be sorted by, with the parser of the
+// cursor values of its sort field.
+type sortColumn struct {
+	name  string
+	parse func(value string) (any, error)
+}
+
+var (
+	idColumn        = sortColumn{name: "id"}
+	createdAtColumn = sortColumn{name: "created_at", parse: parseTimeValue}
+)
+
+var loanSortColumns = map[string]sortColumn{
+	model.SortByID:              idColumn,
+	model.SortByCreatedAt:       createdAtColumn,
+	model.SortByPrincipalAmount: {name: "principal_amount", parse: parseInt64Value},
+}
+
+var investmentSortColumns = map[string]sortColumn{
+	model.SortByID:        idColumn,
+	model.SortByCreatedAt: createdAtColumn,
+	model.SortByAmount:    {name: "amount", parse: parseInt64Value},
+}
+
+func parseTimeValue(value string) (any, error) {
+	return time.Parse(time.RFC3339Nano, value)
+}
+
+func parseInt64Value(value string) (any, error) {
+	return strconv.ParseInt(value, 10, 64)
+}
+
+// conditions collects the WHERE clause of a listing query with its arguments.
+type conditions struct {
+	clauses []string
+	args    []any
+}
+
+func (c *conditions) add(clause string, args ...any) {
+	c.clauses = append(c.clauses, clause)
+	c.args = append(c.args, args...)
+}
+
+func (c *conditions) addRange(column string, min int64, max int64) {
+	if min != 0 {
+		c.add(column+" >= ?", min)
+	}
+	if max != 0 {
+		c.add(column+" <= ?", max)
+	}
+}
+
+// addTimeRange selects the times from the start up to, but excluding, the end.
+func (c *conditions) addTimeRange(column string, from time.Time, to time.Time) {
+	if !from.IsZero() {
+		c.add(column+" >= ?", from)
+	}
+	if !to.IsZero() {
+		c.add(column+" < ?", to)
+	}
+}
+
+// addAfter selects the rows following the cursor in the sort order.
+func (c *conditions) addAfter(column sortColumn, sort model.Sort, cursor *model.Cursor) error {
+	if cursor == nil {
+		return nil
+	}
+
+	if cursor.Sort != sort.String() {
+		return model.ErrCursorInvalid
+	}
+
+	operator := ">"
+	if sort.Desc {
+		operator = "<"
+	}
+
+	if column.parse == nil {
+		c.add("id "+operator+" ?", cursor.ID)
+		return nil
+	}
+
+	value, err := column.parse(cursor.Value)
+	if err != nil {
+		return model.ErrCursorInvalid
+	}
+
+	c.add("("+column.name+" "+operator+" ? OR ("+column.name+" = ? AND id "+operator+" ?))", value, value, cursor.ID)
+	return nil
+}
+
+func (c *conditions) where() string {
+	if len(c.clauses) == 0 {
+		return ""
+	}
+
+	return "WHERE " + strings.Join(c.clauses, " AND ")
+}
+
+// orderBy returns the ORDER BY clause of the sort, with the ID breaking ties.
+func orderBy(column sortColumn, sort model.Sort) string {
+	direction := "ASC"
+	if sort.Desc {
+		direction = "DESC"
+	}
+
+	if column.parse == nil {
+		return "ORDER BY id " + direction
+	}
+
+	return "ORDER BY " + column.name + " " + direction + ", id " + direction
+}
+
+func loanConditions(filter model.LoanFilter) *conditions {
+	c := &conditions{}
+	if filter.State != nil {
+		c.add("state = ?", *filter.State)
+	}
+	if filter.BorrowerID != 0 {
+		c.add("borrower_id = ?", filter.BorrowerID)
+	}
+	if filter.LoanProductID != 0 {
+		c.add("loan_product_id = ?", filter.LoanProductID)
+	}
+	c.addRange("principal_amount", filter.MinAmount, filter.MaxAmount)
+	c.addTimeRange("created_at", filter.CreatedFrom, filter.CreatedTo)
+	c.addTimeRange("approved_at", filter.ApprovedFrom, filter.ApprovedTo)
+
+	return c
+}
+
+func investmentConditions(filter model.InvestmentFilter) *conditions {
+	c := &conditions{}
+	if filter.InvestorID != 0 {
+		c.add("investor_id = ?", filter.InvestorID)
+	}
+	if filter.LoanID != 0 {
+		c.add("loan_id = ?", filter.LoanID)
+	}
+	c.addRange("amount", filter.MinAmount, filter.MaxAmount)
+	c.addTimeRange("created_at", filter.CreatedFrom, filter.CreatedTo)
+
+	return c
+}
@@ -9,8 +9,9 @@ import (
 func (r *LoanRepository) GetLoanByID(ctx context.Context, id int64) (*model.Loan, error) {
 	query := `
 		SELECT
-			id, state, borrower_id, principal_amount, rate, roi,
+			id, state, borrower_id, loan_product_id, principal_amount, currency, rate, roi,
 			approval_proof, approved_by, agreement_letter, disbursed_by,
+			borrower_agreement, borrower_agreement_checksum,
 			created_at, approved_at, invested_at, disbursed_at, last_updated_at
 		FROM
 			loans
@@ -18,147 +19,157 @@ func (r *LoanRepository) GetLoanByID(ctx context.Context, id int64) (*model.Loan
 			id = ?
 	`
 
-	row := r.DB.QueryRowContext(ctx, query, id)
+	return scanLoan(r.conn(ctx).QueryRowContext(ctx, query, id))
+}
 
-	loan := &model.Loan{}
-	err := row.Scan(
-		&loan.ID,
-		&loan.State,
-		&loan.BorrowerID,
-		&loan.PrincipalAmount,
-		&loan.Rate,
-		&loan.ROI,
-		&loan.ApprovalProof,
-		&loan.ApprovedBy,
-		&loan.AgreementLetter,
-		&loan.DisbursedBy,
-		&loan.CreatedAt,
-		&loan.ApprovedAt,
-		&loan.InvestedAt,
-		&loan.DisbursedAt,
-		&loan.LastUpdatedAt,
-	)
+// LockLoan reads a loan and locks its row until the transaction of ctx ends,
+// so concurrent changes of the loan wait and are validated against this one.
+func (r *LoanRepository) LockLoan(ctx context.Context, id int64) (*model.Loan, error) {
+	query := `
+		SELECT
+			id, state, borrower_id, loan_product_id, principal_amount, currency, rate, roi,
+			approval_proof, approved_by, agreement_letter, disbursed_by,
+			borrower_agreement, borrower_agreement_checksum,
+			created_at, approved_at, invested_at, disbursed_at, last_updated_at
+		FROM
+			loans
+		WHERE
+			id = ?
+		FOR UPDATE
+	`
+
+	return scanLoan(r.conn(ctx).QueryRowContext(ctx, query, id))
+}
+
+// GetLoans returns a page of the loans matching the filter in its sort order,
+// starting after its cursor.
+func (r *LoanRepository) GetLoans(ctx context.Context, filter model.LoanFilter) ([]*model.Loan, error) {
+	column, ok := loanSortColumns[filter.Sort.Field]
+	if !ok {
+		return nil, model.ErrSortFieldInvalid
+	}
 
+	c := loanConditions(filter)
+	err := c.addAfter(column, filter.Sort, filter.Cursor)
 	if err != nil {
 		return nil, err
 	}
 
-	return loan, nil
-}
-
-func (r *LoanRepository) GetLoans(ctx context.Context, limit int, offset int) ([]*model.Loan, error) {
 	query := `
 		SELECT
-			id, state, borrower_id, principal_amount, rate, roi,
+			id, state, borrower_id, loan_product_id, principal_amount, currency, rate, roi,
 			approval_proof, approved_by, agreement_letter, disbursed_by,
+			borrower_agreement, borrower_agreement_checksum,
 			created_at, approved_at, invested_at, disbursed_at, last_updated_at
 		FROM
 			loans
-		LIMIT ? OFFSET ?
+		` + c.where() + `
+		` + orderBy(column, filter.Sort) + `
+		LIMIT ?
 	`
 
-	rows, err := r.DB.QueryContext(ctx, query, limit, offset)
+	rows, err := r.conn(ctx).QueryContext(ctx, query, append(c.args, filter.Limit)...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	loans := []*model.Loan{}
+	return scanLoans(rows)
+}
+
+// CountLoans counts the loans matching the filter, ignoring its cursor.
+func (r *LoanRepository) CountLoans(ctx context.Context, filter model.LoanFilter) (int, error) {
+	c := loanConditions(filter)
+	query := `
+		SELECT
+			COUNT(*)
+		FROM
+			loans
+		` + c.where() + `
+	`
+
+	var count int
+	err := r.conn(ctx).QueryRowContext(ctx, query, c.args...).Scan(&count)
+	return count, err
+}
+
+// CountLoansByState counts the loans in every state. States without loans are
+// left out.
+func (r *LoanRepository) CountLoansByState(ctx context.Context) (map[model.LoanState]int, error) {
+	query := `
+		SELECT
+			state, COUNT(*)
+		FROM
+			loans
+		GROUP BY state
+	`
+
+	rows, err := r.conn(ctx).QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := map[model.LoanState]int{}
 	for rows.Next() {
-		loan := &model.Loan{}
-		err := rows.Scan(
-			&loan.ID,
-			&loan.State,
-			&loan.BorrowerID,
-			&loan.PrincipalAmount,
-			&loan.Rate,
-			&loan.ROI,
-			&loan.ApprovalProof,
-			&loan.ApprovedBy,
-			&loan.AgreementLetter,
-			&loan.DisbursedBy,
-			&loan.CreatedAt,
-			&loan.ApprovedAt,
-			&loan.InvestedAt,
-			&loan.DisbursedAt,
-			&loan.LastUpdatedAt,
-		)
+		var state model.LoanState
+		var count int
+		err = rows.Scan(&state, &count)
 		if err != nil {
 			return nil, err
 		}
 
-		loans = append(loans, loan)
+		counts[state] = count
 	}
 
-	return loans, nil
+	return counts, nil
 }
 
 func (r *LoanRepository) GetLoansByBorrowerID(ctx context.Context, borrowerID int64, limit int, offset int) ([]*model.Loan, error) {
 	query := `
 		SELECT
-			id, state, borrower_id, principal_amount, rate, roi,
+			id, state, borrower_id, loan_product_id, principal_amount, currency, rate, roi,
 			approval_proof, approved_by, agreement_letter, disbursed_by,
+			borrower_agreement, borrower_agreement_checksum,
 			created_at, approved_at, invested_at, disbursed_at, last_updated_at
 		FROM
 			loans
 		WHERE
 			borrower_id = ?
+		ORDER BY id
 		LIMIT ? OFFSET ?
 	`
 
-	rows, err := r.DB.QueryContext(ctx, query, borrowerID, limit, offset)
+	rows, err := r.conn(ctx).QueryContext(ctx, query, borrowerID, limit, offset)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	loans := []*model.Loan{}
-	for rows.Next() {
-		loan := &model.Loan{}
-		err := rows.Scan(
-			&loan.ID,
-			&loan.State,
-			&loan.BorrowerID,
-			&loan.PrincipalAmount,
-			&loan.Rate,
-			&loan.ROI,
-			&loan.ApprovalProof,
-			&loan.ApprovedBy,
-			&loan.AgreementLetter,
-			&loan.DisbursedBy,
-			&loan.CreatedAt,
-			&loan.ApprovedAt,
-			&loan.InvestedAt,
-			&loan.DisbursedAt,
-			&loan.LastUpdatedAt,
-		)
-		if err != nil {
-			return nil, err
-		}
-
-		loans = append(loans, loan)
-	}
-
-	return loans, nil
+	return scanLoans(rows)
 }
 
 func (r *LoanRepository) CreateLoan(ctx context.Context, loan *model.Loan) (id int64, err error) {
 	query := `
         INSERT INTO loans (
-            state, borrower_id, principal_amount, rate, roi
+            state, borrower_id, loan_product_id, principal_amount, currency, rate, roi, created_at, last_updated_at
         ) VALUES (
-            ?, ?, ?, ?, ?
+            ?, ?, ?, ?, ?, ?, ?, ?, ?
         )
     `
 
-	res, err := r.DB.ExecContext(
+	res, err := r.conn(ctx).ExecContext(
 		ctx,
 		query,
 		loan.State,
 		loan.BorrowerID,
+		loan.LoanProductID,
 		loan.PrincipalAmount,
+		loan.Currency,
 		loan.Rate,
 		loan.ROI,
+		loan.CreatedAt,
+		loan.LastUpdatedAt,
 	)
 
 	if err != nil {
@@ -176,14 +187,16 @@ func (r *LoanRepository) UpdateLoan(ctx context.Context, loan *model.Loan) error
 			approved_by = ?,
 			agreement_letter = ?,
 			disbursed_by = ?,
+			borrower_agreement = ?,
+			borrower_agreement_checksum = ?,
 			approved_at = ?,
 			invested_at = ?,
 			disbursed_at = ?,
-			last_updated_at = CURRENT_TIMESTAMP
+			last_updated_at = ?
 		WHERE id = ?
 	`
 
-	_, err := r.DB.ExecContext(
+	_, err := r.conn(ctx).ExecContext(
 		ctx,
 		query,
 		loan.State,
@@ -191,11 +204,105 @@ func (r *LoanRepository) UpdateLoan(ctx context.Context, loan *model.Loan) error
 		loan.ApprovedBy,
 		loan.AgreementLetter,
 		loan.DisbursedBy,
+		loan.BorrowerAgreement,
+		loan.BorrowerAgreementChecksum,
 		loan.ApprovedAt,
 		loan.InvestedAt,
 		loan.DisbursedAt,
+		loan.LastUpdatedAt,
 		loan.ID,
 	)
 
 	return err
 }
+
+// ReplaceLoan writes the full loan row including its ID, overwriting any row
+// with the same ID.
+func (r *LoanRepository) ReplaceLoan(ctx context.Context, loan *model.Loan) error {
+	query := `
+		REPLACE INTO loans (
+			id, state, borrower_id, loan_product_id, principal_amount, currency, rate, roi,
+			approval_proof, approved_by, agreement_letter, disbursed_by,
+			borrower_agreement, borrower_agreement_checksum,
+			created_at, approved_at, invested_at, disbursed_at, last_updated_at
+		) VALUES (
+			?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?
+		)
+	`
+
+	_, err := r.conn(ctx).ExecContext(
+		ctx,
+		query,
+		loan.ID,
+		loan.State,
+		loan.BorrowerID,
+		loan.LoanProductID,
+		loan.PrincipalAmount,
+		loan.Currency,
+		loan.Rate,
+		loan.ROI,
+		loan.ApprovalProof,
+		loan.ApprovedBy,
+		loan.AgreementLetter,
+		loan.DisbursedBy,
+		loan.BorrowerAgreement,
+		loan.BorrowerAgreementChecksum,
+		loan.CreatedAt,
+		loan.ApprovedAt,
+		loan.InvestedAt,
+		loan.DisbursedAt,
+		loan.LastUpdatedAt,
+	)
+
+	return err
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+// scanLoan scans a row selected with the loan columns in table order.
+func scanLoan(row scanner) (*model.Loan, error) {
+	loan := &model.Loan{}
+	err := row.Scan(
+		&loan.ID,
+		&loan.State,
+		&loan.BorrowerID,
+		&loan.LoanProductID,
+		&loan.PrincipalAmount,
+		&loan.Currency,
+		&loan.Rate,
+		&loan.ROI,
+		&loan.ApprovalProof,
+		&loan.ApprovedBy,
+		&loan.AgreementLetter,
+		&loan.DisbursedBy,
+		&loan.BorrowerAgreement,
+		&loan.BorrowerAgreementChecksum,
+		&loan.CreatedAt,
+		&loan.ApprovedAt,
+		&loan.InvestedAt,
+		&loan.DisbursedAt,
+		&loan.LastUpdatedAt,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return loan, nil
+}
+
+func scanLoans(rows sqlRows) ([]*model.Loan, error) {
+	loans := []*model.Loan{}
+	for rows.Next() {
+		loan, err := scanLoan(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		loans = append(loans, loan)
+	}
+
+	return loans, nil
+}
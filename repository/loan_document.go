@@ -0,0 +1,134 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/aldipi/loan-service/model"
+)
+
+func (r *LoanRepository) CreateLoanDocument(ctx context.Context, document *model.LoanDocument) (id int64, err error) {
+	query := `
+		INSERT INTO loan_documents (
+			loan_id, investment_id, kind, location, checksum, content_type, size, status
+		) VALUES (
+			?, ?, ?, ?, ?, ?, ?, ?
+		)
+	`
+
+	res, err := r.conn(ctx).ExecContext(ctx, query,
+		document.LoanID,
+		document.InvestmentID,
+		document.Kind,
+		document.Location,
+		document.Checksum,
+		document.ContentType,
+		document.Size,
+		document.Status,
+	)
+
+	if err != nil {
+		return
+	}
+
+	return res.LastInsertId()
+}
+
+func (r *LoanRepository) GetLoanDocumentByID(ctx context.Context, id int64) (*model.LoanDocument, error) {
+	query := `
+		SELECT
+			id, loan_id, investment_id, kind, location, checksum, content_type, size, status, created_at
+		FROM
+			loan_documents
+		WHERE
+			id = ?
+	`
+
+	return scanLoanDocument(r.conn(ctx).QueryRowContext(ctx, query, id))
+}
+
+func (r *LoanRepository) UpdateLoanDocumentStatus(ctx context.Context, id int64, status model.DocumentStatus) error {
+	query := `
+		UPDATE loan_documents
+		SET status = ?
+		WHERE id = ?
+	`
+
+	_, err := r.conn(ctx).ExecContext(ctx, query, status, id)
+
+	return err
+}
+
+func (r *LoanRepository) GetLoanDocumentsByLoanID(ctx context.Context, loanID int64) ([]*model.LoanDocument, error) {
+	query := `
+		SELECT
+			id, loan_id, investment_id, kind, location, checksum, content_type, size, status, created_at
+		FROM
+			loan_documents
+		WHERE
+			loan_id = ?
+		ORDER BY id
+	`
+
+	rows, err := r.conn(ctx).QueryContext(ctx, query, loanID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanLoanDocuments(rows)
+}
+
+func (r *LoanRepository) GetLoanDocumentsByChecksum(ctx context.Context, checksum string) ([]*model.LoanDocument, error) {
+	query := `
+		SELECT
+			id, loan_id, investment_id, kind, location, checksum, content_type, size, status, created_at
+		FROM
+			loan_documents
+		WHERE
+			checksum = ?
+		ORDER BY id
+	`
+
+	rows, err := r.conn(ctx).QueryContext(ctx, query, checksum)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanLoanDocuments(rows)
+}
+
+func scanLoanDocuments(rows sqlRows) ([]*model.LoanDocument, error) {
+	documents := []*model.LoanDocument{}
+	for rows.Next() {
+		document, err := scanLoanDocument(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		documents = append(documents, document)
+	}
+
+	return documents, nil
+}
+
+func scanLoanDocument(row scanner) (*model.LoanDocument, error) {
+	document := &model.LoanDocument{}
+	err := row.Scan(
+		&document.ID,
+		&document.LoanID,
+		&document.InvestmentID,
+		&document.Kind,
+		&document.Location,
+		&document.Checksum,
+		&document.ContentType,
+		&document.Size,
+		&document.Status,
+		&document.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return document, nil
+}
@@ -0,0 +1,152 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/aldipi/loan-service/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateLoanDocument(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewLoanRepository(db)
+
+	checksum := "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b4b0b822cd15d6c15b0f00a08"
+
+	query := regexp.QuoteMeta(`
+		INSERT INTO loan_documents (
+			loan_id, investment_id, kind, location, checksum, content_type, size, status
+		) VALUES (
+			?, ?, ?, ?, ?, ?, ?, ?
+		)
+	`)
+
+	mock.ExpectExec(query).
+		WithArgs(1, sql.NullInt64{}, model.DocumentApprovalProof, "loans/1/approval_proof/1.png", checksum, "image/png", 2048, model.DocumentAttached).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	id, err := repo.CreateLoanDocument(context.Background(), &model.LoanDocument{
+		LoanID:      1,
+		Kind:        model.DocumentApprovalProof,
+		Location:    "loans/1/approval_proof/1.png",
+		Checksum:    checksum,
+		ContentType: "image/png",
+		Size:        2048,
+		Status:      model.DocumentAttached,
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), id)
+}
+
+func TestGetLoanDocumentsByLoanID(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewLoanRepository(db)
+
+	createdAt := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	rows := sqlmock.NewRows([]string{"id", "loan_id", "investment_id", "kind", "location", "checksum", "content_type", "size", "status", "created_at"}).
+		AddRow(1, 1, nil, model.DocumentApprovalProof, "loans/1/approval_proof/1.png", "abc", "image/png", 2048, model.DocumentAttached, createdAt).
+		AddRow(2, 1, 3, model.DocumentInvestorAgreement, "agreements/loans/1/investors/100/1.pdf", "def", "application/pdf", 1024, model.DocumentPendingSignature, createdAt)
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id, loan_id, investment_id, kind, location, checksum, content_type, size, status, created_at FROM loan_documents WHERE loan_id = ? ORDER BY id")).
+		WithArgs(1).
+		WillReturnRows(rows)
+
+	documents, err := repo.GetLoanDocumentsByLoanID(context.Background(), 1)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []*model.LoanDocument{
+		{
+			ID:          1,
+			LoanID:      1,
+			Kind:        model.DocumentApprovalProof,
+			Location:    "loans/1/approval_proof/1.png",
+			Checksum:    "abc",
+			ContentType: "image/png",
+			Size:        2048,
+			Status:      model.DocumentAttached,
+			CreatedAt:   createdAt,
+		},
+		{
+			ID:           2,
+			LoanID:       1,
+			InvestmentID: sql.NullInt64{Int64: 3, Valid: true},
+			Kind:         model.DocumentInvestorAgreement,
+			Location:     "agreements/loans/1/investors/100/1.pdf",
+			Checksum:     "def",
+			ContentType:  "application/pdf",
+			Size:         1024,
+			Status:       model.DocumentPendingSignature,
+			CreatedAt:    createdAt,
+		},
+	}, documents)
+}
+
+func TestGetLoanDocumentsByChecksum(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewLoanRepository(db)
+
+	rows := sqlmock.NewRows([]string{"id", "loan_id", "investment_id", "kind", "location", "checksum", "content_type", "size", "status", "created_at"})
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id, loan_id, investment_id, kind, location, checksum, content_type, size, status, created_at FROM loan_documents WHERE checksum = ? ORDER BY id")).
+		WithArgs("abc").
+		WillReturnRows(rows)
+
+	documents, err := repo.GetLoanDocumentsByChecksum(context.Background(), "abc")
+
+	assert.NoError(t, err)
+	assert.Empty(t, documents)
+}
+
+func TestGetLoanDocumentByID(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewLoanRepository(db)
+
+	createdAt := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	rows := sqlmock.NewRows([]string{"id", "loan_id", "investment_id", "kind", "location", "checksum", "content_type", "size", "status", "created_at"}).
+		AddRow(5, 1, nil, model.DocumentBorrowerAgreement, "agreements/loans/1/borrower/1.pdf", "abc", "application/pdf", 1024, model.DocumentSigned, createdAt)
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id, loan_id, investment_id, kind, location, checksum, content_type, size, status, created_at FROM loan_documents WHERE id = ?")).
+		WithArgs(5).
+		WillReturnRows(rows)
+
+	document, err := repo.GetLoanDocumentByID(context.Background(), 5)
+
+	assert.NoError(t, err)
+	assert.Equal(t, model.DocumentBorrowerAgreement, document.Kind)
+	assert.Equal(t, model.DocumentSigned, document.Status)
+}
+
+func TestUpdateLoanDocumentStatus(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewLoanRepository(db)
+
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE loan_documents SET status = ? WHERE id = ?")).
+		WithArgs(model.DocumentSigned, 5).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err = repo.UpdateLoanDocumentStatus(context.Background(), 5, model.DocumentSigned)
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
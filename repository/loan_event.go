@@ -0,0 +1,104 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/aldipi/loan-service/model"
+)
+
+func (r *LoanRepository) CreateLoanEvent(ctx context.Context, event *model.LoanEvent) (id int64, err error) {
+	query := `
+		INSERT INTO loan_events (loan_id, type, payload, occurred_at)
+		VALUES (?, ?, ?, ?)
+	`
+
+	res, err := r.conn(ctx).ExecContext(ctx, query,
+		event.LoanID,
+		event.Type,
+		event.Payload,
+		event.OccurredAt,
+	)
+
+	if err != nil {
+		return
+	}
+
+	return res.LastInsertId()
+}
+
+// GetLoanEvents returns up to limit events ordered by loan and, within a loan,
+// in the order they were stored. The page starts after the event afterID of
+// the loan afterLoanID, so the events of a loan are read one page after the
+// other. The index on loan_id, which holds the event ID as well, serves the
+// order.
+func (r *LoanRepository) GetLoanEvents(ctx context.Context, afterLoanID int64, afterID int64, limit int) ([]*model.LoanEvent, error) {
+	query := `
+		SELECT
+			id, loan_id, type, payload, occurred_at, created_at
+		FROM
+			loan_events
+		WHERE
+			loan_id > ? OR (loan_id = ? AND id > ?)
+		ORDER BY loan_id, id
+		LIMIT ?
+	`
+
+	rows, err := r.conn(ctx).QueryContext(ctx, query, afterLoanID, afterLoanID, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []*model.LoanEvent{}
+	for rows.Next() {
+		event := &model.LoanEvent{}
+		err = rows.Scan(
+			&event.ID,
+			&event.LoanID,
+			&event.Type,
+			&event.Payload,
+			&event.OccurredAt,
+			&event.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		events = append(events, event)
+	}
+
+	return events, nil
+}
+
+// GetLoanIDsWithoutEvents returns the IDs of the loans that have no events,
+// such as loans created before events were stored.
+func (r *LoanRepository) GetLoanIDsWithoutEvents(ctx context.Context) ([]int64, error) {
+	query := `
+		SELECT
+			l.id
+		FROM
+			loans l
+		WHERE
+			NOT EXISTS (SELECT 1 FROM loan_events e WHERE e.loan_id = l.id)
+		ORDER BY l.id
+	`
+
+	rows, err := r.conn(ctx).QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	loanIDs := []int64{}
+	for rows.Next() {
+		var loanID int64
+		err = rows.Scan(&loanID)
+		if err != nil {
+			return nil, err
+		}
+
+		loanIDs = append(loanIDs, loanID)
+	}
+
+	return loanIDs, nil
+}
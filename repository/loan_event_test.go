@@ -0,0 +1,138 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/aldipi/loan-service/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateLoanEvent(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewLoanRepository(db)
+
+	occurredAt := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	payload := json.RawMessage(`{"employee_id":555,"approval_proof":"https://file.io/123/proof.jpg"}`)
+
+	query := regexp.QuoteMeta(`
+		INSERT INTO loan_events (loan_id, type, payload, occurred_at)
+		VALUES (?, ?, ?, ?)
+	`)
+
+	mock.ExpectExec(query).
+		WithArgs(1, model.LoanEventApproved, payload, occurredAt).
+		WillReturnResult(sqlmock.NewResult(7, 1))
+
+	id, err := repo.CreateLoanEvent(context.Background(), &model.LoanEvent{
+		LoanID:     1,
+		Type:       model.LoanEventApproved,
+		Payload:    payload,
+		OccurredAt: occurredAt,
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(7), id)
+}
+
+func TestGetLoanEvents(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewLoanRepository(db)
+
+	occurredAt := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	rows := sqlmock.NewRows([]string{"id", "loan_id", "type", "payload", "occurred_at", "created_at"}).
+		AddRow(11, 3, model.LoanEventProposed, []byte(`{"borrower_id":123}`), occurredAt, occurredAt)
+
+	query := regexp.QuoteMeta(`
+		SELECT
+			id, loan_id, type, payload, occurred_at, created_at
+		FROM
+			loan_events
+		WHERE
+			loan_id > ? OR (loan_id = ? AND id > ?)
+		ORDER BY loan_id, id
+		LIMIT ?
+	`)
+
+	mock.ExpectQuery(query).WithArgs(3, 3, 10, 100).WillReturnRows(rows)
+
+	events, err := repo.GetLoanEvents(context.Background(), 3, 10, 100)
+
+	assert.NoError(t, err)
+	assert.Len(t, events, 1)
+	assert.Equal(t, int64(11), events[0].ID)
+	assert.Equal(t, int64(3), events[0].LoanID)
+}
+
+func TestGetLoanIDsWithoutEvents(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewLoanRepository(db)
+
+	query := regexp.QuoteMeta(`
+		SELECT
+			l.id
+		FROM
+			loans l
+		WHERE
+			NOT EXISTS (SELECT 1 FROM loan_events e WHERE e.loan_id = l.id)
+		ORDER BY l.id
+	`)
+
+	mock.ExpectQuery(query).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2).AddRow(5))
+
+	loanIDs, err := repo.GetLoanIDsWithoutEvents(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{2, 5}, loanIDs)
+}
+
+func TestWithTransactionCommit(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewLoanRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM loans")).WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	err = repo.WithTransaction(context.Background(), func(ctx context.Context) error {
+		_, err := repo.conn(ctx).ExecContext(ctx, "DELETE FROM loans")
+		return err
+	})
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestWithTransactionRollback(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewLoanRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+
+	err = repo.WithTransaction(context.Background(), func(ctx context.Context) error {
+		return model.ErrLoanNotFound
+	})
+
+	assert.ErrorIs(t, err, model.ErrLoanNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -9,14 +9,14 @@ import (
 func (r *LoanRepository) GetLoanProductByID(ctx context.Context, id int64) (*model.LoanProduct, error) {
 	query := `
 		SELECT
-			id, name, rate, roi, created_at, last_updated_at
+			id, name, rate, roi, currency, created_at, last_updated_at
 		FROM
 			loan_products
 		WHERE
 			id = ?
 	`
 
-	row := r.DB.QueryRowContext(ctx, query, id)
+	row := r.conn(ctx).QueryRowContext(ctx, query, id)
 
 	loanProduct := &model.LoanProduct{}
 	err := row.Scan(
@@ -24,6 +24,7 @@ func (r *LoanRepository) GetLoanProductByID(ctx context.Context, id int64) (*mod
 		&loanProduct.Name,
 		&loanProduct.Rate,
 		&loanProduct.ROI,
+		&loanProduct.Currency,
 		&loanProduct.CreatedAt,
 		&loanProduct.LastUpdatedAt,
 	)
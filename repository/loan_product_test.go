@@ -24,10 +24,10 @@ func TestGetLoanProductByID(t *testing.T) {
 	createdAt, _ := time.Parse("2006-01-02 15:04:05", "2021-01-01 00:00:00")
 	lastUpdatedAt, _ := time.Parse("2006-01-02 15:04:05", "2021-01-01 00:00:00")
 
-	rows := sqlmock.NewRows([]string{"id", "name", "rate", "roi", "created_at", "last_updated_at"}).
-		AddRow(1, "Loan Product 1", rate, roi, createdAt, lastUpdatedAt)
+	rows := sqlmock.NewRows([]string{"id", "name", "rate", "roi", "currency", "created_at", "last_updated_at"}).
+		AddRow(1, "Loan Product 1", rate, roi, "IDR", createdAt, lastUpdatedAt)
 
-	mock.ExpectQuery("SELECT id, name, rate, roi, created_at, last_updated_at FROM loan_products WHERE id = ?").
+	mock.ExpectQuery("SELECT id, name, rate, roi, currency, created_at, last_updated_at FROM loan_products WHERE id = ?").
 		WithArgs(1).
 		WillReturnRows(rows)
 
@@ -39,6 +39,7 @@ func TestGetLoanProductByID(t *testing.T) {
 		Name:          "Loan Product 1",
 		Rate:          rate,
 		ROI:           roi,
+		Currency:      "IDR",
 		CreatedAt:     createdAt,
 		LastUpdatedAt: lastUpdatedAt,
 	}))
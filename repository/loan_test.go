@@ -31,10 +31,10 @@ func TestGetLoanByID(t *testing.T) {
 	approvalProof := sql.NullString{String: "https://file.io/123/approval_proof.jpg", Valid: true}
 	agreementLetter := sql.NullString{String: "https://file.io/123/agreement_letter.pdf", Valid: true}
 
-	rows := sqlmock.NewRows([]string{"id", "state", "borrower_id", "principal_amount", "rate", "roi", "approval_proof", "approved_by", "agreement_letter", "disbursed_by", "created_at", "approved_at", "invested_at", "disbursed_at", "last_updated_at"}).
-		AddRow(1, model.LoanStateDisbursed, 123, 1000000, rate, roi, approvalProof, 555, agreementLetter, 777, createdAt, approvedAt, investedAt, disbursedAt, lastUpdatedAt)
+	rows := sqlmock.NewRows([]string{"id", "state", "borrower_id", "loan_product_id", "principal_amount", "currency", "rate", "roi", "approval_proof", "approved_by", "agreement_letter", "disbursed_by", "borrower_agreement", "borrower_agreement_checksum", "created_at", "approved_at", "invested_at", "disbursed_at", "last_updated_at"}).
+		AddRow(1, model.LoanStateDisbursed, 123, 100, 1000000, "IDR", rate, roi, approvalProof, 555, agreementLetter, 777, nil, nil, createdAt, approvedAt, investedAt, disbursedAt, lastUpdatedAt)
 
-	mock.ExpectQuery("SELECT id, state, borrower_id, principal_amount, rate, roi, approval_proof, approved_by, agreement_letter, disbursed_by, created_at, approved_at, invested_at, disbursed_at, last_updated_at FROM loans WHERE id = ?").
+	mock.ExpectQuery("SELECT id, state, borrower_id, loan_product_id, principal_amount, currency, rate, roi, approval_proof, approved_by, agreement_letter, disbursed_by, borrower_agreement, borrower_agreement_checksum, created_at, approved_at, invested_at, disbursed_at, last_updated_at FROM loans WHERE id = ?").
 		WithArgs(1).
 		WillReturnRows(rows)
 
@@ -46,7 +46,9 @@ func TestGetLoanByID(t *testing.T) {
 		ID:              1,
 		State:           model.LoanStateDisbursed,
 		BorrowerID:      123,
+		LoanProductID:   sql.NullInt64{Int64: 100, Valid: true},
 		PrincipalAmount: 1000000,
+		Currency:        "IDR",
 		Rate:            rate,
 		ROI:             roi,
 		ApprovalProof:   approvalProof,
@@ -61,6 +63,35 @@ func TestGetLoanByID(t *testing.T) {
 	}))
 }
 
+func TestLockLoan(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewLoanRepository(db)
+
+	createdAt := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	rows := sqlmock.NewRows([]string{"id", "state", "borrower_id", "loan_product_id", "principal_amount", "currency", "rate", "roi", "approval_proof", "approved_by", "agreement_letter", "disbursed_by", "borrower_agreement", "borrower_agreement_checksum", "created_at", "approved_at", "invested_at", "disbursed_at", "last_updated_at"}).
+		AddRow(1, model.LoanStateProposed, 123, nil, 1000000, "IDR", "6", "3", nil, nil, nil, nil, nil, nil, createdAt, nil, nil, nil, createdAt)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id, state, borrower_id, loan_product_id, principal_amount, currency, rate, roi, approval_proof, approved_by, agreement_letter, disbursed_by, borrower_agreement, borrower_agreement_checksum, created_at, approved_at, invested_at, disbursed_at, last_updated_at FROM loans WHERE id = ? FOR UPDATE")).
+		WithArgs(1).
+		WillReturnRows(rows)
+	mock.ExpectCommit()
+
+	var loan *model.Loan
+	err = repo.WithTransaction(context.Background(), func(ctx context.Context) error {
+		loan, err = repo.LockLoan(ctx, 1)
+		return err
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), loan.ID)
+	assert.Equal(t, model.LoanStateProposed, loan.State)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
 func TestGetLoanByIDReturnEmpty(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	assert.NoError(t, err)
@@ -68,9 +99,9 @@ func TestGetLoanByIDReturnEmpty(t *testing.T) {
 
 	repo := NewLoanRepository(db)
 
-	rows := sqlmock.NewRows([]string{"id", "state", "borrower_id", "principal_amount", "rate", "roi", "approval_proof", "approved_by", "agreement_letter", "disbursed_by", "created_at", "approved_at", "invested_at", "disbursed_at", "last_updated_at"})
+	rows := sqlmock.NewRows([]string{"id", "state", "borrower_id", "loan_product_id", "principal_amount", "currency", "rate", "roi", "approval_proof", "approved_by", "agreement_letter", "disbursed_by", "borrower_agreement", "borrower_agreement_checksum", "created_at", "approved_at", "invested_at", "disbursed_at", "last_updated_at"})
 
-	mock.ExpectQuery("SELECT id, state, borrower_id, principal_amount, rate, roi, approval_proof, approved_by, agreement_letter, disbursed_by, created_at, approved_at, invested_at, disbursed_at, last_updated_at FROM loans WHERE id = ?").
+	mock.ExpectQuery("SELECT id, state, borrower_id, loan_product_id, principal_amount, currency, rate, roi, approval_proof, approved_by, agreement_letter, disbursed_by, borrower_agreement, borrower_agreement_checksum, created_at, approved_at, invested_at, disbursed_at, last_updated_at FROM loans WHERE id = ?").
 		WithArgs(1).
 		WillReturnRows(rows)
 
@@ -97,25 +128,27 @@ func TestGetLoans(t *testing.T) {
 	approvalProof := sql.NullString{String: "https://file.io/123/approval_proof.jpg", Valid: true}
 	agreementLetter := sql.NullString{String: "https://file.io/123/agreement_letter.pdf", Valid: true}
 
-	rows := sqlmock.NewRows([]string{"id", "state", "borrower_id", "principal_amount", "rate", "roi", "approval_proof", "approved_by", "agreement_letter", "disbursed_by", "created_at", "approved_at", "invested_at", "disbursed_at", "last_updated_at"}).
-		AddRow(1, model.LoanStateDisbursed, 123, 1000000, rate, roi, approvalProof, 555, agreementLetter, 777, createdAt, approvedAt, investedAt, disbursedAt, lastUpdatedAt).
-		AddRow(2, model.LoanStateApproved, 456, 2000000, rate, roi, approvalProof, 333, nil, nil, createdAt, approvedAt, nil, nil, lastUpdatedAt)
+	rows := sqlmock.NewRows([]string{"id", "state", "borrower_id", "loan_product_id", "principal_amount", "currency", "rate", "roi", "approval_proof", "approved_by", "agreement_letter", "disbursed_by", "borrower_agreement", "borrower_agreement_checksum", "created_at", "approved_at", "invested_at", "disbursed_at", "last_updated_at"}).
+		AddRow(1, model.LoanStateDisbursed, 123, nil, 1000000, "IDR", rate, roi, approvalProof, 555, agreementLetter, 777, nil, nil, createdAt, approvedAt, investedAt, disbursedAt, lastUpdatedAt).
+		AddRow(2, model.LoanStateApproved, 456, nil, 2000000, "IDR", rate, roi, approvalProof, 333, nil, nil, nil, nil, createdAt, approvedAt, nil, nil, lastUpdatedAt)
 
 	query := regexp.QuoteMeta(`
 		SELECT
-			id, state, borrower_id, principal_amount, rate, roi,
+			id, state, borrower_id, loan_product_id, principal_amount, currency, rate, roi,
 			approval_proof, approved_by, agreement_letter, disbursed_by,
+			borrower_agreement, borrower_agreement_checksum,
 			created_at, approved_at, invested_at, disbursed_at, last_updated_at
 		FROM
 			loans
-		LIMIT ? OFFSET ?
+		ORDER BY id ASC
+		LIMIT ?
 	`)
 
 	mock.ExpectQuery(query).
-		WithArgs(10, 0).
+		WithArgs(10).
 		WillReturnRows(rows)
 
-	loans, err := repo.GetLoans(context.Background(), 10, 0)
+	loans, err := repo.GetLoans(context.Background(), model.LoanFilter{Sort: model.Sort{Field: model.SortByID}, Limit: 10})
 
 	assert.NoError(t, err)
 	assert.NotEmpty(t, loans)
@@ -125,6 +158,7 @@ func TestGetLoans(t *testing.T) {
 		State:           model.LoanStateDisbursed,
 		BorrowerID:      123,
 		PrincipalAmount: 1000000,
+		Currency:        "IDR",
 		Rate:            rate,
 		ROI:             roi,
 		ApprovalProof:   approvalProof,
@@ -142,6 +176,7 @@ func TestGetLoans(t *testing.T) {
 		State:           model.LoanStateApproved,
 		BorrowerID:      456,
 		PrincipalAmount: 2000000,
+		Currency:        "IDR",
 		Rate:            rate,
 		ROI:             roi,
 		ApprovalProof:   approvalProof,
@@ -163,28 +198,179 @@ func TestGetLoansReturnEmpty(t *testing.T) {
 
 	repo := NewLoanRepository(db)
 
-	rows := sqlmock.NewRows([]string{"id", "state", "borrower_id", "principal_amount", "rate", "roi", "approval_proof", "approved_by", "agreement_letter", "disbursed_by", "created_at", "approved_at", "invested_at", "disbursed_at", "last_updated_at"})
+	rows := sqlmock.NewRows([]string{"id", "state", "borrower_id", "loan_product_id", "principal_amount", "currency", "rate", "roi", "approval_proof", "approved_by", "agreement_letter", "disbursed_by", "borrower_agreement", "borrower_agreement_checksum", "created_at", "approved_at", "invested_at", "disbursed_at", "last_updated_at"})
 
 	query := regexp.QuoteMeta(`
 		SELECT
-			id, state, borrower_id, principal_amount, rate, roi,
+			id, state, borrower_id, loan_product_id, principal_amount, currency, rate, roi,
 			approval_proof, approved_by, agreement_letter, disbursed_by,
+			borrower_agreement, borrower_agreement_checksum,
 			created_at, approved_at, invested_at, disbursed_at, last_updated_at
 		FROM
 			loans
-		LIMIT ? OFFSET ?
+		ORDER BY id ASC
+		LIMIT ?
 	`)
 
 	mock.ExpectQuery(query).
-		WithArgs(10, 0).
+		WithArgs(10).
 		WillReturnRows(rows)
 
-	loans, err := repo.GetLoans(context.Background(), 10, 0)
+	loans, err := repo.GetLoans(context.Background(), model.LoanFilter{Sort: model.Sort{Field: model.SortByID}, Limit: 10})
 
 	assert.NoError(t, err)
 	assert.Empty(t, loans)
 }
 
+func TestGetLoansWithFiltersAndCursor(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewLoanRepository(db)
+
+	state := model.LoanStateApproved
+	createdFrom := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	createdTo := time.Date(2021, 2, 1, 0, 0, 0, 0, time.UTC)
+	cursorAt := time.Date(2021, 1, 15, 10, 0, 0, 0, time.UTC)
+
+	rows := sqlmock.NewRows([]string{"id", "state", "borrower_id", "loan_product_id", "principal_amount", "currency", "rate", "roi", "approval_proof", "approved_by", "agreement_letter", "disbursed_by", "borrower_agreement", "borrower_agreement_checksum", "created_at", "approved_at", "invested_at", "disbursed_at", "last_updated_at"}).
+		AddRow(2, model.LoanStateApproved, 456, 7, 2000000, "IDR", decimal.NewFromInt(6), decimal.NewFromInt(3), nil, 333, nil, nil, nil, nil, createdFrom, createdFrom, nil, nil, createdFrom)
+
+	query := regexp.QuoteMeta(`
+		SELECT
+			id, state, borrower_id, loan_product_id, principal_amount, currency, rate, roi,
+			approval_proof, approved_by, agreement_letter, disbursed_by,
+			borrower_agreement, borrower_agreement_checksum,
+			created_at, approved_at, invested_at, disbursed_at, last_updated_at
+		FROM
+			loans
+		WHERE state = ? AND loan_product_id = ? AND principal_amount >= ? AND created_at >= ? AND created_at < ?
+			AND (created_at < ? OR (created_at = ? AND id < ?))
+		ORDER BY created_at DESC, id DESC
+		LIMIT ?
+	`)
+
+	mock.ExpectQuery(query).
+		WithArgs(state, 7, 1000000, createdFrom, createdTo, cursorAt, cursorAt, 9, 5).
+		WillReturnRows(rows)
+
+	sort := model.Sort{Field: model.SortByCreatedAt, Desc: true}
+	loans, err := repo.GetLoans(context.Background(), model.LoanFilter{
+		State:         &state,
+		LoanProductID: 7,
+		MinAmount:     1000000,
+		CreatedFrom:   createdFrom,
+		CreatedTo:     createdTo,
+		Sort:          sort,
+		Cursor:        &model.Cursor{Sort: sort.String(), Value: cursorAt.Format(time.RFC3339Nano), ID: 9},
+		Limit:         5,
+	})
+
+	assert.NoError(t, err)
+	assert.Len(t, loans, 1)
+	assert.Equal(t, int64(2), loans[0].ID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetLoansRejectsInvalidListing(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter model.LoanFilter
+		err    error
+	}{
+		{
+			name:   "unknown sort field",
+			filter: model.LoanFilter{Sort: model.Sort{Field: "rate"}, Limit: 10},
+			err:    model.ErrSortFieldInvalid,
+		},
+		{
+			name: "cursor of another sort",
+			filter: model.LoanFilter{
+				Sort:   model.Sort{Field: model.SortByPrincipalAmount},
+				Cursor: &model.Cursor{Sort: "-created_at", Value: "2021-01-01T00:00:00Z", ID: 1},
+				Limit:  10,
+			},
+			err: model.ErrCursorInvalid,
+		},
+		{
+			name: "malformed cursor value",
+			filter: model.LoanFilter{
+				Sort:   model.Sort{Field: model.SortByPrincipalAmount},
+				Cursor: &model.Cursor{Sort: "principal_amount", Value: "a lot", ID: 1},
+				Limit:  10,
+			},
+			err: model.ErrCursorInvalid,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			assert.NoError(t, err)
+			defer db.Close()
+
+			repo := NewLoanRepository(db)
+
+			loans, err := repo.GetLoans(context.Background(), tt.filter)
+
+			assert.ErrorIs(t, err, tt.err)
+			assert.Nil(t, loans)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestCountLoans(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewLoanRepository(db)
+
+	approvedFrom := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	query := regexp.QuoteMeta(`
+		SELECT
+			COUNT(*)
+		FROM
+			loans
+		WHERE borrower_id = ? AND principal_amount <= ? AND approved_at >= ?
+	`)
+
+	mock.ExpectQuery(query).
+		WithArgs(123, 5000000, approvedFrom).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(42))
+
+	count, err := repo.CountLoans(context.Background(), model.LoanFilter{
+		BorrowerID:   123,
+		MaxAmount:    5000000,
+		ApprovedFrom: approvedFrom,
+		Cursor:       &model.Cursor{Sort: "id", ID: 10},
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 42, count)
+}
+
+func TestCountLoansByState(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewLoanRepository(db)
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT state, COUNT(*) FROM loans GROUP BY state")).
+		WillReturnRows(sqlmock.NewRows([]string{"state", "count"}).
+			AddRow(model.LoanStateProposed, 3).
+			AddRow(model.LoanStateDisbursed, 1))
+
+	counts, err := repo.CountLoansByState(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, map[model.LoanState]int{model.LoanStateProposed: 3, model.LoanStateDisbursed: 1}, counts)
+}
+
 func TestGetLoansByBorrowerID(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	assert.NoError(t, err)
@@ -202,19 +388,21 @@ func TestGetLoansByBorrowerID(t *testing.T) {
 	approvalProof := sql.NullString{String: "https://file.io/123/approval_proof.jpg", Valid: true}
 	agreementLetter := sql.NullString{String: "https://file.io/123/agreement_letter.pdf", Valid: true}
 
-	rows := sqlmock.NewRows([]string{"id", "state", "borrower_id", "principal_amount", "rate", "roi", "approval_proof", "approved_by", "agreement_letter", "disbursed_by", "created_at", "approved_at", "invested_at", "disbursed_at", "last_updated_at"}).
-		AddRow(1, model.LoanStateDisbursed, 123, 1000000, rate, roi, approvalProof, 555, agreementLetter, 777, createdAt, approvedAt, investedAt, disbursedAt, lastUpdatedAt).
-		AddRow(2, model.LoanStateApproved, 123, 2000000, rate, roi, approvalProof, 333, nil, nil, createdAt, approvedAt, nil, nil, lastUpdatedAt)
+	rows := sqlmock.NewRows([]string{"id", "state", "borrower_id", "loan_product_id", "principal_amount", "currency", "rate", "roi", "approval_proof", "approved_by", "agreement_letter", "disbursed_by", "borrower_agreement", "borrower_agreement_checksum", "created_at", "approved_at", "invested_at", "disbursed_at", "last_updated_at"}).
+		AddRow(1, model.LoanStateDisbursed, 123, nil, 1000000, "IDR", rate, roi, approvalProof, 555, agreementLetter, 777, nil, nil, createdAt, approvedAt, investedAt, disbursedAt, lastUpdatedAt).
+		AddRow(2, model.LoanStateApproved, 123, nil, 2000000, "IDR", rate, roi, approvalProof, 333, nil, nil, nil, nil, createdAt, approvedAt, nil, nil, lastUpdatedAt)
 
 	query := regexp.QuoteMeta(`
 		SELECT
-			id, state, borrower_id, principal_amount, rate, roi,
+			id, state, borrower_id, loan_product_id, principal_amount, currency, rate, roi,
 			approval_proof, approved_by, agreement_letter, disbursed_by,
+			borrower_agreement, borrower_agreement_checksum,
 			created_at, approved_at, invested_at, disbursed_at, last_updated_at
 		FROM
 			loans
 		WHERE
 			borrower_id = ?
+		ORDER BY id
 		LIMIT ? OFFSET ?
 	`)
 
@@ -232,6 +420,7 @@ func TestGetLoansByBorrowerID(t *testing.T) {
 		State:           model.LoanStateDisbursed,
 		BorrowerID:      123,
 		PrincipalAmount: 1000000,
+		Currency:        "IDR",
 		Rate:            rate,
 		ROI:             roi,
 		ApprovalProof:   approvalProof,
@@ -249,6 +438,7 @@ func TestGetLoansByBorrowerID(t *testing.T) {
 		State:           model.LoanStateApproved,
 		BorrowerID:      123,
 		PrincipalAmount: 2000000,
+		Currency:        "IDR",
 		Rate:            rate,
 		ROI:             roi,
 		ApprovalProof:   approvalProof,
@@ -270,17 +460,19 @@ func TestGetLoansByBorrowerIDReturnEmpty(t *testing.T) {
 
 	repo := NewLoanRepository(db)
 
-	rows := sqlmock.NewRows([]string{"id", "state", "borrower_id", "principal_amount", "rate", "roi", "approval_proof", "approved_by", "agreement_letter", "disbursed_by", "created_at", "approved_at", "invested_at", "disbursed_at", "last_updated_at"})
+	rows := sqlmock.NewRows([]string{"id", "state", "borrower_id", "loan_product_id", "principal_amount", "currency", "rate", "roi", "approval_proof", "approved_by", "agreement_letter", "disbursed_by", "borrower_agreement", "borrower_agreement_checksum", "created_at", "approved_at", "invested_at", "disbursed_at", "last_updated_at"})
 
 	query := regexp.QuoteMeta(`
 		SELECT
-			id, state, borrower_id, principal_amount, rate, roi,
+			id, state, borrower_id, loan_product_id, principal_amount, currency, rate, roi,
 			approval_proof, approved_by, agreement_letter, disbursed_by,
+			borrower_agreement, borrower_agreement_checksum,
 			created_at, approved_at, invested_at, disbursed_at, last_updated_at
 		FROM
 			loans
 		WHERE
 			borrower_id = ?
+		ORDER BY id
 		LIMIT ? OFFSET ?
 	`)
 
@@ -308,20 +500,22 @@ func TestCreateLoan(t *testing.T) {
 
 	query := regexp.QuoteMeta(`
         INSERT INTO loans (
-            state, borrower_id, principal_amount, rate, roi
+            state, borrower_id, loan_product_id, principal_amount, currency, rate, roi, created_at, last_updated_at
         ) VALUES (
-            ?, ?, ?, ?, ?
+            ?, ?, ?, ?, ?, ?, ?, ?, ?
         )
     `)
 
 	mock.ExpectExec(query).
-		WithArgs(model.LoanStateProposed, 123, 1000000, rate, roi).
+		WithArgs(model.LoanStateProposed, 123, sql.NullInt64{Int64: 100, Valid: true}, 1000000, "IDR", rate, roi, createdAt, lastUpdatedAt).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
 	loan := &model.Loan{
 		State:           model.LoanStateProposed,
 		BorrowerID:      123,
+		LoanProductID:   sql.NullInt64{Int64: 100, Valid: true},
 		PrincipalAmount: 1000000,
+		Currency:        "IDR",
 		Rate:            rate,
 		ROI:             roi,
 		CreatedAt:       createdAt,
@@ -344,6 +538,7 @@ func TestUpdateLoan(t *testing.T) {
 	approvedAt := sql.NullTime{Time: time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC), Valid: true}
 	investedAt := sql.NullTime{Time: time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC), Valid: true}
 	disbursedAt := sql.NullTime{Time: time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC), Valid: true}
+	lastUpdatedAt := time.Date(2021, 1, 2, 0, 0, 0, 0, time.UTC)
 	approvalProof := sql.NullString{String: "https://file.io/123/approval_proof.jpg", Valid: true}
 	agreementLetter := sql.NullString{String: "https://file.io/123/agreement_letter.pdf", Valid: true}
 
@@ -354,15 +549,17 @@ func TestUpdateLoan(t *testing.T) {
 			approved_by = ?,
 			agreement_letter = ?,
 			disbursed_by = ?,
+			borrower_agreement = ?,
+			borrower_agreement_checksum = ?,
 			approved_at = ?,
 			invested_at = ?,
 			disbursed_at = ?,
-			last_updated_at = CURRENT_TIMESTAMP
+			last_updated_at = ?
 		WHERE id = ?
 	`)
 
 	mock.ExpectExec(query).
-		WithArgs(model.LoanStateDisbursed, approvalProof, 333, agreementLetter, 555, approvedAt, investedAt, disbursedAt, 1).
+		WithArgs(model.LoanStateDisbursed, approvalProof, 333, agreementLetter, 555, sql.NullString{}, sql.NullString{}, approvedAt, investedAt, disbursedAt, lastUpdatedAt, 1).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
 	loan := &model.Loan{
@@ -375,9 +572,56 @@ func TestUpdateLoan(t *testing.T) {
 		ApprovedAt:      approvedAt,
 		InvestedAt:      investedAt,
 		DisbursedAt:     disbursedAt,
+		LastUpdatedAt:   lastUpdatedAt,
 	}
 
 	err = repo.UpdateLoan(context.Background(), loan)
 
 	assert.NoError(t, err)
 }
+
+func TestReplaceLoan(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewLoanRepository(db)
+
+	rate := decimal.NewFromFloat(6.5)
+	roi := decimal.NewFromFloat(3.0)
+	createdAt := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	approvedAt := sql.NullTime{Time: time.Date(2021, 1, 2, 0, 0, 0, 0, time.UTC), Valid: true}
+	approvalProof := sql.NullString{String: "https://file.io/123/approval_proof.jpg", Valid: true}
+
+	query := regexp.QuoteMeta(`
+		REPLACE INTO loans (
+			id, state, borrower_id, loan_product_id, principal_amount, currency, rate, roi,
+			approval_proof, approved_by, agreement_letter, disbursed_by,
+			borrower_agreement, borrower_agreement_checksum,
+			created_at, approved_at, invested_at, disbursed_at, last_updated_at
+		) VALUES (
+			?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?
+		)
+	`)
+
+	mock.ExpectExec(query).
+		WithArgs(1, model.LoanStateApproved, 123, sql.NullInt64{}, 1000000, "IDR", rate, roi, approvalProof, sql.NullInt64{Int64: 555, Valid: true}, sql.NullString{}, sql.NullInt64{}, sql.NullString{}, sql.NullString{}, createdAt, approvedAt, sql.NullTime{}, sql.NullTime{}, approvedAt.Time).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	err = repo.ReplaceLoan(context.Background(), &model.Loan{
+		ID:              1,
+		State:           model.LoanStateApproved,
+		BorrowerID:      123,
+		PrincipalAmount: 1000000,
+		Currency:        "IDR",
+		Rate:            rate,
+		ROI:             roi,
+		ApprovalProof:   approvalProof,
+		ApprovedBy:      sql.NullInt64{Int64: 555, Valid: true},
+		CreatedAt:       createdAt,
+		ApprovedAt:      approvedAt,
+		LastUpdatedAt:   approvedAt.Time,
+	})
+
+	assert.NoError(t, err)
+}
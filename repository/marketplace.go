@@ -0,0 +1,116 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/aldipi/loan-service/model"
+	"github.com/shopspring/decimal"
+)
+
+// marketplaceLoans aggregates the investments of every approved loan with the
+// end of its funding period. It takes the funding period in seconds and the
+// approved state.
+const marketplaceLoans = `
+			SELECT
+				l.id, l.borrower_id, l.loan_product_id, l.principal_amount, l.currency, l.rate, l.roi, l.approved_at,
+				DATE_ADD(l.approved_at, INTERVAL ? SECOND) AS expires_at,
+				COALESCE(SUM(i.amount), 0) AS funded_amount,
+				COUNT(DISTINCT i.investor_id) AS investor_count
+			FROM
+				loans l
+				LEFT JOIN investments i ON i.loan_id = l.id
+			WHERE
+				l.state = ?
+			GROUP BY
+				l.id
+`
+
+var marketplaceSortColumns = map[string]sortColumn{
+	model.SortByID:            idColumn,
+	model.SortByROI:           {name: "roi", parse: parseDecimalValue},
+	model.SortByFundedPercent: {name: "ROUND(funded_amount * 100 / principal_amount, 2)", parse: parseDecimalValue},
+}
+
+func parseDecimalValue(value string) (any, error) {
+	return decimal.NewFromString(value)
+}
+
+// GetMarketplaceLoans returns a page of the approved loans that are neither
+// fully funded nor past their funding period at the given time.
+func (r *LoanRepository) GetMarketplaceLoans(ctx context.Context, filter model.MarketplaceFilter, at time.Time, fundingPeriod time.Duration) ([]*model.MarketplaceLoan, error) {
+	column, ok := marketplaceSortColumns[filter.Sort.Field]
+	if !ok {
+		return nil, model.ErrSortFieldInvalid
+	}
+
+	c := &conditions{}
+	c.add("funded_amount < principal_amount")
+	c.add("expires_at > ?", at)
+	err := c.addAfter(column, filter.Sort, filter.Cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT
+			id, borrower_id, loan_product_id, principal_amount, currency, funded_amount,
+			ROUND(funded_amount * 100 / principal_amount, 2) AS funded_percent,
+			rate, roi, investor_count, approved_at, expires_at,
+			TIMESTAMPDIFF(SECOND, ?, expires_at) AS time_left
+		FROM (` + marketplaceLoans + `) AS marketplace
+		` + c.where() + `
+		` + orderBy(column, filter.Sort) + `
+		LIMIT ?
+	`
+
+	args := append([]any{at, int64(fundingPeriod.Seconds()), model.LoanStateApproved}, c.args...)
+	rows, err := r.conn(ctx).QueryContext(ctx, query, append(args, filter.Limit)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	loans := []*model.MarketplaceLoan{}
+	for rows.Next() {
+		loan := &model.MarketplaceLoan{}
+		err = rows.Scan(
+			&loan.ID,
+			&loan.BorrowerID,
+			&loan.LoanProductID,
+			&loan.PrincipalAmount,
+			&loan.Currency,
+			&loan.FundedAmount,
+			&loan.FundedPercent,
+			&loan.Rate,
+			&loan.ROI,
+			&loan.InvestorCount,
+			&loan.ApprovedAt,
+			&loan.ExpiresAt,
+			&loan.TimeLeft,
+		)
+		if err != nil {
+			return nil, err
+		}
+		loan.RemainingAmount = loan.PrincipalAmount - loan.FundedAmount
+
+		loans = append(loans, loan)
+	}
+
+	return loans, nil
+}
+
+// CountMarketplaceLoans counts the loans listed by GetMarketplaceLoans over all
+// pages.
+func (r *LoanRepository) CountMarketplaceLoans(ctx context.Context, at time.Time, fundingPeriod time.Duration) (int, error) {
+	query := `
+		SELECT
+			COUNT(*)
+		FROM (` + marketplaceLoans + `) AS marketplace
+		WHERE funded_amount < principal_amount AND expires_at > ?
+	`
+
+	var count int
+	err := r.conn(ctx).QueryRowContext(ctx, query, int64(fundingPeriod.Seconds()), model.LoanStateApproved, at).Scan(&count)
+	return count, err
+}
@@ -0,0 +1,173 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/aldipi/loan-service/model"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+const marketplaceQuery = `
+		SELECT
+			id, borrower_id, loan_product_id, principal_amount, currency, funded_amount,
+			ROUND(funded_amount * 100 / principal_amount, 2) AS funded_percent,
+			rate, roi, investor_count, approved_at, expires_at,
+			TIMESTAMPDIFF(SECOND, ?, expires_at) AS time_left
+		FROM (
+			SELECT
+				l.id, l.borrower_id, l.loan_product_id, l.principal_amount, l.currency, l.rate, l.roi, l.approved_at,
+				DATE_ADD(l.approved_at, INTERVAL ? SECOND) AS expires_at,
+				COALESCE(SUM(i.amount), 0) AS funded_amount,
+				COUNT(DISTINCT i.investor_id) AS investor_count
+			FROM
+				loans l
+				LEFT JOIN investments i ON i.loan_id = l.id
+			WHERE
+				l.state = ?
+			GROUP BY
+				l.id
+		) AS marketplace
+`
+
+func TestGetMarketplaceLoans(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewLoanRepository(db)
+
+	at := time.Date(2021, 1, 10, 0, 0, 0, 0, time.UTC)
+	approvedAt := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	expiresAt := approvedAt.Add(30 * 24 * time.Hour)
+
+	rows := sqlmock.NewRows([]string{"id", "borrower_id", "loan_product_id", "principal_amount", "currency", "funded_amount", "funded_percent", "rate", "roi", "investor_count", "approved_at", "expires_at", "time_left"}).
+		AddRow(1, 123, 7, 1000000, "IDR", 250000, "25.00", "10.00", "5.50", 2, approvedAt, expiresAt, 1814400)
+
+	query := regexp.QuoteMeta(marketplaceQuery + `
+		WHERE funded_amount < principal_amount AND expires_at > ?
+			AND (roi < ? OR (roi = ? AND id < ?))
+		ORDER BY roi DESC, id DESC
+		LIMIT ?
+	`)
+
+	roi := decimal.RequireFromString("6.5")
+	mock.ExpectQuery(query).
+		WithArgs(at, int64(2592000), model.LoanStateApproved, at, roi, roi, 4, 11).
+		WillReturnRows(rows)
+
+	sort := model.Sort{Field: model.SortByROI, Desc: true}
+	loans, err := repo.GetMarketplaceLoans(context.Background(), model.MarketplaceFilter{
+		Sort:   sort,
+		Cursor: &model.Cursor{Sort: sort.String(), Value: "6.5", ID: 4},
+		Limit:  11,
+	}, at, 30*24*time.Hour)
+
+	assert.NoError(t, err)
+	assert.Len(t, loans, 1)
+	assert.Equal(t, &model.MarketplaceLoan{
+		ID:              1,
+		BorrowerID:      123,
+		LoanProductID:   sql.NullInt64{Int64: 7, Valid: true},
+		PrincipalAmount: 1000000,
+		Currency:        "IDR",
+		FundedAmount:    250000,
+		RemainingAmount: 750000,
+		FundedPercent:   decimal.RequireFromString("25.00"),
+		Rate:            decimal.RequireFromString("10.00"),
+		ROI:             decimal.RequireFromString("5.50"),
+		InvestorCount:   2,
+		ApprovedAt:      approvedAt,
+		ExpiresAt:       expiresAt,
+		TimeLeft:        1814400,
+	}, loans[0])
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetMarketplaceLoansSortsByFundingProgress(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewLoanRepository(db)
+
+	at := time.Date(2021, 1, 10, 0, 0, 0, 0, time.UTC)
+
+	query := regexp.QuoteMeta(marketplaceQuery + `
+		WHERE funded_amount < principal_amount AND expires_at > ?
+		ORDER BY ROUND(funded_amount * 100 / principal_amount, 2) ASC, id ASC
+		LIMIT ?
+	`)
+
+	mock.ExpectQuery(query).
+		WithArgs(at, int64(86400), model.LoanStateApproved, at, 10).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "borrower_id", "loan_product_id", "principal_amount", "currency", "funded_amount", "funded_percent", "rate", "roi", "investor_count", "approved_at", "expires_at", "time_left"}))
+
+	loans, err := repo.GetMarketplaceLoans(context.Background(), model.MarketplaceFilter{
+		Sort:  model.Sort{Field: model.SortByFundedPercent},
+		Limit: 10,
+	}, at, 24*time.Hour)
+
+	assert.NoError(t, err)
+	assert.Empty(t, loans)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetMarketplaceLoansRejectsUnknownSortField(t *testing.T) {
+	db, _, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewLoanRepository(db)
+
+	_, err = repo.GetMarketplaceLoans(context.Background(), model.MarketplaceFilter{
+		Sort:  model.Sort{Field: "principal_amount"},
+		Limit: 10,
+	}, time.Now(), time.Hour)
+
+	assert.ErrorIs(t, err, model.ErrSortFieldInvalid)
+}
+
+func TestCountMarketplaceLoans(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewLoanRepository(db)
+
+	at := time.Date(2021, 1, 10, 0, 0, 0, 0, time.UTC)
+
+	query := regexp.QuoteMeta(`
+		SELECT
+			COUNT(*)
+		FROM (
+			SELECT
+				l.id, l.borrower_id, l.loan_product_id, l.principal_amount, l.currency, l.rate, l.roi, l.approved_at,
+				DATE_ADD(l.approved_at, INTERVAL ? SECOND) AS expires_at,
+				COALESCE(SUM(i.amount), 0) AS funded_amount,
+				COUNT(DISTINCT i.investor_id) AS investor_count
+			FROM
+				loans l
+				LEFT JOIN investments i ON i.loan_id = l.id
+			WHERE
+				l.state = ?
+			GROUP BY
+				l.id
+		) AS marketplace
+		WHERE funded_amount < principal_amount AND expires_at > ?
+	`)
+
+	mock.ExpectQuery(query).
+		WithArgs(int64(2592000), model.LoanStateApproved, at).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+
+	count, err := repo.CountMarketplaceLoans(context.Background(), at, 30*24*time.Hour)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 3, count)
+}
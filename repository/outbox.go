@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/aldipi/loan-service/model"
+)
+
+func (r *LoanRepository) CreateOutboxMessage(ctx context.Context, message *model.OutboxMessage) (id int64, err error) {
+	query := `
+		INSERT INTO outbox_messages (event_type, loan_id, payload, next_attempt_at)
+		VALUES (?, ?, ?, ?)
+	`
+
+	res, err := r.conn(ctx).ExecContext(ctx, query,
+		message.EventType,
+		message.LoanID,
+		message.Payload,
+		message.NextAttemptAt,
+	)
+
+	if err != nil {
+		return
+	}
+
+	return res.LastInsertId()
+}
+
+// GetPendingOutboxMessages returns unpublished messages that are due for a
+// delivery attempt at the given time, oldest first.
+func (r *LoanRepository) GetPendingOutboxMessages(ctx context.Context, at time.Time, limit int) ([]*model.OutboxMessage, error) {
+	query := `
+		SELECT
+			id, event_type, loan_id, payload, attempts, next_attempt_at,
+			last_error, published_at, created_at
+		FROM
+			outbox_messages
+		WHERE
+			published_at IS NULL AND next_attempt_at <= ?
+		ORDER BY id
+		LIMIT ?
+	`
+
+	rows, err := r.conn(ctx).QueryContext(ctx, query, at, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	messages := []*model.OutboxMessage{}
+	for rows.Next() {
+		message := &model.OutboxMessage{}
+		err = rows.Scan(
+			&message.ID,
+			&message.EventType,
+			&message.LoanID,
+			&message.Payload,
+			&message.Attempts,
+			&message.NextAttemptAt,
+			&message.LastError,
+			&message.PublishedAt,
+			&message.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		messages = append(messages, message)
+	}
+
+	return messages, nil
+}
+
+func (r *LoanRepository) MarkOutboxMessagePublished(ctx context.Context, id int64, publishedAt time.Time) error {
+	query := `
+		UPDATE outbox_messages
+		SET published_at = ?,
+			attempts = attempts + 1,
+			last_error = NULL
+		WHERE id = ?
+	`
+
+	_, err := r.conn(ctx).ExecContext(ctx, query, publishedAt, id)
+
+	return err
+}
+
+func (r *LoanRepository) MarkOutboxMessageFailed(ctx context.Context, id int64, nextAttemptAt time.Time, lastError string) error {
+	query := `
+		UPDATE outbox_messages
+		SET attempts = attempts + 1,
+			next_attempt_at = ?,
+			last_error = ?
+		WHERE id = ?
+	`
+
+	_, err := r.conn(ctx).ExecContext(ctx, query, nextAttemptAt, lastError, id)
+
+	return err
+}
@@ -0,0 +1,134 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"reflect"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/aldipi/loan-service/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateOutboxMessage(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewLoanRepository(db)
+
+	at := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	payload := json.RawMessage(`{"employee_id":555}`)
+
+	query := regexp.QuoteMeta(`
+		INSERT INTO outbox_messages (event_type, loan_id, payload, next_attempt_at)
+		VALUES (?, ?, ?, ?)
+	`)
+
+	mock.ExpectExec(query).
+		WithArgs(model.LoanEventApproved, 1, payload, at).
+		WillReturnResult(sqlmock.NewResult(3, 1))
+
+	id, err := repo.CreateOutboxMessage(context.Background(), &model.OutboxMessage{
+		EventType:     model.LoanEventApproved,
+		LoanID:        1,
+		Payload:       payload,
+		NextAttemptAt: at,
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), id)
+}
+
+func TestGetPendingOutboxMessages(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewLoanRepository(db)
+
+	at := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	rows := sqlmock.NewRows([]string{"id", "event_type", "loan_id", "payload", "attempts", "next_attempt_at", "last_error", "published_at", "created_at"}).
+		AddRow(3, model.LoanEventApproved, 1, []byte(`{"employee_id":555}`), 2, at, "connection refused", nil, at)
+
+	query := regexp.QuoteMeta(`
+		SELECT
+			id, event_type, loan_id, payload, attempts, next_attempt_at,
+			last_error, published_at, created_at
+		FROM
+			outbox_messages
+		WHERE
+			published_at IS NULL AND next_attempt_at <= ?
+		ORDER BY id
+		LIMIT ?
+	`)
+
+	mock.ExpectQuery(query).WithArgs(at, 100).WillReturnRows(rows)
+
+	messages, err := repo.GetPendingOutboxMessages(context.Background(), at, 100)
+
+	assert.NoError(t, err)
+	assert.Len(t, messages, 1)
+	assert.True(t, reflect.DeepEqual(messages[0], &model.OutboxMessage{
+		ID:            3,
+		EventType:     model.LoanEventApproved,
+		LoanID:        1,
+		Payload:       json.RawMessage(`{"employee_id":555}`),
+		Attempts:      2,
+		NextAttemptAt: at,
+		LastError:     sql.NullString{String: "connection refused", Valid: true},
+		CreatedAt:     at,
+	}))
+}
+
+func TestMarkOutboxMessagePublished(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewLoanRepository(db)
+
+	at := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	query := regexp.QuoteMeta(`
+		UPDATE outbox_messages
+		SET published_at = ?,
+			attempts = attempts + 1,
+			last_error = NULL
+		WHERE id = ?
+	`)
+
+	mock.ExpectExec(query).WithArgs(at, 3).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err = repo.MarkOutboxMessagePublished(context.Background(), 3, at)
+
+	assert.NoError(t, err)
+}
+
+func TestMarkOutboxMessageFailed(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewLoanRepository(db)
+
+	at := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	query := regexp.QuoteMeta(`
+		UPDATE outbox_messages
+		SET attempts = attempts + 1,
+			next_attempt_at = ?,
+			last_error = ?
+		WHERE id = ?
+	`)
+
+	mock.ExpectExec(query).WithArgs(at, "connection refused", 3).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err = repo.MarkOutboxMessageFailed(context.Background(), 3, at, "connection refused")
+
+	assert.NoError(t, err)
+}
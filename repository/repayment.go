@@ -0,0 +1,50 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/aldipi/loan-service/model"
+)
+
+func (r *LoanRepository) CreateRepayment(ctx context.Context, repayment *model.Repayment) (id int64, err error) {
+	query := `
+		INSERT INTO loan_repayments (
+			loan_id, amount, principal, interest, fee, currency, recorded_by, created_at
+		) VALUES (
+			?, ?, ?, ?, ?, ?, ?, ?
+		)
+	`
+
+	res, err := r.conn(ctx).ExecContext(ctx, query,
+		repayment.LoanID,
+		repayment.Amount,
+		repayment.Principal,
+		repayment.Interest,
+		repayment.Fee,
+		repayment.Currency,
+		repayment.RecordedBy,
+		repayment.CreatedAt,
+	)
+
+	if err != nil {
+		return
+	}
+
+	return res.LastInsertId()
+}
+
+// SumRepaymentsByLoanID adds up the amounts repaid on the loan so far.
+func (r *LoanRepository) SumRepaymentsByLoanID(ctx context.Context, loanID int64) (int64, error) {
+	query := `
+		SELECT
+			COALESCE(SUM(amount), 0)
+		FROM
+			loan_repayments
+		WHERE
+			loan_id = ?
+	`
+
+	var sum int64
+	err := r.conn(ctx).QueryRowContext(ctx, query, loanID).Scan(&sum)
+	return sum, err
+}
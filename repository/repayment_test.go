@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/aldipi/loan-service/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateRepayment(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewLoanRepository(db)
+
+	createdAt := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO loan_repayments ( loan_id, amount, principal, interest, fee, currency, recorded_by, created_at ) VALUES ( ?, ?, ?, ?, ?, ?, ?, ? )")).
+		WithArgs(1, 560000, 500000, 60000, 10000, "IDR", 555, createdAt).
+		WillReturnResult(sqlmock.NewResult(4, 1))
+
+	id, err := repo.CreateRepayment(context.Background(), &model.Repayment{
+		LoanID:     1,
+		Amount:     560000,
+		Principal:  500000,
+		Interest:   60000,
+		Fee:        10000,
+		Currency:   "IDR",
+		RecordedBy: 555,
+		CreatedAt:  createdAt,
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), id)
+}
+
+func TestSumRepaymentsByLoanID(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewLoanRepository(db)
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT COALESCE(SUM(amount), 0) FROM loan_repayments WHERE loan_id = ?")).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"sum"}).AddRow(560000))
+
+	sum, err := repo.SumRepaymentsByLoanID(context.Background(), 1)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(560000), sum)
+}
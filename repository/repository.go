@@ -1,13 +1,78 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
+	"log/slog"
+
+	"github.com/aldipi/loan-service/tracing"
 )
 
 type LoanRepository struct {
 	DB *sql.DB
+
+	logger *slog.Logger
+}
+
+// Option configures optional dependencies of LoanRepository.
+type Option func(r *LoanRepository)
+
+func NewLoanRepository(db *sql.DB, opts ...Option) *LoanRepository {
+	r := &LoanRepository{DB: db, logger: slog.Default()}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// WithLogger sets the logger of failed queries, and of every query at debug
+// level.
+func WithLogger(logger *slog.Logger) Option {
+	return func(r *LoanRepository) {
+		r.logger = logger
+	}
+}
+
+type txKey struct{}
+
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// conn returns the transaction carried by ctx, or the DB pool when the call
+// is not part of a transaction. Queries are logged, and traced when ctx
+// carries a span.
+func (r *LoanRepository) conn(ctx context.Context) instrumentedConn {
+	if tx, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return instrumentedConn{q: tx, logger: r.logger}
+	}
+	return instrumentedConn{q: r.DB, logger: r.logger}
 }
 
-func NewLoanRepository(db *sql.DB) *LoanRepository {
-	return &LoanRepository{DB: db}
+// WithTransaction runs fn inside a database transaction. Repository calls made
+// with the context passed to fn are executed within that transaction. The
+// transaction is rolled back if fn returns an error and committed otherwise.
+// Nested calls reuse the outer transaction.
+func (r *LoanRepository) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) (err error) {
+	if _, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return fn(ctx)
+	}
+
+	ctx, span := tracing.Start(ctx, "db.transaction")
+	defer func() { tracing.End(span, err) }()
+
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	err = fn(context.WithValue(ctx, txKey{}, tx))
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
 }
@@ -0,0 +1,156 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/aldipi/loan-service/model"
+)
+
+func (r *LoanRepository) CreateSignature(ctx context.Context, signature *model.Signature) (id int64, err error) {
+	query := `
+		INSERT INTO document_signatures (
+			loan_document_id, loan_id, signer_role, signer_id, checksum, token_hash
+		) VALUES (
+			?, ?, ?, ?, ?, ?
+		)
+	`
+
+	res, err := r.conn(ctx).ExecContext(ctx, query,
+		signature.LoanDocumentID,
+		signature.LoanID,
+		signature.SignerRole,
+		signature.SignerID,
+		signature.Checksum,
+		signature.TokenHash,
+	)
+
+	if err != nil {
+		return
+	}
+
+	return res.LastInsertId()
+}
+
+func (r *LoanRepository) GetSignatureByTokenHash(ctx context.Context, tokenHash string) (*model.Signature, error) {
+	query := `
+		SELECT
+			id, loan_document_id, loan_id, signer_role, signer_id, checksum, token_hash, signed_at, ip_address, signature, created_at
+		FROM
+			document_signatures
+		WHERE
+			token_hash = ?
+	`
+
+	return scanSignature(r.conn(ctx).QueryRowContext(ctx, query, tokenHash))
+}
+
+func (r *LoanRepository) GetSignaturesByLoanDocumentID(ctx context.Context, loanDocumentID int64) ([]*model.Signature, error) {
+	query := `
+		SELECT
+			id, loan_document_id, loan_id, signer_role, signer_id, checksum, token_hash, signed_at, ip_address, signature, created_at
+		FROM
+			document_signatures
+		WHERE
+			loan_document_id = ?
+		ORDER BY id
+	`
+
+	rows, err := r.conn(ctx).QueryContext(ctx, query, loanDocumentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanSignatures(rows)
+}
+
+func (r *LoanRepository) GetSignaturesByLoanID(ctx context.Context, loanID int64) ([]*model.Signature, error) {
+	query := `
+		SELECT
+			id, loan_document_id, loan_id, signer_role, signer_id, checksum, token_hash, signed_at, ip_address, signature, created_at
+		FROM
+			document_signatures
+		WHERE
+			loan_id = ?
+		ORDER BY id
+	`
+
+	rows, err := r.conn(ctx).QueryContext(ctx, query, loanID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanSignatures(rows)
+}
+
+// SignSignature records the signature of a pending request. It returns
+// sql.ErrNoRows when the request was already signed, so a token can only be
+// used once even by concurrent requests.
+func (r *LoanRepository) SignSignature(ctx context.Context, signature *model.Signature) error {
+	query := `
+		UPDATE document_signatures
+		SET signed_at = ?,
+			ip_address = ?,
+			signature = ?
+		WHERE id = ? AND signed_at IS NULL
+	`
+
+	res, err := r.conn(ctx).ExecContext(ctx, query,
+		signature.SignedAt,
+		signature.IPAddress,
+		signature.Value,
+		signature.ID,
+	)
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+func scanSignatures(rows sqlRows) ([]*model.Signature, error) {
+	signatures := []*model.Signature{}
+	for rows.Next() {
+		signature, err := scanSignature(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		signatures = append(signatures, signature)
+	}
+
+	return signatures, nil
+}
+
+func scanSignature(row scanner) (*model.Signature, error) {
+	signature := &model.Signature{}
+	err := row.Scan(
+		&signature.ID,
+		&signature.LoanDocumentID,
+		&signature.LoanID,
+		&signature.SignerRole,
+		&signature.SignerID,
+		&signature.Checksum,
+		&signature.TokenHash,
+		&signature.SignedAt,
+		&signature.IPAddress,
+		&signature.Value,
+		&signature.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return signature, nil
+}
@@ -0,0 +1,158 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/aldipi/loan-service/model"
+	"github.com/stretchr/testify/assert"
+)
+
+var signatureColumns = []string{"id", "loan_document_id", "loan_id", "signer_role", "signer_id", "checksum", "token_hash", "signed_at", "ip_address", "signature", "created_at"}
+
+func TestCreateSignature(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewLoanRepository(db)
+
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO document_signatures ( loan_document_id, loan_id, signer_role, signer_id, checksum, token_hash ) VALUES ( ?, ?, ?, ?, ?, ? )")).
+		WithArgs(5, 1, model.SignerBorrower, 123, "abc", "def").
+		WillReturnResult(sqlmock.NewResult(9, 1))
+
+	id, err := repo.CreateSignature(context.Background(), &model.Signature{
+		LoanDocumentID: 5,
+		LoanID:         1,
+		SignerRole:     model.SignerBorrower,
+		SignerID:       123,
+		Checksum:       "abc",
+		TokenHash:      "def",
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(9), id)
+}
+
+func TestGetSignatureByTokenHash(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewLoanRepository(db)
+
+	createdAt := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	signedAt := time.Date(2021, 1, 2, 0, 0, 0, 0, time.UTC)
+	rows := sqlmock.NewRows(signatureColumns).
+		AddRow(9, 5, 1, model.SignerInvestor, 100, "abc", "def", signedAt, "10.0.0.1", "c2ln", createdAt)
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id, loan_document_id, loan_id, signer_role, signer_id, checksum, token_hash, signed_at, ip_address, signature, created_at FROM document_signatures WHERE token_hash = ?")).
+		WithArgs("def").
+		WillReturnRows(rows)
+
+	signature, err := repo.GetSignatureByTokenHash(context.Background(), "def")
+
+	assert.NoError(t, err)
+	assert.Equal(t, &model.Signature{
+		ID:             9,
+		LoanDocumentID: 5,
+		LoanID:         1,
+		SignerRole:     model.SignerInvestor,
+		SignerID:       100,
+		Checksum:       "abc",
+		TokenHash:      "def",
+		SignedAt:       sql.NullTime{Time: signedAt, Valid: true},
+		IPAddress:      sql.NullString{String: "10.0.0.1", Valid: true},
+		Value:          sql.NullString{String: "c2ln", Valid: true},
+		CreatedAt:      createdAt,
+	}, signature)
+}
+
+func TestGetSignatureByTokenHashNotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewLoanRepository(db)
+
+	mock.ExpectQuery(regexp.QuoteMeta("FROM document_signatures WHERE token_hash = ?")).
+		WithArgs("def").
+		WillReturnRows(sqlmock.NewRows(signatureColumns))
+
+	_, err = repo.GetSignatureByTokenHash(context.Background(), "def")
+
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+}
+
+func TestGetSignaturesByLoanID(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewLoanRepository(db)
+
+	createdAt := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	rows := sqlmock.NewRows(signatureColumns).
+		AddRow(9, 5, 1, model.SignerInvestor, 100, "abc", "def", nil, nil, nil, createdAt).
+		AddRow(10, 6, 1, model.SignerBorrower, 123, "ghi", "jkl", nil, nil, nil, createdAt)
+
+	mock.ExpectQuery(regexp.QuoteMeta("FROM document_signatures WHERE loan_id = ? ORDER BY id")).
+		WithArgs(1).
+		WillReturnRows(rows)
+
+	signatures, err := repo.GetSignaturesByLoanID(context.Background(), 1)
+
+	assert.NoError(t, err)
+	assert.Len(t, signatures, 2)
+	assert.False(t, signatures[0].SignedAt.Valid)
+	assert.Equal(t, model.SignerBorrower, signatures[1].SignerRole)
+}
+
+func TestGetSignaturesByLoanDocumentID(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewLoanRepository(db)
+
+	mock.ExpectQuery(regexp.QuoteMeta("FROM document_signatures WHERE loan_document_id = ? ORDER BY id")).
+		WithArgs(5).
+		WillReturnRows(sqlmock.NewRows(signatureColumns))
+
+	signatures, err := repo.GetSignaturesByLoanDocumentID(context.Background(), 5)
+
+	assert.NoError(t, err)
+	assert.Empty(t, signatures)
+}
+
+func TestSignSignature(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewLoanRepository(db)
+
+	signedAt := time.Date(2021, 1, 2, 0, 0, 0, 0, time.UTC)
+	signature := &model.Signature{
+		ID:        9,
+		SignedAt:  sql.NullTime{Time: signedAt, Valid: true},
+		IPAddress: sql.NullString{String: "10.0.0.1", Valid: true},
+		Value:     sql.NullString{String: "c2ln", Valid: true},
+	}
+
+	query := regexp.QuoteMeta("UPDATE document_signatures SET signed_at = ?, ip_address = ?, signature = ? WHERE id = ? AND signed_at IS NULL")
+
+	mock.ExpectExec(query).
+		WithArgs(signature.SignedAt, signature.IPAddress, signature.Value, 9).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(query).
+		WithArgs(signature.SignedAt, signature.IPAddress, signature.Value, 9).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	assert.NoError(t, repo.SignSignature(context.Background(), signature))
+	assert.ErrorIs(t, repo.SignSignature(context.Background(), signature), sql.ErrNoRows)
+}
@@ -9,19 +9,21 @@ import (
 func (r *LoanRepository) GetUserByID(ctx context.Context, id int64) (*model.User, error) {
 	query := `
 		SELECT
-			id, name, created_at, last_updated_at
+			id, name, email, locale, created_at, last_updated_at
 		FROM
 			users
 		WHERE
 			id = ?
 	`
 
-	row := r.DB.QueryRowContext(ctx, query, id)
+	row := r.conn(ctx).QueryRowContext(ctx, query, id)
 
 	user := &model.User{}
 	err := row.Scan(
 		&user.ID,
 		&user.Name,
+		&user.Email,
+		&user.Locale,
 		&user.CreatedAt,
 		&user.LastUpdatedAt,
 	)
@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"database/sql"
 	"reflect"
 	"testing"
 	"time"
@@ -20,10 +21,10 @@ func TestGetUserByID(t *testing.T) {
 
 	createdAt, _ := time.Parse("2006-01-02 15:04:05", "2021-01-01 00:00:00")
 	lastUpdatedAt, _ := time.Parse("2006-01-02 15:04:05", "2021-01-01 00:00:00")
-	rows := sqlmock.NewRows([]string{"id", "name", "created_at", "last_updated_at"}).
-		AddRow(1, "John Doe", createdAt, lastUpdatedAt)
+	rows := sqlmock.NewRows([]string{"id", "name", "email", "locale", "created_at", "last_updated_at"}).
+		AddRow(1, "John Doe", "john.doe@example.com", "id", createdAt, lastUpdatedAt)
 
-	mock.ExpectQuery("SELECT id, name, created_at, last_updated_at FROM users WHERE id = ?").
+	mock.ExpectQuery("SELECT id, name, email, locale, created_at, last_updated_at FROM users WHERE id = ?").
 		WithArgs(1).
 		WillReturnRows(rows)
 
@@ -34,6 +35,8 @@ func TestGetUserByID(t *testing.T) {
 	assert.True(t, reflect.DeepEqual(user, &model.User{
 		ID:            1,
 		Name:          "John Doe",
+		Email:         sql.NullString{String: "john.doe@example.com", Valid: true},
+		Locale:        "id",
 		CreatedAt:     createdAt,
 		LastUpdatedAt: lastUpdatedAt,
 	}))
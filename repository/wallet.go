@@ -0,0 +1,236 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/aldipi/loan-service/model"
+)
+
+func (r *LoanRepository) GetWalletByInvestorID(ctx context.Context, investorID int64) (*model.Wallet, error) {
+	query := `
+		SELECT
+			investor_id, currency, available, reserved, deployed, last_updated_at
+		FROM
+			wallets
+		WHERE
+			investor_id = ?
+	`
+
+	return scanWallet(r.conn(ctx).QueryRowContext(ctx, query, investorID))
+}
+
+// LockWallet returns the wallet of the investor, locked until the end of the
+// transaction. The wallet is created empty, in the currency of the investor,
+// if the investor has none yet.
+func (r *LoanRepository) LockWallet(ctx context.Context, investorID int64) (*model.Wallet, error) {
+	_, err := r.conn(ctx).ExecContext(ctx, `
+		INSERT IGNORE INTO wallets (investor_id, currency)
+		SELECT id, currency FROM investors WHERE id = ?
+	`, investorID)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT
+			investor_id, currency, available, reserved, deployed, last_updated_at
+		FROM
+			wallets
+		WHERE
+			investor_id = ?
+		FOR UPDATE
+	`
+
+	return scanWallet(r.conn(ctx).QueryRowContext(ctx, query, investorID))
+}
+
+func (r *LoanRepository) UpdateWallet(ctx context.Context, wallet *model.Wallet) error {
+	query := `
+		UPDATE wallets
+		SET available = ?,
+			reserved = ?,
+			deployed = ?,
+			last_updated_at = ?
+		WHERE investor_id = ?
+	`
+
+	_, err := r.conn(ctx).ExecContext(ctx, query,
+		wallet.Available,
+		wallet.Reserved,
+		wallet.Deployed,
+		wallet.LastUpdatedAt,
+		wallet.InvestorID,
+	)
+
+	return err
+}
+
+func (r *LoanRepository) CreateWalletEntry(ctx context.Context, entry *model.WalletEntry) (id int64, err error) {
+	query := `
+		INSERT INTO wallet_entries (
+			investor_id, type, amount, currency, status, loan_id, investment_id, reference, idempotency_key, created_at
+		) VALUES (
+			?, ?, ?, ?, ?, ?, ?, ?, ?, ?
+		)
+	`
+
+	res, err := r.conn(ctx).ExecContext(ctx, query,
+		entry.InvestorID,
+		entry.Type,
+		entry.Amount,
+		entry.Currency,
+		entry.Status,
+		entry.LoanID,
+		entry.InvestmentID,
+		entry.Reference,
+		entry.IdempotencyKey,
+		entry.CreatedAt,
+	)
+
+	if err != nil {
+		return
+	}
+
+	return res.LastInsertId()
+}
+
+// LockWalletEntry returns the wallet entry, locked until the end of the
+// transaction.
+func (r *LoanRepository) LockWalletEntry(ctx context.Context, id int64) (*model.WalletEntry, error) {
+	query := `
+		SELECT
+			id, investor_id, type, amount, currency, status, loan_id, investment_id, reference, idempotency_key, created_at
+		FROM
+			wallet_entries
+		WHERE
+			id = ?
+		FOR UPDATE
+	`
+
+	return scanWalletEntry(r.conn(ctx).QueryRowContext(ctx, query, id))
+}
+
+// UpdateWalletEntry records the outcome of a transfer through the payment
+// rail.
+func (r *LoanRepository) UpdateWalletEntry(ctx context.Context, entry *model.WalletEntry) error {
+	query := `
+		UPDATE wallet_entries
+		SET status = ?,
+			reference = ?
+		WHERE id = ?
+	`
+
+	_, err := r.conn(ctx).ExecContext(ctx, query, entry.Status, entry.Reference, entry.ID)
+	return err
+}
+
+// GetWalletEntriesByInvestorID returns the latest entries of the wallet of the
+// investor, newest first.
+func (r *LoanRepository) GetWalletEntriesByInvestorID(ctx context.Context, investorID int64, limit int) ([]*model.WalletEntry, error) {
+	query := `
+		SELECT
+			id, investor_id, type, amount, currency, status, loan_id, investment_id, reference, idempotency_key, created_at
+		FROM
+			wallet_entries
+		WHERE
+			investor_id = ?
+		ORDER BY id DESC
+		LIMIT ?
+	`
+
+	return r.queryWalletEntries(ctx, query, investorID, limit)
+}
+
+// GetPendingWalletEntries returns the entries still waiting for the payment
+// rail that were made before the given time, oldest first.
+func (r *LoanRepository) GetPendingWalletEntries(ctx context.Context, before time.Time, limit int) ([]*model.WalletEntry, error) {
+	query := `
+		SELECT
+			id, investor_id, type, amount, currency, status, loan_id, investment_id, reference, idempotency_key, created_at
+		FROM
+			wallet_entries
+		WHERE
+			status = ? AND created_at < ?
+		ORDER BY created_at, id
+		LIMIT ?
+	`
+
+	return r.queryWalletEntries(ctx, query, model.WalletEntryPending, before, limit)
+}
+
+func (r *LoanRepository) queryWalletEntries(ctx context.Context, query string, args ...any) ([]*model.WalletEntry, error) {
+	rows, err := r.conn(ctx).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []*model.WalletEntry{}
+	for rows.Next() {
+		entry, err := scanWalletEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// SumWalletEntries adds up the amounts of the settled wallet entries of the
+// investor with the given type.
+func (r *LoanRepository) SumWalletEntries(ctx context.Context, investorID int64, entryType model.WalletEntryType) (int64, error) {
+	query := `
+		SELECT
+			COALESCE(SUM(amount), 0)
+		FROM
+			wallet_entries
+		WHERE
+			investor_id = ? AND type = ? AND status = ?
+	`
+
+	var sum int64
+	err := r.conn(ctx).QueryRowContext(ctx, query, investorID, entryType, model.WalletEntrySettled).Scan(&sum)
+	return sum, err
+}
+
+func scanWallet(row scanner) (*model.Wallet, error) {
+	wallet := &model.Wallet{}
+	err := row.Scan(
+		&wallet.InvestorID,
+		&wallet.Currency,
+		&wallet.Available,
+		&wallet.Reserved,
+		&wallet.Deployed,
+		&wallet.LastUpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return wallet, nil
+}
+
+func scanWalletEntry(row scanner) (*model.WalletEntry, error) {
+	entry := &model.WalletEntry{}
+	err := row.Scan(
+		&entry.ID,
+		&entry.InvestorID,
+		&entry.Type,
+		&entry.Amount,
+		&entry.Currency,
+		&entry.Status,
+		&entry.LoanID,
+		&entry.InvestmentID,
+		&entry.Reference,
+		&entry.IdempotencyKey,
+		&entry.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return entry, nil
+}
@@ -0,0 +1,250 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/aldipi/loan-service/model"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	walletColumns      = []string{"investor_id", "currency", "available", "reserved", "deployed", "last_updated_at"}
+	walletEntryColumns = []string{"id", "investor_id", "type", "amount", "currency", "status", "loan_id", "investment_id", "reference", "idempotency_key", "created_at"}
+)
+
+func TestGetWalletByInvestorID(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewLoanRepository(db)
+
+	updatedAt := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT investor_id, currency, available, reserved, deployed, last_updated_at FROM wallets WHERE investor_id = ?")).
+		WithArgs(100).
+		WillReturnRows(sqlmock.NewRows(walletColumns).AddRow(100, "IDR", 500000, 200000, 300000, updatedAt))
+
+	wallet, err := repo.GetWalletByInvestorID(context.Background(), 100)
+
+	assert.NoError(t, err)
+	assert.Equal(t, &model.Wallet{InvestorID: 100, Currency: "IDR", Available: 500000, Reserved: 200000, Deployed: 300000, LastUpdatedAt: updatedAt}, wallet)
+}
+
+func TestGetWalletByInvestorIDNotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewLoanRepository(db)
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT investor_id, currency, available, reserved, deployed, last_updated_at FROM wallets WHERE investor_id = ?")).
+		WithArgs(100).
+		WillReturnError(sql.ErrNoRows)
+
+	wallet, err := repo.GetWalletByInvestorID(context.Background(), 100)
+
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+	assert.Nil(t, wallet)
+}
+
+func TestLockWallet(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewLoanRepository(db)
+
+	updatedAt := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	mock.ExpectExec(regexp.QuoteMeta("INSERT IGNORE INTO wallets (investor_id, currency) SELECT id, currency FROM investors WHERE id = ?")).
+		WithArgs(100).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT investor_id, currency, available, reserved, deployed, last_updated_at FROM wallets WHERE investor_id = ? FOR UPDATE")).
+		WithArgs(100).
+		WillReturnRows(sqlmock.NewRows(walletColumns).AddRow(100, "IDR", 0, 0, 0, updatedAt))
+
+	wallet, err := repo.LockWallet(context.Background(), 100)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(100), wallet.InvestorID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpdateWallet(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewLoanRepository(db)
+
+	updatedAt := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE wallets SET available = ?, reserved = ?, deployed = ?, last_updated_at = ? WHERE investor_id = ?")).
+		WithArgs(100, 200, 300, updatedAt, 100).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err = repo.UpdateWallet(context.Background(), &model.Wallet{InvestorID: 100, Available: 100, Reserved: 200, Deployed: 300, LastUpdatedAt: updatedAt})
+
+	assert.NoError(t, err)
+}
+
+func TestCreateWalletEntry(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewLoanRepository(db)
+
+	createdAt := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO wallet_entries ( investor_id, type, amount, currency, status, loan_id, investment_id, reference, idempotency_key, created_at ) VALUES ( ?, ?, ?, ?, ?, ?, ?, ?, ?, ? )")).
+		WithArgs(100, model.WalletReservation, 200000, "IDR", model.WalletEntrySettled, sql.NullInt64{Int64: 1, Valid: true}, sql.NullInt64{Int64: 5, Valid: true}, sql.NullString{}, sql.NullString{}, createdAt).
+		WillReturnResult(sqlmock.NewResult(7, 1))
+
+	id, err := repo.CreateWalletEntry(context.Background(), &model.WalletEntry{
+		InvestorID:   100,
+		Type:         model.WalletReservation,
+		Amount:       200000,
+		Currency:     "IDR",
+		Status:       model.WalletEntrySettled,
+		LoanID:       sql.NullInt64{Int64: 1, Valid: true},
+		InvestmentID: sql.NullInt64{Int64: 5, Valid: true},
+		CreatedAt:    createdAt,
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(7), id)
+}
+
+func TestLockWalletEntry(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewLoanRepository(db)
+
+	createdAt := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id, investor_id, type, amount, currency, status, loan_id, investment_id, reference, idempotency_key, created_at FROM wallet_entries WHERE id = ? FOR UPDATE")).
+		WithArgs(7).
+		WillReturnRows(sqlmock.NewRows(walletEntryColumns).AddRow(7, 100, model.WalletDeposit, 500000, "IDR", model.WalletEntryPending, nil, nil, nil, "key-7", createdAt))
+
+	entry, err := repo.LockWalletEntry(context.Background(), 7)
+
+	assert.NoError(t, err)
+	assert.Equal(t, &model.WalletEntry{
+		ID:             7,
+		InvestorID:     100,
+		Type:           model.WalletDeposit,
+		Amount:         500000,
+		Currency:       "IDR",
+		Status:         model.WalletEntryPending,
+		IdempotencyKey: sql.NullString{String: "key-7", Valid: true},
+		CreatedAt:      createdAt,
+	}, entry)
+}
+
+func TestUpdateWalletEntry(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewLoanRepository(db)
+
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE wallet_entries SET status = ?, reference = ? WHERE id = ?")).
+		WithArgs(model.WalletEntrySettled, sql.NullString{String: "fake-1", Valid: true}, 7).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err = repo.UpdateWalletEntry(context.Background(), &model.WalletEntry{
+		ID:        7,
+		Status:    model.WalletEntrySettled,
+		Reference: sql.NullString{String: "fake-1", Valid: true},
+	})
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetPendingWalletEntries(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewLoanRepository(db)
+
+	before := time.Date(2021, 1, 1, 0, 5, 0, 0, time.UTC)
+	createdAt := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id, investor_id, type, amount, currency, status, loan_id, investment_id, reference, idempotency_key, created_at FROM wallet_entries WHERE status = ? AND created_at < ? ORDER BY created_at, id LIMIT ?")).
+		WithArgs(model.WalletEntryPending, before, 50).
+		WillReturnRows(sqlmock.NewRows(walletEntryColumns).AddRow(7, 100, model.WalletWithdrawal, 300000, "IDR", model.WalletEntryPending, nil, nil, nil, "key-7", createdAt))
+
+	entries, err := repo.GetPendingWalletEntries(context.Background(), before, 50)
+
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+	assert.Equal(t, int64(7), entries[0].ID)
+	assert.Equal(t, sql.NullString{String: "key-7", Valid: true}, entries[0].IdempotencyKey)
+}
+
+func TestGetWalletEntriesByInvestorID(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewLoanRepository(db)
+
+	createdAt := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	rows := sqlmock.NewRows(walletEntryColumns).
+		AddRow(2, 100, model.WalletReservation, 200000, "IDR", model.WalletEntrySettled, 1, 5, nil, nil, createdAt).
+		AddRow(1, 100, model.WalletDeposit, 500000, "IDR", model.WalletEntrySettled, nil, nil, "fake-1", "key-1", createdAt)
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id, investor_id, type, amount, currency, status, loan_id, investment_id, reference, idempotency_key, created_at FROM wallet_entries WHERE investor_id = ? ORDER BY id DESC LIMIT ?")).
+		WithArgs(100, 10).
+		WillReturnRows(rows)
+
+	entries, err := repo.GetWalletEntriesByInvestorID(context.Background(), 100, 10)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []*model.WalletEntry{
+		{
+			ID:           2,
+			InvestorID:   100,
+			Type:         model.WalletReservation,
+			Amount:       200000,
+			Currency:     "IDR",
+			Status:       model.WalletEntrySettled,
+			LoanID:       sql.NullInt64{Int64: 1, Valid: true},
+			InvestmentID: sql.NullInt64{Int64: 5, Valid: true},
+			CreatedAt:    createdAt,
+		},
+		{
+			ID:             1,
+			InvestorID:     100,
+			Type:           model.WalletDeposit,
+			Amount:         500000,
+			Currency:       "IDR",
+			Status:         model.WalletEntrySettled,
+			Reference:      sql.NullString{String: "fake-1", Valid: true},
+			IdempotencyKey: sql.NullString{String: "key-1", Valid: true},
+			CreatedAt:      createdAt,
+		},
+	}, entries)
+}
+
+func TestSumWalletEntries(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewLoanRepository(db)
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT COALESCE(SUM(amount), 0) FROM wallet_entries WHERE investor_id = ? AND type = ? AND status = ?")).
+		WithArgs(100, model.WalletPayout, model.WalletEntrySettled).
+		WillReturnRows(sqlmock.NewRows([]string{"sum"}).AddRow(15000))
+
+	sum, err := repo.SumWalletEntries(context.Background(), 100, model.WalletPayout)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(15000), sum)
+}
@@ -0,0 +1,241 @@
+package repository
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/aldipi/loan-service/model"
+)
+
+func (r *LoanRepository) CreateWebhook(ctx context.Context, webhook *model.Webhook) (id int64, err error) {
+	query := `
+		INSERT INTO webhooks (url, event_types, secret)
+		VALUES (?, ?, ?)
+	`
+
+	res, err := r.conn(ctx).ExecContext(ctx, query,
+		webhook.URL,
+		joinEventTypes(webhook.EventTypes),
+		webhook.Secret,
+	)
+
+	if err != nil {
+		return
+	}
+
+	return res.LastInsertId()
+}
+
+func (r *LoanRepository) GetWebhookByID(ctx context.Context, id int64) (*model.Webhook, error) {
+	query := `
+		SELECT
+			id, url, event_types, secret, created_at, last_updated_at
+		FROM
+			webhooks
+		WHERE
+			id = ?
+	`
+
+	row := r.conn(ctx).QueryRowContext(ctx, query, id)
+
+	webhook := &model.Webhook{}
+	var eventTypes string
+	err := row.Scan(
+		&webhook.ID,
+		&webhook.URL,
+		&eventTypes,
+		&webhook.Secret,
+		&webhook.CreatedAt,
+		&webhook.LastUpdatedAt,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	webhook.EventTypes = splitEventTypes(eventTypes)
+
+	return webhook, nil
+}
+
+func (r *LoanRepository) GetWebhooksByEventType(ctx context.Context, eventType model.LoanEventType) ([]*model.Webhook, error) {
+	query := `
+		SELECT
+			id, url, event_types, secret, created_at, last_updated_at
+		FROM
+			webhooks
+		WHERE
+			FIND_IN_SET(?, event_types) > 0
+	`
+
+	rows, err := r.conn(ctx).QueryContext(ctx, query, eventType)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	webhooks := []*model.Webhook{}
+	for rows.Next() {
+		webhook := &model.Webhook{}
+		var eventTypes string
+		err = rows.Scan(
+			&webhook.ID,
+			&webhook.URL,
+			&eventTypes,
+			&webhook.Secret,
+			&webhook.CreatedAt,
+			&webhook.LastUpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		webhook.EventTypes = splitEventTypes(eventTypes)
+		webhooks = append(webhooks, webhook)
+	}
+
+	return webhooks, nil
+}
+
+// CreateWebhookDelivery queues a delivery. A delivery of the same outbox
+// message to the same webhook is ignored, so republishing a message is safe.
+func (r *LoanRepository) CreateWebhookDelivery(ctx context.Context, delivery *model.WebhookDelivery) error {
+	query := `
+		INSERT IGNORE INTO webhook_deliveries (
+			webhook_id, outbox_message_id, event_type, payload, status, next_attempt_at
+		) VALUES (
+			?, ?, ?, ?, ?, ?
+		)
+	`
+
+	_, err := r.conn(ctx).ExecContext(ctx, query,
+		delivery.WebhookID,
+		delivery.OutboxMessageID,
+		delivery.EventType,
+		delivery.Payload,
+		delivery.Status,
+		delivery.NextAttemptAt,
+	)
+
+	return err
+}
+
+func (r *LoanRepository) GetPendingWebhookDeliveries(ctx context.Context, at time.Time, limit int) ([]*model.WebhookDelivery, error) {
+	query := `
+		SELECT
+			id, webhook_id, outbox_message_id, event_type, payload, status, attempts,
+			next_attempt_at, last_response_status, last_error, delivered_at,
+			created_at, last_updated_at
+		FROM
+			webhook_deliveries
+		WHERE
+			status = ? AND next_attempt_at <= ?
+		ORDER BY id
+		LIMIT ?
+	`
+
+	rows, err := r.conn(ctx).QueryContext(ctx, query, model.WebhookDeliveryPending, at, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanWebhookDeliveries(rows)
+}
+
+func (r *LoanRepository) GetWebhookDeliveriesByWebhookID(ctx context.Context, webhookID int64, limit int, offset int) ([]*model.WebhookDelivery, error) {
+	query := `
+		SELECT
+			id, webhook_id, outbox_message_id, event_type, payload, status, attempts,
+			next_attempt_at, last_response_status, last_error, delivered_at,
+			created_at, last_updated_at
+		FROM
+			webhook_deliveries
+		WHERE
+			webhook_id = ?
+		ORDER BY id DESC
+		LIMIT ? OFFSET ?
+	`
+
+	rows, err := r.conn(ctx).QueryContext(ctx, query, webhookID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanWebhookDeliveries(rows)
+}
+
+func (r *LoanRepository) UpdateWebhookDelivery(ctx context.Context, delivery *model.WebhookDelivery) error {
+	query := `
+		UPDATE webhook_deliveries
+		SET status = ?,
+			attempts = ?,
+			next_attempt_at = ?,
+			last_response_status = ?,
+			last_error = ?,
+			delivered_at = ?,
+			last_updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?
+	`
+
+	_, err := r.conn(ctx).ExecContext(ctx, query,
+		delivery.Status,
+		delivery.Attempts,
+		delivery.NextAttemptAt,
+		delivery.LastResponseStatus,
+		delivery.LastError,
+		delivery.DeliveredAt,
+		delivery.ID,
+	)
+
+	return err
+}
+
+func scanWebhookDeliveries(rows sqlRows) ([]*model.WebhookDelivery, error) {
+	deliveries := []*model.WebhookDelivery{}
+	for rows.Next() {
+		delivery := &model.WebhookDelivery{}
+		err := rows.Scan(
+			&delivery.ID,
+			&delivery.WebhookID,
+			&delivery.OutboxMessageID,
+			&delivery.EventType,
+			&delivery.Payload,
+			&delivery.Status,
+			&delivery.Attempts,
+			&delivery.NextAttemptAt,
+			&delivery.LastResponseStatus,
+			&delivery.LastError,
+			&delivery.DeliveredAt,
+			&delivery.CreatedAt,
+			&delivery.LastUpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		deliveries = append(deliveries, delivery)
+	}
+
+	return deliveries, nil
+}
+
+func joinEventTypes(eventTypes []model.LoanEventType) string {
+	values := make([]string, len(eventTypes))
+	for i, eventType := range eventTypes {
+		values[i] = string(eventType)
+	}
+	return strings.Join(values, ",")
+}
+
+func splitEventTypes(value string) []model.LoanEventType {
+	eventTypes := []model.LoanEventType{}
+	for _, eventType := range strings.Split(value, ",") {
+		if eventType != "" {
+			eventTypes = append(eventTypes, model.LoanEventType(eventType))
+		}
+	}
+	return eventTypes
+}
@@ -0,0 +1,216 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"reflect"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/aldipi/loan-service/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateWebhook(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewLoanRepository(db)
+
+	query := regexp.QuoteMeta(`
+		INSERT INTO webhooks (url, event_types, secret)
+		VALUES (?, ?, ?)
+	`)
+
+	mock.ExpectExec(query).
+		WithArgs("https://partner.example.com/hooks", "LoanApproved,LoanDisbursed", "0123456789abcdef").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	id, err := repo.CreateWebhook(context.Background(), &model.Webhook{
+		URL:        "https://partner.example.com/hooks",
+		EventTypes: []model.LoanEventType{model.LoanEventApproved, model.LoanEventDisbursed},
+		Secret:     "0123456789abcdef",
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), id)
+}
+
+func TestGetWebhookByID(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewLoanRepository(db)
+
+	createdAt := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	rows := sqlmock.NewRows([]string{"id", "url", "event_types", "secret", "created_at", "last_updated_at"}).
+		AddRow(1, "https://partner.example.com/hooks", "LoanApproved,LoanDisbursed", "0123456789abcdef", createdAt, createdAt)
+
+	mock.ExpectQuery("SELECT id, url, event_types, secret, created_at, last_updated_at FROM webhooks WHERE id = ?").
+		WithArgs(1).
+		WillReturnRows(rows)
+
+	webhook, err := repo.GetWebhookByID(context.Background(), 1)
+
+	assert.NoError(t, err)
+	assert.True(t, reflect.DeepEqual(webhook, &model.Webhook{
+		ID:            1,
+		URL:           "https://partner.example.com/hooks",
+		EventTypes:    []model.LoanEventType{model.LoanEventApproved, model.LoanEventDisbursed},
+		Secret:        "0123456789abcdef",
+		CreatedAt:     createdAt,
+		LastUpdatedAt: createdAt,
+	}))
+}
+
+func TestGetWebhooksByEventType(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewLoanRepository(db)
+
+	createdAt := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	rows := sqlmock.NewRows([]string{"id", "url", "event_types", "secret", "created_at", "last_updated_at"}).
+		AddRow(1, "https://partner.example.com/hooks", "LoanApproved", "0123456789abcdef", createdAt, createdAt)
+
+	query := regexp.QuoteMeta(`
+		SELECT
+			id, url, event_types, secret, created_at, last_updated_at
+		FROM
+			webhooks
+		WHERE
+			FIND_IN_SET(?, event_types) > 0
+	`)
+
+	mock.ExpectQuery(query).WithArgs(model.LoanEventApproved).WillReturnRows(rows)
+
+	webhooks, err := repo.GetWebhooksByEventType(context.Background(), model.LoanEventApproved)
+
+	assert.NoError(t, err)
+	assert.Len(t, webhooks, 1)
+	assert.Equal(t, []model.LoanEventType{model.LoanEventApproved}, webhooks[0].EventTypes)
+}
+
+func TestCreateWebhookDelivery(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewLoanRepository(db)
+
+	at := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	payload := json.RawMessage(`{"id":3}`)
+
+	query := regexp.QuoteMeta(`
+		INSERT IGNORE INTO webhook_deliveries (
+			webhook_id, outbox_message_id, event_type, payload, status, next_attempt_at
+		) VALUES (
+			?, ?, ?, ?, ?, ?
+		)
+	`)
+
+	mock.ExpectExec(query).
+		WithArgs(1, 3, model.LoanEventApproved, payload, model.WebhookDeliveryPending, at).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	err = repo.CreateWebhookDelivery(context.Background(), &model.WebhookDelivery{
+		WebhookID:       1,
+		OutboxMessageID: 3,
+		EventType:       model.LoanEventApproved,
+		Payload:         payload,
+		Status:          model.WebhookDeliveryPending,
+		NextAttemptAt:   at,
+	})
+
+	assert.NoError(t, err)
+}
+
+func TestGetWebhookDeliveriesByWebhookID(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewLoanRepository(db)
+
+	at := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	rows := sqlmock.NewRows([]string{"id", "webhook_id", "outbox_message_id", "event_type", "payload", "status", "attempts", "next_attempt_at", "last_response_status", "last_error", "delivered_at", "created_at", "last_updated_at"}).
+		AddRow(5, 1, 3, model.LoanEventApproved, []byte(`{"id":3}`), model.WebhookDeliveryDead, 8, at, 500, "unexpected status 500", nil, at, at)
+
+	query := regexp.QuoteMeta(`
+		SELECT
+			id, webhook_id, outbox_message_id, event_type, payload, status, attempts,
+			next_attempt_at, last_response_status, last_error, delivered_at,
+			created_at, last_updated_at
+		FROM
+			webhook_deliveries
+		WHERE
+			webhook_id = ?
+		ORDER BY id DESC
+		LIMIT ? OFFSET ?
+	`)
+
+	mock.ExpectQuery(query).WithArgs(1, 10, 0).WillReturnRows(rows)
+
+	deliveries, err := repo.GetWebhookDeliveriesByWebhookID(context.Background(), 1, 10, 0)
+
+	assert.NoError(t, err)
+	assert.Len(t, deliveries, 1)
+	assert.True(t, reflect.DeepEqual(deliveries[0], &model.WebhookDelivery{
+		ID:                 5,
+		WebhookID:          1,
+		OutboxMessageID:    3,
+		EventType:          model.LoanEventApproved,
+		Payload:            json.RawMessage(`{"id":3}`),
+		Status:             model.WebhookDeliveryDead,
+		Attempts:           8,
+		NextAttemptAt:      at,
+		LastResponseStatus: sql.NullInt64{Int64: 500, Valid: true},
+		LastError:          sql.NullString{String: "unexpected status 500", Valid: true},
+		CreatedAt:          at,
+		LastUpdatedAt:      at,
+	}))
+}
+
+func TestUpdateWebhookDelivery(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewLoanRepository(db)
+
+	at := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	deliveredAt := sql.NullTime{Time: at, Valid: true}
+
+	query := regexp.QuoteMeta(`
+		UPDATE webhook_deliveries
+		SET status = ?,
+			attempts = ?,
+			next_attempt_at = ?,
+			last_response_status = ?,
+			last_error = ?,
+			delivered_at = ?,
+			last_updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?
+	`)
+
+	mock.ExpectExec(query).
+		WithArgs(model.WebhookDeliveryDelivered, 1, at, sql.NullInt64{Int64: 200, Valid: true}, sql.NullString{}, deliveredAt, 5).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err = repo.UpdateWebhookDelivery(context.Background(), &model.WebhookDelivery{
+		ID:                 5,
+		Status:             model.WebhookDeliveryDelivered,
+		Attempts:           1,
+		NextAttemptAt:      at,
+		LastResponseStatus: sql.NullInt64{Int64: 200, Valid: true},
+		DeliveredAt:        deliveredAt,
+	})
+
+	assert.NoError(t, err)
+}
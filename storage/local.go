@@ -0,0 +1,171 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidKey = errors.New("invalid storage key")
+
+// LocalStore keeps blobs as files under a directory. Blobs are downloaded
+// through the store itself, which serves a file only for URLs signed with the
+// store secret that have not expired yet.
+type LocalStore struct {
+	dir     string
+	baseURL string
+	secret  []byte
+}
+
+func NewLocalStore(dir string, baseURL string, secret []byte) *LocalStore {
+	return &LocalStore{dir: dir, baseURL: strings.TrimRight(baseURL, "/"), secret: secret}
+}
+
+// Put writes data to the file at key, replacing any existing file. The
+// content type is implied by the key extension when the file is served.
+func (s *LocalStore) Put(ctx context.Context, key string, contentType string, data []byte) error {
+	return s.PutReader(ctx, key, contentType, bytes.NewReader(data), int64(len(data)))
+}
+
+// PutReader writes the size bytes read from body to the file at key, like Put,
+// without holding them in memory.
+func (s *LocalStore) PutReader(ctx context.Context, key string, contentType string, body io.Reader, size int64) error {
+	name, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(name), 0o755)
+	if err != nil {
+		return err
+	}
+
+	// Write to a temporary file first so readers never see a partial blob.
+	tmp := name + ".tmp"
+	err = writeFile(tmp, body, size)
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	err = os.Rename(tmp, name)
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	return nil
+}
+
+func writeFile(name string, body io.Reader, size int64) error {
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+
+	n, err := io.Copy(f, io.LimitReader(body, size))
+	if err == nil && n != size {
+		err = io.ErrUnexpectedEOF
+	}
+	closeErr := f.Close()
+	if err != nil {
+		return err
+	}
+	return closeErr
+}
+
+func (s *LocalStore) Get(ctx context.Context, key string) ([]byte, error) {
+	name, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return os.ReadFile(name)
+}
+
+// Delete removes the file at key. Deleting a key without a file succeeds.
+func (s *LocalStore) Delete(ctx context.Context, key string) error {
+	name, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+
+	return err
+}
+
+// SignedURL returns a URL that downloads the blob at key until it expires.
+func (s *LocalStore) SignedURL(ctx context.Context, key string, expiresIn time.Duration) (string, error) {
+	_, err := s.path(key)
+	if err != nil {
+		return "", err
+	}
+
+	expires := strconv.FormatInt(time.Now().Add(expiresIn).Unix(), 10)
+	query := url.Values{}
+	query.Set("expires", expires)
+	query.Set("signature", s.sign(key, expires))
+
+	return s.baseURL + "/" + key + "?" + query.Encode(), nil
+}
+
+// ServeHTTP serves the blob named by the request path relative to the base
+// URL. Requests without a valid signature get 403 Forbidden.
+func (s *LocalStore) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	key := strings.TrimPrefix(r.URL.Path, "/")
+	expires := r.URL.Query().Get("expires")
+
+	expiresAt, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || time.Now().Unix() > expiresAt ||
+		!hmac.Equal([]byte(r.URL.Query().Get("signature")), []byte(s.sign(key, expires))) {
+		http.Error(w, "invalid or expired signature", http.StatusForbidden)
+		return
+	}
+
+	name, err := s.path(key)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	http.ServeFile(w, r, name)
+}
+
+func (s *LocalStore) sign(key string, expires string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(key))
+	mac.Write([]byte("\n"))
+	mac.Write([]byte(expires))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// path returns the file name of key. Keys are slash separated relative paths
+// and may not escape the store directory.
+func (s *LocalStore) path(key string) (string, error) {
+	if !validKey(key) {
+		return "", ErrInvalidKey
+	}
+
+	return filepath.Join(s.dir, filepath.FromSlash(key)), nil
+}
+
+func validKey(key string) bool {
+	return key != "" && path.Clean("/"+key) == "/"+key
+}
@@ -0,0 +1,104 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLocalStorePut(t *testing.T) {
+	dir := t.TempDir()
+	store := NewLocalStore(dir, "http://localhost:8080/documents/", []byte("secret"))
+
+	err := store.Put(context.Background(), "agreements/loans/1/agreement.pdf", "application/pdf", []byte("%PDF-1.4"))
+
+	assert.NoError(t, err)
+
+	data, err := os.ReadFile(filepath.Join(dir, "agreements", "loans", "1", "agreement.pdf"))
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("%PDF-1.4"), data)
+
+	data, err = store.Get(context.Background(), "agreements/loans/1/agreement.pdf")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("%PDF-1.4"), data)
+
+	_, err = store.Get(context.Background(), "agreements/loans/2/agreement.pdf")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+
+	err = store.Delete(context.Background(), "agreements/loans/1/agreement.pdf")
+	assert.NoError(t, err)
+	_, err = store.Get(context.Background(), "agreements/loans/1/agreement.pdf")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+
+	err = store.Delete(context.Background(), "agreements/loans/1/agreement.pdf")
+	assert.NoError(t, err)
+}
+
+func TestLocalStorePutReader(t *testing.T) {
+	dir := t.TempDir()
+	store := NewLocalStore(dir, "http://localhost:8080/documents", []byte("secret"))
+
+	err := store.PutReader(context.Background(), "exports/1/loans.csv", "text/csv", strings.NewReader("id\n1\n"), 5)
+	assert.NoError(t, err)
+
+	data, err := os.ReadFile(filepath.Join(dir, "exports", "1", "loans.csv"))
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("id\n1\n"), data)
+
+	// A body shorter than its size leaves no file behind.
+	err = store.PutReader(context.Background(), "exports/2/loans.csv", "text/csv", strings.NewReader("id\n"), 5)
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	entries, _ := os.ReadDir(filepath.Join(dir, "exports", "2"))
+	assert.Empty(t, entries)
+}
+
+func TestLocalStorePutRejectsInvalidKey(t *testing.T) {
+	store := NewLocalStore(t.TempDir(), "http://localhost:8080/documents", []byte("secret"))
+
+	for _, key := range []string{"", "../secret", "/etc/passwd", "a/../../b", "a//b"} {
+		err := store.Put(context.Background(), key, "application/pdf", []byte("x"))
+		assert.ErrorIs(t, err, ErrInvalidKey, key)
+	}
+}
+
+func TestLocalStoreSignedURL(t *testing.T) {
+	store := NewLocalStore(t.TempDir(), "", []byte("secret"))
+	server := httptest.NewServer(store)
+	defer server.Close()
+	store.baseURL = server.URL
+
+	err := store.Put(context.Background(), "proofs/1.jpg", "image/jpeg", []byte("jpeg"))
+	assert.NoError(t, err)
+
+	signedURL, err := store.SignedURL(context.Background(), "proofs/1.jpg", time.Minute)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(signedURL, server.URL+"/proofs/1.jpg?expires="))
+
+	resp, err := http.Get(signedURL)
+	assert.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, []byte("jpeg"), body)
+
+	resp, err = http.Get(strings.Replace(signedURL, "1.jpg", "2.jpg", 1))
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	expiredURL, err := store.SignedURL(context.Background(), "proofs/1.jpg", -time.Minute)
+	assert.NoError(t, err)
+	resp, err = http.Get(expiredURL)
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+}
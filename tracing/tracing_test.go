@@ -0,0 +1,96 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func newRecorder() (*tracetest.SpanRecorder, trace.Tracer) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	return recorder, provider.Tracer("test")
+}
+
+func TestStart(t *testing.T) {
+	recorder, tracer := newRecorder()
+
+	ctx, root := tracer.Start(context.Background(), "GET /loans", trace.WithSpanKind(trace.SpanKindServer))
+	childCtx, child := Start(ctx, "LoanUsecase.GetLoans", attribute.String("filter", "all"))
+	child.SetAttributes(attribute.Int("count", 2))
+	End(child, errors.New("boom"))
+	End(root, nil)
+
+	assert.Equal(t, child, trace.SpanFromContext(childCtx))
+	spans := recorder.Ended()
+	assert.Len(t, spans, 2)
+
+	childData, rootData := spans[0], spans[1]
+	assert.Equal(t, rootData.SpanContext().TraceID(), childData.SpanContext().TraceID())
+	assert.Equal(t, rootData.SpanContext().SpanID(), childData.Parent().SpanID())
+	assert.Equal(t, trace.SpanKindInternal, childData.SpanKind())
+	assert.Equal(t, []attribute.KeyValue{attribute.String("filter", "all"), attribute.Int("count", 2)}, childData.Attributes())
+	assert.Equal(t, codes.Error, childData.Status().Code)
+	assert.Equal(t, "boom", childData.Status().Description)
+	assert.Equal(t, codes.Unset, rootData.Status().Code)
+}
+
+func TestStartWithoutSpan(t *testing.T) {
+	ctx, span := Start(context.Background(), "LoanUsecase.GetLoans")
+
+	assert.False(t, span.SpanContext().IsValid())
+	assert.False(t, span.IsRecording())
+	assert.Equal(t, context.Background(), ctx)
+	span.SetAttributes(attribute.Int("count", 1))
+	End(span, errors.New("boom"))
+}
+
+func TestTransport(t *testing.T) {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator())
+
+	var received string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Get("traceparent")
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	recorder, tracer := newRecorder()
+	ctx, root := tracer.Start(context.Background(), "GET /loans", trace.WithSpanKind(trace.SpanKindServer))
+	client := &http.Client{Transport: NewTransport(nil)}
+
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/file.pdf?signature=secret", nil)
+	resp, err := client.Do(req)
+	assert.NoError(t, err)
+	resp.Body.Close()
+	root.End()
+
+	assert.Empty(t, req.Header.Get("traceparent"))
+	spans := recorder.Ended()
+	assert.Len(t, spans, 2)
+	span := spans[0]
+	assert.Equal(t, trace.SpanKindClient, span.SpanKind())
+	assert.Equal(t, root.SpanContext().SpanID(), span.Parent().SpanID())
+	assert.Equal(t, "00-"+span.SpanContext().TraceID().String()+"-"+span.SpanContext().SpanID().String()+"-01", received)
+	assert.Contains(t, span.Attributes(), attribute.String("url.full", server.URL+"/file.pdf"))
+	assert.Contains(t, span.Attributes(), attribute.Int("http.response.status_code", http.StatusAccepted))
+
+	req, _ = http.NewRequest(http.MethodGet, server.URL, nil)
+	resp, err = client.Do(req)
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Empty(t, received)
+	assert.Len(t, recorder.Ended(), 2)
+}
@@ -0,0 +1,92 @@
+package usecase
+
+import (
+	"context"
+
+	"github.com/aldipi/loan-service/model"
+	"github.com/aldipi/loan-service/tracing"
+)
+
+// The batch getters below load the relations of many loans at once, so
+// callers walking a list of loans make one query per relation rather than one
+// per loan. IDs without a record are left out of the returned maps.
+
+// GetInvestmentsByLoanIDs returns the investments of the loans by loan ID,
+// with signed agreement URLs.
+func (u *LoanUsecase) GetInvestmentsByLoanIDs(ctx context.Context, loanIDs []int64) (investments map[int64][]*model.Investment, err error) {
+	ctx, span := tracing.Start(ctx, "LoanUsecase.GetInvestmentsByLoanIDs")
+	defer func() { tracing.End(span, err) }()
+
+	list, err := u.repo.GetInvestmentsByLoanIDs(ctx, loanIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	err = u.signInvestmentURLs(ctx, list...)
+	if err != nil {
+		return nil, err
+	}
+
+	investments = map[int64][]*model.Investment{}
+	for _, investment := range list {
+		investments[investment.LoanID] = append(investments[investment.LoanID], investment)
+	}
+
+	return investments, nil
+}
+
+func (u *LoanUsecase) GetLoanProductsByIDs(ctx context.Context, ids []int64) (loanProducts map[int64]*model.LoanProduct, err error) {
+	ctx, span := tracing.Start(ctx, "LoanUsecase.GetLoanProductsByIDs")
+	defer func() { tracing.End(span, err) }()
+
+	list, err := u.repo.GetLoanProductsByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	return byID(list, func(p *model.LoanProduct) int64 { return p.ID }), nil
+}
+
+func (u *LoanUsecase) GetUsersByIDs(ctx context.Context, ids []int64) (users map[int64]*model.User, err error) {
+	ctx, span := tracing.Start(ctx, "LoanUsecase.GetUsersByIDs")
+	defer func() { tracing.End(span, err) }()
+
+	list, err := u.repo.GetUsersByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	return byID(list, func(user *model.User) int64 { return user.ID }), nil
+}
+
+func (u *LoanUsecase) GetEmployeesByIDs(ctx context.Context, ids []int64) (employees map[int64]*model.Employee, err error) {
+	ctx, span := tracing.Start(ctx, "LoanUsecase.GetEmployeesByIDs")
+	defer func() { tracing.End(span, err) }()
+
+	list, err := u.repo.GetEmployeesByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	return byID(list, func(e *model.Employee) int64 { return e.ID }), nil
+}
+
+func (u *LoanUsecase) GetInvestorsByIDs(ctx context.Context, ids []int64) (investors map[int64]*model.Investor, err error) {
+	ctx, span := tracing.Start(ctx, "LoanUsecase.GetInvestorsByIDs")
+	defer func() { tracing.End(span, err) }()
+
+	list, err := u.repo.GetInvestorsByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	return byID(list, func(i *model.Investor) int64 { return i.ID }), nil
+}
+
+func byID[T any](items []T, id func(T) int64) map[int64]T {
+	m := make(map[int64]T, len(items))
+	for _, item := range items {
+		m[id(item)] = item
+	}
+	return m
+}
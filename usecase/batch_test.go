@@ -0,0 +1,99 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aldipi/loan-service/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestGetInvestmentsByLoanIDs(t *testing.T) {
+	repo := new(MockRepository)
+	store := new(mockBlobStore)
+	uc := NewLoanUsecase(repo, WithDocuments(nil, store), WithSignedURLExpiry(time.Minute))
+
+	repo.On("GetInvestmentsByLoanIDs", mock.Anything, []int64{1, 2, 3}).Return([]*model.Investment{
+		{ID: 10, LoanID: 1, InvestorID: 100, AgreementLetter: "investments/10.pdf"},
+		{ID: 11, LoanID: 2, InvestorID: 101, AgreementLetter: "https://file.io/11.pdf"},
+		{ID: 12, LoanID: 1, InvestorID: 101, AgreementLetter: "investments/12.pdf"},
+	}, nil)
+	store.On("SignedURL", mock.Anything, "investments/10.pdf", time.Minute).Return("http://localhost/10.pdf?signature=abc", nil)
+	store.On("SignedURL", mock.Anything, "investments/12.pdf", time.Minute).Return("http://localhost/12.pdf?signature=def", nil)
+
+	investments, err := uc.GetInvestmentsByLoanIDs(context.Background(), []int64{1, 2, 3})
+
+	assert.NoError(t, err)
+	assert.Equal(t, map[int64][]*model.Investment{
+		1: {
+			{ID: 10, LoanID: 1, InvestorID: 100, AgreementLetter: "http://localhost/10.pdf?signature=abc"},
+			{ID: 12, LoanID: 1, InvestorID: 101, AgreementLetter: "http://localhost/12.pdf?signature=def"},
+		},
+		2: {
+			{ID: 11, LoanID: 2, InvestorID: 101, AgreementLetter: "https://file.io/11.pdf"},
+		},
+	}, investments)
+}
+
+func TestGetInvestmentsByLoanIDsError(t *testing.T) {
+	repo := new(MockRepository)
+	uc := NewLoanUsecase(repo)
+
+	repo.On("GetInvestmentsByLoanIDs", mock.Anything, []int64{1}).Return(nil, errors.New("connection refused"))
+
+	investments, err := uc.GetInvestmentsByLoanIDs(context.Background(), []int64{1})
+
+	assert.EqualError(t, err, "connection refused")
+	assert.Nil(t, investments)
+}
+
+func TestGetLoanProductsByIDs(t *testing.T) {
+	repo := new(MockRepository)
+	uc := NewLoanUsecase(repo)
+
+	repo.On("GetLoanProductsByIDs", mock.Anything, []int64{1, 2}).Return([]*model.LoanProduct{{ID: 2, Name: "Micro"}}, nil)
+
+	loanProducts, err := uc.GetLoanProductsByIDs(context.Background(), []int64{1, 2})
+
+	assert.NoError(t, err)
+	assert.Equal(t, map[int64]*model.LoanProduct{2: {ID: 2, Name: "Micro"}}, loanProducts)
+}
+
+func TestGetUsersByIDs(t *testing.T) {
+	repo := new(MockRepository)
+	uc := NewLoanUsecase(repo)
+
+	repo.On("GetUsersByIDs", mock.Anything, []int64{1, 2}).Return([]*model.User{{ID: 1, Name: "John"}, {ID: 2, Name: "Jane"}}, nil)
+
+	users, err := uc.GetUsersByIDs(context.Background(), []int64{1, 2})
+
+	assert.NoError(t, err)
+	assert.Equal(t, map[int64]*model.User{1: {ID: 1, Name: "John"}, 2: {ID: 2, Name: "Jane"}}, users)
+}
+
+func TestGetEmployeesByIDs(t *testing.T) {
+	repo := new(MockRepository)
+	uc := NewLoanUsecase(repo)
+
+	repo.On("GetEmployeesByIDs", mock.Anything, []int64{7}).Return([]*model.Employee{{ID: 7, Name: "Budi"}}, nil)
+
+	employees, err := uc.GetEmployeesByIDs(context.Background(), []int64{7})
+
+	assert.NoError(t, err)
+	assert.Equal(t, map[int64]*model.Employee{7: {ID: 7, Name: "Budi"}}, employees)
+}
+
+func TestGetInvestorsByIDs(t *testing.T) {
+	repo := new(MockRepository)
+	uc := NewLoanUsecase(repo)
+
+	repo.On("GetInvestorsByIDs", mock.Anything, []int64{100}).Return([]*model.Investor{{ID: 100, Name: "Ani"}}, nil)
+
+	investors, err := uc.GetInvestorsByIDs(context.Background(), []int64{100})
+
+	assert.NoError(t, err)
+	assert.Equal(t, map[int64]*model.Investor{100: {ID: 100, Name: "Ani"}}, investors)
+}
@@ -0,0 +1,212 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"sort"
+
+	"github.com/aldipi/loan-service/model"
+	"github.com/aldipi/loan-service/tracing"
+)
+
+// BulkApproveLoans approves every loan of approvals like ApproveLoan, and
+// reports the result of each.
+func (u *LoanUsecase) BulkApproveLoans(ctx context.Context, employeeID int64, approvals []model.BulkApproval, options model.BulkOptions) (result *model.BulkResult, err error) {
+	ctx, span := tracing.Start(ctx, "LoanUsecase.BulkApproveLoans")
+	defer func() { tracing.End(span, err) }()
+
+	loanIDs := make([]int64, len(approvals))
+	for i, approval := range approvals {
+		loanIDs[i] = approval.LoanID
+	}
+
+	return u.bulkChangeLoans(ctx, loanIDs, options, func(ctx context.Context, i int) (*loanChange, error) {
+		return u.prepareApproval(ctx, approvals[i].LoanID, employeeID, approvals[i].ApprovalProof)
+	})
+}
+
+// BulkDisburseLoans disburses every loan of disbursements like DisburseLoan,
+// and reports the result of each.
+func (u *LoanUsecase) BulkDisburseLoans(ctx context.Context, employeeID int64, disbursements []model.BulkDisbursement, options model.BulkOptions) (result *model.BulkResult, err error) {
+	ctx, span := tracing.Start(ctx, "LoanUsecase.BulkDisburseLoans")
+	defer func() { tracing.End(span, err) }()
+
+	loanIDs := make([]int64, len(disbursements))
+	for i, disbursement := range disbursements {
+		loanIDs[i] = disbursement.LoanID
+	}
+
+	return u.bulkChangeLoans(ctx, loanIDs, options, func(ctx context.Context, i int) (*loanChange, error) {
+		return u.prepareDisbursement(ctx, disbursements[i].LoanID, employeeID, disbursements[i].AgreementLetter)
+	})
+}
+
+// errBulkRolledBack rolls back an atomic request once one of its loans
+// failed, and every dry run.
+var errBulkRolledBack = errors.New("bulk request rolled back")
+
+// bulkChangeLoans validates the change prepare returns for every loan, and
+// applies the valid ones unless it is a dry run. Loans are validated and
+// saved one at a time, so a failed loan does not hold back the others, except
+// in atomic requests and dry runs, see bulkChangeLoansInTransaction. A loan
+// listed twice fails the second time, as its first change would no longer be
+// valid, and so does a document already proving another loan of the request.
+func (u *LoanUsecase) bulkChangeLoans(ctx context.Context, loanIDs []int64, options model.BulkOptions, prepare func(ctx context.Context, i int) (*loanChange, error)) (*model.BulkResult, error) {
+	if len(loanIDs) == 0 {
+		return nil, model.ErrBulkEmpty
+	}
+	if len(loanIDs) > model.MaxBulkLoans {
+		return nil, model.ErrBulkTooLarge
+	}
+
+	result := &model.BulkResult{Items: make([]model.BulkItemResult, len(loanIDs))}
+	for i, loanID := range loanIDs {
+		result.Items[i].LoanID = loanID
+	}
+
+	if options.Atomic || options.DryRun {
+		return u.bulkChangeLoansInTransaction(ctx, result, options.DryRun, prepare)
+	}
+
+	checks := newBulkChecks()
+	for i := range result.Items {
+		item := &result.Items[i]
+		if checks.duplicateLoan(item.LoanID) {
+			u.failBulkItem(ctx, result, item, model.ErrBulkDuplicateLoan)
+			continue
+		}
+
+		err := u.changeLoan(ctx, func(ctx context.Context) (*loanChange, error) {
+			return prepare(ctx, i)
+		})
+		if err != nil {
+			u.failBulkItem(ctx, result, item, err)
+			continue
+		}
+		item.Status = model.BulkItemApplied
+		result.Applied++
+	}
+
+	return result, nil
+}
+
+// bulkChangeLoansInTransaction validates every loan and saves them in one
+// transaction. The documents are fetched and verified before the transaction,
+// then each loan is validated again under lock and stays locked until all
+// loans are saved. Nothing is saved when a loan fails or in a dry run. Loans
+// are locked in the order of their IDs, so requests sharing loans wait for
+// each other instead of deadlocking.
+func (u *LoanUsecase) bulkChangeLoansInTransaction(ctx context.Context, result *model.BulkResult, dryRun bool, prepare func(ctx context.Context, i int) (*loanChange, error)) (*model.BulkResult, error) {
+	order := make([]int, len(result.Items))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return result.Items[order[a]].LoanID < result.Items[order[b]].LoanID
+	})
+
+	changes := make([]*loanChange, len(result.Items))
+	checks := newBulkChecks()
+	for _, i := range order {
+		item := &result.Items[i]
+		if checks.duplicateLoan(item.LoanID) {
+			u.failBulkItem(ctx, result, item, model.ErrBulkDuplicateLoan)
+			continue
+		}
+
+		change, err := prepare(ctx, i)
+		if err == nil && checks.duplicateDocument(change) {
+			err = model.ErrDocumentDuplicate
+		}
+		if err != nil {
+			u.failBulkItem(ctx, result, item, err)
+			continue
+		}
+		changes[i] = change
+	}
+
+	err := u.repo.WithTransaction(ctx, func(ctx context.Context) error {
+		for _, i := range order {
+			if changes[i] == nil {
+				continue
+			}
+
+			err := changes[i].lock(ctx)
+			if err != nil {
+				u.failBulkItem(ctx, result, &result.Items[i], err)
+				changes[i] = nil
+				continue
+			}
+			result.Items[i].Status = model.BulkItemValid
+		}
+		if result.Failed > 0 || dryRun {
+			return errBulkRolledBack
+		}
+
+		for _, i := range order {
+			err := changes[i].save(ctx)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err == errBulkRolledBack {
+		return result, nil
+	}
+	// Nothing is applied when saving fails, so the error is the result of
+	// the whole request.
+	if err != nil {
+		return nil, err
+	}
+
+	for _, i := range order {
+		changes[i].done(ctx)
+	}
+	for i := range result.Items {
+		result.Items[i].Status = model.BulkItemApplied
+	}
+	result.Applied = len(result.Items)
+
+	return result, nil
+}
+
+// bulkChecks finds loans listed twice in a bulk request, and documents
+// proving two of its loans. checkDocumentUnused only finds documents of loans
+// saved already, which misses the others of requests not saved one loan at a
+// time.
+type bulkChecks struct {
+	loans     map[int64]bool
+	documents map[string]bool
+}
+
+func newBulkChecks() *bulkChecks {
+	return &bulkChecks{loans: map[int64]bool{}, documents: map[string]bool{}}
+}
+
+func (c *bulkChecks) duplicateLoan(loanID int64) bool {
+	duplicate := c.loans[loanID]
+	c.loans[loanID] = true
+	return duplicate
+}
+
+func (c *bulkChecks) duplicateDocument(change *loanChange) bool {
+	duplicate := c.documents[change.document.Checksum]
+	c.documents[change.document.Checksum] = true
+	return duplicate
+}
+
+// failBulkItem records why a loan failed. Loan errors are reported as they
+// are, other errors are logged and not shown, like internal errors of single
+// requests.
+func (u *LoanUsecase) failBulkItem(ctx context.Context, result *model.BulkResult, item *model.BulkItemResult, err error) {
+	item.Status = model.BulkItemFailed
+	result.Failed++
+
+	if loanErr, ok := err.(model.LoanError); ok {
+		item.Error = loanErr.Error()
+		return
+	}
+	u.logger.ErrorContext(ctx, "bulk loan change failed", "loan_id", item.LoanID, "error", err)
+	item.Error = "internal server error"
+}
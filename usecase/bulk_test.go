@@ -0,0 +1,310 @@
+package usecase
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/aldipi/loan-service/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// bulkApprovalSetup returns a usecase with proposed loans 1 and 2, approved
+// loan 3 and no loan 9. Every loan has its own approval proof.
+func bulkApprovalSetup() (*LoanUsecase, *MockRepository) {
+	repo := new(MockRepository)
+	store := new(mockBlobStore)
+	uc := NewLoanUsecase(repo, WithDocuments(nil, store))
+
+	for _, id := range []int64{1, 2, 3} {
+		state := model.LoanStateProposed
+		if id == 3 {
+			state = model.LoanStateApproved
+		}
+		repo.On("GetLoanByID", mock.Anything, id).Return(&model.Loan{ID: id, State: state}, nil)
+		repo.On("LockLoan", mock.Anything, id).Return(&model.Loan{ID: id, State: state}, nil)
+		store.On("Get", mock.Anything, approvalProofKey(id)).Return(append(append([]byte{}, pngHeader...), byte(id)), nil)
+	}
+	repo.On("GetLoanByID", mock.Anything, int64(9)).Return(nil, sql.ErrNoRows)
+	repo.On("GetEmployeeByID", mock.Anything, int64(555)).Return(&model.Employee{ID: 555}, nil)
+	repo.On("GetLoanDocumentsByChecksum", mock.Anything, mock.Anything).Return([]*model.LoanDocument{}, nil)
+	repo.On("CreateLoanDocument", mock.Anything, mock.Anything).Return(int64(1), nil)
+	repo.On("CreateLoanEvent", mock.Anything, mock.Anything).Return(int64(1), nil)
+	repo.On("CreateOutboxMessage", mock.Anything, mock.Anything).Return(int64(1), nil)
+
+	return uc, repo
+}
+
+func approvalProofKey(loanID int64) string {
+	return fmt.Sprintf("loans/%d/approval_proof/1.png", loanID)
+}
+
+func bulkApprovals(loanIDs ...int64) []model.BulkApproval {
+	approvals := []model.BulkApproval{}
+	for _, id := range loanIDs {
+		approvals = append(approvals, model.BulkApproval{LoanID: id, ApprovalProof: approvalProofKey(id)})
+	}
+	return approvals
+}
+
+func TestBulkApproveLoans(t *testing.T) {
+	uc, repo := bulkApprovalSetup()
+	repo.On("UpdateLoan", mock.Anything, mock.Anything).Return(nil)
+
+	result, err := uc.BulkApproveLoans(context.Background(), 555, bulkApprovals(1, 3, 9, 1, 2), model.BulkOptions{})
+
+	assert.NoError(t, err)
+	assert.Equal(t, &model.BulkResult{
+		Applied: 2,
+		Failed:  3,
+		Items: []model.BulkItemResult{
+			{LoanID: 1, Status: model.BulkItemApplied},
+			{LoanID: 3, Status: model.BulkItemFailed, Error: model.ErrLoanNotProposed.Error()},
+			{LoanID: 9, Status: model.BulkItemFailed, Error: model.ErrLoanNotFound.Error()},
+			{LoanID: 1, Status: model.BulkItemFailed, Error: model.ErrBulkDuplicateLoan.Error()},
+			{LoanID: 2, Status: model.BulkItemApplied},
+		},
+	}, result)
+	repo.AssertNumberOfCalls(t, "UpdateLoan", 2)
+}
+
+func TestBulkApproveLoansHidesInternalErrors(t *testing.T) {
+	uc, repo := bulkApprovalSetup()
+	repo.On("UpdateLoan", mock.Anything, mock.MatchedBy(func(loan *model.Loan) bool { return loan.ID == 1 })).Return(errors.New("deadlock"))
+	repo.On("UpdateLoan", mock.Anything, mock.Anything).Return(nil)
+
+	result, err := uc.BulkApproveLoans(context.Background(), 555, bulkApprovals(1, 2), model.BulkOptions{})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []model.BulkItemResult{
+		{LoanID: 1, Status: model.BulkItemFailed, Error: "internal server error"},
+		{LoanID: 2, Status: model.BulkItemApplied},
+	}, result.Items)
+}
+
+func TestBulkApproveLoansDryRun(t *testing.T) {
+	for _, atomic := range []bool{false, true} {
+		uc, repo := bulkApprovalSetup()
+
+		result, err := uc.BulkApproveLoans(context.Background(), 555, bulkApprovals(1, 3, 2), model.BulkOptions{DryRun: true, Atomic: atomic})
+
+		assert.NoError(t, err)
+		assert.Equal(t, &model.BulkResult{
+			Failed: 1,
+			Items: []model.BulkItemResult{
+				{LoanID: 1, Status: model.BulkItemValid},
+				{LoanID: 3, Status: model.BulkItemFailed, Error: model.ErrLoanNotProposed.Error()},
+				{LoanID: 2, Status: model.BulkItemValid},
+			},
+		}, result)
+		repo.AssertNotCalled(t, "UpdateLoan", mock.Anything, mock.Anything)
+		repo.AssertNotCalled(t, "CreateLoanEvent", mock.Anything, mock.Anything)
+	}
+}
+
+func TestBulkApproveLoansAtomic(t *testing.T) {
+	uc, repo := bulkApprovalSetup()
+	repo.On("UpdateLoan", mock.Anything, mock.Anything).Return(nil)
+
+	// One failed loan holds back the others.
+	result, err := uc.BulkApproveLoans(context.Background(), 555, bulkApprovals(1, 9), model.BulkOptions{Atomic: true})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []model.BulkItemResult{
+		{LoanID: 1, Status: model.BulkItemValid},
+		{LoanID: 9, Status: model.BulkItemFailed, Error: model.ErrLoanNotFound.Error()},
+	}, result.Items)
+	repo.AssertNotCalled(t, "UpdateLoan", mock.Anything, mock.Anything)
+
+	// The usecases change the loans returned by LockLoan in place, so the
+	// next request gets fresh loans.
+	uc, repo = bulkApprovalSetup()
+	repo.On("UpdateLoan", mock.Anything, mock.Anything).Return(nil)
+
+	result, err = uc.BulkApproveLoans(context.Background(), 555, bulkApprovals(1, 2), model.BulkOptions{Atomic: true})
+
+	assert.NoError(t, err)
+	assert.Equal(t, &model.BulkResult{
+		Applied: 2,
+		Items: []model.BulkItemResult{
+			{LoanID: 1, Status: model.BulkItemApplied},
+			{LoanID: 2, Status: model.BulkItemApplied},
+		},
+	}, result)
+	repo.AssertNumberOfCalls(t, "UpdateLoan", 2)
+}
+
+func TestBulkApproveLoansAtomicRejectsSharedDocument(t *testing.T) {
+	uc, _ := bulkApprovalSetup()
+	approvals := bulkApprovals(1, 2)
+	approvals[1].ApprovalProof = approvalProofKey(1)
+
+	result, err := uc.BulkApproveLoans(context.Background(), 555, approvals, model.BulkOptions{Atomic: true})
+
+	assert.NoError(t, err)
+	assert.Equal(t, model.BulkItemFailed, result.Items[1].Status)
+	assert.Equal(t, model.ErrDocumentDuplicate.Error(), result.Items[1].Error)
+}
+
+func TestBulkApproveLoansAtomicSaveFails(t *testing.T) {
+	uc, repo := bulkApprovalSetup()
+	repo.On("UpdateLoan", mock.Anything, mock.MatchedBy(func(loan *model.Loan) bool { return loan.ID == 2 })).Return(errors.New("deadlock"))
+	repo.On("UpdateLoan", mock.Anything, mock.Anything).Return(nil)
+
+	result, err := uc.BulkApproveLoans(context.Background(), 555, bulkApprovals(1, 2), model.BulkOptions{Atomic: true})
+
+	assert.EqualError(t, err, "deadlock")
+	assert.Nil(t, result)
+}
+
+func TestBulkLoanLimits(t *testing.T) {
+	uc := NewLoanUsecase(new(MockRepository))
+
+	_, err := uc.BulkApproveLoans(context.Background(), 555, nil, model.BulkOptions{})
+	assert.Equal(t, model.ErrBulkEmpty, err)
+
+	_, err = uc.BulkDisburseLoans(context.Background(), 555, make([]model.BulkDisbursement, model.MaxBulkLoans+1), model.BulkOptions{})
+	assert.Equal(t, model.ErrBulkTooLarge, err)
+}
+
+func TestBulkDisburseLoans(t *testing.T) {
+	repo := new(MockRepository)
+	notifier := new(mockNotifier)
+	uc := NewLoanUsecase(repo, WithNotifier(notifier))
+
+	repo.On("GetLoanByID", mock.Anything, int64(1)).Return(&model.Loan{ID: 1, State: model.LoanStateApproved}, nil)
+	repo.On("GetEmployeeByID", mock.Anything, int64(555)).Return(&model.Employee{ID: 555}, nil)
+
+	result, err := uc.BulkDisburseLoans(context.Background(), 555, []model.BulkDisbursement{{LoanID: 1, AgreementLetter: "https://file.io/1/agreement.pdf"}}, model.BulkOptions{})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []model.BulkItemResult{
+		{LoanID: 1, Status: model.BulkItemFailed, Error: model.ErrLoanNotInvested.Error()},
+	}, result.Items)
+	notifier.AssertNotCalled(t, "Notify", mock.Anything, mock.Anything)
+}
+
+// bulkDisbursementSetup returns a usecase with invested loans 1 and 2, whose
+// agreements are signed, approved loan 3 and no loan 9. Every loan has its
+// own signed agreement letter. LockLoan fails outside of transactions.
+func bulkDisbursementSetup() (*LoanUsecase, *MockRepository) {
+	repo := new(MockRepository)
+	store := new(mockBlobStore)
+	uc := NewLoanUsecase(lockingRepository{repo}, WithDocuments(nil, store))
+
+	for _, id := range []int64{1, 2, 3} {
+		state := model.LoanStateInvested
+		if id == 3 {
+			state = model.LoanStateApproved
+		}
+		repo.On("GetLoanByID", mock.Anything, id).Return(&model.Loan{ID: id, PrincipalAmount: 1000000, State: state}, nil)
+		repo.On("LockLoan", mock.Anything, id).Return(&model.Loan{ID: id, PrincipalAmount: 1000000, State: state}, nil)
+		repo.On("GetInvestmentsByLoanID", mock.Anything, id).Return([]*model.Investment{{ID: id * 10, LoanID: id}}, nil)
+		repo.On("GetLoanDocumentsByLoanID", mock.Anything, id).Return(signedAgreements(id, id*10), nil)
+		store.On("Get", mock.Anything, agreementLetterKey(id)).Return([]byte(fmt.Sprintf("%%PDF-1.4 %d", id)), nil)
+	}
+	repo.On("GetLoanByID", mock.Anything, int64(9)).Return(nil, sql.ErrNoRows)
+	repo.On("GetEmployeeByID", mock.Anything, int64(555)).Return(&model.Employee{ID: 555}, nil)
+	repo.On("GetLoanDocumentsByChecksum", mock.Anything, mock.Anything).Return([]*model.LoanDocument{}, nil)
+	repo.On("UpdateLoan", mock.Anything, mock.Anything).Return(nil)
+	repo.On("CreateLoanDocument", mock.Anything, mock.Anything).Return(int64(1), nil)
+	repo.On("CreateLoanEvent", mock.Anything, mock.Anything).Return(int64(1), nil)
+	repo.On("CreateOutboxMessage", mock.Anything, mock.Anything).Return(int64(1), nil)
+	expectJournalEntries(repo)
+
+	return uc, repo
+}
+
+func agreementLetterKey(loanID int64) string {
+	return fmt.Sprintf("loans/%d/signed_agreement/1.pdf", loanID)
+}
+
+func bulkDisbursements(loanIDs ...int64) []model.BulkDisbursement {
+	disbursements := []model.BulkDisbursement{}
+	for _, id := range loanIDs {
+		disbursements = append(disbursements, model.BulkDisbursement{LoanID: id, AgreementLetter: agreementLetterKey(id)})
+	}
+	return disbursements
+}
+
+func TestBulkDisburseLoansApplied(t *testing.T) {
+	uc, repo := bulkDisbursementSetup()
+
+	result, err := uc.BulkDisburseLoans(context.Background(), 555, bulkDisbursements(1, 3, 2), model.BulkOptions{})
+
+	assert.NoError(t, err)
+	assert.Equal(t, &model.BulkResult{
+		Applied: 2,
+		Failed:  1,
+		Items: []model.BulkItemResult{
+			{LoanID: 1, Status: model.BulkItemApplied},
+			{LoanID: 3, Status: model.BulkItemFailed, Error: model.ErrLoanNotInvested.Error()},
+			{LoanID: 2, Status: model.BulkItemApplied},
+		},
+	}, result)
+	repo.AssertNumberOfCalls(t, "UpdateLoan", 2)
+	repo.AssertCalled(t, "CreateJournalEntry", mock.Anything, journalKind(model.JournalDisbursement))
+}
+
+func TestBulkDisburseLoansAtomic(t *testing.T) {
+	uc, repo := bulkDisbursementSetup()
+
+	// One failed loan holds back the others.
+	result, err := uc.BulkDisburseLoans(context.Background(), 555, bulkDisbursements(1, 3), model.BulkOptions{Atomic: true})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []model.BulkItemResult{
+		{LoanID: 1, Status: model.BulkItemValid},
+		{LoanID: 3, Status: model.BulkItemFailed, Error: model.ErrLoanNotInvested.Error()},
+	}, result.Items)
+	repo.AssertNotCalled(t, "UpdateLoan", mock.Anything, mock.Anything)
+	repo.AssertNotCalled(t, "CreateJournalEntry", mock.Anything, mock.Anything)
+
+	// Loans are locked in the order of their IDs and reported in the order
+	// of the request.
+	uc, repo = bulkDisbursementSetup()
+
+	result, err = uc.BulkDisburseLoans(context.Background(), 555, bulkDisbursements(2, 1), model.BulkOptions{Atomic: true})
+
+	assert.NoError(t, err)
+	assert.Equal(t, &model.BulkResult{
+		Applied: 2,
+		Items: []model.BulkItemResult{
+			{LoanID: 2, Status: model.BulkItemApplied},
+			{LoanID: 1, Status: model.BulkItemApplied},
+		},
+	}, result)
+	lockedLoans := []int64{}
+	for _, call := range repo.Calls {
+		if call.Method == "LockLoan" {
+			lockedLoans = append(lockedLoans, call.Arguments.Get(1).(int64))
+		}
+	}
+	assert.Equal(t, []int64{1, 2}, lockedLoans)
+	repo.AssertNumberOfCalls(t, "UpdateLoan", 2)
+}
+
+func TestBulkDisburseLoansDryRun(t *testing.T) {
+	for _, atomic := range []bool{false, true} {
+		uc, repo := bulkDisbursementSetup()
+
+		result, err := uc.BulkDisburseLoans(context.Background(), 555, bulkDisbursements(1, 9, 2), model.BulkOptions{DryRun: true, Atomic: atomic})
+
+		assert.NoError(t, err)
+		assert.Equal(t, &model.BulkResult{
+			Failed: 1,
+			Items: []model.BulkItemResult{
+				{LoanID: 1, Status: model.BulkItemValid},
+				{LoanID: 9, Status: model.BulkItemFailed, Error: model.ErrLoanNotFound.Error()},
+				{LoanID: 2, Status: model.BulkItemValid},
+			},
+		}, result)
+		repo.AssertNotCalled(t, "UpdateLoan", mock.Anything, mock.Anything)
+		repo.AssertNotCalled(t, "CreateLoanDocument", mock.Anything, mock.Anything)
+		repo.AssertNotCalled(t, "CreateJournalEntry", mock.Anything, mock.Anything)
+		repo.AssertNotCalled(t, "CreateLoanEvent", mock.Anything, mock.Anything)
+	}
+}
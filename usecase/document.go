@@ -0,0 +1,396 @@
+package usecase
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/aldipi/loan-service/model"
+	"github.com/aldipi/loan-service/tracing"
+)
+
+const (
+	agreementContentType = "application/pdf"
+
+	defaultSignedURLExpiry      = 15 * time.Minute
+	defaultDocumentFetchTimeout = 30 * time.Second
+)
+
+var errNoBlobStore = errors.New("document storage is not configured")
+
+// documentTypes lists the content types accepted for each kind of attached
+// document and the file extension used in their keys.
+var documentTypes = map[model.DocumentKind]map[string]string{
+	model.DocumentApprovalProof: {
+		"image/jpeg": "jpg",
+		"image/png":  "png",
+	},
+	model.DocumentSignedAgreement: {
+		"application/pdf": "pdf",
+	},
+}
+
+// DocumentGenerator renders agreement letters.
+type DocumentGenerator interface {
+	GenerateAgreement(ctx context.Context, agreement *model.Agreement) ([]byte, error)
+}
+
+// BlobStore keeps files by key. Files are downloaded through signed URLs that
+// stop working after they expire, so stored keys can be shared safely. Get
+// returns an error wrapping fs.ErrNotExist for a key without a file, while
+// Delete of such a key succeeds.
+type BlobStore interface {
+	Put(ctx context.Context, key string, contentType string, data []byte) error
+	PutReader(ctx context.Context, key string, contentType string, body io.Reader, size int64) error
+	Get(ctx context.Context, key string) ([]byte, error)
+	Delete(ctx context.Context, key string) error
+	SignedURL(ctx context.Context, key string, expiresIn time.Duration) (string, error)
+}
+
+// UploadLoanDocument stores a file attached to the loan. The content type is
+// detected from the data, and a declared content type that does not match it
+// is rejected. Only actors who can see the loan can upload its documents.
+func (u *LoanUsecase) UploadLoanDocument(ctx context.Context, actor model.Actor, loanID int64, kind model.DocumentKind, contentType string, data []byte) (upload *model.Upload, err error) {
+	ctx, span := tracing.Start(ctx, "LoanUsecase.UploadLoanDocument")
+	defer func() { tracing.End(span, err) }()
+
+	if u.blobs == nil {
+		return nil, errNoBlobStore
+	}
+
+	detected, err := checkDocument(kind, data)
+	if err != nil {
+		return nil, err
+	}
+
+	declared, _, _ := mime.ParseMediaType(contentType)
+	if declared != "" && declared != "application/octet-stream" && declared != detected {
+		return nil, model.ErrDocumentUnsupportedType
+	}
+
+	_, err = u.accessLoan(ctx, actor, loanID)
+	if err != nil {
+		return nil, err
+	}
+
+	key := fmt.Sprintf("loans/%d/%s/%d.%s", loanID, kind, time.Now().UnixNano(), documentTypes[kind][detected])
+	err = u.blobs.Put(ctx, key, detected, data)
+	if err != nil {
+		return nil, err
+	}
+
+	url, err := u.blobs.SignedURL(ctx, key, u.signedURLExpiry)
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.Upload{
+		Key:         key,
+		URL:         url,
+		ContentType: detected,
+		Size:        len(data),
+	}, nil
+}
+
+// GetLoanDocuments lists every document attached to the loan with the checksum
+// recorded when it was attached. Only actors who can see the loan can list
+// its documents.
+func (u *LoanUsecase) GetLoanDocuments(ctx context.Context, actor model.Actor, loanID int64) (documents []*model.LoanDocument, err error) {
+	ctx, span := tracing.Start(ctx, "LoanUsecase.GetLoanDocuments")
+	defer func() { tracing.End(span, err) }()
+
+	_, err = u.accessLoan(ctx, actor, loanID)
+	if err != nil {
+		return nil, err
+	}
+
+	documents, err = u.repo.GetLoanDocumentsByLoanID(ctx, loanID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, document := range documents {
+		err = u.signURL(ctx, &document.Location)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return documents, nil
+}
+
+// verifyDocument fetches the document submitted as proof of a loan change and
+// returns the record to attach to the loan. Files that are empty or of the
+// wrong type are rejected. The document is fetched outside of the transaction
+// of the change, see checkDocumentUnused for the check made under lock.
+func (u *LoanUsecase) verifyDocument(ctx context.Context, loanID int64, kind model.DocumentKind, location string) (*model.LoanDocument, error) {
+	if location == "" {
+		return nil, model.ErrDocumentEmpty
+	}
+
+	data, err := u.fetchDocument(ctx, location)
+	if err != nil {
+		return nil, err
+	}
+
+	contentType, err := checkDocument(kind, data)
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256(data)
+
+	return &model.LoanDocument{
+		LoanID:      loanID,
+		Kind:        kind,
+		Location:    location,
+		Checksum:    hex.EncodeToString(sum[:]),
+		ContentType: contentType,
+		Size:        len(data),
+		Status:      model.DocumentAttached,
+	}, nil
+}
+
+// checkDocumentUnused rejects a document already attached to a loan as proof.
+// It runs with the loan locked, so it sees the proofs of changes saved while
+// the document was fetched.
+func (u *LoanUsecase) checkDocumentUnused(ctx context.Context, document *model.LoanDocument) error {
+	attached, err := u.repo.GetLoanDocumentsByChecksum(ctx, document.Checksum)
+	if err != nil {
+		return err
+	}
+	for _, attached := range attached {
+		if _, ok := documentTypes[attached.Kind]; ok {
+			return model.ErrDocumentDuplicate
+		}
+	}
+
+	return nil
+}
+
+// fetchDocument reads a document from the blob store, or downloads it when it
+// was submitted as an external URL on an allowed host.
+func (u *LoanUsecase) fetchDocument(ctx context.Context, location string) ([]byte, error) {
+	if isBlobKey(location) {
+		if u.blobs == nil {
+			return nil, errNoBlobStore
+		}
+
+		data, err := u.blobs.Get(ctx, location)
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, model.ErrDocumentUnavailable
+		}
+
+		return data, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, location, nil)
+	if err != nil || !u.documentURLAllowed(req.URL) {
+		return nil, model.ErrDocumentUnavailable
+	}
+
+	// Redirects are followed to allowed URLs only.
+	client := *u.httpClient
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if len(via) >= 10 || !u.documentURLAllowed(req.URL) {
+			return model.ErrDocumentUnavailable
+		}
+		return nil
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, model.ErrDocumentUnavailable
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, model.ErrDocumentUnavailable
+	}
+
+	return io.ReadAll(io.LimitReader(resp.Body, model.MaxDocumentSize+1))
+}
+
+// documentURLAllowed reports whether a document may be downloaded from the
+// URL, which must be an http or https URL on one of the allowed hosts if any.
+func (u *LoanUsecase) documentURLAllowed(location *url.URL) bool {
+	if location.Scheme != "http" && location.Scheme != "https" {
+		return false
+	}
+	return len(u.docHosts) == 0 || slices.Contains(u.docHosts, location.Hostname())
+}
+
+// checkDocument returns the content type detected from the data, rejecting
+// documents that are empty, too large or of a type not accepted for kind.
+func checkDocument(kind model.DocumentKind, data []byte) (string, error) {
+	if len(data) == 0 {
+		return "", model.ErrDocumentEmpty
+	}
+
+	if len(data) > model.MaxDocumentSize {
+		return "", model.ErrDocumentTooLarge
+	}
+
+	detected, _, _ := mime.ParseMediaType(http.DetectContentType(data))
+	if _, ok := documentTypes[kind][detected]; !ok {
+		return "", model.ErrDocumentUnsupportedType
+	}
+
+	return detected, nil
+}
+
+// attachAgreements records the generated agreement letters of an investment
+// as documents of its loan, pending the signatures of their parties. It
+// returns the signing links to send once the investment is committed.
+func (u *LoanUsecase) attachAgreements(ctx context.Context, loan *model.Loan, investment *model.Investment, agreement model.Document, borrowerAgreement model.Document) ([]signingRequest, error) {
+	requests := []signingRequest{}
+	if agreement.Key != "" {
+		request, err := u.attachAgreement(ctx, &model.LoanDocument{
+			LoanID:       investment.LoanID,
+			InvestmentID: sql.NullInt64{Int64: investment.ID, Valid: true},
+			Kind:         model.DocumentInvestorAgreement,
+			Location:     agreement.Key,
+			Checksum:     agreement.Checksum,
+			ContentType:  agreementContentType,
+			Size:         agreement.Size,
+			Status:       model.DocumentPendingSignature,
+		}, model.SignerInvestor, investment.InvestorID)
+		if err != nil {
+			return nil, err
+		}
+		requests = append(requests, request)
+	}
+	if borrowerAgreement.Key != "" {
+		request, err := u.attachAgreement(ctx, &model.LoanDocument{
+			LoanID:      investment.LoanID,
+			Kind:        model.DocumentBorrowerAgreement,
+			Location:    borrowerAgreement.Key,
+			Checksum:    borrowerAgreement.Checksum,
+			ContentType: agreementContentType,
+			Size:        borrowerAgreement.Size,
+			Status:      model.DocumentPendingSignature,
+		}, model.SignerBorrower, loan.BorrowerID)
+		if err != nil {
+			return nil, err
+		}
+		requests = append(requests, request)
+	}
+
+	return requests, nil
+}
+
+func (u *LoanUsecase) attachAgreement(ctx context.Context, document *model.LoanDocument, role model.SignerRole, signerID int64) (signingRequest, error) {
+	id, err := u.repo.CreateLoanDocument(ctx, document)
+	if err != nil {
+		return signingRequest{}, err
+	}
+	document.ID = id
+
+	return u.requestSignature(ctx, document, role, signerID)
+}
+
+// storeAgreement generates the agreement letter and stores it under key. It
+// returns an empty document when the usecase has no document generator.
+func (u *LoanUsecase) storeAgreement(ctx context.Context, key string, agreement *model.Agreement) (model.Document, error) {
+	if u.documents == nil || u.blobs == nil {
+		return model.Document{}, nil
+	}
+
+	data, err := u.documents.GenerateAgreement(ctx, agreement)
+	if err != nil {
+		return model.Document{}, fmt.Errorf("generate %s agreement: %w", agreement.Kind, err)
+	}
+
+	err = u.blobs.Put(ctx, key, agreementContentType, data)
+	if err != nil {
+		return model.Document{}, fmt.Errorf("store %s agreement: %w", agreement.Kind, err)
+	}
+
+	checksum := sha256.Sum256(data)
+
+	return model.Document{Key: key, Checksum: hex.EncodeToString(checksum[:]), Size: len(data)}, nil
+}
+
+// deleteAgreements removes the agreement letters stored for an investment
+// that was rolled back, so no blob is left without a document referring to
+// it. Letters that can not be deleted are only logged, as the investment
+// failed already.
+func (u *LoanUsecase) deleteAgreements(ctx context.Context, keys []string) {
+	for _, key := range keys {
+		if key == "" {
+			continue
+		}
+
+		err := u.blobs.Delete(ctx, key)
+		if err != nil {
+			u.logger.ErrorContext(ctx, "failed to delete agreement of rolled back investment", "key", key, "error", err)
+		}
+	}
+}
+
+// signLoanURLs replaces the blob keys of the loan documents with signed
+// download URLs. Documents recorded as plain URLs are left untouched.
+func (u *LoanUsecase) signLoanURLs(ctx context.Context, loans ...*model.Loan) error {
+	for _, loan := range loans {
+		for _, document := range []*string{&loan.ApprovalProof.String, &loan.AgreementLetter.String, &loan.BorrowerAgreement.String} {
+			err := u.signURL(ctx, document)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (u *LoanUsecase) signInvestmentURLs(ctx context.Context, investments ...*model.Investment) error {
+	for _, investment := range investments {
+		err := u.signURL(ctx, &investment.AgreementLetter)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (u *LoanUsecase) signURL(ctx context.Context, document *string) error {
+	if u.blobs == nil || !isBlobKey(*document) {
+		return nil
+	}
+
+	url, err := u.blobs.SignedURL(ctx, *document, u.signedURLExpiry)
+	if err != nil {
+		return err
+	}
+	*document = url
+
+	return nil
+}
+
+// isBlobKey reports whether a stored document refers to the blob store rather
+// than to an external URL recorded before documents were uploaded.
+func isBlobKey(document string) bool {
+	return document != "" && !strings.Contains(document, "://")
+}
+
+// investorAgreementKey is unique per attempt, so a letter of an investment
+// that failed to commit is never overwritten by a later one.
+func investorAgreementKey(loanID int64, investorID int64) string {
+	return fmt.Sprintf("agreements/loans/%d/investors/%d/%d.pdf", loanID, investorID, time.Now().UnixNano())
+}
+
+func borrowerAgreementKey(loanID int64) string {
+	return fmt.Sprintf("agreements/loans/%d/borrower/%d.pdf", loanID, time.Now().UnixNano())
+}
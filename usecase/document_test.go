@@ -0,0 +1,517 @@
+package usecase
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aldipi/loan-service/model"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type mockDocumentGenerator struct {
+	mock.Mock
+}
+
+func (m *mockDocumentGenerator) GenerateAgreement(ctx context.Context, agreement *model.Agreement) ([]byte, error) {
+	args := m.Called(ctx, agreement)
+	return args.Get(0).([]byte), args.Error(1)
+}
+
+type mockBlobStore struct {
+	mock.Mock
+}
+
+func (m *mockBlobStore) Put(ctx context.Context, key string, contentType string, data []byte) error {
+	args := m.Called(ctx, key, contentType, data)
+	return args.Error(0)
+}
+
+func (m *mockBlobStore) PutReader(ctx context.Context, key string, contentType string, body io.Reader, size int64) error {
+	data, _ := io.ReadAll(body)
+	args := m.Called(ctx, key, contentType, data, size)
+	return args.Error(0)
+}
+
+func (m *mockBlobStore) Get(ctx context.Context, key string) ([]byte, error) {
+	args := m.Called(ctx, key)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]byte), args.Error(1)
+}
+
+func (m *mockBlobStore) Delete(ctx context.Context, key string) error {
+	args := m.Called(ctx, key)
+	return args.Error(0)
+}
+
+func (m *mockBlobStore) SignedURL(ctx context.Context, key string, expiresIn time.Duration) (string, error) {
+	args := m.Called(ctx, key, expiresIn)
+	return args.String(0), args.Error(1)
+}
+
+func checksumOf(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
+
+// serveDocument serves data over HTTP and returns its URL. The server listens
+// on a loopback address, so the usecase needs a client like http.DefaultClient
+// instead of its default one to download it.
+func serveDocument(t *testing.T, data []byte) string {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(data)
+	}))
+	t.Cleanup(server.Close)
+
+	return server.URL + "/document"
+}
+
+func TestCreateInvestmentGeneratesAgreements(t *testing.T) {
+	repo := new(MockRepository)
+	generator := new(mockDocumentGenerator)
+	store := new(mockBlobStore)
+	uc := NewLoanUsecase(repo, WithDocuments(generator, store))
+
+	loan := &model.Loan{ID: 1, BorrowerID: 123, PrincipalAmount: 1000000, Rate: decimal.NewFromInt(12), ROI: decimal.NewFromInt(10), State: model.LoanStateApproved}
+
+	expectFundedWallets(repo)
+	expectJournalEntries(repo)
+	repo.On("GetInvestorByID", mock.Anything, int64(100)).Return(&model.Investor{ID: 100, Name: "Investor A"}, nil)
+	repo.On("LockLoan", mock.Anything, int64(1)).Return(loan, nil)
+	repo.On("GetInvestmentsByLoanID", mock.Anything, int64(1)).Return([]*model.Investment{{ID: 1, InvestorID: 101, LoanID: 1, Amount: 400000}}, nil)
+	repo.On("GetUserByID", mock.Anything, int64(123)).Return(&model.User{ID: 123, Name: "John Doe"}, nil)
+	repo.On("CreateInvestment", mock.Anything, mock.Anything).Return(int64(2), nil)
+	repo.On("UpdateLoan", mock.Anything, loan).Return(nil)
+	repo.On("CreateLoanEvent", mock.Anything, mock.Anything).Return(int64(1), nil)
+	repo.On("CreateOutboxMessage", mock.Anything, mock.Anything).Return(int64(1), nil)
+	generator.On("GenerateAgreement", mock.Anything, mock.MatchedBy(func(a *model.Agreement) bool {
+		return a.Kind == model.AgreementInvestor && a.LoanID == 1 && a.PartyName == "Investor A" && a.Amount == 600000 && a.ROI.Equal(decimal.NewFromInt(10))
+	})).Return([]byte("investor pdf"), nil)
+	generator.On("GenerateAgreement", mock.Anything, mock.MatchedBy(func(a *model.Agreement) bool {
+		return a.Kind == model.AgreementBorrower && a.PartyName == "John Doe" && a.Amount == 1000000
+	})).Return([]byte("borrower pdf"), nil)
+	store.On("Put", mock.Anything, mock.MatchedBy(func(key string) bool {
+		return strings.HasPrefix(key, "agreements/loans/1/investors/100/")
+	}), "application/pdf", []byte("investor pdf")).Return(nil)
+	store.On("Put", mock.Anything, mock.MatchedBy(func(key string) bool {
+		return strings.HasPrefix(key, "agreements/loans/1/borrower/")
+	}), "application/pdf", []byte("borrower pdf")).Return(nil)
+	store.On("SignedURL", mock.Anything, mock.Anything, 15*time.Minute).Return("http://localhost/investor.pdf?signature=abc", nil)
+	repo.On("CreateLoanDocument", mock.Anything, mock.Anything).Return(int64(1), nil)
+	repo.On("CreateSignature", mock.Anything, mock.Anything).Return(int64(1), nil)
+
+	investment, err := uc.CreateInvestment(context.Background(), 100, 1, 600000)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "http://localhost/investor.pdf?signature=abc", investment.AgreementLetter)
+	assert.Equal(t, checksumOf("investor pdf"), investment.AgreementChecksum)
+	assert.True(t, strings.HasPrefix(loan.BorrowerAgreement.String, "agreements/loans/1/borrower/"))
+	assert.Equal(t, checksumOf("borrower pdf"), loan.BorrowerAgreementChecksum.String)
+	repo.AssertCalled(t, "CreateLoanEvent", mock.Anything, mock.MatchedBy(func(e *model.LoanEvent) bool {
+		return e.Type == model.LoanEventFullyFunded && strings.Contains(string(e.Payload), checksumOf("borrower pdf"))
+	}))
+	repo.AssertCalled(t, "CreateLoanDocument", mock.Anything, mock.MatchedBy(func(d *model.LoanDocument) bool {
+		return d.Kind == model.DocumentInvestorAgreement && d.InvestmentID.Int64 == 2 && d.Checksum == checksumOf("investor pdf") && d.Size == len("investor pdf") && d.Status == model.DocumentPendingSignature
+	}))
+	repo.AssertCalled(t, "CreateLoanDocument", mock.Anything, mock.MatchedBy(func(d *model.LoanDocument) bool {
+		return d.Kind == model.DocumentBorrowerAgreement && !d.InvestmentID.Valid && d.Checksum == checksumOf("borrower pdf") && d.Status == model.DocumentPendingSignature
+	}))
+	repo.AssertCalled(t, "CreateSignature", mock.Anything, mock.MatchedBy(func(s *model.Signature) bool {
+		return s.SignerRole == model.SignerInvestor && s.SignerID == 100 && s.Checksum == checksumOf("investor pdf") && len(s.TokenHash) == 64
+	}))
+	repo.AssertCalled(t, "CreateSignature", mock.Anything, mock.MatchedBy(func(s *model.Signature) bool {
+		return s.SignerRole == model.SignerBorrower && s.SignerID == 123 && s.Checksum == checksumOf("borrower pdf")
+	}))
+}
+
+func TestCreateInvestmentPartiallyFundedDoesNotGenerateBorrowerAgreement(t *testing.T) {
+	repo := new(MockRepository)
+	generator := new(mockDocumentGenerator)
+	store := new(mockBlobStore)
+	uc := NewLoanUsecase(repo, WithDocuments(generator, store))
+
+	loan := &model.Loan{ID: 1, BorrowerID: 123, PrincipalAmount: 1000000, State: model.LoanStateApproved}
+
+	expectFundedWallets(repo)
+	expectJournalEntries(repo)
+	repo.On("GetInvestorByID", mock.Anything, int64(100)).Return(&model.Investor{ID: 100, Name: "Investor A"}, nil)
+	repo.On("LockLoan", mock.Anything, int64(1)).Return(loan, nil)
+	repo.On("GetInvestmentsByLoanID", mock.Anything, int64(1)).Return([]*model.Investment{}, nil)
+	repo.On("CreateInvestment", mock.Anything, mock.Anything).Return(int64(1), nil)
+	repo.On("CreateLoanEvent", mock.Anything, mock.Anything).Return(int64(1), nil)
+	repo.On("CreateOutboxMessage", mock.Anything, mock.Anything).Return(int64(1), nil)
+	generator.On("GenerateAgreement", mock.Anything, mock.Anything).Return([]byte("investor pdf"), nil)
+	store.On("Put", mock.Anything, mock.Anything, "application/pdf", mock.Anything).Return(nil)
+	store.On("SignedURL", mock.Anything, mock.Anything, mock.Anything).Return("http://localhost/investor.pdf", nil)
+	repo.On("CreateLoanDocument", mock.Anything, mock.Anything).Return(int64(1), nil)
+	repo.On("CreateSignature", mock.Anything, mock.Anything).Return(int64(1), nil)
+
+	_, err := uc.CreateInvestment(context.Background(), 100, 1, 600000)
+
+	assert.NoError(t, err)
+	generator.AssertNumberOfCalls(t, "GenerateAgreement", 1)
+	assert.False(t, loan.BorrowerAgreement.Valid)
+	repo.AssertNotCalled(t, "GetUserByID", mock.Anything, mock.Anything)
+}
+
+func TestCreateInvestmentAgreementStoreFails(t *testing.T) {
+	repo := new(MockRepository)
+	generator := new(mockDocumentGenerator)
+	store := new(mockBlobStore)
+	uc := NewLoanUsecase(repo, WithDocuments(generator, store))
+
+	loan := &model.Loan{ID: 1, PrincipalAmount: 1000000, State: model.LoanStateApproved}
+
+	expectFundedWallets(repo)
+	expectJournalEntries(repo)
+	repo.On("GetInvestorByID", mock.Anything, int64(100)).Return(&model.Investor{ID: 100}, nil)
+	repo.On("LockLoan", mock.Anything, int64(1)).Return(loan, nil)
+	repo.On("GetInvestmentsByLoanID", mock.Anything, int64(1)).Return([]*model.Investment{}, nil)
+	generator.On("GenerateAgreement", mock.Anything, mock.Anything).Return([]byte("investor pdf"), nil)
+	store.On("Put", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(errors.New("disk full"))
+
+	_, err := uc.CreateInvestment(context.Background(), 100, 1, 600000)
+
+	assert.ErrorContains(t, err, "disk full")
+	repo.AssertNotCalled(t, "CreateInvestment", mock.Anything, mock.Anything)
+}
+
+func TestCreateInvestmentRollbackDeletesAgreements(t *testing.T) {
+	repo := new(MockRepository)
+	generator := new(mockDocumentGenerator)
+	store := new(mockBlobStore)
+	uc := NewLoanUsecase(repo, WithDocuments(generator, store))
+
+	loan := &model.Loan{ID: 1, BorrowerID: 123, PrincipalAmount: 1000000, State: model.LoanStateApproved}
+
+	expectFundedWallets(repo)
+	repo.On("GetInvestorByID", mock.Anything, int64(100)).Return(&model.Investor{ID: 100}, nil)
+	repo.On("LockLoan", mock.Anything, int64(1)).Return(loan, nil)
+	repo.On("GetInvestmentsByLoanID", mock.Anything, int64(1)).Return([]*model.Investment{}, nil)
+	repo.On("GetUserByID", mock.Anything, int64(123)).Return(&model.User{ID: 123}, nil)
+	repo.On("CreateInvestment", mock.Anything, mock.Anything).Return(int64(0), errors.New("connection reset"))
+	generator.On("GenerateAgreement", mock.Anything, mock.Anything).Return([]byte("pdf"), nil)
+	store.On("Put", mock.Anything, mock.Anything, "application/pdf", mock.Anything).Return(nil)
+	store.On("Delete", mock.Anything, mock.MatchedBy(func(key string) bool {
+		return strings.HasPrefix(key, "agreements/loans/1/investors/100/")
+	})).Return(nil)
+	store.On("Delete", mock.Anything, mock.MatchedBy(func(key string) bool {
+		return strings.HasPrefix(key, "agreements/loans/1/borrower/")
+	})).Return(errors.New("timeout"))
+
+	_, err := uc.CreateInvestment(context.Background(), 100, 1, 1000000)
+
+	assert.ErrorContains(t, err, "connection reset")
+	store.AssertNumberOfCalls(t, "Put", 2)
+	store.AssertNumberOfCalls(t, "Delete", 2)
+	for _, call := range store.Calls {
+		if call.Method == "Put" {
+			store.AssertCalled(t, "Delete", mock.Anything, call.Arguments.String(1))
+		}
+	}
+}
+
+var pngHeader = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+// documentBorrower is the borrower of the loans in the document tests.
+var documentBorrower = model.Actor{Role: model.RoleBorrower, ID: 2}
+
+func TestUploadLoanDocument(t *testing.T) {
+	repo := new(MockRepository)
+	store := new(mockBlobStore)
+	uc := NewLoanUsecase(repo, WithDocuments(nil, store), WithSignedURLExpiry(time.Minute))
+
+	repo.On("GetLoanByID", mock.Anything, int64(1)).Return(&model.Loan{ID: 1, BorrowerID: 2}, nil)
+	repo.On("GetInvestmentsByLoanID", mock.Anything, int64(1)).Return([]*model.Investment{}, nil)
+	store.On("Put", mock.Anything, mock.MatchedBy(func(key string) bool {
+		return strings.HasPrefix(key, "loans/1/approval_proof/") && strings.HasSuffix(key, ".png")
+	}), "image/png", pngHeader).Return(nil)
+	store.On("SignedURL", mock.Anything, mock.Anything, time.Minute).Return("http://localhost/proof.png?signature=abc", nil)
+
+	upload, err := uc.UploadLoanDocument(context.Background(), documentBorrower, 1, model.DocumentApprovalProof, "image/png", pngHeader)
+
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(upload.Key, "loans/1/approval_proof/"))
+	assert.Equal(t, "http://localhost/proof.png?signature=abc", upload.URL)
+	assert.Equal(t, "image/png", upload.ContentType)
+	assert.Equal(t, len(pngHeader), upload.Size)
+}
+
+func TestUploadLoanDocumentRejectsInvalidFiles(t *testing.T) {
+	repo := new(MockRepository)
+	store := new(mockBlobStore)
+	uc := NewLoanUsecase(repo, WithDocuments(nil, store))
+
+	tests := []struct {
+		name        string
+		kind        model.DocumentKind
+		contentType string
+		data        []byte
+		err         error
+	}{
+		{"empty", model.DocumentApprovalProof, "image/png", []byte{}, model.ErrDocumentEmpty},
+		{"too large", model.DocumentSignedAgreement, "application/pdf", make([]byte, model.MaxDocumentSize+1), model.ErrDocumentTooLarge},
+		{"pdf as approval proof", model.DocumentApprovalProof, "application/pdf", []byte("%PDF-1.4"), model.ErrDocumentUnsupportedType},
+		{"image as signed agreement", model.DocumentSignedAgreement, "image/png", pngHeader, model.ErrDocumentUnsupportedType},
+		{"declared type mismatch", model.DocumentApprovalProof, "image/jpeg", pngHeader, model.ErrDocumentUnsupportedType},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := uc.UploadLoanDocument(context.Background(), documentBorrower, 1, tt.kind, tt.contentType, tt.data)
+
+			assert.ErrorIs(t, err, tt.err)
+		})
+	}
+
+	store.AssertNotCalled(t, "Put", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestUploadLoanDocumentLoanNotFound(t *testing.T) {
+	repo := new(MockRepository)
+	store := new(mockBlobStore)
+	uc := NewLoanUsecase(repo, WithDocuments(nil, store))
+
+	repo.On("GetLoanByID", mock.Anything, int64(1)).Return(nil, errors.New("not found"))
+
+	_, err := uc.UploadLoanDocument(context.Background(), documentBorrower, 1, model.DocumentSignedAgreement, "", []byte("%PDF-1.4"))
+
+	assert.ErrorIs(t, err, model.ErrLoanNotFound)
+}
+
+func TestUploadLoanDocumentAccessDenied(t *testing.T) {
+	repo := new(MockRepository)
+	store := new(mockBlobStore)
+	uc := NewLoanUsecase(repo, WithDocuments(nil, store))
+
+	repo.On("GetLoanByID", mock.Anything, int64(1)).Return(&model.Loan{ID: 1, BorrowerID: 3}, nil)
+	repo.On("GetInvestmentsByLoanID", mock.Anything, int64(1)).Return([]*model.Investment{}, nil)
+
+	_, err := uc.UploadLoanDocument(context.Background(), documentBorrower, 1, model.DocumentSignedAgreement, "", []byte("%PDF-1.4"))
+
+	assert.ErrorIs(t, err, model.ErrLoanAccessDenied)
+	store.AssertNotCalled(t, "Put", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestGetLoansSignsDocumentURLs(t *testing.T) {
+	repo := new(MockRepository)
+	store := new(mockBlobStore)
+	uc := NewLoanUsecase(repo, WithDocuments(nil, store))
+
+	loans := []*model.Loan{{
+		ID:              1,
+		ApprovalProof:   sql.NullString{String: "loans/1/approval_proof/1.png", Valid: true},
+		AgreementLetter: sql.NullString{String: "https://file.io/1/agreement.pdf", Valid: true},
+	}}
+
+	repo.On("GetLoans", mock.Anything, mock.Anything).Return(loans, nil)
+	repo.On("CountLoans", mock.Anything, mock.Anything).Return(1, nil)
+	store.On("SignedURL", mock.Anything, "loans/1/approval_proof/1.png", 15*time.Minute).Return("http://localhost/proof.png?signature=abc", nil)
+
+	page, err := uc.GetLoans(context.Background(), model.LoanFilter{})
+	result := page.Data
+
+	assert.NoError(t, err)
+	assert.Equal(t, "http://localhost/proof.png?signature=abc", result[0].ApprovalProof.String)
+	assert.Equal(t, "https://file.io/1/agreement.pdf", result[0].AgreementLetter.String)
+	assert.False(t, result[0].BorrowerAgreement.Valid)
+	store.AssertNumberOfCalls(t, "SignedURL", 1)
+}
+
+func TestApproveLoanRecordsApprovalProof(t *testing.T) {
+	repo := new(MockRepository)
+	store := new(mockBlobStore)
+	uc := NewLoanUsecase(repo, WithDocuments(nil, store))
+
+	loan := &model.Loan{ID: 1, State: model.LoanStateProposed}
+
+	repo.On("GetLoanByID", mock.Anything, int64(1)).Return(loan, nil)
+	repo.On("LockLoan", mock.Anything, int64(1)).Return(loan, nil)
+	repo.On("GetEmployeeByID", mock.Anything, int64(555)).Return(&model.Employee{ID: 555}, nil)
+	repo.On("GetLoanDocumentsByChecksum", mock.Anything, checksumOf(string(pngHeader))).Return([]*model.LoanDocument{}, nil)
+	repo.On("UpdateLoan", mock.Anything, loan).Return(nil)
+	repo.On("CreateLoanDocument", mock.Anything, mock.Anything).Return(int64(1), nil)
+	repo.On("CreateLoanEvent", mock.Anything, mock.Anything).Return(int64(1), nil)
+	repo.On("CreateOutboxMessage", mock.Anything, mock.Anything).Return(int64(1), nil)
+	store.On("Get", mock.Anything, "loans/1/approval_proof/1.png").Return(pngHeader, nil)
+
+	err := uc.ApproveLoan(context.Background(), 1, 555, "loans/1/approval_proof/1.png")
+
+	assert.NoError(t, err)
+	repo.AssertCalled(t, "CreateLoanDocument", mock.Anything, &model.LoanDocument{
+		LoanID:      1,
+		Kind:        model.DocumentApprovalProof,
+		Location:    "loans/1/approval_proof/1.png",
+		Checksum:    checksumOf(string(pngHeader)),
+		ContentType: "image/png",
+		Size:        len(pngHeader),
+		Status:      model.DocumentAttached,
+	})
+}
+
+func TestApproveLoanRejectsInvalidApprovalProof(t *testing.T) {
+	tests := []struct {
+		name     string
+		proof    func(t *testing.T) string
+		attached []*model.LoanDocument
+		err      error
+	}{
+		{
+			name:  "empty location",
+			proof: func(t *testing.T) string { return "" },
+			err:   model.ErrDocumentEmpty,
+		},
+		{
+			name:  "empty file",
+			proof: func(t *testing.T) string { return serveDocument(t, []byte{}) },
+			err:   model.ErrDocumentEmpty,
+		},
+		{
+			name:  "unsupported type",
+			proof: func(t *testing.T) string { return serveDocument(t, []byte("%PDF-1.4")) },
+			err:   model.ErrDocumentUnsupportedType,
+		},
+		{
+			name: "unavailable",
+			proof: func(t *testing.T) string {
+				server := httptest.NewServer(http.NotFoundHandler())
+				t.Cleanup(server.Close)
+				return server.URL + "/missing.png"
+			},
+			err: model.ErrDocumentUnavailable,
+		},
+		{
+			name:     "already attached as proof",
+			proof:    func(t *testing.T) string { return serveDocument(t, pngHeader) },
+			attached: []*model.LoanDocument{{ID: 7, LoanID: 2, Kind: model.DocumentApprovalProof}},
+			err:      model.ErrDocumentDuplicate,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := new(MockRepository)
+			uc := NewLoanUsecase(repo, WithHTTPClient(http.DefaultClient))
+
+			repo.On("GetLoanByID", mock.Anything, int64(1)).Return(&model.Loan{ID: 1, State: model.LoanStateProposed}, nil)
+			repo.On("LockLoan", mock.Anything, int64(1)).Return(&model.Loan{ID: 1, State: model.LoanStateProposed}, nil)
+			repo.On("GetEmployeeByID", mock.Anything, int64(555)).Return(&model.Employee{ID: 555}, nil)
+			repo.On("GetLoanDocumentsByChecksum", mock.Anything, mock.Anything).Return(tt.attached, nil)
+
+			err := uc.ApproveLoan(context.Background(), 1, 555, tt.proof(t))
+
+			assert.ErrorIs(t, err, tt.err)
+			repo.AssertNotCalled(t, "UpdateLoan", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestFetchDocumentRejectsDisallowedURLs(t *testing.T) {
+	proof := serveDocument(t, pngHeader)
+	redirect := httptest.NewServer(http.RedirectHandler(proof, http.StatusFound))
+	t.Cleanup(redirect.Close)
+
+	tests := []struct {
+		name     string
+		uc       *LoanUsecase
+		location string
+	}{
+		{"internal address", NewLoanUsecase(nil), proof},
+		{"unsupported scheme", NewLoanUsecase(nil, WithHTTPClient(http.DefaultClient)), "file:///etc/passwd"},
+		{"host not allowed", NewLoanUsecase(nil, WithHTTPClient(http.DefaultClient), WithDocumentHosts("files.example.com")), proof},
+		{"redirect to host not allowed", NewLoanUsecase(nil, WithHTTPClient(http.DefaultClient), WithDocumentHosts("localhost")), strings.Replace(redirect.URL, "127.0.0.1", "localhost", 1)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.uc.fetchDocument(context.Background(), tt.location)
+
+			assert.ErrorIs(t, err, model.ErrDocumentUnavailable)
+		})
+	}
+
+	uc := NewLoanUsecase(nil, WithHTTPClient(http.DefaultClient), WithDocumentHosts("127.0.0.1"))
+	data, err := uc.fetchDocument(context.Background(), proof)
+
+	assert.NoError(t, err)
+	assert.Equal(t, pngHeader, data)
+}
+
+func TestApproveLoanAcceptsGeneratedAgreementChecksum(t *testing.T) {
+	repo := new(MockRepository)
+	uc := NewLoanUsecase(repo, WithHTTPClient(http.DefaultClient))
+
+	loan := &model.Loan{ID: 1, State: model.LoanStateProposed}
+	proof := serveDocument(t, pngHeader)
+
+	repo.On("GetLoanByID", mock.Anything, int64(1)).Return(loan, nil)
+	repo.On("LockLoan", mock.Anything, int64(1)).Return(loan, nil)
+	repo.On("GetEmployeeByID", mock.Anything, int64(555)).Return(&model.Employee{ID: 555}, nil)
+	repo.On("GetLoanDocumentsByChecksum", mock.Anything, mock.Anything).Return([]*model.LoanDocument{{ID: 7, Kind: model.DocumentInvestorAgreement}}, nil)
+	repo.On("UpdateLoan", mock.Anything, loan).Return(nil)
+	repo.On("CreateLoanDocument", mock.Anything, mock.Anything).Return(int64(1), nil)
+	repo.On("CreateLoanEvent", mock.Anything, mock.Anything).Return(int64(1), nil)
+	repo.On("CreateOutboxMessage", mock.Anything, mock.Anything).Return(int64(1), nil)
+
+	err := uc.ApproveLoan(context.Background(), 1, 555, proof)
+
+	assert.NoError(t, err)
+}
+
+func TestGetLoanDocuments(t *testing.T) {
+	repo := new(MockRepository)
+	store := new(mockBlobStore)
+	uc := NewLoanUsecase(repo, WithDocuments(nil, store))
+
+	repo.On("GetLoanByID", mock.Anything, int64(1)).Return(&model.Loan{ID: 1, BorrowerID: 2}, nil)
+	repo.On("GetInvestmentsByLoanID", mock.Anything, int64(1)).Return([]*model.Investment{}, nil)
+	repo.On("GetLoanDocumentsByLoanID", mock.Anything, int64(1)).Return([]*model.LoanDocument{
+		{ID: 1, LoanID: 1, Kind: model.DocumentApprovalProof, Location: "loans/1/approval_proof/1.png", Checksum: "abc"},
+		{ID: 2, LoanID: 1, Kind: model.DocumentSignedAgreement, Location: "https://file.io/1/agreement.pdf", Checksum: "def"},
+	}, nil)
+	store.On("SignedURL", mock.Anything, "loans/1/approval_proof/1.png", 15*time.Minute).Return("http://localhost/proof.png?signature=abc", nil)
+
+	documents, err := uc.GetLoanDocuments(context.Background(), documentBorrower, 1)
+
+	assert.NoError(t, err)
+	assert.Len(t, documents, 2)
+	assert.Equal(t, "http://localhost/proof.png?signature=abc", documents[0].Location)
+	assert.Equal(t, "abc", documents[0].Checksum)
+	assert.Equal(t, "https://file.io/1/agreement.pdf", documents[1].Location)
+}
+
+func TestGetLoanDocumentsLoanNotFound(t *testing.T) {
+	repo := new(MockRepository)
+	uc := NewLoanUsecase(repo)
+
+	repo.On("GetLoanByID", mock.Anything, int64(1)).Return(nil, errors.New("not found"))
+
+	_, err := uc.GetLoanDocuments(context.Background(), documentBorrower, 1)
+
+	assert.ErrorIs(t, err, model.ErrLoanNotFound)
+}
+
+func TestGetLoanDocumentsAccessDenied(t *testing.T) {
+	repo := new(MockRepository)
+	uc := NewLoanUsecase(repo)
+
+	repo.On("GetLoanByID", mock.Anything, int64(1)).Return(&model.Loan{ID: 1, BorrowerID: 2}, nil)
+	repo.On("GetInvestmentsByLoanID", mock.Anything, int64(1)).Return([]*model.Investment{{LoanID: 1, InvestorID: 5}}, nil)
+
+	_, err := uc.GetLoanDocuments(context.Background(), model.Actor{Role: model.RoleInvestor, ID: 6}, 1)
+
+	assert.ErrorIs(t, err, model.ErrLoanAccessDenied)
+	repo.AssertNotCalled(t, "GetLoanDocumentsByLoanID", mock.Anything, mock.Anything)
+}
@@ -0,0 +1,74 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+	"time"
+)
+
+// errInternalAddress is returned when a URL supplied by a user points at an
+// address of the service's own network.
+var errInternalAddress = errors.New("address is not publicly routable")
+
+// isInternalAddress reports whether addr is a loopback, private, link-local
+// or unspecified address, which requests to URLs supplied by users must not
+// reach.
+func isInternalAddress(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsLoopback() || addr.IsPrivate() || addr.IsUnspecified() ||
+		addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() || addr.IsInterfaceLocalMulticast()
+}
+
+// checkPublicHost fails when host is, or resolves to, an internal address. A
+// host that can not be resolved passes, its addresses are checked again when
+// it is dialed.
+func checkPublicHost(ctx context.Context, host string) error {
+	if addr, err := netip.ParseAddr(host); err == nil {
+		if isInternalAddress(addr) {
+			return errInternalAddress
+		}
+		return nil
+	}
+
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return nil
+	}
+	for _, addr := range addrs {
+		if isInternalAddress(addr) {
+			return errInternalAddress
+		}
+	}
+	return nil
+}
+
+// publicTransport returns a transport refusing to connect to internal
+// addresses. The check runs on the address actually dialed, after the host
+// was resolved, so a host resolving to another address than when it was
+// checked is still refused. Proxies are not used, since the proxy would make
+// the connection instead.
+func publicTransport() *http.Transport {
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return err
+			}
+			if isInternalAddress(addrPort.Addr()) {
+				return errInternalAddress
+			}
+			return nil
+		},
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return transport
+}
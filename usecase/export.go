@@ -0,0 +1,487 @@
+package usecase
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/aldipi/loan-service/model"
+	"github.com/aldipi/loan-service/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	defaultExportInterval   = 5 * time.Second
+	defaultExportBatchSize  = 10
+	defaultExportJobTimeout = time.Hour
+)
+
+var errNoTableEncoder = errors.New("exports are not configured")
+
+// TableEncoder writes tables into files of the export formats. Rows are
+// written as the table produces them, so tables of any size are exported with
+// little memory.
+type TableEncoder interface {
+	EncodeTable(w io.Writer, format model.ExportFormat, table model.Table) error
+}
+
+var loanExportColumns = []string{
+	"id", "state", "borrower_id", "loan_product_id", "principal_amount", "currency", "rate", "roi",
+	"approved_by", "disbursed_by", "created_at", "approved_at", "invested_at", "disbursed_at",
+}
+
+var investmentExportColumns = []string{
+	"id", "loan_id", "investor_id", "amount", "currency",
+	"settlement_amount", "settlement_currency", "fx_rate", "created_at",
+}
+
+var loanStateNames = map[model.LoanState]string{
+	model.LoanStateProposed:  "proposed",
+	model.LoanStateApproved:  "approved",
+	model.LoanStateInvested:  "invested",
+	model.LoanStateDisbursed: "disbursed",
+}
+
+// ExportLoans writes the loans matching the filter that the actor can see to
+// w, in the sort order of the filter and ignoring its limit. Employees export
+// every loan and borrowers only their own. Loans are streamed from the
+// database while they are written, so a failure after the first rows leaves
+// w with a partial file.
+func (u *LoanUsecase) ExportLoans(ctx context.Context, actor model.Actor, format model.ExportFormat, filter model.LoanFilter, w io.Writer) (err error) {
+	ctx, span := tracing.Start(ctx, "LoanUsecase.ExportLoans")
+	defer func() { tracing.End(span, err) }()
+
+	if !format.Valid() {
+		return model.ErrExportFormatInvalid
+	}
+	if u.tables == nil {
+		return errNoTableEncoder
+	}
+
+	filter, err = u.loanExportFilter(ctx, actor, filter)
+	if err != nil {
+		return err
+	}
+
+	_, err = u.exportLoans(ctx, format, filter, w)
+	return err
+}
+
+// ExportInvestments writes the investments matching the filter that the
+// actor can see to w, like ExportLoans. Employees export every investment and
+// investors only their own.
+func (u *LoanUsecase) ExportInvestments(ctx context.Context, actor model.Actor, format model.ExportFormat, filter model.InvestmentFilter, w io.Writer) (err error) {
+	ctx, span := tracing.Start(ctx, "LoanUsecase.ExportInvestments")
+	defer func() { tracing.End(span, err) }()
+
+	if !format.Valid() {
+		return model.ErrExportFormatInvalid
+	}
+	if u.tables == nil {
+		return errNoTableEncoder
+	}
+
+	filter, err = u.investmentExportFilter(ctx, actor, filter)
+	if err != nil {
+		return err
+	}
+
+	_, err = u.exportInvestments(ctx, format, filter, w)
+	return err
+}
+
+// CreateLoanExportJob queues the export of ExportLoans to be written in the
+// background, for ranges too large to be downloaded in one request.
+func (u *LoanUsecase) CreateLoanExportJob(ctx context.Context, actor model.Actor, format model.ExportFormat, filter model.LoanFilter) (job *model.ExportJob, err error) {
+	ctx, span := tracing.Start(ctx, "LoanUsecase.CreateLoanExportJob")
+	defer func() { tracing.End(span, err) }()
+
+	filter, err = u.loanExportFilter(ctx, actor, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return u.createExportJob(ctx, actor, model.ExportLoans, format, filter)
+}
+
+// CreateInvestmentExportJob queues the export of ExportInvestments to be
+// written in the background.
+func (u *LoanUsecase) CreateInvestmentExportJob(ctx context.Context, actor model.Actor, format model.ExportFormat, filter model.InvestmentFilter) (job *model.ExportJob, err error) {
+	ctx, span := tracing.Start(ctx, "LoanUsecase.CreateInvestmentExportJob")
+	defer func() { tracing.End(span, err) }()
+
+	filter, err = u.investmentExportFilter(ctx, actor, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return u.createExportJob(ctx, actor, model.ExportInvestments, format, filter)
+}
+
+// GetExportJob returns an export job requested by the actor, with a link to
+// download its file once it completed. Jobs of others are not found.
+func (u *LoanUsecase) GetExportJob(ctx context.Context, actor model.Actor, id int64) (job *model.ExportJob, err error) {
+	ctx, span := tracing.Start(ctx, "LoanUsecase.GetExportJob")
+	defer func() { tracing.End(span, err) }()
+
+	job, err = u.repo.GetExportJobByID(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, model.ErrExportJobNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if job.RequestedByRole != actor.Role || job.RequestedBy != actor.ID {
+		return nil, model.ErrExportJobNotFound
+	}
+
+	if job.State == model.ExportJobCompleted && u.blobs != nil {
+		job.DownloadURL, err = u.blobs.SignedURL(ctx, job.FileKey.String, u.signedURLExpiry)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return job, nil
+}
+
+// loanExportFilter limits the filter to the loans the actor can export.
+func (u *LoanUsecase) loanExportFilter(ctx context.Context, actor model.Actor, filter model.LoanFilter) (model.LoanFilter, error) {
+	switch actor.Role {
+	case model.RoleEmployee:
+		_, err := u.repo.GetEmployeeByID(ctx, actor.ID)
+		if err != nil {
+			return filter, model.ErrExportAccessDenied
+		}
+	case model.RoleBorrower:
+		filter.BorrowerID = actor.ID
+	default:
+		return filter, model.ErrExportAccessDenied
+	}
+
+	return filter, nil
+}
+
+// investmentExportFilter limits the filter to the investments the actor can
+// export.
+func (u *LoanUsecase) investmentExportFilter(ctx context.Context, actor model.Actor, filter model.InvestmentFilter) (model.InvestmentFilter, error) {
+	switch actor.Role {
+	case model.RoleEmployee:
+		_, err := u.repo.GetEmployeeByID(ctx, actor.ID)
+		if err != nil {
+			return filter, model.ErrExportAccessDenied
+		}
+	case model.RoleInvestor:
+		filter.InvestorID = actor.ID
+	default:
+		return filter, model.ErrExportAccessDenied
+	}
+
+	return filter, nil
+}
+
+func (u *LoanUsecase) createExportJob(ctx context.Context, actor model.Actor, kind model.ExportKind, format model.ExportFormat, filter any) (*model.ExportJob, error) {
+	if !format.Valid() {
+		return nil, model.ErrExportFormatInvalid
+	}
+	if u.tables == nil {
+		return nil, errNoTableEncoder
+	}
+	if u.blobs == nil {
+		return nil, errNoBlobStore
+	}
+
+	data, err := json.Marshal(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	job := &model.ExportJob{
+		Kind:            kind,
+		Format:          format,
+		Filter:          data,
+		RequestedByRole: actor.Role,
+		RequestedBy:     actor.ID,
+		State:           model.ExportJobPending,
+		CreatedAt:       now(),
+	}
+	job.ID, err = u.repo.CreateExportJob(ctx, job)
+	if err != nil {
+		return nil, err
+	}
+	job.LastUpdatedAt = job.CreatedAt
+
+	return job, nil
+}
+
+// exportLoans writes the loans matching the filter and returns how many were
+// written.
+func (u *LoanUsecase) exportLoans(ctx context.Context, format model.ExportFormat, filter model.LoanFilter, w io.Writer) (count int64, err error) {
+	if filter.Sort.Field == "" {
+		filter.Sort.Field = model.SortByID
+	}
+
+	err = u.tables.EncodeTable(w, format, model.Table{
+		Name:    string(model.ExportLoans),
+		Columns: loanExportColumns,
+		Rows: func(write func(values ...any) error) error {
+			return u.repo.StreamLoans(ctx, filter, func(loan *model.Loan) error {
+				count++
+				return write(
+					loan.ID,
+					loanStateNames[loan.State],
+					loan.BorrowerID,
+					nullInt64(loan.LoanProductID),
+					loan.PrincipalAmount,
+					string(loan.Currency),
+					loan.Rate,
+					loan.ROI,
+					nullInt64(loan.ApprovedBy),
+					nullInt64(loan.DisbursedBy),
+					loan.CreatedAt,
+					nullTime(loan.ApprovedAt),
+					nullTime(loan.InvestedAt),
+					nullTime(loan.DisbursedAt),
+				)
+			})
+		},
+	})
+	return count, err
+}
+
+// exportInvestments writes the investments matching the filter and returns
+// how many were written.
+func (u *LoanUsecase) exportInvestments(ctx context.Context, format model.ExportFormat, filter model.InvestmentFilter, w io.Writer) (count int64, err error) {
+	if filter.Sort.Field == "" {
+		filter.Sort.Field = model.SortByID
+	}
+
+	err = u.tables.EncodeTable(w, format, model.Table{
+		Name:    string(model.ExportInvestments),
+		Columns: investmentExportColumns,
+		Rows: func(write func(values ...any) error) error {
+			return u.repo.StreamInvestments(ctx, filter, func(investment *model.Investment) error {
+				count++
+				return write(
+					investment.ID,
+					investment.LoanID,
+					investment.InvestorID,
+					investment.Amount,
+					string(investment.Currency),
+					investment.SettlementAmount,
+					string(investment.SettlementCurrency),
+					investment.FXRate,
+					investment.CreatedAt,
+				)
+			})
+		},
+	})
+	return count, err
+}
+
+// nullInt64 returns the value, or nil for an empty cell.
+func nullInt64(value sql.NullInt64) any {
+	if !value.Valid {
+		return nil
+	}
+	return value.Int64
+}
+
+// nullTime returns the time, or nil for an empty cell.
+func nullTime(value sql.NullTime) any {
+	if !value.Valid {
+		return nil
+	}
+	return value.Time
+}
+
+// ExportWorker runs pending export jobs in the background, writing their files
+// into the blob store. A job running for longer than JobTimeout fails, and a
+// job still marked running after JobTimeout was left behind by a stopped
+// worker and is run again.
+type ExportWorker struct {
+	usecase *LoanUsecase
+	status  workerStatus
+
+	Interval   time.Duration
+	BatchSize  int
+	JobTimeout time.Duration
+	Logger     *slog.Logger
+}
+
+func NewExportWorker(usecase *LoanUsecase) *ExportWorker {
+	return &ExportWorker{
+		usecase:    usecase,
+		Interval:   defaultExportInterval,
+		BatchSize:  defaultExportBatchSize,
+		JobTimeout: defaultExportJobTimeout,
+		Logger:     slog.Default(),
+	}
+}
+
+// Run requeues the jobs left running by a stopped worker, then runs pending
+// jobs every Interval until ctx is cancelled. Errors are logged and the jobs
+// are retried on the next tick.
+func (w *ExportWorker) Run(ctx context.Context) {
+	w.status.setRunning(true)
+	defer w.status.setRunning(false)
+
+	_, err := w.RequeueStale(ctx)
+	if err != nil && ctx.Err() == nil {
+		w.Logger.ErrorContext(ctx, "requeue stale export jobs", "error", err)
+	}
+
+	ticker := time.NewTicker(w.Interval)
+	defer ticker.Stop()
+
+	for {
+		_, err := w.RunPending(ctx)
+		if ctx.Err() == nil {
+			w.status.record(err)
+			if err != nil {
+				w.Logger.ErrorContext(ctx, "run export jobs", "error", err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Check returns an error unless the worker is running and its last batch was
+// run. Jobs that failed do not fail the batch.
+func (w *ExportWorker) Check(ctx context.Context) error {
+	return w.status.check()
+}
+
+// RequeueStale makes the jobs left running by a stopped worker pending again
+// and returns how many there were. Jobs running for longer than JobTimeout are
+// stopped by their worker, so a job not updated since is no longer running.
+func (w *ExportWorker) RequeueStale(ctx context.Context) (int, error) {
+	requeued, err := w.usecase.repo.RequeueStaleExportJobs(ctx, w.JobTimeout)
+	if err != nil {
+		return 0, err
+	}
+	if requeued > 0 {
+		w.Logger.WarnContext(ctx, "requeued stale export jobs", "count", requeued)
+	}
+
+	return int(requeued), nil
+}
+
+// RunPending runs one batch of pending jobs and returns how many completed.
+// Jobs already started by another worker are skipped.
+func (w *ExportWorker) RunPending(ctx context.Context) (int, error) {
+	jobs, err := w.usecase.repo.GetPendingExportJobs(ctx, w.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	var completed int
+	for _, job := range jobs {
+		if ctx.Err() != nil {
+			return completed, ctx.Err()
+		}
+
+		started, err := w.usecase.repo.StartExportJob(ctx, job.ID, now())
+		if err != nil {
+			return completed, err
+		}
+		if !started {
+			continue
+		}
+
+		jobCtx, cancel := context.WithTimeout(ctx, w.JobTimeout)
+		err = w.usecase.runExportJob(jobCtx, job)
+		cancel()
+		if err != nil {
+			w.Logger.WarnContext(ctx, "export job failed", "export_job_id", job.ID, "error", err)
+			job.State = model.ExportJobFailed
+			job.LastError = sql.NullString{String: err.Error(), Valid: true}
+		} else {
+			completed++
+		}
+
+		// The outcome is recorded even when the worker is stopping, so the
+		// job is not left running.
+		job.CompletedAt = sql.NullTime{Time: now(), Valid: true}
+		err = w.usecase.repo.FinishExportJob(context.WithoutCancel(ctx), job)
+		if err != nil {
+			return completed, err
+		}
+	}
+
+	return completed, nil
+}
+
+// runExportJob writes the export of the job to a temporary file, which is
+// then uploaded to the blob store, and marks the job completed.
+func (u *LoanUsecase) runExportJob(ctx context.Context, job *model.ExportJob) (err error) {
+	ctx, span := tracing.Start(ctx, "LoanUsecase.runExportJob", attribute.Int64("export_job.id", job.ID))
+	defer func() { tracing.End(span, err) }()
+
+	if u.tables == nil {
+		return errNoTableEncoder
+	}
+	if u.blobs == nil {
+		return errNoBlobStore
+	}
+
+	file, err := os.CreateTemp("", "export-*."+string(job.Format))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	var count int64
+	switch job.Kind {
+	case model.ExportLoans:
+		var filter model.LoanFilter
+		err = json.Unmarshal(job.Filter, &filter)
+		if err == nil {
+			count, err = u.exportLoans(ctx, job.Format, filter, file)
+		}
+	case model.ExportInvestments:
+		var filter model.InvestmentFilter
+		err = json.Unmarshal(job.Filter, &filter)
+		if err == nil {
+			count, err = u.exportInvestments(ctx, job.Format, filter, file)
+		}
+	default:
+		err = fmt.Errorf("unknown export kind %q", job.Kind)
+	}
+	if err != nil {
+		return err
+	}
+
+	size, err := file.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	_, err = file.Seek(0, io.SeekStart)
+	if err != nil {
+		return err
+	}
+
+	key := fmt.Sprintf("exports/%d/%s.%s", job.ID, job.Kind, job.Format)
+	err = u.blobs.PutReader(ctx, key, job.Format.ContentType(), file, size)
+	if err != nil {
+		return err
+	}
+
+	job.State = model.ExportJobCompleted
+	job.RowCount = count
+	job.FileKey = sql.NullString{String: key, Valid: true}
+	return nil
+}
@@ -0,0 +1,271 @@
+package usecase
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/aldipi/loan-service/model"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// recordingEncoder writes every row of a table as a line of its values.
+type recordingEncoder struct {
+	tables []model.Table
+	rows   [][]any
+}
+
+func (e *recordingEncoder) EncodeTable(w io.Writer, format model.ExportFormat, table model.Table) error {
+	e.tables = append(e.tables, table)
+	return table.Rows(func(values ...any) error {
+		e.rows = append(e.rows, values)
+		_, err := fmt.Fprintln(w, values...)
+		return err
+	})
+}
+
+func TestExportLoans(t *testing.T) {
+	repo := new(MockRepository)
+	encoder := &recordingEncoder{}
+	uc := NewLoanUsecase(repo, WithExports(encoder))
+
+	createdAt := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	state := model.LoanStateApproved
+	filter := model.LoanFilter{State: &state, CreatedFrom: createdAt, Limit: 5}
+	repo.On("GetEmployeeByID", mock.Anything, int64(555)).Return(&model.Employee{ID: 555}, nil)
+	repo.On("StreamLoans", mock.Anything, model.LoanFilter{State: &state, CreatedFrom: createdAt, Limit: 5, Sort: model.Sort{Field: model.SortByID}}).Return([]*model.Loan{{
+		ID:              1,
+		State:           model.LoanStateApproved,
+		BorrowerID:      123,
+		LoanProductID:   sql.NullInt64{Int64: 7, Valid: true},
+		PrincipalAmount: 100000000,
+		Currency:        "IDR",
+		Rate:            decimal.NewFromInt(6),
+		ROI:             decimal.NewFromInt(3),
+		ApprovedBy:      sql.NullInt64{Int64: 555, Valid: true},
+		CreatedAt:       createdAt,
+		ApprovedAt:      sql.NullTime{Time: createdAt, Valid: true},
+	}}, nil)
+
+	out := &bytes.Buffer{}
+	err := uc.ExportLoans(context.Background(), model.Actor{Role: model.RoleEmployee, ID: 555}, model.ExportFormatCSV, filter, out)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "loans", encoder.tables[0].Name)
+	assert.Equal(t, loanExportColumns, encoder.tables[0].Columns)
+	assert.Equal(t, [][]any{{
+		int64(1), "approved", int64(123), int64(7), int64(100000000), "IDR", decimal.NewFromInt(6), decimal.NewFromInt(3),
+		int64(555), nil, createdAt, createdAt, nil, nil,
+	}}, encoder.rows)
+	assert.NotEmpty(t, out.String())
+}
+
+func TestExportLoansBorrowerExportsOwnLoans(t *testing.T) {
+	repo := new(MockRepository)
+	uc := NewLoanUsecase(repo, WithExports(&recordingEncoder{}))
+
+	repo.On("StreamLoans", mock.Anything, model.LoanFilter{BorrowerID: 123, Sort: model.Sort{Field: model.SortByID}}).Return([]*model.Loan{}, nil)
+
+	err := uc.ExportLoans(context.Background(), model.Actor{Role: model.RoleBorrower, ID: 123}, model.ExportFormatXLSX, model.LoanFilter{BorrowerID: 456}, io.Discard)
+
+	assert.NoError(t, err)
+	repo.AssertExpectations(t)
+}
+
+func TestExportInvestmentsInvestorExportsOwnInvestments(t *testing.T) {
+	repo := new(MockRepository)
+	encoder := &recordingEncoder{}
+	uc := NewLoanUsecase(repo, WithExports(encoder))
+
+	createdAt := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	repo.On("StreamInvestments", mock.Anything, model.InvestmentFilter{InvestorID: 9, Sort: model.Sort{Field: model.SortByAmount, Desc: true}}).Return([]*model.Investment{{
+		ID:                 5,
+		LoanID:             1,
+		InvestorID:         9,
+		Amount:             50000000,
+		Currency:           "IDR",
+		SettlementAmount:   330000,
+		SettlementCurrency: "USD",
+		FXRate:             decimal.RequireFromString("0.000066"),
+		CreatedAt:          createdAt,
+	}}, nil)
+
+	err := uc.ExportInvestments(context.Background(), model.Actor{Role: model.RoleInvestor, ID: 9}, model.ExportFormatCSV,
+		model.InvestmentFilter{InvestorID: 10, Sort: model.Sort{Field: model.SortByAmount, Desc: true}}, io.Discard)
+
+	assert.NoError(t, err)
+	assert.Equal(t, [][]any{{
+		int64(5), int64(1), int64(9), int64(50000000), "IDR", int64(330000), "USD", decimal.RequireFromString("0.000066"), createdAt,
+	}}, encoder.rows)
+}
+
+func TestExportDenied(t *testing.T) {
+	repo := new(MockRepository)
+	uc := NewLoanUsecase(repo, WithExports(&recordingEncoder{}))
+
+	repo.On("GetEmployeeByID", mock.Anything, int64(1)).Return(nil, sql.ErrNoRows)
+
+	err := uc.ExportLoans(context.Background(), model.Actor{Role: model.RoleInvestor, ID: 9}, model.ExportFormatCSV, model.LoanFilter{}, io.Discard)
+	assert.Equal(t, model.ErrExportAccessDenied, err)
+
+	err = uc.ExportLoans(context.Background(), model.Actor{Role: model.RoleEmployee, ID: 1}, model.ExportFormatCSV, model.LoanFilter{}, io.Discard)
+	assert.Equal(t, model.ErrExportAccessDenied, err)
+
+	err = uc.ExportInvestments(context.Background(), model.Actor{Role: model.RoleBorrower, ID: 123}, model.ExportFormatCSV, model.InvestmentFilter{}, io.Discard)
+	assert.Equal(t, model.ErrExportAccessDenied, err)
+
+	_, err = uc.CreateLoanExportJob(context.Background(), model.Actor{Role: model.RoleInvestor, ID: 9}, model.ExportFormatCSV, model.LoanFilter{})
+	assert.Equal(t, model.ErrExportAccessDenied, err)
+
+	repo.AssertNotCalled(t, "StreamLoans", mock.Anything, mock.Anything)
+	repo.AssertNotCalled(t, "StreamInvestments", mock.Anything, mock.Anything)
+}
+
+func TestExportInvalidFormat(t *testing.T) {
+	repo := new(MockRepository)
+	uc := NewLoanUsecase(repo, WithExports(&recordingEncoder{}), WithDocuments(nil, new(mockBlobStore)))
+
+	err := uc.ExportLoans(context.Background(), model.Actor{Role: model.RoleBorrower, ID: 123}, "pdf", model.LoanFilter{}, io.Discard)
+	assert.Equal(t, model.ErrExportFormatInvalid, err)
+
+	_, err = uc.CreateLoanExportJob(context.Background(), model.Actor{Role: model.RoleBorrower, ID: 123}, "pdf", model.LoanFilter{})
+	assert.Equal(t, model.ErrExportFormatInvalid, err)
+}
+
+func TestCreateLoanExportJob(t *testing.T) {
+	repo := new(MockRepository)
+	uc := NewLoanUsecase(repo, WithExports(&recordingEncoder{}), WithDocuments(nil, new(mockBlobStore)))
+
+	repo.On("CreateExportJob", mock.Anything, mock.Anything).Return(int64(4), nil)
+
+	job, err := uc.CreateLoanExportJob(context.Background(), model.Actor{Role: model.RoleBorrower, ID: 123}, model.ExportFormatXLSX, model.LoanFilter{MinAmount: 1000})
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), job.ID)
+	assert.Equal(t, model.ExportJobPending, job.State)
+	assert.Equal(t, model.ExportLoans, job.Kind)
+	assert.Equal(t, model.RoleBorrower, job.RequestedByRole)
+	assert.Equal(t, int64(123), job.RequestedBy)
+
+	var filter model.LoanFilter
+	assert.NoError(t, json.Unmarshal(job.Filter, &filter))
+	assert.Equal(t, model.LoanFilter{BorrowerID: 123, MinAmount: 1000}, filter)
+}
+
+func TestGetExportJob(t *testing.T) {
+	repo := new(MockRepository)
+	store := new(mockBlobStore)
+	uc := NewLoanUsecase(repo, WithExports(&recordingEncoder{}), WithDocuments(nil, store), WithSignedURLExpiry(time.Minute))
+
+	repo.On("GetExportJobByID", mock.Anything, int64(4)).Return(&model.ExportJob{
+		ID:              4,
+		RequestedByRole: model.RoleEmployee,
+		RequestedBy:     555,
+		State:           model.ExportJobCompleted,
+		FileKey:         sql.NullString{String: "exports/4/loans.csv", Valid: true},
+	}, nil)
+	repo.On("GetExportJobByID", mock.Anything, int64(5)).Return(nil, sql.ErrNoRows)
+	store.On("SignedURL", mock.Anything, "exports/4/loans.csv", time.Minute).Return("http://localhost/exports/4/loans.csv?signature=abc", nil)
+
+	job, err := uc.GetExportJob(context.Background(), model.Actor{Role: model.RoleEmployee, ID: 555}, 4)
+	assert.NoError(t, err)
+	assert.Equal(t, "http://localhost/exports/4/loans.csv?signature=abc", job.DownloadURL)
+
+	_, err = uc.GetExportJob(context.Background(), model.Actor{Role: model.RoleBorrower, ID: 555}, 4)
+	assert.Equal(t, model.ErrExportJobNotFound, err)
+
+	_, err = uc.GetExportJob(context.Background(), model.Actor{Role: model.RoleEmployee, ID: 555}, 5)
+	assert.Equal(t, model.ErrExportJobNotFound, err)
+}
+
+func TestExportWorkerRunPending(t *testing.T) {
+	repo := new(MockRepository)
+	store := new(mockBlobStore)
+	uc := NewLoanUsecase(repo, WithExports(&recordingEncoder{}), WithDocuments(nil, store))
+	worker := NewExportWorker(uc)
+
+	completed := &model.ExportJob{ID: 4, Kind: model.ExportLoans, Format: model.ExportFormatCSV, Filter: json.RawMessage(`{"BorrowerID":123}`)}
+	taken := &model.ExportJob{ID: 5, Kind: model.ExportLoans, Format: model.ExportFormatCSV, Filter: json.RawMessage(`{}`)}
+	failed := &model.ExportJob{ID: 6, Kind: model.ExportInvestments, Format: model.ExportFormatXLSX, Filter: json.RawMessage(`{}`)}
+
+	repo.On("GetPendingExportJobs", mock.Anything, defaultExportBatchSize).Return([]*model.ExportJob{completed, taken, failed}, nil)
+	repo.On("StartExportJob", mock.Anything, int64(4), mock.Anything).Return(true, nil)
+	repo.On("StartExportJob", mock.Anything, int64(5), mock.Anything).Return(false, nil)
+	repo.On("StartExportJob", mock.Anything, int64(6), mock.Anything).Return(true, nil)
+	repo.On("StreamLoans", mock.Anything, model.LoanFilter{BorrowerID: 123, Sort: model.Sort{Field: model.SortByID}}).Return([]*model.Loan{{ID: 1}, {ID: 2}}, nil)
+	repo.On("StreamInvestments", mock.Anything, mock.Anything).Return(nil, errors.New("connection reset"))
+	store.On("PutReader", mock.Anything, "exports/4/loans.csv", "text/csv; charset=utf-8", mock.Anything, mock.Anything).Return(nil)
+	repo.On("FinishExportJob", mock.Anything, mock.Anything).Return(nil)
+
+	count, err := worker.RunPending(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	assert.Equal(t, model.ExportJobCompleted, completed.State)
+	assert.Equal(t, int64(2), completed.RowCount)
+	assert.Equal(t, "exports/4/loans.csv", completed.FileKey.String)
+	assert.True(t, completed.CompletedAt.Valid)
+	store.AssertCalled(t, "PutReader", mock.Anything, "exports/4/loans.csv", "text/csv; charset=utf-8", mock.MatchedBy(func(data []byte) bool {
+		return bytes.Count(data, []byte("\n")) == 2
+	}), mock.Anything)
+
+	assert.Equal(t, model.ExportJobFailed, failed.State)
+	assert.Equal(t, "connection reset", failed.LastError.String)
+	assert.True(t, failed.CompletedAt.Valid)
+
+	repo.AssertNumberOfCalls(t, "FinishExportJob", 2)
+	repo.AssertNotCalled(t, "StreamLoans", mock.Anything, model.LoanFilter{Sort: model.Sort{Field: model.SortByID}})
+}
+
+func TestExportWorkerJobTimeout(t *testing.T) {
+	repo := new(MockRepository)
+	uc := NewLoanUsecase(repo, WithExports(&recordingEncoder{}), WithDocuments(nil, new(mockBlobStore)))
+	worker := NewExportWorker(uc)
+	worker.JobTimeout = 10 * time.Millisecond
+
+	job := &model.ExportJob{ID: 4, Kind: model.ExportLoans, Format: model.ExportFormatCSV, Filter: json.RawMessage(`{}`)}
+
+	repo.On("GetPendingExportJobs", mock.Anything, defaultExportBatchSize).Return([]*model.ExportJob{job}, nil)
+	repo.On("StartExportJob", mock.Anything, int64(4), mock.Anything).Return(true, nil)
+	repo.On("StreamLoans", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		<-args.Get(0).(context.Context).Done()
+	}).Return(nil, context.DeadlineExceeded)
+	repo.On("FinishExportJob", mock.Anything, job).Return(nil)
+
+	count, err := worker.RunPending(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, 0, count)
+	assert.Equal(t, model.ExportJobFailed, job.State)
+	assert.Equal(t, context.DeadlineExceeded.Error(), job.LastError.String)
+}
+
+func TestExportWorkerRunRequeuesStaleJobs(t *testing.T) {
+	repo := new(MockRepository)
+	worker := NewExportWorker(NewLoanUsecase(repo))
+
+	repo.On("RequeueStaleExportJobs", mock.Anything, defaultExportJobTimeout).Return(int64(2), nil)
+	repo.On("GetPendingExportJobs", mock.Anything, defaultExportBatchSize).Return([]*model.ExportJob{}, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	worker.Run(ctx)
+
+	repo.AssertCalled(t, "RequeueStaleExportJobs", mock.Anything, defaultExportJobTimeout)
+	repo.AssertCalled(t, "GetPendingExportJobs", mock.Anything, defaultExportBatchSize)
+}
+
+func TestExportWorkerCheck(t *testing.T) {
+	worker := NewExportWorker(NewLoanUsecase(new(MockRepository)))
+
+	assert.Error(t, worker.Check(context.Background()))
+}
@@ -0,0 +1,55 @@
+package usecase
+
+import (
+	"context"
+
+	"github.com/aldipi/loan-service/model"
+	"github.com/shopspring/decimal"
+)
+
+// FXRateProvider quotes exchange rates as the number of units of the to
+// currency per unit of the from currency. Rate returns
+// model.ErrFXRateUnavailable for a pair it has no rate for.
+type FXRateProvider interface {
+	Rate(ctx context.Context, from model.Currency, to model.Currency) (decimal.Decimal, error)
+}
+
+// fxRate returns the rate converting from one currency to another. Amounts in
+// the same currency convert at 1 without asking the provider.
+func (u *LoanUsecase) fxRate(ctx context.Context, from model.Currency, to model.Currency) (decimal.Decimal, error) {
+	if from == to {
+		return decimal.NewFromInt(1), nil
+	}
+
+	if u.fxRates == nil {
+		return decimal.Decimal{}, model.ErrFXRateUnavailable
+	}
+
+	return u.fxRates.Rate(ctx, from, to)
+}
+
+// converter converts amounts into one currency, asking the provider once for
+// the rate of every other currency.
+type converter struct {
+	u     *LoanUsecase
+	to    model.Currency
+	rates map[model.Currency]decimal.Decimal
+}
+
+func (u *LoanUsecase) newConverter(to model.Currency) *converter {
+	return &converter{u: u, to: to, rates: map[model.Currency]decimal.Decimal{}}
+}
+
+func (c *converter) convert(ctx context.Context, amount int64, from model.Currency) (int64, error) {
+	rate, ok := c.rates[from]
+	if !ok {
+		var err error
+		rate, err = c.u.fxRate(ctx, from, c.to)
+		if err != nil {
+			return 0, err
+		}
+		c.rates[from] = rate
+	}
+
+	return from.Convert(amount, rate, c.to), nil
+}
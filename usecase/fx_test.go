@@ -0,0 +1,133 @@
+package usecase
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/aldipi/loan-service/model"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type mockFXRates struct {
+	mock.Mock
+}
+
+func (m *mockFXRates) Rate(ctx context.Context, from model.Currency, to model.Currency) (decimal.Decimal, error) {
+	args := m.Called(ctx, from, to)
+	return args.Get(0).(decimal.Decimal), args.Error(1)
+}
+
+func TestConvert(t *testing.T) {
+	// IDR 1,000,000.00 at 0.0000625 USD per IDR
+	assert.Equal(t, int64(6250), model.Currency("IDR").Convert(100000000, decimal.RequireFromString("0.0000625"), "USD"))
+	// USD 1.00 at 155.55 JPY per USD, rounded to whole yen
+	assert.Equal(t, int64(156), model.Currency("USD").Convert(100, decimal.RequireFromString("155.55"), "JPY"))
+	assert.Equal(t, int64(100), model.Currency("IDR").Convert(100, decimal.Zero, "IDR"))
+}
+
+func TestCreateInvestmentInOtherCurrency(t *testing.T) {
+	repo := new(MockRepository)
+	rates := new(mockFXRates)
+	uc := NewLoanUsecase(repo, WithFXRates(rates))
+
+	loan := &model.Loan{ID: 1, PrincipalAmount: 100000000, Currency: "IDR", State: model.LoanStateApproved}
+	wallet := &model.Wallet{InvestorID: 100, Currency: "SGD", Available: 50000}
+	rate := decimal.RequireFromString("0.000085")
+
+	expectJournalEntries(repo)
+	repo.On("GetInvestorByID", mock.Anything, int64(100)).Return(&model.Investor{ID: 100, Currency: "SGD"}, nil)
+	repo.On("LockLoan", mock.Anything, int64(1)).Return(loan, nil)
+	repo.On("GetInvestmentsByLoanID", mock.Anything, int64(1)).Return([]*model.Investment{}, nil)
+	repo.On("GetWalletByInvestorID", mock.Anything, int64(100)).Return(&model.Wallet{InvestorID: 100, Currency: "SGD", Available: 50000}, nil)
+	rates.On("Rate", mock.Anything, model.Currency("IDR"), model.Currency("SGD")).Return(rate, nil)
+	repo.On("CreateInvestment", mock.Anything, mock.Anything).Return(int64(3), nil)
+	repo.On("LockWallet", mock.Anything, int64(100)).Return(wallet, nil)
+	repo.On("UpdateWallet", mock.Anything, wallet).Return(nil)
+	repo.On("CreateWalletEntry", mock.Anything, mock.Anything).Return(int64(1), nil)
+	repo.On("CreateLoanEvent", mock.Anything, mock.Anything).Return(int64(1), nil)
+	repo.On("CreateOutboxMessage", mock.Anything, mock.Anything).Return(int64(1), nil)
+
+	// IDR 400,000.00 at 0.000085 SGD per IDR is SGD 34.00.
+	investment, err := uc.CreateInvestment(context.Background(), 100, 1, 40000000)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(40000000), investment.Amount)
+	assert.Equal(t, model.Currency("IDR"), investment.Currency)
+	assert.Equal(t, int64(3400), investment.SettlementAmount)
+	assert.Equal(t, model.Currency("SGD"), investment.SettlementCurrency)
+	assert.True(t, rate.Equal(investment.FXRate))
+	assert.Equal(t, int64(46600), wallet.Available)
+	assert.Equal(t, int64(3400), wallet.Reserved)
+	repo.AssertCalled(t, "CreateWalletEntry", mock.Anything, mock.MatchedBy(func(e *model.WalletEntry) bool {
+		return e.Type == model.WalletReservation && e.Amount == 3400 && e.Currency == "SGD"
+	}))
+	repo.AssertCalled(t, "CreateJournalEntry", mock.Anything, mock.MatchedBy(func(e *model.JournalEntry) bool {
+		return e.Kind == model.JournalInvestment && e.Currency == "SGD" && e.InvestmentID == sql.NullInt64{Int64: 3, Valid: true}
+	}))
+}
+
+func TestCreateInvestmentInOtherCurrencyInsufficientFunds(t *testing.T) {
+	repo := new(MockRepository)
+	rates := new(mockFXRates)
+	uc := NewLoanUsecase(repo, WithFXRates(rates))
+
+	loan := &model.Loan{ID: 1, PrincipalAmount: 100000000, Currency: "IDR", State: model.LoanStateApproved}
+
+	// The wallet covers the amount in IDR, but not once converted to SGD.
+	repo.On("GetInvestorByID", mock.Anything, int64(100)).Return(&model.Investor{ID: 100, Currency: "SGD"}, nil)
+	repo.On("LockLoan", mock.Anything, int64(1)).Return(loan, nil)
+	repo.On("GetInvestmentsByLoanID", mock.Anything, int64(1)).Return([]*model.Investment{}, nil)
+	repo.On("GetWalletByInvestorID", mock.Anything, int64(100)).Return(&model.Wallet{InvestorID: 100, Currency: "SGD", Available: 3399}, nil)
+	rates.On("Rate", mock.Anything, model.Currency("IDR"), model.Currency("SGD")).Return(decimal.RequireFromString("0.000085"), nil)
+
+	_, err := uc.CreateInvestment(context.Background(), 100, 1, 40000000)
+
+	assert.ErrorIs(t, err, model.ErrInsufficientFunds)
+	repo.AssertNotCalled(t, "CreateInvestment", mock.Anything, mock.Anything)
+}
+
+func TestCreateInvestmentWithoutFXRates(t *testing.T) {
+	repo := new(MockRepository)
+	uc := NewLoanUsecase(repo)
+
+	loan := &model.Loan{ID: 1, PrincipalAmount: 1000000, Currency: "IDR", State: model.LoanStateApproved}
+
+	repo.On("GetInvestorByID", mock.Anything, int64(100)).Return(&model.Investor{ID: 100, Currency: "SGD"}, nil)
+	repo.On("LockLoan", mock.Anything, int64(1)).Return(loan, nil)
+	repo.On("GetInvestmentsByLoanID", mock.Anything, int64(1)).Return([]*model.Investment{}, nil)
+	repo.On("GetWalletByInvestorID", mock.Anything, int64(100)).Return(&model.Wallet{InvestorID: 100, Currency: "SGD", Available: 10000000}, nil)
+
+	_, err := uc.CreateInvestment(context.Background(), 100, 1, 400000)
+
+	assert.ErrorIs(t, err, model.ErrFXRateUnavailable)
+	repo.AssertNotCalled(t, "CreateInvestment", mock.Anything, mock.Anything)
+}
+
+func TestGetPortfolioInReportingCurrency(t *testing.T) {
+	repo := new(MockRepository)
+	rates := new(mockFXRates)
+	uc := NewLoanUsecase(repo, WithFXRates(rates))
+
+	repo.On("GetInvestorByID", mock.Anything, int64(100)).Return(&model.Investor{ID: 100, Currency: "SGD"}, nil)
+	repo.On("GetHoldingsByInvestorID", mock.Anything, int64(100)).Return([]*model.Holding{
+		{InvestmentID: 1, LoanID: 10, Amount: 100000000, Currency: "IDR", LoanState: model.LoanStateApproved, BorrowerID: 123, ROI: decimal.NewFromInt(10)},
+		{InvestmentID: 2, LoanID: 11, Amount: 20000, Currency: "SGD", LoanState: model.LoanStateDisbursed, BorrowerID: 456, ROI: decimal.NewFromInt(5)},
+	}, nil)
+	repo.On("SumWalletEntries", mock.Anything, int64(100), model.WalletPayout).Return(int64(1000), nil)
+	rates.On("Rate", mock.Anything, model.Currency("IDR"), model.Currency("USD")).Return(decimal.RequireFromString("0.0000625"), nil).Once()
+	rates.On("Rate", mock.Anything, model.Currency("SGD"), model.Currency("USD")).Return(decimal.RequireFromString("0.75"), nil).Once()
+
+	portfolio, err := uc.GetPortfolio(context.Background(), 100, "USD")
+
+	assert.NoError(t, err)
+	assert.Equal(t, model.Currency("USD"), portfolio.Currency)
+	// USD 62.50 + USD 150.00
+	assert.Equal(t, "21250", portfolio.TotalInvested.String())
+	assert.Equal(t, "6250", portfolio.CommittedCapital.String())
+	assert.Equal(t, "15000", portfolio.DeployedCapital.String())
+	assert.Equal(t, "750", portfolio.RealisedPayouts.String())
+	rates.AssertExpectations(t)
+}
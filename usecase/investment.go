@@ -3,21 +3,54 @@ package usecase
 import (
 	"context"
 	"database/sql"
-	"time"
+	"errors"
 
 	"github.com/aldipi/loan-service/model"
+	"github.com/aldipi/loan-service/tracing"
 )
 
-func (u *LoanUsecase) GetInvestmentsByInvestorID(ctx context.Context, investorID int64, limit int, offset int) ([]*model.Investment, error) {
-	investments, err := u.repo.GetInvestmentsByInvestorID(ctx, investorID, limit, offset)
+// GetInvestmentsByInvestorID returns a page of the investments of the investor
+// matching the filter.
+func (u *LoanUsecase) GetInvestmentsByInvestorID(ctx context.Context, investorID int64, filter model.InvestmentFilter) (page *model.Page[*model.Investment], err error) {
+	ctx, span := tracing.Start(ctx, "LoanUsecase.GetInvestmentsByInvestorID")
+	defer func() { tracing.End(span, err) }()
+
+	filter.InvestorID = investorID
+	if filter.Sort.Field == "" {
+		filter.Sort.Field = model.SortByID
+	}
+	limit := pageSize(filter.Limit)
+	filter.Limit = limit + 1
+
+	investments, err := u.repo.GetInvestments(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	total, err := u.repo.CountInvestments(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	page = &model.Page[*model.Investment]{Data: investments, Total: total}
+	if len(investments) > limit {
+		page.Data = investments[:limit]
+		last := page.Data[limit-1]
+		page.NextCursor = nextCursor(filter.Sort, investmentSortValue(last, filter.Sort.Field), last.ID)
+	}
+
+	err = u.signInvestmentURLs(ctx, page.Data...)
 	if err != nil {
 		return nil, err
 	}
 
-	return investments, nil
+	return page, nil
 }
 
-func (u *LoanUsecase) CheckAvailableInvestmentByLoanID(ctx context.Context, loanID int64) (int, error) {
+func (u *LoanUsecase) CheckAvailableInvestmentByLoanID(ctx context.Context, loanID int64) (available int64, err error) {
+	ctx, span := tracing.Start(ctx, "LoanUsecase.CheckAvailableInvestmentByLoanID")
+	defer func() { tracing.End(span, err) }()
+
 	loan, err := u.repo.GetLoanByID(ctx, loanID)
 	if err != nil {
 		return 0, model.ErrLoanNotFound
@@ -32,7 +65,7 @@ func (u *LoanUsecase) CheckAvailableInvestmentByLoanID(ctx context.Context, loan
 		return 0, err
 	}
 
-	var totalInvested int
+	var totalInvested int64
 	for _, investment := range investments {
 		totalInvested += investment.Amount
 	}
@@ -40,70 +73,211 @@ func (u *LoanUsecase) CheckAvailableInvestmentByLoanID(ctx context.Context, loan
 	return loan.PrincipalAmount - totalInvested, nil
 }
 
-// NOTE: CreateInvestment function is not thread safe because it doesn't use transaction.
-//
-//	It will need to implement proper transaction handling before it can be used in production.
-func (u *LoanUsecase) CreateInvestment(ctx context.Context, investorID int64, loanID int64, amount int) (investment *model.Investment, err error) {
+// CreateInvestment invests amount in an approved loan from the wallet of the
+// investor. The amount is in the currency of the loan. When the wallet of the
+// investor is in another currency, the investor pays the amount converted at
+// the current FX rate, which is kept on the investment.
+func (u *LoanUsecase) CreateInvestment(ctx context.Context, investorID int64, loanID int64, amount int64) (investment *model.Investment, err error) {
+	ctx, span := tracing.Start(ctx, "LoanUsecase.CreateInvestment")
+	defer func() { tracing.End(span, err) }()
+
 	investor, err := u.repo.GetInvestorByID(ctx, investorID)
 	if err != nil {
 		return nil, model.ErrInvestorNotFound
 	}
 
-	loan, err := u.repo.GetLoanByID(ctx, loanID)
-	if err != nil {
-		return nil, model.ErrLoanNotFound
-	}
+	// The loan is locked from checking the amount left to invest until the
+	// investment is saved, so concurrent investments are checked against
+	// each other and can not overfund the loan.
+	var loan *model.Loan
+	var signingRequests []signingRequest
+	var agreementKeys []string
+	err = u.repo.WithTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		loan, err = u.repo.LockLoan(ctx, loanID)
+		if err != nil {
+			return model.ErrLoanNotFound
+		}
 
-	if loan.State != model.LoanStateApproved {
-		return nil, model.ErrLoanNotApproved
-	}
+		if loan.State != model.LoanStateApproved {
+			return model.ErrLoanNotApproved
+		}
 
-	investments, err := u.repo.GetInvestmentsByLoanID(ctx, loanID)
+		if u.fundingEnded(loan, now()) {
+			return model.ErrLoanFundingEnded
+		}
+
+		investments, err := u.repo.GetInvestmentsByLoanID(ctx, loanID)
+		if err != nil {
+			return err
+		}
+
+		// Check available investment amount
+		var totalInvested int64
+		for _, investment := range investments {
+			totalInvested += investment.Amount
+		}
+
+		if amount > (loan.PrincipalAmount - totalInvested) {
+			return model.ErrInvestmentInvalidAmount
+		}
+
+		// The balance is checked again under lock when the funds are
+		// reserved, this only avoids generating agreements for investments
+		// that can not be paid.
+		wallet, err := u.repo.GetWalletByInvestorID(ctx, investor.ID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return model.ErrInsufficientFunds
+		}
+		if err != nil {
+			return err
+		}
+
+		rate, err := u.fxRate(ctx, loan.Currency, wallet.Currency)
+		if err != nil {
+			return err
+		}
+		settlementAmount := loan.Currency.Convert(amount, rate, wallet.Currency)
+
+		if wallet.Available < settlementAmount {
+			return model.ErrInsufficientFunds
+		}
+
+		at := now()
+		agreement, err := u.storeAgreement(ctx, investorAgreementKey(loan.ID, investor.ID), &model.Agreement{
+			Kind:      model.AgreementInvestor,
+			LoanID:    loan.ID,
+			PartyName: investor.Name,
+			Amount:    amount,
+			Currency:  loan.Currency,
+			Rate:      loan.Rate,
+			ROI:       loan.ROI,
+			Date:      at,
+		})
+		if err != nil {
+			return err
+		}
+		agreementKeys = append(agreementKeys, agreement.Key)
+
+		investment = &model.Investment{
+			Amount:             amount,
+			Currency:           loan.Currency,
+			SettlementAmount:   settlementAmount,
+			SettlementCurrency: wallet.Currency,
+			FXRate:             rate,
+			InvestorID:         investor.ID,
+			LoanID:             loan.ID,
+			AgreementLetter:    agreement.Key,
+			AgreementChecksum:  agreement.Checksum,
+		}
+
+		// The borrower agreement is generated with the investment that fully
+		// funds the loan, so it is ready for signing before disbursement.
+		var borrowerAgreement model.Document
+		if amount == loan.PrincipalAmount-totalInvested && u.documents != nil {
+			borrower, err := u.repo.GetUserByID(ctx, loan.BorrowerID)
+			if err != nil {
+				return model.ErrUserNotFound
+			}
+
+			borrowerAgreement, err = u.storeAgreement(ctx, borrowerAgreementKey(loan.ID), &model.Agreement{
+				Kind:      model.AgreementBorrower,
+				LoanID:    loan.ID,
+				PartyName: borrower.Name,
+				Amount:    loan.PrincipalAmount,
+				Currency:  loan.Currency,
+				Rate:      loan.Rate,
+				ROI:       loan.ROI,
+				Date:      at,
+			})
+			if err != nil {
+				return err
+			}
+			agreementKeys = append(agreementKeys, borrowerAgreement.Key)
+		}
+
+		aggregate := LoanFromProjection(loan, totalInvested)
+		investmentID, err := u.repo.CreateInvestment(ctx, investment)
+		if err != nil {
+			return err
+		}
+		investment.ID = investmentID
+
+		err = u.postWalletEntry(ctx, &model.WalletEntry{
+			InvestorID:   investor.ID,
+			Type:         model.WalletReservation,
+			Amount:       investment.SettlementAmount,
+			Currency:     investment.SettlementCurrency,
+			LoanID:       sql.NullInt64{Int64: loan.ID, Valid: true},
+			InvestmentID: sql.NullInt64{Int64: investment.ID, Valid: true},
+		})
+		if err != nil {
+			return err
+		}
+
+		err = u.postJournal(ctx, investmentJournal(investment))
+		if err != nil {
+			return err
+		}
+
+		err = aggregate.Invest(investment, borrowerAgreement, at)
+		if err != nil {
+			return err
+		}
+
+		if loan.State == model.LoanStateInvested {
+			err = u.repo.UpdateLoan(ctx, loan)
+			if err != nil {
+				return err
+			}
+
+			funded := append(investments, investment)
+			err = u.releaseReservations(ctx, funded)
+			if err != nil {
+				return err
+			}
+
+			for _, journal := range fundingJournals(loan, funded) {
+				err = u.postJournal(ctx, journal)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		signingRequests, err = u.attachAgreements(ctx, loan, investment, agreement, borrowerAgreement)
+		if err != nil {
+			return err
+		}
+
+		return u.saveLoanEvents(ctx, aggregate)
+	})
 	if err != nil {
+		u.deleteAgreements(ctx, agreementKeys)
 		return nil, err
 	}
 
-	// Check available investment amount
-	var totalInvested int
-	for _, investment := range investments {
-		totalInvested += investment.Amount
+	u.logger.InfoContext(ctx, "investment created", "investment_id", investment.ID, "loan_id", loan.ID, "investor_id", investor.ID,
+		"amount", investment.Amount, "currency", investment.Currency, "settlement_amount", investment.SettlementAmount, "settlement_currency", investment.SettlementCurrency)
+	if loan.State == model.LoanStateInvested {
+		u.logger.InfoContext(ctx, "loan fully funded", "loan_id", loan.ID)
 	}
-
-	if amount > (loan.PrincipalAmount - totalInvested) {
-		return nil, model.ErrInvestmentInvalidAmount
+	if u.metrics != nil {
+		u.metrics.InvestmentCreated(investment)
+		if loan.State == model.LoanStateInvested {
+			u.metrics.LoanFunded(loan)
+		}
 	}
 
-	investment = &model.Investment{
-		Amount:          amount,
-		InvestorID:      investor.ID,
-		LoanID:          loan.ID,
-		AgreementLetter: generateAgreementLetter(),
+	u.notifySigners(ctx, *loan, signingRequests)
+	if loan.State == model.LoanStateInvested {
+		u.notifyLoan(ctx, *loan, model.NotificationBorrowerLoanInvested, model.NotificationInvestorLoanInvested)
 	}
 
-	investmentID, err := u.repo.CreateInvestment(ctx, investment)
+	err = u.signInvestmentURLs(ctx, investment)
 	if err != nil {
 		return nil, err
 	}
-	investment.ID = investmentID
-
-	// Postprocess loan investment
-	// NOTE: Again this is not thread safe, if error occurs after this line, the loan will be in invalid state.
-	//       It will need to implement proper transaction and retry mechanism.
-	totalInvested += amount
-	if totalInvested == loan.PrincipalAmount {
-		loan.State = model.LoanStateInvested
-		loan.InvestedAt = sql.NullTime{Time: time.Now(), Valid: true}
-		loan.LastUpdatedAt = time.Now()
-
-		err = u.repo.UpdateLoan(ctx, loan)
-		if err != nil {
-			return nil, err
-		}
-	}
 
 	return investment, nil
 }
-
-func generateAgreementLetter() string {
-	return "http://file.io/investor/agreement.pdf"
-}
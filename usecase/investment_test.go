@@ -2,8 +2,10 @@ package usecase
 
 import (
 	"context"
+	"database/sql"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/aldipi/loan-service/model"
 	"github.com/stretchr/testify/assert"
@@ -14,7 +16,9 @@ func TestGetInvestmentsByInvestorID(t *testing.T) {
 	repo := new(MockRepository)
 	uc := NewLoanUsecase(repo)
 
-	repo.On("GetInvestmentsByInvestorID", mock.Anything, int64(100), 10, 0).Return([]*model.Investment{
+	filter := model.InvestmentFilter{InvestorID: 100, Sort: model.Sort{Field: model.SortByID}, Limit: 11}
+	repo.On("CountInvestments", mock.Anything, filter).Return(2, nil)
+	repo.On("GetInvestments", mock.Anything, filter).Return([]*model.Investment{
 		{
 			ID:         1,
 			InvestorID: 100,
@@ -29,9 +33,12 @@ func TestGetInvestmentsByInvestorID(t *testing.T) {
 		},
 	}, nil)
 
-	investments, err := uc.GetInvestmentsByInvestorID(context.Background(), 100, 10, 0)
+	page, err := uc.GetInvestmentsByInvestorID(context.Background(), 100, model.InvestmentFilter{Limit: 10})
 
 	assert.NoError(t, err)
+	assert.Equal(t, 2, page.Total)
+	assert.Empty(t, page.NextCursor)
+	investments := page.Data
 	assert.Len(t, investments, 2)
 	assert.True(t, reflect.DeepEqual(investments[0], &model.Investment{
 		ID:         1,
@@ -51,12 +58,37 @@ func TestGetInvestmentsByInvestorIDReturnEmpty(t *testing.T) {
 	repo := new(MockRepository)
 	uc := NewLoanUsecase(repo)
 
-	repo.On("GetInvestmentsByInvestorID", mock.Anything, int64(100), 10, 0).Return([]*model.Investment{}, nil)
+	repo.On("GetInvestments", mock.Anything, mock.Anything).Return([]*model.Investment{}, nil)
+	repo.On("CountInvestments", mock.Anything, mock.Anything).Return(0, nil)
 
-	investments, err := uc.GetInvestmentsByInvestorID(context.Background(), 100, 10, 0)
+	page, err := uc.GetInvestmentsByInvestorID(context.Background(), 100, model.InvestmentFilter{Limit: 10})
 
 	assert.NoError(t, err)
-	assert.Len(t, investments, 0)
+	assert.Len(t, page.Data, 0)
+}
+
+func TestGetInvestmentsByInvestorIDReturnsNextCursor(t *testing.T) {
+	repo := new(MockRepository)
+	uc := NewLoanUsecase(repo)
+
+	sort := model.Sort{Field: model.SortByAmount}
+	filter := model.InvestmentFilter{InvestorID: 100, LoanID: 1, Sort: sort, Limit: 2}
+	repo.On("GetInvestments", mock.Anything, filter).Return([]*model.Investment{
+		{ID: 3, InvestorID: 100, LoanID: 1, Amount: 1000},
+		{ID: 2, InvestorID: 100, LoanID: 1, Amount: 2000},
+	}, nil)
+	repo.On("CountInvestments", mock.Anything, filter).Return(4, nil)
+
+	// The investor of the filter is always the caller.
+	page, err := uc.GetInvestmentsByInvestorID(context.Background(), 100, model.InvestmentFilter{InvestorID: 101, LoanID: 1, Sort: sort, Limit: 1})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 4, page.Total)
+	assert.Len(t, page.Data, 1)
+
+	next, err := model.DecodeCursor(page.NextCursor)
+	assert.NoError(t, err)
+	assert.Equal(t, &model.Cursor{Sort: "amount", Value: "1000", ID: 3}, next)
 }
 
 func TestCheckAvailableInvestmentByLoanID(t *testing.T) {
@@ -90,7 +122,7 @@ func TestCheckAvailableInvestmentByLoanID(t *testing.T) {
 	availableAmount, err := uc.CheckAvailableInvestmentByLoanID(context.Background(), 1)
 
 	// Expected calculation
-	totalInvested := 0
+	var totalInvested int64
 	for _, investment := range investments {
 		totalInvested += investment.Amount
 	}
@@ -140,6 +172,7 @@ func TestCreateInvestment(t *testing.T) {
 		ID:              1,
 		PrincipalAmount: 1000000,
 		State:           model.LoanStateApproved,
+		ApprovedAt:      sql.NullTime{Time: time.Now().Add(-time.Hour), Valid: true},
 	}
 
 	investments := []*model.Investment{
@@ -157,11 +190,15 @@ func TestCreateInvestment(t *testing.T) {
 		},
 	}
 
+	expectFundedWallets(repo)
+	expectJournalEntries(repo)
 	repo.On("GetInvestorByID", mock.Anything, int64(100)).Return(investor, nil)
-	repo.On("GetLoanByID", mock.Anything, int64(1)).Return(loan, nil)
+	repo.On("LockLoan", mock.Anything, int64(1)).Return(loan, nil)
 	repo.On("GetInvestmentsByLoanID", mock.Anything, int64(1)).Return(investments, nil)
 	repo.On("CreateInvestment", mock.Anything, mock.Anything).Return(int64(3), nil)
 	repo.On("UpdateLoan", mock.Anything, loan).Return(nil)
+	repo.On("CreateLoanEvent", mock.Anything, mock.Anything).Return(int64(1), nil)
+	repo.On("CreateOutboxMessage", mock.Anything, mock.Anything).Return(int64(1), nil)
 
 	investment, err := uc.CreateInvestment(context.Background(), 100, 1, 500000)
 
@@ -169,8 +206,14 @@ func TestCreateInvestment(t *testing.T) {
 	assert.Equal(t, int64(3), investment.ID)
 	assert.Equal(t, int64(100), investment.InvestorID)
 	assert.Equal(t, int64(1), investment.LoanID)
-	assert.Equal(t, 500000, investment.Amount)
+	assert.Equal(t, int64(500000), investment.Amount)
 	assert.Equal(t, model.LoanStateInvested, loan.State)
+	repo.AssertCalled(t, "CreateLoanEvent", mock.Anything, mock.MatchedBy(func(e *model.LoanEvent) bool {
+		return e.Type == model.LoanEventInvestment
+	}))
+	repo.AssertCalled(t, "CreateLoanEvent", mock.Anything, mock.MatchedBy(func(e *model.LoanEvent) bool {
+		return e.Type == model.LoanEventFullyFunded
+	}))
 }
 
 func TestCreateInvestmentInvestorNotFound(t *testing.T) {
@@ -193,7 +236,7 @@ func TestCreateInvestmentLoanNotFound(t *testing.T) {
 	}
 
 	repo.On("GetInvestorByID", mock.Anything, int64(100)).Return(investor, nil)
-	repo.On("GetLoanByID", mock.Anything, int64(1)).Return(nil, model.ErrLoanNotFound)
+	repo.On("LockLoan", mock.Anything, int64(1)).Return(nil, model.ErrLoanNotFound)
 
 	_, err := uc.CreateInvestment(context.Background(), 100, 1, 500000)
 
@@ -215,13 +258,33 @@ func TestCreateInvestmentLoanNotApproved(t *testing.T) {
 	}
 
 	repo.On("GetInvestorByID", mock.Anything, int64(100)).Return(investor, nil)
-	repo.On("GetLoanByID", mock.Anything, int64(1)).Return(loan, nil)
+	repo.On("LockLoan", mock.Anything, int64(1)).Return(loan, nil)
 
 	_, err := uc.CreateInvestment(context.Background(), 100, 1, 500000)
 
 	assert.ErrorIs(t, err, model.ErrLoanNotApproved)
 }
 
+func TestCreateInvestmentFundingEnded(t *testing.T) {
+	repo := new(MockRepository)
+	uc := NewLoanUsecase(repo, WithFundingPeriod(24*time.Hour))
+
+	loan := &model.Loan{
+		ID:              1,
+		PrincipalAmount: 1000000,
+		State:           model.LoanStateApproved,
+		ApprovedAt:      sql.NullTime{Time: time.Now().Add(-25 * time.Hour), Valid: true},
+	}
+
+	repo.On("GetInvestorByID", mock.Anything, int64(100)).Return(&model.Investor{ID: 100}, nil)
+	repo.On("LockLoan", mock.Anything, int64(1)).Return(loan, nil)
+
+	_, err := uc.CreateInvestment(context.Background(), 100, 1, 500000)
+
+	assert.ErrorIs(t, err, model.ErrLoanFundingEnded)
+	repo.AssertNotCalled(t, "CreateInvestment", mock.Anything, mock.Anything)
+}
+
 func TestCreateInvestmentInvalidAmount(t *testing.T) {
 	repo := new(MockRepository)
 	uc := NewLoanUsecase(repo)
@@ -252,7 +315,7 @@ func TestCreateInvestmentInvalidAmount(t *testing.T) {
 	}
 
 	repo.On("GetInvestorByID", mock.Anything, int64(100)).Return(investor, nil)
-	repo.On("GetLoanByID", mock.Anything, int64(1)).Return(loan, nil)
+	repo.On("LockLoan", mock.Anything, int64(1)).Return(loan, nil)
 	repo.On("GetInvestmentsByLoanID", mock.Anything, int64(1)).Return(investments, nil)
 
 	_, err := uc.CreateInvestment(context.Background(), 100, 1, 700000)
@@ -289,11 +352,15 @@ func TestCreateInvestmentDoNotUpdateLoanState(t *testing.T) {
 		},
 	}
 
+	expectFundedWallets(repo)
+	expectJournalEntries(repo)
 	repo.On("GetInvestorByID", mock.Anything, int64(100)).Return(investor, nil)
-	repo.On("GetLoanByID", mock.Anything, int64(1)).Return(loan, nil)
+	repo.On("LockLoan", mock.Anything, int64(1)).Return(loan, nil)
 	repo.On("GetInvestmentsByLoanID", mock.Anything, int64(1)).Return(investments, nil)
 	repo.On("CreateInvestment", mock.Anything, mock.Anything).Return(int64(3), nil)
 	repo.On("UpdateLoan", mock.Anything, loan).Return(nil)
+	repo.On("CreateLoanEvent", mock.Anything, mock.Anything).Return(int64(1), nil)
+	repo.On("CreateOutboxMessage", mock.Anything, mock.Anything).Return(int64(1), nil)
 
 	investment, err := uc.CreateInvestment(context.Background(), 100, 1, 100000)
 
@@ -302,6 +369,8 @@ func TestCreateInvestmentDoNotUpdateLoanState(t *testing.T) {
 	assert.Equal(t, int64(3), investment.ID)
 	assert.Equal(t, int64(100), investment.InvestorID)
 	assert.Equal(t, int64(1), investment.LoanID)
-	assert.Equal(t, 100000, investment.Amount)
+	assert.Equal(t, int64(100000), investment.Amount)
 	assert.Equal(t, model.LoanStateApproved, loan.State)
+	repo.AssertNotCalled(t, "UpdateLoan", mock.Anything, loan)
+	repo.AssertNumberOfCalls(t, "CreateLoanEvent", 1)
 }
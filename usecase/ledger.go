@@ -0,0 +1,231 @@
+package usecase
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aldipi/loan-service/model"
+	"github.com/aldipi/loan-service/tracing"
+)
+
+var errJournalUnbalanced = errors.New("journal entry is not balanced")
+
+// GetTrialBalance returns the balances of all accounts of the ledger, checking
+// that the debits equal the credits in every currency.
+func (u *LoanUsecase) GetTrialBalance(ctx context.Context) (trialBalance *model.TrialBalance, err error) {
+	ctx, span := tracing.Start(ctx, "LoanUsecase.GetTrialBalance")
+	defer func() { tracing.End(span, err) }()
+
+	accounts, err := u.repo.GetAccountBalances(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	unbalanced, err := u.repo.GetUnbalancedJournalEntryIDs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	trialBalance = &model.TrialBalance{
+		Accounts:          accounts,
+		Totals:            []*model.CurrencyTotal{},
+		Balanced:          len(unbalanced) == 0,
+		UnbalancedEntries: unbalanced,
+	}
+
+	totals := map[model.Currency]*model.CurrencyTotal{}
+	for _, account := range accounts {
+		total, ok := totals[account.Currency]
+		if !ok {
+			total = &model.CurrencyTotal{Currency: account.Currency}
+			totals[account.Currency] = total
+			trialBalance.Totals = append(trialBalance.Totals, total)
+		}
+		total.Debit += account.Debit
+		total.Credit += account.Credit
+	}
+
+	sort.Slice(trialBalance.Totals, func(i, j int) bool {
+		return trialBalance.Totals[i].Currency < trialBalance.Totals[j].Currency
+	})
+	for _, total := range trialBalance.Totals {
+		if total.Debit != total.Credit {
+			trialBalance.Balanced = false
+		}
+	}
+
+	return trialBalance, nil
+}
+
+// CheckLedger returns an error when the debits of the ledger do not equal its
+// credits.
+func (u *LoanUsecase) CheckLedger(ctx context.Context) (trialBalance *model.TrialBalance, err error) {
+	ctx, span := tracing.Start(ctx, "LoanUsecase.CheckLedger")
+	defer func() { tracing.End(span, err) }()
+
+	trialBalance, err = u.GetTrialBalance(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if !trialBalance.Balanced {
+		totals := []string{}
+		for _, total := range trialBalance.Totals {
+			totals = append(totals, fmt.Sprintf("%s debits %d, credits %d", total.Currency, total.Debit, total.Credit))
+		}
+		return trialBalance, fmt.Errorf("ledger is not balanced: %s, unbalanced entries %v",
+			strings.Join(totals, "; "), trialBalance.UnbalancedEntries)
+	}
+
+	return trialBalance, nil
+}
+
+// postJournal stores the entry, refusing entries whose debits do not equal
+// their credits.
+func (u *LoanUsecase) postJournal(ctx context.Context, entry *model.JournalEntry) error {
+	if !entry.Balanced() {
+		return errJournalUnbalanced
+	}
+
+	entry.CreatedAt = now()
+	id, err := u.repo.CreateJournalEntry(ctx, entry)
+	if err != nil {
+		return err
+	}
+	entry.ID = id
+
+	return nil
+}
+
+// walletJournal records a deposit or withdrawal of the investor, moving money
+// between the bank and what the platform owes the investor.
+func walletJournal(entry *model.WalletEntry) *model.JournalEntry {
+	if entry.Type == model.WalletWithdrawal {
+		journal := &model.JournalEntry{Kind: model.JournalWithdrawal, Currency: entry.Currency}
+		journal.Debit(model.AccountInvestorCash, entry.InvestorID, entry.Amount)
+		journal.Credit(model.AccountSettlement, 0, entry.Amount)
+		return journal
+	}
+
+	journal := &model.JournalEntry{Kind: model.JournalDeposit, Currency: entry.Currency}
+	journal.Debit(model.AccountSettlement, 0, entry.Amount)
+	journal.Credit(model.AccountInvestorCash, entry.InvestorID, entry.Amount)
+	return journal
+}
+
+// investmentJournal reserves the cash of the investor for the investment, in
+// the currency the investor pays it in.
+func investmentJournal(investment *model.Investment) *model.JournalEntry {
+	journal := &model.JournalEntry{
+		Kind:         model.JournalInvestment,
+		Currency:     investment.SettlementCurrency,
+		LoanID:       sql.NullInt64{Int64: investment.LoanID, Valid: true},
+		InvestmentID: sql.NullInt64{Int64: investment.ID, Valid: true},
+	}
+	journal.Debit(model.AccountInvestorCash, investment.InvestorID, investment.SettlementAmount)
+	journal.Credit(model.AccountInvestorReserved, investment.InvestorID, investment.SettlementAmount)
+	return journal
+}
+
+// fundingJournals deploy the reserved funds of the investments of a fully
+// funded loan. The borrower now owes the principal, and is owed it until the
+// loan is disbursed. Investments paid in another currency than the loan are
+// deployed in an entry of their own currency, since an entry is in a single
+// currency.
+func fundingJournals(loan *model.Loan, investments []*model.Investment) []*model.JournalEntry {
+	journal := &model.JournalEntry{
+		Kind:     model.JournalFunding,
+		Currency: loan.Currency,
+		LoanID:   sql.NullInt64{Int64: loan.ID, Valid: true},
+	}
+	journals := []*model.JournalEntry{journal}
+	byCurrency := map[model.Currency]*model.JournalEntry{loan.Currency: journal}
+	for _, investment := range investments {
+		journal, ok := byCurrency[investment.SettlementCurrency]
+		if !ok {
+			journal = &model.JournalEntry{
+				Kind:     model.JournalFunding,
+				Currency: investment.SettlementCurrency,
+				LoanID:   sql.NullInt64{Int64: loan.ID, Valid: true},
+			}
+			journals = append(journals, journal)
+			byCurrency[investment.SettlementCurrency] = journal
+		}
+		journal.Debit(model.AccountInvestorReserved, investment.InvestorID, investment.SettlementAmount)
+		journal.Credit(model.AccountInvestorDeployed, investment.InvestorID, investment.SettlementAmount)
+	}
+	journal.Debit(model.AccountLoanReceivable, loan.ID, loan.PrincipalAmount)
+	journal.Credit(model.AccountBorrowerPayable, loan.ID, loan.PrincipalAmount)
+	return journals
+}
+
+// disbursementJournal pays the principal out of the bank to the borrower.
+func disbursementJournal(loan *model.Loan) *model.JournalEntry {
+	journal := &model.JournalEntry{
+		Kind:     model.JournalDisbursement,
+		Currency: loan.Currency,
+		LoanID:   sql.NullInt64{Int64: loan.ID, Valid: true},
+	}
+	journal.Debit(model.AccountBorrowerPayable, loan.ID, loan.PrincipalAmount)
+	journal.Credit(model.AccountSettlement, 0, loan.PrincipalAmount)
+	return journal
+}
+
+// repaymentJournal takes the repayment into the bank, settling the principal
+// owed by the borrower. The interest is owed to the investors, except for the
+// fee the platform keeps as revenue.
+func repaymentJournal(repayment *model.Repayment) *model.JournalEntry {
+	journal := &model.JournalEntry{
+		Kind:     model.JournalRepayment,
+		Currency: repayment.Currency,
+		LoanID:   sql.NullInt64{Int64: repayment.LoanID, Valid: true},
+	}
+	journal.Debit(model.AccountSettlement, 0, repayment.Amount)
+	journal.Credit(model.AccountLoanReceivable, repayment.LoanID, repayment.Principal)
+	journal.Credit(model.AccountInterestPayable, repayment.LoanID, repayment.Interest-repayment.Fee)
+	journal.Credit(model.AccountPlatformRevenue, 0, repayment.Fee)
+	return journal
+}
+
+// payoutJournals return the share of a repayment to the cash of an investor:
+// the capital deployed in the loan and the interest owed on it. Investments
+// paid in another currency than the loan are paid out in their own currency,
+// so the interest leaves the bank in the loan currency and enters it in the
+// settlement currency, like their funding. Entries without lines are left
+// out.
+func payoutJournals(loan *model.Loan, payout *investorPayout) []*model.JournalEntry {
+	investment := payout.investment
+	newJournal := func(currency model.Currency) *model.JournalEntry {
+		return &model.JournalEntry{
+			Kind:         model.JournalPayout,
+			Currency:     currency,
+			LoanID:       sql.NullInt64{Int64: loan.ID, Valid: true},
+			InvestmentID: sql.NullInt64{Int64: investment.ID, Valid: true},
+		}
+	}
+
+	journals := []*model.JournalEntry{}
+	journal := newJournal(investment.SettlementCurrency)
+	journal.Debit(model.AccountInvestorDeployed, investment.InvestorID, payout.settlementPrincipal)
+	if investment.SettlementCurrency == loan.Currency {
+		journal.Debit(model.AccountInterestPayable, loan.ID, payout.interest)
+	} else {
+		exchange := newJournal(loan.Currency)
+		exchange.Debit(model.AccountInterestPayable, loan.ID, payout.interest)
+		exchange.Credit(model.AccountSettlement, 0, payout.interest)
+		if len(exchange.Lines) > 0 {
+			journals = append(journals, exchange)
+		}
+		journal.Debit(model.AccountSettlement, 0, payout.settlementInterest)
+	}
+	journal.Credit(model.AccountInvestorCash, investment.InvestorID, payout.settlementPrincipal+payout.settlementInterest)
+	if len(journal.Lines) > 0 {
+		journals = append(journals, journal)
+	}
+
+	return journals
+}
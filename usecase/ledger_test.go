@@ -0,0 +1,294 @@
+package usecase
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/aldipi/loan-service/model"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func expectJournalEntries(repo *MockRepository) {
+	repo.On("CreateJournalEntry", mock.Anything, mock.Anything).Return(int64(1), nil)
+}
+
+func journalKind(kind model.JournalKind) any {
+	return mock.MatchedBy(func(e *model.JournalEntry) bool {
+		return e.Kind == kind
+	})
+}
+
+func TestGetTrialBalance(t *testing.T) {
+	repo := new(MockRepository)
+	uc := NewLoanUsecase(repo)
+
+	repo.On("GetAccountBalances", mock.Anything).Return([]*model.AccountBalance{
+		{Account: model.AccountInvestorCash, OwnerID: 100, Currency: "IDR", Debit: 200000, Credit: 500000, Balance: -300000},
+		{Account: model.AccountSettlement, Currency: "IDR", Debit: 500000, Credit: 200000, Balance: 300000},
+		{Account: model.AccountInvestorCash, OwnerID: 101, Currency: "SGD", Credit: 1000, Balance: -1000},
+		{Account: model.AccountSettlement, Currency: "SGD", Debit: 1000, Balance: 1000},
+	}, nil)
+	repo.On("GetUnbalancedJournalEntryIDs", mock.Anything).Return([]int64{}, nil)
+
+	trialBalance, err := uc.GetTrialBalance(context.Background())
+
+	assert.NoError(t, err)
+	assert.Len(t, trialBalance.Accounts, 4)
+	assert.Equal(t, []*model.CurrencyTotal{
+		{Currency: "IDR", Debit: 700000, Credit: 700000},
+		{Currency: "SGD", Debit: 1000, Credit: 1000},
+	}, trialBalance.Totals)
+	assert.True(t, trialBalance.Balanced)
+}
+
+func TestCheckLedgerUnbalanced(t *testing.T) {
+	repo := new(MockRepository)
+	uc := NewLoanUsecase(repo)
+
+	repo.On("GetAccountBalances", mock.Anything).Return([]*model.AccountBalance{
+		{Account: model.AccountInvestorCash, OwnerID: 100, Currency: "IDR", Credit: 500000, Balance: -500000},
+		{Account: model.AccountSettlement, Currency: "IDR", Debit: 400000, Balance: 400000},
+	}, nil)
+	repo.On("GetUnbalancedJournalEntryIDs", mock.Anything).Return([]int64{3}, nil)
+
+	trialBalance, err := uc.CheckLedger(context.Background())
+
+	assert.EqualError(t, err, "ledger is not balanced: IDR debits 400000, credits 500000, unbalanced entries [3]")
+	assert.False(t, trialBalance.Balanced)
+}
+
+func TestCheckLedgerUnbalancedEntries(t *testing.T) {
+	repo := new(MockRepository)
+	uc := NewLoanUsecase(repo)
+
+	// Totals can match while single entries do not.
+	repo.On("GetAccountBalances", mock.Anything).Return([]*model.AccountBalance{}, nil)
+	repo.On("GetUnbalancedJournalEntryIDs", mock.Anything).Return([]int64{3, 4}, nil)
+
+	_, err := uc.CheckLedger(context.Background())
+
+	assert.Error(t, err)
+}
+
+func TestCheckLedgerError(t *testing.T) {
+	repo := new(MockRepository)
+	uc := NewLoanUsecase(repo)
+
+	repo.On("GetAccountBalances", mock.Anything).Return(nil, errors.New("connection refused"))
+
+	_, err := uc.CheckLedger(context.Background())
+
+	assert.EqualError(t, err, "connection refused")
+}
+
+func TestPostJournalRejectsUnbalancedEntries(t *testing.T) {
+	repo := new(MockRepository)
+	uc := NewLoanUsecase(repo)
+
+	entry := &model.JournalEntry{Kind: model.JournalDeposit}
+	entry.Debit(model.AccountSettlement, 0, 500000)
+	entry.Credit(model.AccountInvestorCash, 100, 400000)
+
+	err := uc.postJournal(context.Background(), entry)
+
+	assert.ErrorIs(t, err, errJournalUnbalanced)
+	assert.ErrorIs(t, uc.postJournal(context.Background(), &model.JournalEntry{}), errJournalUnbalanced)
+	repo.AssertNotCalled(t, "CreateJournalEntry", mock.Anything, mock.Anything)
+}
+
+// TestJournalsBalanceOverLoanLifecycle follows the money of a loan funded by
+// two investors from their deposits to its repayment in two parts, checking
+// every account is settled at the end but the cash owed to the investors, the
+// fee of the platform and the bank.
+func TestJournalsBalanceOverLoanLifecycle(t *testing.T) {
+	loan := &model.Loan{ID: 1, PrincipalAmount: 1000000, Currency: "IDR", Rate: decimal.NewFromInt(12), ROI: decimal.NewFromInt(10)}
+	investments := []*model.Investment{
+		{ID: 1, InvestorID: 100, LoanID: 1, Amount: 600000, Currency: "IDR", SettlementAmount: 600000, SettlementCurrency: "IDR"},
+		{ID: 2, InvestorID: 101, LoanID: 1, Amount: 400000, Currency: "IDR", SettlementAmount: 400000, SettlementCurrency: "IDR"},
+	}
+
+	journals := []*model.JournalEntry{
+		walletJournal(&model.WalletEntry{InvestorID: 100, Type: model.WalletDeposit, Amount: 600000, Currency: "IDR"}),
+		walletJournal(&model.WalletEntry{InvestorID: 101, Type: model.WalletDeposit, Amount: 500000, Currency: "IDR"}),
+		investmentJournal(investments[0]),
+		investmentJournal(investments[1]),
+	}
+	journals = append(journals, fundingJournals(loan, investments)...)
+	journals = append(journals,
+		disbursementJournal(loan),
+		walletJournal(&model.WalletEntry{InvestorID: 101, Type: model.WalletWithdrawal, Amount: 100000, Currency: "IDR"}),
+	)
+
+	split := newRepaymentSplit(loan)
+	var paid int64
+	for _, amount := range []int64{373333, 746667} {
+		journals = append(journals, repaymentJournal(split.repayment(paid, amount)))
+		for _, payout := range split.payouts(investments, paid, amount) {
+			journals = append(journals, payoutJournals(loan, payout)...)
+		}
+		paid += amount
+	}
+
+	assert.Equal(t, map[model.AccountType]map[int64]int64{
+		model.AccountSettlement:       {0: 1120000},
+		model.AccountInvestorCash:     {100: -660000, 101: -440000},
+		model.AccountInvestorReserved: {100: 0, 101: 0},
+		model.AccountInvestorDeployed: {100: 0, 101: 0},
+		model.AccountLoanReceivable:   {1: 0},
+		model.AccountBorrowerPayable:  {1: 0},
+		model.AccountInterestPayable:  {1: 0},
+		model.AccountPlatformRevenue:  {0: -20000},
+	}, trialBalance(t, journals)["IDR"])
+}
+
+// TestPayoutJournalsBalanceAcrossCurrencies repays a loan funded partly in
+// another currency, checking the investor paid in SGD gets back their
+// settlement amount and interest in SGD, while the interest leaves the bank in
+// IDR.
+func TestPayoutJournalsBalanceAcrossCurrencies(t *testing.T) {
+	loan := &model.Loan{ID: 1, PrincipalAmount: 1000000, Currency: "IDR", Rate: decimal.NewFromInt(12), ROI: decimal.NewFromInt(10)}
+	investments := []*model.Investment{
+		{ID: 1, InvestorID: 100, LoanID: 1, Amount: 600000, Currency: "IDR", SettlementAmount: 600000, SettlementCurrency: "IDR", FXRate: decimal.NewFromInt(1)},
+		{ID: 2, InvestorID: 101, LoanID: 1, Amount: 400000, Currency: "IDR", SettlementAmount: 3125, SettlementCurrency: "SGD", FXRate: decimal.RequireFromString("0.0078125")},
+	}
+
+	journals := append(fundingJournals(loan, investments), disbursementJournal(loan))
+	split := newRepaymentSplit(loan)
+	var paid int64
+	for _, amount := range []int64{500000, 620000} {
+		journals = append(journals, repaymentJournal(split.repayment(paid, amount)))
+		for _, payout := range split.payouts(investments, paid, amount) {
+			journals = append(journals, payoutJournals(loan, payout)...)
+		}
+		paid += amount
+	}
+
+	balances := trialBalance(t, journals)
+	assert.Equal(t, int64(0), balances["IDR"][model.AccountLoanReceivable][1])
+	assert.Equal(t, int64(0), balances["IDR"][model.AccountInterestPayable][1])
+	assert.Equal(t, int64(-20000), balances["IDR"][model.AccountPlatformRevenue][0])
+	assert.Equal(t, int64(-660000), balances["IDR"][model.AccountInvestorCash][100])
+	assert.Equal(t, int64(3125), balances["SGD"][model.AccountInvestorDeployed][101]+balances["SGD"][model.AccountInvestorReserved][101])
+	// 40000 of interest in IDR is 312.5 in SGD, rounded in each of the two
+	// payouts.
+	assert.Equal(t, int64(-3125-313), balances["SGD"][model.AccountInvestorCash][101])
+}
+
+// trialBalance checks every journal is balanced and returns the balances of
+// the accounts by currency and owner.
+func trialBalance(t *testing.T, journals []*model.JournalEntry) map[model.Currency]map[model.AccountType]map[int64]int64 {
+	balances := map[model.Currency]map[model.AccountType]map[int64]int64{}
+	totals := map[model.Currency]int64{}
+	for _, journal := range journals {
+		assert.True(t, journal.Balanced(), journal.Kind)
+		if balances[journal.Currency] == nil {
+			balances[journal.Currency] = map[model.AccountType]map[int64]int64{}
+		}
+		for _, line := range journal.Lines {
+			if balances[journal.Currency][line.Account] == nil {
+				balances[journal.Currency][line.Account] = map[int64]int64{}
+			}
+			balances[journal.Currency][line.Account][line.OwnerID] += line.Debit - line.Credit
+			totals[journal.Currency] += line.Debit - line.Credit
+		}
+	}
+
+	for currency, total := range totals {
+		assert.Zero(t, total, currency)
+	}
+	return balances
+}
+
+func TestFundingJournalsSplitByCurrency(t *testing.T) {
+	loan := &model.Loan{ID: 1, PrincipalAmount: 1000000, Currency: "IDR"}
+	investments := []*model.Investment{
+		{ID: 1, InvestorID: 100, Amount: 600000, Currency: "IDR", SettlementAmount: 600000, SettlementCurrency: "IDR"},
+		{ID: 2, InvestorID: 101, Amount: 400000, Currency: "IDR", SettlementAmount: 3125, SettlementCurrency: "SGD"},
+	}
+
+	journals := fundingJournals(loan, investments)
+
+	assert.Len(t, journals, 2)
+	assert.Equal(t, model.Currency("IDR"), journals[0].Currency)
+	assert.Len(t, journals[0].Lines, 4)
+	assert.Equal(t, model.Currency("SGD"), journals[1].Currency)
+	assert.Equal(t, []*model.JournalLine{
+		{Account: model.AccountInvestorReserved, OwnerID: 101, Debit: 3125},
+		{Account: model.AccountInvestorDeployed, OwnerID: 101, Credit: 3125},
+	}, journals[1].Lines)
+	for _, journal := range journals {
+		assert.True(t, journal.Balanced())
+	}
+}
+
+func TestDepositPostsJournal(t *testing.T) {
+	repo := new(MockRepository)
+	rail := new(mockPaymentRail)
+	uc := NewLoanUsecase(repo, WithPaymentRail(rail))
+
+	repo.On("GetInvestorByID", mock.Anything, int64(100)).Return(&model.Investor{ID: 100}, nil)
+	repo.On("LockWallet", mock.Anything, int64(100)).Return(&model.Wallet{InvestorID: 100, Currency: model.DefaultCurrency}, nil)
+	rail.On("Collect", mock.Anything, mock.Anything, int64(100), int64(500000), model.DefaultCurrency).Return("ref-1", nil)
+	repo.On("UpdateWallet", mock.Anything, mock.Anything).Return(nil)
+	expectTransfer(repo, 7)
+	repo.On("UpdateWalletEntry", mock.Anything, mock.Anything).Return(nil)
+	repo.On("CreateJournalEntry", mock.Anything, mock.Anything).Return(int64(3), nil)
+
+	_, err := uc.Deposit(context.Background(), 100, 500000)
+
+	assert.NoError(t, err)
+	repo.AssertCalled(t, "CreateJournalEntry", mock.Anything, mock.MatchedBy(func(e *model.JournalEntry) bool {
+		return e.Kind == model.JournalDeposit && assert.ObjectsAreEqual([]*model.JournalLine{
+			{Account: model.AccountSettlement, Debit: 500000},
+			{Account: model.AccountInvestorCash, OwnerID: 100, Credit: 500000},
+		}, e.Lines)
+	}))
+}
+
+func TestCreateInvestmentPostsFundingJournal(t *testing.T) {
+	repo := new(MockRepository)
+	uc := NewLoanUsecase(repo)
+
+	loan := &model.Loan{ID: 1, PrincipalAmount: 1000000, State: model.LoanStateApproved}
+
+	expectFundedWallets(repo)
+	expectJournalEntries(repo)
+	repo.On("GetInvestorByID", mock.Anything, int64(100)).Return(&model.Investor{ID: 100}, nil)
+	repo.On("LockLoan", mock.Anything, int64(1)).Return(loan, nil)
+	repo.On("GetInvestmentsByLoanID", mock.Anything, int64(1)).Return([]*model.Investment{{ID: 1, InvestorID: 101, LoanID: 1, Amount: 400000, SettlementAmount: 400000}}, nil)
+	repo.On("CreateInvestment", mock.Anything, mock.Anything).Return(int64(2), nil)
+	repo.On("UpdateLoan", mock.Anything, loan).Return(nil)
+	repo.On("CreateLoanEvent", mock.Anything, mock.Anything).Return(int64(1), nil)
+	repo.On("CreateOutboxMessage", mock.Anything, mock.Anything).Return(int64(1), nil)
+
+	_, err := uc.CreateInvestment(context.Background(), 100, 1, 600000)
+
+	assert.NoError(t, err)
+	repo.AssertCalled(t, "CreateJournalEntry", mock.Anything, journalKind(model.JournalInvestment))
+	repo.AssertCalled(t, "CreateJournalEntry", mock.Anything, mock.MatchedBy(func(e *model.JournalEntry) bool {
+		return e.Kind == model.JournalFunding && e.LoanID == sql.NullInt64{Int64: 1, Valid: true} && len(e.Lines) == 6
+	}))
+}
+
+func TestCreateInvestmentJournalFailureRollsBack(t *testing.T) {
+	repo := new(MockRepository)
+	uc := NewLoanUsecase(repo)
+
+	loan := &model.Loan{ID: 1, PrincipalAmount: 1000000, State: model.LoanStateApproved}
+
+	expectFundedWallets(repo)
+	repo.On("GetInvestorByID", mock.Anything, int64(100)).Return(&model.Investor{ID: 100}, nil)
+	repo.On("LockLoan", mock.Anything, int64(1)).Return(loan, nil)
+	repo.On("GetInvestmentsByLoanID", mock.Anything, int64(1)).Return([]*model.Investment{}, nil)
+	repo.On("CreateInvestment", mock.Anything, mock.Anything).Return(int64(2), nil)
+	repo.On("CreateJournalEntry", mock.Anything, mock.Anything).Return(int64(0), errors.New("connection lost"))
+
+	_, err := uc.CreateInvestment(context.Background(), 100, 1, 600000)
+
+	assert.EqualError(t, err, "connection lost")
+	repo.AssertNotCalled(t, "CreateLoanEvent", mock.Anything, mock.Anything)
+}
@@ -0,0 +1,58 @@
+package usecase
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/aldipi/loan-service/model"
+)
+
+// pageSize bounds the requested page size, defaulting when it is not set.
+func pageSize(limit int) int {
+	if limit <= 0 {
+		return model.DefaultPageSize
+	}
+	if limit > model.MaxPageSize {
+		return model.MaxPageSize
+	}
+
+	return limit
+}
+
+// nextCursor points after the last item of a page.
+func nextCursor(sort model.Sort, value string, id int64) string {
+	return model.Cursor{Sort: sort.String(), Value: value, ID: id}.Encode()
+}
+
+func loanSortValue(loan *model.Loan, field string) string {
+	switch field {
+	case model.SortByCreatedAt:
+		return loan.CreatedAt.UTC().Format(time.RFC3339Nano)
+	case model.SortByPrincipalAmount:
+		return strconv.FormatInt(loan.PrincipalAmount, 10)
+	default:
+		return ""
+	}
+}
+
+func investmentSortValue(investment *model.Investment, field string) string {
+	switch field {
+	case model.SortByCreatedAt:
+		return investment.CreatedAt.UTC().Format(time.RFC3339Nano)
+	case model.SortByAmount:
+		return strconv.FormatInt(investment.Amount, 10)
+	default:
+		return ""
+	}
+}
+
+func marketplaceSortValue(loan *model.MarketplaceLoan, field string) string {
+	switch field {
+	case model.SortByROI:
+		return loan.ROI.String()
+	case model.SortByFundedPercent:
+		return loan.FundedPercent.String()
+	default:
+		return ""
+	}
+}
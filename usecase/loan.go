@@ -2,23 +2,80 @@ package usecase
 
 import (
 	"context"
-	"database/sql"
-	"time"
 
 	"github.com/aldipi/loan-service/model"
+	"github.com/aldipi/loan-service/tracing"
 )
 
-func (u *LoanUsecase) GetLoans(ctx context.Context, limit int, offset int) ([]*model.Loan, error) {
-	loans, err := u.repo.GetLoans(ctx, limit, offset)
+// GetLoans returns a page of the loans matching the filter. One more loan than
+// the page size is fetched to tell whether another page follows.
+func (u *LoanUsecase) GetLoans(ctx context.Context, filter model.LoanFilter) (page *model.Page[*model.Loan], err error) {
+	ctx, span := tracing.Start(ctx, "LoanUsecase.GetLoans")
+	defer func() { tracing.End(span, err) }()
+
+	if filter.Sort.Field == "" {
+		filter.Sort.Field = model.SortByID
+	}
+	limit := pageSize(filter.Limit)
+	filter.Limit = limit + 1
+
+	loans, err := u.repo.GetLoans(ctx, filter)
 	if err != nil {
 		return nil, err
 	}
 
-	return loans, nil
+	total, err := u.repo.CountLoans(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	page = &model.Page[*model.Loan]{Data: loans, Total: total}
+	if len(loans) > limit {
+		page.Data = loans[:limit]
+		last := page.Data[limit-1]
+		page.NextCursor = nextCursor(filter.Sort, loanSortValue(last, filter.Sort.Field), last.ID)
+	}
+
+	err = u.signLoanURLs(ctx, page.Data...)
+	if err != nil {
+		return nil, err
+	}
+
+	return page, nil
+}
+
+// GetLoansForActor returns a page of the loans matching the filter that the
+// actor can see. Employees see every loan and borrowers only their own.
+// Investors find their loans through their investments instead.
+func (u *LoanUsecase) GetLoansForActor(ctx context.Context, actor model.Actor, filter model.LoanFilter) (page *model.Page[*model.Loan], err error) {
+	ctx, span := tracing.Start(ctx, "LoanUsecase.GetLoansForActor")
+	defer func() { tracing.End(span, err) }()
+
+	switch actor.Role {
+	case model.RoleEmployee:
+		_, err = u.repo.GetEmployeeByID(ctx, actor.ID)
+		if err != nil {
+			return nil, model.ErrLoanAccessDenied
+		}
+	case model.RoleBorrower:
+		filter.BorrowerID = actor.ID
+	default:
+		return nil, model.ErrLoanAccessDenied
+	}
+
+	return u.GetLoans(ctx, filter)
 }
 
-func (u *LoanUsecase) GetLoansByBorrowerID(ctx context.Context, borrowerID int64, limit int, offset int) ([]*model.Loan, error) {
-	loans, err := u.repo.GetLoansByBorrowerID(ctx, borrowerID, limit, offset)
+func (u *LoanUsecase) GetLoansByBorrowerID(ctx context.Context, borrowerID int64, limit int, offset int) (loans []*model.Loan, err error) {
+	ctx, span := tracing.Start(ctx, "LoanUsecase.GetLoansByBorrowerID")
+	defer func() { tracing.End(span, err) }()
+
+	loans, err = u.repo.GetLoansByBorrowerID(ctx, borrowerID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	err = u.signLoanURLs(ctx, loans...)
 	if err != nil {
 		return nil, err
 	}
@@ -26,7 +83,18 @@ func (u *LoanUsecase) GetLoansByBorrowerID(ctx context.Context, borrowerID int64
 	return loans, nil
 }
 
-func (u *LoanUsecase) CreateLoan(ctx context.Context, userID int64, loanProductID int64, amount int) (loan *model.Loan, err error) {
+// CountLoansByState counts the loans in every state.
+func (u *LoanUsecase) CountLoansByState(ctx context.Context) (counts map[model.LoanState]int, err error) {
+	ctx, span := tracing.Start(ctx, "LoanUsecase.CountLoansByState")
+	defer func() { tracing.End(span, err) }()
+
+	return u.repo.CountLoansByState(ctx)
+}
+
+func (u *LoanUsecase) CreateLoan(ctx context.Context, userID int64, loanProductID int64, amount int64) (loan *model.Loan, err error) {
+	ctx, span := tracing.Start(ctx, "LoanUsecase.CreateLoan")
+	defer func() { tracing.End(span, err) }()
+
 	user, err := u.repo.GetUserByID(ctx, userID)
 	if err != nil {
 		return nil, model.ErrUserNotFound
@@ -37,78 +105,233 @@ func (u *LoanUsecase) CreateLoan(ctx context.Context, userID int64, loanProductI
 		return nil, model.ErrLoanProductNotFound
 	}
 
-	loan = &model.Loan{
-		State:           0,
-		BorrowerID:      user.ID,
-		PrincipalAmount: amount,
-		Rate:            loanProduct.Rate,
-		ROI:             loanProduct.ROI,
+	aggregate := NewLoan(0)
+	err = aggregate.Propose(user.ID, amount, loanProduct, now())
+	if err != nil {
+		return nil, err
 	}
 
-	loanID, err := u.repo.CreateLoan(ctx, loan)
+	loan = aggregate.Projection()
+	err = u.repo.WithTransaction(ctx, func(ctx context.Context) error {
+		loanID, err := u.repo.CreateLoan(ctx, loan)
+		if err != nil {
+			return err
+		}
+		loan.ID = loanID
+
+		return u.saveLoanEvents(ctx, aggregate)
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	loan.ID = loanID
+	u.logger.InfoContext(ctx, "loan created", "loan_id", loan.ID, "borrower_id", loan.BorrowerID, "loan_product_id", loanProduct.ID, "amount", loan.PrincipalAmount, "currency", loan.Currency)
+	if u.metrics != nil {
+		u.metrics.LoanCreated(loan)
+	}
 
 	return loan, nil
 }
 
-func (u *LoanUsecase) ApproveLoan(ctx context.Context, loanID int64, employeeID int64, approvalProof string) error {
+// loanChange is a change of a loan validated against the loan as read without
+// a lock, attaching document as its proof, which is fetched and verified
+// already. lock validates it again against the loan locked in the transaction
+// of its context, save writes it in that transaction, and done reports it once
+// committed.
+type loanChange struct {
+	document *model.LoanDocument
+	lock     func(ctx context.Context) error
+	save     func(ctx context.Context) error
+	done     func(ctx context.Context)
+}
+
+// changeLoan validates a change of a loan with prepare and saves it in one
+// transaction. The document proving the change is downloaded before the
+// transaction, so the loan is not locked while waiting for it. The change is
+// then validated again with the loan locked by LockLoan, so a concurrent
+// change of the same loan waits until this one is saved and is then validated
+// against it, instead of both passing validation and overwriting each other.
+func (u *LoanUsecase) changeLoan(ctx context.Context, prepare func(ctx context.Context) (*loanChange, error)) error {
+	change, err := prepare(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = u.repo.WithTransaction(ctx, func(ctx context.Context) error {
+		err := change.lock(ctx)
+		if err != nil {
+			return err
+		}
+		return change.save(ctx)
+	})
+	if err != nil {
+		return err
+	}
+
+	change.done(ctx)
+	return nil
+}
+
+func (u *LoanUsecase) ApproveLoan(ctx context.Context, loanID int64, employeeID int64, approvalProof string) (err error) {
+	ctx, span := tracing.Start(ctx, "LoanUsecase.ApproveLoan")
+	defer func() { tracing.End(span, err) }()
+
+	return u.changeLoan(ctx, func(ctx context.Context) (*loanChange, error) {
+		return u.prepareApproval(ctx, loanID, employeeID, approvalProof)
+	})
+}
+
+// prepareApproval validates the approval of a loan and verifies its approval
+// proof without changing the loan.
+func (u *LoanUsecase) prepareApproval(ctx context.Context, loanID int64, employeeID int64, approvalProof string) (*loanChange, error) {
 	loan, err := u.repo.GetLoanByID(ctx, loanID)
 	if err != nil {
-		return model.ErrLoanNotFound
+		return nil, model.ErrLoanNotFound
 	}
 
 	employee, err := u.repo.GetEmployeeByID(ctx, employeeID)
 	if err != nil {
-		return model.ErrEmployeeNotFound
+		return nil, model.ErrEmployeeNotFound
 	}
 
-	if loan.State != model.LoanStateProposed {
-		return model.ErrLoanNotProposed
+	approve := func(loan *model.Loan) (*Loan, error) {
+		aggregate := LoanFromProjection(loan, 0)
+		return aggregate, aggregate.Approve(employee.ID, approvalProof, now())
 	}
 
-	loan.State = model.LoanStateApproved
-	loan.ApprovedBy = sql.NullInt64{Int64: employee.ID, Valid: true}
-	loan.ApprovalProof = sql.NullString{String: approvalProof, Valid: true}
-	loan.ApprovedAt = sql.NullTime{Time: time.Now(), Valid: true}
-	loan.LastUpdatedAt = time.Now()
+	unlocked := *loan
+	_, err = approve(&unlocked)
+	if err != nil {
+		return nil, err
+	}
 
-	err = u.repo.UpdateLoan(ctx, loan)
+	document, err := u.verifyDocument(ctx, loan.ID, model.DocumentApprovalProof, approvalProof)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	return nil
+	var aggregate *Loan
+	return &loanChange{
+		document: document,
+		lock: func(ctx context.Context) error {
+			loan, err = u.repo.LockLoan(ctx, loanID)
+			if err != nil {
+				return model.ErrLoanNotFound
+			}
+
+			aggregate, err = approve(loan)
+			if err != nil {
+				return err
+			}
+
+			return u.checkDocumentUnused(ctx, document)
+		},
+		save: func(ctx context.Context) error {
+			err := u.repo.UpdateLoan(ctx, loan)
+			if err != nil {
+				return err
+			}
+
+			_, err = u.repo.CreateLoanDocument(ctx, document)
+			if err != nil {
+				return err
+			}
+
+			return u.saveLoanEvents(ctx, aggregate)
+		},
+		done: func(ctx context.Context) {
+			u.logger.InfoContext(ctx, "loan approved", "loan_id", loan.ID, "employee_id", employee.ID)
+			if u.metrics != nil {
+				u.metrics.LoanApproved(loan)
+			}
+		},
+	}, nil
 }
 
-func (u *LoanUsecase) DisburseLoan(ctx context.Context, loanID int64, employeeID int64, agreementLetter string) error {
+func (u *LoanUsecase) DisburseLoan(ctx context.Context, loanID int64, employeeID int64, agreementLetter string) (err error) {
+	ctx, span := tracing.Start(ctx, "LoanUsecase.DisburseLoan")
+	defer func() { tracing.End(span, err) }()
+
+	return u.changeLoan(ctx, func(ctx context.Context) (*loanChange, error) {
+		return u.prepareDisbursement(ctx, loanID, employeeID, agreementLetter)
+	})
+}
+
+// prepareDisbursement validates the disbursement of a loan and verifies its
+// signed agreement letter without changing the loan. Signed agreements stay
+// signed, so they are not checked again under lock.
+func (u *LoanUsecase) prepareDisbursement(ctx context.Context, loanID int64, employeeID int64, agreementLetter string) (*loanChange, error) {
 	loan, err := u.repo.GetLoanByID(ctx, loanID)
 	if err != nil {
-		return model.ErrLoanNotFound
+		return nil, model.ErrLoanNotFound
 	}
 
 	employee, err := u.repo.GetEmployeeByID(ctx, employeeID)
 	if err != nil {
-		return model.ErrEmployeeNotFound
+		return nil, model.ErrEmployeeNotFound
 	}
 
-	if loan.State != model.LoanStateInvested {
-		return model.ErrLoanNotInvested
+	disburse := func(loan *model.Loan) (*Loan, error) {
+		aggregate := LoanFromProjection(loan, loan.PrincipalAmount)
+		return aggregate, aggregate.Disburse(employee.ID, agreementLetter, now())
 	}
 
-	loan.State = model.LoanStateDisbursed
-	loan.DisbursedBy = sql.NullInt64{Int64: employee.ID, Valid: true}
-	loan.AgreementLetter = sql.NullString{String: agreementLetter, Valid: true}
-	loan.DisbursedAt = sql.NullTime{Time: time.Now(), Valid: true}
-	loan.LastUpdatedAt = time.Now()
+	unlocked := *loan
+	_, err = disburse(&unlocked)
+	if err != nil {
+		return nil, err
+	}
 
-	err = u.repo.UpdateLoan(ctx, loan)
+	err = u.checkAgreementsSigned(ctx, loan.ID)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	return nil
+	document, err := u.verifyDocument(ctx, loan.ID, model.DocumentSignedAgreement, agreementLetter)
+	if err != nil {
+		return nil, err
+	}
+
+	var aggregate *Loan
+	return &loanChange{
+		document: document,
+		lock: func(ctx context.Context) error {
+			loan, err = u.repo.LockLoan(ctx, loanID)
+			if err != nil {
+				return model.ErrLoanNotFound
+			}
+
+			aggregate, err = disburse(loan)
+			if err != nil {
+				return err
+			}
+
+			return u.checkDocumentUnused(ctx, document)
+		},
+		save: func(ctx context.Context) error {
+			err := u.repo.UpdateLoan(ctx, loan)
+			if err != nil {
+				return err
+			}
+
+			_, err = u.repo.CreateLoanDocument(ctx, document)
+			if err != nil {
+				return err
+			}
+
+			err = u.postJournal(ctx, disbursementJournal(loan))
+			if err != nil {
+				return err
+			}
+
+			return u.saveLoanEvents(ctx, aggregate)
+		},
+		done: func(ctx context.Context) {
+			u.logger.InfoContext(ctx, "loan disbursed", "loan_id", loan.ID, "employee_id", employee.ID)
+			if u.metrics != nil {
+				u.metrics.LoanDisbursed(loan)
+			}
+			u.notifyLoan(ctx, *loan, model.NotificationBorrowerLoanDisbursed, model.NotificationInvestorLoanDisbursed)
+		},
+	}, nil
 }
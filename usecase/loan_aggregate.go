@@ -0,0 +1,290 @@
+package usecase
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/aldipi/loan-service/model"
+	"github.com/shopspring/decimal"
+)
+
+// LoanEvent is a domain event in the lifecycle of a loan.
+type LoanEvent interface {
+	EventType() model.LoanEventType
+	EventTime() time.Time
+}
+
+type LoanProposed struct {
+	BorrowerID      int64           `json:"borrower_id"`
+	LoanProductID   int64           `json:"loan_product_id,omitempty"`
+	PrincipalAmount int64           `json:"principal_amount,string"`
+	Currency        model.Currency  `json:"currency"`
+	Rate            decimal.Decimal `json:"rate"`
+	ROI             decimal.Decimal `json:"roi"`
+	OccurredAt      time.Time       `json:"occurred_at"`
+}
+
+type LoanApproved struct {
+	EmployeeID    int64     `json:"employee_id"`
+	ApprovalProof string    `json:"approval_proof"`
+	OccurredAt    time.Time `json:"occurred_at"`
+}
+
+type InvestmentMade struct {
+	InvestmentID int64     `json:"investment_id"`
+	InvestorID   int64     `json:"investor_id"`
+	Amount       int64     `json:"amount,string"`
+	OccurredAt   time.Time `json:"occurred_at"`
+}
+
+type LoanFullyFunded struct {
+	BorrowerAgreement         string    `json:"borrower_agreement,omitempty"`
+	BorrowerAgreementChecksum string    `json:"borrower_agreement_checksum,omitempty"`
+	OccurredAt                time.Time `json:"occurred_at"`
+}
+
+type LoanDisbursed struct {
+	EmployeeID      int64     `json:"employee_id"`
+	AgreementLetter string    `json:"agreement_letter"`
+	OccurredAt      time.Time `json:"occurred_at"`
+}
+
+func (e LoanProposed) EventType() model.LoanEventType    { return model.LoanEventProposed }
+func (e LoanApproved) EventType() model.LoanEventType    { return model.LoanEventApproved }
+func (e InvestmentMade) EventType() model.LoanEventType  { return model.LoanEventInvestment }
+func (e LoanFullyFunded) EventType() model.LoanEventType { return model.LoanEventFullyFunded }
+func (e LoanDisbursed) EventType() model.LoanEventType   { return model.LoanEventDisbursed }
+
+func (e LoanProposed) EventTime() time.Time    { return e.OccurredAt }
+func (e LoanApproved) EventTime() time.Time    { return e.OccurredAt }
+func (e InvestmentMade) EventTime() time.Time  { return e.OccurredAt }
+func (e LoanFullyFunded) EventTime() time.Time { return e.OccurredAt }
+func (e LoanDisbursed) EventTime() time.Time   { return e.OccurredAt }
+
+// Loan is the event-sourced loan aggregate. Its state only changes by applying
+// domain events, so replaying the stored events of a loan always reproduces the
+// same row in the loans projection.
+type Loan struct {
+	loan          *model.Loan
+	totalInvested int64
+	changes       []LoanEvent
+}
+
+// NewLoan returns an aggregate for a loan that has no events yet.
+func NewLoan(loanID int64) *Loan {
+	return &Loan{loan: &model.Loan{ID: loanID}}
+}
+
+// LoanFromProjection returns an aggregate positioned at the state stored in the
+// loans projection, so new events can be recorded without replaying history.
+func LoanFromProjection(loan *model.Loan, totalInvested int64) *Loan {
+	return &Loan{loan: loan, totalInvested: totalInvested}
+}
+
+// Projection returns the loans table row for the current aggregate state.
+func (a *Loan) Projection() *model.Loan {
+	return a.loan
+}
+
+func (a *Loan) TotalInvested() int64 {
+	return a.totalInvested
+}
+
+// Changes returns the events recorded since the aggregate was loaded.
+func (a *Loan) Changes() []LoanEvent {
+	return a.changes
+}
+
+// Apply mutates the aggregate with an event that already happened.
+func (a *Loan) Apply(event LoanEvent) error {
+	loan := a.loan
+
+	switch e := event.(type) {
+	case LoanProposed:
+		if !loan.CreatedAt.IsZero() {
+			return model.ErrLoanEventInvalid
+		}
+		loan.State = model.LoanStateProposed
+		loan.BorrowerID = e.BorrowerID
+		loan.LoanProductID = sql.NullInt64{Int64: e.LoanProductID, Valid: e.LoanProductID != 0}
+		loan.PrincipalAmount = e.PrincipalAmount
+		loan.Currency = e.Currency
+		loan.Rate = e.Rate
+		loan.ROI = e.ROI
+		loan.CreatedAt = e.OccurredAt
+		loan.LastUpdatedAt = e.OccurredAt
+	case LoanApproved:
+		if loan.State != model.LoanStateProposed {
+			return model.ErrLoanNotProposed
+		}
+		loan.State = model.LoanStateApproved
+		loan.ApprovedBy = sql.NullInt64{Int64: e.EmployeeID, Valid: true}
+		loan.ApprovalProof = sql.NullString{String: e.ApprovalProof, Valid: true}
+		loan.ApprovedAt = sql.NullTime{Time: e.OccurredAt, Valid: true}
+		loan.LastUpdatedAt = e.OccurredAt
+	case InvestmentMade:
+		if loan.State != model.LoanStateApproved {
+			return model.ErrLoanNotApproved
+		}
+		if e.Amount <= 0 || e.Amount > loan.PrincipalAmount-a.totalInvested {
+			return model.ErrInvestmentInvalidAmount
+		}
+		// Investments live in their own table, so they do not touch the loan row.
+		a.totalInvested += e.Amount
+	case LoanFullyFunded:
+		if loan.State != model.LoanStateApproved || a.totalInvested != loan.PrincipalAmount {
+			return model.ErrLoanEventInvalid
+		}
+		loan.State = model.LoanStateInvested
+		if e.BorrowerAgreement != "" {
+			loan.BorrowerAgreement = sql.NullString{String: e.BorrowerAgreement, Valid: true}
+			loan.BorrowerAgreementChecksum = sql.NullString{String: e.BorrowerAgreementChecksum, Valid: true}
+		}
+		loan.InvestedAt = sql.NullTime{Time: e.OccurredAt, Valid: true}
+		loan.LastUpdatedAt = e.OccurredAt
+	case LoanDisbursed:
+		if loan.State != model.LoanStateInvested {
+			return model.ErrLoanNotInvested
+		}
+		loan.State = model.LoanStateDisbursed
+		loan.DisbursedBy = sql.NullInt64{Int64: e.EmployeeID, Valid: true}
+		loan.AgreementLetter = sql.NullString{String: e.AgreementLetter, Valid: true}
+		loan.DisbursedAt = sql.NullTime{Time: e.OccurredAt, Valid: true}
+		loan.LastUpdatedAt = e.OccurredAt
+	default:
+		return model.ErrLoanEventUnknown
+	}
+
+	return nil
+}
+
+// Record applies a new event and keeps it to be stored.
+func (a *Loan) Record(event LoanEvent) error {
+	err := a.Apply(event)
+	if err != nil {
+		return err
+	}
+
+	a.changes = append(a.changes, event)
+
+	return nil
+}
+
+func (a *Loan) Propose(borrowerID int64, amount int64, product *model.LoanProduct, at time.Time) error {
+	return a.Record(LoanProposed{
+		BorrowerID:      borrowerID,
+		LoanProductID:   product.ID,
+		PrincipalAmount: amount,
+		Currency:        product.Currency,
+		Rate:            product.Rate,
+		ROI:             product.ROI,
+		OccurredAt:      at,
+	})
+}
+
+func (a *Loan) Approve(employeeID int64, approvalProof string, at time.Time) error {
+	return a.Record(LoanApproved{
+		EmployeeID:    employeeID,
+		ApprovalProof: approvalProof,
+		OccurredAt:    at,
+	})
+}
+
+// Invest records an investment and, when it covers the remaining principal,
+// marks the loan as fully funded with the given borrower agreement letter.
+func (a *Loan) Invest(investment *model.Investment, borrowerAgreement model.Document, at time.Time) error {
+	err := a.Record(InvestmentMade{
+		InvestmentID: investment.ID,
+		InvestorID:   investment.InvestorID,
+		Amount:       investment.Amount,
+		OccurredAt:   at,
+	})
+	if err != nil {
+		return err
+	}
+
+	if a.totalInvested == a.loan.PrincipalAmount {
+		return a.Record(LoanFullyFunded{
+			BorrowerAgreement:         borrowerAgreement.Key,
+			BorrowerAgreementChecksum: borrowerAgreement.Checksum,
+			OccurredAt:                at,
+		})
+	}
+
+	return nil
+}
+
+func (a *Loan) Disburse(employeeID int64, agreementLetter string, at time.Time) error {
+	return a.Record(LoanDisbursed{
+		EmployeeID:      employeeID,
+		AgreementLetter: agreementLetter,
+		OccurredAt:      at,
+	})
+}
+
+// ReplayLoan rebuilds a loan aggregate from its stored events.
+func ReplayLoan(loanID int64, events []*model.LoanEvent) (*Loan, error) {
+	a := NewLoan(loanID)
+	for _, stored := range events {
+		err := a.replay(stored)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return a, nil
+}
+
+// replay applies a stored event to the aggregate.
+func (a *Loan) replay(stored *model.LoanEvent) error {
+	event, err := decodeLoanEvent(stored)
+	if err != nil {
+		return err
+	}
+
+	return a.Apply(event)
+}
+
+func encodeLoanEvent(loanID int64, event LoanEvent) (*model.LoanEvent, error) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.LoanEvent{
+		LoanID:     loanID,
+		Type:       event.EventType(),
+		Payload:    payload,
+		OccurredAt: event.EventTime(),
+	}, nil
+}
+
+func decodeLoanEvent(stored *model.LoanEvent) (LoanEvent, error) {
+	var err error
+
+	switch stored.Type {
+	case model.LoanEventProposed:
+		e := LoanProposed{}
+		err = json.Unmarshal(stored.Payload, &e)
+		return e, err
+	case model.LoanEventApproved:
+		e := LoanApproved{}
+		err = json.Unmarshal(stored.Payload, &e)
+		return e, err
+	case model.LoanEventInvestment:
+		e := InvestmentMade{}
+		err = json.Unmarshal(stored.Payload, &e)
+		return e, err
+	case model.LoanEventFullyFunded:
+		e := LoanFullyFunded{}
+		err = json.Unmarshal(stored.Payload, &e)
+		return e, err
+	case model.LoanEventDisbursed:
+		e := LoanDisbursed{}
+		err = json.Unmarshal(stored.Payload, &e)
+		return e, err
+	}
+
+	return nil, model.ErrLoanEventUnknown
+}
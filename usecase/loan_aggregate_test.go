@@ -0,0 +1,72 @@
+package usecase
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/aldipi/loan-service/model"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoanAggregateLifecycle(t *testing.T) {
+	at := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	product := &model.LoanProduct{Rate: decimal.NewFromFloat(10.0), ROI: decimal.NewFromFloat(5.5)}
+
+	a := NewLoan(1)
+	assert.NoError(t, a.Propose(123, 1000, product, at))
+	assert.NoError(t, a.Approve(555, "https://file.io/123/proof.jpg", at.Add(time.Hour)))
+	assert.NoError(t, a.Invest(&model.Investment{ID: 1, InvestorID: 100, Amount: 400}, model.Document{}, at.Add(2*time.Hour)))
+	assert.Equal(t, model.LoanStateApproved, a.Projection().State)
+	assert.NoError(t, a.Invest(&model.Investment{ID: 2, InvestorID: 101, Amount: 600}, model.Document{Key: "https://file.io/123/borrower.pdf", Checksum: "abc"}, at.Add(3*time.Hour)))
+	assert.NoError(t, a.Disburse(777, "https://file.io/123/agreement.pdf", at.Add(4*time.Hour)))
+
+	assert.Len(t, a.Changes(), 6)
+	assert.IsType(t, LoanFullyFunded{}, a.Changes()[4])
+	assert.Equal(t, &model.Loan{
+		ID:                        1,
+		State:                     model.LoanStateDisbursed,
+		BorrowerID:                123,
+		PrincipalAmount:           1000,
+		Rate:                      decimal.NewFromFloat(10.0),
+		ROI:                       decimal.NewFromFloat(5.5),
+		ApprovalProof:             sql.NullString{String: "https://file.io/123/proof.jpg", Valid: true},
+		ApprovedBy:                sql.NullInt64{Int64: 555, Valid: true},
+		AgreementLetter:           sql.NullString{String: "https://file.io/123/agreement.pdf", Valid: true},
+		DisbursedBy:               sql.NullInt64{Int64: 777, Valid: true},
+		BorrowerAgreement:         sql.NullString{String: "https://file.io/123/borrower.pdf", Valid: true},
+		BorrowerAgreementChecksum: sql.NullString{String: "abc", Valid: true},
+		CreatedAt:                 at,
+		ApprovedAt:                sql.NullTime{Time: at.Add(time.Hour), Valid: true},
+		InvestedAt:                sql.NullTime{Time: at.Add(3 * time.Hour), Valid: true},
+		DisbursedAt:               sql.NullTime{Time: at.Add(4 * time.Hour), Valid: true},
+		LastUpdatedAt:             at.Add(4 * time.Hour),
+	}, a.Projection())
+}
+
+func TestLoanAggregateRejectsInvalidTransitions(t *testing.T) {
+	at := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	product := &model.LoanProduct{Rate: decimal.NewFromFloat(10.0), ROI: decimal.NewFromFloat(5.5)}
+
+	a := NewLoan(1)
+	assert.NoError(t, a.Propose(123, 1000, product, at))
+
+	assert.ErrorIs(t, a.Invest(&model.Investment{Amount: 100}, model.Document{}, at), model.ErrLoanNotApproved)
+	assert.ErrorIs(t, a.Disburse(777, "https://file.io/123/agreement.pdf", at), model.ErrLoanNotInvested)
+	assert.ErrorIs(t, a.Record(LoanFullyFunded{OccurredAt: at}), model.ErrLoanEventInvalid)
+
+	assert.NoError(t, a.Approve(555, "https://file.io/123/proof.jpg", at))
+	assert.ErrorIs(t, a.Approve(555, "https://file.io/123/proof.jpg", at), model.ErrLoanNotProposed)
+	assert.ErrorIs(t, a.Invest(&model.Investment{Amount: 1001}, model.Document{}, at), model.ErrInvestmentInvalidAmount)
+
+	assert.Len(t, a.Changes(), 2)
+}
+
+func TestReplayLoanUnknownEvent(t *testing.T) {
+	_, err := ReplayLoan(1, []*model.LoanEvent{
+		{ID: 1, LoanID: 1, Type: "LoanRenamed", Payload: []byte(`{}`)},
+	})
+
+	assert.ErrorIs(t, err, model.ErrLoanEventUnknown)
+}
@@ -0,0 +1,176 @@
+package usecase
+
+import (
+	"context"
+
+	"github.com/aldipi/loan-service/model"
+	"github.com/aldipi/loan-service/tracing"
+)
+
+// GetLoanByID returns the loan with its funding progress and the requested
+// relations. Only the borrower, employees and investors of the loan can see
+// it, and investors only see their own investments.
+func (u *LoanUsecase) GetLoanByID(ctx context.Context, actor model.Actor, loanID int64, expand []model.LoanExpansion) (detail *model.LoanDetail, err error) {
+	ctx, span := tracing.Start(ctx, "LoanUsecase.GetLoanByID")
+	defer func() { tracing.End(span, err) }()
+
+	expansions := map[model.LoanExpansion]bool{}
+	for _, expansion := range expand {
+		switch expansion {
+		case model.ExpandInvestments, model.ExpandProduct, model.ExpandBorrower, model.ExpandApprover, model.ExpandDisburser:
+			expansions[expansion] = true
+		default:
+			return nil, model.ErrLoanExpansionInvalid
+		}
+	}
+
+	loan, err := u.repo.GetLoanByID(ctx, loanID)
+	if err != nil {
+		return nil, model.ErrLoanNotFound
+	}
+
+	investments, err := u.repo.GetInvestmentsByLoanID(ctx, loanID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = u.authorizeLoan(ctx, actor, loan, investments)
+	if err != nil {
+		return nil, err
+	}
+
+	detail = &model.LoanDetail{Loan: *loan}
+	for _, investment := range investments {
+		detail.FundedAmount += investment.Amount
+	}
+	detail.RemainingAmount = loan.PrincipalAmount - detail.FundedAmount
+
+	if expansions[model.ExpandProduct] && loan.LoanProductID.Valid {
+		detail.Product, err = u.repo.GetLoanProductByID(ctx, loan.LoanProductID.Int64)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if expansions[model.ExpandBorrower] {
+		borrower, err := u.repo.GetUserByID(ctx, loan.BorrowerID)
+		if err != nil {
+			return nil, err
+		}
+		detail.Borrower = &model.Party{ID: borrower.ID, Name: borrower.Name}
+	}
+
+	if expansions[model.ExpandApprover] && loan.ApprovedBy.Valid {
+		detail.Approver, err = u.employeeParty(ctx, loan.ApprovedBy.Int64)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if expansions[model.ExpandDisburser] && loan.DisbursedBy.Valid {
+		detail.Disburser, err = u.employeeParty(ctx, loan.DisbursedBy.Int64)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if expansions[model.ExpandInvestments] {
+		detail.Investments, err = u.loanInvestments(ctx, actor, investments)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = u.signLoanURLs(ctx, &detail.Loan)
+	if err != nil {
+		return nil, err
+	}
+
+	return detail, nil
+}
+
+// authorizeLoan allows the borrower of the loan, any employee and the
+// investors of the loan.
+func (u *LoanUsecase) authorizeLoan(ctx context.Context, actor model.Actor, loan *model.Loan, investments []*model.Investment) error {
+	switch actor.Role {
+	case model.RoleBorrower:
+		if loan.BorrowerID == actor.ID {
+			return nil
+		}
+	case model.RoleEmployee:
+		_, err := u.repo.GetEmployeeByID(ctx, actor.ID)
+		if err == nil {
+			return nil
+		}
+	case model.RoleInvestor:
+		for _, investment := range investments {
+			if investment.InvestorID == actor.ID {
+				return nil
+			}
+		}
+	}
+
+	return model.ErrLoanAccessDenied
+}
+
+// accessLoan returns the loan when the actor is allowed to see it by
+// authorizeLoan.
+func (u *LoanUsecase) accessLoan(ctx context.Context, actor model.Actor, loanID int64) (*model.Loan, error) {
+	loan, err := u.repo.GetLoanByID(ctx, loanID)
+	if err != nil {
+		return nil, model.ErrLoanNotFound
+	}
+
+	investments, err := u.repo.GetInvestmentsByLoanID(ctx, loanID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = u.authorizeLoan(ctx, actor, loan, investments)
+	if err != nil {
+		return nil, err
+	}
+
+	return loan, nil
+}
+
+func (u *LoanUsecase) employeeParty(ctx context.Context, employeeID int64) (*model.Party, error) {
+	employee, err := u.repo.GetEmployeeByID(ctx, employeeID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.Party{ID: employee.ID, Name: employee.Name}, nil
+}
+
+// loanInvestments returns the investments visible to the actor with the names
+// of their investors.
+func (u *LoanUsecase) loanInvestments(ctx context.Context, actor model.Actor, investments []*model.Investment) ([]*model.LoanInvestment, error) {
+	investorNames := map[int64]string{}
+	loanInvestments := []*model.LoanInvestment{}
+	for _, investment := range investments {
+		if actor.Role == model.RoleInvestor && investment.InvestorID != actor.ID {
+			continue
+		}
+
+		name, ok := investorNames[investment.InvestorID]
+		if !ok {
+			investor, err := u.repo.GetInvestorByID(ctx, investment.InvestorID)
+			if err != nil {
+				return nil, err
+			}
+			name = investor.Name
+			investorNames[investment.InvestorID] = name
+		}
+
+		loanInvestment := &model.LoanInvestment{Investment: *investment, InvestorName: name}
+		err := u.signURL(ctx, &loanInvestment.AgreementLetter)
+		if err != nil {
+			return nil, err
+		}
+
+		loanInvestments = append(loanInvestments, loanInvestment)
+	}
+
+	return loanInvestments, nil
+}
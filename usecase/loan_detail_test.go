@@ -0,0 +1,146 @@
+package usecase
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/aldipi/loan-service/model"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func disbursedLoan() *model.Loan {
+	return &model.Loan{
+		ID:              1,
+		State:           model.LoanStateDisbursed,
+		BorrowerID:      123,
+		LoanProductID:   sql.NullInt64{Int64: 7, Valid: true},
+		PrincipalAmount: 1000000,
+		ApprovedBy:      sql.NullInt64{Int64: 555, Valid: true},
+		DisbursedBy:     sql.NullInt64{Int64: 777, Valid: true},
+	}
+}
+
+func loanInvestments() []*model.Investment {
+	return []*model.Investment{
+		{ID: 1, InvestorID: 100, LoanID: 1, Amount: 400000},
+		{ID: 2, InvestorID: 101, LoanID: 1, Amount: 300000},
+		{ID: 3, InvestorID: 100, LoanID: 1, Amount: 100000},
+	}
+}
+
+func TestGetLoanByIDExpandsRelations(t *testing.T) {
+	repo := new(MockRepository)
+	uc := NewLoanUsecase(repo)
+
+	repo.On("GetLoanByID", mock.Anything, int64(1)).Return(disbursedLoan(), nil)
+	repo.On("GetInvestmentsByLoanID", mock.Anything, int64(1)).Return(loanInvestments(), nil)
+	repo.On("GetEmployeeByID", mock.Anything, int64(555)).Return(&model.Employee{ID: 555, Name: "Approver"}, nil)
+	repo.On("GetEmployeeByID", mock.Anything, int64(777)).Return(&model.Employee{ID: 777, Name: "Disburser"}, nil)
+	repo.On("GetLoanProductByID", mock.Anything, int64(7)).Return(&model.LoanProduct{ID: 7, Name: "Productive Loan", Rate: decimal.NewFromInt(10)}, nil)
+	repo.On("GetUserByID", mock.Anything, int64(123)).Return(&model.User{ID: 123, Name: "John Doe", Email: sql.NullString{String: "john@example.com", Valid: true}}, nil)
+	repo.On("GetInvestorByID", mock.Anything, int64(100)).Return(&model.Investor{ID: 100, Name: "Investor A"}, nil)
+	repo.On("GetInvestorByID", mock.Anything, int64(101)).Return(&model.Investor{ID: 101, Name: "Investor B"}, nil)
+
+	detail, err := uc.GetLoanByID(context.Background(), model.Actor{Role: model.RoleEmployee, ID: 555}, 1, []model.LoanExpansion{
+		model.ExpandInvestments, model.ExpandProduct, model.ExpandBorrower, model.ExpandApprover, model.ExpandDisburser,
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), detail.ID)
+	assert.Equal(t, int64(800000), detail.FundedAmount)
+	assert.Equal(t, int64(200000), detail.RemainingAmount)
+	assert.Equal(t, "Productive Loan", detail.Product.Name)
+	assert.Equal(t, &model.Party{ID: 123, Name: "John Doe"}, detail.Borrower)
+	assert.Equal(t, &model.Party{ID: 555, Name: "Approver"}, detail.Approver)
+	assert.Equal(t, &model.Party{ID: 777, Name: "Disburser"}, detail.Disburser)
+	assert.Len(t, detail.Investments, 3)
+	assert.Equal(t, "Investor B", detail.Investments[1].InvestorName)
+	repo.AssertNumberOfCalls(t, "GetInvestorByID", 2)
+}
+
+func TestGetLoanByIDWithoutExpansions(t *testing.T) {
+	repo := new(MockRepository)
+	uc := NewLoanUsecase(repo)
+
+	repo.On("GetLoanByID", mock.Anything, int64(1)).Return(disbursedLoan(), nil)
+	repo.On("GetInvestmentsByLoanID", mock.Anything, int64(1)).Return(loanInvestments(), nil)
+
+	detail, err := uc.GetLoanByID(context.Background(), model.Actor{Role: model.RoleBorrower, ID: 123}, 1, nil)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(800000), detail.FundedAmount)
+	assert.Nil(t, detail.Product)
+	assert.Nil(t, detail.Borrower)
+	assert.Nil(t, detail.Investments)
+	repo.AssertNotCalled(t, "GetLoanProductByID", mock.Anything, mock.Anything)
+}
+
+func TestGetLoanByIDInvestorSeesOwnInvestments(t *testing.T) {
+	repo := new(MockRepository)
+	uc := NewLoanUsecase(repo)
+
+	repo.On("GetLoanByID", mock.Anything, int64(1)).Return(disbursedLoan(), nil)
+	repo.On("GetInvestmentsByLoanID", mock.Anything, int64(1)).Return(loanInvestments(), nil)
+	repo.On("GetInvestorByID", mock.Anything, int64(100)).Return(&model.Investor{ID: 100, Name: "Investor A"}, nil)
+
+	detail, err := uc.GetLoanByID(context.Background(), model.Actor{Role: model.RoleInvestor, ID: 100}, 1, []model.LoanExpansion{model.ExpandInvestments})
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(800000), detail.FundedAmount)
+	assert.Len(t, detail.Investments, 2)
+	for _, investment := range detail.Investments {
+		assert.Equal(t, int64(100), investment.InvestorID)
+	}
+}
+
+func TestGetLoanByIDAccessDenied(t *testing.T) {
+	tests := []struct {
+		name  string
+		actor model.Actor
+	}{
+		{name: "other borrower", actor: model.Actor{Role: model.RoleBorrower, ID: 456}},
+		{name: "investor not in loan", actor: model.Actor{Role: model.RoleInvestor, ID: 102}},
+		{name: "unknown employee", actor: model.Actor{Role: model.RoleEmployee, ID: 999}},
+		{name: "no role", actor: model.Actor{ID: 123}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := new(MockRepository)
+			uc := NewLoanUsecase(repo)
+
+			repo.On("GetLoanByID", mock.Anything, int64(1)).Return(disbursedLoan(), nil)
+			repo.On("GetInvestmentsByLoanID", mock.Anything, int64(1)).Return(loanInvestments(), nil)
+			repo.On("GetEmployeeByID", mock.Anything, int64(999)).Return(nil, errors.New("not found"))
+
+			_, err := uc.GetLoanByID(context.Background(), tt.actor, 1, nil)
+
+			assert.ErrorIs(t, err, model.ErrLoanAccessDenied)
+		})
+	}
+}
+
+func TestGetLoanByIDInvalidExpansion(t *testing.T) {
+	repo := new(MockRepository)
+	uc := NewLoanUsecase(repo)
+
+	_, err := uc.GetLoanByID(context.Background(), model.Actor{Role: model.RoleBorrower, ID: 123}, 1, []model.LoanExpansion{"documents"})
+
+	assert.ErrorIs(t, err, model.ErrLoanExpansionInvalid)
+	repo.AssertNotCalled(t, "GetLoanByID", mock.Anything, mock.Anything)
+}
+
+func TestGetLoanByIDNotFound(t *testing.T) {
+	repo := new(MockRepository)
+	uc := NewLoanUsecase(repo)
+
+	repo.On("GetLoanByID", mock.Anything, int64(1)).Return(nil, errors.New("not found"))
+
+	_, err := uc.GetLoanByID(context.Background(), model.Actor{Role: model.RoleBorrower, ID: 123}, 1, nil)
+
+	assert.ErrorIs(t, err, model.ErrLoanNotFound)
+}
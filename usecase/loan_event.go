@@ -0,0 +1,114 @@
+package usecase
+
+import (
+	"context"
+
+	"github.com/aldipi/loan-service/model"
+	"github.com/aldipi/loan-service/tracing"
+)
+
+const rebuildBatchSize = 500
+
+// saveLoanEvents stores the events recorded on the aggregate and queues them in
+// the outbox for publishing. It should be called in the same transaction that
+// writes the aggregate projection.
+func (u *LoanUsecase) saveLoanEvents(ctx context.Context, aggregate *Loan) error {
+	for _, event := range aggregate.Changes() {
+		stored, err := encodeLoanEvent(aggregate.Projection().ID, event)
+		if err != nil {
+			return err
+		}
+
+		_, err = u.repo.CreateLoanEvent(ctx, stored)
+		if err != nil {
+			return err
+		}
+
+		_, err = u.repo.CreateOutboxMessage(ctx, &model.OutboxMessage{
+			EventType:     stored.Type,
+			LoanID:        stored.LoanID,
+			Payload:       stored.Payload,
+			NextAttemptAt: stored.OccurredAt,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RebuildLoanProjection replays every stored loan event and rewrites the rows
+// of the loans table they describe. The events are read page by page in the
+// order of their loans, so only the loan being replayed is held in memory.
+// Loans without any event, such as loans created before events were stored,
+// can not be rebuilt and are kept as they are. They are listed in the result
+// so they can be checked.
+func (u *LoanUsecase) RebuildLoanProjection(ctx context.Context) (rebuild *model.LoanRebuild, err error) {
+	ctx, span := tracing.Start(ctx, "LoanUsecase.RebuildLoanProjection")
+	defer func() { tracing.End(span, err) }()
+
+	rebuild = &model.LoanRebuild{}
+	err = u.repo.WithTransaction(ctx, func(ctx context.Context) error {
+		var aggregate *Loan
+		var afterLoanID, afterID int64
+		for {
+			events, err := u.repo.GetLoanEvents(ctx, afterLoanID, afterID, rebuildBatchSize)
+			if err != nil {
+				return err
+			}
+
+			for _, event := range events {
+				if aggregate == nil || aggregate.Projection().ID != event.LoanID {
+					err = u.replaceLoan(ctx, rebuild, aggregate)
+					if err != nil {
+						return err
+					}
+					aggregate = NewLoan(event.LoanID)
+				}
+
+				err = aggregate.replay(event)
+				if err != nil {
+					return err
+				}
+				afterLoanID, afterID = event.LoanID, event.ID
+			}
+
+			if len(events) < rebuildBatchSize {
+				break
+			}
+		}
+
+		err := u.replaceLoan(ctx, rebuild, aggregate)
+		if err != nil {
+			return err
+		}
+
+		rebuild.WithoutEvents, err = u.repo.GetLoanIDsWithoutEvents(ctx)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(rebuild.WithoutEvents) > 0 {
+		u.logger.WarnContext(ctx, "loans without events kept as they are", "count", len(rebuild.WithoutEvents), "loan_ids", rebuild.WithoutEvents)
+	}
+
+	return rebuild, nil
+}
+
+// replaceLoan writes the projection of a replayed loan, if any.
+func (u *LoanUsecase) replaceLoan(ctx context.Context, rebuild *model.LoanRebuild, aggregate *Loan) error {
+	if aggregate == nil {
+		return nil
+	}
+
+	err := u.repo.ReplaceLoan(ctx, aggregate.Projection())
+	if err != nil {
+		return err
+	}
+
+	rebuild.Rebuilt++
+	return nil
+}
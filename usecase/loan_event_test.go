@@ -0,0 +1,140 @@
+package usecase
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/aldipi/loan-service/model"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// TestRebuildLoanProjectionEqualsLiveProjection drives a loan through its whole
+// lifecycle, then rebuilds the projection from the captured events and checks
+// the rebuilt row equals the row written by the usecases.
+func TestRebuildLoanProjectionEqualsLiveProjection(t *testing.T) {
+	repo := new(MockRepository)
+	uc := NewLoanUsecase(repo, WithHTTPClient(http.DefaultClient))
+
+	events := []*model.LoanEvent{}
+
+	expectFundedWallets(repo)
+	expectJournalEntries(repo)
+	repo.On("GetUserByID", mock.Anything, int64(123)).Return(&model.User{ID: 123}, nil)
+	repo.On("GetLoanProductByID", mock.Anything, int64(100)).Return(&model.LoanProduct{ID: 100, Rate: decimal.NewFromInt(10), ROI: decimal.RequireFromString("5.5")}, nil)
+	repo.On("GetEmployeeByID", mock.Anything, int64(555)).Return(&model.Employee{ID: 555}, nil)
+	repo.On("GetInvestorByID", mock.Anything, mock.Anything).Return(&model.Investor{ID: 100}, nil)
+	repo.On("CreateLoan", mock.Anything, mock.Anything).Return(int64(1), nil)
+	repo.On("UpdateLoan", mock.Anything, mock.Anything).Return(nil)
+	repo.On("CreateLoanEvent", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		event := args.Get(1).(*model.LoanEvent)
+		event.ID = int64(len(events) + 1)
+		events = append(events, event)
+	}).Return(int64(0), nil)
+	repo.On("CreateOutboxMessage", mock.Anything, mock.Anything).Return(int64(0), nil)
+	repo.On("GetLoanDocumentsByChecksum", mock.Anything, mock.Anything).Return([]*model.LoanDocument{}, nil)
+	repo.On("CreateLoanDocument", mock.Anything, mock.Anything).Return(int64(0), nil)
+
+	live, err := uc.CreateLoan(context.Background(), 123, 100, 1000000)
+	assert.NoError(t, err)
+
+	// The usecases update the loan locked by LockLoan in place, so live
+	// always holds the projection row as written by UpdateLoan.
+	repo.On("GetLoanByID", mock.Anything, int64(1)).Return(live, nil)
+	repo.On("LockLoan", mock.Anything, int64(1)).Return(live, nil)
+
+	assert.NoError(t, uc.ApproveLoan(context.Background(), 1, 555, serveDocument(t, pngHeader)))
+
+	repo.On("GetInvestmentsByLoanID", mock.Anything, int64(1)).Return([]*model.Investment{}, nil).Once()
+	repo.On("CreateInvestment", mock.Anything, mock.Anything).Return(int64(1), nil).Once()
+	_, err = uc.CreateInvestment(context.Background(), 100, 1, 400000)
+	assert.NoError(t, err)
+
+	repo.On("GetInvestmentsByLoanID", mock.Anything, int64(1)).Return([]*model.Investment{{ID: 1, LoanID: 1, Amount: 400000}}, nil).Once()
+	repo.On("CreateInvestment", mock.Anything, mock.Anything).Return(int64(2), nil).Once()
+	_, err = uc.CreateInvestment(context.Background(), 101, 1, 600000)
+	assert.NoError(t, err)
+
+	repo.On("GetInvestmentsByLoanID", mock.Anything, int64(1)).Return([]*model.Investment{{ID: 1}, {ID: 2}}, nil).Once()
+	repo.On("GetLoanDocumentsByLoanID", mock.Anything, int64(1)).Return(signedAgreements(1, 1, 2), nil)
+	assert.NoError(t, uc.DisburseLoan(context.Background(), 1, 555, serveDocument(t, []byte("%PDF-1.4"))))
+	assert.Len(t, events, 6)
+
+	var rebuilt *model.Loan
+	repo.On("GetLoanEvents", mock.Anything, int64(0), int64(0), rebuildBatchSize).Return(events, nil)
+	repo.On("ReplaceLoan", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		rebuilt = args.Get(1).(*model.Loan)
+	}).Return(nil)
+	repo.On("GetLoanIDsWithoutEvents", mock.Anything).Return([]int64{}, nil)
+
+	rebuild, err := uc.RebuildLoanProjection(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, &model.LoanRebuild{Rebuilt: 1, WithoutEvents: []int64{}}, rebuild)
+	assert.Equal(t, model.LoanStateDisbursed, rebuilt.State)
+	assert.Equal(t, live, rebuilt)
+}
+
+func TestRebuildLoanProjectionInvalidHistory(t *testing.T) {
+	repo := new(MockRepository)
+	uc := NewLoanUsecase(repo)
+
+	repo.On("GetLoanEvents", mock.Anything, int64(0), int64(0), rebuildBatchSize).Return([]*model.LoanEvent{
+		{ID: 1, LoanID: 1, Type: model.LoanEventDisbursed, Payload: []byte(`{"employee_id":555}`)},
+	}, nil)
+
+	_, err := uc.RebuildLoanProjection(context.Background())
+
+	assert.ErrorIs(t, err, model.ErrLoanNotInvested)
+	repo.AssertNotCalled(t, "ReplaceLoan", mock.Anything, mock.Anything)
+}
+
+// TestRebuildLoanProjectionPages rebuilds a loan whose events span two pages,
+// and reports the loans without events.
+func TestRebuildLoanProjectionPages(t *testing.T) {
+	repo := new(MockRepository)
+	uc := NewLoanUsecase(repo)
+
+	proposed := func(id int64, loanID int64) *model.LoanEvent {
+		return &model.LoanEvent{ID: id, LoanID: loanID, Type: model.LoanEventProposed, Payload: []byte(`{"borrower_id":123,"principal_amount":"1000000","currency":"IDR"}`)}
+	}
+	approved := func(id int64, loanID int64) *model.LoanEvent {
+		return &model.LoanEvent{ID: id, LoanID: loanID, Type: model.LoanEventApproved, Payload: []byte(`{"employee_id":555}`)}
+	}
+
+	// The loans of the first page are proposed and approved, except for the
+	// last two. One of them is only proposed, the other is approved on the
+	// second page.
+	page := []*model.LoanEvent{}
+	var loanID int64
+	for len(page) < rebuildBatchSize-2 {
+		loanID++
+		page = append(page, proposed(loanID*10, loanID), approved(loanID*10+1, loanID))
+	}
+	loanID++
+	page = append(page, proposed(loanID*10, loanID))
+	loanID++
+	page = append(page, proposed(loanID*10, loanID))
+	assert.Len(t, page, rebuildBatchSize)
+
+	replaced := map[int64]*model.Loan{}
+	repo.On("GetLoanEvents", mock.Anything, int64(0), int64(0), rebuildBatchSize).Return(page, nil)
+	repo.On("GetLoanEvents", mock.Anything, loanID, loanID*10, rebuildBatchSize).Return([]*model.LoanEvent{approved(loanID*10+1, loanID)}, nil)
+	repo.On("ReplaceLoan", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		loan := args.Get(1).(*model.Loan)
+		assert.NotContains(t, replaced, loan.ID)
+		replaced[loan.ID] = loan
+	}).Return(nil)
+	repo.On("GetLoanIDsWithoutEvents", mock.Anything).Return([]int64{1000, 1001}, nil)
+
+	rebuild, err := uc.RebuildLoanProjection(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, &model.LoanRebuild{Rebuilt: int(loanID), WithoutEvents: []int64{1000, 1001}}, rebuild)
+	assert.Len(t, replaced, int(loanID))
+	assert.Equal(t, model.LoanStateApproved, replaced[1].State)
+	assert.Equal(t, model.LoanStateProposed, replaced[loanID-1].State)
+	assert.Equal(t, model.LoanStateApproved, replaced[loanID].State)
+}
@@ -1,8 +1,12 @@
 package usecase
 
 import (
+	"bytes"
 	"context"
 	"database/sql"
+	"encoding/json"
+	"log/slog"
+	"net/http"
 	"reflect"
 	"testing"
 	"time"
@@ -11,6 +15,9 @@ import (
 	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
 )
 
 func TestGetLoans(t *testing.T) {
@@ -19,7 +26,9 @@ func TestGetLoans(t *testing.T) {
 
 	dummyTime, _ := time.Parse("2006-01-02 15:04:05", "2021-01-01 00:00:00")
 
-	repo.On("GetLoans", mock.Anything, 10, 0).Return([]*model.Loan{
+	filter := model.LoanFilter{Sort: model.Sort{Field: model.SortByID}, Limit: 11}
+	repo.On("CountLoans", mock.Anything, filter).Return(2, nil)
+	repo.On("GetLoans", mock.Anything, filter).Return([]*model.Loan{
 		{
 			ID:              1,
 			State:           model.LoanStateApproved,
@@ -45,10 +54,12 @@ func TestGetLoans(t *testing.T) {
 		},
 	}, nil)
 
-	loans, err := uc.GetLoans(context.Background(), 10, 0)
+	page, err := uc.GetLoans(context.Background(), model.LoanFilter{})
 	assert.NoError(t, err)
-	assert.Len(t, loans, 2)
-	assert.True(t, reflect.DeepEqual(loans, []*model.Loan{
+	assert.Equal(t, 2, page.Total)
+	assert.Empty(t, page.NextCursor)
+	assert.Len(t, page.Data, 2)
+	assert.True(t, reflect.DeepEqual(page.Data, []*model.Loan{
 		{
 			ID:              1,
 			State:           model.LoanStateApproved,
@@ -79,11 +90,69 @@ func TestGetLoansReturnEmpty(t *testing.T) {
 	repo := new(MockRepository)
 	uc := NewLoanUsecase(repo)
 
-	repo.On("GetLoans", mock.Anything, 10, 0).Return([]*model.Loan{}, nil)
+	repo.On("GetLoans", mock.Anything, mock.Anything).Return([]*model.Loan{}, nil)
+	repo.On("CountLoans", mock.Anything, mock.Anything).Return(0, nil)
 
-	loans, err := uc.GetLoans(context.Background(), 10, 0)
+	page, err := uc.GetLoans(context.Background(), model.LoanFilter{Limit: 10})
 	assert.NoError(t, err)
-	assert.Len(t, loans, 0)
+	assert.Len(t, page.Data, 0)
+	assert.Equal(t, 0, page.Total)
+	assert.Empty(t, page.NextCursor)
+}
+
+func TestGetLoansReturnsNextCursor(t *testing.T) {
+	repo := new(MockRepository)
+	uc := NewLoanUsecase(repo)
+
+	createdAt := time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC)
+	state := model.LoanStateApproved
+	sort := model.Sort{Field: model.SortByCreatedAt, Desc: true}
+	cursor := &model.Cursor{Sort: "-created_at", Value: "2021-01-03T00:00:00Z", ID: 9}
+
+	filter := model.LoanFilter{State: &state, Sort: sort, Cursor: cursor, Limit: 3}
+	repo.On("GetLoans", mock.Anything, filter).Return([]*model.Loan{
+		{ID: 8, CreatedAt: createdAt},
+		{ID: 5, CreatedAt: createdAt},
+		{ID: 4, CreatedAt: createdAt.Add(-time.Hour)},
+	}, nil)
+	repo.On("CountLoans", mock.Anything, filter).Return(7, nil)
+
+	page, err := uc.GetLoans(context.Background(), model.LoanFilter{State: &state, Sort: sort, Cursor: cursor, Limit: 2})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 7, page.Total)
+	assert.Len(t, page.Data, 2)
+
+	next, err := model.DecodeCursor(page.NextCursor)
+	assert.NoError(t, err)
+	assert.Equal(t, &model.Cursor{Sort: "-created_at", Value: "2021-01-02T03:04:05Z", ID: 5}, next)
+}
+
+func TestGetLoansBoundsPageSize(t *testing.T) {
+	tests := []struct {
+		name  string
+		limit int
+		fetch int
+	}{
+		{name: "default", limit: 0, fetch: model.DefaultPageSize + 1},
+		{name: "maximum", limit: 1000, fetch: model.MaxPageSize + 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := new(MockRepository)
+			uc := NewLoanUsecase(repo)
+
+			filter := model.LoanFilter{Sort: model.Sort{Field: model.SortByID}, Limit: tt.fetch}
+			repo.On("GetLoans", mock.Anything, filter).Return([]*model.Loan{}, nil)
+			repo.On("CountLoans", mock.Anything, filter).Return(0, nil)
+
+			_, err := uc.GetLoans(context.Background(), model.LoanFilter{Limit: tt.limit})
+
+			assert.NoError(t, err)
+			repo.AssertExpectations(t)
+		})
+	}
 }
 
 func TestGetLoansByBorrowerID(t *testing.T) {
@@ -166,17 +235,43 @@ func TestCreateLoan(t *testing.T) {
 	repo.On("GetUserByID", mock.Anything, int64(123)).Return(&model.User{ID: 123}, nil)
 	repo.On("GetLoanProductByID", mock.Anything, int64(100)).Return(&model.LoanProduct{ID: 1, Rate: decimal.NewFromFloat(10.0), ROI: decimal.NewFromFloat(5.5)}, nil)
 	repo.On("CreateLoan", mock.Anything, mock.Anything).Return(int64(1), nil)
+	repo.On("CreateLoanEvent", mock.Anything, mock.Anything).Return(int64(1), nil)
+	repo.On("CreateOutboxMessage", mock.Anything, mock.Anything).Return(int64(1), nil)
 
 	loan, err := uc.CreateLoan(context.Background(), int64(123), int64(100), 1000000)
 
 	assert.NoError(t, err)
 	assert.Equal(t, int64(1), loan.ID)
 	assert.Equal(t, int64(123), loan.BorrowerID)
-	assert.Equal(t, 1000000, loan.PrincipalAmount)
+	assert.Equal(t, int64(1000000), loan.PrincipalAmount)
 	assert.Equal(t, decimal.NewFromFloat(10.0), loan.Rate)
 	assert.Equal(t, decimal.NewFromFloat(5.5), loan.ROI)
 }
 
+func TestCreateLoanLogs(t *testing.T) {
+	repo := new(MockRepository)
+	var buf bytes.Buffer
+	uc := NewLoanUsecase(repo, WithLogger(slog.New(slog.NewJSONHandler(&buf, nil))))
+
+	repo.On("GetUserByID", mock.Anything, int64(123)).Return(&model.User{ID: 123, Name: "Budi"}, nil)
+	repo.On("GetLoanProductByID", mock.Anything, int64(100)).Return(&model.LoanProduct{ID: 100, Currency: "IDR", Rate: decimal.NewFromFloat(10.0), ROI: decimal.NewFromFloat(5.5)}, nil)
+	repo.On("CreateLoan", mock.Anything, mock.Anything).Return(int64(1), nil)
+	repo.On("CreateLoanEvent", mock.Anything, mock.Anything).Return(int64(1), nil)
+	repo.On("CreateOutboxMessage", mock.Anything, mock.Anything).Return(int64(1), nil)
+
+	_, err := uc.CreateLoan(context.Background(), int64(123), int64(100), 1000000)
+	assert.NoError(t, err)
+
+	line := map[string]any{}
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &line))
+	assert.Equal(t, "loan created", line["msg"])
+	assert.Equal(t, float64(1), line["loan_id"])
+	assert.Equal(t, float64(123), line["borrower_id"])
+	assert.Equal(t, float64(100), line["loan_product_id"])
+	assert.Equal(t, "IDR", line["currency"])
+	assert.NotContains(t, buf.String(), "Budi")
+}
+
 func TestCreateLoanUserNotFound(t *testing.T) {
 	repo := new(MockRepository)
 	uc := NewLoanUsecase(repo)
@@ -202,25 +297,82 @@ func TestCreateLoanProductNotFound(t *testing.T) {
 	assert.Nil(t, loan)
 }
 
-func TestApproveLoan(t *testing.T) {
+// startRequestSpan starts the server span of a request. The spans started
+// below it are recorded by the returned recorder once they end.
+func startRequestSpan(name string) (context.Context, trace.Span, *tracetest.SpanRecorder) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	ctx, span := provider.Tracer("test").Start(context.Background(), name, trace.WithSpanKind(trace.SpanKindServer))
+	return ctx, span, recorder
+}
+
+func TestGetLoansIsTraced(t *testing.T) {
 	repo := new(MockRepository)
 	uc := NewLoanUsecase(repo)
 
+	ctx, root, recorder := startRequestSpan("GET /loans")
+
+	var repoSpan trace.Span
+	inUsecaseSpan := mock.MatchedBy(func(ctx context.Context) bool {
+		repoSpan = trace.SpanFromContext(ctx)
+		return repoSpan.SpanContext().IsValid() && repoSpan != root
+	})
+	repo.On("GetLoans", inUsecaseSpan, mock.Anything).Return([]*model.Loan{}, nil)
+	repo.On("CountLoans", inUsecaseSpan, mock.Anything).Return(0, nil)
+
+	_, err := uc.GetLoans(ctx, model.LoanFilter{Limit: 10})
+	assert.NoError(t, err)
+	repo.AssertExpectations(t)
+
+	spans := recorder.Ended()
+	assert.Len(t, spans, 1)
+	assert.Equal(t, "LoanUsecase.GetLoans", spans[0].Name())
+	assert.Equal(t, root.SpanContext().SpanID(), spans[0].Parent().SpanID())
+	assert.Equal(t, repoSpan.SpanContext().SpanID(), spans[0].SpanContext().SpanID())
+}
+
+func TestApproveLoanTracesError(t *testing.T) {
+	repo := new(MockRepository)
+	uc := NewLoanUsecase(repo)
+
+	ctx, _, recorder := startRequestSpan("PATCH /loans/:id/approval")
+
+	repo.On("GetLoanByID", mock.Anything, int64(1)).Return(nil, model.ErrLoanNotFound)
+
+	err := uc.ApproveLoan(ctx, int64(1), int64(555), "https://file.io/123/proof.jpg")
+
+	assert.ErrorIs(t, err, model.ErrLoanNotFound)
+	spans := recorder.Ended()
+	assert.Len(t, spans, 1)
+	assert.Equal(t, "LoanUsecase.ApproveLoan", spans[0].Name())
+	assert.Equal(t, model.ErrLoanNotFound.Error(), spans[0].Status().Description)
+}
+
+func TestApproveLoan(t *testing.T) {
+	repo := new(MockRepository)
+	uc := NewLoanUsecase(repo, WithHTTPClient(http.DefaultClient))
+
 	loan := &model.Loan{ID: 1, State: model.LoanStateProposed}
 
 	repo.On("GetLoanByID", mock.Anything, int64(1)).Return(loan, nil)
+	repo.On("LockLoan", mock.Anything, int64(1)).Return(loan, nil)
 	repo.On("GetEmployeeByID", mock.Anything, int64(555)).Return(&model.Employee{ID: 555}, nil)
 	repo.On("UpdateLoan", mock.Anything, mock.Anything).Return(nil)
+	repo.On("CreateLoanEvent", mock.Anything, mock.Anything).Return(int64(1), nil)
+	repo.On("CreateOutboxMessage", mock.Anything, mock.Anything).Return(int64(1), nil)
+	repo.On("GetLoanDocumentsByChecksum", mock.Anything, mock.Anything).Return([]*model.LoanDocument{}, nil)
+	repo.On("CreateLoanDocument", mock.Anything, mock.Anything).Return(int64(1), nil)
 
-	err := uc.ApproveLoan(context.Background(), int64(1), int64(555), "https://file.io/123/proof.jpg")
+	approvalProof := serveDocument(t, pngHeader)
+	err := uc.ApproveLoan(context.Background(), int64(1), int64(555), approvalProof)
 
 	assert.NoError(t, err)
-	repo.AssertCalled(t, "GetLoanByID", mock.Anything, int64(1))
+	repo.AssertCalled(t, "LockLoan", mock.Anything, int64(1))
 	repo.AssertCalled(t, "GetEmployeeByID", mock.Anything, int64(555))
 	repo.AssertCalled(t, "UpdateLoan", mock.Anything, loan)
 	assert.Equal(t, model.LoanStateApproved, loan.State)
 	assert.Equal(t, int64(555), loan.ApprovedBy.Int64)
-	assert.Equal(t, "https://file.io/123/proof.jpg", loan.ApprovalProof.String)
+	assert.Equal(t, approvalProof, loan.ApprovalProof.String)
 }
 
 func TestApproveLoanNotFound(t *testing.T) {
@@ -258,7 +410,6 @@ func TestApproveLoanNotProposed(t *testing.T) {
 
 	repo.On("GetLoanByID", mock.Anything, int64(1)).Return(loan, nil)
 	repo.On("GetEmployeeByID", mock.Anything, int64(555)).Return(&model.Employee{ID: 555}, nil)
-	repo.On("GetLoanByID", mock.Anything, int64(1)).Return(loan, nil)
 
 	err := uc.ApproveLoan(context.Background(), int64(1), int64(555), "https://file.io/123/proof.jpg")
 
@@ -268,23 +419,36 @@ func TestApproveLoanNotProposed(t *testing.T) {
 
 func TestDisburseLoan(t *testing.T) {
 	repo := new(MockRepository)
-	uc := NewLoanUsecase(repo)
+	uc := NewLoanUsecase(repo, WithHTTPClient(http.DefaultClient))
 
-	loan := &model.Loan{ID: 1, State: model.LoanStateInvested}
+	loan := &model.Loan{ID: 1, PrincipalAmount: 1000000, State: model.LoanStateInvested}
 
+	expectJournalEntries(repo)
 	repo.On("GetLoanByID", mock.Anything, int64(1)).Return(loan, nil)
+	repo.On("LockLoan", mock.Anything, int64(1)).Return(loan, nil)
 	repo.On("GetEmployeeByID", mock.Anything, int64(555)).Return(&model.Employee{ID: 555}, nil)
 	repo.On("UpdateLoan", mock.Anything, mock.Anything).Return(nil)
+	repo.On("CreateLoanEvent", mock.Anything, mock.Anything).Return(int64(1), nil)
+	repo.On("CreateOutboxMessage", mock.Anything, mock.Anything).Return(int64(1), nil)
+	repo.On("GetInvestmentsByLoanID", mock.Anything, int64(1)).Return([]*model.Investment{{ID: 2, LoanID: 1}}, nil)
+	repo.On("GetLoanDocumentsByLoanID", mock.Anything, int64(1)).Return(signedAgreements(1, 2), nil)
+	repo.On("GetLoanDocumentsByChecksum", mock.Anything, mock.Anything).Return([]*model.LoanDocument{}, nil)
+	repo.On("CreateLoanDocument", mock.Anything, mock.Anything).Return(int64(1), nil)
 
-	err := uc.DisburseLoan(context.Background(), int64(1), int64(555), "https://file.io/123/agreement.pdf")
+	agreementLetter := serveDocument(t, []byte("%PDF-1.4"))
+	err := uc.DisburseLoan(context.Background(), int64(1), int64(555), agreementLetter)
 
 	assert.NoError(t, err)
-	repo.AssertCalled(t, "GetLoanByID", mock.Anything, int64(1))
+	repo.AssertCalled(t, "LockLoan", mock.Anything, int64(1))
 	repo.AssertCalled(t, "GetEmployeeByID", mock.Anything, int64(555))
 	repo.AssertCalled(t, "UpdateLoan", mock.Anything, loan)
 	assert.Equal(t, model.LoanStateDisbursed, loan.State)
 	assert.Equal(t, int64(555), loan.DisbursedBy.Int64)
-	assert.Equal(t, "https://file.io/123/agreement.pdf", loan.AgreementLetter.String)
+	assert.Equal(t, agreementLetter, loan.AgreementLetter.String)
+	repo.AssertCalled(t, "CreateLoanDocument", mock.Anything, mock.MatchedBy(func(d *model.LoanDocument) bool {
+		return d.Kind == model.DocumentSignedAgreement && d.ContentType == "application/pdf"
+	}))
+	repo.AssertCalled(t, "CreateJournalEntry", mock.Anything, journalKind(model.JournalDisbursement))
 }
 
 func TestDisburseLoanNotFound(t *testing.T) {
@@ -322,10 +486,129 @@ func TestDisburseLoanNotInvested(t *testing.T) {
 
 	repo.On("GetLoanByID", mock.Anything, int64(1)).Return(loan, nil)
 	repo.On("GetEmployeeByID", mock.Anything, int64(555)).Return(&model.Employee{ID: 555}, nil)
-	repo.On("GetLoanByID", mock.Anything, int64(1)).Return(loan, nil)
 
 	err := uc.DisburseLoan(context.Background(), int64(1), int64(555), "https://file.io/123/agreement.pdf")
 
 	assert.Error(t, err)
 	assert.ErrorIs(t, err, model.ErrLoanNotInvested)
 }
+
+func TestGetLoansForActorEmployee(t *testing.T) {
+	repo := new(MockRepository)
+	uc := NewLoanUsecase(repo)
+
+	filter := model.LoanFilter{Sort: model.Sort{Field: model.SortByID}, Limit: 11}
+	repo.On("GetEmployeeByID", mock.Anything, int64(7)).Return(&model.Employee{ID: 7}, nil)
+	repo.On("GetLoans", mock.Anything, filter).Return([]*model.Loan{{ID: 1, BorrowerID: 123}}, nil)
+	repo.On("CountLoans", mock.Anything, filter).Return(1, nil)
+
+	page, err := uc.GetLoansForActor(context.Background(), model.Actor{Role: model.RoleEmployee, ID: 7}, model.LoanFilter{})
+
+	assert.NoError(t, err)
+	assert.Equal(t, &model.Page[*model.Loan]{Data: []*model.Loan{{ID: 1, BorrowerID: 123}}, Total: 1}, page)
+}
+
+func TestGetLoansForActorBorrowerSeesOwnLoans(t *testing.T) {
+	repo := new(MockRepository)
+	uc := NewLoanUsecase(repo)
+
+	filter := model.LoanFilter{BorrowerID: 123, Sort: model.Sort{Field: model.SortByID}, Limit: 11}
+	repo.On("GetLoans", mock.Anything, filter).Return([]*model.Loan{{ID: 1, BorrowerID: 123}}, nil)
+	repo.On("CountLoans", mock.Anything, filter).Return(1, nil)
+
+	page, err := uc.GetLoansForActor(context.Background(), model.Actor{Role: model.RoleBorrower, ID: 123}, model.LoanFilter{BorrowerID: 456})
+
+	assert.NoError(t, err)
+	assert.Len(t, page.Data, 1)
+	repo.AssertExpectations(t)
+}
+
+func TestGetLoansForActorDenied(t *testing.T) {
+	repo := new(MockRepository)
+	uc := NewLoanUsecase(repo)
+
+	repo.On("GetEmployeeByID", mock.Anything, int64(7)).Return(nil, sql.ErrNoRows)
+
+	_, err := uc.GetLoansForActor(context.Background(), model.Actor{Role: model.RoleEmployee, ID: 7}, model.LoanFilter{})
+	assert.Equal(t, model.ErrLoanAccessDenied, err)
+
+	_, err = uc.GetLoansForActor(context.Background(), model.Actor{Role: model.RoleInvestor, ID: 100}, model.LoanFilter{})
+	assert.Equal(t, model.ErrLoanAccessDenied, err)
+
+	repo.AssertNotCalled(t, "GetLoans", mock.Anything, mock.Anything)
+}
+
+type inTransactionKey struct{}
+
+// lockingRepository fails LockLoan outside of WithTransaction, where the lock
+// would be released right away.
+type lockingRepository struct {
+	*MockRepository
+}
+
+func (r lockingRepository) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(context.WithValue(ctx, inTransactionKey{}, true))
+}
+
+func (r lockingRepository) LockLoan(ctx context.Context, id int64) (*model.Loan, error) {
+	if ctx.Value(inTransactionKey{}) == nil {
+		return nil, sql.ErrConnDone
+	}
+	return r.MockRepository.LockLoan(ctx, id)
+}
+
+func TestLoanChangesLockLoanInTransaction(t *testing.T) {
+	repo := new(MockRepository)
+	store := new(mockBlobStore)
+	uc := NewLoanUsecase(lockingRepository{repo}, WithDocuments(nil, store))
+
+	// The loan is disbursed by a concurrent request once its documents are
+	// verified, so the changes are validated again under lock.
+	repo.On("GetLoanByID", mock.Anything, int64(1)).Return(&model.Loan{ID: 1, State: model.LoanStateProposed}, nil).Once()
+	repo.On("GetLoanByID", mock.Anything, int64(1)).Return(&model.Loan{ID: 1, State: model.LoanStateInvested}, nil).Once()
+	repo.On("LockLoan", mock.Anything, int64(1)).Return(&model.Loan{ID: 1, State: model.LoanStateDisbursed}, nil)
+	repo.On("GetEmployeeByID", mock.Anything, int64(555)).Return(&model.Employee{ID: 555}, nil)
+	repo.On("GetInvestorByID", mock.Anything, int64(100)).Return(&model.Investor{ID: 100}, nil)
+	repo.On("GetInvestmentsByLoanID", mock.Anything, int64(1)).Return([]*model.Investment{}, nil)
+	repo.On("GetLoanDocumentsByLoanID", mock.Anything, int64(1)).Return(signedAgreements(1), nil)
+	store.On("Get", mock.Anything, approvalProofKey(1)).Return(pngHeader, nil)
+	store.On("Get", mock.Anything, agreementLetterKey(1)).Return([]byte("%PDF-1.4"), nil)
+
+	assert.ErrorIs(t, uc.ApproveLoan(context.Background(), 1, 555, approvalProofKey(1)), model.ErrLoanNotProposed)
+	assert.ErrorIs(t, uc.DisburseLoan(context.Background(), 1, 555, agreementLetterKey(1)), model.ErrLoanNotInvested)
+	_, err := uc.CreateInvestment(context.Background(), 100, 1, 500000)
+	assert.ErrorIs(t, err, model.ErrLoanNotApproved)
+	repo.AssertNumberOfCalls(t, "LockLoan", 3)
+	repo.AssertNotCalled(t, "GetLoanDocumentsByChecksum", mock.Anything, mock.Anything)
+}
+
+func TestLoanChangesFetchDocumentsOutsideTransaction(t *testing.T) {
+	repo := new(MockRepository)
+	store := new(mockBlobStore)
+	uc := NewLoanUsecase(lockingRepository{repo}, WithDocuments(nil, store))
+
+	fetchedInTransaction := []bool{}
+	for _, id := range []int64{1, 2, 3} {
+		repo.On("GetLoanByID", mock.Anything, id).Return(&model.Loan{ID: id, State: model.LoanStateProposed}, nil)
+		repo.On("LockLoan", mock.Anything, id).Return(&model.Loan{ID: id, State: model.LoanStateProposed}, nil)
+		store.On("Get", mock.Anything, approvalProofKey(id)).Run(func(args mock.Arguments) {
+			fetchedInTransaction = append(fetchedInTransaction, args.Get(0).(context.Context).Value(inTransactionKey{}) != nil)
+		}).Return(append(append([]byte{}, pngHeader...), byte(id)), nil)
+	}
+	repo.On("GetEmployeeByID", mock.Anything, int64(555)).Return(&model.Employee{ID: 555}, nil)
+	repo.On("GetLoanDocumentsByChecksum", mock.Anything, mock.Anything).Return([]*model.LoanDocument{}, nil)
+	repo.On("UpdateLoan", mock.Anything, mock.Anything).Return(nil)
+	repo.On("CreateLoanDocument", mock.Anything, mock.Anything).Return(int64(1), nil)
+	repo.On("CreateLoanEvent", mock.Anything, mock.Anything).Return(int64(1), nil)
+	repo.On("CreateOutboxMessage", mock.Anything, mock.Anything).Return(int64(1), nil)
+
+	err := uc.ApproveLoan(context.Background(), 1, 555, approvalProofKey(1))
+	assert.NoError(t, err)
+
+	result, err := uc.BulkApproveLoans(context.Background(), 555, bulkApprovals(2, 3), model.BulkOptions{Atomic: true})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, result.Applied)
+
+	assert.Equal(t, []bool{false, false, false}, fetchedInTransaction)
+	repo.AssertNumberOfCalls(t, "GetLoanDocumentsByChecksum", 3)
+}
@@ -0,0 +1,52 @@
+package usecase
+
+import (
+	"context"
+	"time"
+
+	"github.com/aldipi/loan-service/model"
+	"github.com/aldipi/loan-service/tracing"
+)
+
+const defaultFundingPeriod = 30 * 24 * time.Hour
+
+// GetMarketplaceLoans returns a page of the approved loans investors can still
+// invest in, with their funding progress and the time left in their funding
+// period.
+func (u *LoanUsecase) GetMarketplaceLoans(ctx context.Context, filter model.MarketplaceFilter) (page *model.Page[*model.MarketplaceLoan], err error) {
+	ctx, span := tracing.Start(ctx, "LoanUsecase.GetMarketplaceLoans")
+	defer func() { tracing.End(span, err) }()
+
+	if filter.Sort.Field == "" {
+		filter.Sort.Field = model.SortByID
+	}
+	limit := pageSize(filter.Limit)
+	filter.Limit = limit + 1
+	at := now()
+
+	loans, err := u.repo.GetMarketplaceLoans(ctx, filter, at, u.fundingPeriod)
+	if err != nil {
+		return nil, err
+	}
+
+	total, err := u.repo.CountMarketplaceLoans(ctx, at, u.fundingPeriod)
+	if err != nil {
+		return nil, err
+	}
+
+	page = &model.Page[*model.MarketplaceLoan]{Data: loans, Total: total}
+	if len(loans) > limit {
+		page.Data = loans[:limit]
+		last := page.Data[limit-1]
+		page.NextCursor = nextCursor(filter.Sort, marketplaceSortValue(last, filter.Sort.Field), last.ID)
+	}
+
+	return page, nil
+}
+
+// fundingEnded reports whether the funding period of the approved loan is
+// over at the given time, when the marketplace stops offering it. Loans
+// approved before approval times were recorded have no funding period.
+func (u *LoanUsecase) fundingEnded(loan *model.Loan, at time.Time) bool {
+	return loan.ApprovedAt.Valid && !loan.ApprovedAt.Time.Add(u.fundingPeriod).After(at)
+}
@@ -0,0 +1,65 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aldipi/loan-service/model"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestGetMarketplaceLoans(t *testing.T) {
+	repo := new(MockRepository)
+	uc := NewLoanUsecase(repo, WithFundingPeriod(7*24*time.Hour))
+
+	sort := model.Sort{Field: model.SortByFundedPercent, Desc: true}
+	filter := model.MarketplaceFilter{Sort: sort, Limit: 3}
+	repo.On("GetMarketplaceLoans", mock.Anything, filter, mock.Anything, 7*24*time.Hour).Return([]*model.MarketplaceLoan{
+		{ID: 3, FundedPercent: decimal.RequireFromString("90.00")},
+		{ID: 1, FundedPercent: decimal.RequireFromString("42.50")},
+		{ID: 2, FundedPercent: decimal.RequireFromString("10.00")},
+	}, nil)
+	repo.On("CountMarketplaceLoans", mock.Anything, mock.Anything, 7*24*time.Hour).Return(5, nil)
+
+	page, err := uc.GetMarketplaceLoans(context.Background(), model.MarketplaceFilter{Sort: sort, Limit: 2})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 5, page.Total)
+	assert.Len(t, page.Data, 2)
+
+	next, err := model.DecodeCursor(page.NextCursor)
+	assert.NoError(t, err)
+	assert.Equal(t, &model.Cursor{Sort: "-funded_percent", Value: "42.5", ID: 1}, next)
+}
+
+func TestGetMarketplaceLoansLastPage(t *testing.T) {
+	repo := new(MockRepository)
+	uc := NewLoanUsecase(repo)
+
+	filter := model.MarketplaceFilter{Sort: model.Sort{Field: model.SortByID}, Limit: model.DefaultPageSize + 1}
+	repo.On("GetMarketplaceLoans", mock.Anything, filter, mock.Anything, defaultFundingPeriod).Return([]*model.MarketplaceLoan{{ID: 1}}, nil)
+	repo.On("CountMarketplaceLoans", mock.Anything, mock.Anything, defaultFundingPeriod).Return(1, nil)
+
+	page, err := uc.GetMarketplaceLoans(context.Background(), model.MarketplaceFilter{})
+
+	assert.NoError(t, err)
+	assert.Len(t, page.Data, 1)
+	assert.Empty(t, page.NextCursor)
+}
+
+func TestGetMarketplaceLoansError(t *testing.T) {
+	repo := new(MockRepository)
+	uc := NewLoanUsecase(repo)
+
+	repo.On("GetMarketplaceLoans", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return([]*model.MarketplaceLoan(nil), errors.New("database error"))
+
+	page, err := uc.GetMarketplaceLoans(context.Background(), model.MarketplaceFilter{})
+
+	assert.Error(t, err)
+	assert.Nil(t, page)
+	repo.AssertNotCalled(t, "CountMarketplaceLoans", mock.Anything, mock.Anything, mock.Anything)
+}
@@ -0,0 +1,15 @@
+package usecase
+
+import (
+	"github.com/aldipi/loan-service/model"
+)
+
+// Metrics records business metrics of the loans and investments handled by
+// the usecase. Changes are recorded once they are committed.
+type Metrics interface {
+	LoanCreated(loan *model.Loan)
+	LoanApproved(loan *model.Loan)
+	LoanFunded(loan *model.Loan)
+	LoanDisbursed(loan *model.Loan)
+	InvestmentCreated(investment *model.Investment)
+}
@@ -0,0 +1,109 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aldipi/loan-service/model"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type mockMetrics struct {
+	mock.Mock
+}
+
+func (m *mockMetrics) LoanCreated(loan *model.Loan) {
+	m.Called(loan)
+}
+
+func (m *mockMetrics) LoanApproved(loan *model.Loan) {
+	m.Called(loan)
+}
+
+func (m *mockMetrics) LoanFunded(loan *model.Loan) {
+	m.Called(loan)
+}
+
+func (m *mockMetrics) LoanDisbursed(loan *model.Loan) {
+	m.Called(loan)
+}
+
+func (m *mockMetrics) InvestmentCreated(investment *model.Investment) {
+	m.Called(investment)
+}
+
+func TestCreateLoanRecordsMetrics(t *testing.T) {
+	repo := new(MockRepository)
+	metrics := new(mockMetrics)
+	uc := NewLoanUsecase(repo, WithMetrics(metrics))
+
+	repo.On("GetUserByID", mock.Anything, int64(123)).Return(&model.User{ID: 123}, nil)
+	repo.On("GetLoanProductByID", mock.Anything, int64(100)).Return(&model.LoanProduct{ID: 100, Rate: decimal.NewFromFloat(10.0), ROI: decimal.NewFromFloat(5.5)}, nil)
+	repo.On("CreateLoan", mock.Anything, mock.Anything).Return(int64(1), nil)
+	repo.On("CreateLoanEvent", mock.Anything, mock.Anything).Return(int64(1), nil)
+	repo.On("CreateOutboxMessage", mock.Anything, mock.Anything).Return(int64(1), nil)
+	metrics.On("LoanCreated", mock.Anything).Return()
+
+	loan, err := uc.CreateLoan(context.Background(), int64(123), int64(100), 1000000)
+
+	assert.NoError(t, err)
+	metrics.AssertCalled(t, "LoanCreated", loan)
+}
+
+func TestCreateLoanFailureRecordsNoMetrics(t *testing.T) {
+	repo := new(MockRepository)
+	metrics := new(mockMetrics)
+	uc := NewLoanUsecase(repo, WithMetrics(metrics))
+
+	repo.On("GetUserByID", mock.Anything, int64(123)).Return(&model.User{ID: 123}, nil)
+	repo.On("GetLoanProductByID", mock.Anything, int64(100)).Return(&model.LoanProduct{ID: 100, Rate: decimal.NewFromFloat(10.0), ROI: decimal.NewFromFloat(5.5)}, nil)
+	repo.On("CreateLoan", mock.Anything, mock.Anything).Return(int64(0), errors.New("db is down"))
+
+	_, err := uc.CreateLoan(context.Background(), int64(123), int64(100), 1000000)
+
+	assert.Error(t, err)
+	metrics.AssertNotCalled(t, "LoanCreated", mock.Anything)
+}
+
+func TestCreateInvestmentRecordsMetrics(t *testing.T) {
+	repo := new(MockRepository)
+	metrics := new(mockMetrics)
+	uc := NewLoanUsecase(repo, WithMetrics(metrics))
+
+	loan := &model.Loan{ID: 1, PrincipalAmount: 1000000, State: model.LoanStateApproved}
+	investments := []*model.Investment{{ID: 1, InvestorID: 101, LoanID: 1, Amount: 500000, SettlementAmount: 500000}}
+
+	expectFundedWallets(repo)
+	expectJournalEntries(repo)
+	repo.On("GetInvestorByID", mock.Anything, int64(100)).Return(&model.Investor{ID: 100}, nil)
+	repo.On("LockLoan", mock.Anything, int64(1)).Return(loan, nil)
+	repo.On("GetInvestmentsByLoanID", mock.Anything, int64(1)).Return(investments, nil)
+	repo.On("CreateInvestment", mock.Anything, mock.Anything).Return(int64(2), nil)
+	repo.On("UpdateLoan", mock.Anything, loan).Return(nil)
+	repo.On("CreateLoanEvent", mock.Anything, mock.Anything).Return(int64(1), nil)
+	repo.On("CreateOutboxMessage", mock.Anything, mock.Anything).Return(int64(1), nil)
+	metrics.On("InvestmentCreated", mock.Anything).Return()
+	metrics.On("LoanFunded", mock.Anything).Return()
+
+	investment, err := uc.CreateInvestment(context.Background(), 100, 1, 500000)
+
+	assert.NoError(t, err)
+	metrics.AssertCalled(t, "InvestmentCreated", investment)
+	metrics.AssertCalled(t, "LoanFunded", loan)
+}
+
+func TestCountLoansByState(t *testing.T) {
+	repo := new(MockRepository)
+	uc := NewLoanUsecase(repo)
+
+	counts := map[model.LoanState]int{model.LoanStateApproved: 2}
+	repo.On("CountLoansByState", mock.Anything).Return(counts, nil)
+
+	result, err := uc.CountLoansByState(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, counts, result)
+}
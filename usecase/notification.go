@@ -0,0 +1,91 @@
+package usecase
+
+import (
+	"context"
+
+	"github.com/aldipi/loan-service/model"
+)
+
+// Notifier delivers a notification to its recipient.
+type Notifier interface {
+	Notify(ctx context.Context, notification *model.Notification) error
+}
+
+// notifyLoan notifies the borrower and every investor of the loan in the
+// background. It must only be called after the loan change is committed.
+func (u *LoanUsecase) notifyLoan(ctx context.Context, loan model.Loan, borrowerEvent model.NotificationEvent, investorEvent model.NotificationEvent) {
+	if u.notifier == nil {
+		return
+	}
+
+	ctx = context.WithoutCancel(ctx)
+
+	u.background.Add(1)
+	go func() {
+		defer u.background.Done()
+
+		err := u.sendLoanNotifications(ctx, loan, borrowerEvent, investorEvent)
+		if err != nil {
+			u.logger.ErrorContext(ctx, "notify loan", "loan_id", loan.ID, "error", err)
+		}
+	}()
+}
+
+func (u *LoanUsecase) sendLoanNotifications(ctx context.Context, loan model.Loan, borrowerEvent model.NotificationEvent, investorEvent model.NotificationEvent) error {
+	borrower, err := u.repo.GetUserByID(ctx, loan.BorrowerID)
+	if err != nil {
+		return err
+	}
+
+	if borrower.Email.Valid {
+		err = u.notifier.Notify(ctx, &model.Notification{
+			Event:  borrowerEvent,
+			Locale: borrower.Locale,
+			Name:   borrower.Name,
+			Email:  borrower.Email.String,
+			Loan:   loan,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	investments, err := u.repo.GetInvestmentsByLoanID(ctx, loan.ID)
+	if err != nil {
+		return err
+	}
+
+	investorIDs := []int64{}
+	investedAmounts := map[int64]int64{}
+	for _, investment := range investments {
+		if _, ok := investedAmounts[investment.InvestorID]; !ok {
+			investorIDs = append(investorIDs, investment.InvestorID)
+		}
+		investedAmounts[investment.InvestorID] += investment.Amount
+	}
+
+	for _, investorID := range investorIDs {
+		investor, err := u.repo.GetInvestorByID(ctx, investorID)
+		if err != nil {
+			return err
+		}
+
+		if !investor.Email.Valid {
+			continue
+		}
+
+		err = u.notifier.Notify(ctx, &model.Notification{
+			Event:          investorEvent,
+			Locale:         investor.Locale,
+			Name:           investor.Name,
+			Email:          investor.Email.String,
+			Loan:           loan,
+			InvestedAmount: investedAmounts[investorID],
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
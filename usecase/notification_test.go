@@ -0,0 +1,136 @@
+package usecase
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/aldipi/loan-service/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type mockNotifier struct {
+	mock.Mock
+}
+
+func (m *mockNotifier) Notify(ctx context.Context, notification *model.Notification) error {
+	args := m.Called(ctx, notification)
+	return args.Error(0)
+}
+
+func TestCreateInvestmentNotifiesWhenFullyFunded(t *testing.T) {
+	repo := new(MockRepository)
+	notifier := new(mockNotifier)
+	uc := NewLoanUsecase(repo, WithNotifier(notifier))
+
+	loan := &model.Loan{ID: 1, BorrowerID: 123, PrincipalAmount: 1000000, State: model.LoanStateApproved}
+	investments := []*model.Investment{
+		{ID: 1, InvestorID: 101, LoanID: 1, Amount: 200000},
+		{ID: 2, InvestorID: 101, LoanID: 1, Amount: 300000},
+		{ID: 3, InvestorID: 102, LoanID: 1, Amount: 500000},
+	}
+
+	expectFundedWallets(repo)
+	expectJournalEntries(repo)
+	repo.On("GetInvestorByID", mock.Anything, int64(102)).Return(&model.Investor{ID: 102, Name: "Investor B", Locale: "en"}, nil)
+	repo.On("GetInvestorByID", mock.Anything, int64(101)).Return(&model.Investor{ID: 101, Name: "Investor A", Email: sql.NullString{String: "a@example.com", Valid: true}, Locale: "id"}, nil)
+	repo.On("LockLoan", mock.Anything, int64(1)).Return(loan, nil)
+	repo.On("GetInvestmentsByLoanID", mock.Anything, int64(1)).Return(investments[:2], nil).Once()
+	repo.On("GetInvestmentsByLoanID", mock.Anything, int64(1)).Return(investments, nil).Once()
+	repo.On("CreateInvestment", mock.Anything, mock.Anything).Return(int64(3), nil)
+	repo.On("UpdateLoan", mock.Anything, loan).Return(nil)
+	repo.On("CreateLoanEvent", mock.Anything, mock.Anything).Return(int64(1), nil)
+	repo.On("CreateOutboxMessage", mock.Anything, mock.Anything).Return(int64(1), nil)
+	repo.On("GetUserByID", mock.Anything, int64(123)).Return(&model.User{ID: 123, Name: "John Doe", Email: sql.NullString{String: "john@example.com", Valid: true}, Locale: "en"}, nil)
+	notifier.On("Notify", mock.Anything, mock.Anything).Return(nil)
+
+	_, err := uc.CreateInvestment(context.Background(), 102, 1, 500000)
+	uc.Wait()
+
+	assert.NoError(t, err)
+	notifier.AssertNumberOfCalls(t, "Notify", 2)
+	notifier.AssertCalled(t, "Notify", mock.Anything, mock.MatchedBy(func(n *model.Notification) bool {
+		return n.Event == model.NotificationBorrowerLoanInvested && n.Email == "john@example.com" && n.Loan.State == model.LoanStateInvested
+	}))
+	notifier.AssertCalled(t, "Notify", mock.Anything, mock.MatchedBy(func(n *model.Notification) bool {
+		return n.Event == model.NotificationInvestorLoanInvested && n.Email == "a@example.com" && n.Locale == "id" && n.InvestedAmount == 500000
+	}))
+}
+
+func TestCreateInvestmentDoesNotNotifyWhenPartiallyFunded(t *testing.T) {
+	repo := new(MockRepository)
+	notifier := new(mockNotifier)
+	uc := NewLoanUsecase(repo, WithNotifier(notifier))
+
+	loan := &model.Loan{ID: 1, BorrowerID: 123, PrincipalAmount: 1000000, State: model.LoanStateApproved}
+
+	expectFundedWallets(repo)
+	expectJournalEntries(repo)
+	repo.On("GetInvestorByID", mock.Anything, int64(101)).Return(&model.Investor{ID: 101}, nil)
+	repo.On("LockLoan", mock.Anything, int64(1)).Return(loan, nil)
+	repo.On("GetInvestmentsByLoanID", mock.Anything, int64(1)).Return([]*model.Investment{}, nil)
+	repo.On("CreateInvestment", mock.Anything, mock.Anything).Return(int64(1), nil)
+	repo.On("CreateLoanEvent", mock.Anything, mock.Anything).Return(int64(1), nil)
+	repo.On("CreateOutboxMessage", mock.Anything, mock.Anything).Return(int64(1), nil)
+
+	_, err := uc.CreateInvestment(context.Background(), 101, 1, 500000)
+	uc.Wait()
+
+	assert.NoError(t, err)
+	notifier.AssertNotCalled(t, "Notify", mock.Anything, mock.Anything)
+}
+
+func TestDisburseLoanDoesNotNotifyWhenCommitFails(t *testing.T) {
+	repo := new(MockRepository)
+	notifier := new(mockNotifier)
+	uc := NewLoanUsecase(repo, WithNotifier(notifier), WithHTTPClient(http.DefaultClient))
+
+	loan := &model.Loan{ID: 1, BorrowerID: 123, PrincipalAmount: 1000000, State: model.LoanStateInvested}
+
+	repo.On("GetLoanByID", mock.Anything, int64(1)).Return(loan, nil)
+	repo.On("LockLoan", mock.Anything, int64(1)).Return(loan, nil)
+	repo.On("GetEmployeeByID", mock.Anything, int64(555)).Return(&model.Employee{ID: 555}, nil)
+	repo.On("GetInvestmentsByLoanID", mock.Anything, int64(1)).Return([]*model.Investment{}, nil)
+	repo.On("GetLoanDocumentsByLoanID", mock.Anything, int64(1)).Return(signedAgreements(1), nil)
+	repo.On("GetLoanDocumentsByChecksum", mock.Anything, mock.Anything).Return([]*model.LoanDocument{}, nil)
+	repo.On("UpdateLoan", mock.Anything, loan).Return(errors.New("deadlock"))
+
+	err := uc.DisburseLoan(context.Background(), 1, 555, serveDocument(t, []byte("%PDF-1.4")))
+	uc.Wait()
+
+	assert.Error(t, err)
+	notifier.AssertNotCalled(t, "Notify", mock.Anything, mock.Anything)
+}
+
+func TestDisburseLoanNotifies(t *testing.T) {
+	repo := new(MockRepository)
+	notifier := new(mockNotifier)
+	uc := NewLoanUsecase(repo, WithNotifier(notifier), WithHTTPClient(http.DefaultClient))
+
+	loan := &model.Loan{ID: 1, BorrowerID: 123, PrincipalAmount: 1000000, State: model.LoanStateInvested}
+
+	expectJournalEntries(repo)
+	repo.On("GetLoanByID", mock.Anything, int64(1)).Return(loan, nil)
+	repo.On("LockLoan", mock.Anything, int64(1)).Return(loan, nil)
+	repo.On("GetEmployeeByID", mock.Anything, int64(555)).Return(&model.Employee{ID: 555}, nil)
+	repo.On("UpdateLoan", mock.Anything, loan).Return(nil)
+	repo.On("CreateLoanEvent", mock.Anything, mock.Anything).Return(int64(1), nil)
+	repo.On("CreateOutboxMessage", mock.Anything, mock.Anything).Return(int64(1), nil)
+	repo.On("GetUserByID", mock.Anything, int64(123)).Return(&model.User{ID: 123, Email: sql.NullString{String: "john@example.com", Valid: true}}, nil)
+	repo.On("GetInvestmentsByLoanID", mock.Anything, int64(1)).Return([]*model.Investment{}, nil)
+	repo.On("GetLoanDocumentsByLoanID", mock.Anything, int64(1)).Return(signedAgreements(1), nil)
+	repo.On("GetLoanDocumentsByChecksum", mock.Anything, mock.Anything).Return([]*model.LoanDocument{}, nil)
+	repo.On("CreateLoanDocument", mock.Anything, mock.Anything).Return(int64(1), nil)
+	notifier.On("Notify", mock.Anything, mock.Anything).Return(nil)
+
+	err := uc.DisburseLoan(context.Background(), 1, 555, serveDocument(t, []byte("%PDF-1.4")))
+	uc.Wait()
+
+	assert.NoError(t, err)
+	notifier.AssertCalled(t, "Notify", mock.Anything, mock.MatchedBy(func(n *model.Notification) bool {
+		return n.Event == model.NotificationBorrowerLoanDisbursed && n.Loan.State == model.LoanStateDisbursed
+	}))
+}
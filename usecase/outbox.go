@@ -0,0 +1,127 @@
+package usecase
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/aldipi/loan-service/model"
+)
+
+const (
+	defaultOutboxInterval   = time.Second
+	defaultOutboxBatchSize  = 100
+	defaultOutboxMinBackoff = time.Second
+	defaultOutboxMaxBackoff = time.Hour
+)
+
+// EventPublisher delivers outbox messages to downstream services. Publish may
+// be called more than once for the same message, so consumers must be
+// idempotent on the message ID.
+type EventPublisher interface {
+	Publish(ctx context.Context, message *model.OutboxMessage) error
+}
+
+// OutboxRelay polls the outbox table and publishes pending messages. A message
+// that fails to publish is retried with exponential backoff until it succeeds.
+type OutboxRelay struct {
+	repo      Repository
+	publisher EventPublisher
+	status    workerStatus
+
+	Interval   time.Duration
+	BatchSize  int
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	Logger     *slog.Logger
+}
+
+func NewOutboxRelay(repo Repository, publisher EventPublisher) *OutboxRelay {
+	return &OutboxRelay{
+		repo:       repo,
+		publisher:  publisher,
+		Interval:   defaultOutboxInterval,
+		BatchSize:  defaultOutboxBatchSize,
+		MinBackoff: defaultOutboxMinBackoff,
+		MaxBackoff: defaultOutboxMaxBackoff,
+		Logger:     slog.Default(),
+	}
+}
+
+// Run relays pending messages every Interval until ctx is cancelled. Errors
+// are logged and the batch is retried on the next tick.
+func (r *OutboxRelay) Run(ctx context.Context) {
+	r.status.setRunning(true)
+	defer r.status.setRunning(false)
+
+	ticker := time.NewTicker(r.Interval)
+	defer ticker.Stop()
+
+	for {
+		_, err := r.RelayPending(ctx)
+		if ctx.Err() == nil {
+			r.status.record(err)
+			if err != nil {
+				r.Logger.ErrorContext(ctx, "relay outbox messages", "error", err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Check returns an error unless the relay is running and its last batch was
+// relayed. Messages that failed to publish do not fail the batch.
+func (r *OutboxRelay) Check(ctx context.Context) error {
+	return r.status.check()
+}
+
+// RelayPending publishes one batch of due messages and returns how many were
+// published successfully.
+func (r *OutboxRelay) RelayPending(ctx context.Context) (int, error) {
+	messages, err := r.repo.GetPendingOutboxMessages(ctx, now(), r.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	var published int
+	for _, message := range messages {
+		err = r.publisher.Publish(ctx, message)
+		if err != nil {
+			r.Logger.WarnContext(ctx, "publish outbox message", "message_id", message.ID, "attempts", message.Attempts+1, "error", err)
+			nextAttemptAt := now().Add(backoff(message.Attempts, r.MinBackoff, r.MaxBackoff))
+			err = r.repo.MarkOutboxMessageFailed(ctx, message.ID, nextAttemptAt, err.Error())
+			if err != nil {
+				return published, err
+			}
+			continue
+		}
+
+		err = r.repo.MarkOutboxMessagePublished(ctx, message.ID, now())
+		if err != nil {
+			return published, err
+		}
+		published++
+	}
+
+	return published, nil
+}
+
+// backoff returns the delay before the next attempt of a delivery that already
+// failed the given number of times, doubling from min and capped at max.
+func backoff(attempts int, min time.Duration, max time.Duration) time.Duration {
+	delay := min
+	for i := 0; i < attempts && delay < max; i++ {
+		delay *= 2
+	}
+
+	if delay > max {
+		return max
+	}
+
+	return delay
+}
@@ -0,0 +1,116 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/aldipi/loan-service/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type mockPublisher struct {
+	mock.Mock
+}
+
+func (m *mockPublisher) Publish(ctx context.Context, message *model.OutboxMessage) error {
+	args := m.Called(ctx, message)
+	return args.Error(0)
+}
+
+func TestRelayPending(t *testing.T) {
+	repo := new(MockRepository)
+	publisher := new(mockPublisher)
+	relay := NewOutboxRelay(repo, publisher)
+
+	delivered := &model.OutboxMessage{ID: 1, EventType: model.LoanEventApproved, LoanID: 1}
+	failed := &model.OutboxMessage{ID: 2, EventType: model.LoanEventDisbursed, LoanID: 2, Attempts: 3}
+
+	repo.On("GetPendingOutboxMessages", mock.Anything, mock.Anything, defaultOutboxBatchSize).Return([]*model.OutboxMessage{delivered, failed}, nil)
+	repo.On("MarkOutboxMessagePublished", mock.Anything, int64(1), mock.Anything).Return(nil)
+	repo.On("MarkOutboxMessageFailed", mock.Anything, int64(2), mock.Anything, "broker unavailable").Return(nil)
+	publisher.On("Publish", mock.Anything, delivered).Return(nil)
+	publisher.On("Publish", mock.Anything, failed).Return(errors.New("broker unavailable"))
+
+	before := now()
+	published, err := relay.RelayPending(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, published)
+	repo.AssertCalled(t, "MarkOutboxMessageFailed", mock.Anything, int64(2), mock.MatchedBy(func(next time.Time) bool {
+		return !next.Before(before.Add(8 * time.Second))
+	}), "broker unavailable")
+}
+
+func TestRelayPendingRepositoryError(t *testing.T) {
+	repo := new(MockRepository)
+	publisher := new(mockPublisher)
+	relay := NewOutboxRelay(repo, publisher)
+
+	repo.On("GetPendingOutboxMessages", mock.Anything, mock.Anything, defaultOutboxBatchSize).Return([]*model.OutboxMessage{}, errors.New("connection refused"))
+
+	_, err := relay.RelayPending(context.Background())
+
+	assert.Error(t, err)
+	publisher.AssertNotCalled(t, "Publish", mock.Anything, mock.Anything)
+}
+
+func TestOutboxRelayCheck(t *testing.T) {
+	repo := new(MockRepository)
+	relay := NewOutboxRelay(repo, new(mockPublisher))
+	relay.Interval = 10 * time.Millisecond
+
+	assert.ErrorIs(t, relay.Check(context.Background()), errWorkerNotRunning)
+
+	repo.On("GetPendingOutboxMessages", mock.Anything, mock.Anything, defaultOutboxBatchSize).Return([]*model.OutboxMessage{}, errors.New("connection refused"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		relay.Run(ctx)
+		close(done)
+	}()
+
+	assert.Eventually(t, func() bool {
+		err := relay.Check(context.Background())
+		return err != nil && !errors.Is(err, errWorkerNotRunning)
+	}, time.Second, 5*time.Millisecond)
+	assert.ErrorContains(t, relay.Check(context.Background()), "connection refused")
+
+	cancel()
+	<-done
+	assert.ErrorIs(t, relay.Check(context.Background()), errWorkerNotRunning)
+}
+
+func TestBackoff(t *testing.T) {
+	assert.Equal(t, time.Second, backoff(0, time.Second, time.Hour))
+	assert.Equal(t, 2*time.Second, backoff(1, time.Second, time.Hour))
+	assert.Equal(t, 16*time.Second, backoff(4, time.Second, time.Hour))
+	assert.Equal(t, time.Hour, backoff(100, time.Second, time.Hour))
+}
+
+func TestSaveLoanEventsWritesOutbox(t *testing.T) {
+	repo := new(MockRepository)
+	uc := NewLoanUsecase(repo, WithHTTPClient(http.DefaultClient))
+
+	loan := &model.Loan{ID: 1, State: model.LoanStateProposed}
+
+	repo.On("GetLoanByID", mock.Anything, int64(1)).Return(loan, nil)
+	repo.On("LockLoan", mock.Anything, int64(1)).Return(loan, nil)
+	repo.On("GetEmployeeByID", mock.Anything, int64(555)).Return(&model.Employee{ID: 555}, nil)
+	repo.On("UpdateLoan", mock.Anything, loan).Return(nil)
+	repo.On("CreateLoanEvent", mock.Anything, mock.Anything).Return(int64(1), nil)
+	repo.On("CreateOutboxMessage", mock.Anything, mock.Anything).Return(int64(1), nil)
+	repo.On("GetLoanDocumentsByChecksum", mock.Anything, mock.Anything).Return([]*model.LoanDocument{}, nil)
+	repo.On("CreateLoanDocument", mock.Anything, mock.Anything).Return(int64(1), nil)
+
+	err := uc.ApproveLoan(context.Background(), 1, 555, serveDocument(t, pngHeader))
+
+	assert.NoError(t, err)
+	repo.AssertCalled(t, "CreateOutboxMessage", mock.Anything, mock.MatchedBy(func(message *model.OutboxMessage) bool {
+		return message.EventType == model.LoanEventApproved && message.LoanID == 1 && len(message.Payload) > 0
+	}))
+}
@@ -0,0 +1,125 @@
+package usecase
+
+import (
+	"context"
+	"sort"
+
+	"github.com/aldipi/loan-service/model"
+	"github.com/aldipi/loan-service/tracing"
+	"github.com/shopspring/decimal"
+)
+
+var hundred = decimal.NewFromInt(100)
+
+// GetPortfolio summarises the investments of the investor. Money is added up
+// with decimals, and only the averages and shares are rounded, to two places.
+// Realised payouts are the payouts credited to the wallet of the investor.
+// Amounts are converted at current FX rates to minor units of the reporting
+// currency, the currency of the investor when it is empty.
+func (u *LoanUsecase) GetPortfolio(ctx context.Context, investorID int64, currency model.Currency) (portfolio *model.Portfolio, err error) {
+	ctx, span := tracing.Start(ctx, "LoanUsecase.GetPortfolio")
+	defer func() { tracing.End(span, err) }()
+
+	investor, err := u.repo.GetInvestorByID(ctx, investorID)
+	if err != nil {
+		return nil, model.ErrInvestorNotFound
+	}
+
+	if currency == "" {
+		currency = investor.Currency
+	}
+	if !currency.Valid() {
+		return nil, model.ErrCurrencyInvalid
+	}
+	converter := u.newConverter(currency)
+
+	holdings, err := u.repo.GetHoldingsByInvestorID(ctx, investorID)
+	if err != nil {
+		return nil, err
+	}
+
+	payouts, err := u.repo.SumWalletEntries(ctx, investorID, model.WalletPayout)
+	if err != nil {
+		return nil, err
+	}
+
+	payouts, err = converter.convert(ctx, payouts, investor.Currency)
+	if err != nil {
+		return nil, err
+	}
+
+	portfolio = &model.Portfolio{
+		InvestorID:      investorID,
+		Currency:        currency,
+		InvestmentCount: len(holdings),
+		RealisedPayouts: decimal.NewFromInt(payouts),
+		ByState:         []*model.PortfolioStateTotal{},
+	}
+
+	states := map[model.LoanState]*model.PortfolioStateTotal{}
+	borrowers := map[int64]decimal.Decimal{}
+	products := map[int64]decimal.Decimal{}
+	weightedROI := decimal.Zero
+	for _, holding := range holdings {
+		converted, err := converter.convert(ctx, holding.Amount, holding.Currency)
+		if err != nil {
+			return nil, err
+		}
+		amount := decimal.NewFromInt(converted)
+
+		portfolio.TotalInvested = portfolio.TotalInvested.Add(amount)
+		if holding.LoanState == model.LoanStateDisbursed {
+			portfolio.DeployedCapital = portfolio.DeployedCapital.Add(amount)
+		} else {
+			portfolio.CommittedCapital = portfolio.CommittedCapital.Add(amount)
+		}
+		portfolio.ExpectedReturn = portfolio.ExpectedReturn.Add(amount.Mul(holding.ROI).Div(hundred))
+		weightedROI = weightedROI.Add(amount.Mul(holding.ROI))
+
+		total, ok := states[holding.LoanState]
+		if !ok {
+			total = &model.PortfolioStateTotal{State: holding.LoanState}
+			states[holding.LoanState] = total
+			portfolio.ByState = append(portfolio.ByState, total)
+		}
+		total.InvestmentCount++
+		total.Amount = total.Amount.Add(amount)
+
+		borrowers[holding.BorrowerID] = borrowers[holding.BorrowerID].Add(amount)
+		products[holding.LoanProductID.Int64] = products[holding.LoanProductID.Int64].Add(amount)
+	}
+
+	sort.Slice(portfolio.ByState, func(i, j int) bool {
+		return portfolio.ByState[i].State < portfolio.ByState[j].State
+	})
+
+	if !portfolio.TotalInvested.IsZero() {
+		portfolio.WeightedAverageROI = weightedROI.Div(portfolio.TotalInvested).Round(2)
+	}
+	portfolio.ByBorrower = concentrations(borrowers, portfolio.TotalInvested)
+	portfolio.ByProduct = concentrations(products, portfolio.TotalInvested)
+
+	return portfolio, nil
+}
+
+// concentrations lists the amounts by ID with their share of the total, the
+// largest first.
+func concentrations(amounts map[int64]decimal.Decimal, total decimal.Decimal) []*model.PortfolioConcentration {
+	result := []*model.PortfolioConcentration{}
+	for id, amount := range amounts {
+		result = append(result, &model.PortfolioConcentration{
+			ID:     id,
+			Amount: amount,
+			Share:  amount.Mul(hundred).Div(total).Round(2),
+		})
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if !result[i].Amount.Equal(result[j].Amount) {
+			return result[i].Amount.GreaterThan(result[j].Amount)
+		}
+		return result[i].ID < result[j].ID
+	})
+
+	return result
+}
@@ -0,0 +1,101 @@
+package usecase
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/aldipi/loan-service/model"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestGetPortfolio(t *testing.T) {
+	repo := new(MockRepository)
+	uc := NewLoanUsecase(repo)
+
+	repo.On("GetInvestorByID", mock.Anything, int64(100)).Return(&model.Investor{ID: 100, Currency: "IDR"}, nil)
+	repo.On("GetHoldingsByInvestorID", mock.Anything, int64(100)).Return([]*model.Holding{
+		{InvestmentID: 1, LoanID: 10, Amount: 200000, Currency: "IDR", LoanState: model.LoanStateDisbursed, BorrowerID: 123, LoanProductID: sql.NullInt64{Int64: 7, Valid: true}, ROI: decimal.RequireFromString("5.5")},
+		{InvestmentID: 2, LoanID: 11, Amount: 300000, Currency: "IDR", LoanState: model.LoanStateApproved, BorrowerID: 456, LoanProductID: sql.NullInt64{Int64: 7, Valid: true}, ROI: decimal.RequireFromString("3")},
+		{InvestmentID: 3, LoanID: 12, Amount: 100000, Currency: "IDR", LoanState: model.LoanStateApproved, BorrowerID: 123, ROI: decimal.RequireFromString("4.25")},
+	}, nil)
+	repo.On("SumWalletEntries", mock.Anything, int64(100), model.WalletPayout).Return(int64(15000), nil)
+
+	portfolio, err := uc.GetPortfolio(context.Background(), 100, "")
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(100), portfolio.InvestorID)
+	assert.Equal(t, model.Currency("IDR"), portfolio.Currency)
+	assert.Equal(t, 3, portfolio.InvestmentCount)
+	assert.Equal(t, "600000", portfolio.TotalInvested.String())
+	assert.Equal(t, "400000", portfolio.CommittedCapital.String())
+	assert.Equal(t, "200000", portfolio.DeployedCapital.String())
+	// 200000 * 5.5% + 300000 * 3% + 100000 * 4.25%
+	assert.Equal(t, "24250", portfolio.ExpectedReturn.String())
+	assert.Equal(t, "15000", portfolio.RealisedPayouts.String())
+	// 24250 / 600000
+	assert.Equal(t, "4.04", portfolio.WeightedAverageROI.String())
+
+	assert.Len(t, portfolio.ByState, 2)
+	assert.Equal(t, model.LoanStateApproved, portfolio.ByState[0].State)
+	assert.Equal(t, 2, portfolio.ByState[0].InvestmentCount)
+	assert.Equal(t, "400000", portfolio.ByState[0].Amount.String())
+	assert.Equal(t, model.LoanStateDisbursed, portfolio.ByState[1].State)
+
+	assert.Len(t, portfolio.ByBorrower, 2)
+	assert.Equal(t, int64(123), portfolio.ByBorrower[0].ID)
+	assert.Equal(t, "300000", portfolio.ByBorrower[0].Amount.String())
+	assert.Equal(t, "50", portfolio.ByBorrower[0].Share.String())
+	assert.Equal(t, int64(456), portfolio.ByBorrower[1].ID)
+
+	assert.Len(t, portfolio.ByProduct, 2)
+	assert.Equal(t, int64(7), portfolio.ByProduct[0].ID)
+	assert.Equal(t, "83.33", portfolio.ByProduct[0].Share.String())
+	assert.Equal(t, int64(0), portfolio.ByProduct[1].ID)
+	assert.Equal(t, "16.67", portfolio.ByProduct[1].Share.String())
+}
+
+func TestGetPortfolioWithoutInvestments(t *testing.T) {
+	repo := new(MockRepository)
+	uc := NewLoanUsecase(repo)
+
+	repo.On("GetInvestorByID", mock.Anything, int64(100)).Return(&model.Investor{ID: 100, Currency: "IDR"}, nil)
+	repo.On("GetHoldingsByInvestorID", mock.Anything, int64(100)).Return([]*model.Holding{}, nil)
+	repo.On("SumWalletEntries", mock.Anything, int64(100), model.WalletPayout).Return(int64(0), nil)
+
+	portfolio, err := uc.GetPortfolio(context.Background(), 100, "")
+
+	assert.NoError(t, err)
+	assert.Equal(t, 0, portfolio.InvestmentCount)
+	assert.True(t, portfolio.TotalInvested.IsZero())
+	assert.True(t, portfolio.WeightedAverageROI.IsZero())
+	assert.Empty(t, portfolio.ByState)
+	assert.Empty(t, portfolio.ByBorrower)
+	assert.Empty(t, portfolio.ByProduct)
+}
+
+func TestGetPortfolioInvestorNotFound(t *testing.T) {
+	repo := new(MockRepository)
+	uc := NewLoanUsecase(repo)
+
+	repo.On("GetInvestorByID", mock.Anything, int64(100)).Return(nil, errors.New("not found"))
+
+	_, err := uc.GetPortfolio(context.Background(), 100, "")
+
+	assert.ErrorIs(t, err, model.ErrInvestorNotFound)
+	repo.AssertNotCalled(t, "GetHoldingsByInvestorID", mock.Anything, mock.Anything)
+}
+
+func TestGetPortfolioInvalidCurrency(t *testing.T) {
+	repo := new(MockRepository)
+	uc := NewLoanUsecase(repo)
+
+	repo.On("GetInvestorByID", mock.Anything, int64(100)).Return(&model.Investor{ID: 100, Currency: "IDR"}, nil)
+
+	_, err := uc.GetPortfolio(context.Background(), 100, "XYZ")
+
+	assert.ErrorIs(t, err, model.ErrCurrencyInvalid)
+}
@@ -0,0 +1,241 @@
+package usecase
+
+import (
+	"context"
+	"database/sql"
+	"math/big"
+
+	"github.com/aldipi/loan-service/model"
+	"github.com/aldipi/loan-service/tracing"
+	"github.com/shopspring/decimal"
+)
+
+// RepayLoan records a repayment of a disbursed loan, collected from the
+// borrower by an employee, and pays the share of every investor out to their
+// wallet: the capital they deployed goes back to their available balance, and
+// the interest they earned is paid out on top of it.
+func (u *LoanUsecase) RepayLoan(ctx context.Context, loanID int64, employeeID int64, amount int64) (repayment *model.Repayment, err error) {
+	ctx, span := tracing.Start(ctx, "LoanUsecase.RepayLoan")
+	defer func() { tracing.End(span, err) }()
+
+	if amount <= 0 {
+		return nil, model.ErrRepaymentInvalidAmount
+	}
+
+	employee, err := u.repo.GetEmployeeByID(ctx, employeeID)
+	if err != nil {
+		return nil, model.ErrEmployeeNotFound
+	}
+
+	err = u.repo.WithTransaction(ctx, func(ctx context.Context) error {
+		loan, err := u.repo.LockLoan(ctx, loanID)
+		if err != nil {
+			return model.ErrLoanNotFound
+		}
+
+		if loan.State != model.LoanStateDisbursed {
+			return model.ErrLoanNotDisbursed
+		}
+
+		paid, err := u.repo.SumRepaymentsByLoanID(ctx, loan.ID)
+		if err != nil {
+			return err
+		}
+
+		split := newRepaymentSplit(loan)
+		if paid+amount > split.due {
+			return model.ErrRepaymentExceedsDue
+		}
+
+		investments, err := u.repo.GetInvestmentsByLoanID(ctx, loan.ID)
+		if err != nil {
+			return err
+		}
+
+		repayment = split.repayment(paid, amount)
+		repayment.RecordedBy = employee.ID
+		repayment.CreatedAt = now()
+		repayment.ID, err = u.repo.CreateRepayment(ctx, repayment)
+		if err != nil {
+			return err
+		}
+
+		err = u.postJournal(ctx, repaymentJournal(repayment))
+		if err != nil {
+			return err
+		}
+
+		for _, payout := range split.payouts(investments, paid, amount) {
+			err = u.payOut(ctx, loan, payout)
+			if err != nil {
+				return err
+			}
+		}
+
+		repayment.RemainingDue = split.due - paid - amount
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	u.logger.InfoContext(ctx, "loan repaid",
+		"loan_id", repayment.LoanID,
+		"employee_id", employee.ID,
+		"amount", repayment.Amount,
+		"remaining_due", repayment.RemainingDue,
+	)
+
+	return repayment, nil
+}
+
+// payOut posts the payout of an investment and moves its funds in the wallet
+// of the investor.
+func (u *LoanUsecase) payOut(ctx context.Context, loan *model.Loan, payout *investorPayout) error {
+	for _, journal := range payoutJournals(loan, payout) {
+		err := u.postJournal(ctx, journal)
+		if err != nil {
+			return err
+		}
+	}
+
+	investment := payout.investment
+	entries := []struct {
+		entryType model.WalletEntryType
+		amount    int64
+	}{
+		{model.WalletRepayment, payout.settlementPrincipal},
+		{model.WalletPayout, payout.settlementInterest},
+	}
+	for _, e := range entries {
+		if e.amount == 0 {
+			continue
+		}
+
+		err := u.postWalletEntry(ctx, &model.WalletEntry{
+			InvestorID:   investment.InvestorID,
+			Type:         e.entryType,
+			Amount:       e.amount,
+			Currency:     investment.SettlementCurrency,
+			LoanID:       sql.NullInt64{Int64: investment.LoanID, Valid: true},
+			InvestmentID: sql.NullInt64{Int64: investment.ID, Valid: true},
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// repaymentSplit divides the repayments of a loan into principal, interest and
+// fee. The borrower owes the principal and the interest at the rate of the
+// loan, and the investors earn the interest at its ROI. Every split is worked
+// out on the total repaid so far and the previous total is subtracted, so the
+// rounding of the repayments adds up to the amounts due once the loan is
+// repaid.
+type repaymentSplit struct {
+	loan *model.Loan
+	due  int64
+}
+
+func newRepaymentSplit(loan *model.Loan) *repaymentSplit {
+	interest := decimal.NewFromInt(loan.PrincipalAmount).
+		Mul(loan.Rate).
+		Div(decimal.NewFromInt(100)).
+		Floor().
+		IntPart()
+
+	return &repaymentSplit{loan: loan, due: loan.PrincipalAmount + interest}
+}
+
+// principal returns the principal of the first paid amount of the loan.
+func (s *repaymentSplit) principal(paid int64) int64 {
+	return mulDiv(paid, s.loan.PrincipalAmount, s.due)
+}
+
+// fee returns the part of the interest of the first paid amount the platform
+// keeps, the difference between the rate and the ROI of the loan.
+func (s *repaymentSplit) fee(paid int64) int64 {
+	rate := s.loan.Rate
+	if !rate.IsPositive() || s.loan.ROI.GreaterThanOrEqual(rate) {
+		return 0
+	}
+
+	interest := paid - s.principal(paid)
+	return decimal.NewFromInt(interest).
+		Mul(rate.Sub(s.loan.ROI)).
+		Div(rate).
+		Floor().
+		IntPart()
+}
+
+// investorInterest returns the interest of the first paid amount of the loan
+// owed to the investors.
+func (s *repaymentSplit) investorInterest(paid int64) int64 {
+	return paid - s.principal(paid) - s.fee(paid)
+}
+
+// repayment splits an amount repaid after paid was repaid before.
+func (s *repaymentSplit) repayment(paid int64, amount int64) *model.Repayment {
+	principal := s.principal(paid+amount) - s.principal(paid)
+	return &model.Repayment{
+		LoanID:    s.loan.ID,
+		Amount:    amount,
+		Principal: principal,
+		Interest:  amount - principal,
+		Fee:       s.fee(paid+amount) - s.fee(paid),
+		Currency:  s.loan.Currency,
+	}
+}
+
+// investorPayout is the share of a repayment owed to an investment. The
+// interest is in the loan currency, and the amounts paid out to the investor
+// are in the currency the investor paid the investment in.
+type investorPayout struct {
+	investment          *model.Investment
+	interest            int64
+	settlementPrincipal int64
+	settlementInterest  int64
+}
+
+// payouts divides an amount repaid after paid was repaid before between the
+// investments of the loan, by the amount they invested. The principal repaid
+// in the settlement currency is the share of the settlement amount of the
+// investment, so investors paid in another currency get back what they paid,
+// while their interest is converted at the rate of the investment.
+func (s *repaymentSplit) payouts(investments []*model.Investment, paid int64, amount int64) []*investorPayout {
+	principalBefore, principalAfter := s.principal(paid), s.principal(paid+amount)
+	interestBefore, interestAfter := s.investorInterest(paid), s.investorInterest(paid+amount)
+	total := s.loan.PrincipalAmount
+
+	payouts := []*investorPayout{}
+	var invested int64
+	for _, investment := range investments {
+		share := func(amount int64) int64 {
+			return mulDiv(amount, invested+investment.Amount, total) - mulDiv(amount, invested, total)
+		}
+
+		payout := &investorPayout{
+			investment: investment,
+			interest:   share(interestAfter) - share(interestBefore),
+			settlementPrincipal: mulDiv(investment.SettlementAmount, principalAfter, total) -
+				mulDiv(investment.SettlementAmount, principalBefore, total),
+		}
+		payout.settlementInterest = s.loan.Currency.Convert(payout.interest, investment.FXRate, investment.SettlementCurrency)
+		payouts = append(payouts, payout)
+		invested += investment.Amount
+	}
+
+	return payouts
+}
+
+// mulDiv returns a*b/c rounded down, without overflowing on the product.
+func mulDiv(a int64, b int64, c int64) int64 {
+	if c == 0 {
+		return 0
+	}
+
+	product := new(big.Int).Mul(big.NewInt(a), big.NewInt(b))
+	return product.Quo(product, big.NewInt(c)).Int64()
+}
@@ -0,0 +1,114 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aldipi/loan-service/model"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func repayableLoan() *model.Loan {
+	return &model.Loan{
+		ID:              1,
+		PrincipalAmount: 1000000,
+		Currency:        "IDR",
+		Rate:            decimal.NewFromInt(12),
+		ROI:             decimal.NewFromInt(10),
+		State:           model.LoanStateDisbursed,
+	}
+}
+
+func TestRepayLoan(t *testing.T) {
+	repo := new(MockRepository)
+	uc := NewLoanUsecase(repo)
+
+	investments := []*model.Investment{
+		{ID: 1, InvestorID: 100, LoanID: 1, Amount: 600000, Currency: "IDR", SettlementAmount: 600000, SettlementCurrency: "IDR", FXRate: decimal.NewFromInt(1)},
+		{ID: 2, InvestorID: 101, LoanID: 1, Amount: 400000, Currency: "IDR", SettlementAmount: 400000, SettlementCurrency: "IDR", FXRate: decimal.NewFromInt(1)},
+	}
+	wallets := map[int64]*model.Wallet{
+		100: {InvestorID: 100, Currency: "IDR", Deployed: 600000},
+		101: {InvestorID: 101, Currency: "IDR", Deployed: 400000},
+	}
+
+	journals := []*model.JournalEntry{}
+	entries := []*model.WalletEntry{}
+	repo.On("GetEmployeeByID", mock.Anything, int64(555)).Return(&model.Employee{ID: 555}, nil)
+	repo.On("LockLoan", mock.Anything, int64(1)).Return(repayableLoan(), nil)
+	repo.On("SumRepaymentsByLoanID", mock.Anything, int64(1)).Return(int64(0), nil)
+	repo.On("GetInvestmentsByLoanID", mock.Anything, int64(1)).Return(investments, nil)
+	repo.On("CreateRepayment", mock.Anything, mock.Anything).Return(int64(4), nil)
+	repo.On("CreateJournalEntry", mock.Anything, mock.Anything).Return(int64(1), nil).Run(func(args mock.Arguments) {
+		journals = append(journals, args.Get(1).(*model.JournalEntry))
+	})
+	repo.On("LockWallet", mock.Anything, int64(100)).Return(wallets[100], nil)
+	repo.On("LockWallet", mock.Anything, int64(101)).Return(wallets[101], nil)
+	repo.On("UpdateWallet", mock.Anything, mock.Anything).Return(nil)
+	repo.On("CreateWalletEntry", mock.Anything, mock.Anything).Return(int64(1), nil).Run(func(args mock.Arguments) {
+		entries = append(entries, args.Get(1).(*model.WalletEntry))
+	})
+
+	repayment, err := uc.RepayLoan(context.Background(), 1, 555, 560000)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), repayment.ID)
+	assert.Equal(t, int64(500000), repayment.Principal)
+	assert.Equal(t, int64(60000), repayment.Interest)
+	assert.Equal(t, int64(10000), repayment.Fee)
+	assert.Equal(t, int64(560000), repayment.RemainingDue)
+	assert.Equal(t, int64(555), repayment.RecordedBy)
+
+	balances := trialBalance(t, journals)["IDR"]
+	assert.Equal(t, int64(560000), balances[model.AccountSettlement][0])
+	assert.Equal(t, int64(-500000), balances[model.AccountLoanReceivable][1])
+	assert.Equal(t, int64(0), balances[model.AccountInterestPayable][1])
+	assert.Equal(t, int64(-10000), balances[model.AccountPlatformRevenue][0])
+	assert.Equal(t, int64(-330000), balances[model.AccountInvestorCash][100])
+	assert.Equal(t, int64(-220000), balances[model.AccountInvestorCash][101])
+
+	if assert.Len(t, entries, 4) {
+		assert.Equal(t, model.WalletRepayment, entries[0].Type)
+		assert.Equal(t, int64(300000), entries[0].Amount)
+		assert.Equal(t, model.WalletPayout, entries[1].Type)
+		assert.Equal(t, int64(30000), entries[1].Amount)
+		assert.Equal(t, int64(2), entries[3].InvestmentID.Int64)
+		assert.Equal(t, int64(20000), entries[3].Amount)
+	}
+	assert.Equal(t, &model.Wallet{InvestorID: 100, Currency: "IDR", Available: 330000, Deployed: 300000, LastUpdatedAt: entries[1].CreatedAt}, wallets[100])
+}
+
+func TestRepayLoanErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		amount   int64
+		employee error
+		loan     *model.Loan
+		paid     int64
+		err      error
+	}{
+		{name: "invalid amount", amount: 0, err: model.ErrRepaymentInvalidAmount},
+		{name: "employee not found", amount: 560000, employee: errors.New("not found"), err: model.ErrEmployeeNotFound},
+		{name: "loan not disbursed", amount: 560000, loan: &model.Loan{ID: 1, State: model.LoanStateInvested}, err: model.ErrLoanNotDisbursed},
+		{name: "exceeds due", amount: 560001, loan: repayableLoan(), paid: 560000, err: model.ErrRepaymentExceedsDue},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := new(MockRepository)
+			uc := NewLoanUsecase(repo)
+
+			repo.On("GetEmployeeByID", mock.Anything, int64(555)).Return(&model.Employee{ID: 555}, tt.employee)
+			repo.On("LockLoan", mock.Anything, int64(1)).Return(tt.loan, nil)
+			repo.On("SumRepaymentsByLoanID", mock.Anything, int64(1)).Return(tt.paid, nil)
+
+			_, err := uc.RepayLoan(context.Background(), 1, 555, tt.amount)
+
+			assert.ErrorIs(t, err, tt.err)
+			repo.AssertNotCalled(t, "CreateRepayment", mock.Anything, mock.Anything)
+		})
+	}
+}
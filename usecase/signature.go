@@ -0,0 +1,303 @@
+package usecase
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aldipi/loan-service/model"
+	"github.com/aldipi/loan-service/tracing"
+)
+
+const (
+	defaultSigningURL = "http://localhost:8080/signatures"
+	signingTokenSize  = 32
+)
+
+var errNoSigningKey = errors.New("agreement signing is not configured")
+
+// signingRequest is a signature requested from a party, with the link sent to
+// them. The link holds the only copy of the signing token.
+type signingRequest struct {
+	role     model.SignerRole
+	signerID int64
+	url      string
+}
+
+// GetAgreementToSign returns the agreement the signing token was issued for,
+// with a download URL so the signer can review it before signing.
+func (u *LoanUsecase) GetAgreementToSign(ctx context.Context, token string) (document *model.LoanDocument, err error) {
+	ctx, span := tracing.Start(ctx, "LoanUsecase.GetAgreementToSign")
+	defer func() { tracing.End(span, err) }()
+
+	signature, err := u.repo.GetSignatureByTokenHash(ctx, hashToken(token))
+	if err != nil {
+		return nil, model.ErrSignatureNotFound
+	}
+
+	document, err = u.repo.GetLoanDocumentByID(ctx, signature.LoanDocumentID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = u.signURL(ctx, &document.Location)
+	if err != nil {
+		return nil, err
+	}
+
+	return document, nil
+}
+
+// SignAgreement signs the agreement the signing token was issued for on behalf
+// of signerID. The agreement is checked against the checksum recorded when it
+// was generated, so a file swapped in the meantime is never signed. Once every
+// party signed, the agreement becomes signed.
+func (u *LoanUsecase) SignAgreement(ctx context.Context, token string, signerID int64, ipAddress string) (signature *model.Signature, err error) {
+	ctx, span := tracing.Start(ctx, "LoanUsecase.SignAgreement")
+	defer func() { tracing.End(span, err) }()
+
+	if u.signingKey == nil {
+		return nil, errNoSigningKey
+	}
+
+	signature, err = u.repo.GetSignatureByTokenHash(ctx, hashToken(token))
+	if err != nil {
+		return nil, model.ErrSignatureNotFound
+	}
+
+	if signature.SignerID != signerID {
+		return nil, model.ErrSignatureSignerMismatch
+	}
+
+	if signature.SignedAt.Valid {
+		return nil, model.ErrAgreementAlreadySigned
+	}
+
+	document, err := u.repo.GetLoanDocumentByID(ctx, signature.LoanDocumentID)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := u.fetchDocument(ctx, document.Location)
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256(data)
+	if hex.EncodeToString(sum[:]) != signature.Checksum {
+		return nil, model.ErrDocumentChecksumChanged
+	}
+
+	signature.SignedAt = sql.NullTime{Time: now(), Valid: true}
+	signature.IPAddress = sql.NullString{String: ipAddress, Valid: ipAddress != ""}
+	signature.Value = sql.NullString{
+		String: base64.StdEncoding.EncodeToString(ed25519.Sign(u.signingKey, signaturePayload(signature))),
+		Valid:  true,
+	}
+
+	err = u.repo.WithTransaction(ctx, func(ctx context.Context) error {
+		err := u.repo.SignSignature(ctx, signature)
+		if errors.Is(err, sql.ErrNoRows) {
+			return model.ErrAgreementAlreadySigned
+		}
+		if err != nil {
+			return err
+		}
+
+		signatures, err := u.repo.GetSignaturesByLoanDocumentID(ctx, document.ID)
+		if err != nil {
+			return err
+		}
+		for _, other := range signatures {
+			if other.ID != signature.ID && !other.SignedAt.Valid {
+				return nil
+			}
+		}
+
+		return u.repo.UpdateLoanDocumentStatus(ctx, document.ID, model.DocumentSigned)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return signature, nil
+}
+
+// GetLoanSignatures lists the signatures requested for the agreements of the
+// loan, signed or not. Only actors who can see the loan can list them.
+func (u *LoanUsecase) GetLoanSignatures(ctx context.Context, actor model.Actor, loanID int64) (signatures []*model.Signature, err error) {
+	ctx, span := tracing.Start(ctx, "LoanUsecase.GetLoanSignatures")
+	defer func() { tracing.End(span, err) }()
+
+	_, err = u.accessLoan(ctx, actor, loanID)
+	if err != nil {
+		return nil, err
+	}
+
+	return u.repo.GetSignaturesByLoanID(ctx, loanID)
+}
+
+// VerifySignature reports whether the signature was made by the key of the
+// service and still matches the recorded agreement checksum, signer, time and
+// address.
+func VerifySignature(key ed25519.PublicKey, signature *model.Signature) bool {
+	if !signature.SignedAt.Valid || !signature.Value.Valid {
+		return false
+	}
+
+	value, err := base64.StdEncoding.DecodeString(signature.Value.String)
+	if err != nil {
+		return false
+	}
+
+	return ed25519.Verify(key, signaturePayload(signature), value)
+}
+
+// checkAgreementsSigned requires the borrower agreement and the agreement of
+// every investment of the loan to be signed.
+func (u *LoanUsecase) checkAgreementsSigned(ctx context.Context, loanID int64) error {
+	documents, err := u.repo.GetLoanDocumentsByLoanID(ctx, loanID)
+	if err != nil {
+		return err
+	}
+
+	investments, err := u.repo.GetInvestmentsByLoanID(ctx, loanID)
+	if err != nil {
+		return err
+	}
+
+	borrowerSigned := false
+	investmentsSigned := map[int64]bool{}
+	for _, document := range documents {
+		if document.Status != model.DocumentSigned {
+			continue
+		}
+
+		switch document.Kind {
+		case model.DocumentBorrowerAgreement:
+			borrowerSigned = true
+		case model.DocumentInvestorAgreement:
+			investmentsSigned[document.InvestmentID.Int64] = true
+		}
+	}
+
+	if !borrowerSigned {
+		return model.ErrAgreementsNotSigned
+	}
+
+	for _, investment := range investments {
+		if !investmentsSigned[investment.ID] {
+			return model.ErrAgreementsNotSigned
+		}
+	}
+
+	return nil
+}
+
+// requestSignature records that the party has to sign the agreement and
+// returns the link to sign it.
+func (u *LoanUsecase) requestSignature(ctx context.Context, document *model.LoanDocument, role model.SignerRole, signerID int64) (signingRequest, error) {
+	token := make([]byte, signingTokenSize)
+	_, err := rand.Read(token)
+	if err != nil {
+		return signingRequest{}, err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(token)
+
+	_, err = u.repo.CreateSignature(ctx, &model.Signature{
+		LoanDocumentID: document.ID,
+		LoanID:         document.LoanID,
+		SignerRole:     role,
+		SignerID:       signerID,
+		Checksum:       document.Checksum,
+		TokenHash:      hashToken(encoded),
+	})
+	if err != nil {
+		return signingRequest{}, err
+	}
+
+	return signingRequest{
+		role:     role,
+		signerID: signerID,
+		url:      strings.TrimRight(u.signingURL, "/") + "/" + encoded,
+	}, nil
+}
+
+// notifySigners sends the signing links to their parties in the background.
+// It must only be called after the signature requests are committed.
+func (u *LoanUsecase) notifySigners(ctx context.Context, loan model.Loan, requests []signingRequest) {
+	if u.notifier == nil || len(requests) == 0 {
+		return
+	}
+
+	ctx = context.WithoutCancel(ctx)
+
+	u.background.Add(1)
+	go func() {
+		defer u.background.Done()
+
+		for _, request := range requests {
+			err := u.sendSigningRequest(ctx, loan, request)
+			if err != nil {
+				u.logger.ErrorContext(ctx, "request signature", "role", request.role, "signer_id", request.signerID, "loan_id", loan.ID, "error", err)
+			}
+		}
+	}()
+}
+
+func (u *LoanUsecase) sendSigningRequest(ctx context.Context, loan model.Loan, request signingRequest) error {
+	notification := &model.Notification{
+		Event:      model.NotificationSignatureRequested,
+		Loan:       loan,
+		SigningURL: request.url,
+	}
+
+	switch request.role {
+	case model.SignerBorrower:
+		borrower, err := u.repo.GetUserByID(ctx, request.signerID)
+		if err != nil {
+			return err
+		}
+		if !borrower.Email.Valid {
+			return nil
+		}
+		notification.Locale, notification.Name, notification.Email = borrower.Locale, borrower.Name, borrower.Email.String
+	case model.SignerInvestor:
+		investor, err := u.repo.GetInvestorByID(ctx, request.signerID)
+		if err != nil {
+			return err
+		}
+		if !investor.Email.Valid {
+			return nil
+		}
+		notification.Locale, notification.Name, notification.Email = investor.Locale, investor.Name, investor.Email.String
+	}
+
+	return u.notifier.Notify(ctx, notification)
+}
+
+// signaturePayload is the message signed for a signature. It binds the
+// agreement checksum to the signer, the signing time and their address.
+func signaturePayload(signature *model.Signature) []byte {
+	return []byte(fmt.Sprintf("document:%d\nsha256:%s\nsigner:%s:%d\nsigned_at:%s\nip_address:%s\n",
+		signature.LoanDocumentID,
+		signature.Checksum,
+		signature.SignerRole,
+		signature.SignerID,
+		signature.SignedAt.Time.UTC().Format(time.RFC3339),
+		signature.IPAddress.String,
+	))
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
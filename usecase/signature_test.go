@@ -0,0 +1,285 @@
+package usecase
+
+import (
+	"context"
+	"crypto/ed25519"
+	"database/sql"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aldipi/loan-service/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// signedAgreements returns the signed borrower agreement of the loan and the
+// signed investor agreements of the investments.
+func signedAgreements(loanID int64, investmentIDs ...int64) []*model.LoanDocument {
+	documents := []*model.LoanDocument{
+		{ID: 1, LoanID: loanID, Kind: model.DocumentBorrowerAgreement, Status: model.DocumentSigned},
+	}
+	for _, investmentID := range investmentIDs {
+		documents = append(documents, &model.LoanDocument{
+			ID:           investmentID + 1,
+			LoanID:       loanID,
+			InvestmentID: sql.NullInt64{Int64: investmentID, Valid: true},
+			Kind:         model.DocumentInvestorAgreement,
+			Status:       model.DocumentSigned,
+		})
+	}
+	return documents
+}
+
+func newSigningKey(t *testing.T) ed25519.PrivateKey {
+	_, key, err := ed25519.GenerateKey(nil)
+	assert.NoError(t, err)
+	return key
+}
+
+func pendingSignature() *model.Signature {
+	return &model.Signature{
+		ID:             9,
+		LoanDocumentID: 5,
+		LoanID:         1,
+		SignerRole:     model.SignerInvestor,
+		SignerID:       100,
+		Checksum:       checksumOf("investor pdf"),
+		TokenHash:      hashToken("token"),
+	}
+}
+
+func agreementDocument() *model.LoanDocument {
+	return &model.LoanDocument{
+		ID:           5,
+		LoanID:       1,
+		InvestmentID: sql.NullInt64{Int64: 2, Valid: true},
+		Kind:         model.DocumentInvestorAgreement,
+		Location:     "agreements/loans/1/investors/100/1.pdf",
+		Checksum:     checksumOf("investor pdf"),
+		Status:       model.DocumentPendingSignature,
+	}
+}
+
+func TestSignAgreement(t *testing.T) {
+	repo := new(MockRepository)
+	store := new(mockBlobStore)
+	key := newSigningKey(t)
+	uc := NewLoanUsecase(repo, WithDocuments(nil, store), WithSignatures(key, ""))
+
+	repo.On("GetSignatureByTokenHash", mock.Anything, hashToken("token")).Return(pendingSignature(), nil)
+	repo.On("GetLoanDocumentByID", mock.Anything, int64(5)).Return(agreementDocument(), nil)
+	store.On("Get", mock.Anything, "agreements/loans/1/investors/100/1.pdf").Return([]byte("investor pdf"), nil)
+	repo.On("SignSignature", mock.Anything, mock.Anything).Return(nil)
+	repo.On("GetSignaturesByLoanDocumentID", mock.Anything, int64(5)).Return([]*model.Signature{{ID: 9, SignedAt: sql.NullTime{Time: time.Now(), Valid: true}}}, nil)
+	repo.On("UpdateLoanDocumentStatus", mock.Anything, int64(5), model.DocumentSigned).Return(nil)
+
+	signature, err := uc.SignAgreement(context.Background(), "token", 100, "10.0.0.1")
+
+	assert.NoError(t, err)
+	assert.True(t, signature.SignedAt.Valid)
+	assert.Equal(t, "10.0.0.1", signature.IPAddress.String)
+	assert.True(t, VerifySignature(key.Public().(ed25519.PublicKey), signature))
+	repo.AssertCalled(t, "SignSignature", mock.Anything, signature)
+	repo.AssertCalled(t, "UpdateLoanDocumentStatus", mock.Anything, int64(5), model.DocumentSigned)
+
+	tampered := *signature
+	tampered.Checksum = checksumOf("other pdf")
+	assert.False(t, VerifySignature(key.Public().(ed25519.PublicKey), &tampered))
+}
+
+func TestSignAgreementWaitsForOtherSigners(t *testing.T) {
+	repo := new(MockRepository)
+	store := new(mockBlobStore)
+	uc := NewLoanUsecase(repo, WithDocuments(nil, store), WithSignatures(newSigningKey(t), ""))
+
+	repo.On("GetSignatureByTokenHash", mock.Anything, hashToken("token")).Return(pendingSignature(), nil)
+	repo.On("GetLoanDocumentByID", mock.Anything, int64(5)).Return(agreementDocument(), nil)
+	store.On("Get", mock.Anything, mock.Anything).Return([]byte("investor pdf"), nil)
+	repo.On("SignSignature", mock.Anything, mock.Anything).Return(nil)
+	repo.On("GetSignaturesByLoanDocumentID", mock.Anything, int64(5)).Return([]*model.Signature{{ID: 9}, {ID: 10}}, nil)
+
+	_, err := uc.SignAgreement(context.Background(), "token", 100, "10.0.0.1")
+
+	assert.NoError(t, err)
+	repo.AssertNotCalled(t, "UpdateLoanDocumentStatus", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestSignAgreementRejects(t *testing.T) {
+	signed := pendingSignature()
+	signed.SignedAt = sql.NullTime{Time: time.Now(), Valid: true}
+
+	tests := []struct {
+		name      string
+		signature *model.Signature
+		signerID  int64
+		data      string
+		signErr   error
+		err       error
+	}{
+		{name: "unknown token", signerID: 100, err: model.ErrSignatureNotFound},
+		{name: "other signer", signature: pendingSignature(), signerID: 101, err: model.ErrSignatureSignerMismatch},
+		{name: "already signed", signature: signed, signerID: 100, err: model.ErrAgreementAlreadySigned},
+		{name: "changed document", signature: pendingSignature(), signerID: 100, data: "swapped pdf", err: model.ErrDocumentChecksumChanged},
+		{name: "signed concurrently", signature: pendingSignature(), signerID: 100, data: "investor pdf", signErr: sql.ErrNoRows, err: model.ErrAgreementAlreadySigned},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := new(MockRepository)
+			store := new(mockBlobStore)
+			uc := NewLoanUsecase(repo, WithDocuments(nil, store), WithSignatures(newSigningKey(t), ""))
+
+			if tt.signature == nil {
+				repo.On("GetSignatureByTokenHash", mock.Anything, hashToken("token")).Return(nil, sql.ErrNoRows)
+			} else {
+				repo.On("GetSignatureByTokenHash", mock.Anything, hashToken("token")).Return(tt.signature, nil)
+			}
+			repo.On("GetLoanDocumentByID", mock.Anything, int64(5)).Return(agreementDocument(), nil)
+			store.On("Get", mock.Anything, mock.Anything).Return([]byte(tt.data), nil)
+			repo.On("SignSignature", mock.Anything, mock.Anything).Return(tt.signErr)
+
+			_, err := uc.SignAgreement(context.Background(), "token", tt.signerID, "10.0.0.1")
+
+			assert.ErrorIs(t, err, tt.err)
+			repo.AssertNotCalled(t, "UpdateLoanDocumentStatus", mock.Anything, mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestSignAgreementWithoutSigningKey(t *testing.T) {
+	repo := new(MockRepository)
+	uc := NewLoanUsecase(repo)
+
+	_, err := uc.SignAgreement(context.Background(), "token", 100, "10.0.0.1")
+
+	assert.ErrorIs(t, err, errNoSigningKey)
+	repo.AssertNotCalled(t, "GetSignatureByTokenHash", mock.Anything, mock.Anything)
+}
+
+func TestGetAgreementToSign(t *testing.T) {
+	repo := new(MockRepository)
+	store := new(mockBlobStore)
+	uc := NewLoanUsecase(repo, WithDocuments(nil, store))
+
+	repo.On("GetSignatureByTokenHash", mock.Anything, hashToken("token")).Return(pendingSignature(), nil)
+	repo.On("GetLoanDocumentByID", mock.Anything, int64(5)).Return(agreementDocument(), nil)
+	store.On("SignedURL", mock.Anything, "agreements/loans/1/investors/100/1.pdf", 15*time.Minute).Return("http://localhost/investor.pdf?signature=abc", nil)
+
+	document, err := uc.GetAgreementToSign(context.Background(), "token")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "http://localhost/investor.pdf?signature=abc", document.Location)
+	assert.Equal(t, checksumOf("investor pdf"), document.Checksum)
+	assert.Equal(t, model.DocumentPendingSignature, document.Status)
+}
+
+func TestGetAgreementToSignUnknownToken(t *testing.T) {
+	repo := new(MockRepository)
+	uc := NewLoanUsecase(repo)
+
+	repo.On("GetSignatureByTokenHash", mock.Anything, hashToken("token")).Return(nil, sql.ErrNoRows)
+
+	_, err := uc.GetAgreementToSign(context.Background(), "token")
+
+	assert.ErrorIs(t, err, model.ErrSignatureNotFound)
+}
+
+func TestGetLoanSignatures(t *testing.T) {
+	repo := new(MockRepository)
+	uc := NewLoanUsecase(repo)
+
+	repo.On("GetLoanByID", mock.Anything, int64(1)).Return(&model.Loan{ID: 1}, nil)
+	repo.On("GetInvestmentsByLoanID", mock.Anything, int64(1)).Return([]*model.Investment{{LoanID: 1, InvestorID: 5}}, nil)
+	repo.On("GetSignaturesByLoanID", mock.Anything, int64(1)).Return([]*model.Signature{pendingSignature()}, nil)
+
+	signatures, err := uc.GetLoanSignatures(context.Background(), model.Actor{Role: model.RoleInvestor, ID: 5}, 1)
+
+	assert.NoError(t, err)
+	assert.Len(t, signatures, 1)
+
+	_, err = uc.GetLoanSignatures(context.Background(), model.Actor{Role: model.RoleBorrower, ID: 7}, 1)
+
+	assert.ErrorIs(t, err, model.ErrLoanAccessDenied)
+	repo.AssertNumberOfCalls(t, "GetSignaturesByLoanID", 1)
+}
+
+func TestDisburseLoanRequiresSignedAgreements(t *testing.T) {
+	pendingBorrower := signedAgreements(1, 2)
+	pendingBorrower[0].Status = model.DocumentPendingSignature
+
+	tests := []struct {
+		name      string
+		documents []*model.LoanDocument
+	}{
+		{name: "no agreements", documents: []*model.LoanDocument{}},
+		{name: "borrower agreement pending", documents: pendingBorrower},
+		{name: "investor agreement missing", documents: signedAgreements(1, 2)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := new(MockRepository)
+			uc := NewLoanUsecase(repo)
+
+			repo.On("GetLoanByID", mock.Anything, int64(1)).Return(&model.Loan{ID: 1, State: model.LoanStateInvested}, nil)
+			repo.On("GetEmployeeByID", mock.Anything, int64(555)).Return(&model.Employee{ID: 555}, nil)
+			repo.On("GetInvestmentsByLoanID", mock.Anything, int64(1)).Return([]*model.Investment{{ID: 2}, {ID: 3}}, nil)
+			repo.On("GetLoanDocumentsByLoanID", mock.Anything, int64(1)).Return(tt.documents, nil)
+
+			err := uc.DisburseLoan(context.Background(), 1, 555, "https://file.io/123/agreement.pdf")
+
+			assert.ErrorIs(t, err, model.ErrAgreementsNotSigned)
+			repo.AssertNotCalled(t, "UpdateLoan", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestCreateInvestmentSendsSigningLink(t *testing.T) {
+	repo := new(MockRepository)
+	generator := new(mockDocumentGenerator)
+	store := new(mockBlobStore)
+	notifier := new(mockNotifier)
+	uc := NewLoanUsecase(repo,
+		WithDocuments(generator, store),
+		WithNotifier(notifier),
+		WithSignatures(newSigningKey(t), "https://loans.example.com/signatures/"),
+	)
+
+	loan := &model.Loan{ID: 1, BorrowerID: 123, PrincipalAmount: 1000000, State: model.LoanStateApproved}
+
+	var tokenHash string
+	expectFundedWallets(repo)
+	expectJournalEntries(repo)
+	repo.On("GetInvestorByID", mock.Anything, int64(100)).Return(&model.Investor{ID: 100, Name: "Investor A", Email: sql.NullString{String: "a@example.com", Valid: true}}, nil)
+	repo.On("LockLoan", mock.Anything, int64(1)).Return(loan, nil)
+	repo.On("GetInvestmentsByLoanID", mock.Anything, int64(1)).Return([]*model.Investment{}, nil)
+	repo.On("CreateInvestment", mock.Anything, mock.Anything).Return(int64(2), nil)
+	repo.On("CreateLoanEvent", mock.Anything, mock.Anything).Return(int64(1), nil)
+	repo.On("CreateOutboxMessage", mock.Anything, mock.Anything).Return(int64(1), nil)
+	repo.On("CreateLoanDocument", mock.Anything, mock.Anything).Return(int64(5), nil)
+	repo.On("CreateSignature", mock.Anything, mock.MatchedBy(func(s *model.Signature) bool {
+		return s.LoanDocumentID == 5 && s.SignerID == 100
+	})).Run(func(args mock.Arguments) {
+		tokenHash = args.Get(1).(*model.Signature).TokenHash
+	}).Return(int64(9), nil)
+	generator.On("GenerateAgreement", mock.Anything, mock.Anything).Return([]byte("investor pdf"), nil)
+	store.On("Put", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	store.On("SignedURL", mock.Anything, mock.Anything, mock.Anything).Return("http://localhost/investor.pdf", nil)
+	notifier.On("Notify", mock.Anything, mock.Anything).Return(nil)
+
+	_, err := uc.CreateInvestment(context.Background(), 100, 1, 400000)
+	uc.Wait()
+
+	assert.NoError(t, err)
+	notifier.AssertCalled(t, "Notify", mock.Anything, mock.MatchedBy(func(n *model.Notification) bool {
+		token := strings.TrimPrefix(n.SigningURL, "https://loans.example.com/signatures/")
+		return n.Event == model.NotificationSignatureRequested && n.Email == "a@example.com" && token != n.SigningURL && hashToken(token) == tokenHash
+	}))
+}
+
+func TestVerifySignatureUnsigned(t *testing.T) {
+	key := newSigningKey(t)
+
+	assert.False(t, VerifySignature(key.Public().(ed25519.PublicKey), pendingSignature()))
+}
@@ -2,32 +2,259 @@ package usecase
 
 import (
 	"context"
+	"crypto/ed25519"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
 
 	"github.com/aldipi/loan-service/model"
+	"github.com/aldipi/loan-service/tracing"
 )
 
 type Repository interface {
+	WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error
+
 	GetLoanByID(ctx context.Context, id int64) (*model.Loan, error)
-	GetLoans(ctx context.Context, limit int, offset int) ([]*model.Loan, error)
+	LockLoan(ctx context.Context, id int64) (*model.Loan, error)
+	GetLoans(ctx context.Context, filter model.LoanFilter) ([]*model.Loan, error)
+	CountLoans(ctx context.Context, filter model.LoanFilter) (int, error)
+	CountLoansByState(ctx context.Context) (map[model.LoanState]int, error)
 	GetLoansByBorrowerID(ctx context.Context, borrowerID int64, limit int, offset int) ([]*model.Loan, error)
 	CreateLoan(ctx context.Context, loan *model.Loan) (id int64, err error)
 	UpdateLoan(ctx context.Context, loan *model.Loan) error
+	ReplaceLoan(ctx context.Context, loan *model.Loan) error
+
+	CreateLoanEvent(ctx context.Context, event *model.LoanEvent) (id int64, err error)
+	GetLoanEvents(ctx context.Context, afterLoanID int64, afterID int64, limit int) ([]*model.LoanEvent, error)
+	GetLoanIDsWithoutEvents(ctx context.Context) ([]int64, error)
+
+	CreateOutboxMessage(ctx context.Context, message *model.OutboxMessage) (id int64, err error)
+	GetPendingOutboxMessages(ctx context.Context, at time.Time, limit int) ([]*model.OutboxMessage, error)
+	MarkOutboxMessagePublished(ctx context.Context, id int64, publishedAt time.Time) error
+	MarkOutboxMessageFailed(ctx context.Context, id int64, nextAttemptAt time.Time, lastError string) error
+
+	CreateWebhook(ctx context.Context, webhook *model.Webhook) (id int64, err error)
+	GetWebhookByID(ctx context.Context, id int64) (*model.Webhook, error)
+	GetWebhooksByEventType(ctx context.Context, eventType model.LoanEventType) ([]*model.Webhook, error)
+	CreateWebhookDelivery(ctx context.Context, delivery *model.WebhookDelivery) error
+	GetPendingWebhookDeliveries(ctx context.Context, at time.Time, limit int) ([]*model.WebhookDelivery, error)
+	GetWebhookDeliveriesByWebhookID(ctx context.Context, webhookID int64, limit int, offset int) ([]*model.WebhookDelivery, error)
+	UpdateWebhookDelivery(ctx context.Context, delivery *model.WebhookDelivery) error
+
+	CreateLoanDocument(ctx context.Context, document *model.LoanDocument) (id int64, err error)
+	GetLoanDocumentsByLoanID(ctx context.Context, loanID int64) ([]*model.LoanDocument, error)
+	GetLoanDocumentsByChecksum(ctx context.Context, checksum string) ([]*model.LoanDocument, error)
+	GetLoanDocumentByID(ctx context.Context, id int64) (*model.LoanDocument, error)
+	UpdateLoanDocumentStatus(ctx context.Context, id int64, status model.DocumentStatus) error
+
+	CreateSignature(ctx context.Context, signature *model.Signature) (id int64, err error)
+	GetSignatureByTokenHash(ctx context.Context, tokenHash string) (*model.Signature, error)
+	GetSignaturesByLoanDocumentID(ctx context.Context, loanDocumentID int64) ([]*model.Signature, error)
+	GetSignaturesByLoanID(ctx context.Context, loanID int64) ([]*model.Signature, error)
+	SignSignature(ctx context.Context, signature *model.Signature) error
 
 	GetLoanProductByID(ctx context.Context, id int64) (*model.LoanProduct, error)
+	GetLoanProductsByIDs(ctx context.Context, ids []int64) ([]*model.LoanProduct, error)
 
 	GetInvestmentsByLoanID(ctx context.Context, loanID int64) ([]*model.Investment, error)
-	GetInvestmentsByInvestorID(ctx context.Context, investorID int64, limit int, offset int) ([]*model.Investment, error)
+	GetInvestmentsByLoanIDs(ctx context.Context, loanIDs []int64) ([]*model.Investment, error)
+	GetMarketplaceLoans(ctx context.Context, filter model.MarketplaceFilter, at time.Time, fundingPeriod time.Duration) ([]*model.MarketplaceLoan, error)
+	CountMarketplaceLoans(ctx context.Context, at time.Time, fundingPeriod time.Duration) (int, error)
+
+	GetInvestments(ctx context.Context, filter model.InvestmentFilter) ([]*model.Investment, error)
+	CountInvestments(ctx context.Context, filter model.InvestmentFilter) (int, error)
+	GetHoldingsByInvestorID(ctx context.Context, investorID int64) ([]*model.Holding, error)
 	CreateInvestment(ctx context.Context, investment *model.Investment) (id int64, err error)
 
+	GetWalletByInvestorID(ctx context.Context, investorID int64) (*model.Wallet, error)
+	LockWallet(ctx context.Context, investorID int64) (*model.Wallet, error)
+	UpdateWallet(ctx context.Context, wallet *model.Wallet) error
+	CreateWalletEntry(ctx context.Context, entry *model.WalletEntry) (id int64, err error)
+	LockWalletEntry(ctx context.Context, id int64) (*model.WalletEntry, error)
+	UpdateWalletEntry(ctx context.Context, entry *model.WalletEntry) error
+	GetPendingWalletEntries(ctx context.Context, before time.Time, limit int) ([]*model.WalletEntry, error)
+	GetWalletEntriesByInvestorID(ctx context.Context, investorID int64, limit int) ([]*model.WalletEntry, error)
+	SumWalletEntries(ctx context.Context, investorID int64, entryType model.WalletEntryType) (int64, error)
+
+	CreateJournalEntry(ctx context.Context, entry *model.JournalEntry) (id int64, err error)
+	GetAccountBalances(ctx context.Context) ([]*model.AccountBalance, error)
+	GetUnbalancedJournalEntryIDs(ctx context.Context) ([]int64, error)
+
+	CreateRepayment(ctx context.Context, repayment *model.Repayment) (id int64, err error)
+	SumRepaymentsByLoanID(ctx context.Context, loanID int64) (int64, error)
+
 	GetUserByID(ctx context.Context, id int64) (*model.User, error)
 	GetEmployeeByID(ctx context.Context, id int64) (*model.Employee, error)
 	GetInvestorByID(ctx context.Context, id int64) (*model.Investor, error)
+	GetUsersByIDs(ctx context.Context, ids []int64) ([]*model.User, error)
+	GetEmployeesByIDs(ctx context.Context, ids []int64) ([]*model.Employee, error)
+	GetInvestorsByIDs(ctx context.Context, ids []int64) ([]*model.Investor, error)
+
+	StreamLoans(ctx context.Context, filter model.LoanFilter, fn func(loan *model.Loan) error) error
+	StreamInvestments(ctx context.Context, filter model.InvestmentFilter, fn func(investment *model.Investment) error) error
+	CreateExportJob(ctx context.Context, job *model.ExportJob) (id int64, err error)
+	GetExportJobByID(ctx context.Context, id int64) (*model.ExportJob, error)
+	GetPendingExportJobs(ctx context.Context, limit int) ([]*model.ExportJob, error)
+	StartExportJob(ctx context.Context, id int64, startedAt time.Time) (bool, error)
+	RequeueStaleExportJobs(ctx context.Context, timeout time.Duration) (int64, error)
+	FinishExportJob(ctx context.Context, job *model.ExportJob) error
 }
 
 type LoanUsecase struct {
-	repo Repository
+	repo        Repository
+	notifier    Notifier
+	documents   DocumentGenerator
+	blobs       BlobStore
+	httpClient  *http.Client
+	docHosts    []string
+	signingKey  ed25519.PrivateKey
+	paymentRail PaymentRail
+	fxRates     FXRateProvider
+	metrics     Metrics
+	tables      TableEncoder
+	logger      *slog.Logger
+
+	signedURLExpiry time.Duration
+	signingURL      string
+	fundingPeriod   time.Duration
+
+	background sync.WaitGroup
+}
+
+// Option configures optional dependencies of LoanUsecase.
+type Option func(u *LoanUsecase)
+
+func NewLoanUsecase(repo Repository, opts ...Option) *LoanUsecase {
+	u := &LoanUsecase{
+		repo:            repo,
+		httpClient:      &http.Client{Timeout: defaultDocumentFetchTimeout, Transport: tracing.NewTransport(publicTransport())},
+		signedURLExpiry: defaultSignedURLExpiry,
+		signingURL:      defaultSigningURL,
+		fundingPeriod:   defaultFundingPeriod,
+		logger:          slog.Default(),
+	}
+	for _, opt := range opts {
+		opt(u)
+	}
+	return u
+}
+
+// WithNotifier makes the usecase notify borrowers and investors about loan
+// state changes.
+func WithNotifier(notifier Notifier) Option {
+	return func(u *LoanUsecase) {
+		u.notifier = notifier
+	}
+}
+
+// WithDocuments makes the usecase generate agreement letters and keep them,
+// together with uploaded documents, in the blob store. Without it investments
+// and loans have no agreement letter, uploads are rejected and loans can not
+// be disbursed.
+func WithDocuments(generator DocumentGenerator, store BlobStore) Option {
+	return func(u *LoanUsecase) {
+		u.documents = generator
+		u.blobs = store
+	}
+}
+
+// WithSignedURLExpiry sets how long document download URLs returned by the
+// usecase stay valid.
+func WithSignedURLExpiry(expiry time.Duration) Option {
+	return func(u *LoanUsecase) {
+		u.signedURLExpiry = expiry
+	}
+}
+
+// WithHTTPClient sets the client used to download documents that are
+// submitted as external URLs. The default client refuses to connect to
+// loopback, private and link-local addresses.
+func WithHTTPClient(client *http.Client) Option {
+	return func(u *LoanUsecase) {
+		u.httpClient = client
+	}
+}
+
+// WithDocumentHosts limits documents submitted as external URLs to URLs on
+// the hosts. Without hosts external URLs on any public host are downloaded.
+func WithDocumentHosts(hosts ...string) Option {
+	return func(u *LoanUsecase) {
+		u.docHosts = hosts
+	}
+}
+
+// WithSignatures makes the usecase sign agreements with key on behalf of the
+// parties. Signing links sent to the parties start with signingURL, or with
+// http://localhost:8080/signatures when it is empty.
+func WithSignatures(key ed25519.PrivateKey, signingURL string) Option {
+	return func(u *LoanUsecase) {
+		u.signingKey = key
+		if signingURL != "" {
+			u.signingURL = signingURL
+		}
+	}
+}
+
+// WithFundingPeriod sets how long approved loans are offered to investors on
+// the marketplace.
+func WithFundingPeriod(period time.Duration) Option {
+	return func(u *LoanUsecase) {
+		u.fundingPeriod = period
+	}
+}
+
+// WithPaymentRail makes the usecase move wallet deposits and withdrawals
+// through the rail. Without it deposits and withdrawals fail.
+func WithPaymentRail(rail PaymentRail) Option {
+	return func(u *LoanUsecase) {
+		u.paymentRail = rail
+	}
+}
+
+// WithFXRates makes the usecase convert investments in a currency other than
+// the wallet currency of the investor, and report in other currencies, at the
+// rates of the provider. Without it only amounts in the same currency can be
+// combined.
+func WithFXRates(provider FXRateProvider) Option {
+	return func(u *LoanUsecase) {
+		u.fxRates = provider
+	}
+}
+
+// WithMetrics makes the usecase record business metrics of the loans and
+// investments it handles.
+func WithMetrics(metrics Metrics) Option {
+	return func(u *LoanUsecase) {
+		u.metrics = metrics
+	}
+}
+
+// WithExports makes the usecase export listings with the encoder. Exports in
+// the background also need the blob store of WithDocuments, which keeps the
+// exported files. Without it exports fail.
+func WithExports(encoder TableEncoder) Option {
+	return func(u *LoanUsecase) {
+		u.tables = encoder
+	}
+}
+
+// WithLogger sets the logger of loan changes and of failures in background
+// work such as notifications.
+func WithLogger(logger *slog.Logger) Option {
+	return func(u *LoanUsecase) {
+		u.logger = logger
+	}
+}
+
+// Wait blocks until work started in the background by the usecase, such as
+// sending notifications, has finished.
+func (u *LoanUsecase) Wait() {
+	u.background.Wait()
 }
 
-func NewLoanUsecase(repo Repository) *LoanUsecase {
-	return &LoanUsecase{repo: repo}
+// now returns the current time at the precision stored by the database, so
+// event timestamps and projection timestamps compare equal after a round trip.
+func now() time.Time {
+	return time.Now().UTC().Truncate(time.Second)
 }
@@ -2,6 +2,7 @@ package usecase
 
 import (
 	"context"
+	"time"
 
 	"github.com/aldipi/loan-service/model"
 	"github.com/stretchr/testify/mock"
@@ -11,6 +12,11 @@ type MockRepository struct {
 	mock.Mock
 }
 
+// WithTransaction runs fn directly, the mock has no transaction to begin.
+func (m *MockRepository) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
 func (m *MockRepository) GetLoanByID(ctx context.Context, id int64) (*model.Loan, error) {
 	args := m.Called(ctx, id)
 	if args.Get(0) == nil {
@@ -19,11 +25,29 @@ func (m *MockRepository) GetLoanByID(ctx context.Context, id int64) (*model.Loan
 	return args.Get(0).(*model.Loan), args.Error(1)
 }
 
-func (m *MockRepository) GetLoans(ctx context.Context, limit int, offset int) ([]*model.Loan, error) {
-	args := m.Called(ctx, limit, offset)
+func (m *MockRepository) LockLoan(ctx context.Context, id int64) (*model.Loan, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Loan), args.Error(1)
+}
+
+func (m *MockRepository) GetLoans(ctx context.Context, filter model.LoanFilter) ([]*model.Loan, error) {
+	args := m.Called(ctx, filter)
 	return args.Get(0).([]*model.Loan), args.Error(1)
 }
 
+func (m *MockRepository) CountLoans(ctx context.Context, filter model.LoanFilter) (int, error) {
+	args := m.Called(ctx, filter)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockRepository) CountLoansByState(ctx context.Context) (map[model.LoanState]int, error) {
+	args := m.Called(ctx)
+	return args.Get(0).(map[model.LoanState]int), args.Error(1)
+}
+
 func (m *MockRepository) GetLoansByBorrowerID(ctx context.Context, borrowerID int64, limit int, offset int) ([]*model.Loan, error) {
 	args := m.Called(ctx, borrowerID, limit, offset)
 	return args.Get(0).([]*model.Loan), args.Error(1)
@@ -39,6 +63,140 @@ func (m *MockRepository) UpdateLoan(ctx context.Context, loan *model.Loan) error
 	return args.Error(0)
 }
 
+func (m *MockRepository) ReplaceLoan(ctx context.Context, loan *model.Loan) error {
+	args := m.Called(ctx, loan)
+	return args.Error(0)
+}
+
+func (m *MockRepository) CreateLoanEvent(ctx context.Context, event *model.LoanEvent) (int64, error) {
+	args := m.Called(ctx, event)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockRepository) GetLoanEvents(ctx context.Context, afterLoanID int64, afterID int64, limit int) ([]*model.LoanEvent, error) {
+	args := m.Called(ctx, afterLoanID, afterID, limit)
+	return args.Get(0).([]*model.LoanEvent), args.Error(1)
+}
+
+func (m *MockRepository) GetLoanIDsWithoutEvents(ctx context.Context) ([]int64, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]int64), args.Error(1)
+}
+
+func (m *MockRepository) CreateOutboxMessage(ctx context.Context, message *model.OutboxMessage) (int64, error) {
+	args := m.Called(ctx, message)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockRepository) GetPendingOutboxMessages(ctx context.Context, at time.Time, limit int) ([]*model.OutboxMessage, error) {
+	args := m.Called(ctx, at, limit)
+	return args.Get(0).([]*model.OutboxMessage), args.Error(1)
+}
+
+func (m *MockRepository) MarkOutboxMessagePublished(ctx context.Context, id int64, publishedAt time.Time) error {
+	args := m.Called(ctx, id, publishedAt)
+	return args.Error(0)
+}
+
+func (m *MockRepository) MarkOutboxMessageFailed(ctx context.Context, id int64, nextAttemptAt time.Time, lastError string) error {
+	args := m.Called(ctx, id, nextAttemptAt, lastError)
+	return args.Error(0)
+}
+
+func (m *MockRepository) CreateWebhook(ctx context.Context, webhook *model.Webhook) (int64, error) {
+	args := m.Called(ctx, webhook)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockRepository) GetWebhookByID(ctx context.Context, id int64) (*model.Webhook, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Webhook), args.Error(1)
+}
+
+func (m *MockRepository) GetWebhooksByEventType(ctx context.Context, eventType model.LoanEventType) ([]*model.Webhook, error) {
+	args := m.Called(ctx, eventType)
+	return args.Get(0).([]*model.Webhook), args.Error(1)
+}
+
+func (m *MockRepository) CreateWebhookDelivery(ctx context.Context, delivery *model.WebhookDelivery) error {
+	args := m.Called(ctx, delivery)
+	return args.Error(0)
+}
+
+func (m *MockRepository) GetPendingWebhookDeliveries(ctx context.Context, at time.Time, limit int) ([]*model.WebhookDelivery, error) {
+	args := m.Called(ctx, at, limit)
+	return args.Get(0).([]*model.WebhookDelivery), args.Error(1)
+}
+
+func (m *MockRepository) GetWebhookDeliveriesByWebhookID(ctx context.Context, webhookID int64, limit int, offset int) ([]*model.WebhookDelivery, error) {
+	args := m.Called(ctx, webhookID, limit, offset)
+	return args.Get(0).([]*model.WebhookDelivery), args.Error(1)
+}
+
+func (m *MockRepository) UpdateWebhookDelivery(ctx context.Context, delivery *model.WebhookDelivery) error {
+	args := m.Called(ctx, delivery)
+	return args.Error(0)
+}
+
+func (m *MockRepository) CreateLoanDocument(ctx context.Context, document *model.LoanDocument) (int64, error) {
+	args := m.Called(ctx, document)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockRepository) GetLoanDocumentsByLoanID(ctx context.Context, loanID int64) ([]*model.LoanDocument, error) {
+	args := m.Called(ctx, loanID)
+	return args.Get(0).([]*model.LoanDocument), args.Error(1)
+}
+
+func (m *MockRepository) GetLoanDocumentsByChecksum(ctx context.Context, checksum string) ([]*model.LoanDocument, error) {
+	args := m.Called(ctx, checksum)
+	return args.Get(0).([]*model.LoanDocument), args.Error(1)
+}
+
+func (m *MockRepository) GetLoanDocumentByID(ctx context.Context, id int64) (*model.LoanDocument, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.LoanDocument), args.Error(1)
+}
+
+func (m *MockRepository) UpdateLoanDocumentStatus(ctx context.Context, id int64, status model.DocumentStatus) error {
+	args := m.Called(ctx, id, status)
+	return args.Error(0)
+}
+
+func (m *MockRepository) CreateSignature(ctx context.Context, signature *model.Signature) (int64, error) {
+	args := m.Called(ctx, signature)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockRepository) GetSignatureByTokenHash(ctx context.Context, tokenHash string) (*model.Signature, error) {
+	args := m.Called(ctx, tokenHash)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Signature), args.Error(1)
+}
+
+func (m *MockRepository) GetSignaturesByLoanDocumentID(ctx context.Context, loanDocumentID int64) ([]*model.Signature, error) {
+	args := m.Called(ctx, loanDocumentID)
+	return args.Get(0).([]*model.Signature), args.Error(1)
+}
+
+func (m *MockRepository) GetSignaturesByLoanID(ctx context.Context, loanID int64) ([]*model.Signature, error) {
+	args := m.Called(ctx, loanID)
+	return args.Get(0).([]*model.Signature), args.Error(1)
+}
+
+func (m *MockRepository) SignSignature(ctx context.Context, signature *model.Signature) error {
+	args := m.Called(ctx, signature)
+	return args.Error(0)
+}
+
 func (m *MockRepository) GetLoanProductByID(ctx context.Context, id int64) (*model.LoanProduct, error) {
 	args := m.Called(ctx, id)
 	if args.Get(0) == nil {
@@ -52,16 +210,121 @@ func (m *MockRepository) GetInvestmentsByLoanID(ctx context.Context, loanID int6
 	return args.Get(0).([]*model.Investment), args.Error(1)
 }
 
-func (m *MockRepository) GetInvestmentsByInvestorID(ctx context.Context, investorID int64, limit int, offset int) ([]*model.Investment, error) {
-	args := m.Called(ctx, investorID, limit, offset)
+func (m *MockRepository) GetMarketplaceLoans(ctx context.Context, filter model.MarketplaceFilter, at time.Time, fundingPeriod time.Duration) ([]*model.MarketplaceLoan, error) {
+	args := m.Called(ctx, filter, at, fundingPeriod)
+	return args.Get(0).([]*model.MarketplaceLoan), args.Error(1)
+}
+
+func (m *MockRepository) CountMarketplaceLoans(ctx context.Context, at time.Time, fundingPeriod time.Duration) (int, error) {
+	args := m.Called(ctx, at, fundingPeriod)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockRepository) GetInvestments(ctx context.Context, filter model.InvestmentFilter) ([]*model.Investment, error) {
+	args := m.Called(ctx, filter)
 	return args.Get(0).([]*model.Investment), args.Error(1)
 }
 
+func (m *MockRepository) CountInvestments(ctx context.Context, filter model.InvestmentFilter) (int, error) {
+	args := m.Called(ctx, filter)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockRepository) GetHoldingsByInvestorID(ctx context.Context, investorID int64) ([]*model.Holding, error) {
+	args := m.Called(ctx, investorID)
+	return args.Get(0).([]*model.Holding), args.Error(1)
+}
+
 func (m *MockRepository) CreateInvestment(ctx context.Context, investment *model.Investment) (int64, error) {
 	args := m.Called(ctx, investment)
 	return args.Get(0).(int64), args.Error(1)
 }
 
+func (m *MockRepository) GetWalletByInvestorID(ctx context.Context, investorID int64) (*model.Wallet, error) {
+	args := m.Called(ctx, investorID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Wallet), args.Error(1)
+}
+
+func (m *MockRepository) LockWallet(ctx context.Context, investorID int64) (*model.Wallet, error) {
+	args := m.Called(ctx, investorID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Wallet), args.Error(1)
+}
+
+func (m *MockRepository) UpdateWallet(ctx context.Context, wallet *model.Wallet) error {
+	args := m.Called(ctx, wallet)
+	return args.Error(0)
+}
+
+func (m *MockRepository) CreateWalletEntry(ctx context.Context, entry *model.WalletEntry) (int64, error) {
+	args := m.Called(ctx, entry)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockRepository) LockWalletEntry(ctx context.Context, id int64) (*model.WalletEntry, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.WalletEntry), args.Error(1)
+}
+
+func (m *MockRepository) UpdateWalletEntry(ctx context.Context, entry *model.WalletEntry) error {
+	args := m.Called(ctx, entry)
+	return args.Error(0)
+}
+
+func (m *MockRepository) GetPendingWalletEntries(ctx context.Context, before time.Time, limit int) ([]*model.WalletEntry, error) {
+	args := m.Called(ctx, before, limit)
+	return args.Get(0).([]*model.WalletEntry), args.Error(1)
+}
+
+func (m *MockRepository) GetWalletEntriesByInvestorID(ctx context.Context, investorID int64, limit int) ([]*model.WalletEntry, error) {
+	args := m.Called(ctx, investorID, limit)
+	return args.Get(0).([]*model.WalletEntry), args.Error(1)
+}
+
+func (m *MockRepository) SumWalletEntries(ctx context.Context, investorID int64, entryType model.WalletEntryType) (int64, error) {
+	args := m.Called(ctx, investorID, entryType)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockRepository) CreateJournalEntry(ctx context.Context, entry *model.JournalEntry) (int64, error) {
+	args := m.Called(ctx, entry)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockRepository) GetAccountBalances(ctx context.Context) ([]*model.AccountBalance, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*model.AccountBalance), args.Error(1)
+}
+
+func (m *MockRepository) GetUnbalancedJournalEntryIDs(ctx context.Context) ([]int64, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]int64), args.Error(1)
+}
+
+func (m *MockRepository) CreateRepayment(ctx context.Context, repayment *model.Repayment) (int64, error) {
+	args := m.Called(ctx, repayment)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockRepository) SumRepaymentsByLoanID(ctx context.Context, loanID int64) (int64, error) {
+	args := m.Called(ctx, loanID)
+	return args.Get(0).(int64), args.Error(1)
+}
+
 func (m *MockRepository) GetUserByID(ctx context.Context, id int64) (*model.User, error) {
 	args := m.Called(ctx, id)
 	if args.Get(0) == nil {
@@ -85,3 +348,109 @@ func (m *MockRepository) GetInvestorByID(ctx context.Context, id int64) (*model.
 	}
 	return args.Get(0).(*model.Investor), args.Error(1)
 }
+
+func (m *MockRepository) GetLoanProductsByIDs(ctx context.Context, ids []int64) ([]*model.LoanProduct, error) {
+	args := m.Called(ctx, ids)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*model.LoanProduct), args.Error(1)
+}
+
+func (m *MockRepository) GetInvestmentsByLoanIDs(ctx context.Context, loanIDs []int64) ([]*model.Investment, error) {
+	args := m.Called(ctx, loanIDs)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*model.Investment), args.Error(1)
+}
+
+func (m *MockRepository) GetUsersByIDs(ctx context.Context, ids []int64) ([]*model.User, error) {
+	args := m.Called(ctx, ids)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*model.User), args.Error(1)
+}
+
+func (m *MockRepository) GetEmployeesByIDs(ctx context.Context, ids []int64) ([]*model.Employee, error) {
+	args := m.Called(ctx, ids)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*model.Employee), args.Error(1)
+}
+
+func (m *MockRepository) GetInvestorsByIDs(ctx context.Context, ids []int64) ([]*model.Investor, error) {
+	args := m.Called(ctx, ids)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*model.Investor), args.Error(1)
+}
+
+// StreamLoans calls fn with the loans returned by the expectation, then
+// returns its error.
+func (m *MockRepository) StreamLoans(ctx context.Context, filter model.LoanFilter, fn func(loan *model.Loan) error) error {
+	args := m.Called(ctx, filter)
+	if loans, ok := args.Get(0).([]*model.Loan); ok {
+		for _, loan := range loans {
+			err := fn(loan)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return args.Error(1)
+}
+
+// StreamInvestments calls fn with the investments returned by the
+// expectation, then returns its error.
+func (m *MockRepository) StreamInvestments(ctx context.Context, filter model.InvestmentFilter, fn func(investment *model.Investment) error) error {
+	args := m.Called(ctx, filter)
+	if investments, ok := args.Get(0).([]*model.Investment); ok {
+		for _, investment := range investments {
+			err := fn(investment)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return args.Error(1)
+}
+
+func (m *MockRepository) CreateExportJob(ctx context.Context, job *model.ExportJob) (id int64, err error) {
+	args := m.Called(ctx, job)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockRepository) GetExportJobByID(ctx context.Context, id int64) (*model.ExportJob, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.ExportJob), args.Error(1)
+}
+
+func (m *MockRepository) GetPendingExportJobs(ctx context.Context, limit int) ([]*model.ExportJob, error) {
+	args := m.Called(ctx, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*model.ExportJob), args.Error(1)
+}
+
+func (m *MockRepository) StartExportJob(ctx context.Context, id int64, startedAt time.Time) (bool, error) {
+	args := m.Called(ctx, id, startedAt)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRepository) RequeueStaleExportJobs(ctx context.Context, timeout time.Duration) (int64, error) {
+	args := m.Called(ctx, timeout)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockRepository) FinishExportJob(ctx context.Context, job *model.ExportJob) error {
+	args := m.Called(ctx, job)
+	return args.Error(0)
+}
@@ -0,0 +1,412 @@
+package usecase
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/aldipi/loan-service/model"
+	"github.com/aldipi/loan-service/tracing"
+)
+
+var errNoPaymentRail = errors.New("payment rail is not configured")
+
+const (
+	defaultTransferInterval   = 30 * time.Second
+	defaultTransferRetryAfter = time.Minute
+	defaultTransferBatchSize  = 50
+
+	idempotencyKeySize = 16
+)
+
+// PaymentRail moves money between investors and the service, returning the
+// reference of the transfer. Amounts are in minor units of the currency. The
+// rail moves the money of an idempotency key at most once, and answers a
+// request repeating the key with the reference of the first transfer. A
+// transfer the rail refused fails with model.ErrPaymentDeclined, while other
+// errors leave it unknown whether the money moved.
+type PaymentRail interface {
+	Collect(ctx context.Context, idempotencyKey string, investorID int64, amount int64, currency model.Currency) (reference string, err error)
+	Payout(ctx context.Context, idempotencyKey string, investorID int64, amount int64, currency model.Currency) (reference string, err error)
+}
+
+// GetWallet returns the balances of the wallet of the investor with its latest
+// entries.
+func (u *LoanUsecase) GetWallet(ctx context.Context, investorID int64, limit int) (wallet *model.Wallet, err error) {
+	ctx, span := tracing.Start(ctx, "LoanUsecase.GetWallet")
+	defer func() { tracing.End(span, err) }()
+
+	investor, err := u.repo.GetInvestorByID(ctx, investorID)
+	if err != nil {
+		return nil, model.ErrInvestorNotFound
+	}
+
+	wallet, err = u.repo.GetWalletByInvestorID(ctx, investorID)
+	if errors.Is(err, sql.ErrNoRows) {
+		wallet = &model.Wallet{InvestorID: investorID, Currency: investor.Currency}
+	} else if err != nil {
+		return nil, err
+	}
+
+	wallet.Entries, err = u.repo.GetWalletEntriesByInvestorID(ctx, investorID, pageSize(limit))
+	if err != nil {
+		return nil, err
+	}
+
+	return wallet, nil
+}
+
+// Deposit collects the amount, in the currency of the wallet, from the
+// investor through the payment rail and adds it to their available balance.
+func (u *LoanUsecase) Deposit(ctx context.Context, investorID int64, amount int64) (entry *model.WalletEntry, err error) {
+	ctx, span := tracing.Start(ctx, "LoanUsecase.Deposit")
+	defer func() { tracing.End(span, err) }()
+
+	return u.transferFunds(ctx, investorID, model.WalletDeposit, amount)
+}
+
+// Withdraw pays the amount out of the available balance of the investor
+// through the payment rail.
+func (u *LoanUsecase) Withdraw(ctx context.Context, investorID int64, amount int64) (entry *model.WalletEntry, err error) {
+	ctx, span := tracing.Start(ctx, "LoanUsecase.Withdraw")
+	defer func() { tracing.End(span, err) }()
+
+	return u.transferFunds(ctx, investorID, model.WalletWithdrawal, amount)
+}
+
+// transferFunds records the deposit or withdrawal as pending, then moves the
+// money through the payment rail once the transaction is committed, so no
+// wallet stays locked while the rail answers. The entry is settled or, when
+// the rail declined, reversed in a second transaction. When the outcome can
+// not be recorded the entry stays pending, and the transfer worker asks the
+// rail again with the same idempotency key.
+func (u *LoanUsecase) transferFunds(ctx context.Context, investorID int64, entryType model.WalletEntryType, amount int64) (*model.WalletEntry, error) {
+	if u.paymentRail == nil {
+		return nil, errNoPaymentRail
+	}
+
+	if amount <= 0 {
+		return nil, model.ErrWalletInvalidAmount
+	}
+
+	_, err := u.repo.GetInvestorByID(ctx, investorID)
+	if err != nil {
+		return nil, model.ErrInvestorNotFound
+	}
+
+	key, err := newIdempotencyKey()
+	if err != nil {
+		return nil, err
+	}
+
+	entry := &model.WalletEntry{
+		InvestorID:     investorID,
+		Type:           entryType,
+		Amount:         amount,
+		Status:         model.WalletEntryPending,
+		IdempotencyKey: sql.NullString{String: key, Valid: true},
+	}
+	err = u.repo.WithTransaction(ctx, func(ctx context.Context) error {
+		return u.postWalletEntry(ctx, entry)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = u.completeTransfer(ctx, entry)
+	if err != nil {
+		return nil, err
+	}
+
+	u.logger.InfoContext(ctx, "wallet funds transferred", "investor_id", investorID, "type", entryType, "entry_id", entry.ID, "amount", amount, "currency", entry.Currency)
+
+	return entry, nil
+}
+
+// completeTransfer moves the money of the pending entry through the payment
+// rail and records the outcome. A declined transfer is reversed and returns
+// the error of the rail. Any other error of the rail leaves the entry pending,
+// as the money may have moved.
+func (u *LoanUsecase) completeTransfer(ctx context.Context, entry *model.WalletEntry) error {
+	transfer := u.paymentRail.Collect
+	if entry.Type == model.WalletWithdrawal {
+		transfer = u.paymentRail.Payout
+	}
+
+	reference, err := transfer(ctx, entry.IdempotencyKey.String, entry.InvestorID, entry.Amount, entry.Currency)
+	if errors.Is(err, model.ErrPaymentDeclined) {
+		resolveErr := u.resolveTransfer(ctx, entry, model.WalletEntryReversed, sql.NullString{})
+		if resolveErr != nil {
+			return resolveErr
+		}
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	err = u.resolveTransfer(ctx, entry, model.WalletEntrySettled, sql.NullString{String: reference, Valid: true})
+	if err != nil {
+		u.logger.ErrorContext(ctx, "wallet transfer left pending", "entry_id", entry.ID, "reference", reference, "error", err)
+		return err
+	}
+
+	return nil
+}
+
+// resolveTransfer settles or reverses the pending entry. A settled deposit
+// becomes available and a reversed withdrawal gives back the funds it held,
+// and a settled transfer posts its journal entry. Entries already resolved,
+// such as by the transfer worker, are left as they are.
+func (u *LoanUsecase) resolveTransfer(ctx context.Context, entry *model.WalletEntry, status model.WalletEntryStatus, reference sql.NullString) error {
+	return u.repo.WithTransaction(ctx, func(ctx context.Context) error {
+		locked, err := u.repo.LockWalletEntry(ctx, entry.ID)
+		if err != nil {
+			return err
+		}
+		if locked.Status != model.WalletEntryPending {
+			*entry = *locked
+			return nil
+		}
+
+		wallet, err := u.repo.LockWallet(ctx, locked.InvestorID)
+		if err != nil {
+			return err
+		}
+
+		locked.Status = status
+		locked.Reference = reference
+		switch {
+		case status == model.WalletEntrySettled && locked.Type == model.WalletDeposit:
+			err = applyWalletEntry(wallet, locked)
+			if err != nil {
+				return err
+			}
+		case status == model.WalletEntryReversed && locked.Type == model.WalletWithdrawal:
+			wallet.Available += locked.Amount
+		}
+
+		wallet.LastUpdatedAt = now()
+		err = u.repo.UpdateWallet(ctx, wallet)
+		if err != nil {
+			return err
+		}
+
+		err = u.repo.UpdateWalletEntry(ctx, locked)
+		if err != nil {
+			return err
+		}
+
+		if status == model.WalletEntrySettled {
+			err = u.postJournal(ctx, walletJournal(locked))
+			if err != nil {
+				return err
+			}
+		}
+
+		*entry = *locked
+		return nil
+	})
+}
+
+// newIdempotencyKey returns a random key identifying a transfer to the
+// payment rail.
+func newIdempotencyKey() (string, error) {
+	key := make([]byte, idempotencyKeySize)
+	_, err := rand.Read(key)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(key), nil
+}
+
+// TransferWorker resolves the deposits and withdrawals left pending, because
+// their outcome could not be recorded or the rail failed without declining
+// them. Entries pending for longer than RetryAfter are sent to the rail again
+// with their idempotency key, which never moves their money twice, and are
+// settled or reversed.
+type TransferWorker struct {
+	usecase *LoanUsecase
+	status  workerStatus
+
+	Interval   time.Duration
+	RetryAfter time.Duration
+	BatchSize  int
+	Logger     *slog.Logger
+}
+
+func NewTransferWorker(usecase *LoanUsecase) *TransferWorker {
+	return &TransferWorker{
+		usecase:    usecase,
+		Interval:   defaultTransferInterval,
+		RetryAfter: defaultTransferRetryAfter,
+		BatchSize:  defaultTransferBatchSize,
+		Logger:     slog.Default(),
+	}
+}
+
+// Run resolves pending transfers every Interval until ctx is cancelled.
+// Errors are logged and the transfers are retried on the next tick.
+func (w *TransferWorker) Run(ctx context.Context) {
+	w.status.setRunning(true)
+	defer w.status.setRunning(false)
+
+	ticker := time.NewTicker(w.Interval)
+	defer ticker.Stop()
+
+	for {
+		_, err := w.ResolvePending(ctx)
+		if ctx.Err() == nil {
+			w.status.record(err)
+			if err != nil {
+				w.Logger.ErrorContext(ctx, "resolve pending transfers", "error", err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Check returns an error unless the worker is running and its last batch was
+// resolved. Transfers still failing do not fail the batch.
+func (w *TransferWorker) Check(ctx context.Context) error {
+	return w.status.check()
+}
+
+// ResolvePending resolves one batch of pending transfers and returns how many
+// were settled or reversed.
+func (w *TransferWorker) ResolvePending(ctx context.Context) (int, error) {
+	if w.usecase.paymentRail == nil {
+		return 0, errNoPaymentRail
+	}
+
+	entries, err := w.usecase.repo.GetPendingWalletEntries(ctx, now().Add(-w.RetryAfter), w.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	var resolved int
+	for _, entry := range entries {
+		if ctx.Err() != nil {
+			return resolved, ctx.Err()
+		}
+
+		err := w.usecase.completeTransfer(ctx, entry)
+		if err != nil && !errors.Is(err, model.ErrPaymentDeclined) {
+			w.Logger.WarnContext(ctx, "pending transfer failed", "entry_id", entry.ID, "error", err)
+			continue
+		}
+
+		w.Logger.InfoContext(ctx, "pending transfer resolved", "entry_id", entry.ID, "investor_id", entry.InvestorID, "type", entry.Type, "status", entry.Status)
+		resolved++
+	}
+
+	return resolved, nil
+}
+
+// releaseReservations turns the funds reserved for the investments of a fully
+// funded loan into deployed capital.
+func (u *LoanUsecase) releaseReservations(ctx context.Context, investments []*model.Investment) error {
+	for _, investment := range investments {
+		err := u.postWalletEntry(ctx, &model.WalletEntry{
+			InvestorID:   investment.InvestorID,
+			Type:         model.WalletRelease,
+			Amount:       investment.SettlementAmount,
+			Currency:     investment.SettlementCurrency,
+			LoanID:       sql.NullInt64{Int64: investment.LoanID, Valid: true},
+			InvestmentID: sql.NullInt64{Int64: investment.ID, Valid: true},
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// postWalletEntry applies the entry to the wallet of its investor, locked for
+// the rest of the transaction. Entries without a currency are in the currency
+// of the wallet, and entries without a status are settled.
+func (u *LoanUsecase) postWalletEntry(ctx context.Context, entry *model.WalletEntry) error {
+	wallet, err := u.repo.LockWallet(ctx, entry.InvestorID)
+	if err != nil {
+		return err
+	}
+
+	if entry.Currency == "" {
+		entry.Currency = wallet.Currency
+	}
+	if entry.Status == "" {
+		entry.Status = model.WalletEntrySettled
+	}
+
+	err = applyWalletEntry(wallet, entry)
+	if err != nil {
+		return err
+	}
+
+	entry.CreatedAt = now()
+	wallet.LastUpdatedAt = entry.CreatedAt
+	err = u.repo.UpdateWallet(ctx, wallet)
+	if err != nil {
+		return err
+	}
+
+	entry.ID, err = u.repo.CreateWalletEntry(ctx, entry)
+	return err
+}
+
+// applyWalletEntry moves the amount of the entry between the balances of the
+// wallet, rejecting entries the balances do not cover and entries in another
+// currency. A deposit is only available once it is settled, while a pending
+// withdrawal already holds its funds, so they can not be spent twice.
+func applyWalletEntry(wallet *model.Wallet, entry *model.WalletEntry) error {
+	if entry.Currency != wallet.Currency {
+		return model.ErrCurrencyMismatch
+	}
+
+	switch entry.Type {
+	case model.WalletDeposit:
+		if entry.Status != model.WalletEntryPending {
+			wallet.Available += entry.Amount
+		}
+	case model.WalletPayout:
+		wallet.Available += entry.Amount
+	case model.WalletRepayment:
+		if wallet.Deployed < entry.Amount {
+			return model.ErrInsufficientFunds
+		}
+		wallet.Deployed -= entry.Amount
+		wallet.Available += entry.Amount
+	case model.WalletWithdrawal:
+		if wallet.Available < entry.Amount {
+			return model.ErrInsufficientFunds
+		}
+		wallet.Available -= entry.Amount
+	case model.WalletReservation:
+		if wallet.Available < entry.Amount {
+			return model.ErrInsufficientFunds
+		}
+		wallet.Available -= entry.Amount
+		wallet.Reserved += entry.Amount
+	case model.WalletRelease:
+		if wallet.Reserved < entry.Amount {
+			return model.ErrInsufficientFunds
+		}
+		wallet.Reserved -= entry.Amount
+		wallet.Deployed += entry.Amount
+	default:
+		return fmt.Errorf("unknown wallet entry type %q", entry.Type)
+	}
+
+	return nil
+}
@@ -0,0 +1,439 @@
+package usecase
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/aldipi/loan-service/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type mockPaymentRail struct {
+	mock.Mock
+}
+
+func (m *mockPaymentRail) Collect(ctx context.Context, idempotencyKey string, investorID int64, amount int64, currency model.Currency) (string, error) {
+	args := m.Called(ctx, idempotencyKey, investorID, amount, currency)
+	return args.String(0), args.Error(1)
+}
+
+func (m *mockPaymentRail) Payout(ctx context.Context, idempotencyKey string, investorID int64, amount int64, currency model.Currency) (string, error) {
+	args := m.Called(ctx, idempotencyKey, investorID, amount, currency)
+	return args.String(0), args.Error(1)
+}
+
+// expectTransfer stores the pending entry of a transfer under the id, and
+// returns it as created. LockWalletEntry returns the stored entry, which is
+// updated in place as the transfer is resolved.
+func expectTransfer(repo *MockRepository, id int64) (created *model.WalletEntry) {
+	created = &model.WalletEntry{}
+	stored := &model.WalletEntry{}
+	repo.On("CreateWalletEntry", mock.Anything, mock.MatchedBy(func(e *model.WalletEntry) bool {
+		return e.Status == model.WalletEntryPending
+	})).Run(func(args mock.Arguments) {
+		*created = *args.Get(1).(*model.WalletEntry)
+		created.ID = id
+		*stored = *created
+	}).Return(id, nil)
+	repo.On("LockWalletEntry", mock.Anything, id).Return(stored, nil)
+	return created
+}
+
+// expectFundedWallets gives every investor enough funds to invest, and enough
+// reserved funds to release.
+func expectFundedWallets(repo *MockRepository) {
+	repo.On("GetWalletByInvestorID", mock.Anything, mock.Anything).Return(&model.Wallet{Available: 10000000}, nil)
+	repo.On("LockWallet", mock.Anything, mock.Anything).Return(&model.Wallet{Available: 10000000, Reserved: 10000000}, nil)
+	repo.On("UpdateWallet", mock.Anything, mock.Anything).Return(nil)
+	repo.On("CreateWalletEntry", mock.Anything, mock.Anything).Return(int64(1), nil)
+}
+
+func TestGetWallet(t *testing.T) {
+	repo := new(MockRepository)
+	uc := NewLoanUsecase(repo)
+
+	entries := []*model.WalletEntry{{ID: 1, InvestorID: 100, Type: model.WalletDeposit, Amount: 500000}}
+	repo.On("GetInvestorByID", mock.Anything, int64(100)).Return(&model.Investor{ID: 100}, nil)
+	repo.On("GetWalletByInvestorID", mock.Anything, int64(100)).Return(&model.Wallet{InvestorID: 100, Available: 500000}, nil)
+	repo.On("GetWalletEntriesByInvestorID", mock.Anything, int64(100), 10).Return(entries, nil)
+
+	wallet, err := uc.GetWallet(context.Background(), 100, 0)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(500000), wallet.Available)
+	assert.Equal(t, entries, wallet.Entries)
+}
+
+func TestGetWalletWithoutFunds(t *testing.T) {
+	repo := new(MockRepository)
+	uc := NewLoanUsecase(repo)
+
+	repo.On("GetInvestorByID", mock.Anything, int64(100)).Return(&model.Investor{ID: 100}, nil)
+	repo.On("GetWalletByInvestorID", mock.Anything, int64(100)).Return(nil, sql.ErrNoRows)
+	repo.On("GetWalletEntriesByInvestorID", mock.Anything, int64(100), 10).Return([]*model.WalletEntry{}, nil)
+
+	wallet, err := uc.GetWallet(context.Background(), 100, 10)
+
+	assert.NoError(t, err)
+	assert.Equal(t, &model.Wallet{InvestorID: 100, Entries: []*model.WalletEntry{}}, wallet)
+}
+
+func TestDeposit(t *testing.T) {
+	repo := new(MockRepository)
+	rail := new(mockPaymentRail)
+	uc := NewLoanUsecase(lockingRepository{repo}, WithPaymentRail(rail))
+
+	wallet := &model.Wallet{InvestorID: 100, Currency: model.DefaultCurrency, Available: 100000}
+	expectJournalEntries(repo)
+	repo.On("GetInvestorByID", mock.Anything, int64(100)).Return(&model.Investor{ID: 100}, nil)
+	repo.On("LockWallet", mock.Anything, int64(100)).Return(wallet, nil)
+	repo.On("UpdateWallet", mock.Anything, wallet).Return(nil)
+	created := expectTransfer(repo, 7)
+	repo.On("UpdateWalletEntry", mock.Anything, mock.Anything).Return(nil)
+	rail.On("Collect", mock.Anything, mock.Anything, int64(100), int64(500000), model.DefaultCurrency).Run(func(args mock.Arguments) {
+		assert.Nil(t, args.Get(0).(context.Context).Value(inTransactionKey{}), "rail called in a transaction")
+		assert.Equal(t, int64(100000), wallet.Available)
+	}).Return("ref-1", nil)
+
+	entry, err := uc.Deposit(context.Background(), 100, 500000)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(7), entry.ID)
+	assert.Equal(t, model.WalletDeposit, entry.Type)
+	assert.Equal(t, model.WalletEntrySettled, entry.Status)
+	assert.Equal(t, sql.NullString{String: "ref-1", Valid: true}, entry.Reference)
+	assert.Equal(t, int64(600000), wallet.Available)
+	assert.NotEmpty(t, created.IdempotencyKey.String)
+	rail.AssertCalled(t, "Collect", mock.Anything, created.IdempotencyKey.String, int64(100), int64(500000), model.DefaultCurrency)
+	repo.AssertCalled(t, "UpdateWalletEntry", mock.Anything, mock.MatchedBy(func(e *model.WalletEntry) bool {
+		return e.ID == 7 && e.Status == model.WalletEntrySettled && e.Reference.String == "ref-1"
+	}))
+}
+
+func TestDepositDeclined(t *testing.T) {
+	repo := new(MockRepository)
+	rail := new(mockPaymentRail)
+	uc := NewLoanUsecase(repo, WithPaymentRail(rail))
+
+	wallet := &model.Wallet{InvestorID: 100, Currency: model.DefaultCurrency, Available: 100000}
+	repo.On("GetInvestorByID", mock.Anything, int64(100)).Return(&model.Investor{ID: 100}, nil)
+	repo.On("LockWallet", mock.Anything, int64(100)).Return(wallet, nil)
+	repo.On("UpdateWallet", mock.Anything, wallet).Return(nil)
+	expectTransfer(repo, 7)
+	repo.On("UpdateWalletEntry", mock.Anything, mock.Anything).Return(nil)
+	rail.On("Collect", mock.Anything, mock.Anything, int64(100), int64(500000), model.DefaultCurrency).Return("", model.ErrPaymentDeclined)
+
+	_, err := uc.Deposit(context.Background(), 100, 500000)
+
+	assert.ErrorIs(t, err, model.ErrPaymentDeclined)
+	assert.Equal(t, int64(100000), wallet.Available)
+	repo.AssertCalled(t, "UpdateWalletEntry", mock.Anything, mock.MatchedBy(func(e *model.WalletEntry) bool {
+		return e.ID == 7 && e.Status == model.WalletEntryReversed && !e.Reference.Valid
+	}))
+	repo.AssertNotCalled(t, "CreateJournalEntry", mock.Anything, mock.Anything)
+}
+
+func TestDepositRailFailureLeavesTransferPending(t *testing.T) {
+	repo := new(MockRepository)
+	rail := new(mockPaymentRail)
+	uc := NewLoanUsecase(repo, WithPaymentRail(rail))
+
+	// The rail timed out, so the money may have been collected.
+	wallet := &model.Wallet{InvestorID: 100, Currency: model.DefaultCurrency}
+	repo.On("GetInvestorByID", mock.Anything, int64(100)).Return(&model.Investor{ID: 100}, nil)
+	repo.On("LockWallet", mock.Anything, int64(100)).Return(wallet, nil)
+	repo.On("UpdateWallet", mock.Anything, wallet).Return(nil)
+	expectTransfer(repo, 7)
+	rail.On("Collect", mock.Anything, mock.Anything, int64(100), int64(500000), model.DefaultCurrency).Return("", context.DeadlineExceeded)
+
+	_, err := uc.Deposit(context.Background(), 100, 500000)
+
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, int64(0), wallet.Available)
+	repo.AssertNotCalled(t, "LockWalletEntry", mock.Anything, mock.Anything)
+	repo.AssertNotCalled(t, "UpdateWalletEntry", mock.Anything, mock.Anything)
+}
+
+func TestDepositSettlementFailureIsRetried(t *testing.T) {
+	repo := new(MockRepository)
+	rail := new(mockPaymentRail)
+	uc := NewLoanUsecase(repo, WithPaymentRail(rail))
+
+	// The rail collected the money, but recording it failed, so the entry
+	// stays pending until the worker settles it.
+	wallet := &model.Wallet{InvestorID: 100, Currency: model.DefaultCurrency}
+	expectJournalEntries(repo)
+	repo.On("GetInvestorByID", mock.Anything, int64(100)).Return(&model.Investor{ID: 100}, nil)
+	repo.On("LockWallet", mock.Anything, int64(100)).Return(wallet, nil)
+	repo.On("UpdateWallet", mock.Anything, wallet).Return(nil)
+	repo.On("LockWalletEntry", mock.Anything, int64(7)).Return(nil, errors.New("connection lost")).Once()
+	created := expectTransfer(repo, 7)
+	repo.On("UpdateWalletEntry", mock.Anything, mock.Anything).Return(nil)
+	rail.On("Collect", mock.Anything, mock.Anything, int64(100), int64(500000), model.DefaultCurrency).Return("ref-1", nil)
+
+	_, err := uc.Deposit(context.Background(), 100, 500000)
+
+	assert.EqualError(t, err, "connection lost")
+	assert.Equal(t, int64(0), wallet.Available)
+	repo.AssertNotCalled(t, "UpdateWalletEntry", mock.Anything, mock.Anything)
+	repo.AssertNotCalled(t, "CreateJournalEntry", mock.Anything, mock.Anything)
+
+	worker := NewTransferWorker(uc)
+	pending := *created
+	repo.On("GetPendingWalletEntries", mock.Anything, mock.Anything, defaultTransferBatchSize).Return([]*model.WalletEntry{&pending}, nil)
+
+	resolved, err := worker.ResolvePending(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, resolved)
+	assert.Equal(t, int64(500000), wallet.Available)
+	assert.Equal(t, model.WalletEntrySettled, pending.Status)
+	rail.AssertNumberOfCalls(t, "Collect", 2)
+	for _, call := range rail.Calls {
+		assert.Equal(t, created.IdempotencyKey.String, call.Arguments.String(1))
+	}
+	repo.AssertNumberOfCalls(t, "CreateJournalEntry", 1)
+}
+
+func TestDepositInvalidAmount(t *testing.T) {
+	repo := new(MockRepository)
+	uc := NewLoanUsecase(repo, WithPaymentRail(new(mockPaymentRail)))
+
+	_, err := uc.Deposit(context.Background(), 100, 0)
+
+	assert.ErrorIs(t, err, model.ErrWalletInvalidAmount)
+}
+
+func TestDepositWithoutPaymentRail(t *testing.T) {
+	repo := new(MockRepository)
+	uc := NewLoanUsecase(repo)
+
+	_, err := uc.Deposit(context.Background(), 100, 500000)
+
+	assert.ErrorIs(t, err, errNoPaymentRail)
+}
+
+func TestWithdraw(t *testing.T) {
+	repo := new(MockRepository)
+	rail := new(mockPaymentRail)
+	uc := NewLoanUsecase(repo, WithPaymentRail(rail))
+
+	wallet := &model.Wallet{InvestorID: 100, Currency: model.DefaultCurrency, Available: 500000, Reserved: 200000}
+	expectJournalEntries(repo)
+	repo.On("GetInvestorByID", mock.Anything, int64(100)).Return(&model.Investor{ID: 100}, nil)
+	repo.On("LockWallet", mock.Anything, int64(100)).Return(wallet, nil)
+	repo.On("UpdateWallet", mock.Anything, wallet).Return(nil)
+	expectTransfer(repo, 8)
+	repo.On("UpdateWalletEntry", mock.Anything, mock.Anything).Return(nil)
+	rail.On("Payout", mock.Anything, mock.Anything, int64(100), int64(300000), model.DefaultCurrency).Run(func(args mock.Arguments) {
+		// The funds are held while the rail pays them out.
+		assert.Equal(t, int64(200000), wallet.Available)
+	}).Return("ref-2", nil)
+
+	entry, err := uc.Withdraw(context.Background(), 100, 300000)
+
+	assert.NoError(t, err)
+	assert.Equal(t, model.WalletWithdrawal, entry.Type)
+	assert.Equal(t, model.WalletEntrySettled, entry.Status)
+	assert.Equal(t, int64(200000), wallet.Available)
+	assert.Equal(t, int64(200000), wallet.Reserved)
+	repo.AssertCalled(t, "CreateJournalEntry", mock.Anything, journalKind(model.JournalWithdrawal))
+}
+
+func TestWithdrawDeclinedGivesFundsBack(t *testing.T) {
+	repo := new(MockRepository)
+	rail := new(mockPaymentRail)
+	uc := NewLoanUsecase(repo, WithPaymentRail(rail))
+
+	wallet := &model.Wallet{InvestorID: 100, Currency: model.DefaultCurrency, Available: 500000}
+	repo.On("GetInvestorByID", mock.Anything, int64(100)).Return(&model.Investor{ID: 100}, nil)
+	repo.On("LockWallet", mock.Anything, int64(100)).Return(wallet, nil)
+	repo.On("UpdateWallet", mock.Anything, wallet).Return(nil)
+	expectTransfer(repo, 8)
+	repo.On("UpdateWalletEntry", mock.Anything, mock.Anything).Return(nil)
+	rail.On("Payout", mock.Anything, mock.Anything, int64(100), int64(300000), model.DefaultCurrency).Return("", model.ErrPaymentDeclined)
+
+	_, err := uc.Withdraw(context.Background(), 100, 300000)
+
+	assert.ErrorIs(t, err, model.ErrPaymentDeclined)
+	assert.Equal(t, int64(500000), wallet.Available)
+	repo.AssertCalled(t, "UpdateWalletEntry", mock.Anything, mock.MatchedBy(func(e *model.WalletEntry) bool {
+		return e.ID == 8 && e.Status == model.WalletEntryReversed
+	}))
+	repo.AssertNotCalled(t, "CreateJournalEntry", mock.Anything, mock.Anything)
+}
+
+func TestWithdrawInsufficientFunds(t *testing.T) {
+	repo := new(MockRepository)
+	rail := new(mockPaymentRail)
+	uc := NewLoanUsecase(repo, WithPaymentRail(rail))
+
+	// Reserved funds can not be withdrawn.
+	repo.On("GetInvestorByID", mock.Anything, int64(100)).Return(&model.Investor{ID: 100}, nil)
+	repo.On("LockWallet", mock.Anything, int64(100)).Return(&model.Wallet{InvestorID: 100, Available: 100000, Reserved: 500000}, nil)
+
+	_, err := uc.Withdraw(context.Background(), 100, 300000)
+
+	assert.ErrorIs(t, err, model.ErrInsufficientFunds)
+	rail.AssertNotCalled(t, "Payout", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestResolvePendingTransfersSkipsResolvedEntries(t *testing.T) {
+	repo := new(MockRepository)
+	rail := new(mockPaymentRail)
+	uc := NewLoanUsecase(repo, WithPaymentRail(rail))
+
+	// The request settled the entry while the worker asked the rail again.
+	pending := &model.WalletEntry{ID: 7, InvestorID: 100, Type: model.WalletDeposit, Amount: 500000, Currency: model.DefaultCurrency, Status: model.WalletEntryPending, IdempotencyKey: sql.NullString{String: "key-7", Valid: true}}
+	settled := *pending
+	settled.Status = model.WalletEntrySettled
+	settled.Reference = sql.NullString{String: "ref-1", Valid: true}
+	repo.On("GetPendingWalletEntries", mock.Anything, mock.Anything, defaultTransferBatchSize).Return([]*model.WalletEntry{pending}, nil)
+	repo.On("LockWalletEntry", mock.Anything, int64(7)).Return(&settled, nil)
+	rail.On("Collect", mock.Anything, "key-7", int64(100), int64(500000), model.DefaultCurrency).Return("ref-1", nil)
+
+	resolved, err := NewTransferWorker(uc).ResolvePending(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, resolved)
+	assert.Equal(t, &settled, pending)
+	repo.AssertNotCalled(t, "LockWallet", mock.Anything, mock.Anything)
+	repo.AssertNotCalled(t, "UpdateWalletEntry", mock.Anything, mock.Anything)
+}
+
+func TestCreateInvestmentReservesFunds(t *testing.T) {
+	repo := new(MockRepository)
+	uc := NewLoanUsecase(repo)
+
+	loan := &model.Loan{ID: 1, PrincipalAmount: 1000000, State: model.LoanStateApproved}
+	wallet := &model.Wallet{InvestorID: 100, Available: 500000}
+
+	expectJournalEntries(repo)
+	repo.On("GetInvestorByID", mock.Anything, int64(100)).Return(&model.Investor{ID: 100}, nil)
+	repo.On("LockLoan", mock.Anything, int64(1)).Return(loan, nil)
+	repo.On("GetInvestmentsByLoanID", mock.Anything, int64(1)).Return([]*model.Investment{}, nil)
+	repo.On("GetWalletByInvestorID", mock.Anything, int64(100)).Return(&model.Wallet{InvestorID: 100, Available: 500000}, nil)
+	repo.On("CreateInvestment", mock.Anything, mock.Anything).Return(int64(3), nil)
+	repo.On("LockWallet", mock.Anything, int64(100)).Return(wallet, nil)
+	repo.On("UpdateWallet", mock.Anything, wallet).Return(nil)
+	repo.On("CreateWalletEntry", mock.Anything, mock.Anything).Return(int64(1), nil)
+	repo.On("CreateLoanEvent", mock.Anything, mock.Anything).Return(int64(1), nil)
+	repo.On("CreateOutboxMessage", mock.Anything, mock.Anything).Return(int64(1), nil)
+
+	_, err := uc.CreateInvestment(context.Background(), 100, 1, 400000)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(100000), wallet.Available)
+	assert.Equal(t, int64(400000), wallet.Reserved)
+	repo.AssertCalled(t, "CreateWalletEntry", mock.Anything, mock.MatchedBy(func(e *model.WalletEntry) bool {
+		return e.Type == model.WalletReservation && e.Amount == 400000 && e.InvestmentID.Int64 == 3 && e.LoanID.Int64 == 1
+	}))
+	repo.AssertNotCalled(t, "CreateWalletEntry", mock.Anything, mock.MatchedBy(func(e *model.WalletEntry) bool {
+		return e.Type == model.WalletRelease
+	}))
+}
+
+func TestCreateInvestmentReleasesReservationsWhenFullyFunded(t *testing.T) {
+	repo := new(MockRepository)
+	uc := NewLoanUsecase(repo)
+
+	loan := &model.Loan{ID: 1, PrincipalAmount: 1000000, State: model.LoanStateApproved}
+	investorWallet := &model.Wallet{InvestorID: 100, Available: 600000}
+	otherWallet := &model.Wallet{InvestorID: 101, Reserved: 400000}
+
+	expectJournalEntries(repo)
+	repo.On("GetInvestorByID", mock.Anything, int64(100)).Return(&model.Investor{ID: 100}, nil)
+	repo.On("LockLoan", mock.Anything, int64(1)).Return(loan, nil)
+	repo.On("GetInvestmentsByLoanID", mock.Anything, int64(1)).Return([]*model.Investment{{ID: 1, InvestorID: 101, LoanID: 1, Amount: 400000, SettlementAmount: 400000}}, nil)
+	repo.On("GetWalletByInvestorID", mock.Anything, int64(100)).Return(&model.Wallet{InvestorID: 100, Available: 600000}, nil)
+	repo.On("CreateInvestment", mock.Anything, mock.Anything).Return(int64(2), nil)
+	repo.On("LockWallet", mock.Anything, int64(100)).Return(investorWallet, nil)
+	repo.On("LockWallet", mock.Anything, int64(101)).Return(otherWallet, nil)
+	repo.On("UpdateWallet", mock.Anything, mock.Anything).Return(nil)
+	repo.On("CreateWalletEntry", mock.Anything, mock.Anything).Return(int64(1), nil)
+	repo.On("UpdateLoan", mock.Anything, loan).Return(nil)
+	repo.On("CreateLoanEvent", mock.Anything, mock.Anything).Return(int64(1), nil)
+	repo.On("CreateOutboxMessage", mock.Anything, mock.Anything).Return(int64(1), nil)
+
+	_, err := uc.CreateInvestment(context.Background(), 100, 1, 600000)
+
+	assert.NoError(t, err)
+	assert.Equal(t, &model.Wallet{InvestorID: 100, Deployed: 600000, LastUpdatedAt: investorWallet.LastUpdatedAt}, investorWallet)
+	assert.Equal(t, &model.Wallet{InvestorID: 101, Deployed: 400000, LastUpdatedAt: otherWallet.LastUpdatedAt}, otherWallet)
+	repo.AssertCalled(t, "CreateWalletEntry", mock.Anything, mock.MatchedBy(func(e *model.WalletEntry) bool {
+		return e.Type == model.WalletRelease && e.InvestorID == 101 && e.Amount == 400000 && e.InvestmentID.Int64 == 1
+	}))
+	repo.AssertCalled(t, "CreateWalletEntry", mock.Anything, mock.MatchedBy(func(e *model.WalletEntry) bool {
+		return e.Type == model.WalletRelease && e.InvestorID == 100 && e.Amount == 600000 && e.InvestmentID.Int64 == 2
+	}))
+}
+
+func TestCreateInvestmentInsufficientFunds(t *testing.T) {
+	repo := new(MockRepository)
+	uc := NewLoanUsecase(repo)
+
+	loan := &model.Loan{ID: 1, PrincipalAmount: 1000000, State: model.LoanStateApproved}
+
+	repo.On("GetInvestorByID", mock.Anything, int64(100)).Return(&model.Investor{ID: 100}, nil)
+	repo.On("LockLoan", mock.Anything, int64(1)).Return(loan, nil)
+	repo.On("GetInvestmentsByLoanID", mock.Anything, int64(1)).Return([]*model.Investment{}, nil)
+	repo.On("GetWalletByInvestorID", mock.Anything, int64(100)).Return(&model.Wallet{InvestorID: 100, Available: 300000}, nil)
+
+	_, err := uc.CreateInvestment(context.Background(), 100, 1, 400000)
+
+	assert.ErrorIs(t, err, model.ErrInsufficientFunds)
+	repo.AssertNotCalled(t, "CreateInvestment", mock.Anything, mock.Anything)
+}
+
+func TestCreateInvestmentBalanceSpentConcurrently(t *testing.T) {
+	repo := new(MockRepository)
+	uc := NewLoanUsecase(repo)
+
+	loan := &model.Loan{ID: 1, PrincipalAmount: 1000000, State: model.LoanStateApproved}
+
+	// The balance was spent by another investment between the early check and
+	// the lock, so the transaction is rolled back.
+	repo.On("GetInvestorByID", mock.Anything, int64(100)).Return(&model.Investor{ID: 100}, nil)
+	repo.On("LockLoan", mock.Anything, int64(1)).Return(loan, nil)
+	repo.On("GetInvestmentsByLoanID", mock.Anything, int64(1)).Return([]*model.Investment{}, nil)
+	repo.On("GetWalletByInvestorID", mock.Anything, int64(100)).Return(&model.Wallet{InvestorID: 100, Available: 400000}, nil)
+	repo.On("CreateInvestment", mock.Anything, mock.Anything).Return(int64(3), nil)
+	repo.On("LockWallet", mock.Anything, int64(100)).Return(&model.Wallet{InvestorID: 100}, nil)
+
+	_, err := uc.CreateInvestment(context.Background(), 100, 1, 400000)
+
+	assert.ErrorIs(t, err, model.ErrInsufficientFunds)
+	repo.AssertNotCalled(t, "CreateLoanEvent", mock.Anything, mock.Anything)
+	assert.Equal(t, model.LoanStateApproved, loan.State)
+}
+
+func TestCreateInvestmentWithoutWallet(t *testing.T) {
+	repo := new(MockRepository)
+	uc := NewLoanUsecase(repo)
+
+	repo.On("GetInvestorByID", mock.Anything, int64(100)).Return(&model.Investor{ID: 100}, nil)
+	repo.On("LockLoan", mock.Anything, int64(1)).Return(&model.Loan{ID: 1, PrincipalAmount: 1000000, State: model.LoanStateApproved}, nil)
+	repo.On("GetInvestmentsByLoanID", mock.Anything, int64(1)).Return([]*model.Investment{}, nil)
+	repo.On("GetWalletByInvestorID", mock.Anything, int64(100)).Return(nil, sql.ErrNoRows)
+
+	_, err := uc.CreateInvestment(context.Background(), 100, 1, 400000)
+
+	assert.ErrorIs(t, err, model.ErrInsufficientFunds)
+}
+
+func TestCreateInvestmentWalletError(t *testing.T) {
+	repo := new(MockRepository)
+	uc := NewLoanUsecase(repo)
+
+	repo.On("GetInvestorByID", mock.Anything, int64(100)).Return(&model.Investor{ID: 100}, nil)
+	repo.On("LockLoan", mock.Anything, int64(1)).Return(&model.Loan{ID: 1, PrincipalAmount: 1000000, State: model.LoanStateApproved}, nil)
+	repo.On("GetInvestmentsByLoanID", mock.Anything, int64(1)).Return([]*model.Investment{}, nil)
+	repo.On("GetWalletByInvestorID", mock.Anything, int64(100)).Return(nil, errors.New("connection refused"))
+
+	_, err := uc.CreateInvestment(context.Background(), 100, 1, 400000)
+
+	assert.EqualError(t, err, "connection refused")
+}
@@ -0,0 +1,329 @@
+package usecase
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/aldipi/loan-service/model"
+	"github.com/aldipi/loan-service/tracing"
+)
+
+const (
+	WebhookHeaderID        = "X-Webhook-Id"
+	WebhookHeaderEvent     = "X-Webhook-Event"
+	WebhookHeaderTimestamp = "X-Webhook-Timestamp"
+	WebhookHeaderSignature = "X-Webhook-Signature"
+
+	webhookSecretMinLength = 16
+
+	defaultWebhookInterval    = 5 * time.Second
+	defaultWebhookBatchSize   = 50
+	defaultWebhookMaxAttempts = 8
+	defaultWebhookMinBackoff  = 10 * time.Second
+	defaultWebhookMaxBackoff  = 6 * time.Hour
+	defaultWebhookTimeout     = 10 * time.Second
+)
+
+func (u *LoanUsecase) CreateWebhook(ctx context.Context, actor model.Actor, webhookURL string, eventTypes []string, secret string) (webhook *model.Webhook, err error) {
+	ctx, span := tracing.Start(ctx, "LoanUsecase.CreateWebhook")
+	defer func() { tracing.End(span, err) }()
+
+	if err := u.authorizeWebhooks(ctx, actor); err != nil {
+		return nil, err
+	}
+
+	parsed, err := url.Parse(webhookURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return nil, model.ErrWebhookInvalidURL
+	}
+	if err := checkPublicHost(ctx, parsed.Hostname()); err != nil {
+		return nil, model.ErrWebhookInvalidURL
+	}
+
+	if len(eventTypes) == 0 {
+		return nil, model.ErrWebhookInvalidEventType
+	}
+
+	webhook = &model.Webhook{
+		URL:    webhookURL,
+		Secret: secret,
+	}
+	for _, eventType := range eventTypes {
+		if !isLoanEventType(model.LoanEventType(eventType)) {
+			return nil, model.ErrWebhookInvalidEventType
+		}
+		webhook.EventTypes = append(webhook.EventTypes, model.LoanEventType(eventType))
+	}
+
+	if len(secret) < webhookSecretMinLength {
+		return nil, model.ErrWebhookInvalidSecret
+	}
+
+	webhookID, err := u.repo.CreateWebhook(ctx, webhook)
+	if err != nil {
+		return nil, err
+	}
+	webhook.ID = webhookID
+
+	return webhook, nil
+}
+
+func (u *LoanUsecase) GetWebhookDeliveries(ctx context.Context, actor model.Actor, webhookID int64, limit int, offset int) (deliveries []*model.WebhookDelivery, err error) {
+	ctx, span := tracing.Start(ctx, "LoanUsecase.GetWebhookDeliveries")
+	defer func() { tracing.End(span, err) }()
+
+	if err := u.authorizeWebhooks(ctx, actor); err != nil {
+		return nil, err
+	}
+
+	_, err = u.repo.GetWebhookByID(ctx, webhookID)
+	if err != nil {
+		return nil, model.ErrWebhookNotFound
+	}
+
+	deliveries, err = u.repo.GetWebhookDeliveriesByWebhookID(ctx, webhookID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	return deliveries, nil
+}
+
+// authorizeWebhooks allows only employees, since webhooks receive the events
+// of every loan.
+func (u *LoanUsecase) authorizeWebhooks(ctx context.Context, actor model.Actor) error {
+	if actor.Role != model.RoleEmployee {
+		return model.ErrWebhookAccessDenied
+	}
+	if _, err := u.repo.GetEmployeeByID(ctx, actor.ID); err != nil {
+		return model.ErrWebhookAccessDenied
+	}
+	return nil
+}
+
+func isLoanEventType(eventType model.LoanEventType) bool {
+	switch eventType {
+	case model.LoanEventProposed,
+		model.LoanEventApproved,
+		model.LoanEventInvestment,
+		model.LoanEventFullyFunded,
+		model.LoanEventDisbursed:
+		return true
+	}
+	return false
+}
+
+// SignWebhookPayload returns the value of the signature header for a request
+// body sent at the given unix timestamp. Receivers recompute it with their
+// secret to authenticate the request and reject stale timestamps to prevent
+// replays.
+func SignWebhookPayload(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// webhookBody is the JSON request body sent to webhooks.
+type webhookBody struct {
+	ID        int64               `json:"id"`
+	EventType model.LoanEventType `json:"event_type"`
+	LoanID    int64               `json:"loan_id"`
+	Data      json.RawMessage     `json:"data"`
+	CreatedAt time.Time           `json:"created_at"`
+}
+
+// WebhookPublisher is an EventPublisher that queues a delivery of the message
+// for every webhook subscribed to its event type.
+type WebhookPublisher struct {
+	repo Repository
+}
+
+func NewWebhookPublisher(repo Repository) *WebhookPublisher {
+	return &WebhookPublisher{repo: repo}
+}
+
+func (p *WebhookPublisher) Publish(ctx context.Context, message *model.OutboxMessage) error {
+	webhooks, err := p.repo.GetWebhooksByEventType(ctx, message.EventType)
+	if err != nil {
+		return err
+	}
+
+	if len(webhooks) == 0 {
+		return nil
+	}
+
+	body, err := json.Marshal(webhookBody{
+		ID:        message.ID,
+		EventType: message.EventType,
+		LoanID:    message.LoanID,
+		Data:      message.Payload,
+		CreatedAt: message.CreatedAt,
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, webhook := range webhooks {
+		err = p.repo.CreateWebhookDelivery(ctx, &model.WebhookDelivery{
+			WebhookID:       webhook.ID,
+			OutboxMessageID: message.ID,
+			EventType:       message.EventType,
+			Payload:         body,
+			Status:          model.WebhookDeliveryPending,
+			NextAttemptAt:   now(),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// WebhookDispatcher sends pending webhook deliveries. A delivery is retried
+// with exponential backoff until it gets a 2xx response, and is moved to the
+// dead state after MaxAttempts failed attempts.
+type WebhookDispatcher struct {
+	repo   Repository
+	client *http.Client
+	status workerStatus
+
+	Interval    time.Duration
+	BatchSize   int
+	MaxAttempts int
+	MinBackoff  time.Duration
+	MaxBackoff  time.Duration
+	Logger      *slog.Logger
+}
+
+func NewWebhookDispatcher(repo Repository, client *http.Client) *WebhookDispatcher {
+	if client == nil {
+		client = &http.Client{Timeout: defaultWebhookTimeout, Transport: tracing.NewTransport(publicTransport())}
+	}
+
+	return &WebhookDispatcher{
+		repo:        repo,
+		client:      client,
+		Interval:    defaultWebhookInterval,
+		BatchSize:   defaultWebhookBatchSize,
+		MaxAttempts: defaultWebhookMaxAttempts,
+		MinBackoff:  defaultWebhookMinBackoff,
+		MaxBackoff:  defaultWebhookMaxBackoff,
+		Logger:      slog.Default(),
+	}
+}
+
+// Run dispatches pending deliveries every Interval until ctx is cancelled.
+func (d *WebhookDispatcher) Run(ctx context.Context) {
+	d.status.setRunning(true)
+	defer d.status.setRunning(false)
+
+	ticker := time.NewTicker(d.Interval)
+	defer ticker.Stop()
+
+	for {
+		_, err := d.DispatchPending(ctx)
+		if ctx.Err() == nil {
+			d.status.record(err)
+			if err != nil {
+				d.Logger.ErrorContext(ctx, "dispatch webhook deliveries", "error", err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Check returns an error unless the dispatcher is running and its last batch
+// was dispatched. Deliveries rejected by their webhook do not fail the batch.
+func (d *WebhookDispatcher) Check(ctx context.Context) error {
+	return d.status.check()
+}
+
+// DispatchPending sends one batch of due deliveries and returns how many were
+// delivered successfully.
+func (d *WebhookDispatcher) DispatchPending(ctx context.Context) (int, error) {
+	deliveries, err := d.repo.GetPendingWebhookDeliveries(ctx, now(), d.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	var delivered int
+	for _, delivery := range deliveries {
+		webhook, err := d.repo.GetWebhookByID(ctx, delivery.WebhookID)
+		if err != nil {
+			return delivered, err
+		}
+
+		statusCode, err := d.send(ctx, webhook, delivery)
+
+		delivery.Attempts++
+		delivery.LastResponseStatus = sql.NullInt64{Int64: int64(statusCode), Valid: statusCode != 0}
+		if err == nil {
+			delivery.Status = model.WebhookDeliveryDelivered
+			delivery.LastError = sql.NullString{}
+			delivery.DeliveredAt = sql.NullTime{Time: now(), Valid: true}
+			delivered++
+		} else {
+			delivery.LastError = sql.NullString{String: err.Error(), Valid: true}
+			if delivery.Attempts >= d.MaxAttempts {
+				delivery.Status = model.WebhookDeliveryDead
+			} else {
+				delivery.NextAttemptAt = now().Add(backoff(delivery.Attempts-1, d.MinBackoff, d.MaxBackoff))
+			}
+			d.Logger.WarnContext(ctx, "deliver webhook", "delivery_id", delivery.ID, "webhook_id", webhook.ID, "attempts", delivery.Attempts, "status", delivery.Status, "error", err)
+		}
+
+		err = d.repo.UpdateWebhookDelivery(ctx, delivery)
+		if err != nil {
+			return delivered, err
+		}
+	}
+
+	return delivered, nil
+}
+
+// send posts the delivery to the webhook and returns the response status code,
+// or zero when no response was received.
+func (d *WebhookDispatcher) send(ctx context.Context, webhook *model.Webhook, delivery *model.WebhookDelivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+
+	timestamp := now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookHeaderID, strconv.FormatInt(delivery.OutboxMessageID, 10))
+	req.Header.Set(WebhookHeaderEvent, string(delivery.EventType))
+	req.Header.Set(WebhookHeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(WebhookHeaderSignature, SignWebhookPayload(webhook.Secret, timestamp, delivery.Payload))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,242 @@
+package usecase
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/aldipi/loan-service/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+var webhookEmployee = model.Actor{Role: model.RoleEmployee, ID: 555}
+
+// newWebhookUsecase returns a usecase whose repository knows webhookEmployee.
+func newWebhookUsecase() (*LoanUsecase, *MockRepository) {
+	repo := new(MockRepository)
+	repo.On("GetEmployeeByID", mock.Anything, webhookEmployee.ID).Return(&model.Employee{ID: webhookEmployee.ID}, nil)
+	return NewLoanUsecase(repo), repo
+}
+
+func TestCreateWebhook(t *testing.T) {
+	uc, repo := newWebhookUsecase()
+
+	repo.On("CreateWebhook", mock.Anything, mock.Anything).Return(int64(1), nil)
+
+	webhook, err := uc.CreateWebhook(context.Background(), webhookEmployee, "https://partner.example.com/hooks", []string{"LoanApproved", "LoanDisbursed"}, "0123456789abcdef")
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), webhook.ID)
+	assert.Equal(t, []model.LoanEventType{model.LoanEventApproved, model.LoanEventDisbursed}, webhook.EventTypes)
+}
+
+func TestCreateWebhookInvalid(t *testing.T) {
+	uc, repo := newWebhookUsecase()
+
+	_, err := uc.CreateWebhook(context.Background(), webhookEmployee, "ftp://partner.example.com", []string{"LoanApproved"}, "0123456789abcdef")
+	assert.ErrorIs(t, err, model.ErrWebhookInvalidURL)
+
+	_, err = uc.CreateWebhook(context.Background(), webhookEmployee, "https://partner.example.com/hooks", []string{"LoanRenamed"}, "0123456789abcdef")
+	assert.ErrorIs(t, err, model.ErrWebhookInvalidEventType)
+
+	_, err = uc.CreateWebhook(context.Background(), webhookEmployee, "https://partner.example.com/hooks", []string{}, "0123456789abcdef")
+	assert.ErrorIs(t, err, model.ErrWebhookInvalidEventType)
+
+	_, err = uc.CreateWebhook(context.Background(), webhookEmployee, "https://partner.example.com/hooks", []string{"LoanApproved"}, "short")
+	assert.ErrorIs(t, err, model.ErrWebhookInvalidSecret)
+
+	repo.AssertNotCalled(t, "CreateWebhook", mock.Anything, mock.Anything)
+}
+
+func TestCreateWebhookInternalHost(t *testing.T) {
+	uc, repo := newWebhookUsecase()
+
+	for _, webhookURL := range []string{
+		"http://localhost:8080/hooks",
+		"http://127.0.0.1/hooks",
+		"https://10.0.0.5/hooks",
+		"https://192.168.1.10/hooks",
+		"http://169.254.169.254/latest/meta-data",
+		"http://[::1]/hooks",
+		"http://[fe80::1]/hooks",
+		"http://0.0.0.0/hooks",
+	} {
+		_, err := uc.CreateWebhook(context.Background(), webhookEmployee, webhookURL, []string{"LoanApproved"}, "0123456789abcdef")
+		assert.ErrorIs(t, err, model.ErrWebhookInvalidURL, webhookURL)
+	}
+
+	repo.AssertNotCalled(t, "CreateWebhook", mock.Anything, mock.Anything)
+}
+
+func TestWebhooksRequireEmployee(t *testing.T) {
+	uc, repo := newWebhookUsecase()
+	repo.On("GetEmployeeByID", mock.Anything, int64(7)).Return(nil, sql.ErrNoRows)
+
+	for _, actor := range []model.Actor{
+		{Role: model.RoleInvestor, ID: webhookEmployee.ID},
+		{Role: model.RoleBorrower, ID: 2},
+		{Role: model.RoleEmployee, ID: 7},
+		{},
+	} {
+		_, err := uc.CreateWebhook(context.Background(), actor, "https://partner.example.com/hooks", []string{"LoanApproved"}, "0123456789abcdef")
+		assert.ErrorIs(t, err, model.ErrWebhookAccessDenied, actor)
+
+		_, err = uc.GetWebhookDeliveries(context.Background(), actor, 1, 10, 0)
+		assert.ErrorIs(t, err, model.ErrWebhookAccessDenied, actor)
+	}
+
+	repo.AssertNotCalled(t, "CreateWebhook", mock.Anything, mock.Anything)
+	repo.AssertNotCalled(t, "GetWebhookByID", mock.Anything, mock.Anything)
+}
+
+func TestWebhookDispatcherRefusesInternalAddress(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+	}))
+	defer server.Close()
+
+	dispatcher := NewWebhookDispatcher(new(MockRepository), nil)
+	req, err := http.NewRequest(http.MethodPost, server.URL, nil)
+	assert.NoError(t, err)
+
+	_, err = dispatcher.client.Do(req)
+
+	assert.ErrorIs(t, err, errInternalAddress)
+	assert.Zero(t, requests)
+}
+
+func TestGetWebhookDeliveriesWebhookNotFound(t *testing.T) {
+	uc, repo := newWebhookUsecase()
+
+	repo.On("GetWebhookByID", mock.Anything, int64(1)).Return(nil, model.ErrWebhookNotFound)
+
+	_, err := uc.GetWebhookDeliveries(context.Background(), webhookEmployee, 1, 10, 0)
+
+	assert.ErrorIs(t, err, model.ErrWebhookNotFound)
+}
+
+func TestWebhookPublisher(t *testing.T) {
+	repo := new(MockRepository)
+	p := NewWebhookPublisher(repo)
+
+	message := &model.OutboxMessage{ID: 3, EventType: model.LoanEventApproved, LoanID: 1, Payload: json.RawMessage(`{"employee_id":555}`)}
+
+	repo.On("GetWebhooksByEventType", mock.Anything, model.LoanEventApproved).Return([]*model.Webhook{{ID: 1}, {ID: 2}}, nil)
+	repo.On("CreateWebhookDelivery", mock.Anything, mock.Anything).Return(nil)
+
+	err := p.Publish(context.Background(), message)
+
+	assert.NoError(t, err)
+	repo.AssertNumberOfCalls(t, "CreateWebhookDelivery", 2)
+	repo.AssertCalled(t, "CreateWebhookDelivery", mock.Anything, mock.MatchedBy(func(delivery *model.WebhookDelivery) bool {
+		body := webhookBody{}
+		err := json.Unmarshal(delivery.Payload, &body)
+		return err == nil && delivery.WebhookID == 2 && delivery.OutboxMessageID == 3 &&
+			delivery.Status == model.WebhookDeliveryPending && body.LoanID == 1 && string(body.Data) == `{"employee_id":555}`
+	}))
+}
+
+func TestDispatchPendingSignsDelivery(t *testing.T) {
+	secret := "0123456789abcdef"
+	payload := []byte(`{"id":3,"event_type":"LoanApproved"}`)
+
+	var received *http.Request
+	var receivedBody []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r
+		receivedBody, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	repo := new(MockRepository)
+	dispatcher := NewWebhookDispatcher(repo, server.Client())
+
+	delivery := &model.WebhookDelivery{ID: 5, WebhookID: 1, OutboxMessageID: 3, EventType: model.LoanEventApproved, Payload: payload, Status: model.WebhookDeliveryPending}
+
+	repo.On("GetPendingWebhookDeliveries", mock.Anything, mock.Anything, defaultWebhookBatchSize).Return([]*model.WebhookDelivery{delivery}, nil)
+	repo.On("GetWebhookByID", mock.Anything, int64(1)).Return(&model.Webhook{ID: 1, URL: server.URL, Secret: secret}, nil)
+	repo.On("UpdateWebhookDelivery", mock.Anything, delivery).Return(nil)
+
+	delivered, err := dispatcher.DispatchPending(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, delivered)
+	assert.Equal(t, payload, receivedBody)
+	assert.Equal(t, "3", received.Header.Get(WebhookHeaderID))
+	assert.Equal(t, "LoanApproved", received.Header.Get(WebhookHeaderEvent))
+
+	timestamp, err := strconv.ParseInt(received.Header.Get(WebhookHeaderTimestamp), 10, 64)
+	assert.NoError(t, err)
+	assert.Equal(t, SignWebhookPayload(secret, timestamp, receivedBody), received.Header.Get(WebhookHeaderSignature))
+
+	assert.Equal(t, model.WebhookDeliveryDelivered, delivery.Status)
+	assert.Equal(t, 1, delivery.Attempts)
+	assert.Equal(t, int64(http.StatusNoContent), delivery.LastResponseStatus.Int64)
+	assert.True(t, delivery.DeliveredAt.Valid)
+}
+
+func TestDispatchPendingRetriesAndDeadLetters(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	repo := new(MockRepository)
+	dispatcher := NewWebhookDispatcher(repo, server.Client())
+
+	retried := &model.WebhookDelivery{ID: 5, WebhookID: 1, Payload: []byte(`{}`), Status: model.WebhookDeliveryPending, Attempts: 1}
+	dead := &model.WebhookDelivery{ID: 6, WebhookID: 1, Payload: []byte(`{}`), Status: model.WebhookDeliveryPending, Attempts: defaultWebhookMaxAttempts - 1}
+
+	repo.On("GetPendingWebhookDeliveries", mock.Anything, mock.Anything, defaultWebhookBatchSize).Return([]*model.WebhookDelivery{retried, dead}, nil)
+	repo.On("GetWebhookByID", mock.Anything, int64(1)).Return(&model.Webhook{ID: 1, URL: server.URL, Secret: "0123456789abcdef"}, nil)
+	repo.On("UpdateWebhookDelivery", mock.Anything, mock.Anything).Return(nil)
+
+	before := now()
+	delivered, err := dispatcher.DispatchPending(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, 0, delivered)
+
+	assert.Equal(t, model.WebhookDeliveryPending, retried.Status)
+	assert.Equal(t, 2, retried.Attempts)
+	assert.Equal(t, "unexpected status 500", retried.LastError.String)
+	assert.False(t, retried.NextAttemptAt.Before(before.Add(2*defaultWebhookMinBackoff)))
+
+	assert.Equal(t, model.WebhookDeliveryDead, dead.Status)
+	assert.Equal(t, defaultWebhookMaxAttempts, dead.Attempts)
+	assert.Equal(t, int64(http.StatusInternalServerError), dead.LastResponseStatus.Int64)
+}
+
+func TestWebhookDispatcherCheck(t *testing.T) {
+	repo := new(MockRepository)
+	dispatcher := NewWebhookDispatcher(repo, nil)
+	dispatcher.Interval = 10 * time.Millisecond
+
+	assert.ErrorIs(t, dispatcher.Check(context.Background()), errWorkerNotRunning)
+
+	repo.On("GetPendingWebhookDeliveries", mock.Anything, mock.Anything, defaultWebhookBatchSize).Return([]*model.WebhookDelivery{}, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		dispatcher.Run(ctx)
+		close(done)
+	}()
+
+	assert.Eventually(t, func() bool {
+		return dispatcher.Check(context.Background()) == nil
+	}, time.Second, 5*time.Millisecond)
+
+	cancel()
+	<-done
+	assert.ErrorIs(t, dispatcher.Check(context.Background()), errWorkerNotRunning)
+}
@@ -0,0 +1,44 @@
+package usecase
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+var errWorkerNotRunning = errors.New("not running")
+
+// workerStatus tracks whether a background worker is running and whether its
+// last run succeeded, for readiness checks.
+type workerStatus struct {
+	mu      sync.Mutex
+	running bool
+	lastErr error
+}
+
+func (s *workerStatus) setRunning(running bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.running = running
+}
+
+func (s *workerStatus) record(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastErr = err
+}
+
+// check returns an error unless the worker is running and its last run, if
+// any, succeeded.
+func (s *workerStatus) check() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.running {
+		return errWorkerNotRunning
+	}
+	if s.lastErr != nil {
+		return fmt.Errorf("last run failed: %w", s.lastErr)
+	}
+	return nil
+}